@@ -5,14 +5,19 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"flugo.com/config"
+	"flugo.com/events"
 	"flugo.com/logger"
+	"flugo.com/reqctx"
+	"flugo.com/response"
 	"flugo.com/router"
+	"flugo.com/utils"
 )
 
 type Claims struct {
@@ -23,6 +28,73 @@ type Claims struct {
 	Extra    map[string]interface{} `json:"extra,omitempty"`
 	Exp      int64                  `json:"exp"`
 	Iat      int64                  `json:"iat"`
+	Nbf      int64                  `json:"nbf,omitempty"`
+	// RememberMe stretches the access token to the refresh lifetime.
+	RememberMe bool `json:"remember_me,omitempty"`
+	// TenantID binds the token to a tenant; see tenantMatches.
+	TenantID string `json:"tenant_id,omitempty"`
+	// ClientID and Scopes are set on machine tokens, issued by
+	// TokenHandler, which have no user.
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	// SessionID names the Session of user tokens and TokenID makes every
+	// refresh token unique.
+	SessionID string `json:"sid,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	// Type tells access tokens from refresh tokens, which are only good
+	// for RefreshToken.
+	Type string `json:"typ,omitempty"`
+
+	device *device
+}
+
+type ClaimsOption func(*Claims)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// WithTenant binds the token to a tenant, typically reqctx.Tenant(r) at
+// login.
+func WithTenant(id reqctx.TenantID) ClaimsOption {
+	return func(c *Claims) {
+		c.TenantID = string(id)
+	}
+}
+
+// WithRememberMe issues a long-lived session, as for a "Remember Me" login.
+func WithRememberMe() ClaimsOption {
+	return func(c *Claims) {
+		c.RememberMe = true
+	}
+}
+
+// clockSkew tolerates small clock differences between services when checking
+// iat. nbf is enforced exactly, so a token issued ahead of time is rejected
+// until its start.
+const clockSkew = 5 * time.Minute
+
+// EventLoginFailed is emitted with a LoginFailedEvent whenever credentials
+// or a bearer token are rejected.
+const EventLoginFailed = "auth.login_failed"
+
+type LoginFailedEvent struct {
+	Identifier string
+	Reason     string
+	RemoteAddr string
+}
+
+// LoginFailed emits EventLoginFailed for a rejected login attempt.
+func LoginFailed(r *http.Request, identifier, reason string) {
+	err := events.Emit(r.Context(), EventLoginFailed, LoginFailedEvent{
+		Identifier: identifier,
+		Reason:     reason,
+		RemoteAddr: r.RemoteAddr,
+	})
+	if err != nil {
+		logger.Warn("auth.login_failed handlers failed: %v", err)
+	}
 }
 
 type Token struct {
@@ -36,6 +108,7 @@ type AuthService struct {
 	secretKey   []byte
 	expTime     time.Duration
 	refreshTime time.Duration
+	sessions    SessionStore
 }
 
 func NewAuthService(cfg *config.JWTConfig) *AuthService {
@@ -43,6 +116,7 @@ func NewAuthService(cfg *config.JWTConfig) *AuthService {
 		secretKey:   []byte(cfg.Secret),
 		expTime:     time.Duration(cfg.ExpirationTime) * time.Second,
 		refreshTime: time.Duration(cfg.RefreshTime) * time.Second,
+		sessions:    NewMemorySessionStore(),
 	}
 }
 
@@ -52,36 +126,116 @@ func Init(cfg *config.JWTConfig) {
 	DefaultAuthService = NewAuthService(cfg)
 }
 
-func (a *AuthService) GenerateToken(claims Claims) (*Token, error) {
+// GenerateToken issues an access and refresh token and starts a Session
+// for them. A Nbf in the future delays validity and the expiry is counted
+// from that moment instead of now. With RememberMe the access token lasts
+// the refresh time and the refresh token outlives it by the refresh time
+// again.
+func (a *AuthService) GenerateToken(claims Claims, opts ...ClaimsOption) (*Token, error) {
+	for _, opt := range opts {
+		opt(&claims)
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         newSessionID(),
+		UserID:     claims.UserID,
+		RememberMe: claims.RememberMe,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if claims.device != nil {
+		session.UserAgent = claims.device.userAgent
+		session.IPAddress = claims.device.ipAddress
+	}
+
+	token, refreshExp, err := a.issue(claims, session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.startSession(session, token, refreshExp); err != nil {
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+	return token, nil
+}
+
+// GenerateSessionlessToken issues a single access token valid for ttl,
+// without a session or refresh token. It is meant for tools that share the
+// JWT secret but not the server's session store, such as the cache:clear
+// command; keep ttl short, as such a token cannot be revoked.
+func (a *AuthService) GenerateSessionlessToken(claims Claims, ttl time.Duration) (*Token, error) {
 	now := time.Now()
 	claims.Iat = now.Unix()
-	claims.Exp = now.Add(a.expTime).Unix()
+	claims.Exp = now.Add(ttl).Unix()
+	claims.SessionID = ""
+	claims.Type = TokenTypeAccess
 
 	accessToken, err := a.createJWT(claims)
 	if err != nil {
 		return nil, err
 	}
+	return &Token{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+	}, nil
+}
+
+// issue signs the token pair of session sessionID, returning when the
+// refresh token expires.
+func (a *AuthService) issue(claims Claims, sessionID string, now time.Time) (*Token, time.Time, error) {
+	claims.Iat = now.Unix()
+	claims.SessionID = sessionID
+	claims.Type = TokenTypeAccess
+
+	accessTime, refreshTime := a.expTime, a.refreshTime
+	if claims.RememberMe {
+		accessTime = a.refreshTime
+		refreshTime = 2 * a.refreshTime
+	}
+
+	validFrom := now
+	if claims.Nbf > now.Unix() {
+		validFrom = time.Unix(claims.Nbf, 0)
+	}
+	claims.Exp = validFrom.Add(accessTime).Unix()
 
+	accessToken, err := a.createJWT(claims)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	refreshExp := validFrom.Add(refreshTime)
 	refreshClaims := Claims{
-		UserID: claims.UserID,
-		Exp:    now.Add(a.refreshTime).Unix(),
-		Iat:    now.Unix(),
+		UserID:     claims.UserID,
+		Exp:        refreshExp.Unix(),
+		Iat:        now.Unix(),
+		Nbf:        claims.Nbf,
+		RememberMe: claims.RememberMe,
+		TenantID:   claims.TenantID,
+		SessionID:  sessionID,
+		TokenID:    utils.MustSecureToken(8),
+		Type:       TokenTypeRefresh,
 	}
 
 	refreshToken, err := a.createJWT(refreshClaims)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
 	return &Token{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
-		ExpiresIn:    int64(a.expTime.Seconds()),
-	}, nil
+		ExpiresIn:    int64(accessTime.Seconds()),
+	}, refreshExp, nil
 }
 
 func (a *AuthService) createJWT(claims Claims) (string, error) {
+	if claims.Nbf == 0 {
+		claims.Nbf = claims.Iat
+	}
+
 	header := map[string]interface{}{
 		"alg": "HS256",
 		"typ": "JWT",
@@ -105,7 +259,42 @@ func (a *AuthService) sign(message string) string {
 	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
 }
 
+// ValidateToken checks an access token: its signature, its validity
+// period and, for user tokens, that its session was not revoked.
 func (a *AuthService) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := a.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeAccess {
+		return nil, fmt.Errorf("not an access token")
+	}
+	if claims.SessionID != "" {
+		if _, err := a.activeSession(claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// activeSession returns the session of claims unless it was revoked, has
+// expired or belongs to another user.
+func (a *AuthService) activeSession(claims *Claims) (*Session, error) {
+	session, err := a.sessions.Get(claims.SessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !session.active(time.Now()) || session.UserID != claims.UserID {
+		return nil, ErrSessionRevoked
+	}
+	return session, nil
+}
+
+// parse checks the signature and validity period of any token.
+func (a *AuthService) parse(tokenString string) (*Claims, error) {
 	parts := strings.Split(tokenString, ".")
 	if len(parts) != 3 {
 		return nil, fmt.Errorf("invalid token format")
@@ -128,27 +317,92 @@ func (a *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
-	if time.Now().Unix() > claims.Exp {
+	now := time.Now()
+	if now.Unix() > claims.Exp {
 		return nil, fmt.Errorf("token has expired")
 	}
 
+	if claims.Nbf > 0 && now.Unix() < claims.Nbf {
+		return nil, fmt.Errorf("token is not valid until %s", time.Unix(claims.Nbf, 0).Format(time.RFC3339))
+	}
+
+	if claims.Iat > now.Add(clockSkew).Unix() {
+		return nil, fmt.Errorf("token issued in the future")
+	}
+
 	return &claims, nil
 }
 
+// RefreshToken rotates a refresh token: it returns a new token pair for
+// the same session and the presented refresh token stops working. Presenting
+// it again revokes the session with ErrRefreshTokenReused.
 func (a *AuthService) RefreshToken(refreshTokenString string) (*Token, error) {
-	claims, err := a.ValidateToken(refreshTokenString)
+	claims, err := a.parse(refreshTokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID != "" {
+		return nil, fmt.Errorf("machine tokens cannot be refreshed")
+	}
+	if claims.Type != TokenTypeRefresh {
+		return nil, fmt.Errorf("not a refresh token")
+	}
+	if claims.SessionID == "" {
+		return nil, ErrSessionRevoked
+	}
+
+	session, err := a.activeSession(claims)
 	if err != nil {
 		return nil, err
 	}
 
 	newClaims := Claims{
-		UserID: claims.UserID,
+		UserID:     claims.UserID,
+		RememberMe: claims.RememberMe,
+		TenantID:   claims.TenantID,
+	}
+	token, refreshExp, err := a.issue(newClaims, session.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := a.rotate(session.ID, refreshTokenString, token, refreshExp); err != nil {
+		return nil, err
 	}
+	return token, nil
+}
 
-	return a.GenerateToken(newClaims)
+// Handlers returning values, see router.Handle, return these to answer with
+// a 401 or a 403.
+var (
+	ErrUnauthorized = errors.New("authentication required")
+	ErrForbidden    = errors.New("insufficient permissions")
+)
+
+func init() {
+	response.RegisterError(ErrUnauthorized, response.ErrorMapping{
+		Status: http.StatusUnauthorized, Code: "unauthorized", Message: "Authentication required",
+	})
+	response.RegisterError(ErrForbidden, response.ErrorMapping{
+		Status: http.StatusForbidden, Code: "forbidden", Message: "Insufficient permissions",
+	})
 }
 
+// CurrentUser returns the authenticated user, or ErrUnauthorized.
+func CurrentUser(r *http.Request) (*Claims, error) {
+	user := GetCurrentUser(r)
+	if user == nil {
+		return nil, ErrUnauthorized
+	}
+	return user, nil
+}
+
+// RequireAuth admits requests with a valid user access token. Machine
+// tokens are refused; routes for service clients use RequireScopes.
 func RequireAuth() router.MiddlewareFunc {
+	return requireAuth(false)
+}
+
+func requireAuth(allowClients bool) router.MiddlewareFunc {
 	return func(next router.HandlerFunc) router.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			token := extractToken(r)
@@ -160,17 +414,30 @@ func RequireAuth() router.MiddlewareFunc {
 			claims, err := DefaultAuthService.ValidateToken(token)
 			if err != nil {
 				logger.Warn("Invalid token: %v", err)
+				LoginFailed(r, "", err.Error())
 				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 				return
 			}
 
-			SetCurrentUser(r, claims)
-			next(w, r)
+			if claims.ClientID != "" && !allowClients {
+				logger.Warn("Token of client %q used on a user route", claims.ClientID)
+				http.Error(w, "User token required", http.StatusForbidden)
+				return
+			}
+
+			if !tenantMatches(r, claims) {
+				logger.Warn("Token for tenant %q used on tenant %q", claims.TenantID, reqctx.Tenant(r))
+				http.Error(w, "Token does not belong to this tenant", http.StatusForbidden)
+				return
+			}
+
+			next(response.WithRoles(w, claims.Roles), reqctx.WithClaims(r, claims))
 		}
 	}
 }
 
 func RequireRoles(roles ...string) router.MiddlewareFunc {
+	required := utils.NewSet(roles...)
 	return func(next router.HandlerFunc) router.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			user := GetCurrentUser(r)
@@ -179,7 +446,7 @@ func RequireRoles(roles ...string) router.MiddlewareFunc {
 				return
 			}
 
-			if !hasAnyRole(user.Roles, roles) {
+			if !hasAnyRole(user.Roles, required) {
 				http.Error(w, "Insufficient permissions", http.StatusForbidden)
 				return
 			}
@@ -194,8 +461,9 @@ func OptionalAuth() router.MiddlewareFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			token := extractToken(r)
 			if token != "" {
-				if claims, err := DefaultAuthService.ValidateToken(token); err == nil {
-					SetCurrentUser(r, claims)
+				if claims, err := DefaultAuthService.ValidateToken(token); err == nil && claims.ClientID == "" && tenantMatches(r, claims) {
+					w = response.WithRoles(w, claims.Roles)
+					r = reqctx.WithClaims(r, claims)
 				}
 			}
 			next(w, r)
@@ -203,6 +471,14 @@ func OptionalAuth() router.MiddlewareFunc {
 	}
 }
 
+// tenantMatches checks a token against the tenant resolved by
+// middleware.Tenant. Requests without a tenant accept any token; on a
+// tenant's requests the token must carry that tenant.
+func tenantMatches(r *http.Request, claims *Claims) bool {
+	tenant := reqctx.Tenant(r)
+	return tenant == "" || claims.TenantID == string(tenant)
+}
+
 func extractToken(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -217,43 +493,25 @@ func extractToken(r *http.Request) string {
 	return parts[1]
 }
 
-func hasAnyRole(userRoles, requiredRoles []string) bool {
-	for _, required := range requiredRoles {
-		for _, userRole := range userRoles {
-			if userRole == required {
-				return true
-			}
+func hasAnyRole(userRoles []string, required utils.Set[string]) bool {
+	for _, role := range userRoles {
+		if required.Contains(role) {
+			return true
 		}
 	}
 	return false
 }
 
-type contextKey string
-
-const userContextKey contextKey = "current_user"
-
+// SetCurrentUser attaches claims to r in place, for code that cannot pass a
+// derived request on; middleware should call next with reqctx.WithClaims.
 func SetCurrentUser(r *http.Request, claims *Claims) {
-	ctx := r.Context()
-	*r = *r.WithContext(ctx)
-	r.Header.Set("X-Current-User", fmt.Sprintf("%d", claims.UserID))
+	*r = *reqctx.WithClaims(r, claims)
 }
 
+// GetCurrentUser returns the claims stored by RequireAuth or OptionalAuth,
+// or nil.
 func GetCurrentUser(r *http.Request) *Claims {
-	userID := r.Header.Get("X-Current-User")
-	if userID == "" {
-		return nil
-	}
-
-	token := extractToken(r)
-	if token == "" {
-		return nil
-	}
-
-	claims, err := DefaultAuthService.ValidateToken(token)
-	if err != nil {
-		return nil
-	}
-
+	claims, _ := reqctx.Claims(r).(*Claims)
 	return claims
 }
 
@@ -265,11 +523,11 @@ func GetCurrentUserID(r *http.Request) int {
 	return user.UserID
 }
 
-func GenerateToken(claims Claims) (*Token, error) {
+func GenerateToken(claims Claims, opts ...ClaimsOption) (*Token, error) {
 	if DefaultAuthService == nil {
 		return nil, fmt.Errorf("auth service not initialized")
 	}
-	return DefaultAuthService.GenerateToken(claims)
+	return DefaultAuthService.GenerateToken(claims, opts...)
 }
 
 func ValidateToken(token string) (*Claims, error) {
@@ -286,6 +544,37 @@ func RefreshToken(refreshToken string) (*Token, error) {
 	return DefaultAuthService.RefreshToken(refreshToken)
 }
 
+// GenerateCodeVerifier returns a PKCE code verifier (RFC 7636): 43
+// characters of URL-safe base64 encoding 32 random bytes.
+func GenerateCodeVerifier() string {
+	return utils.MustSecureToken(32)
+}
+
+// ComputeCodeChallenge returns the S256 challenge BASE64URL(SHA256(verifier)).
+func ComputeCodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	if !validCodeVerifier(verifier) {
+		return false
+	}
+	return hmac.Equal([]byte(ComputeCodeChallenge(verifier)), []byte(challenge))
+}
+
+func validCodeVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
 // JWTConfig is an alias for config.JWTConfig for backward compatibility
 type JWTConfig struct {
 	Secret         string
@@ -0,0 +1,61 @@
+package auth_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+)
+
+// signHS256 signs claims the way another service sharing the secret would.
+func signHS256(t *testing.T, secret string, claims auth.Claims) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(message))
+	return message + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestValidateTokenToleratesIssuerClockAhead(t *testing.T) {
+	service := newSessionService(t, nil)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		iat    time.Duration
+		nbf    time.Duration
+		accept bool
+	}{
+		{"issuer 30s ahead", 30 * time.Second, 0, true},
+		{"issuer 4m ahead", 4 * time.Minute, 0, true},
+		{"issuer 10m ahead", 10 * time.Minute, 0, false},
+		{"nbf 30s ahead", 0, 30 * time.Second, false},
+		{"issuer and nbf 4m ahead", 4 * time.Minute, 4 * time.Minute, false},
+		{"not valid for an hour", 0, time.Hour, false},
+		{"nbf passed", 0, -time.Second, true},
+	}
+	for _, tt := range tests {
+		claims := auth.Claims{
+			Username: "svc",
+			Type:     auth.TokenTypeAccess,
+			Iat:      now.Add(tt.iat).Unix(),
+			Exp:      now.Add(2 * time.Hour).Unix(),
+		}
+		if tt.nbf != 0 {
+			claims.Nbf = now.Add(tt.nbf).Unix()
+		}
+		_, err := service.ValidateToken(signHS256(t, "test-secret", claims))
+		if accepted := err == nil; accepted != tt.accept {
+			t.Errorf("%s: ValidateToken error = %v, want accepted %v", tt.name, err, tt.accept)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/database/schema"
+	"flugo.com/logger"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/utils"
+)
+
+// Client is a service allowed to get machine tokens with the client
+// credentials grant. Its tokens carry the client ID and scopes instead of a
+// user.
+type Client struct {
+	ID         string
+	SecretHash string
+	Scopes     []string
+}
+
+// ClientStore looks registered clients up by ID, returning
+// ErrClientNotFound for unknown ones.
+type ClientStore interface {
+	FindClient(id string) (*Client, error)
+}
+
+var ErrClientNotFound = errors.New("client not found")
+
+// GenerateClientSecret returns a random secret for a new client.
+func GenerateClientSecret() string {
+	return utils.MustSecureToken(32)
+}
+
+// HashClientSecret returns the hash stored for a client secret. Secrets
+// come from GenerateClientSecret and are long random strings, not
+// passwords, so a single SHA-256 is enough to keep them out of config and
+// the database.
+func HashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// unknownClientHash is compared against for unknown client IDs, so they
+// take as long to reject as a wrong secret.
+var unknownClientHash = HashClientSecret("unknown client")
+
+func secretMatches(client *Client, secret string) bool {
+	want := unknownClientHash
+	if client != nil {
+		want = client.SecretHash
+	}
+	match := subtle.ConstantTimeCompare([]byte(HashClientSecret(secret)), []byte(strings.ToLower(want))) == 1
+	return match && client != nil
+}
+
+type staticClients map[string]*Client
+
+// StaticClients is a ClientStore over a fixed list of clients.
+func StaticClients(clients ...Client) ClientStore {
+	store := make(staticClients, len(clients))
+	for i := range clients {
+		store[clients[i].ID] = &clients[i]
+	}
+	return store
+}
+
+// ClientsFromConfig is a ClientStore over the clients of the JWT config.
+func ClientsFromConfig(clients []config.ClientConfig) ClientStore {
+	list := make([]Client, len(clients))
+	for i, c := range clients {
+		list[i] = Client{ID: c.ID, SecretHash: c.SecretHash, Scopes: c.Scopes}
+	}
+	return StaticClients(list...)
+}
+
+func (s staticClients) FindClient(id string) (*Client, error) {
+	if client, ok := s[id]; ok {
+		return client, nil
+	}
+	return nil, ErrClientNotFound
+}
+
+// DBClientStore keeps clients in the oauth_clients table, with their scopes
+// space-separated.
+type DBClientStore struct {
+	db *database.DB
+}
+
+// ClientsMigration creates the oauth_clients table of DBClientStore.
+// Register it with database.RegisterMigration and run the migrations before
+// using the store.
+var ClientsMigration = schema.Migration("20240201000002", "create_oauth_clients",
+	schema.CreateIfNotExists("oauth_clients", func(t *schema.Table) {
+		t.String("client_id", 255)
+		t.String("secret_hash", 64)
+		t.Text("scopes")
+		t.Timestamp("created_at")
+		t.Primary("client_id")
+	}),
+	schema.DropIfExists("oauth_clients"))
+
+// DatabaseClients stores clients in db, or in DefaultDB when db is nil.
+func DatabaseClients(db *database.DB) *DBClientStore {
+	return &DBClientStore{db: db}
+}
+
+// Register adds a client or replaces its secret and scopes.
+func (s *DBClientStore) Register(id, secret string, scopes ...string) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec("DELETE FROM oauth_clients WHERE client_id = ?", id); err != nil {
+		return fmt.Errorf("failed to register client: %w", err)
+	}
+	_, err = db.Exec("INSERT INTO oauth_clients (client_id, secret_hash, scopes, created_at) VALUES (?, ?, ?, ?)",
+		id, HashClientSecret(secret), strings.Join(scopes, " "), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to register client: %w", err)
+	}
+	return nil
+}
+
+func (s *DBClientStore) FindClient(id string) (*Client, error) {
+	db, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	client := Client{ID: id}
+	var scopes string
+	err = db.QueryRow("SELECT secret_hash, scopes FROM oauth_clients WHERE client_id = ?", id).Scan(&client.SecretHash, &scopes)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up client: %w", err)
+	}
+	client.Scopes = strings.Fields(scopes)
+	return &client, nil
+}
+
+func (s *DBClientStore) conn() (*database.DB, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+	if database.DefaultDB == nil {
+		return nil, database.ErrNotInitialized
+	}
+	return database.DefaultDB, nil
+}
+
+// GenerateClientToken issues an access token for a client. Machine tokens
+// have no refresh token; clients request a new one when it expires.
+func (a *AuthService) GenerateClientToken(clientID string, scopes []string) (*Token, error) {
+	now := time.Now()
+	accessToken, err := a.createJWT(Claims{
+		ClientID: clientID,
+		Scopes:   scopes,
+		Iat:      now.Unix(),
+		Exp:      now.Add(a.expTime).Unix(),
+		Type:     TokenTypeAccess,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Token{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(a.expTime.Seconds()),
+	}, nil
+}
+
+// TokenIssueLimit caps token requests per client ID and per remote
+// address, failed ones included, over TokenIssueWindow.
+var (
+	TokenIssueLimit  = 10
+	TokenIssueWindow = time.Minute
+)
+
+// issueAttempts counts token requests in fixed windows.
+var issueAttempts = struct {
+	sync.Mutex
+	windows map[string]*issueWindow
+}{windows: make(map[string]*issueWindow)}
+
+type issueWindow struct {
+	start time.Time
+	count int
+}
+
+// allowIssue counts a request for each key, returning how long to wait
+// when one of them is over the limit.
+func allowIssue(keys ...string) (bool, time.Duration) {
+	issueAttempts.Lock()
+	defer issueAttempts.Unlock()
+
+	now := time.Now()
+	for key, w := range issueAttempts.windows {
+		if now.Sub(w.start) >= TokenIssueWindow {
+			delete(issueAttempts.windows, key)
+		}
+	}
+
+	var wait time.Duration
+	for _, key := range keys {
+		w, ok := issueAttempts.windows[key]
+		if !ok {
+			w = &issueWindow{start: now}
+			issueAttempts.windows[key] = w
+		}
+		w.count++
+		if w.count > TokenIssueLimit {
+			wait = max(wait, w.start.Add(TokenIssueWindow).Sub(now))
+		}
+	}
+	return wait == 0, wait
+}
+
+// ResetTokenIssueLimits clears the token request counters.
+func ResetTokenIssueLimits() {
+	issueAttempts.Lock()
+	defer issueAttempts.Unlock()
+	issueAttempts.windows = make(map[string]*issueWindow)
+}
+
+// TokenHandler serves the OAuth 2.0 client credentials grant (RFC 6749
+// section 4.4):
+//
+//	r.POST("/oauth/token", auth.TokenHandler(auth.ClientsFromConfig(cfg.JWT.Clients)))
+//
+// Clients authenticate with HTTP Basic auth or client_id and client_secret
+// form fields and may narrow their scopes with a space-separated scope
+// field; by default a token gets all of the client's scopes. Answers and
+// errors use the OAuth JSON format rather than the response envelope, so
+// standard client libraries understand them.
+func TokenHandler(store ClientStore) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			tokenError(w, http.StatusBadRequest, "invalid_request", "Request body must be form encoded")
+			return
+		}
+		if grant := r.PostForm.Get("grant_type"); grant != "client_credentials" {
+			tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "Only the client_credentials grant is supported")
+			return
+		}
+
+		// Basic credentials are form-encoded first (RFC 6749 section 2.3.1).
+		clientID, secret, basic := r.BasicAuth()
+		if basic {
+			clientID, _ = url.QueryUnescape(clientID)
+			secret, _ = url.QueryUnescape(secret)
+		} else {
+			clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if clientID == "" || secret == "" {
+			tokenError(w, http.StatusUnauthorized, "invalid_client", "Client credentials required")
+			return
+		}
+
+		if ok, wait := allowIssue("client:"+clientID, "addr:"+remoteHost(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+			tokenError(w, http.StatusTooManyRequests, "slow_down", "Too many token requests")
+			return
+		}
+
+		client, err := store.FindClient(clientID)
+		if err != nil && !errors.Is(err, ErrClientNotFound) {
+			logger.Error("Client lookup failed: %v", err)
+			tokenError(w, http.StatusInternalServerError, "server_error", "Client lookup failed")
+			return
+		}
+		if !secretMatches(client, secret) {
+			LoginFailed(r, clientID, "invalid client credentials")
+			if basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			}
+			tokenError(w, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+			return
+		}
+
+		scopes := client.Scopes
+		if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+			for _, scope := range requested {
+				if !slices.Contains(client.Scopes, scope) {
+					tokenError(w, http.StatusBadRequest, "invalid_scope", "Scope "+scope+" is not allowed for this client")
+					return
+				}
+			}
+			scopes = requested
+		}
+
+		if DefaultAuthService == nil {
+			tokenError(w, http.StatusInternalServerError, "server_error", "Auth service not initialized")
+			return
+		}
+		token, err := DefaultAuthService.GenerateClientToken(client.ID, scopes)
+		if err != nil {
+			tokenError(w, http.StatusInternalServerError, "server_error", "Failed to issue token")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		response.JSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": token.AccessToken,
+			"token_type":   token.TokenType,
+			"expires_in":   token.ExpiresIn,
+			"scope":        strings.Join(scopes, " "),
+		})
+	}
+}
+
+func tokenError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// HasScope reports whether a machine token carries scope.
+func (c *Claims) HasScope(scope string) bool {
+	return c.ClientID != "" && slices.Contains(c.Scopes, scope)
+}
+
+// RequireScopes admits machine tokens carrying every one of scopes. It
+// authenticates the request itself and is the only middleware that accepts
+// machine tokens, which RequireAuth and OptionalAuth refuse. User tokens are
+// forbidden, as machine tokens are by RequireRoles, so one route does not
+// serve both by accident.
+func RequireScopes(scopes ...string) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		check := func(w http.ResponseWriter, r *http.Request) {
+			claims := GetCurrentUser(r)
+			for _, scope := range scopes {
+				if !claims.HasScope(scope) {
+					http.Error(w, "Insufficient scope", http.StatusForbidden)
+					return
+				}
+			}
+			next(w, r)
+		}
+		authenticated := requireAuth(true)(check)
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if GetCurrentUser(r) != nil {
+				check(w, r)
+				return
+			}
+			authenticated(w, r)
+		}
+	}
+}
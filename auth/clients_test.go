@@ -0,0 +1,159 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/container"
+	"flugo.com/httpclient"
+	"flugo.com/router"
+)
+
+const billingSecret = "billing-secret"
+
+func newServiceServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	auth.Init(&config.JWTConfig{Secret: "test-secret", ExpirationTime: 3600, RefreshTime: 86400})
+
+	r := router.NewRouter(container.NewContainer())
+	r.POST("/oauth/token", auth.TokenHandler(auth.StaticClients(auth.Client{
+		ID:         "billing",
+		SecretHash: auth.HashClientSecret(billingSecret),
+		Scopes:     []string{"reports:read", "reports:write"},
+	})))
+	r.GET("/reports", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.GetCurrentUser(r).ClientID))
+	}, auth.RequireScopes("reports:read"))
+	r.GET("/admin", func(w http.ResponseWriter, r *http.Request) {}, auth.RequireAuth(), auth.RequireRoles("admin"))
+	r.GET("/profile", func(w http.ResponseWriter, r *http.Request) {}, auth.RequireAuth())
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func requestToken(t *testing.T, server *httptest.Server, form url.Values) (int, map[string]interface{}) {
+	t.Helper()
+	form.Set("grant_type", "client_credentials")
+	resp, err := http.PostForm(server.URL+"/oauth/token", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestClientCredentialsFlow(t *testing.T) {
+	server := newServiceServer(t)
+	client := httpclient.New(httpclient.Options{
+		BaseURL: server.URL,
+		TokenSource: &httpclient.ClientCredentials{
+			TokenURL:     server.URL + "/oauth/token",
+			ClientID:     "billing",
+			ClientSecret: billingSecret,
+			Scopes:       []string{"reports:read"},
+		},
+	})
+
+	resp, err := client.Get(context.Background(), "/reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "billing" {
+		t.Errorf("scoped route = %d %s", resp.StatusCode, resp.Body)
+	}
+
+	// Machine tokens have no roles.
+	if resp, _ := client.Get(context.Background(), "/admin"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("role route with a machine token = %d, want 403", resp.StatusCode)
+	}
+
+	// Machine tokens do not pass for a user.
+	if resp, _ := client.Get(context.Background(), "/profile"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("user route with a machine token = %d, want 403", resp.StatusCode)
+	}
+
+	// User tokens have no scopes.
+	user, _ := auth.GenerateToken(auth.Claims{UserID: 1, Roles: []string{"admin"}})
+	req, _ := http.NewRequest("GET", server.URL+"/reports", nil)
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	userResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userResp.Body.Close()
+	if userResp.StatusCode != http.StatusForbidden {
+		t.Errorf("scoped route with a user token = %d, want 403", userResp.StatusCode)
+	}
+}
+
+func TestTokenRequestErrors(t *testing.T) {
+	server := newServiceServer(t)
+
+	for name, tc := range map[string]struct {
+		form   url.Values
+		status int
+		code   string
+	}{
+		"wrong secret":   {url.Values{"client_id": {"billing"}, "client_secret": {"guess"}}, 401, "invalid_client"},
+		"unknown client": {url.Values{"client_id": {"nobody"}, "client_secret": {billingSecret}}, 401, "invalid_client"},
+		"foreign scope":  {url.Values{"client_id": {"billing"}, "client_secret": {billingSecret}, "scope": {"users:write"}}, 400, "invalid_scope"},
+	} {
+		status, body := requestToken(t, server, tc.form)
+		if status != tc.status || body["error"] != tc.code {
+			t.Errorf("%s: %d %v, want %d %s", name, status, body, tc.status, tc.code)
+		}
+	}
+
+	status, body := requestToken(t, server, url.Values{"client_id": {"billing"}, "client_secret": {billingSecret}})
+	if status != http.StatusOK || body["scope"] != "reports:read reports:write" || body["token_type"] != "Bearer" {
+		t.Errorf("token answer = %d %v", status, body)
+	}
+}
+
+func TestTokenIssueRateLimit(t *testing.T) {
+	server := newServiceServer(t)
+	limit := auth.TokenIssueLimit
+	auth.TokenIssueLimit = 3
+	auth.ResetTokenIssueLimits()
+	t.Cleanup(func() {
+		auth.TokenIssueLimit = limit
+		auth.ResetTokenIssueLimits()
+	})
+
+	form := url.Values{"client_id": {"limited"}, "client_secret": {"guess"}}
+	for i := 0; i < 3; i++ {
+		if status, _ := requestToken(t, server, form); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d", i+1, status)
+		}
+	}
+	if status, body := requestToken(t, server, form); status != http.StatusTooManyRequests || body["error"] != "slow_down" {
+		t.Errorf("attempt over the limit = %d %v", status, body)
+	}
+}
+
+func TestDatabaseClients(t *testing.T) {
+	store := auth.DatabaseClients(migratedDB(t))
+	if err := store.Register("billing", billingSecret, "reports:read", "reports:write"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := store.FindClient("billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.SecretHash != auth.HashClientSecret(billingSecret) || strings.Join(client.Scopes, ",") != "reports:read,reports:write" {
+		t.Errorf("client = %+v", client)
+	}
+	if _, err := store.FindClient("nobody"); err != auth.ErrClientNotFound {
+		t.Errorf("unknown client error = %v", err)
+	}
+}
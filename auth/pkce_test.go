@@ -0,0 +1,41 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"flugo.com/auth"
+)
+
+func TestCodeChallengeRFC7636(t *testing.T) {
+	// The S256 example from RFC 7636, Appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := auth.ComputeCodeChallenge(verifier); got != challenge {
+		t.Errorf("ComputeCodeChallenge = %s, want %s", got, challenge)
+	}
+	if !auth.VerifyCodeChallenge(verifier, challenge) {
+		t.Error("VerifyCodeChallenge rejected the RFC 7636 example")
+	}
+	if auth.VerifyCodeChallenge(verifier, strings.ToLower(challenge)) {
+		t.Error("VerifyCodeChallenge accepted a different challenge")
+	}
+}
+
+func TestVerifyCodeChallengeRejectsInvalidVerifiers(t *testing.T) {
+	for name, verifier := range map[string]string{
+		"short":   strings.Repeat("a", 42),
+		"long":    strings.Repeat("a", 129),
+		"charset": strings.Repeat("a", 42) + "+",
+	} {
+		if auth.VerifyCodeChallenge(verifier, auth.ComputeCodeChallenge(verifier)) {
+			t.Errorf("%s verifier accepted", name)
+		}
+	}
+
+	verifier := auth.GenerateCodeVerifier()
+	if len(verifier) != 43 || !auth.VerifyCodeChallenge(verifier, auth.ComputeCodeChallenge(verifier)) {
+		t.Errorf("generated verifier %q does not verify", verifier)
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"flugo.com/database"
+	"flugo.com/database/schema"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore keeps the refresh token sessions of users. Rotate must
+// compare and swap atomically, so that of two refreshes with the same
+// token only one succeeds.
+type SessionStore interface {
+	Create(session *Session) error
+	Get(id string) (*Session, error)
+	// Rotate replaces the token hash of session id with newHash and moves
+	// its expiry, but only while its hash is still oldHash and it is not
+	// revoked. It reports whether it did.
+	Rotate(id, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	Revoke(id string) error
+	// List returns the sessions of userID that are neither revoked nor
+	// expired, most recently used first.
+	List(userID int) ([]*Session, error)
+}
+
+// MemorySessionStore keeps sessions in process memory, so they end with the
+// process; it is the default store.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (s *MemorySessionStore) Create(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range s.sessions {
+		if now.After(existing.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	stored := *session
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+func (s *MemorySessionStore) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	found := *session
+	return &found, nil
+}
+
+func (s *MemorySessionStore) Rotate(id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.Revoked || session.TokenHash != oldHash {
+		return false, nil
+	}
+	session.TokenHash = newHash
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = time.Now()
+	return true, nil
+}
+
+func (s *MemorySessionStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	session.Revoked = true
+	return nil
+}
+
+func (s *MemorySessionStore) List(userID int) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var sessions []*Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.active(now) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+func sortSessions(sessions []*Session) {
+	slices.SortFunc(sessions, func(a, b *Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+}
+
+// DBSessionStore keeps sessions in the auth_sessions table so they survive
+// restarts and are shared between instances.
+type DBSessionStore struct {
+	db *database.DB
+}
+
+// SessionsMigration creates the auth_sessions table of DBSessionStore.
+// Register it with database.RegisterMigration and run the migrations before
+// using the store.
+var SessionsMigration = schema.Migration("20240201000001", "create_auth_sessions",
+	schema.CreateIfNotExists("auth_sessions", func(t *schema.Table) {
+		t.String("id", 64)
+		t.Integer("user_id")
+		t.String("token_hash", 64)
+		t.Text("user_agent")
+		t.String("ip_address", 64)
+		t.Boolean("remember_me")
+		t.Timestamp("created_at")
+		t.Timestamp("last_used_at")
+		t.Timestamp("expires_at")
+		t.Boolean("revoked")
+		t.Primary("id")
+		t.Index("user_id")
+	}),
+	schema.DropIfExists("auth_sessions"))
+
+// DatabaseSessions stores sessions in db, or in DefaultDB when db is nil.
+func DatabaseSessions(db *database.DB) *DBSessionStore {
+	return &DBSessionStore{db: db}
+}
+
+const sessionColumns = "id, user_id, token_hash, user_agent, ip_address, remember_me, created_at, last_used_at, expires_at, revoked"
+
+func (s *DBSessionStore) Create(session *Session) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO auth_sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.UserID, session.TokenHash, session.UserAgent, session.IPAddress, session.RememberMe,
+		session.CreatedAt, session.LastUsedAt, session.ExpiresAt, session.Revoked)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (s *DBSessionStore) Get(id string) (*Session, error) {
+	db, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	err = db.QueryRow("SELECT "+sessionColumns+" FROM auth_sessions WHERE id = ?", id).Scan(sessionFields(&session)...)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *DBSessionStore) Rotate(id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	db, err := s.conn()
+	if err != nil {
+		return false, err
+	}
+
+	result, err := db.Exec("UPDATE auth_sessions SET token_hash = ?, expires_at = ?, last_used_at = ? WHERE id = ? AND token_hash = ? AND revoked = ?",
+		newHash, expiresAt, time.Now(), id, oldHash, false)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate session: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate session: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (s *DBSessionStore) Revoke(id string) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("UPDATE auth_sessions SET revoked = ? WHERE id = ?", true, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *DBSessionStore) List(userID int) ([]*Session, error) {
+	db, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryRows("SELECT "+sessionColumns+" FROM auth_sessions WHERE user_id = ? AND revoked = ? AND expires_at > ? ORDER BY last_used_at DESC",
+		userID, false, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(sessionFields(&session)...); err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+func sessionFields(s *Session) []interface{} {
+	return []interface{}{&s.ID, &s.UserID, &s.TokenHash, &s.UserAgent, &s.IPAddress, &s.RememberMe,
+		&s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.Revoked}
+}
+
+func (s *DBSessionStore) conn() (*database.DB, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+	if database.DefaultDB == nil {
+		return nil, database.ErrNotInitialized
+	}
+	return database.DefaultDB, nil
+}
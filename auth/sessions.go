@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"flugo.com/events"
+	"flugo.com/logger"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/utils"
+)
+
+// Session is one login of a user: the family of refresh tokens that
+// descend from it by rotation. Only the hash of the latest token is kept.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	TokenHash  string    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	RememberMe bool      `json:"remember_me"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Revoked    bool      `json:"-"`
+	// Current marks the session of the request, in SessionsHandler.
+	Current bool `json:"current"`
+}
+
+func (s *Session) active(now time.Time) bool {
+	return !s.Revoked && now.Before(s.ExpiresAt)
+}
+
+// Refresh answers these errors with a 401; the user has to log in again.
+var (
+	ErrSessionRevoked     = errors.New("session revoked or expired")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// EventRefreshTokenReused is emitted with a RefreshTokenReusedEvent when a
+// refresh token that was already rotated is presented again. The token was
+// likely stolen, so its whole session has been revoked.
+const EventRefreshTokenReused = "auth.refresh_token_reused"
+
+type RefreshTokenReusedEvent struct {
+	SessionID string
+	UserID    int
+}
+
+func init() {
+	response.RegisterError(ErrSessionRevoked, response.ErrorMapping{
+		Status: http.StatusUnauthorized, Code: "session_revoked", Message: "Session expired, please log in again",
+	})
+	response.RegisterError(ErrRefreshTokenReused, response.ErrorMapping{
+		Status: http.StatusUnauthorized, Code: "refresh_token_reused", Message: "Session revoked, please log in again",
+	})
+}
+
+// WithDevice records the user agent and address of the login request on
+// its session, to tell sessions apart in SessionsHandler.
+func WithDevice(r *http.Request) ClaimsOption {
+	return func(c *Claims) {
+		c.device = &device{userAgent: r.UserAgent(), ipAddress: remoteHost(r)}
+	}
+}
+
+type device struct {
+	userAgent string
+	ipAddress string
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSessionID() string {
+	return utils.MustSecureToken(16)
+}
+
+// SetSessionStore replaces the in-memory store of refresh token sessions,
+// typically with DatabaseSessions so they survive restarts.
+func (a *AuthService) SetSessionStore(store SessionStore) {
+	a.sessions = store
+}
+
+// startSession stores the session of a new token pair.
+func (a *AuthService) startSession(session *Session, token *Token, expiresAt time.Time) error {
+	session.TokenHash = hashToken(token.RefreshToken)
+	session.ExpiresAt = expiresAt
+	return a.sessions.Create(session)
+}
+
+// rotate moves session id from the refresh token presented to its
+// successor. A token that is not the latest of its session was rotated
+// before, so it is being reused: the session is revoked.
+func (a *AuthService) rotate(id string, presented string, next *Token, expiresAt time.Time) error {
+	rotated, err := a.sessions.Rotate(id, hashToken(presented), hashToken(next.RefreshToken), expiresAt)
+	if err != nil {
+		return err
+	}
+	if rotated {
+		return nil
+	}
+
+	session, err := a.sessions.Get(id)
+	if err != nil || session.Revoked {
+		return ErrSessionRevoked
+	}
+	if err := a.sessions.Revoke(id); err != nil {
+		return err
+	}
+	logger.Warn("Refresh token of session %s for user %d was reused; session revoked", id, session.UserID)
+	err = events.Emit(context.Background(), EventRefreshTokenReused, RefreshTokenReusedEvent{SessionID: id, UserID: session.UserID})
+	if err != nil {
+		logger.Warn("%s handlers failed: %v", EventRefreshTokenReused, err)
+	}
+	return ErrRefreshTokenReused
+}
+
+func (a *AuthService) Sessions(userID int) ([]*Session, error) {
+	return a.sessions.List(userID)
+}
+
+// RevokeSession ends session id: its access and refresh tokens are refused
+// from then on.
+func (a *AuthService) RevokeSession(id string) error {
+	return a.sessions.Revoke(id)
+}
+
+// RevokeOtherSessions revokes the sessions of userID except keepID, as for
+// "log out other devices", and returns how many it revoked.
+func (a *AuthService) RevokeOtherSessions(userID int, keepID string) (int, error) {
+	sessions, err := a.sessions.List(userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keepID {
+			continue
+		}
+		if err := a.sessions.Revoke(session.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+func SetSessionStore(store SessionStore) {
+	if DefaultAuthService != nil {
+		DefaultAuthService.SetSessionStore(store)
+	}
+}
+
+func Sessions(userID int) ([]*Session, error) {
+	if DefaultAuthService == nil {
+		return nil, fmt.Errorf("auth service not initialized")
+	}
+	return DefaultAuthService.Sessions(userID)
+}
+
+func RevokeSession(id string) error {
+	if DefaultAuthService == nil {
+		return fmt.Errorf("auth service not initialized")
+	}
+	return DefaultAuthService.RevokeSession(id)
+}
+
+func RevokeOtherSessions(userID int, keepID string) (int, error) {
+	if DefaultAuthService == nil {
+		return 0, fmt.Errorf("auth service not initialized")
+	}
+	return DefaultAuthService.RevokeOtherSessions(userID, keepID)
+}
+
+// RefreshHandler exchanges {"refresh_token": "..."} for a new token pair.
+func RefreshHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := response.BindJSON(r, &body); err != nil {
+			response.InvalidBody(w, err)
+			return
+		}
+		if body.RefreshToken == "" {
+			response.BadRequest(w, "refresh_token is required")
+			return
+		}
+
+		token, err := RefreshToken(body.RefreshToken)
+		if errors.Is(err, ErrSessionRevoked) || errors.Is(err, ErrRefreshTokenReused) {
+			response.WriteError(w, err)
+			return
+		}
+		if err != nil {
+			response.Unauthorized(w, "Invalid refresh token")
+			return
+		}
+		response.Success(w, token, "Token refreshed")
+	}
+}
+
+// SessionsHandler lists the sessions of the current user. Mount it behind
+// RequireAuth.
+func SessionsHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetCurrentUser(r)
+		if claims == nil || claims.UserID == 0 {
+			response.Unauthorized(w, "User not authenticated")
+			return
+		}
+
+		sessions, err := Sessions(claims.UserID)
+		if err != nil {
+			response.InternalError(w, "Failed to list sessions")
+			return
+		}
+		for _, session := range sessions {
+			session.Current = session.ID == claims.SessionID
+		}
+		response.Success(w, sessions)
+	}
+}
+
+// RevokeSessionHandler revokes the session named by the {id} route
+// parameter when it belongs to the current user. Mount it behind
+// RequireAuth.
+func RevokeSessionHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetCurrentUser(r)
+		if claims == nil || claims.UserID == 0 {
+			response.Unauthorized(w, "User not authenticated")
+			return
+		}
+		if DefaultAuthService == nil {
+			response.ServiceUnavailable(w, "Auth service not initialized")
+			return
+		}
+
+		session, err := DefaultAuthService.sessions.Get(router.Param(r, "id"))
+		if err != nil || session.UserID != claims.UserID || !session.active(time.Now()) {
+			response.NotFound(w, "Session not found")
+			return
+		}
+		if err := RevokeSession(session.ID); err != nil {
+			response.InternalError(w, "Failed to revoke session")
+			return
+		}
+		response.Success(w, nil, "Session revoked")
+	}
+}
+
+// RevokeOtherSessionsHandler revokes every session of the current user but
+// the one of the request. Mount it behind RequireAuth.
+func RevokeOtherSessionsHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetCurrentUser(r)
+		if claims == nil || claims.UserID == 0 {
+			response.Unauthorized(w, "User not authenticated")
+			return
+		}
+
+		revoked, err := RevokeOtherSessions(claims.UserID, claims.SessionID)
+		if err != nil {
+			response.InternalError(w, "Failed to revoke sessions")
+			return
+		}
+		response.Success(w, map[string]interface{}{"revoked": revoked}, "Other sessions revoked")
+	}
+}
@@ -0,0 +1,237 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/database"
+)
+
+func newSessionService(t *testing.T, store auth.SessionStore) *auth.AuthService {
+	t.Helper()
+	service := auth.NewAuthService(&config.JWTConfig{Secret: "test-secret", ExpirationTime: 3600, RefreshTime: 86400})
+	if store != nil {
+		service.SetSessionStore(store)
+	}
+	return service
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	service := newSessionService(t, nil)
+
+	first, err := service.GenerateToken(auth.Claims{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.RefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+	third, err := service.RefreshToken(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh of the rotated token: %v", err)
+	}
+
+	if _, err := service.RefreshToken(first.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenReused) {
+		t.Fatalf("reused token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	// The reuse revoked the whole session, including its latest token.
+	if _, err := service.RefreshToken(third.RefreshToken); !errors.Is(err, auth.ErrSessionRevoked) {
+		t.Errorf("latest token after reuse: err = %v, want ErrSessionRevoked", err)
+	}
+	sessions, _ := service.Sessions(7)
+	if len(sessions) != 0 {
+		t.Errorf("Sessions = %d after reuse, want none", len(sessions))
+	}
+}
+
+func TestSessionsAndRevoke(t *testing.T) {
+	service := newSessionService(t, nil)
+
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.Header.Set("User-Agent", "phone")
+	req.RemoteAddr = "203.0.113.7:5000"
+	phone, err := service.GenerateToken(auth.Claims{UserID: 7}, auth.WithDevice(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, _ := service.GenerateToken(auth.Claims{UserID: 7})
+	tablet, _ := service.GenerateToken(auth.Claims{UserID: 7})
+	service.GenerateToken(auth.Claims{UserID: 8})
+
+	sessions, err := service.Sessions(7)
+	if err != nil || len(sessions) != 3 {
+		t.Fatalf("Sessions = %d, %v; want 3", len(sessions), err)
+	}
+	claims, _ := service.ValidateToken(phone.AccessToken)
+	var phoneSession *auth.Session
+	for _, session := range sessions {
+		if session.ID == claims.SessionID {
+			phoneSession = session
+		}
+	}
+	if phoneSession == nil || phoneSession.UserAgent != "phone" || phoneSession.IPAddress != "203.0.113.7" {
+		t.Fatalf("phone session = %+v, want its device recorded", phoneSession)
+	}
+
+	if err := service.RevokeSession(phoneSession.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.RefreshToken(phone.RefreshToken); !errors.Is(err, auth.ErrSessionRevoked) {
+		t.Errorf("refresh of revoked session: err = %v, want ErrSessionRevoked", err)
+	}
+
+	laptopClaims, _ := service.ValidateToken(laptop.AccessToken)
+	revoked, err := service.RevokeOtherSessions(7, laptopClaims.SessionID)
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeOtherSessions = %d, %v; want 1", revoked, err)
+	}
+	if _, err := service.RefreshToken(tablet.RefreshToken); !errors.Is(err, auth.ErrSessionRevoked) {
+		t.Errorf("refresh of other session: err = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := service.RefreshToken(laptop.RefreshToken); err != nil {
+		t.Errorf("refresh of kept session: %v", err)
+	}
+	if sessions, _ := service.Sessions(8); len(sessions) != 1 {
+		t.Errorf("other user's sessions = %d, want 1", len(sessions))
+	}
+}
+
+// migratedDB opens a SQLite database with the auth tables migrated.
+func migratedDB(t *testing.T) *database.DB {
+	t.Helper()
+	database.RegisterMigration(auth.SessionsMigration)
+	database.RegisterMigration(auth.ClientsMigration)
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "auth.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDatabaseSessions(t *testing.T) {
+	db := migratedDB(t)
+	service := newSessionService(t, auth.DatabaseSessions(db))
+
+	first, err := service.GenerateToken(auth.Claims{UserID: 7}, auth.WithRememberMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.RefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	// A new service on the same table, as after a restart, knows the session.
+	restarted := newSessionService(t, auth.DatabaseSessions(db))
+	sessions, err := restarted.Sessions(7)
+	if err != nil || len(sessions) != 1 || !sessions[0].RememberMe {
+		t.Fatalf("Sessions = %+v, %v; want the remembered session", sessions, err)
+	}
+	if _, err := restarted.RefreshToken(first.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenReused) {
+		t.Fatalf("reused token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := restarted.RefreshToken(second.RefreshToken); !errors.Is(err, auth.ErrSessionRevoked) {
+		t.Errorf("latest token after reuse: err = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestTokenTypesAndRevokedAccessTokens(t *testing.T) {
+	service := newSessionService(t, nil)
+	token, err := service.GenerateToken(auth.Claims{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ValidateToken(token.RefreshToken); err == nil {
+		t.Error("refresh token accepted as an access token")
+	}
+	if _, err := service.RefreshToken(token.AccessToken); err == nil {
+		t.Error("access token accepted as a refresh token")
+	}
+
+	claims, err := service.ValidateToken(token.AccessToken)
+	if err != nil || claims.Type != auth.TokenTypeAccess {
+		t.Fatalf("ValidateToken = %+v, %v", claims, err)
+	}
+	if err := service.RevokeSession(claims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ValidateToken(token.AccessToken); !errors.Is(err, auth.ErrSessionRevoked) {
+		t.Errorf("access token of revoked session: err = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRequireAuthRejectsRefreshTokens(t *testing.T) {
+	auth.Init(&config.JWTConfig{Secret: "test-secret", ExpirationTime: 3600, RefreshTime: 86400})
+	token, err := auth.GenerateToken(auth.Claims{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := auth.RequireAuth()(func(w http.ResponseWriter, r *http.Request) {})
+
+	for name, tt := range map[string]struct {
+		token  string
+		status int
+	}{
+		"access":  {token.AccessToken, http.StatusOK},
+		"refresh": {token.RefreshToken, http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/profile", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s token: status %d, want %d", name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestSessionlessTokens(t *testing.T) {
+	issuer := newSessionService(t, nil)
+	token, err := issuer.GenerateSessionlessToken(auth.Claims{Username: "cli", Roles: []string{"admin"}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.RefreshToken != "" {
+		t.Error("sessionless token came with a refresh token")
+	}
+
+	// Another service with the same secret, as in the server, accepts it.
+	claims, err := newSessionService(t, nil).ValidateToken(token.AccessToken)
+	if err != nil || claims.SessionID != "" || claims.Username != "cli" {
+		t.Fatalf("ValidateToken = %+v, %v", claims, err)
+	}
+
+	expired, _ := issuer.GenerateSessionlessToken(auth.Claims{Username: "cli"}, -time.Minute)
+	if _, err := issuer.ValidateToken(expired.AccessToken); err == nil {
+		t.Error("expired sessionless token accepted")
+	}
+}
+
+func TestAuthMigrationsArePortable(t *testing.T) {
+	for _, m := range []database.Migration{auth.SessionsMigration, auth.ClientsMigration} {
+		for _, driver := range []string{"sqlite3", "postgres", "mysql"} {
+			statements, err := m.UpSQL(driver)
+			if err != nil {
+				t.Fatalf("%s on %s: %v", m.Name, driver, err)
+			}
+			if driver == "mysql" && len(statements) != 1 {
+				t.Errorf("%s on mysql: %q, want any index inside CREATE TABLE", m.Name, statements)
+			}
+		}
+	}
+}
@@ -13,6 +13,9 @@ type Item struct {
 	CreatedAt   time.Time
 	AccessCount int64
 	LastAccess  time.Time
+	// StaleUntil keeps an expired item for GetOrSetE to serve while it
+	// refreshes the value; see Options.Stale.
+	StaleUntil int64
 }
 
 func (item *Item) IsExpired() bool {
@@ -22,8 +25,15 @@ func (item *Item) IsExpired() bool {
 	return time.Now().UnixNano() > item.Expiration
 }
 
+// isGone reports whether the item is past both its expiration and its
+// stale window, so it can be removed.
+func (item *Item) isGone(now int64) bool {
+	return item.Expiration > 0 && now > item.Expiration && now > item.StaleUntil
+}
+
 type Stats struct {
 	Hits      int64   `json:"hits"`
+	StaleHits int64   `json:"stale_hits"`
 	Misses    int64   `json:"misses"`
 	Sets      int64   `json:"sets"`
 	Deletes   int64   `json:"deletes"`
@@ -40,6 +50,16 @@ type Cache struct {
 	stats         Stats
 	cleanupTicker *time.Ticker
 	stopCleanup   chan bool
+
+	loadMu sync.Mutex
+	loads  map[string]*load
+
+	warmMu   sync.Mutex
+	warmups  map[string]*warmup
+	warming  bool
+	warmWG   sync.WaitGroup
+	stopped  chan struct{}
+	stopOnce sync.Once
 }
 
 func New(maxSize int, defaultTTL time.Duration) *Cache {
@@ -48,6 +68,8 @@ func New(maxSize int, defaultTTL time.Duration) *Cache {
 		maxSize:     maxSize,
 		defaultTTL:  defaultTTL,
 		stopCleanup: make(chan bool),
+		loads:       make(map[string]*load),
+		stopped:     make(chan struct{}),
 	}
 
 	c.startCleanup()
@@ -75,23 +97,64 @@ func (c *Cache) startCleanup() {
 	}()
 }
 
+// Stop ends the cleanup and warmup refresh goroutines, waiting for the
+// refreshes to return. It may be called more than once.
 func (c *Cache) Stop() {
-	if c.stopCleanup != nil {
-		c.stopCleanup <- true
+	c.stopOnce.Do(func() {
+		c.warmMu.Lock()
+		close(c.stopped)
+		c.warmMu.Unlock()
+		if c.stopCleanup != nil {
+			c.stopCleanup <- true
+		}
+	})
+	c.warmWG.Wait()
+}
+
+func (c *Cache) isStopped() bool {
+	select {
+	case <-c.stopped:
+		return true
+	default:
+		return false
 	}
 }
 
 func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
+	c.set(key, value, ttl, 0)
+}
+
+func (c *Cache) set(key string, value interface{}, ttl, stale time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.store(key, value, ttl, stale)
+}
 
+// Add sets key only if it holds no live value and reports whether it did,
+// so concurrent callers agree on a single winner.
+func (c *Cache) Add(key string, value interface{}, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, found := c.items[key]; found && !item.IsExpired() {
+		return false
+	}
+	c.store(key, value, ttl, 0)
+	return true
+}
+
+// store sets key with c.mu held.
+func (c *Cache) store(key string, value interface{}, ttl, stale time.Duration) {
 	if ttl == 0 {
 		ttl = c.defaultTTL
 	}
 
-	var expiration int64
+	var expiration, staleUntil int64
 	if ttl > 0 {
 		expiration = time.Now().Add(ttl).UnixNano()
+		if stale > 0 {
+			staleUntil = time.Now().Add(ttl + stale).UnixNano()
+		}
 	}
 
 	if len(c.items) >= c.maxSize && c.items[key] == nil {
@@ -104,6 +167,7 @@ func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
 		CreatedAt:   time.Now(),
 		AccessCount: 0,
 		LastAccess:  time.Now(),
+		StaleUntil:  staleUntil,
 	}
 
 	c.stats.Sets++
@@ -122,7 +186,10 @@ func (c *Cache) Get(key string) (interface{}, bool) {
 	}
 
 	if item.IsExpired() {
-		delete(c.items, key)
+		// Items in their stale window stay for GetOrSetE.
+		if item.isGone(time.Now().UnixNano()) {
+			delete(c.items, key)
+		}
 		c.stats.Misses++
 		c.stats.ItemCount = len(c.items)
 		c.updateHitRatio()
@@ -207,6 +274,24 @@ func (c *Cache) Exists(key string) bool {
 	return !item.IsExpired()
 }
 
+// TTL reports how long until key expires without touching its LRU position.
+// Items without an expiration return -1; missing or expired keys return false.
+func (c *Cache) TTL(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, found := c.items[key]
+	if !found || item.IsExpired() {
+		return 0, false
+	}
+
+	if item.Expiration == 0 {
+		return -1, true
+	}
+
+	return time.Until(time.Unix(0, item.Expiration)).Round(time.Millisecond), true
+}
+
 func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -249,7 +334,7 @@ func (c *Cache) deleteExpired() {
 
 	now := time.Now().UnixNano()
 	for key, item := range c.items {
-		if item.Expiration > 0 && now > item.Expiration {
+		if item.isGone(now) {
 			delete(c.items, key)
 			c.stats.Evictions++
 		}
@@ -374,6 +459,13 @@ func Delete(key string) bool {
 	return false
 }
 
+func Add(key string, value interface{}, ttl time.Duration) bool {
+	if DefaultCache != nil {
+		return DefaultCache.Add(key, value, ttl)
+	}
+	return false
+}
+
 func Exists(key string) bool {
 	if DefaultCache != nil {
 		return DefaultCache.Exists(key)
@@ -381,6 +473,13 @@ func Exists(key string) bool {
 	return false
 }
 
+func TTL(key string) (time.Duration, bool) {
+	if DefaultCache != nil {
+		return DefaultCache.TTL(key)
+	}
+	return 0, false
+}
+
 func Clear() {
 	if DefaultCache != nil {
 		DefaultCache.Clear()
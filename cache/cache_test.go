@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddSetsOnlyOnce(t *testing.T) {
+	c := newTestCache(t)
+	if !c.Add("k", 1, time.Minute) || c.Add("k", 2, time.Minute) {
+		t.Fatal("Add() should succeed only for the first caller")
+	}
+	if v, _ := c.Get("k"); v != 1 {
+		t.Errorf("Get() = %v", v)
+	}
+
+	c.Set("short", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if !c.Add("short", 2, time.Minute) {
+		t.Error("Add() over an expired item failed")
+	}
+}
@@ -0,0 +1,230 @@
+// Package cacheadmin serves endpoints to inspect and purge cache.DefaultCache,
+// restricted to admins:
+//
+//	GET    /admin/cache/stats
+//	GET    /admin/cache/keys?prefix=&limit=
+//	GET    /admin/cache/item?key=
+//	DELETE /admin/cache/item?key=
+//	POST   /admin/cache/flush?confirm=true
+//	GET    /admin/cache/warmups
+//
+// Every request emits a cache.admin.* event with an AuditEvent payload.
+package cacheadmin
+
+import (
+	"net/http"
+	"strconv"
+
+	"flugo.com/auth"
+	"flugo.com/cache"
+	"flugo.com/events"
+	"flugo.com/logger"
+	"flugo.com/module"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+const (
+	EventStats   = "cache.admin.stats"
+	EventKeys    = "cache.admin.keys"
+	EventItem    = "cache.admin.item"
+	EventDelete  = "cache.admin.delete"
+	EventFlush   = "cache.admin.flush"
+	EventWarmups = "cache.admin.warmups"
+)
+
+// AuditEvent records who used an endpoint and on what. Count is the number
+// of keys listed or flushed.
+type AuditEvent struct {
+	Action     string
+	UserID     int
+	RemoteAddr string
+	Key        string
+	Prefix     string
+	Count      int
+}
+
+type Config struct {
+	// MaxValueSize is the largest estimated size, in bytes, of a value
+	// returned by GET /item; larger values are described but left out.
+	// Defaults to 4 KiB.
+	MaxValueSize int
+	// MaxKeys caps GET /keys when no limit is given. Defaults to 1000.
+	MaxKeys int
+}
+
+func Module() *module.Module {
+	return ModuleWithConfig(Config{})
+}
+
+func ModuleWithConfig(cfg Config) *module.Module {
+	if cfg.MaxValueSize <= 0 {
+		cfg.MaxValueSize = 4 << 10
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 1000
+	}
+
+	return module.NewModule(module.ModuleConfig{
+		Name: "cache-admin",
+		Controllers: []module.ControllerConfig{
+			{Controller: &Controller{config: cfg}, Path: "/admin/cache"},
+		},
+		Guards: []router.MiddlewareFunc{auth.RequireAuth(), auth.RequireRoles("admin")},
+	})
+}
+
+type Controller struct {
+	config Config
+}
+
+func (c *Controller) GetStats(w http.ResponseWriter, r *http.Request) {
+	store, ok := defaultCache(w)
+	if !ok {
+		return
+	}
+
+	audit(r, EventStats, AuditEvent{})
+	response.Success(w, store.Stats(), "Cache stats retrieved successfully")
+}
+
+func (c *Controller) GetKeys(w http.ResponseWriter, r *http.Request) {
+	store, ok := defaultCache(w)
+	if !ok {
+		return
+	}
+
+	limit := c.config.MaxKeys
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			response.BadRequest(w, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	keys := store.KeysWithPrefix(prefix)
+	total := len(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	audit(r, EventKeys, AuditEvent{Prefix: prefix, Count: len(keys)})
+	response.Success(w, map[string]interface{}{
+		"keys":      keys,
+		"total":     total,
+		"truncated": total > len(keys),
+	}, "Cache keys retrieved successfully")
+}
+
+// GetItem describes the item without its value when the value is larger
+// than Config.MaxValueSize; value_omitted is then true.
+func (c *Controller) GetItem(w http.ResponseWriter, r *http.Request) {
+	store, ok := defaultCache(w)
+	if !ok {
+		return
+	}
+	key, ok := requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	audit(r, EventItem, AuditEvent{Key: key})
+	info, found := store.Inspect(key)
+	if !found {
+		response.NotFound(w, "Cache item not found")
+		return
+	}
+
+	omitted := info.SizeEstimate > c.config.MaxValueSize
+	if omitted {
+		info.Value = nil
+	}
+	response.Success(w, map[string]interface{}{
+		"item":          info,
+		"value_omitted": omitted,
+	}, "Cache item retrieved successfully")
+}
+
+func (c *Controller) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	store, ok := defaultCache(w)
+	if !ok {
+		return
+	}
+	key, ok := requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	audit(r, EventDelete, AuditEvent{Key: key})
+	if !store.Delete(key) {
+		response.NotFound(w, "Cache item not found")
+		return
+	}
+	response.Deleted(w, "Cache item deleted successfully")
+}
+
+// PostFlush clears every item. It requires confirm=true so a stray request
+// cannot empty the cache.
+func (c *Controller) PostFlush(w http.ResponseWriter, r *http.Request) {
+	store, ok := defaultCache(w)
+	if !ok {
+		return
+	}
+
+	if confirmed, _ := strconv.ParseBool(r.URL.Query().Get("confirm")); !confirmed {
+		response.BadRequest(w, "Flushing the cache requires confirm=true")
+		return
+	}
+
+	cleared := store.Size()
+	store.Clear()
+
+	audit(r, EventFlush, AuditEvent{Count: cleared})
+	logger.Warn("Cache flushed through the admin API: %d items cleared", cleared)
+	response.Success(w, map[string]interface{}{"cleared": cleared}, "Cache flushed successfully")
+}
+
+// GetWarmups reports each key registered with cache.RegisterWarmup: when
+// it was last loaded and the last error refreshing it.
+func (c *Controller) GetWarmups(w http.ResponseWriter, r *http.Request) {
+	store, ok := defaultCache(w)
+	if !ok {
+		return
+	}
+
+	statuses := store.WarmupStatus()
+	audit(r, EventWarmups, AuditEvent{Count: len(statuses)})
+	response.Success(w, statuses, "Cache warmups retrieved successfully")
+}
+
+func defaultCache(w http.ResponseWriter) (*cache.Cache, bool) {
+	if cache.DefaultCache == nil {
+		response.ServiceUnavailable(w, "Cache not initialized")
+		return nil, false
+	}
+	return cache.DefaultCache, true
+}
+
+func requireKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		response.BadRequest(w, "key is required")
+		return "", false
+	}
+	return key, true
+}
+
+func audit(r *http.Request, name string, e AuditEvent) {
+	e.Action = name
+	e.RemoteAddr = r.RemoteAddr
+	if user := auth.GetCurrentUser(r); user != nil {
+		e.UserID = user.UserID
+	}
+
+	if err := events.Emit(r.Context(), name, e); err != nil {
+		logger.Warn("%s handlers failed: %v", name, err)
+	}
+}
@@ -0,0 +1,166 @@
+package cacheadmin_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/cache"
+	"flugo.com/cache/cacheadmin"
+	"flugo.com/events"
+	"flugo.com/flugotest"
+	"flugo.com/module"
+)
+
+func newAdminApp(t *testing.T) (*flugotest.App, *[]cacheadmin.AuditEvent) {
+	app := flugotest.NewTestApp(t, flugotest.Options{
+		Modules: []*module.Module{cacheadmin.ModuleWithConfig(cacheadmin.Config{MaxValueSize: 256})},
+	})
+
+	cache.Set("users:1", "alice", time.Minute)
+	cache.Set("users:2", "bob", -1)
+	cache.Set("posts:1", strings.Repeat("x", 1024), time.Minute)
+
+	var audited []cacheadmin.AuditEvent
+	unsubscribe := events.Subscribe("cache.admin.*", func(ctx context.Context, e events.Event) error {
+		audited = append(audited, e.Payload.(cacheadmin.AuditEvent))
+		return nil
+	})
+	t.Cleanup(unsubscribe)
+	return app, &audited
+}
+
+func TestRequiresAdmin(t *testing.T) {
+	app, audited := newAdminApp(t)
+
+	app.Request("GET", "/admin/cache/stats").Do().AssertStatus(401)
+	app.Request("GET", "/admin/cache/stats").WithToken(flugotest.Claims(2)).Do().AssertStatus(403)
+	app.Request("POST", "/admin/cache/flush").Query("confirm", "true").
+		WithToken(flugotest.Claims(2)).Do().AssertStatus(403)
+
+	if cache.DefaultCache.Size() != 3 {
+		t.Errorf("cache size = %d after rejected requests, want 3", cache.DefaultCache.Size())
+	}
+	if len(*audited) != 0 {
+		t.Errorf("audited %v for rejected requests, want nothing", *audited)
+	}
+}
+
+func TestStatsAndKeys(t *testing.T) {
+	app, audited := newAdminApp(t)
+	admin := flugotest.AdminClaims()
+
+	app.Request("GET", "/admin/cache/stats").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.item_count", 3)
+
+	app.Request("GET", "/admin/cache/keys").Query("prefix", "users:").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.keys", []string{"users:1", "users:2"}).
+		AssertJSONPath("data.total", 2).
+		AssertJSONPath("data.truncated", false)
+
+	app.Request("GET", "/admin/cache/keys").Query("limit", "1").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.keys", []string{"posts:1"}).
+		AssertJSONPath("data.total", 3).
+		AssertJSONPath("data.truncated", true)
+
+	app.Request("GET", "/admin/cache/keys").Query("limit", "0").WithToken(admin).Do().
+		AssertStatus(400)
+
+	if len(*audited) != 3 || (*audited)[1].Prefix != "users:" || (*audited)[1].UserID != admin.UserID {
+		t.Errorf("audited %+v, want stats and two key listings by the admin", *audited)
+	}
+}
+
+func TestItem(t *testing.T) {
+	app, audited := newAdminApp(t)
+	admin := flugotest.AdminClaims()
+
+	app.Request("GET", "/admin/cache/item").Query("key", "users:1").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.item.type", "string").
+		AssertJSONPath("data.item.value", "alice").
+		AssertJSONPath("data.value_omitted", false)
+
+	app.Request("GET", "/admin/cache/item").Query("key", "users:2").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.item.ttl_ms", -1)
+
+	resp := app.Request("GET", "/admin/cache/item").Query("key", "users:1").WithToken(admin).Do()
+	if ttl, _ := resp.JSONPath("data.item.ttl_ms"); ttl.(float64) <= 0 {
+		t.Errorf("ttl_ms = %v for an expiring item, want it positive", ttl)
+	}
+
+	resp = app.Request("GET", "/admin/cache/item").Query("key", "posts:1").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.value_omitted", true)
+	if value, err := resp.JSONPath("data.item.value"); err == nil {
+		t.Errorf("value %v returned for a large item, want it left out", value)
+	}
+
+	app.Request("GET", "/admin/cache/item").Query("key", "missing").WithToken(admin).Do().AssertStatus(404)
+	app.Request("GET", "/admin/cache/item").WithToken(admin).Do().AssertStatus(400)
+
+	if n := len(*audited); n != 5 {
+		t.Errorf("audited %d item reads, want 5", n)
+	}
+}
+
+func TestDeleteItem(t *testing.T) {
+	app, audited := newAdminApp(t)
+	admin := flugotest.AdminClaims()
+
+	app.Request("DELETE", "/admin/cache/item").Query("key", "users:1").WithToken(admin).Do().
+		AssertStatus(200)
+	if cache.Exists("users:1") {
+		t.Error("users:1 still cached after DELETE")
+	}
+	app.Request("DELETE", "/admin/cache/item").Query("key", "users:1").WithToken(admin).Do().
+		AssertStatus(404)
+
+	if len(*audited) != 2 || (*audited)[0].Action != cacheadmin.EventDelete || (*audited)[0].Key != "users:1" {
+		t.Errorf("audited %+v, want two deletes of users:1", *audited)
+	}
+}
+
+func TestFlushRequiresConfirmation(t *testing.T) {
+	app, audited := newAdminApp(t)
+	admin := flugotest.AdminClaims()
+
+	app.Request("POST", "/admin/cache/flush").WithToken(admin).Do().AssertStatus(400)
+	app.Request("POST", "/admin/cache/flush").Query("confirm", "false").WithToken(admin).Do().AssertStatus(400)
+	if cache.DefaultCache.Size() != 3 {
+		t.Fatalf("cache size = %d after unconfirmed flushes, want 3", cache.DefaultCache.Size())
+	}
+
+	app.Request("POST", "/admin/cache/flush").Query("confirm", "true").WithToken(admin).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.cleared", 3)
+	if cache.DefaultCache.Size() != 0 {
+		t.Errorf("cache size = %d after flush, want 0", cache.DefaultCache.Size())
+	}
+
+	if len(*audited) != 1 || (*audited)[0].Action != cacheadmin.EventFlush || (*audited)[0].Count != 3 {
+		t.Errorf("audited %+v, want one flush of 3 items", *audited)
+	}
+}
+
+func TestWarmups(t *testing.T) {
+	app, _ := newAdminApp(t)
+	cache.RegisterWarmup("flags", func() (interface{}, error) {
+		return nil, errors.New("flag service down")
+	}, time.Minute, time.Minute)
+	if err := cache.Warm(context.Background()); err == nil {
+		t.Error("Warm returned no error for a failed load")
+	}
+
+	app.Request("GET", "/admin/cache/warmups").WithToken(flugotest.AdminClaims()).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.0.key", "flags").
+		AssertJSONPath("data.0.loaded", false).
+		AssertJSONPath("data.0.last_error", "flag service down")
+}
@@ -0,0 +1,19 @@
+package cache
+
+import "context"
+
+type cacheKey struct{}
+
+// ContextWithCache makes FromContext return c instead of DefaultCache.
+func ContextWithCache(ctx context.Context, c *Cache) context.Context {
+	return context.WithValue(ctx, cacheKey{}, c)
+}
+
+// FromContext returns the cache attached by ContextWithCache, or
+// DefaultCache.
+func FromContext(ctx context.Context) *Cache {
+	if c, ok := ctx.Value(cacheKey{}).(*Cache); ok && c != nil {
+		return c
+	}
+	return DefaultCache
+}
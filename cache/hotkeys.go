@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"flugo.com/response"
+)
+
+type KeyStats struct {
+	Key          string    `json:"key"`
+	AccessCount  int64     `json:"access_count"`
+	LastAccess   time.Time `json:"last_access"`
+	SizeEstimate int       `json:"size_estimate"`
+}
+
+// HotKeys returns the topN most read keys, most accessed first. A topN of
+// zero or less returns every key.
+func (c *Cache) HotKeys(topN int) []KeyStats {
+	c.mu.RLock()
+	keys := make([]KeyStats, 0, len(c.items))
+	for key, item := range c.items {
+		if !item.IsExpired() {
+			keys = append(keys, keyStats(key, item))
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].AccessCount != keys[j].AccessCount {
+			return keys[i].AccessCount > keys[j].AccessCount
+		}
+		return keys[i].Key < keys[j].Key
+	})
+
+	if topN > 0 && len(keys) > topN {
+		keys = keys[:topN]
+	}
+	return keys
+}
+
+// KeyInfo inspects a single key without counting as an access.
+func (c *Cache) KeyInfo(key string) (KeyStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, found := c.items[key]
+	if !found || item.IsExpired() {
+		return KeyStats{}, false
+	}
+	return keyStats(key, item), true
+}
+
+func keyStats(key string, item *Item) KeyStats {
+	return KeyStats{
+		Key:          key,
+		AccessCount:  item.AccessCount,
+		LastAccess:   item.LastAccess,
+		SizeEstimate: len(key) + int(unsafe.Sizeof(*item)) + estimateSize(item.Value),
+	}
+}
+
+// estimateSize approximates the memory held by v: the size of its value plus
+// the backing data of strings, byte slices and the elements of slices and
+// maps. Pointers are not followed.
+func estimateSize(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int(unsafe.Sizeof(val)) + len(val)
+	case []byte:
+		return int(unsafe.Sizeof(val)) + cap(val)
+	}
+
+	rv := reflect.ValueOf(v)
+	size := int(rv.Type().Size())
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			size += estimateSize(rv.Index(i).Interface())
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			size += estimateSize(iter.Key().Interface()) + estimateSize(iter.Value().Interface())
+		}
+	}
+	return size
+}
+
+// HotKeysHandler serves the hottest keys of DefaultCache; ?n= limits the
+// count (default 20).
+func HotKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := 20
+		if v := r.URL.Query().Get("n"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 {
+				response.BadRequest(w, "n must be a positive integer")
+				return
+			}
+			n = parsed
+		}
+
+		if DefaultCache == nil {
+			response.Success(w, []KeyStats{}, "Cache not initialized")
+			return
+		}
+		response.Success(w, DefaultCache.HotKeys(n), "Hot keys retrieved successfully")
+	}
+}
+
+func HotKeys(topN int) []KeyStats {
+	if DefaultCache != nil {
+		return DefaultCache.HotKeys(topN)
+	}
+	return nil
+}
+
+func KeyInfo(key string) (KeyStats, bool) {
+	if DefaultCache != nil {
+		return DefaultCache.KeyInfo(key)
+	}
+	return KeyStats{}, false
+}
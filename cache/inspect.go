@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ItemInfo describes a cached item. TTLMillis is -1 for items that never
+// expire; Stale items are expired but still served by GetOrSetE.
+type ItemInfo struct {
+	KeyStats
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+	TTLMillis int64       `json:"ttl_ms"`
+	Stale     bool        `json:"stale,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// Inspect returns key's metadata and value without counting as an access.
+func (c *Cache) Inspect(key string) (ItemInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, found := c.items[key]
+	now := time.Now()
+	if !found || item.isGone(now.UnixNano()) {
+		return ItemInfo{}, false
+	}
+
+	info := ItemInfo{
+		KeyStats:  keyStats(key, item),
+		Type:      fmt.Sprintf("%T", item.Value),
+		CreatedAt: item.CreatedAt,
+		TTLMillis: -1,
+		Stale:     item.IsExpired(),
+		Value:     item.Value,
+	}
+	if item.Expiration > 0 {
+		expiresAt := time.Unix(0, item.Expiration)
+		info.ExpiresAt = &expiresAt
+		info.TTLMillis = max(expiresAt.Sub(now).Milliseconds(), 0)
+	}
+	return info, true
+}
+
+// KeysWithPrefix returns the live keys starting with prefix, sorted.
+func (c *Cache) KeysWithPrefix(prefix string) []string {
+	var keys []string
+	for _, key := range c.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
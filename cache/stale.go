@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"flugo.com/logger"
+	"flugo.com/trace"
+)
+
+// Options configures GetOrSetE. A TTL of zero uses the cache's default.
+//
+// Stale keeps a value for that long after it expires. GetOrSetE then returns
+// the expired value at once and refreshes it in the background, so callers
+// only wait for the loader when no value, fresh or stale, exists.
+//
+// Context is the caller's context; when it carries a trace, loader calls
+// are recorded as child spans.
+type Options struct {
+	TTL     time.Duration
+	Stale   time.Duration
+	Context context.Context
+}
+
+var errLoadPanicked = errors.New("cache: loader panicked")
+
+// load is one in-flight call of a loader; callers asking for the same key
+// meanwhile wait for it instead of calling the loader again.
+type load struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type lookupState int
+
+const (
+	lookupMiss lookupState = iota
+	lookupFresh
+	lookupStale
+)
+
+// GetOrSetE returns the cached value for key, calling fn to compute it when
+// missing. Concurrent callers share a single call of fn, and errors are
+// returned without caching anything.
+func (c *Cache) GetOrSetE(key string, fn func() (interface{}, error), opts Options) (interface{}, error) {
+	value, state := c.lookup(key)
+	switch state {
+	case lookupFresh:
+		return value, nil
+	case lookupStale:
+		c.refresh(key, fn, opts)
+		return value, nil
+	}
+
+	l, started := c.startLoad(key)
+	if started {
+		c.runLoad(key, l, fn, opts)
+	} else {
+		<-l.done
+	}
+	return l.value, l.err
+}
+
+func (c *Cache) lookup(key string) (interface{}, lookupState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.updateHitRatio()
+
+	item, found := c.items[key]
+	if !found {
+		c.stats.Misses++
+		return nil, lookupMiss
+	}
+
+	now := time.Now().UnixNano()
+	state := lookupFresh
+	if item.IsExpired() {
+		if item.isGone(now) {
+			delete(c.items, key)
+			c.stats.Misses++
+			c.stats.ItemCount = len(c.items)
+			return nil, lookupMiss
+		}
+		state = lookupStale
+	}
+
+	item.AccessCount++
+	item.LastAccess = time.Now()
+	if state == lookupStale {
+		c.stats.StaleHits++
+	} else {
+		c.stats.Hits++
+	}
+	return item.Value, state
+}
+
+// refresh recomputes a stale value in the background unless a load for key
+// is already running. On failure the stale value keeps being served until
+// its window ends.
+func (c *Cache) refresh(key string, fn func() (interface{}, error), opts Options) {
+	l, started := c.startLoad(key)
+	if !started {
+		return
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Cache refresh of %s panicked: %v", key, r)
+			}
+		}()
+		c.runLoad(key, l, fn, opts)
+		if l.err != nil {
+			logger.Warn("Cache refresh of %s failed, serving the stale value: %v", key, l.err)
+		}
+	}()
+}
+
+func (c *Cache) startLoad(key string) (*load, bool) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	if l, ok := c.loads[key]; ok {
+		return l, false
+	}
+	l := &load{done: make(chan struct{})}
+	c.loads[key] = l
+	return l, true
+}
+
+func (c *Cache) runLoad(key string, l *load, fn func() (interface{}, error), opts Options) {
+	defer func() {
+		c.loadMu.Lock()
+		delete(c.loads, key)
+		c.loadMu.Unlock()
+		close(l.done)
+	}()
+
+	// A load that finished just before this one started already stored a
+	// fresh value.
+	if value, ok := c.freshValue(key); ok {
+		l.value = value
+		return
+	}
+
+	_, span := trace.Child(opts.Context, "cache.load")
+	defer span.End()
+	span.SetAttribute("cache.key", key)
+
+	l.err = errLoadPanicked
+	l.value, l.err = fn()
+	span.RecordError(l.err)
+	if l.err == nil {
+		c.set(key, l.value, opts.TTL, opts.Stale)
+	}
+}
+
+// freshValue reads key without counting an access.
+func (c *Cache) freshValue(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, found := c.items[key]
+	if !found || item.IsExpired() {
+		return nil, false
+	}
+	return item.Value, true
+}
+
+func GetOrSetE(key string, fn func() (interface{}, error), opts Options) (interface{}, error) {
+	if DefaultCache != nil {
+		return DefaultCache.GetOrSetE(key, fn, opts)
+	}
+	return fn()
+}
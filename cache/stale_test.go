@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T) *Cache {
+	c := New(100, time.Minute)
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func TestGetOrSetECallsLoaderOnceForConcurrentMisses(t *testing.T) {
+	c := newTestCache(t)
+	var calls atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrSetE("report", func() (interface{}, error) {
+				calls.Add(1)
+				time.Sleep(20 * time.Millisecond)
+				return "computed", nil
+			}, Options{TTL: time.Minute})
+			if err != nil || value != "computed" {
+				t.Errorf("GetOrSetE = %v, %v", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+}
+
+func TestStaleWhileRevalidateRefreshesOnce(t *testing.T) {
+	c := newTestCache(t)
+	opts := Options{TTL: 10 * time.Millisecond, Stale: time.Minute}
+
+	c.GetOrSetE("report", func() (interface{}, error) { return "v1", nil }, opts)
+	time.Sleep(20 * time.Millisecond)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	refresh := func() (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return "v2", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The refresh is blocked, so a stale hit must not wait for it.
+			value, err := c.GetOrSetE("report", refresh, opts)
+			if err != nil || value != "v1" {
+				t.Errorf("GetOrSetE = %v, %v, want the stale v1", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	waitFor(t, func() bool {
+		_, ok := c.freshValue("report")
+		return ok
+	})
+	if n := calls.Load(); n != 1 {
+		t.Errorf("refresh ran %d times, want 1", n)
+	}
+	if value, _ := c.Get("report"); value != "v2" {
+		t.Errorf("value after refresh = %v, want v2", value)
+	}
+	if stats := c.Stats(); stats.StaleHits != 50 || stats.Hits != 1 {
+		t.Errorf("stats = %+v, want 50 stale hits and 1 hit", stats)
+	}
+}
+
+func TestStaleItemsAreRetainedUntilTheWindowEnds(t *testing.T) {
+	c := newTestCache(t)
+	c.set("stale", "old", 5*time.Millisecond, 80*time.Millisecond)
+	c.Set("plain", "old", 5*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	if _, found := c.Get("stale"); found {
+		t.Error("Get returned an expired item")
+	}
+	c.deleteExpired()
+	if c.Size() != 1 {
+		t.Fatalf("size = %d after cleanup, want only the stale item kept", c.Size())
+	}
+
+	// A failed refresh keeps serving the stale value.
+	value, err := c.GetOrSetE("stale", func() (interface{}, error) {
+		return nil, errors.New("backend down")
+	}, Options{})
+	if err != nil || value != "old" {
+		t.Errorf("GetOrSetE = %v, %v, want the stale value", value, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	c.deleteExpired()
+	if c.Size() != 0 {
+		t.Errorf("size = %d after the stale window, want 0", c.Size())
+	}
+
+	// Without a stale value callers wait for the loader and see its error.
+	if _, err := c.GetOrSetE("stale", func() (interface{}, error) {
+		return nil, errors.New("backend down")
+	}, Options{}); err == nil {
+		t.Error("GetOrSetE returned no error without a stale value")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
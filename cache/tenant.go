@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"flugo.com/reqctx"
+)
+
+// TenantKey namespaces key under the tenant carried by ctx, so the same key
+// used by two tenants names two entries:
+//
+//	cache.Set(cache.TenantKey(r.Context(), "users:all"), users, time.Minute)
+//
+// Without a tenant the key lands in a namespace of its own that no tenant
+// shares.
+func TenantKey(ctx context.Context, key string) string {
+	return tenantPrefix(reqctx.TenantFromContext(ctx)) + key
+}
+
+func tenantPrefix(id reqctx.TenantID) string {
+	return "tenant:" + string(id) + ":"
+}
+
+// ClearTenant deletes every entry stored under TenantKey for tenant id and
+// returns how many were removed.
+func (c *Cache) ClearTenant(id reqctx.TenantID) int {
+	prefix := tenantPrefix(id)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.items, key)
+			removed++
+		}
+	}
+	c.stats.Deletes += int64(removed)
+	c.stats.ItemCount = len(c.items)
+	return removed
+}
+
+func ClearTenant(id reqctx.TenantID) int {
+	if DefaultCache == nil {
+		return 0
+	}
+	return DefaultCache.ClearTenant(id)
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"flugo.com/reqctx"
+)
+
+func TestTenantKeySeparatesTenants(t *testing.T) {
+	c := newTestCache(t)
+	acme := reqctx.ContextWithTenant(context.Background(), "acme")
+	globex := reqctx.ContextWithTenant(context.Background(), "globex")
+
+	c.Set(TenantKey(acme, "users:all"), "acme users", time.Minute)
+	c.Set(TenantKey(globex, "users:all"), "globex users", time.Minute)
+	c.Set(TenantKey(context.Background(), "users:all"), "shared users", time.Minute)
+
+	if v, _ := c.Get(TenantKey(acme, "users:all")); v != "acme users" {
+		t.Errorf("acme entry = %v", v)
+	}
+	if v, _ := c.Get(TenantKey(globex, "users:all")); v != "globex users" {
+		t.Errorf("globex entry = %v", v)
+	}
+
+	if removed := c.ClearTenant("acme"); removed != 1 {
+		t.Errorf("ClearTenant removed %d entries, want 1", removed)
+	}
+	if _, ok := c.Get(TenantKey(acme, "users:all")); ok {
+		t.Error("acme entry survived ClearTenant")
+	}
+	if _, ok := c.Get(TenantKey(globex, "users:all")); !ok {
+		t.Error("ClearTenant removed another tenant's entry")
+	}
+	if v, _ := c.Get(TenantKey(context.Background(), "users:all")); v != "shared users" {
+		t.Errorf("entry without a tenant = %v", v)
+	}
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"flugo.com/events"
+	"flugo.com/logger"
+)
+
+// EventWarmupFailed is emitted with a WarmupFailedEvent each time loading
+// a warm key fails.
+const EventWarmupFailed = "cache.warmup.failed"
+
+type WarmupFailedEvent struct {
+	Key string
+	Err error
+	// Age is how old the value still being served is; zero when there is
+	// none. Dropped is set once Age passed the key's max staleness and the
+	// value was removed.
+	Age     time.Duration
+	Dropped bool
+}
+
+// WarmupStatus reports the state of a key registered with RegisterWarmup.
+type WarmupStatus struct {
+	Key            string     `json:"key"`
+	TTLMillis      int64      `json:"ttl_ms"`
+	RefreshMillis  int64      `json:"refresh_every_ms"`
+	MaxStaleMillis int64      `json:"max_stale_ms"`
+	Loaded         bool       `json:"loaded"`
+	LastRefresh    *time.Time `json:"last_refresh,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	Failures       int        `json:"consecutive_failures"`
+}
+
+type WarmupOption func(*warmup)
+
+// WithMaxStale sets how long after its last successful load a value keeps
+// being served while refreshes fail. It defaults to twice the TTL, or to
+// ten refresh intervals for keys without a TTL.
+func WithMaxStale(d time.Duration) WarmupOption {
+	return func(w *warmup) {
+		w.maxStale = d
+	}
+}
+
+type warmup struct {
+	key          string
+	loader       func() (interface{}, error)
+	ttl          time.Duration
+	refreshEvery time.Duration
+	maxStale     time.Duration
+
+	// ready is closed once the first load finished, successfully or not.
+	ready   chan struct{}
+	started bool
+
+	mu          sync.Mutex
+	value       interface{}
+	loaded      bool
+	lastRefresh time.Time
+	lastErr     error
+	lastErrAt   time.Time
+	failures    int
+}
+
+// RegisterWarmup keeps key loaded: Warm loads it at startup and it is then
+// reloaded every refreshEvery, before it expires, so readers never wait for
+// loader. refreshEvery defaults to half the TTL. When a reload fails the
+// previous value keeps being served until it is older than the max
+// staleness (see WithMaxStale), when it is dropped. Keys registered after
+// Warm start loading at once.
+func (c *Cache) RegisterWarmup(key string, loader func() (interface{}, error), ttl, refreshEvery time.Duration, opts ...WarmupOption) {
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
+	if refreshEvery <= 0 {
+		refreshEvery = ttl / 2
+	}
+	if refreshEvery <= 0 {
+		refreshEvery = time.Minute
+	}
+	if ttl > 0 && refreshEvery >= ttl {
+		logger.Warn("Cache warmup for %s refreshes every %v, after its %v TTL: the key will expire between refreshes", key, refreshEvery, ttl)
+	}
+
+	w := &warmup{
+		key:          key,
+		loader:       loader,
+		ttl:          ttl,
+		refreshEvery: refreshEvery,
+		ready:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.maxStale <= 0 {
+		w.maxStale = 2 * ttl
+		if ttl <= 0 {
+			w.maxStale = 10 * refreshEvery
+		}
+	}
+
+	c.warmMu.Lock()
+	defer c.warmMu.Unlock()
+	if c.warmups == nil {
+		c.warmups = make(map[string]*warmup)
+	}
+	if old, ok := c.warmups[key]; ok && old.started {
+		logger.Warn("Cache warmup for %s registered twice; keeping the first loader", key)
+		return
+	}
+	c.warmups[key] = w
+	if c.warming {
+		c.startWarmup(w)
+	}
+}
+
+// Warm loads every registered key in parallel and starts refreshing them
+// in the background. It returns once all keys finished their first load or
+// ctx is done, with an error naming the keys that failed or did not load
+// in time; those keep being retried every refresh interval.
+func (c *Cache) Warm(ctx context.Context) error {
+	c.warmMu.Lock()
+	c.warming = true
+	pending := make([]*warmup, 0, len(c.warmups))
+	for _, w := range c.warmups {
+		c.startWarmup(w)
+		pending = append(pending, w)
+	}
+	c.warmMu.Unlock()
+
+	var errs []error
+	for _, w := range pending {
+		select {
+		case <-w.ready:
+			if status := w.status(); !status.Loaded {
+				errs = append(errs, fmt.Errorf("%s: %s", w.key, status.LastError))
+			}
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("%s: not loaded before the warmup deadline", w.key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startWarmup runs w's refresh loop; c.warmMu must be held.
+func (c *Cache) startWarmup(w *warmup) {
+	if w.started || c.isStopped() {
+		return
+	}
+	w.started = true
+
+	c.warmWG.Add(1)
+	go func() {
+		defer c.warmWG.Done()
+
+		ticker := time.NewTicker(w.refreshEvery)
+		defer ticker.Stop()
+		ok := c.refreshWarmup(w)
+		close(w.ready)
+		for ok {
+			select {
+			case <-ticker.C:
+				ok = c.refreshWarmup(w)
+			case <-c.stopped:
+				return
+			}
+		}
+	}()
+}
+
+// refreshWarmup loads w once and stores the result, or keeps the previous
+// value on failure. It returns false when the cache was stopped meanwhile;
+// a loader still running then is abandoned.
+func (c *Cache) refreshWarmup(w *warmup) bool {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("loader panicked: %v", r)}
+			}
+		}()
+		value, err := w.loader()
+		done <- result{value, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-c.stopped:
+		return false
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	if res.err == nil {
+		w.value, w.loaded, w.lastRefresh, w.failures = res.value, true, now, 0
+		w.mu.Unlock()
+		c.Set(w.key, res.value, w.ttl)
+		return true
+	}
+
+	w.lastErr, w.lastErrAt = res.err, now
+	w.failures++
+	event := WarmupFailedEvent{Key: w.key, Err: res.err}
+	value, loaded := w.value, w.loaded
+	if loaded {
+		event.Age = now.Sub(w.lastRefresh)
+		event.Dropped = event.Age > w.maxStale
+		if event.Dropped {
+			w.value, w.loaded = nil, false
+		}
+	}
+	w.mu.Unlock()
+
+	switch {
+	case event.Dropped:
+		c.Delete(w.key)
+		logger.Error("Cache warmup of %s failed and its value is %v old, dropping it: %v", w.key, event.Age.Round(time.Second), res.err)
+	case loaded:
+		// Serve the last good value for another interval.
+		c.Set(w.key, value, w.ttl)
+		logger.Warn("Cache warmup of %s failed, serving the value from %v ago: %v", w.key, event.Age.Round(time.Second), res.err)
+	default:
+		logger.Warn("Cache warmup of %s failed: %v", w.key, res.err)
+	}
+	events.Emit(context.Background(), EventWarmupFailed, event)
+	return true
+}
+
+func (w *warmup) status() WarmupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := WarmupStatus{
+		Key:            w.key,
+		TTLMillis:      w.ttl.Milliseconds(),
+		RefreshMillis:  w.refreshEvery.Milliseconds(),
+		MaxStaleMillis: w.maxStale.Milliseconds(),
+		Loaded:         w.loaded,
+		Failures:       w.failures,
+	}
+	if !w.lastRefresh.IsZero() {
+		lastRefresh := w.lastRefresh
+		status.LastRefresh = &lastRefresh
+	}
+	if w.lastErr != nil {
+		lastErrAt := w.lastErrAt
+		status.LastError = w.lastErr.Error()
+		status.LastErrorAt = &lastErrAt
+	}
+	return status
+}
+
+// WarmupStatus lists the registered keys, sorted.
+func (c *Cache) WarmupStatus() []WarmupStatus {
+	c.warmMu.Lock()
+	warmups := make([]*warmup, 0, len(c.warmups))
+	for _, w := range c.warmups {
+		warmups = append(warmups, w)
+	}
+	c.warmMu.Unlock()
+
+	statuses := make([]WarmupStatus, 0, len(warmups))
+	for _, w := range warmups {
+		statuses = append(statuses, w.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
+
+func RegisterWarmup(key string, loader func() (interface{}, error), ttl, refreshEvery time.Duration, opts ...WarmupOption) {
+	if DefaultCache != nil {
+		DefaultCache.RegisterWarmup(key, loader, ttl, refreshEvery, opts...)
+	}
+}
+
+func Warm(ctx context.Context) error {
+	if DefaultCache != nil {
+		return DefaultCache.Warm(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flugo.com/events"
+)
+
+func TestWarmLoadsKeysInParallel(t *testing.T) {
+	c := newTestCache(t)
+	for _, key := range []string{"settings", "flags", "categories"} {
+		key := key
+		c.RegisterWarmup(key, func() (interface{}, error) {
+			time.Sleep(50 * time.Millisecond)
+			return key + " value", nil
+		}, time.Minute, 0)
+	}
+
+	start := time.Now()
+	if err := c.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 140*time.Millisecond {
+		t.Errorf("Warm took %v, want the loads to run in parallel", elapsed)
+	}
+
+	if value, ok := c.Get("flags"); !ok || value != "flags value" {
+		t.Errorf("Get(flags) = %v, %v", value, ok)
+	}
+	statuses := c.WarmupStatus()
+	if len(statuses) != 3 || statuses[0].Key != "categories" || !statuses[0].Loaded || statuses[0].LastRefresh == nil {
+		t.Errorf("WarmupStatus() = %+v", statuses)
+	}
+	if statuses[0].RefreshMillis != 30000 {
+		t.Errorf("refresh interval = %dms, want half the TTL", statuses[0].RefreshMillis)
+	}
+}
+
+func TestWarmupKeepsServingUntilMaxStale(t *testing.T) {
+	c := newTestCache(t)
+	var failing atomic.Bool
+	var loads atomic.Int32
+	c.RegisterWarmup("flags", func() (interface{}, error) {
+		n := loads.Add(1)
+		if failing.Load() {
+			return nil, errors.New("flag service down")
+		}
+		return n, nil
+	}, time.Second, 20*time.Millisecond, WithMaxStale(150*time.Millisecond))
+
+	dropped := make(chan WarmupFailedEvent, 1)
+	unsubscribe := events.On(EventWarmupFailed, func(ctx context.Context, e WarmupFailedEvent) error {
+		if e.Dropped {
+			select {
+			case dropped <- e:
+			default:
+			}
+		}
+		return nil
+	})
+	defer unsubscribe()
+
+	if err := c.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if value, _ := c.Get("flags"); value.(int32) < 2 {
+		t.Errorf("value %v not refreshed in the background", value)
+	}
+
+	failing.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	value, ok := c.Get("flags")
+	if !ok {
+		t.Fatal("value dropped after the first failed refreshes")
+	}
+	status := c.WarmupStatus()[0]
+	if status.LastError != "flag service down" || status.Failures == 0 || !status.Loaded {
+		t.Errorf("status while failing = %+v", status)
+	}
+
+	select {
+	case e := <-dropped:
+		if e.Age <= 150*time.Millisecond {
+			t.Errorf("dropped after %v, before the max staleness", e.Age)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stale value never dropped")
+	}
+	if _, ok := c.Get("flags"); ok {
+		t.Errorf("Get still returns %v past the max staleness", value)
+	}
+}
+
+func TestWarmDeadlineAndStop(t *testing.T) {
+	c := New(100, time.Minute)
+	release := make(chan struct{})
+	defer close(release)
+	c.RegisterWarmup("slow", func() (interface{}, error) {
+		<-release
+		return "late", nil
+	}, time.Minute, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Warm(ctx); err == nil {
+		t.Error("Warm returned no error for a key that missed the deadline")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		c.Stop()
+		c.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for a hung loader")
+	}
+
+	// Keys registered after Stop are not loaded.
+	c.RegisterWarmup("after", func() (interface{}, error) { return 1, nil }, time.Minute, 0)
+	if _, ok := c.Get("after"); ok {
+		t.Error("warmup ran on a stopped cache")
+	}
+}
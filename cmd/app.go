@@ -1,59 +1,414 @@
 package cmd
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"flugo.com/auth"
 	"flugo.com/cache"
 	"flugo.com/config"
 	"flugo.com/container"
+	"flugo.com/docs"
+	"flugo.com/email"
+	"flugo.com/export"
+	"flugo.com/featureflags"
+	"flugo.com/framework"
+	"flugo.com/health"
+	"flugo.com/i18n"
 	"flugo.com/logger"
 	"flugo.com/middleware"
 	"flugo.com/module"
+	"flugo.com/queue"
+	"flugo.com/response"
 	"flugo.com/router"
+	"flugo.com/tasks"
+	"flugo.com/trace"
 	"flugo.com/upload"
 )
 
+const defaultGracePeriod = 30 * time.Second
+
+// warmupTimeout bounds how long Start waits for cache warmups before
+// serving; keys still loading then are served once they arrive.
+const warmupTimeout = 10 * time.Second
+
 type Application struct {
 	container *container.Container
 	router    *router.Router
 	modules   []*module.Module
 	config    *config.Config
+	services  *framework.Services
+
+	gracePeriod time.Duration
+
+	mu     sync.Mutex
+	server *http.Server
+}
+
+// Start boots providers and modules, serves HTTP until SIGINT, SIGTERM or
+// Shutdown, then releases every subsystem within the grace period.
+func (a *Application) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.container.StartAll(ctx); err != nil {
+		return err
+	}
+	if len(a.modules) > 0 {
+		logger.Info("Modules: %s", strings.Join(a.ModuleNames(), ", "))
+	}
+	for _, m := range a.modules {
+		if err := m.Start(ctx); err != nil {
+			// Stops the modules started so far, in reverse, and releases
+			// the subsystems already running.
+			return errors.Join(fmt.Errorf("failed to start %w", err), a.shutdown())
+		}
+	}
+
+	warmCtx, cancelWarm := context.WithTimeout(ctx, warmupTimeout)
+	if err := cache.Warm(warmCtx); err != nil {
+		logger.Warn("Cache warmup incomplete: %v", err)
+	}
+	cancelWarm()
+
+	if a.config.Server.ShowBanner {
+		a.PrintBanner(os.Stdout)
+	}
+
+	server := a.newServer()
+	a.mu.Lock()
+	a.server = server
+	a.mu.Unlock()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server listening on %s", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	case <-ctx.Done():
+		logger.Info("Shutdown signal received, draining for up to %v", a.gracePeriod)
+	}
+	stop()
+
+	return errors.Join(err, a.shutdown())
+}
+
+func (a *Application) newServer() *http.Server {
+	cfg := a.config.Server
+	return &http.Server{
+		Addr:         net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Handler:      a.router,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+	}
+}
+
+// shutdown stops the HTTP server first so no new work arrives, then modules,
+// providers, background tasks, the queue, the trace exporter, the database,
+// the cache and finally the logger.
+func (a *Application) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.gracePeriod)
+	defer cancel()
+
+	var errs []error
+	if err := a.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+
+	for i := len(a.modules) - 1; i >= 0; i-- {
+		errs = append(errs, a.modules[i].Stop(ctx))
+	}
+	errs = append(errs, a.container.StopAll(ctx))
+
+	// Background tasks stop before the queue drains, so delayed pushes do not
+	// land in a queue that is shutting down.
+	if err := tasks.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if q := a.services.Queue(); q != nil {
+		if err := q.Drain(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		q.Stop()
+	}
+
+	if err := trace.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("trace exporter: %w", err))
+	}
+
+	if db := a.services.DB(); db != nil {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("database: %w", err))
+		}
+	}
+
+	if c := a.services.Cache(); c != nil {
+		c.Stop()
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		logger.Error("Shutdown finished with errors: %v", err)
+	} else {
+		logger.Info("Application stopped")
+	}
+	logger.Sync()
+
+	return err
 }
 
-func (a *Application) Start() {
-	panic("unimplemented")
+func clearCacheHandler(w http.ResponseWriter, r *http.Request) {
+	cleared := 0
+	if c := framework.From(r).Cache(); c != nil {
+		cleared = c.Size()
+		c.Clear()
+	}
+	response.Success(w, map[string]interface{}{"cleared": cleared}, "Cache cleared")
+}
+
+// mountAdmin registers the endpoints enabled by ServerConfig.EnableAdmin.
+func mountAdmin(r *router.Router, cfg *config.Config) {
+	admin := []router.MiddlewareFunc{auth.RequireAuth(), auth.RequireRoles("admin")}
+
+	r.POST("/admin/cache/clear", clearCacheHandler, admin...)
+	r.GET("/admin/config", func(w http.ResponseWriter, r *http.Request) {
+		response.Success(w, cfg.Diff(), "Configuration overrides")
+	}, admin...)
+	r.GET("/debug/cache/hot", router.HandlerFunc(cache.HotKeysHandler()), admin...)
+	r.GET("/admin/breakers", middleware.CircuitBreakerAdminHandler(), admin...)
+	r.POST("/admin/breakers", middleware.CircuitBreakerAdminHandler(), admin...)
+	r.GET("/admin/flags", featureflags.AdminHandler(), admin...)
+	r.POST("/admin/flags", featureflags.AdminHandler(), admin...)
+}
+
+// exportSigningKey returns ExportConfig.SigningKey, or a key derived from
+// the JWT secret so that download links are not signed with it directly.
+func exportSigningKey(cfg *config.Config) string {
+	if cfg.Export.SigningKey != "" {
+		return cfg.Export.SigningKey
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.JWT.Secret))
+	mac.Write([]byte("export-links"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func initTracing(cfg *config.TraceConfig) {
+	switch cfg.Exporter {
+	case "log":
+		trace.SetExporter(trace.NewLogExporter())
+	case "otlp":
+		trace.SetExporter(trace.NewOTLPExporter(trace.OTLPOptions{
+			Endpoint:    cfg.Endpoint,
+			ServiceName: cfg.ServiceName,
+		}))
+		logger.Info("Exporting traces to %s", cfg.Endpoint)
+	}
 }
 
 func NewApplication() *Application {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal("Failed to load config: %v", err)
+	}
+	return newApplication(cfg)
+}
 
+func newApplication(cfg *config.Config) *Application {
 	logger.Init(&cfg.Logger)
+	if cfg.Server.LogConfigDiff {
+		for _, o := range cfg.Diff() {
+			logger.Info("Config %s = %v (default %v, from %s)", o.Path, o.Value, o.Default, o.Source)
+		}
+	}
 	cache.Init(1000, 30*time.Minute)
 	auth.Init(&cfg.JWT)
 	upload.Init(&cfg.Upload)
+	featureflags.Init(&cfg.Flags)
+	export.Init(export.Config{
+		Directory:  filepath.Join(cfg.Upload.UploadPath, "exports"),
+		SigningKey: exportSigningKey(cfg),
+		BaseURL:    cfg.Export.BaseURL,
+	})
+	if cfg.Export.Enabled && cfg.Export.BaseURL == "" {
+		logger.Warn("EXPORT_BASE_URL is not set; emailed export links will be relative")
+	}
+	email.Init(&email.EmailConfig{
+		SMTPHost:   cfg.Email.SMTPHost,
+		SMTPPort:   cfg.Email.SMTPPort,
+		Username:   cfg.Email.Username,
+		Password:   cfg.Email.Password,
+		FromEmail:  cfg.Email.FromEmail,
+		FromName:   cfg.Email.FromName,
+		ReplyTo:    cfg.Email.ReplyTo,
+		EnableSSL:  cfg.Email.EnableSSL,
+		EnableAuth: cfg.Email.EnableAuth,
+	})
+	response.SetPrettyPrint(cfg.Server.PrettyPrint)
+	response.SetDebug(cfg.Server.Debug)
+	if err := i18n.Init(&cfg.I18n); err != nil {
+		logger.Error("Failed to load translations: %v", err)
+	}
+	initTracing(&cfg.Trace)
+
+	gracePeriod := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if gracePeriod <= 0 {
+		gracePeriod = defaultGracePeriod
+	}
 
 	c := container.NewContainer()
 	r := router.NewRouter(c)
+	app := &Application{
+		container: c,
+		router:    r,
+		modules:   make([]*module.Module, 0),
+		config:    cfg,
+		services: framework.New(framework.Options{
+			Cache:  cache.DefaultCache,
+			Mailer: email.DefaultEmailService,
+			Logger: logger.DefaultLogger,
+			Config: cfg,
+		}),
+		gracePeriod: gracePeriod,
+	}
 
+	r.Use(app.provideServices)
+	if len(cfg.Server.TrustedProxies) > 0 {
+		r.Use(middleware.ProxyHeaders(cfg.Server.TrustedProxies, middleware.ForwardingHeaders(cfg.Server.ProxyHeaders)))
+	}
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Trace())
 	r.Use(middleware.Recovery())
 	r.Use(middleware.Logger())
 	r.Use(middleware.CORS())
+	if i18n.Loaded() {
+		r.Use(middleware.Locale())
+	}
 
-	return &Application{
-		container: c,
-		router:    r,
-		modules:   make([]*module.Module, 0),
-		config:    cfg,
+	if cfg.JWT.EnableSessionRoutes {
+		r.POST("/auth/refresh", auth.RefreshHandler())
+		r.DELETE("/auth/sessions/{id}", auth.RevokeSessionHandler(), auth.RequireAuth())
+		r.GET("/auth/sessions", auth.SessionsHandler(), auth.RequireAuth())
+		r.DELETE("/auth/sessions", auth.RevokeOtherSessionsHandler(), auth.RequireAuth())
+	}
+	if len(cfg.JWT.Clients) > 0 {
+		r.POST("/oauth/token", auth.TokenHandler(auth.ClientsFromConfig(cfg.JWT.Clients)))
+	}
+	if cfg.Server.EnableAdmin {
+		mountAdmin(r, cfg)
+	}
+	if cfg.Export.Enabled {
+		r.GET(export.DownloadPath, export.DownloadHandler())
+	}
+	if cfg.Server.EnableMetrics {
+		r.GET("/metrics/queues", queue.MetricsHandler(), auth.RequireAuth(), auth.RequireRoles("admin"))
+		r.GET("/metrics/breakers", func(w http.ResponseWriter, r *http.Request) {
+			response.Success(w, middleware.CircuitBreakerStats())
+		}, auth.RequireAuth(), auth.RequireRoles("admin"))
+	}
+	if cfg.Server.EnableHealth {
+		// Probes are mounted before /health, which would shadow them by prefix.
+		health.Mount(r, "1.0.0")
+		r.GET("/health", health.ReadyHandler("1.0.0"))
+	}
+
+	if cfg.Server.EnableSwagger {
+		docs.Mount(r, docs.Info{Title: "Flugo API", Version: "1.0.0"})
+	}
+
+	return app
+}
+
+// provideServices attaches the application's services to each request. It
+// reads them per request because Build adds the database and queue after
+// the middleware is registered.
+func (a *Application) provideServices(next router.HandlerFunc) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(a.services.WithContext(r.Context())))
 	}
 }
 
-func (a *Application) RegisterModule(m *module.Module) {
-	a.modules = append(a.modules, m)
-	m.Bootstrap(a.container, a.router)
+// Services returns the services handlers reach through framework.From.
+func (a *Application) Services() *framework.Services {
+	return a.services
+}
+
+// Handler returns the router serving the application, for use with
+// httptest or a custom server.
+func (a *Application) Handler() http.Handler {
+	return a.router
+}
+
+func (a *Application) Routes() []router.Route {
+	return a.router.Routes()
+}
+
+// RegisterModule bootstraps m and its imports. The application keeps every
+// module once, in dependency order, so start and shutdown follow imports.
+func (a *Application) RegisterModule(m *module.Module) error {
+	if err := m.Bootstrap(a.container, a.router); err != nil {
+		return err
+	}
+
+	resolved, err := module.Resolve(m)
+	if err != nil {
+		return err
+	}
+	for _, mod := range resolved {
+		if !slices.Contains(a.modules, mod) {
+			a.modules = append(a.modules, mod)
+		}
+	}
+	return nil
+}
+
+// LoadPlugin registers the module exported by the plugin at soPath; see
+// module.LoadPlugin for how to build one. Call it before Start, for example
+// from WithSetup, so the module's routes exist and its OnStart hook runs.
+func (a *Application) LoadPlugin(soPath string) error {
+	m, err := module.LoadPlugin(soPath)
+	if err != nil {
+		return err
+	}
+	if err := a.RegisterModule(m); err != nil {
+		return fmt.Errorf("plugin %s: %w", soPath, err)
+	}
+	logger.Info("Loaded plugin %s (module %s)", soPath, m.Name())
+	return nil
+}
+
+func (a *Application) Modules() []*module.Module {
+	return a.modules
+}
+
+func (a *Application) ModuleNames() []string {
+	names := make([]string, len(a.modules))
+	for i, m := range a.modules {
+		names[i] = m.Name()
+	}
+	return names
 }
 
 func (a *Application) Use(middleware router.MiddlewareFunc) {
@@ -76,17 +431,48 @@ func (a *Application) DELETE(path string, handler router.HandlerFunc, middleware
 	a.router.DELETE(path, handler, middlewares...)
 }
 
-func (a *Application) Listen(port int) error {
-	address := fmt.Sprintf(":%d", port)
-	log.Printf("Server starting on port %d", port)
-	return http.ListenAndServe(address, a.router)
+// Listen serves the application on port in the background and returns the
+// server, which Shutdown stops. Serving errors are logged. Start, which
+// also handles signals and releases every subsystem, is preferred.
+func (a *Application) Listen(port int) *http.Server {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: a.router,
+	}
+
+	a.mu.Lock()
+	a.server = server
+	a.mu.Unlock()
+
+	logger.Info("Server starting on port %d", port)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Server on port %d failed: %v", port, err)
+		}
+	}()
+	return server
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish or for ctx to expire.
+func (a *Application) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	server := a.server
+	a.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
 
 func Bootstrap(modules ...*module.Module) *Application {
 	app := NewApplication()
 
 	for _, m := range modules {
-		app.RegisterModule(m)
+		if err := app.RegisterModule(m); err != nil {
+			logger.Fatal("Failed to register module: %v", err)
+		}
 	}
 
 	return app
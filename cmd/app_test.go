@@ -0,0 +1,159 @@
+package cmd_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/cmd"
+	"flugo.com/container"
+	"flugo.com/export"
+	"flugo.com/module"
+)
+
+func TestListenAndShutdown(t *testing.T) {
+	entered := make(chan struct{})
+	app, err := cmd.New().WithConfig(testConfig(t)).WithSetup(func(app *cmd.Application) {
+		app.GET("/cli-ping", func(w http.ResponseWriter, r *http.Request) {})
+		app.GET("/cli-slow", func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			time.Sleep(50 * time.Millisecond)
+		})
+	}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	server := app.Listen(port)
+	if server.Addr != fmt.Sprintf(":%d", port) {
+		t.Errorf("Addr = %s", server.Addr)
+	}
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(base + "/cli-ping")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not come up: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A request in flight when Shutdown starts still completes.
+	done := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(base + "/cli-slow")
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want 200", status)
+	}
+	if _, err := http.Get(base + "/cli-ping"); err == nil {
+		t.Error("server still answers after Shutdown")
+	}
+}
+
+func TestExportLinksUseBaseURLAndOwnKey(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Export.Enabled = true
+	cfg.Export.BaseURL = "https://api.example.com/"
+	app, err := cmd.New().WithConfig(cfg).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(cfg.Upload.UploadPath, "exports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "orders.csv"), []byte("id\n1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	link := export.SignedURL("orders.csv")
+	if !strings.HasPrefix(link, "https://api.example.com"+export.DownloadPath+"?") {
+		t.Fatalf("SignedURL = %q, want it under the configured base URL", link)
+	}
+
+	w := httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, httptest.NewRequest("GET", link, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("download status = %d, want 200", w.Code)
+	}
+
+	// A link signed with the JWT secret itself is not accepted.
+	jwtSigned := export.NewExporter(export.Config{SigningKey: cfg.JWT.Secret, Directory: dir})
+	forged, _ := jwtSigned.SignedURL("orders.csv")
+	w = httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, httptest.NewRequest("GET", forged, nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("download with a JWT-signed link status = %d, want 403", w.Code)
+	}
+}
+
+func TestStartFailureStopsStartedModules(t *testing.T) {
+	var hooks []string
+	hook := func(name string, fail bool) *module.Module {
+		return module.NewModule(module.ModuleConfig{
+			Name: name,
+			OnStart: func(ctx context.Context, c *container.Container) error {
+				hooks = append(hooks, "start "+name)
+				if fail {
+					return errors.New("no broker")
+				}
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				hooks = append(hooks, "stop "+name)
+				return nil
+			},
+		})
+	}
+	app, err := cmd.New().WithConfig(testConfig(t)).
+		WithModules(hook("cli-store", false), hook("cli-mailer", false), hook("cli-events", true)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.Start()
+	if err == nil || !strings.Contains(err.Error(), "failed to start module cli-events: no broker") {
+		t.Fatalf("Start error = %v", err)
+	}
+	want := "start cli-store,start cli-mailer,start cli-events,stop cli-mailer,stop cli-store"
+	if got := strings.Join(hooks, ","); got != want {
+		t.Errorf("hooks = %s, want %s", got, want)
+	}
+	if err := app.Services().DB().Ping(context.Background()); err == nil {
+		t.Error("database still open after a failed start")
+	}
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/config"
+	"flugo.com/queue"
+	"flugo.com/router"
+)
+
+type bannerReport struct {
+	Address       string            `json:"address"`
+	Config        map[string]string `json:"config"`
+	Modules       []string          `json:"modules"`
+	Middlewares   []string          `json:"middlewares"`
+	Routes        []bannerRoute     `json:"routes"`
+	Queue         string            `json:"queue,omitempty"`
+	QueueHandlers []string          `json:"queue_handlers,omitempty"`
+	Schedules     []bannerSchedule  `json:"schedules,omitempty"`
+
+	settings [][2]string
+}
+
+type bannerRoute struct {
+	Group       string   `json:"group"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Module      string   `json:"module,omitempty"`
+	Middlewares []string `json:"middlewares"`
+}
+
+type bannerSchedule struct {
+	Name     string    `json:"name"`
+	CronExpr string    `json:"cron_expr"`
+	JobType  string    `json:"job_type"`
+	NextRun  time.Time `json:"next_run"`
+}
+
+// PrintBanner writes a startup report built from the application's actual
+// state: a configuration summary with secrets masked, modules, routes with
+// their middleware, queue handlers and scheduled jobs. It is a single JSON
+// object when the logger format is json and aligned text otherwise.
+func (a *Application) PrintBanner(w io.Writer) {
+	report := a.bannerReport()
+
+	if a.config.Logger.Format == "json" {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	writeBannerText(w, report)
+}
+
+func (a *Application) bannerReport() *bannerReport {
+	cfg := a.config
+	report := &bannerReport{
+		Address:     net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port)),
+		Config:      make(map[string]string),
+		Modules:     a.ModuleNames(),
+		Middlewares: a.router.MiddlewareNames(),
+		settings:    configSummary(cfg),
+	}
+	for _, s := range report.settings {
+		report.Config[s[0]] = s[1]
+	}
+
+	owners := make(map[string]string)
+	for _, m := range a.modules {
+		for _, route := range m.Routes() {
+			owners[route.Method+" "+route.Path] = m.Name()
+		}
+	}
+	for _, route := range a.router.Routes() {
+		report.Routes = append(report.Routes, bannerRoute{
+			Group:       routeGroup(route.Path),
+			Method:      route.Method,
+			Path:        route.Path,
+			Module:      owners[route.Method+" "+route.Path],
+			Middlewares: router.MiddlewareNames(route.Middlewares),
+		})
+	}
+	// Stable, so routes keep registration order within their group.
+	groupOrder := make(map[string]int)
+	for _, route := range report.Routes {
+		if _, ok := groupOrder[route.Group]; !ok {
+			groupOrder[route.Group] = len(groupOrder)
+		}
+	}
+	sort.SliceStable(report.Routes, func(i, j int) bool {
+		return groupOrder[report.Routes[i].Group] < groupOrder[report.Routes[j].Group]
+	})
+
+	if q := queue.DefaultQueue; q != nil {
+		report.Queue = q.Name()
+		report.QueueHandlers = q.Handlers()
+		for _, s := range q.Schedules() {
+			report.Schedules = append(report.Schedules, bannerSchedule{
+				Name:     s.Name,
+				CronExpr: s.CronExpr,
+				JobType:  s.JobType,
+				NextRun:  s.NextRun,
+			})
+		}
+		sort.Slice(report.Schedules, func(i, j int) bool {
+			return report.Schedules[i].Name < report.Schedules[j].Name
+		})
+	}
+
+	return report
+}
+
+func writeBannerText(w io.Writer, report *bannerReport) {
+	fmt.Fprintf(w, "Flugo listening on %s\n\n", report.Address)
+
+	fmt.Fprintln(w, "Configuration")
+	rows := make([][]string, len(report.settings))
+	for i, s := range report.settings {
+		rows[i] = []string{s[0], s[1]}
+	}
+	writeColumns(w, "  ", rows)
+
+	modules := "none"
+	if len(report.Modules) > 0 {
+		modules = strings.Join(report.Modules, ", ")
+	}
+	fmt.Fprintf(w, "\nModules: %s\n", modules)
+	fmt.Fprintf(w, "Global middleware: %s\n", joinOrDash(report.Middlewares))
+
+	fmt.Fprintf(w, "\nRoutes (%d)\n", len(report.Routes))
+	rows = rows[:0]
+	group := ""
+	for i, route := range report.Routes {
+		if i == 0 || route.Group != group {
+			group = route.Group
+			rows = append(rows, []string{group})
+		}
+		module := route.Module
+		if module == "" {
+			module = "-"
+		}
+		rows = append(rows, []string{"  " + route.Method, route.Path, module, joinOrDash(route.Middlewares)})
+	}
+	writeColumns(w, "  ", rows)
+
+	if report.Queue == "" {
+		fmt.Fprintln(w, "\nQueue: disabled")
+		return
+	}
+	fmt.Fprintf(w, "\nQueue handlers (%s): %s\n", report.Queue, joinOrDash(report.QueueHandlers))
+	if len(report.Schedules) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Scheduled jobs (%d)\n", len(report.Schedules))
+	rows = rows[:0]
+	for _, s := range report.Schedules {
+		rows = append(rows, []string{s.Name, s.CronExpr, s.JobType, "next " + s.NextRun.Format(time.RFC3339)})
+	}
+	writeColumns(w, "  ", rows)
+}
+
+// writeColumns aligns every row with more than one cell; single-cell rows
+// are headings and do not affect the widths.
+func writeColumns(w io.Writer, indent string, rows [][]string) {
+	var widths []int
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		for i, cell := range row[:len(row)-1] {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	for _, row := range rows {
+		var b strings.Builder
+		b.WriteString(indent)
+		for i, cell := range row {
+			if i < len(row)-1 {
+				fmt.Fprintf(&b, "%-*s  ", widths[i], cell)
+			} else {
+				b.WriteString(cell)
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// configSummary describes cfg with credentials masked.
+func configSummary(cfg *config.Config) [][2]string {
+	db := cfg.Database
+	database := db.Driver + " " + db.Database
+	if db.Driver != "sqlite3" && db.Driver != "sqlite" && db.Driver != "" {
+		database = fmt.Sprintf("%s %s:%s@%s/%s", db.Driver, db.Username, mask(db.Password),
+			net.JoinHostPort(db.Host, strconv.Itoa(db.Port)), db.Database)
+	}
+
+	queueState := "disabled"
+	if cfg.Queue.Enabled {
+		queueState = fmt.Sprintf("%d workers", cfg.Queue.Workers)
+	}
+
+	var features []string
+	for _, f := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"swagger", cfg.Server.EnableSwagger},
+		{"metrics", cfg.Server.EnableMetrics},
+		{"profiling", cfg.Server.EnableProfiling},
+		{"admin", cfg.Server.EnableAdmin},
+		{"health", cfg.Server.EnableHealth},
+		{"sessions", cfg.JWT.EnableSessionRoutes},
+		{"exports", cfg.Export.Enabled},
+	} {
+		if f.enabled {
+			features = append(features, f.name)
+		}
+	}
+
+	return [][2]string{
+		{"server", fmt.Sprintf("%s (read %ds, write %ds, shutdown %ds)",
+			net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port)),
+			cfg.Server.ReadTimeout, cfg.Server.WriteTimeout, cfg.Server.ShutdownTimeout)},
+		{"features", joinOrDash(features)},
+		{"database", database},
+		{"jwt", fmt.Sprintf("secret %s, access %s, refresh %s", mask(cfg.JWT.Secret),
+			time.Duration(cfg.JWT.ExpirationTime)*time.Second, time.Duration(cfg.JWT.RefreshTime)*time.Second)},
+		{"redis", fmt.Sprintf("%s password %s", net.JoinHostPort(cfg.Redis.Host, strconv.Itoa(cfg.Redis.Port)), mask(cfg.Redis.Password))},
+		{"email", fmt.Sprintf("%s user %q password %s", net.JoinHostPort(cfg.Email.SMTPHost, strconv.Itoa(cfg.Email.SMTPPort)), cfg.Email.Username, mask(cfg.Email.Password))},
+		{"queue", queueState},
+		{"logger", fmt.Sprintf("%s, %s", cfg.Logger.Level, cfg.Logger.Format)},
+		{"uploads", cfg.Upload.UploadPath},
+		{"i18n", fmt.Sprintf("default %s, directory %q", cfg.I18n.DefaultLocale, cfg.I18n.Directory)},
+	}
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return "(not set)"
+	}
+	return "********"
+}
+
+// routeGroup is the first path segment, e.g. "/users" for "/users/:id".
+func routeGroup(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return "/" + segment
+}
+
+func joinOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/framework"
+	"flugo.com/health"
+	"flugo.com/logger"
+	"flugo.com/module"
+	"flugo.com/queue"
+	"flugo.com/router"
+	"flugo.com/validator"
+)
+
+// Builder assembles an Application:
+//
+//	err := cmd.New().WithModules(users.Module).WithMiddleware(middleware.JSONContentType()).Run()
+type Builder struct {
+	cfg         *config.Config
+	modules     []*module.Module
+	middlewares []router.MiddlewareFunc
+	setups      []func(app *Application)
+	gracePeriod time.Duration
+}
+
+func New() *Builder {
+	return &Builder{}
+}
+
+// WithConfig replaces the configuration otherwise read by config.Load.
+func (b *Builder) WithConfig(cfg *config.Config) *Builder {
+	b.cfg = cfg
+	return b
+}
+
+func (b *Builder) WithModules(modules ...*module.Module) *Builder {
+	b.modules = append(b.modules, modules...)
+	return b
+}
+
+func (b *Builder) WithMiddleware(middlewares ...router.MiddlewareFunc) *Builder {
+	b.middlewares = append(b.middlewares, middlewares...)
+	return b
+}
+
+// WithSetup runs fn on the built application, typically to add routes that
+// do not belong to a module.
+func (b *Builder) WithSetup(fn func(app *Application)) *Builder {
+	b.setups = append(b.setups, fn)
+	return b
+}
+
+// WithGracePeriod overrides ServerConfig.ShutdownTimeout.
+func (b *Builder) WithGracePeriod(d time.Duration) *Builder {
+	b.gracePeriod = d
+	return b
+}
+
+// Build initializes the database, queue and health checks and registers the
+// modules, returning an Application ready to Start.
+func (b *Builder) Build() (*Application, error) {
+	cfg, err := b.config()
+	if err != nil {
+		return nil, err
+	}
+
+	app := newApplication(cfg)
+	if b.gracePeriod > 0 {
+		app.gracePeriod = b.gracePeriod
+	}
+
+	var db *database.DB
+	if cfg.Database.Driver != "" {
+		var err error
+		db, err = database.NewDB(&cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database: %w", err)
+		}
+		database.DefaultDB = db
+		health.Register("database", health.DatabaseCheck(db))
+	}
+
+	var q *queue.Queue
+	if cfg.Queue.Enabled {
+		queue.Init(cfg.Queue.Workers)
+		q = queue.DefaultQueue
+		health.Register("queue", health.QueueDepthCheck(q, 900))
+		q.StartWatchdog(queue.WatchdogOptions{
+			MaxDepth:     cfg.Queue.WatchdogMaxDepth,
+			MaxOldestAge: time.Duration(cfg.Queue.WatchdogMaxAge) * time.Second,
+		})
+	}
+
+	app.services = framework.New(framework.Options{
+		DB:     db,
+		Cache:  app.services.Cache(),
+		Queue:  q,
+		Mailer: app.services.Mailer(),
+		Logger: logger.DefaultLogger,
+		Config: cfg,
+	})
+
+	health.Register("cache", health.CacheCheck(app.services.Cache()))
+	if cfg.Email.SMTPHost != "" {
+		health.Register("smtp", health.SMTPCheck(cfg.Email.SMTPHost, cfg.Email.SMTPPort), health.NonCritical())
+	}
+	health.Register("disk", health.DiskSpaceCheck(cfg.Upload.UploadPath, 100<<20), health.NonCritical())
+
+	validator.InitValidators()
+
+	for _, mw := range b.middlewares {
+		app.Use(mw)
+	}
+	for _, m := range b.modules {
+		if err := app.RegisterModule(m); err != nil {
+			return nil, err
+		}
+	}
+	for _, setup := range b.setups {
+		setup(app)
+	}
+
+	return app, nil
+}
+
+func (b *Builder) config() (*config.Config, error) {
+	if b.cfg == nil {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		b.cfg = cfg
+	}
+	return b.cfg, nil
+}
+
+// Run builds the application and blocks until it has shut down.
+func (b *Builder) Run() error {
+	app, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return app.Start()
+}
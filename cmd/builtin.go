@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
+	"time"
+	"unicode"
+
+	"flugo.com/auth"
+	"flugo.com/cache"
+	"flugo.com/database"
+	"flugo.com/email"
+	"flugo.com/featureflags"
+	"flugo.com/logger"
+	"flugo.com/queue"
+	"flugo.com/router"
+	"flugo.com/utils"
+)
+
+func init() {
+	RegisterCommand(Command{
+		Name:        "serve",
+		Description: "Start the HTTP server (default when no command is given)",
+		Run: func(ctx *CommandContext) error {
+			return ctx.Builder.Run()
+		},
+	})
+
+	RegisterCommand(Command{
+		Name:        "migrate",
+		Usage:       "up|down|status",
+		Description: "Apply, roll back or list database migrations",
+		Flags: func(fs *flag.FlagSet) {
+			fs.Int("steps", 1, "number of migrations to roll back with down")
+		},
+		Run: runMigrate,
+	})
+
+	RegisterCommand(Command{
+		Name:        "routes",
+		Description: "Print the route table",
+		Run:         runRoutes,
+	})
+
+	RegisterCommand(Command{
+		Name:        "email:check",
+		Description: "List email template variables and check typed templates against their data",
+		Run:         runEmailCheck,
+	})
+
+	RegisterCommand(Command{
+		Name:        "queue:work",
+		Description: "Run queue workers and schedules without the HTTP server",
+		Flags: func(fs *flag.FlagSet) {
+			fs.String("queues", "default", "comma separated queue names")
+			fs.Int("workers", 5, "workers per queue")
+		},
+		Run: runQueueWork,
+	})
+
+	RegisterCommand(Command{
+		Name:        "cache:clear",
+		Description: "Clear the cache of the running server",
+		Flags: func(fs *flag.FlagSet) {
+			fs.String("url", "", "server base URL (defaults to the configured port on localhost)")
+		},
+		Run: runCacheClear,
+	})
+
+	RegisterCommand(Command{
+		Name:        "make:controller",
+		Usage:       "Name",
+		Description: "Scaffold a controller with CRUD methods",
+		Flags: func(fs *flag.FlagSet) {
+			fs.String("dir", "controllers", "output directory; its name is used as the package")
+			fs.Bool("force", false, "overwrite an existing file")
+		},
+		Run: runMakeController,
+	})
+}
+
+func flagString(fs *flag.FlagSet, name string) string {
+	return fs.Lookup(name).Value.String()
+}
+
+func flagInt(fs *flag.FlagSet, name string) int {
+	n, _ := strconv.Atoi(fs.Lookup(name).Value.String())
+	return n
+}
+
+func openDatabase(ctx *CommandContext) (*database.DB, error) {
+	cfg, err := ctx.Builder.config()
+	if err != nil {
+		return nil, err
+	}
+	logger.Init(&cfg.Logger)
+
+	db, err := database.NewDB(&cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+func runMigrate(ctx *CommandContext) error {
+	action := "up"
+	if len(ctx.Args) > 0 {
+		action = ctx.Args[0]
+	}
+	if action != "up" && action != "down" && action != "status" {
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// openDatabase has loaded the configuration.
+	if cfg, _ := ctx.Builder.config(); cfg.Flags.UseDatabase {
+		database.RegisterMigration(featureflags.Migration)
+	}
+
+	switch action {
+	case "up":
+		ran, err := db.Migrate()
+		for _, m := range ran {
+			fmt.Fprintf(ctx.Out, "Migrated:    %s %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Fprintln(ctx.Out, "Nothing to migrate")
+		}
+		return err
+
+	case "down":
+		reverted, err := db.Rollback(flagInt(ctx.Flags, "steps"))
+		for _, m := range reverted {
+			fmt.Fprintf(ctx.Out, "Rolled back: %s %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(ctx.Out, "Nothing to roll back")
+		}
+		return err
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(ctx.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+	for _, s := range status {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Version, s.Name, state)
+	}
+	return tw.Flush()
+}
+
+func runRoutes(ctx *CommandContext) error {
+	app, err := ctx.Builder.Build()
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]string)
+	for _, m := range app.Modules() {
+		for _, route := range m.Routes() {
+			owners[route.Method+" "+route.Path] = m.Name()
+		}
+	}
+
+	tw := tabwriter.NewWriter(ctx.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tMIDDLEWARES\tMODULE")
+	for _, route := range app.Routes() {
+		owner := owners[route.Method+" "+route.Path]
+		if owner == "" {
+			owner = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", route.Method, route.Path, joinOrDash(router.MiddlewareNames(route.Middlewares)), owner)
+	}
+	return tw.Flush()
+}
+
+// runEmailCheck builds the application first so templates registered by
+// modules are checked too. It fails when a typed template uses a variable
+// its data type lacks, which makes it usable as a CI step.
+func runEmailCheck(ctx *CommandContext) error {
+	if _, err := ctx.Builder.Build(); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(ctx.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEMPLATE\tREQUIRED\tOPTIONAL")
+	for _, name := range email.Templates() {
+		required, optional, _ := email.TemplateVariables(name)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, joinOrDash(required), joinOrDash(optional))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return email.CheckTemplates()
+}
+
+// runQueueWork starts workers for the named queues until SIGINT or SIGTERM.
+// Queues live in process memory, so these workers handle jobs pushed by
+// handlers, schedules and modules running in this process.
+func runQueueWork(ctx *CommandContext) error {
+	cfg, err := ctx.Builder.config()
+	if err != nil {
+		return err
+	}
+	logger.Init(&cfg.Logger)
+	cache.Init(1000, 30*time.Minute)
+
+	if cfg.Database.Driver != "" {
+		db, err := database.NewDB(&cfg.Database)
+		if err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		database.DefaultDB = db
+		defer db.Close()
+	}
+
+	workers := flagInt(ctx.Flags, "workers")
+	if workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+
+	var queues []*queue.Queue
+	for _, name := range strings.Split(flagString(ctx.Flags, "queues"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		q := queue.Get(name)
+		if q == nil {
+			q = queue.NewQueue(name, workers)
+		}
+		if name == "default" {
+			queue.DefaultQueue = q
+		}
+		q.Start()
+		queues = append(queues, q)
+		fmt.Fprintf(ctx.Out, "Processing queue %q with %d workers\n", name, workers)
+	}
+	if len(queues) == 0 {
+		return fmt.Errorf("no queues given")
+	}
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-signalCtx.Done()
+
+	grace := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if grace <= 0 {
+		grace = defaultGracePeriod
+	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	var drainErr error
+	for _, q := range queues {
+		if err := q.Drain(drainCtx); err != nil && drainErr == nil {
+			drainErr = err
+		}
+		q.Stop()
+	}
+	return drainErr
+}
+
+// runCacheClear asks the running server to clear its cache. The cache lives
+// in the server's memory, so the command authenticates with a short-lived
+// admin token signed by the shared JWT secret. The token has no session,
+// since the server does not know the sessions of this process.
+func runCacheClear(ctx *CommandContext) error {
+	cfg, err := ctx.Builder.config()
+	if err != nil {
+		return err
+	}
+
+	baseURL := flagString(ctx.Flags, "url")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://127.0.0.1:%d", cfg.Server.Port)
+	}
+
+	token, err := auth.NewAuthService(&cfg.JWT).GenerateSessionlessToken(auth.Claims{
+		Username: "cli",
+		Roles:    []string{"admin"},
+	}, time.Minute)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(baseURL, "/")+"/admin/cache/clear", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach server at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("server at %s has no admin endpoints, set SERVER_ENABLE_ADMIN=true", baseURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	fmt.Fprintln(ctx.Out, "Cache cleared")
+	return nil
+}
+
+func runMakeController(ctx *CommandContext) error {
+	if len(ctx.Args) != 1 {
+		return fmt.Errorf("usage: make:controller Name")
+	}
+
+	name := strings.TrimSuffix(ctx.Args[0], "Controller")
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		return fmt.Errorf("invalid controller name %q", ctx.Args[0])
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	dir := flagString(ctx.Flags, "dir")
+	path := filepath.Join(dir, snakeName(name)+"_controller.go")
+
+	if _, err := os.Stat(path); err == nil && flagString(ctx.Flags, "force") != "true" {
+		return fmt.Errorf("%s already exists, use --force to overwrite", path)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pkg := strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, filepath.Base(dir)))
+
+	err = controllerTemplate.Execute(file, map[string]string{
+		"Package": pkg,
+		"Name":    name,
+		"Plural":  utils.Pluralize(name, 2),
+		"Path":    "/" + strings.ToLower(utils.Pluralize(name, 2)),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(ctx.Out, "Created %s\n", path)
+	return nil
+}
+
+// snakeName turns BlogPost into blog_post.
+func snakeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+var controllerTemplate = template.Must(template.New("controller").Parse(`package {{.Package}}
+
+import (
+	"net/http"
+
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// {{.Name}}Controller is auto-routed when registered with an empty base path:
+//
+//	r.RegisterController(New{{.Name}}Controller(), "")
+type {{.Name}}Controller struct{}
+
+func New{{.Name}}Controller() *{{.Name}}Controller {
+	return &{{.Name}}Controller{}
+}
+
+// GET {{.Path}}
+func (c *{{.Name}}Controller) Get{{.Plural}}(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, []interface{}{}, "{{.Plural}} retrieved successfully")
+}
+
+// GET {{.Path}}/{id}
+func (c *{{.Name}}Controller) Get{{.Plural}}ById(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	response.Success(w, map[string]interface{}{"id": id}, "{{.Name}} retrieved successfully")
+}
+
+// POST {{.Path}}
+func (c *{{.Name}}Controller) Post{{.Plural}}(w http.ResponseWriter, r *http.Request) {
+	response.Created(w, nil, "{{.Name}} created successfully")
+}
+
+// PUT {{.Path}}/{id}
+func (c *{{.Name}}Controller) Put{{.Plural}}ById(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	response.Success(w, map[string]interface{}{"id": id}, "{{.Name}} updated successfully")
+}
+
+// DELETE {{.Path}}/{id}
+func (c *{{.Name}}Controller) Delete{{.Plural}}ById(w http.ResponseWriter, r *http.Request) {
+	response.Deleted(w, "{{.Name}} deleted successfully")
+}
+`))
@@ -0,0 +1,318 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/cmd"
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/email"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.Default()
+	dir := t.TempDir()
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Server.ShowBanner = false
+	cfg.Server.ShutdownTimeout = 5
+	cfg.Database.Driver = "sqlite3"
+	cfg.Database.Database = filepath.Join(dir, "app.db")
+	cfg.Upload.UploadPath = dir
+	cfg.Logger.Level = "error"
+	cfg.Queue.Enabled = false
+	cfg.JWT.Secret = "cli-test-secret"
+	return cfg
+}
+
+func run(t *testing.T, b *cmd.Builder, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := b.ExecuteArgs(args, &out)
+	return out.String(), err
+}
+
+func TestHelpListsCommands(t *testing.T) {
+	out, err := run(t, cmd.New(), "help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"serve", "migrate up|down|status", "routes", "email:check", "queue:work", "cache:clear", "make:controller Name"} {
+		if !strings.Contains(out, "  "+name) {
+			t.Errorf("help does not list %q:\n%s", name, out)
+		}
+	}
+
+	if _, err := run(t, cmd.New(), "nope"); err == nil || !strings.Contains(err.Error(), `unknown command "nope"`) {
+		t.Errorf("unknown command: err = %v", err)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	database.RegisterMigration(database.SQLMigration("20000101000001", "create_cli_notes",
+		"CREATE TABLE cli_notes (id INTEGER PRIMARY KEY)", "DROP TABLE cli_notes"))
+	database.RegisterMigration(database.SQLMigration("20000101000002", "create_cli_tags",
+		"CREATE TABLE cli_tags (id INTEGER PRIMARY KEY)", "DROP TABLE cli_tags"))
+	b := cmd.New().WithConfig(testConfig(t))
+
+	steps := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"migrate", "status"}, []string{"20000101000001  create_cli_notes  pending"}},
+		{[]string{"migrate"}, []string{"Migrated:    20000101000001 create_cli_notes", "Migrated:    20000101000002 create_cli_tags"}},
+		{[]string{"migrate", "up"}, []string{"Nothing to migrate"}},
+		{[]string{"migrate", "status"}, []string{"20000101000002  create_cli_tags   applied 2"}},
+		{[]string{"migrate", "down", "--steps=2"}, []string{"Rolled back: 20000101000002 create_cli_tags", "Rolled back: 20000101000001 create_cli_notes"}},
+		{[]string{"migrate", "down"}, []string{"Nothing to roll back"}},
+	}
+	for _, step := range steps {
+		out, err := run(t, b, step.args...)
+		if err != nil {
+			t.Fatalf("%v: %v", step.args, err)
+		}
+		for _, want := range step.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%v: output does not contain %q:\n%s", step.args, want, out)
+			}
+		}
+	}
+
+	if _, err := run(t, b, "migrate", "sideways"); err == nil {
+		t.Error("unknown migrate action accepted")
+	}
+
+	// Database-backed feature flags bring their table along.
+	cfg := testConfig(t)
+	cfg.Flags.UseDatabase = true
+	if out, err := run(t, cmd.New().WithConfig(cfg), "migrate"); err != nil || !strings.Contains(out, "create_feature_flags") {
+		t.Errorf("migrate with database flags: %v\n%s", err, out)
+	}
+}
+
+func TestRoutesCommand(t *testing.T) {
+	b := cmd.New().WithConfig(testConfig(t)).WithSetup(func(app *cmd.Application) {
+		app.GET("/cli-ping", func(w http.ResponseWriter, r *http.Request) {}, auth.RequireAuth())
+	})
+	out, err := run(t, b, "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "METHOD") {
+		t.Errorf("routes output has no header:\n%s", out)
+	}
+	line := lineWith(out, "/cli-ping")
+	if fields := strings.Fields(line); len(fields) != 4 || fields[0] != "GET" || fields[3] != "-" {
+		t.Errorf("route line = %q, want GET /cli-ping with its middleware and no module", line)
+	}
+}
+
+type welcomeData struct {
+	Name string
+}
+
+func TestEmailCheckCommand(t *testing.T) {
+	if err := email.RegisterTypedTemplate[welcomeData]("cli-welcome", "Hi {{.Name}}{{if .Plan}} on {{.Plan}}{{end}}"); err != nil {
+		t.Fatal(err)
+	}
+	b := cmd.New().WithConfig(testConfig(t))
+
+	out, err := run(t, b, "email:check")
+	if fields := strings.Fields(lineWith(out, "cli-welcome")); len(fields) != 3 || fields[1] != "Name" || fields[2] != "Plan" {
+		t.Errorf("email:check output:\n%s", out)
+	}
+	if err == nil || !strings.Contains(err.Error(), "Plan") {
+		t.Errorf("err = %v, want the missing Plan field reported", err)
+	}
+
+	if err := email.RegisterTypedTemplate[welcomeData]("cli-welcome", "Hi {{.Name}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, b, "email:check"); err != nil {
+		t.Errorf("email:check with a matching template: %v", err)
+	}
+}
+
+func TestQueueWorkCommand(t *testing.T) {
+	for _, args := range [][]string{{"queue:work", "--workers=0"}, {"queue:work", "--queues= ,"}} {
+		if _, err := run(t, cmd.New().WithConfig(testConfig(t)), args...); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+
+	// With a handler of our own registered, SIGTERM does not kill the test
+	// binary while the command is still starting up.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	// Queues are registered by name for the life of the process, so each
+	// run works fresh ones.
+	mail, reports := fmt.Sprintf("cli-mail-%d", time.Now().UnixNano()), fmt.Sprintf("cli-reports-%d", time.Now().UnixNano())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.New().WithConfig(testConfig(t)).ExecuteArgs([]string{"queue:work", "--queues=" + mail + ", " + reports, "--workers=2"}, &out)
+	}()
+
+	self, _ := os.FindProcess(os.Getpid())
+	timeout := time.After(5 * time.Second)
+	for stopped := false; !stopped; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			stopped = true
+		case <-time.After(20 * time.Millisecond):
+			self.Signal(syscall.SIGTERM)
+		case <-timeout:
+			t.Fatal("queue:work did not stop on SIGTERM")
+		}
+	}
+
+	want := fmt.Sprintf("Processing queue %q with 2 workers\nProcessing queue %q with 2 workers\n", mail, reports)
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+}
+
+func TestCacheClearCommand(t *testing.T) {
+	cfg := testConfig(t)
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		claims, err := auth.NewAuthService(&cfg.JWT).ValidateToken(token)
+		if r.Method != http.MethodPost || r.URL.Path != "/admin/cache/clear" || err != nil || !slices.Contains(claims.Roles, "admin") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	b := cmd.New().WithConfig(cfg)
+
+	out, err := run(t, b, "cache:clear", "--url="+server.URL+"/")
+	if err != nil || out != "Cache cleared\n" {
+		t.Errorf("cache:clear = %q, %v", out, err)
+	}
+
+	status = http.StatusForbidden
+	if out, err := run(t, b, "cache:clear", "--url="+server.URL); err == nil || out != "" {
+		t.Errorf("cache:clear against a refusing server = %q, %v", out, err)
+	}
+}
+
+func TestMakeControllerCommand(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "http-handlers")
+	b := cmd.New()
+
+	out, err := run(t, b, "make:controller", "BlogPostController", "--dir="+dir)
+	path := filepath.Join(dir, "blog_post_controller.go")
+	if err != nil || out != "Created "+path+"\n" {
+		t.Fatalf("make:controller = %q, %v", out, err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatalf("generated controller does not parse: %v", err)
+	}
+	if file.Name.Name != "httphandlers" {
+		t.Errorf("package = %s, want httphandlers", file.Name.Name)
+	}
+	src, _ := os.ReadFile(path)
+	for _, want := range []string{"type BlogPostController struct", "func (c *BlogPostController) GetBlogPostsById("} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("generated controller lacks %q", want)
+		}
+	}
+
+	if _, err := run(t, b, "make:controller", "BlogPost", "--dir="+dir); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("overwrite without --force: err = %v", err)
+	}
+	if _, err := run(t, b, "make:controller", "--force", "BlogPost", "--dir="+dir); err != nil {
+		t.Errorf("overwrite with --force: %v", err)
+	}
+	for _, args := range [][]string{{"make:controller"}, {"make:controller", "1Post"}, {"make:controller", "A", "B"}} {
+		if _, err := run(t, b, args...); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+}
+
+func TestServeCommand(t *testing.T) {
+	var app *cmd.Application
+	ready := make(chan struct{})
+	b := cmd.New().WithConfig(testConfig(t)).WithSetup(func(a *cmd.Application) {
+		app = a
+		close(ready)
+	})
+
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- b.ExecuteArgs([]string{"serve"}, &out) }()
+	<-ready
+
+	// Shutdown is a no-op until the server exists, so keep asking.
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != "" {
+				t.Errorf("serve wrote %q to the command output", out.String())
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+			app.Shutdown(context.Background())
+		case <-timeout:
+			t.Fatal("serve did not stop after Shutdown")
+		}
+	}
+}
+
+func lineWith(out, s string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, s) {
+			return line
+		}
+	}
+	return ""
+}
+
+// syncBuffer is written by a command running in another goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Command is a CLI subcommand run through Execute, e.g. "app migrate up".
+// Flags, when set, declares the command's flags on its own FlagSet.
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Flags       func(fs *flag.FlagSet)
+	Run         func(ctx *CommandContext) error
+}
+
+// CommandContext is handed to a running command.
+type CommandContext struct {
+	Args    []string
+	Flags   *flag.FlagSet
+	Out     io.Writer
+	Builder *Builder
+}
+
+var (
+	commandsMu sync.RWMutex
+	commands   = make(map[string]Command)
+)
+
+// RegisterCommand adds a command, replacing any built-in with the same name.
+func RegisterCommand(c Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	commands[c.Name] = c
+}
+
+// Execute runs the subcommand named in os.Args with a default builder.
+// Without arguments it serves HTTP.
+func Execute() error {
+	return New().Execute()
+}
+
+func (b *Builder) Execute() error {
+	return b.ExecuteArgs(os.Args[1:], os.Stdout)
+}
+
+// ExecuteArgs runs a command with explicit arguments and output.
+func (b *Builder) ExecuteArgs(args []string, out io.Writer) error {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(out)
+		return nil
+	}
+
+	commandsMu.RLock()
+	command, ok := commands[name]
+	commandsMu.RUnlock()
+	if !ok {
+		printUsage(out)
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: %s\n\n%s\n", commandUsage(command), command.Description)
+		fs.PrintDefaults()
+	}
+	if command.Flags != nil {
+		command.Flags(fs)
+	}
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	return command.Run(&CommandContext{
+		Args:    positional,
+		Flags:   fs,
+		Out:     out,
+		Builder: b,
+	})
+}
+
+// parseFlags accepts flags before and after positional arguments, so both
+// "make:controller --dir=x Post" and "make:controller Post --dir=x" work.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func commandUsage(c Command) string {
+	if c.Usage != "" {
+		return c.Name + " " + c.Usage
+	}
+	return c.Name
+}
+
+func printUsage(out io.Writer) {
+	commandsMu.RLock()
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(out, "Usage: <command> [flags] [args]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		c := commands[name]
+		fmt.Fprintf(tw, "  %s\t%s\n", commandUsage(c), strings.TrimSpace(c.Description))
+	}
+	commandsMu.RUnlock()
+	tw.Flush()
+}
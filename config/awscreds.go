@@ -0,0 +1,360 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ecsCredentialsHost   = "http://169.254.170.2"
+	imdsEndpoint         = "http://169.254.169.254"
+	awsCredentialsMargin = 5 * time.Minute
+)
+
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	// expires is zero for long-term credentials.
+	expires time.Time
+}
+
+// awsCredentialChain looks for credentials where the AWS SDKs' default
+// chain does, in the same order:
+//
+//   - AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//   - the static keys of AWS_PROFILE (or "default") in the shared
+//     credentials file, AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials
+//   - a web identity token, as set up by EKS for IAM roles for service
+//     accounts: AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN
+//   - the ECS task role, from AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or
+//     AWS_CONTAINER_CREDENTIALS_FULL_URI
+//   - the EC2 instance profile, through IMDSv2, unless
+//     AWS_EC2_METADATA_DISABLED is true
+//
+// Profiles that assume roles, SSO and credential_process are not
+// supported. Temporary credentials are kept until five minutes before
+// they expire.
+type awsCredentialChain struct {
+	client *http.Client
+
+	mu     sync.Mutex
+	cached awsCredentials
+}
+
+func newAWSCredentialChain() *awsCredentialChain {
+	return &awsCredentialChain{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *awsCredentialChain) retrieve() (awsCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Long-term credentials are read every time, so changes to the
+	// environment or the credentials file apply right away.
+	for _, source := range []func() (awsCredentials, bool, error){credentialsFromEnv, credentialsFromSharedFile} {
+		if creds, ok, err := source(); ok || err != nil {
+			return creds, err
+		}
+	}
+
+	if c.cached.accessKey != "" && time.Now().Add(awsCredentialsMargin).Before(c.cached.expires) {
+		return c.cached, nil
+	}
+	for _, source := range []func() (awsCredentials, bool, error){c.fromWebIdentity, c.fromContainer, c.fromInstanceMetadata} {
+		creds, ok, err := source()
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		if ok {
+			c.cached = creds
+			return creds, nil
+		}
+	}
+	return awsCredentials{}, errors.New("AWS credentials not configured")
+}
+
+func credentialsFromEnv() (awsCredentials, bool, error) {
+	creds := awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	return creds, creds.accessKey != "" && creds.secretKey != "", nil
+}
+
+func credentialsFromSharedFile() (awsCredentials, bool, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, false, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return awsCredentials{}, false, nil
+	}
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	defer file.Close()
+
+	var creds awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.accessKey = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.secretKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.sessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return creds, creds.accessKey != "" && creds.secretKey != "", nil
+}
+
+// fromWebIdentity exchanges the token file for role credentials with STS
+// AssumeRoleWithWebIdentity, which needs no signature.
+func (c *awsCredentialChain) fromWebIdentity() (awsCredentials, bool, error) {
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	roleARN := os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		return awsCredentials{}, false, nil
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, false, fmt.Errorf("failed to read web identity token: %w", err)
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("flugo-%d", time.Now().Unix())
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_STS")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "https://sts.amazonaws.com"
+		if region := os.Getenv("AWS_REGION"); region != "" {
+			endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+		}
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	resp, err := c.client.PostForm(strings.TrimRight(endpoint, "/")+"/", form)
+	if err != nil {
+		return awsCredentials{}, false, fmt.Errorf("sts request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(data, &failure)
+		return awsCredentials{}, false, fmt.Errorf("sts returned status %d for %s: %s %s", resp.StatusCode, roleARN, failure.Code, failure.Message)
+	}
+
+	var body struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(data, &body); err != nil {
+		return awsCredentials{}, false, fmt.Errorf("invalid sts response: %w", err)
+	}
+	creds := body.Credentials
+	return awsCredentials{
+		accessKey:    creds.AccessKeyID,
+		secretKey:    creds.SecretAccessKey,
+		sessionToken: creds.SessionToken,
+		expires:      creds.Expiration,
+	}, true, nil
+}
+
+// containerCredentials is the document served by the ECS and EC2 metadata
+// endpoints.
+type containerCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (c containerCredentials) credentials() awsCredentials {
+	return awsCredentials{
+		accessKey:    c.AccessKeyID,
+		secretKey:    c.SecretAccessKey,
+		sessionToken: c.Token,
+		expires:      c.Expiration,
+	}
+}
+
+func (c *awsCredentialChain) fromContainer() (awsCredentials, bool, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = ecsCredentialsHost + relative
+	}
+	if endpoint == "" {
+		return awsCredentials{}, false, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return awsCredentials{}, false, fmt.Errorf("failed to read container authorization token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	var creds containerCredentials
+	if err := c.getJSON(req, &creds); err != nil {
+		return awsCredentials{}, false, fmt.Errorf("container credentials: %w", err)
+	}
+	return creds.credentials(), true, nil
+}
+
+// fromInstanceMetadata reads the instance profile's credentials through
+// IMDSv2. An unreachable endpoint means the process is not on EC2, which
+// ends the chain without an error.
+func (c *awsCredentialChain) fromInstanceMetadata() (awsCredentials, bool, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("AWS_EC2_METADATA_DISABLED")); disabled {
+		return awsCredentials{}, false, nil
+	}
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = imdsEndpoint
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+
+	probe := &http.Client{Timeout: time.Second}
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	resp, err := probe.Do(req)
+	if err != nil {
+		return awsCredentials{}, false, nil
+	}
+	token, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, false, nil
+	}
+
+	get := func(path string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err == nil {
+			req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		}
+		return req, err
+	}
+
+	req, err = get("")
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	resp, err = c.client.Do(req)
+	if err != nil {
+		return awsCredentials{}, false, fmt.Errorf("instance metadata request failed: %w", err)
+	}
+	roles, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return awsCredentials{}, false, errors.New("the instance has no IAM role")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, false, fmt.Errorf("instance metadata returned status %d", resp.StatusCode)
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+
+	req, err = get(role)
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	var creds containerCredentials
+	if err := c.getJSON(req, &creds); err != nil {
+		return awsCredentials{}, false, fmt.Errorf("instance profile credentials: %w", err)
+	}
+	return creds.credentials(), true, nil
+}
+
+func (c *awsCredentialChain) getJSON(req *http.Request, v interface{}) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d from %s", resp.StatusCode, req.URL.Redacted())
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
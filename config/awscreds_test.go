@@ -0,0 +1,195 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateAWS clears every source of the credential chain.
+func isolateAWS(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME",
+		"AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_STS",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT",
+	} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func expiresIn(d time.Duration) string {
+	return time.Now().Add(d).UTC().Format(time.RFC3339)
+}
+
+func TestAWSCredentialsFromSharedFile(t *testing.T) {
+	isolateAWS(t)
+	path := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(path, []byte(`[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+; the deploy profile
+[deploy]
+aws_access_key_id=AKIDDEPLOY
+aws_secret_access_key=deploy-secret
+aws_session_token=deploy-token
+`), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	chain := newAWSCredentialChain()
+
+	if creds, err := chain.retrieve(); err != nil || creds.accessKey != "AKIDDEFAULT" || creds.secretKey != "default-secret" {
+		t.Errorf("default profile = %+v, %v", creds, err)
+	}
+
+	t.Setenv("AWS_PROFILE", "deploy")
+	if creds, err := chain.retrieve(); err != nil || creds.accessKey != "AKIDDEPLOY" || creds.sessionToken != "deploy-token" {
+		t.Errorf("deploy profile = %+v, %v", creds, err)
+	}
+
+	// The environment wins over the file.
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	if creds, err := chain.retrieve(); err != nil || creds.accessKey != "AKIDENV" {
+		t.Errorf("with environment credentials = %+v, %v", creds, err)
+	}
+}
+
+func TestAWSCredentialsFromWebIdentity(t *testing.T) {
+	isolateAWS(t)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("WebIdentityToken") != "eks-token" ||
+			r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/app" || r.Form.Get("RoleSessionName") != "app-pod" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<ErrorResponse><Error><Code>InvalidParameter</Code><Message>bad request</Message></Error></ErrorResponse>`))
+			return
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAWEBIDENTITY</AccessKeyId>
+      <SecretAccessKey>web-secret</SecretAccessKey>
+      <SessionToken>web-token</SessionToken>
+      <Expiration>` + expiresIn(time.Hour) + `</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("eks-token\n"), 0600)
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/app")
+	t.Setenv("AWS_ROLE_SESSION_NAME", "app-pod")
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+	chain := newAWSCredentialChain()
+
+	for i := 0; i < 2; i++ {
+		creds, err := chain.retrieve()
+		if err != nil || creds.accessKey != "ASIAWEBIDENTITY" || creds.secretKey != "web-secret" || creds.sessionToken != "web-token" {
+			t.Fatalf("retrieve = %+v, %v", creds, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("STS called %d times, want the credentials reused", calls)
+	}
+
+	t.Setenv("AWS_ROLE_SESSION_NAME", "other")
+	if _, err := newAWSCredentialChain().retrieve(); err == nil || !strings.Contains(err.Error(), "InvalidParameter") {
+		t.Errorf("retrieve with a refused token error = %v", err)
+	}
+}
+
+func TestAWSCredentialsFromContainer(t *testing.T) {
+	isolateAWS(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/credentials/task" || r.Header.Get("Authorization") != "ecs-auth" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"AccessKeyId": "ASIATASK", "SecretAccessKey": "task-secret", "Token": "task-token", "Expiration": "` + expiresIn(time.Hour) + `"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/v2/credentials/task")
+	tokenFile := filepath.Join(t.TempDir(), "auth")
+	os.WriteFile(tokenFile, []byte("ecs-auth"), 0600)
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", tokenFile)
+
+	creds, err := newAWSCredentialChain().retrieve()
+	if err != nil || creds.accessKey != "ASIATASK" || creds.sessionToken != "task-token" {
+		t.Errorf("retrieve = %+v, %v", creds, err)
+	}
+
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", "")
+	if _, err := newAWSCredentialChain().retrieve(); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("retrieve without the authorization token error = %v", err)
+	}
+}
+
+func TestAWSCredentialsFromInstanceMetadata(t *testing.T) {
+	isolateAWS(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("imds-token"))
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("app-instance-role"))
+		case "/latest/meta-data/iam/security-credentials/app-instance-role":
+			w.Write([]byte(`{"Code": "Success", "AccessKeyId": "ASIAINSTANCE", "SecretAccessKey": "instance-secret", "Token": "instance-token", "Expiration": "` + expiresIn(time.Hour) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+	creds, err := newAWSCredentialChain().retrieve()
+	if err != nil || creds.accessKey != "ASIAINSTANCE" || creds.sessionToken != "instance-token" {
+		t.Errorf("retrieve = %+v, %v", creds, err)
+	}
+
+	// Off EC2 the metadata endpoint does not answer.
+	server.Close()
+	if _, err := newAWSCredentialChain().retrieve(); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("retrieve without any source error = %v", err)
+	}
+}
+
+func TestAWSCredentialsRefreshedBeforeExpiry(t *testing.T) {
+	isolateAWS(t)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"AccessKeyId": "ASIATASK", "SecretAccessKey": "task-secret", "Token": "task-token", "Expiration": "` + expiresIn(2*time.Minute) + `"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL)
+
+	chain := newAWSCredentialChain()
+	chain.retrieve()
+	chain.retrieve()
+	if calls != 2 {
+		t.Errorf("credentials expiring within the margin fetched %d times, want 2", calls)
+	}
+}
@@ -3,9 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
-	"strconv"
-	"strings"
+	"reflect"
 )
 
 type Config struct {
@@ -17,206 +17,348 @@ type Config struct {
 	Logger   LoggerConfig   `json:"logger"`
 	Email    EmailConfig    `json:"email"`
 	Queue    QueueConfig    `json:"queue"`
+	I18n     I18nConfig     `json:"i18n"`
+	Trace    TraceConfig    `json:"trace"`
+	Flags    FlagsConfig    `json:"flags"`
+	Export   ExportConfig   `json:"export"`
+
+	// sources maps the path of each value set by Load to where it came
+	// from; see Diff.
+	sources map[string]provenance
 }
 
 type ServerConfig struct {
-	Port            int      `json:"port"`
-	Host            string   `json:"host"`
-	ReadTimeout     int      `json:"read_timeout"`
-	WriteTimeout    int      `json:"write_timeout"`
-	AllowedOrigins  []string `json:"allowed_origins"`
-	MaxRequestSize  int64    `json:"max_request_size"`
-	EnableSwagger   bool     `json:"enable_swagger"`
-	EnableMetrics   bool     `json:"enable_metrics"`
-	EnableProfiling bool     `json:"enable_profiling"`
+	Port            int      `json:"port" env:"SERVER_PORT"`
+	Host            string   `json:"host" env:"SERVER_HOST"`
+	ReadTimeout     int      `json:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    int      `json:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	AllowedOrigins  []string `json:"allowed_origins" env:"SERVER_ALLOWED_ORIGINS"`
+	MaxRequestSize  int64    `json:"max_request_size" env:"SERVER_MAX_REQUEST_SIZE"`
+	EnableSwagger   bool     `json:"enable_swagger" env:"SERVER_ENABLE_SWAGGER"`
+	EnableMetrics   bool     `json:"enable_metrics" env:"SERVER_ENABLE_METRICS"`
+	EnableProfiling bool     `json:"enable_profiling" env:"SERVER_ENABLE_PROFILING"`
+	ShutdownTimeout int      `json:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	// ShowBanner prints the startup report of routes, modules and jobs.
+	ShowBanner bool `json:"show_banner" env:"SERVER_SHOW_BANNER"`
+	// PrettyPrint indents JSON responses; leave it off in production.
+	PrettyPrint bool `json:"pretty_print" env:"SERVER_PRETTY_PRINT"`
+	// Debug adds error details to responses; never enable it in production.
+	Debug bool `json:"debug" env:"SERVER_DEBUG"`
+	// LogConfigDiff logs every value that differs from Default at startup,
+	// with where it was set.
+	LogConfigDiff bool `json:"log_config_diff" env:"SERVER_LOG_CONFIG_DIFF"`
+	// TrustedProxies lists the addresses or CIDRs of load balancers whose
+	// forwarding headers set the scheme, host and client address of
+	// requests; see middleware.ProxyHeaders.
+	TrustedProxies []string `json:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES"`
+	// ProxyHeaders is the header family the trusted proxies set:
+	// "x-forwarded" or "forwarded" (RFC 7239).
+	ProxyHeaders string `json:"proxy_headers" env:"SERVER_PROXY_HEADERS"`
+	// EnableAdmin mounts the admin endpoints /admin/cache/clear,
+	// /admin/config, /admin/breakers, /admin/flags and /debug/cache/hot,
+	// all restricted to the admin role.
+	EnableAdmin bool `json:"enable_admin" env:"SERVER_ENABLE_ADMIN"`
+	// EnableHealth mounts /health, /health/live and /health/ready.
+	EnableHealth bool `json:"enable_health" env:"SERVER_ENABLE_HEALTH"`
 }
 
 type DatabaseConfig struct {
-	Driver   string `json:"driver"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Database string `json:"database"`
-	SSLMode  string `json:"ssl_mode"`
-	MaxIdle  int    `json:"max_idle"`
-	MaxOpen  int    `json:"max_open"`
+	Driver   string `json:"driver" env:"DB_DRIVER"`
+	Host     string `json:"host" env:"DB_HOST"`
+	Port     int    `json:"port" env:"DB_PORT"`
+	Username string `json:"username" env:"DB_USERNAME"`
+	Password string `json:"password" env:"DB_PASSWORD" secret:"true"`
+	Database string `json:"database" env:"DB_DATABASE"`
+	SSLMode  string `json:"ssl_mode" env:"DB_SSL_MODE"`
+	MaxIdle  int    `json:"max_idle" env:"DB_MAX_IDLE"`
+	// MaxOpen of 0 picks a default for the driver: 4 for SQLite, 100
+	// otherwise.
+	MaxOpen int `json:"max_open" env:"DB_MAX_OPEN"`
+	// MaxRows caps the rows a query built without Limit returns; 0 turns
+	// the cap off. QueryBuilder.Unlimited opts a single query out.
+	MaxRows int `json:"max_rows" env:"DB_MAX_ROWS"`
+	// Pragmas override database.DefaultSQLitePragmas on SQLite; an empty
+	// value drops a default.
+	Pragmas map[string]string `json:"pragmas"`
 }
 
 type EmailConfig struct {
-	SMTPHost   string `json:"smtp_host"`
-	SMTPPort   int    `json:"smtp_port"`
-	Username   string `json:"username"`
-	Password   string `json:"password"`
-	FromEmail  string `json:"from_email"`
-	FromName   string `json:"from_name"`
-	ReplyTo    string `json:"reply_to"`
-	EnableSSL  bool   `json:"enable_ssl"`
-	EnableAuth bool   `json:"enable_auth"`
+	SMTPHost   string `json:"smtp_host" env:"EMAIL_SMTP_HOST"`
+	SMTPPort   int    `json:"smtp_port" env:"EMAIL_SMTP_PORT"`
+	Username   string `json:"username" env:"EMAIL_USERNAME"`
+	Password   string `json:"password" env:"EMAIL_PASSWORD" secret:"true"`
+	FromEmail  string `json:"from_email" env:"EMAIL_FROM_EMAIL"`
+	FromName   string `json:"from_name" env:"EMAIL_FROM_NAME"`
+	ReplyTo    string `json:"reply_to" env:"EMAIL_REPLY_TO"`
+	EnableSSL  bool   `json:"enable_ssl" env:"EMAIL_ENABLE_SSL"`
+	EnableAuth bool   `json:"enable_auth" env:"EMAIL_ENABLE_AUTH"`
 }
 
 type QueueConfig struct {
-	Workers    int  `json:"workers"`
-	BufferSize int  `json:"buffer_size"`
-	Enabled    bool `json:"enabled"`
+	Workers    int  `json:"workers" env:"QUEUE_WORKERS"`
+	BufferSize int  `json:"buffer_size" env:"QUEUE_BUFFER_SIZE"`
+	Enabled    bool `json:"enabled" env:"QUEUE_ENABLED"`
+	// WatchdogMaxDepth and WatchdogMaxAge (seconds) make the default queue
+	// log a warning while it is deeper, or its oldest pending job older,
+	// than the threshold; 0 disables a check.
+	WatchdogMaxDepth int `json:"watchdog_max_depth" env:"QUEUE_WATCHDOG_MAX_DEPTH"`
+	WatchdogMaxAge   int `json:"watchdog_max_age" env:"QUEUE_WATCHDOG_MAX_AGE"`
 }
 
 type RedisConfig struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Password string `json:"password"`
-	Database int    `json:"database"`
+	Host     string `json:"host" env:"REDIS_HOST"`
+	Port     int    `json:"port" env:"REDIS_PORT"`
+	Password string `json:"password" env:"REDIS_PASSWORD" secret:"true"`
+	Database int    `json:"database" env:"REDIS_DATABASE"`
 }
 
 type JWTConfig struct {
-	Secret         string `json:"secret"`
-	ExpirationTime int    `json:"expiration_time"`
-	RefreshTime    int    `json:"refresh_time"`
+	Secret         string `json:"secret" env:"JWT_SECRET" secret:"true"`
+	ExpirationTime int    `json:"expiration_time" env:"JWT_EXPIRATION_TIME"`
+	RefreshTime    int    `json:"refresh_time" env:"JWT_REFRESH_TIME"`
+	// Clients are the services allowed to get machine tokens from
+	// POST /oauth/token; see auth.TokenHandler.
+	Clients []ClientConfig `json:"clients" secret:"true"`
+	// EnableSessionRoutes mounts POST /auth/refresh and the /auth/sessions
+	// endpoints; see auth.RefreshHandler and auth.SessionsHandler.
+	EnableSessionRoutes bool `json:"enable_session_routes" env:"JWT_ENABLE_SESSION_ROUTES"`
+}
+
+// ClientConfig registers a service client. SecretHash is
+// auth.HashClientSecret of its secret, so config files hold no secrets.
+type ClientConfig struct {
+	ID         string   `json:"id"`
+	SecretHash string   `json:"secret_hash"`
+	Scopes     []string `json:"scopes"`
 }
 
 type UploadConfig struct {
-	MaxFileSize   int64    `json:"max_file_size"`
-	AllowedTypes  []string `json:"allowed_types"`
-	UploadPath    string   `json:"upload_path"`
-	EnableResize  bool     `json:"enable_resize"`
-	ThumbnailSize int      `json:"thumbnail_size"`
+	MaxFileSize   int64    `json:"max_file_size" env:"UPLOAD_MAX_FILE_SIZE"`
+	AllowedTypes  []string `json:"allowed_types" env:"UPLOAD_ALLOWED_TYPES"`
+	UploadPath    string   `json:"upload_path" env:"UPLOAD_PATH"`
+	EnableResize  bool     `json:"enable_resize" env:"UPLOAD_ENABLE_RESIZE"`
+	ThumbnailSize int      `json:"thumbnail_size" env:"UPLOAD_THUMBNAIL_SIZE"`
+	// Quota is the default storage per user in bytes; 0 is unlimited.
+	Quota int64 `json:"quota" env:"UPLOAD_QUOTA"`
+	// AutoOrient turns JPEG photos upright by their EXIF orientation.
+	// StripMetadata removes EXIF data such as GPS positions from JPEGs,
+	// keeping the color profile when KeepICCProfile is set.
+	AutoOrient     bool `json:"auto_orient" env:"UPLOAD_AUTO_ORIENT"`
+	StripMetadata  bool `json:"strip_metadata" env:"UPLOAD_STRIP_METADATA"`
+	KeepICCProfile bool `json:"keep_icc_profile" env:"UPLOAD_KEEP_ICC_PROFILE"`
 }
 
 type LoggerConfig struct {
-	Level      string `json:"level"`
-	Format     string `json:"format"`
-	OutputFile string `json:"output_file"`
-	MaxSize    int    `json:"max_size"`
-	MaxBackups int    `json:"max_backups"`
-	MaxAge     int    `json:"max_age"`
+	Level      string `json:"level" env:"LOG_LEVEL"`
+	Format     string `json:"format" env:"LOG_FORMAT"`
+	OutputFile string `json:"output_file" env:"LOG_OUTPUT_FILE"`
+	MaxSize    int    `json:"max_size" env:"LOG_MAX_SIZE"`
+	MaxBackups int    `json:"max_backups" env:"LOG_MAX_BACKUPS"`
+	MaxAge     int    `json:"max_age" env:"LOG_MAX_AGE"`
+}
+
+// I18nConfig points at a directory of <locale>.json or <locale>.yaml
+// catalogs. ReportMissing logs untranslated keys, which is meant for
+// development.
+type I18nConfig struct {
+	Directory     string `json:"directory" env:"I18N_DIRECTORY"`
+	DefaultLocale string `json:"default_locale" env:"I18N_DEFAULT_LOCALE"`
+	ReportMissing bool   `json:"report_missing" env:"I18N_REPORT_MISSING"`
+}
+
+// TraceConfig picks where spans go: "log" writes them to the logger and
+// "otlp" sends them to Endpoint, an OTLP/HTTP collector URL such as
+// http://localhost:4318/v1/traces. Any other value drops them.
+type TraceConfig struct {
+	Exporter    string `json:"exporter" env:"TRACE_EXPORTER"`
+	Endpoint    string `json:"endpoint" env:"TRACE_ENDPOINT"`
+	ServiceName string `json:"service_name" env:"TRACE_SERVICE_NAME"`
+}
+
+// ExportConfig configures the files written by export.Run.
+type ExportConfig struct {
+	// Enabled mounts GET /exports/download, which serves the signed links
+	// of export.Run.
+	Enabled bool `json:"enabled" env:"EXPORT_ENABLED"`
+	// BaseURL is the public URL of the API, such as
+	// "https://api.example.com", that emailed download links start with.
+	BaseURL string `json:"base_url" env:"EXPORT_BASE_URL"`
+	// SigningKey signs download links. Without it a key is derived from
+	// the JWT secret, so rotating that secret also ends open links.
+	SigningKey string `json:"signing_key" env:"EXPORT_SIGNING_KEY" secret:"true"`
+}
+
+// FlagsConfig defines feature flags; see featureflags. With UseDatabase,
+// flags are also kept in the feature_flags table, whose rows win over the
+// definitions here and are re-read every CacheTTL seconds.
+type FlagsConfig struct {
+	UseDatabase bool         `json:"use_database" env:"FLAGS_USE_DATABASE"`
+	CacheTTL    int          `json:"cache_ttl" env:"FLAGS_CACHE_TTL"`
+	Definitions []FlagConfig `json:"definitions"`
+}
+
+// FlagConfig is a feature flag. Type is "boolean", "percentage" or
+// "allowlist".
+type FlagConfig struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Enabled     bool     `json:"enabled"`
+	Percentage  int      `json:"percentage"`
+	Users       []string `json:"users"`
+	Roles       []string `json:"roles"`
 }
 
 var AppConfig *Config
 
-func Load() *Config {
-	config := &Config{
+// Default returns the configuration used when neither the environment nor
+// a config file sets a value.
+func Default() *Config {
+	return &Config{
 		Server: ServerConfig{
-			Port:            getEnvInt("SERVER_PORT", 8080),
-			Host:            getEnvString("SERVER_HOST", "0.0.0.0"),
-			ReadTimeout:     getEnvInt("SERVER_READ_TIMEOUT", 30),
-			WriteTimeout:    getEnvInt("SERVER_WRITE_TIMEOUT", 30),
-			AllowedOrigins:  getEnvStringSlice("SERVER_ALLOWED_ORIGINS", []string{"*"}),
-			MaxRequestSize:  getEnvInt64("SERVER_MAX_REQUEST_SIZE", 10*1024*1024),
-			EnableSwagger:   getEnvBool("SERVER_ENABLE_SWAGGER", true),
-			EnableMetrics:   getEnvBool("SERVER_ENABLE_METRICS", true),
-			EnableProfiling: getEnvBool("SERVER_ENABLE_PROFILING", false),
+			Port:            8080,
+			Host:            "0.0.0.0",
+			ReadTimeout:     30,
+			WriteTimeout:    30,
+			AllowedOrigins:  []string{"*"},
+			MaxRequestSize:  10 * 1024 * 1024,
+			EnableSwagger:   true,
+			EnableMetrics:   true,
+			EnableProfiling: false,
+			ShutdownTimeout: 30,
+			ShowBanner:      true,
+			PrettyPrint:     false,
+			Debug:           false,
+			LogConfigDiff:   false,
+			TrustedProxies:  []string{},
+			ProxyHeaders:    "x-forwarded",
+			EnableAdmin:     false,
+			EnableHealth:    false,
 		},
 		Database: DatabaseConfig{
-			Driver:   getEnvString("DB_DRIVER", "sqlite3"),
-			Host:     getEnvString("DB_HOST", ""),
-			Port:     getEnvInt("DB_PORT", 0),
-			Username: getEnvString("DB_USERNAME", ""),
-			Password: getEnvString("DB_PASSWORD", ""),
-			Database: getEnvString("DB_DATABASE", "storage/database.db"),
-			SSLMode:  getEnvString("DB_SSL_MODE", ""),
-			MaxIdle:  getEnvInt("DB_MAX_IDLE", 10),
-			MaxOpen:  getEnvInt("DB_MAX_OPEN", 100),
+			Driver:   "sqlite3",
+			Host:     "",
+			Port:     0,
+			Username: "",
+			Password: "",
+			Database: "storage/database.db",
+			SSLMode:  "",
+			MaxIdle:  10,
+			MaxOpen:  0,
+			MaxRows:  10000,
 		},
 		Redis: RedisConfig{
-			Host:     getEnvString("REDIS_HOST", "localhost"),
-			Port:     getEnvInt("REDIS_PORT", 6379),
-			Password: getEnvString("REDIS_PASSWORD", ""),
-			Database: getEnvInt("REDIS_DATABASE", 0),
+			Host:     "localhost",
+			Port:     6379,
+			Password: "",
+			Database: 0,
 		},
 		JWT: JWTConfig{
-			Secret:         getEnvString("JWT_SECRET", "flugo-secret-key"),
-			ExpirationTime: getEnvInt("JWT_EXPIRATION_TIME", 3600),
-			RefreshTime:    getEnvInt("JWT_REFRESH_TIME", 86400),
+			Secret:              "flugo-secret-key",
+			ExpirationTime:      3600,
+			RefreshTime:         86400,
+			EnableSessionRoutes: false,
 		},
 		Upload: UploadConfig{
-			MaxFileSize:   getEnvInt64("UPLOAD_MAX_FILE_SIZE", 10*1024*1024),
-			AllowedTypes:  getEnvStringSlice("UPLOAD_ALLOWED_TYPES", []string{"image/jpeg", "image/png", "image/gif"}),
-			UploadPath:    getEnvString("UPLOAD_PATH", "./uploads"),
-			EnableResize:  getEnvBool("UPLOAD_ENABLE_RESIZE", true),
-			ThumbnailSize: getEnvInt("UPLOAD_THUMBNAIL_SIZE", 200),
+			MaxFileSize:    10 * 1024 * 1024,
+			AllowedTypes:   []string{"image/jpeg", "image/png", "image/gif"},
+			UploadPath:     "./uploads",
+			EnableResize:   true,
+			ThumbnailSize:  200,
+			Quota:          0,
+			AutoOrient:     false,
+			StripMetadata:  false,
+			KeepICCProfile: false,
 		},
 		Logger: LoggerConfig{
-			Level:      getEnvString("LOG_LEVEL", "info"),
-			Format:     getEnvString("LOG_FORMAT", "json"),
-			OutputFile: getEnvString("LOG_OUTPUT_FILE", ""),
-			MaxSize:    getEnvInt("LOG_MAX_SIZE", 100),
-			MaxBackups: getEnvInt("LOG_MAX_BACKUPS", 3),
-			MaxAge:     getEnvInt("LOG_MAX_AGE", 28),
+			Level:      "info",
+			Format:     "json",
+			OutputFile: "",
+			MaxSize:    100,
+			MaxBackups: 3,
+			MaxAge:     28,
 		},
 		Email: EmailConfig{
-			SMTPHost:   getEnvString("EMAIL_SMTP_HOST", "localhost"),
-			SMTPPort:   getEnvInt("EMAIL_SMTP_PORT", 587),
-			Username:   getEnvString("EMAIL_USERNAME", ""),
-			Password:   getEnvString("EMAIL_PASSWORD", ""),
-			FromEmail:  getEnvString("EMAIL_FROM_EMAIL", "noreply@example.com"),
-			FromName:   getEnvString("EMAIL_FROM_NAME", "Flugo Framework"),
-			ReplyTo:    getEnvString("EMAIL_REPLY_TO", ""),
-			EnableSSL:  getEnvBool("EMAIL_ENABLE_SSL", true),
-			EnableAuth: getEnvBool("EMAIL_ENABLE_AUTH", true),
+			SMTPHost:   "localhost",
+			SMTPPort:   587,
+			Username:   "",
+			Password:   "",
+			FromEmail:  "noreply@example.com",
+			FromName:   "Flugo Framework",
+			ReplyTo:    "",
+			EnableSSL:  true,
+			EnableAuth: true,
 		},
 		Queue: QueueConfig{
-			Workers:    getEnvInt("QUEUE_WORKERS", 5),
-			BufferSize: getEnvInt("QUEUE_BUFFER_SIZE", 1000),
-			Enabled:    getEnvBool("QUEUE_ENABLED", true),
+			Workers:          5,
+			BufferSize:       1000,
+			Enabled:          true,
+			WatchdogMaxDepth: 0,
+			WatchdogMaxAge:   0,
+		},
+		I18n: I18nConfig{
+			Directory:     "",
+			DefaultLocale: "en",
+			ReportMissing: false,
+		},
+		Trace: TraceConfig{
+			Exporter:    "",
+			Endpoint:    "http://localhost:4318/v1/traces",
+			ServiceName: "flugo",
 		},
+		Flags: FlagsConfig{
+			UseDatabase: false,
+			CacheTTL:    10,
+		},
+		Export: ExportConfig{
+			Enabled:    false,
+			BaseURL:    "",
+			SigningKey: "",
+		},
+	}
+
+}
+
+// Load starts from Default, applies the environment variables named by
+// the env tags and then CONFIG_FILE, which wins over both. It records
+// where each value came from for Diff. It fails if a secret:// reference
+// cannot be resolved rather than running with the default value.
+func Load() (*Config, error) {
+	config := Default()
+	config.sources = make(map[string]provenance)
+	if err := applyEnv(reflect.ValueOf(config).Elem(), "", config.sources); err != nil {
+		return nil, err
 	}
 
 	if configFile := getEnvString("CONFIG_FILE", ""); configFile != "" {
-		loadFromFile(config, configFile)
+		if err := loadFromFile(config, configFile); err != nil {
+			log.Printf("config: failed to load %s: %v", configFile, err)
+		}
 	}
 
 	AppConfig = config
-	return config
+	return config, nil
 }
 
 func loadFromFile(config *Config, filename string) error {
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	return decoder.Decode(config)
-}
-
-func getEnvString(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-	}
-	return defaultValue
-}
-
-func getEnvInt64(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
-			return intValue
-		}
+	if err := json.Unmarshal(data, config); err != nil {
+		return err
 	}
-	return defaultValue
-}
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
-		}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err == nil && config.sources != nil {
+		recordFile(reflect.TypeOf(*config), keys, "", "file:"+filename, config.sources)
 	}
-	return defaultValue
+	return nil
 }
 
-func getEnvStringSlice(key string, defaultValue []string) []string {
+func getEnvString(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
-		return strings.Split(value, ",")
+		return value
 	}
 	return defaultValue
 }
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Sources reported by Diff besides "env:NAME" and "file:PATH".
+const (
+	SourceDefault = "default"
+	// SourceRuntime marks values changed in code after Load.
+	SourceRuntime = "runtime"
+)
+
+const redacted = "[REDACTED]"
+
+// Override is a configuration value that was set explicitly or differs
+// from its default. Path is the dotted JSON path, such as "server.port".
+type Override struct {
+	Path    string      `json:"path"`
+	Default interface{} `json:"default"`
+	Value   interface{} `json:"value"`
+	Source  string      `json:"source"`
+}
+
+type provenance struct {
+	source string
+	// secret is set for values resolved from a secret:// reference.
+	secret bool
+}
+
+// Diff lists the values of AppConfig set by the environment or a config
+// file, or that otherwise differ from Default.
+func Diff() []Override {
+	if AppConfig == nil {
+		return nil
+	}
+	return AppConfig.Diff()
+}
+
+// Diff lists the values of c set by the environment or a config file, or
+// that otherwise differ from Default, in field order. Fields tagged
+// secret, and values resolved from secret:// references, are redacted.
+func (c *Config) Diff() []Override {
+	var overrides []Override
+	diffStruct(reflect.ValueOf(*Default()), reflect.ValueOf(*c), "", c.sources, &overrides)
+	return overrides
+}
+
+func diffStruct(def, cur reflect.Value, prefix string, sources map[string]provenance, out *[]Override) {
+	t := cur.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		path := prefix + name
+
+		if field.Type.Kind() == reflect.Struct {
+			diffStruct(def.Field(i), cur.Field(i), path+".", sources, out)
+			continue
+		}
+
+		prov, set := sources[path]
+		changed := !reflect.DeepEqual(def.Field(i).Interface(), cur.Field(i).Interface())
+		if !set && !changed {
+			continue
+		}
+		if !set {
+			prov.source = SourceRuntime
+		}
+
+		o := Override{Path: path, Default: def.Field(i).Interface(), Value: cur.Field(i).Interface(), Source: prov.source}
+		if field.Tag.Get("secret") == "true" {
+			o.Default, o.Value = redacted, redacted
+		} else if prov.secret {
+			o.Value = redacted
+		}
+		*out = append(*out, o)
+	}
+}
+
+// applyEnv sets the fields of v that have an env tag and a non-empty
+// variable. Values that do not parse keep their default, as before, but
+// secret:// references that cannot be resolved are returned as errors.
+func applyEnv(v reflect.Value, prefix string, sources map[string]provenance) error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			errs = append(errs, applyEnv(v.Field(i), prefix+name+".", sources))
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		raw := os.Getenv(key)
+		if key == "" || raw == "" {
+			continue
+		}
+
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			value, err := resolveEnvSecret(key, raw)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			f.SetString(value)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				continue
+			}
+			f.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				continue
+			}
+			f.SetBool(b)
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			f.Set(reflect.ValueOf(strings.Split(raw, ",")))
+		default:
+			continue
+		}
+		sources[prefix+name] = provenance{source: "env:" + key, secret: strings.HasPrefix(raw, secretPrefix)}
+	}
+	return errors.Join(errs...)
+}
+
+// recordFile marks the fields present in a config file's JSON object,
+// matching keys case-insensitively as encoding/json does.
+func recordFile(t reflect.Type, keys map[string]json.RawMessage, prefix, source string, sources map[string]provenance) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+
+		for key, raw := range keys {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				var nested map[string]json.RawMessage
+				if json.Unmarshal(raw, &nested) == nil {
+					recordFile(field.Type, nested, prefix+name+".", source, sources)
+				}
+			} else {
+				sources[prefix+name] = provenance{source: source}
+			}
+			break
+		}
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,61 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flugo.com/config"
+)
+
+func TestDiffReportsSources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"server": {"host": "127.0.0.1"}, "logger": {"Level": "debug"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("SERVER_PORT", "9090")
+	t.Setenv("SERVER_READ_TIMEOUT", "soon")
+	t.Setenv("QUEUE_WORKERS", "5")
+	t.Setenv("JWT_SECRET", "production-secret")
+	t.Setenv("SERVER_HOST", "10.0.0.1")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Redis.Port = 6380
+
+	got := make(map[string]config.Override)
+	for _, o := range cfg.Diff() {
+		got[o.Path] = o
+	}
+
+	for path, want := range map[string]config.Override{
+		"server.port":   {Default: 8080, Value: 9090, Source: "env:SERVER_PORT"},
+		"server.host":   {Default: "0.0.0.0", Value: "127.0.0.1", Source: "file:" + file},
+		"logger.level":  {Default: "info", Value: "debug", Source: "file:" + file},
+		"queue.workers": {Default: 5, Value: 5, Source: "env:QUEUE_WORKERS"},
+		"jwt.secret":    {Default: "[REDACTED]", Value: "[REDACTED]", Source: "env:JWT_SECRET"},
+		"redis.port":    {Default: 6379, Value: 6380, Source: config.SourceRuntime},
+	} {
+		o, ok := got[path]
+		if !ok {
+			t.Errorf("%s missing from diff", path)
+			continue
+		}
+		if o.Default != want.Default || o.Value != want.Value || o.Source != want.Source {
+			t.Errorf("%s = %+v, want %+v", path, o, want)
+		}
+	}
+
+	if o, ok := got["server.read_timeout"]; ok {
+		t.Errorf("unparsable env value reported: %+v", o)
+	}
+}
+
+func TestDiffOfDefaultsIsEmpty(t *testing.T) {
+	if diff := config.Default().Diff(); len(diff) != 0 {
+		t.Errorf("diff of defaults = %v", diff)
+	}
+}
@@ -0,0 +1,350 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	secretPrefix   = "secret://"
+	secretCacheTTL = 5 * time.Minute
+)
+
+// SecretsBackend resolves references such as secret://vault/kv/data/app#password
+// or secret://ssm/app/db_password. GetSecret receives the part after the
+// backend name.
+//
+// The Vault and SSM backends call the HTTP APIs directly instead of going
+// through the Vault and AWS SDKs: each needs one read call, and the SDKs
+// would add dozens of modules to a framework whose only dependency is the
+// SQLite driver. The SSM backend finds credentials where the SDKs' default
+// chain does, except for role-assuming profiles, SSO and
+// credential_process; see awsCredentialChain.
+type SecretsBackend interface {
+	GetSecret(key string) (string, error)
+}
+
+type cachedSecret struct {
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	secretsMu       sync.RWMutex
+	secretsBackends = make(map[string]SecretsBackend)
+	secretCache     = make(map[string]cachedSecret)
+)
+
+// SetSecretsBackend registers a backend for the references it understands.
+// Backends created by NewVaultBackend and NewSSMBackend only handle their own
+// scheme; any other backend handles every reference without a dedicated one.
+func SetSecretsBackend(backend SecretsBackend) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	kind := ""
+	if named, ok := backend.(interface{ kind() string }); ok {
+		kind = named.kind()
+	}
+	secretsBackends[kind] = backend
+	secretCache = make(map[string]cachedSecret)
+}
+
+func resolveSecret(ref string) (string, error) {
+	secretsMu.RLock()
+	cached, ok := secretCache[ref]
+	secretsMu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+
+	kind, key, found := strings.Cut(strings.TrimPrefix(ref, secretPrefix), "/")
+	if !found || key == "" {
+		return "", fmt.Errorf("invalid secret reference %q", ref)
+	}
+
+	secretsMu.RLock()
+	backend := secretsBackends[kind]
+	if backend == nil {
+		backend = secretsBackends[""]
+	}
+	secretsMu.RUnlock()
+
+	if backend == nil {
+		return "", fmt.Errorf("no secrets backend configured for %q", kind)
+	}
+
+	value, err := backend.GetSecret(key)
+	if err != nil {
+		return "", err
+	}
+
+	secretsMu.Lock()
+	secretCache[ref] = cachedSecret{value: value, expiresAt: time.Now().Add(secretCacheTTL)}
+	secretsMu.Unlock()
+
+	return value, nil
+}
+
+// resolveEnvSecret returns the value of the environment variable key,
+// resolving it first if it is a secret:// reference.
+func resolveEnvSecret(key, value string) (string, error) {
+	if !strings.HasPrefix(value, secretPrefix) {
+		return value, nil
+	}
+
+	secret, err := resolveSecret(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret for %s: %w", key, err)
+	}
+	return secret, nil
+}
+
+type vaultBackend struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// NewVaultBackend reads secrets from Vault over its HTTP API. Keys take the
+// form path#field; field defaults to "value" or the only field present.
+// Both KV v1 and v2 mounts are supported.
+func NewVaultBackend(addr, token string) SecretsBackend {
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	return &vaultBackend{
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *vaultBackend) kind() string {
+	return "vault"
+}
+
+func (v *vaultBackend) GetSecret(key string) (string, error) {
+	path, field, _ := strings.Cut(key, "#")
+
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d for %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, isV2 := data["metadata"]; isV2 {
+			data = nested
+		}
+	}
+
+	if field == "" {
+		field = "value"
+		if len(data) == 1 {
+			for name := range data {
+				field = name
+			}
+		}
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found in vault secret %s", field, path)
+	}
+	return fmt.Sprint(value), nil
+}
+
+type ssmBackend struct {
+	region      string
+	endpoint    string
+	client      *http.Client
+	credentials *awsCredentialChain
+}
+
+// NewSSMBackend reads SecureString parameters from AWS Systems Manager
+// Parameter Store. Credentials come from the environment, the shared
+// credentials file, a web identity token (EKS), the ECS task role or the
+// EC2 instance profile, in the order of the AWS SDKs' default chain. As
+// with the SDKs, AWS_ENDPOINT_URL_SSM or AWS_ENDPOINT_URL replaces the
+// regional endpoint.
+func NewSSMBackend(region string) SecretsBackend {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SSM")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://ssm.%s.amazonaws.com", region)
+	}
+
+	return &ssmBackend{
+		region:      region,
+		endpoint:    strings.TrimRight(endpoint, "/") + "/",
+		client:      &http.Client{Timeout: 10 * time.Second},
+		credentials: newAWSCredentialChain(),
+	}
+}
+
+func (s *ssmBackend) kind() string {
+	return "ssm"
+}
+
+func (s *ssmBackend) GetSecret(name string) (string, error) {
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"Name":           name,
+		"WithDecryption": true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	creds, err := s.credentials.retrieve()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	signV4(req, payload, "ssm", s.region, creds, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ssm request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &failure)
+		return "", fmt.Errorf("ssm returned status %d for %s: %s %s", resp.StatusCode, name, failure.Type, failure.Message)
+	}
+
+	var body struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", fmt.Errorf("invalid ssm response: %w", err)
+	}
+
+	return body.Parameter.Value, nil
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req,
+// signing every header it carries. Query strings are not supported.
+func signV4(req *http.Request, payload []byte, service, region string, creds awsCredentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signature := hex.EncodeToString(hmacSHA256(signingKey(creds.secretKey, date, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func signingKey(secretKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type backendFunc func(key string) (string, error)
+
+func (f backendFunc) GetSecret(key string) (string, error) {
+	return f(key)
+}
+
+func resetSecrets(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		secretsMu.Lock()
+		secretsBackends = make(map[string]SecretsBackend)
+		secretCache = make(map[string]cachedSecret)
+		secretsMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// The signing key example from the AWS Signature Version 4 documentation.
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signingKey = %s, want %s", got, want)
+	}
+}
+
+// Cases from the AWS Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	creds := awsCredentials{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		method    string
+		signature string
+	}{
+		{"get-vanilla", http.MethodGet, "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", http.MethodPost, "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "https://example.amazonaws.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signV4(req, nil, "service", "us-east-1", creds, now)
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q, want %q", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+		})
+	}
+}
+
+func TestSignV4SessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := awsCredentials{accessKey: "AKIDEXAMPLE", secretKey: "secret", sessionToken: "token"}
+	signV4(req, nil, "service", "us-east-1", creds, time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("session token not signed: %s", auth)
+	}
+}
+
+func TestVaultBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/app":
+			w.Write([]byte(`{"data": {"data": {"password": "v2-password", "user": "app"}, "metadata": {"version": 3}}}`))
+		case "/v1/secret/app":
+			w.Write([]byte(`{"data": {"value": "v1-value", "user": "app"}}`))
+		case "/v1/secret/single":
+			w.Write([]byte(`{"data": {"api_key": "only-field"}}`))
+		case "/v1/secret/nested":
+			w.Write([]byte(`{"data": {"data": {"inner": "x"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend := NewVaultBackend(server.URL+"/", "root")
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr string
+	}{
+		{key: "kv/data/app#password", want: "v2-password"},
+		{key: "/kv/data/app#user", want: "app"},
+		{key: "secret/app", want: "v1-value"},
+		{key: "secret/app#user", want: "app"},
+		{key: "secret/single", want: "only-field"},
+		// Without metadata a "data" field is an ordinary KV v1 field.
+		{key: "secret/nested#data", want: "map[inner:x]"},
+		{key: "kv/data/app", wantErr: `field "value" not found`},
+		{key: "secret/app#missing", wantErr: `field "missing" not found`},
+		{key: "secret/unknown", wantErr: "status 404"},
+	}
+
+	for _, tt := range tests {
+		got, err := backend.GetSecret(tt.key)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GetSecret(%q) error = %v, want %q", tt.key, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("GetSecret(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := NewVaultBackend("", "").GetSecret("secret/app"); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("GetSecret with a bad token error = %v", err)
+	}
+}
+
+func TestSSMBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Method != http.MethodPost || r.Header.Get("X-Amz-Target") != "AmazonSSM.GetParameter" ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			!strings.Contains(auth, "/eu-west-1/ssm/aws4_request,") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Name           string
+			WithDecryption bool
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !body.WithDecryption {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body.Name != "/app/db_password" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ParameterNotFound", "message": "not found"}`))
+			return
+		}
+		w.Write([]byte(`{"Parameter": {"Name": "/app/db_password", "Value": "ssm-password"}}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL_SSM", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	backend := NewSSMBackend("eu-west-1")
+
+	if got, err := backend.GetSecret("app/db_password"); err != nil || got != "ssm-password" {
+		t.Errorf("GetSecret = %q, %v", got, err)
+	}
+	if _, err := backend.GetSecret("app/other"); err == nil || !strings.Contains(err.Error(), "ParameterNotFound") {
+		t.Errorf("GetSecret of a missing parameter error = %v", err)
+	}
+
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := backend.GetSecret("app/db_password"); err == nil || !strings.Contains(err.Error(), "credentials") {
+		t.Errorf("GetSecret without credentials error = %v", err)
+	}
+}
+
+func TestLoadResolvesSecrets(t *testing.T) {
+	resetSecrets(t)
+	SetSecretsBackend(backendFunc(func(key string) (string, error) {
+		return "resolved:" + key, nil
+	}))
+	t.Setenv("DB_PASSWORD", "secret://vault/kv/data/app#password")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "resolved:kv/data/app#password" {
+		t.Errorf("Database.Password = %q", cfg.Database.Password)
+	}
+}
+
+func TestLoadFailsOnUnresolvableSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend SecretsBackend
+		value   string
+		wantErr string
+	}{
+		{name: "no backend", value: "secret://vault/kv/data/app#password", wantErr: `no secrets backend configured for "vault"`},
+		{name: "invalid reference", value: "secret://vault", wantErr: "invalid secret reference"},
+		{
+			name: "backend error",
+			backend: backendFunc(func(string) (string, error) {
+				return "", errors.New("permission denied")
+			}),
+			value:   "secret://vault/kv/data/app#password",
+			wantErr: "permission denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetSecrets(t)
+			if tt.backend != nil {
+				SetSecretsBackend(tt.backend)
+			}
+			t.Setenv("DB_PASSWORD", tt.value)
+			t.Setenv("REDIS_PASSWORD", tt.value)
+
+			cfg, err := Load()
+			if err == nil {
+				t.Fatalf("Load() = %+v, want an error", cfg.Database)
+			}
+			for _, want := range []string{"DB_PASSWORD", "REDIS_PASSWORD", tt.wantErr} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
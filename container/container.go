@@ -8,6 +8,7 @@ import (
 type Container struct {
 	providers map[string]interface{}
 	instances map[string]interface{}
+	order     []string
 }
 
 func NewContainer() *Container {
@@ -23,6 +24,9 @@ func (c *Container) Register(provider interface{}) {
 		t = t.Elem()
 	}
 	name := t.String()
+	if _, exists := c.providers[name]; !exists {
+		c.order = append(c.order, name)
+	}
 	c.providers[name] = provider
 }
 
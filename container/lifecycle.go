@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Starter is implemented by providers that need to open connections or start
+// background work once the application boots.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by providers that hold resources to release on
+// shutdown.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// StartAll starts providers in registration order and stops at the first
+// failure.
+func (c *Container) StartAll(ctx context.Context) error {
+	for _, name := range c.order {
+		if starter, ok := c.providers[name].(Starter); ok {
+			if err := starter.Start(ctx); err != nil {
+				return fmt.Errorf("failed to start %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// StopAll stops providers in reverse registration order, continuing past
+// failures and returning them joined.
+func (c *Container) StopAll(ctx context.Context) error {
+	var errs []error
+	for i := len(c.order) - 1; i >= 0; i-- {
+		name := c.order[i]
+		if stopper, ok := c.providers[name].(Stopper); ok {
+			if err := stopper.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
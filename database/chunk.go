@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Chunk runs the query size rows at a time and calls fn for every row, so
+// exports and batch jobs read any number of rows with bounded memory. A
+// Limit caps the rows read in total and an Offset skips rows first. Pages
+// are read by offset, so order the query by a unique column, or rows
+// written meanwhile can be skipped or seen twice.
+func (qb *QueryBuilder) Chunk(size int, fn func(rows *sql.Rows) error) error {
+	if size <= 0 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+
+	limit, offset := qb.limitCount, qb.offsetCount
+	defer func() { qb.limitCount, qb.offsetCount = limit, offset }()
+
+	read := 0
+	for {
+		page := size
+		if limit > 0 && limit-read < page {
+			page = limit - read
+		}
+		if page <= 0 {
+			return nil
+		}
+		if err := qb.context().Err(); err != nil {
+			return err
+		}
+
+		qb.limitCount, qb.offsetCount = page, offset+read
+		rows, err := qb.Get()
+		if err != nil {
+			return err
+		}
+		n, err := chunkRows(rows, fn)
+		if err != nil {
+			return err
+		}
+		read += n
+		if n < page {
+			return nil
+		}
+	}
+}
+
+func chunkRows(rows *sql.Rows, fn func(rows *sql.Rows) error) (int, error) {
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+		if err := fn(rows); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
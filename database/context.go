@@ -0,0 +1,19 @@
+package database
+
+import "context"
+
+type dbKey struct{}
+
+// ContextWithDB makes FromContext, and the package-level helpers taking a
+// context, use db instead of DefaultDB.
+func ContextWithDB(ctx context.Context, db *DB) context.Context {
+	return context.WithValue(ctx, dbKey{}, db)
+}
+
+// FromContext returns the database attached by ContextWithDB, or DefaultDB.
+func FromContext(ctx context.Context) *DB {
+	if db, ok := ctx.Value(dbKey{}).(*DB); ok && db != nil {
+		return db
+	}
+	return DefaultDB
+}
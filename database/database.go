@@ -1,20 +1,33 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"flugo.com/config"
 	"flugo.com/logger"
-	_ "github.com/mattn/go-sqlite3"
+	"flugo.com/reqctx"
+	"flugo.com/utils"
 )
 
+// DB opens its connection on first use when created by NewLazyDB; every
+// access goes through getConn so both modes share one code path.
 type DB struct {
-	conn   *sql.DB
 	config *config.DatabaseConfig
+	conn   atomic.Pointer[sql.DB]
+
+	mu      sync.Mutex
+	connErr error
+	retryAt time.Time
+	closed  bool
 }
 
 type QueryBuilder struct {
@@ -26,11 +39,66 @@ type QueryBuilder struct {
 	orderBy     string
 	limitCount  int
 	offsetCount int
+	unlimited   bool
 	joins       []string
+	err         error
+	ctx         context.Context
+	tx          *sql.Tx
+	lock        string
+
+	forTenant     bool
+	tenant        reqctx.TenantID
+	tenantApplied bool
+}
+
+// Row wraps *sql.Row so errors raised while building the query surface
+// from Scan.
+type Row struct {
+	row *sql.Row
+	err error
+}
+
+func (r *Row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.row.Scan(dest...)
+}
+
+func (r *Row) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.row.Err()
 }
 
 var DefaultDB *DB
 
+// ErrNotInitialized is returned by the package-level helpers, and by any
+// query, when DefaultDB was never set by Init or InitLazy.
+var ErrNotInitialized = errors.New("database not initialized: call database.Init or database.InitLazy")
+
+// ErrNotFound is sql.ErrNoRows under the name handlers return it by:
+// errors.Is matches either, and response.WriteError answers it with a 404.
+var ErrNotFound = sql.ErrNoRows
+
+var errClosed = errors.New("database is closed")
+
+// ConnectRetryPolicy bounds the connection attempts made by each connect:
+// the first query of a lazy DB, NewDB, or the first query after
+// reconnectCooldown once connecting has failed.
+var ConnectRetryPolicy = utils.RetryPolicy{
+	MaxAttempts:  4,
+	InitialDelay: 250 * time.Millisecond,
+	MaxDelay:     2 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// reconnectCooldown is how long a failed connect is reported to every
+// caller before connecting is tried again.
+const reconnectCooldown = 5 * time.Second
+
 func Init(cfg *config.DatabaseConfig) {
 	var err error
 	DefaultDB, err = NewDB(cfg)
@@ -39,15 +107,120 @@ func Init(cfg *config.DatabaseConfig) {
 	}
 }
 
+// InitLazy sets DefaultDB without connecting; the connection is opened by
+// the first query. Packages can then be imported and tested without a
+// running database.
+func InitLazy(cfg *config.DatabaseConfig) {
+	DefaultDB = NewLazyDB(cfg)
+}
+
 func NewDB(cfg *config.DatabaseConfig) (*DB, error) {
-	var dsn string
+	db := NewLazyDB(cfg)
+	if _, err := db.getConn(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func NewLazyDB(cfg *config.DatabaseConfig) *DB {
+	return &DB{config: cfg}
+}
+
+func (db *DB) getConn() (*sql.DB, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+	if conn := db.conn.Load(); conn != nil {
+		return conn, nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if conn := db.conn.Load(); conn != nil {
+		return conn, nil
+	}
+	if db.closed {
+		return nil, errClosed
+	}
+	if db.connErr != nil && time.Now().Before(db.retryAt) {
+		return nil, db.connErr
+	}
+
+	conn, err := openConn(db.config)
+	if err == nil {
+		policy := ConnectRetryPolicy
+		policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+			logger.Warn("Database connection attempt %d failed, retrying in %v: %v", attempt, delay, err)
+		}
+		err = utils.Retry(context.Background(), policy, conn.Ping)
+		if err != nil {
+			conn.Close()
+			err = fmt.Errorf("failed to ping database: %w", err)
+		}
+	}
+	if err != nil {
+		db.connErr = err
+		db.retryAt = time.Now().Add(reconnectCooldown)
+		return nil, err
+	}
+
+	db.connErr = nil
+	db.conn.Store(conn)
+	logger.Info("Database connected successfully: %s", db.config.Driver)
+	return conn, nil
+}
+
+// Driver returns the configured driver name, with "sqlite" reported as
+// "sqlite3".
+func (db *DB) Driver() string {
+	if db == nil || db.config == nil {
+		return ""
+	}
+	if db.config.Driver == "sqlite" {
+		return "sqlite3"
+	}
+	return db.config.Driver
+}
+
+// maxRows is the LIMIT Get applies when the query sets none; 0 leaves
+// such queries unbounded.
+func (db *DB) maxRows() int {
+	if db == nil || db.config == nil || db.config.MaxRows < 0 {
+		return 0
+	}
+	return db.config.MaxRows
+}
+
+// IsReady reports whether db has an open connection. Unlike Ping it never
+// connects, so it is cheap enough for readiness probes; a lazy DB is not
+// ready until its first query.
+func (db *DB) IsReady() bool {
+	return db != nil && db.conn.Load() != nil
+}
 
+// IsReady reports whether DefaultDB is set and connected.
+func IsReady() bool {
+	return DefaultDB.IsReady()
+}
+
+func openConn(cfg *config.DatabaseConfig) (*sql.DB, error) {
+	var (
+		conn *sql.DB
+		dsn  string
+		err  error
+	)
+
+	maxOpen := cfg.MaxOpen
 	switch cfg.Driver {
 	case "sqlite3", "sqlite":
 		if cfg.Database == "" {
 			cfg.Database = "storage/database.db"
 		}
-		dsn = cfg.Database
+		if maxOpen <= 0 {
+			maxOpen = defaultSQLiteMaxOpen
+		}
+		conn, err = openSQLite(cfg)
 	case "mysql":
 		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 			cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
@@ -58,117 +231,37 @@ func NewDB(cfg *config.DatabaseConfig) (*DB, error) {
 		dsn = cfg.Database
 	}
 
-	conn, err := sql.Open(cfg.Driver, dsn)
+	if maxOpen <= 0 {
+		maxOpen = 100
+	}
+	if conn == nil && err == nil {
+		conn, err = sql.Open(cfg.Driver, dsn)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	conn.SetMaxIdleConns(cfg.MaxIdle)
-	conn.SetMaxOpenConns(cfg.MaxOpen)
+	conn.SetMaxOpenConns(maxOpen)
 	conn.SetConnMaxLifetime(time.Hour)
 
-	if err := conn.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	db := &DB{conn: conn, config: cfg}
-
-	if cfg.Driver == "sqlite3" || cfg.Driver == "sqlite" {
-		db.createDefaultTables()
-	}
-
-	logger.Info("Database connected successfully: %s", cfg.Driver)
-	return db, nil
-}
-
-func (db *DB) createDefaultTables() {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name VARCHAR(100) NOT NULL,
-			email VARCHAR(100) UNIQUE NOT NULL,
-			phone VARCHAR(20),
-			age INTEGER,
-			website VARCHAR(255),
-			password VARCHAR(255) NOT NULL,
-			avatar VARCHAR(255),
-			is_active BOOLEAN DEFAULT 1,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS posts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			title VARCHAR(255) NOT NULL,
-			content TEXT,
-			slug VARCHAR(255) UNIQUE,
-			status VARCHAR(20) DEFAULT 'draft',
-			published_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name VARCHAR(100) NOT NULL,
-			description TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS post_categories (
-			post_id INTEGER,
-			category_id INTEGER,
-			PRIMARY KEY (post_id, category_id),
-			FOREIGN KEY (post_id) REFERENCES posts(id),
-			FOREIGN KEY (category_id) REFERENCES categories(id)
-		)`,
-	}
-
-	for _, query := range queries {
-		if _, err := db.conn.Exec(query); err != nil {
-			logger.Error("Failed to create table: %v", err)
-		}
-	}
-
-	db.seedDefaultData()
-}
-
-func (db *DB) seedDefaultData() {
-	var count int
-	db.conn.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
-
-	if count == 0 {
-		users := []string{
-			"INSERT INTO users (name, email, password, age, website) VALUES ('John Doe', 'john@example.com', 'password123', 30, 'https://john.dev')",
-			"INSERT INTO users (name, email, password, age) VALUES ('Jane Smith', 'jane@example.com', 'password123', 25)",
-			"INSERT INTO users (name, email, password, age) VALUES ('Bob Wilson', 'bob@example.com', 'password123', 35)",
-		}
-
-		for _, query := range users {
-			db.conn.Exec(query)
-		}
-
-		categories := []string{
-			"INSERT INTO categories (name, description) VALUES ('Technology', 'Tech related posts')",
-			"INSERT INTO categories (name, description) VALUES ('Lifestyle', 'Life and style posts')",
-			"INSERT INTO categories (name, description) VALUES ('Business', 'Business and finance posts')",
-		}
-
-		for _, query := range categories {
-			db.conn.Exec(query)
-		}
-
-		logger.Info("Default data seeded successfully")
-	}
+	return conn, nil
 }
 
+// Query starts a query. On a nil DB the builder's terminal methods return
+// ErrNotInitialized.
 func (db *DB) Query() *QueryBuilder {
-	return &QueryBuilder{
+	qb := &QueryBuilder{
 		db:         db,
 		selectCols: []string{"*"},
 		whereConds: []string{},
 		whereArgs:  []interface{}{},
 		joins:      []string{},
 	}
+	if db == nil {
+		qb.err = ErrNotInitialized
+	}
+	return qb
 }
 
 func (qb *QueryBuilder) Table(table string) *QueryBuilder {
@@ -187,6 +280,33 @@ func (qb *QueryBuilder) Where(condition string, args ...interface{}) *QueryBuild
 	return qb
 }
 
+func (qb *QueryBuilder) WhereIn(col string, values ...interface{}) *QueryBuilder {
+	return qb.whereIn(col, "IN", values)
+}
+
+func (qb *QueryBuilder) WhereNotIn(col string, values ...interface{}) *QueryBuilder {
+	return qb.whereIn(col, "NOT IN", values)
+}
+
+func (qb *QueryBuilder) whereIn(col, op string, values []interface{}) *QueryBuilder {
+	if len(values) == 0 {
+		if qb.err == nil {
+			qb.err = fmt.Errorf("%s %s requires at least one value", col, op)
+		}
+		return qb
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	qb.whereConds = append(qb.whereConds, fmt.Sprintf("%s %s (%s)", col, op, placeholders))
+	qb.whereArgs = append(qb.whereArgs, values...)
+	return qb
+}
+
+// Err returns the first error recorded while building the query.
+func (qb *QueryBuilder) Err() error {
+	return qb.err
+}
+
 func (qb *QueryBuilder) Join(join string) *QueryBuilder {
 	qb.joins = append(qb.joins, join)
 	return qb
@@ -202,30 +322,81 @@ func (qb *QueryBuilder) Limit(limit int) *QueryBuilder {
 	return qb
 }
 
+// Unlimited lets Get return every matching row instead of stopping at the
+// configured MaxRows when no Limit is set. Use it for exports and batch
+// jobs that read the rows with ForEachStruct.
+func (qb *QueryBuilder) Unlimited() *QueryBuilder {
+	qb.unlimited = true
+	return qb
+}
+
 func (qb *QueryBuilder) Offset(offset int) *QueryBuilder {
 	qb.offsetCount = offset
 	return qb
 }
 
 func (qb *QueryBuilder) Get() (*sql.Rows, error) {
-	query := qb.buildSelectQuery()
-	return qb.db.conn.Query(query, qb.whereArgs...)
+	qb.applyTenant()
+	if qb.err != nil {
+		return nil, qb.err
+	}
+
+	conn, err := qb.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	if qb.limitCount <= 0 && !qb.unlimited {
+		qb.limitCount = qb.db.maxRows()
+	}
+	query := qb.db.rebind(qb.buildSelectQuery())
+	span := qb.startSpan("SELECT", query)
+	rows, err := conn.QueryContext(qb.context(), query, qb.whereArgs...)
+	endSpan(span, err)
+	return rows, err
 }
 
-func (qb *QueryBuilder) First() *sql.Row {
+func (qb *QueryBuilder) First() *Row {
+	qb.applyTenant()
+	if qb.err != nil {
+		return &Row{err: qb.err}
+	}
+
+	conn, err := qb.conn()
+	if err != nil {
+		return &Row{err: err}
+	}
+
 	qb.limitCount = 1
-	query := qb.buildSelectQuery()
-	return qb.db.conn.QueryRow(query, qb.whereArgs...)
+	query := qb.db.rebind(qb.buildSelectQuery())
+	span := qb.startSpan("SELECT", query)
+	row := conn.QueryRowContext(qb.context(), query, qb.whereArgs...)
+	endSpan(span, row.Err())
+	return &Row{row: row}
 }
 
 func (qb *QueryBuilder) Count() (int, error) {
-	oldCols := qb.selectCols
-	qb.selectCols = []string{"COUNT(*)"}
+	qb.applyTenant()
+	if qb.err != nil {
+		return 0, qb.err
+	}
+
+	// Postgres rejects locking clauses on aggregates.
+	oldCols, oldLock := qb.selectCols, qb.lock
+	qb.selectCols, qb.lock = []string{"COUNT(*)"}, ""
 	query := qb.buildSelectQuery()
-	qb.selectCols = oldCols
+	qb.selectCols, qb.lock = oldCols, oldLock
+
+	conn, err := qb.conn()
+	if err != nil {
+		return 0, err
+	}
 
+	query = qb.db.rebind(query)
+	span := qb.startSpan("SELECT", query)
 	var count int
-	err := qb.db.conn.QueryRow(query, qb.whereArgs...).Scan(&count)
+	err = conn.QueryRowContext(qb.context(), query, qb.whereArgs...).Scan(&count)
+	endSpan(span, err)
 	return count, err
 }
 
@@ -236,9 +407,7 @@ func (qb *QueryBuilder) buildSelectQuery() string {
 		query += " " + strings.Join(qb.joins, " ")
 	}
 
-	if len(qb.whereConds) > 0 {
-		query += " WHERE " + strings.Join(qb.whereConds, " AND ")
-	}
+	query += qb.whereClause()
 
 	if qb.orderBy != "" {
 		query += " ORDER BY " + qb.orderBy
@@ -252,10 +421,32 @@ func (qb *QueryBuilder) buildSelectQuery() string {
 		query += fmt.Sprintf(" OFFSET %d", qb.offsetCount)
 	}
 
-	return query
+	return query + qb.lockClause()
+}
+
+// whereClause joins the conditions with AND. With more than one, each is
+// parenthesized so an OR in one condition cannot escape the others, such as
+// the tenant filter added by ForTenant.
+func (qb *QueryBuilder) whereClause() string {
+	switch len(qb.whereConds) {
+	case 0:
+		return ""
+	case 1:
+		return " WHERE " + qb.whereConds[0]
+	}
+	return " WHERE (" + strings.Join(qb.whereConds, ") AND (") + ")"
 }
 
 func (qb *QueryBuilder) Insert(data map[string]interface{}) (int64, error) {
+	if qb.err != nil {
+		return 0, qb.err
+	}
+
+	data, err := qb.tenantData(data, true)
+	if err != nil {
+		return 0, err
+	}
+
 	cols := make([]string, 0, len(data))
 	placeholders := make([]string, 0, len(data))
 	values := make([]interface{}, 0, len(data))
@@ -269,7 +460,7 @@ func (qb *QueryBuilder) Insert(data map[string]interface{}) (int64, error) {
 	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
 		qb.table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
 
-	result, err := qb.db.conn.Exec(query, values...)
+	result, err := qb.exec("INSERT", query, values)
 	if err != nil {
 		return 0, err
 	}
@@ -278,6 +469,15 @@ func (qb *QueryBuilder) Insert(data map[string]interface{}) (int64, error) {
 }
 
 func (qb *QueryBuilder) Update(data map[string]interface{}) (int64, error) {
+	qb.applyTenant()
+	if qb.err != nil {
+		return 0, qb.err
+	}
+	data, err := qb.tenantData(data, false)
+	if err != nil {
+		return 0, err
+	}
+
 	setParts := make([]string, 0, len(data))
 	values := make([]interface{}, 0, len(data))
 
@@ -290,11 +490,9 @@ func (qb *QueryBuilder) Update(data map[string]interface{}) (int64, error) {
 
 	query := fmt.Sprintf("UPDATE %s SET %s", qb.table, strings.Join(setParts, ", "))
 
-	if len(qb.whereConds) > 0 {
-		query += " WHERE " + strings.Join(qb.whereConds, " AND ")
-	}
+	query += qb.whereClause()
 
-	result, err := qb.db.conn.Exec(query, values...)
+	result, err := qb.exec("UPDATE", query, values)
 	if err != nil {
 		return 0, err
 	}
@@ -303,13 +501,16 @@ func (qb *QueryBuilder) Update(data map[string]interface{}) (int64, error) {
 }
 
 func (qb *QueryBuilder) Delete() (int64, error) {
+	qb.applyTenant()
+	if qb.err != nil {
+		return 0, qb.err
+	}
+
 	query := fmt.Sprintf("DELETE FROM %s", qb.table)
 
-	if len(qb.whereConds) > 0 {
-		query += " WHERE " + strings.Join(qb.whereConds, " AND ")
-	}
+	query += qb.whereClause()
 
-	result, err := qb.db.conn.Exec(query, qb.whereArgs...)
+	result, err := qb.exec("DELETE", query, qb.whereArgs)
 	if err != nil {
 		return 0, err
 	}
@@ -317,24 +518,117 @@ func (qb *QueryBuilder) Delete() (int64, error) {
 	return result.RowsAffected()
 }
 
+// rebind rewrites ? placeholders to $1, $2, ... for Postgres, leaving
+// question marks inside quoted literals untouched.
+func (db *DB) rebind(query string) string {
+	if db == nil || db.config == nil || db.config.Driver != "postgres" {
+		return query
+	}
+
+	var buf strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			fmt.Fprintf(&buf, "$%d", n)
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return db.conn.Exec(query, args...)
+	return db.ExecContext(context.Background(), query, args...)
 }
 
-func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
-	return db.conn.QueryRow(query, args...)
+func (db *DB) QueryRow(query string, args ...interface{}) *Row {
+	return db.QueryRowContext(context.Background(), query, args...)
 }
 
 func (db *DB) QueryRows(query string, args ...interface{}) (*sql.Rows, error) {
-	return db.conn.Query(query, args...)
+	return db.QueryRowsContext(context.Background(), query, args...)
+}
+
+// ExecContext runs query under ctx, recording a span when ctx carries a
+// trace. The Context variants of QueryRow and QueryRows do the same. Raw
+// queries use ? placeholders on every driver and are rebound like the
+// QueryBuilder's.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, err := db.getConn()
+	if err != nil {
+		return nil, err
+	}
+	query = db.rebind(query)
+	span := db.startSpan(ctx, statementName(query), query)
+	result, err := conn.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	conn, err := db.getConn()
+	if err != nil {
+		return &Row{err: err}
+	}
+	query = db.rebind(query)
+	span := db.startSpan(ctx, statementName(query), query)
+	row := conn.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return &Row{row: row}
+}
+
+func (db *DB) QueryRowsContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	conn, err := db.getConn()
+	if err != nil {
+		return nil, err
+	}
+	query = db.rebind(query)
+	span := db.startSpan(ctx, statementName(query), query)
+	rows, err := conn.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
 }
 
+func (db *DB) Ping(ctx context.Context) error {
+	conn, err := db.getConn()
+	if err != nil {
+		return err
+	}
+	return conn.PingContext(ctx)
+}
+
+// Close releases the connection. A lazy DB that was never used is marked
+// closed without connecting.
 func (db *DB) Close() error {
-	return db.conn.Close()
+	if db == nil {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.closed = true
+	if conn := db.conn.Swap(nil); conn != nil {
+		return conn.Close()
+	}
+	return nil
 }
 
 func (db *DB) Begin() (*sql.Tx, error) {
-	return db.conn.Begin()
+	conn, err := db.getConn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Begin()
 }
 
 func Query() *QueryBuilder {
@@ -345,7 +639,7 @@ func Exec(query string, args ...interface{}) (sql.Result, error) {
 	return DefaultDB.Exec(query, args...)
 }
 
-func QueryRow(query string, args ...interface{}) *sql.Row {
+func QueryRow(query string, args ...interface{}) *Row {
 	return DefaultDB.QueryRow(query, args...)
 }
 
@@ -353,43 +647,211 @@ func QueryRows(query string, args ...interface{}) (*sql.Rows, error) {
 	return DefaultDB.QueryRows(query, args...)
 }
 
+func ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return FromContext(ctx).ExecContext(ctx, query, args...)
+}
+
+func QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	return FromContext(ctx).QueryRowContext(ctx, query, args...)
+}
+
+func QueryRowsContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return FromContext(ctx).QueryRowsContext(ctx, query, args...)
+}
+
+// ScanToStruct appends every row to dest, a pointer to a slice of structs.
+// Columns are matched to fields by name, ignoring case.
 func ScanToStruct(rows *sql.Rows, dest interface{}) error {
+	_, err := ScanToStructLimit(rows, dest, 0)
+	return err
+}
+
+// ScanToStructLimit is ScanToStruct stopping after max rows, reporting
+// whether rows were left unread. A max of 0 or less reads them all.
+func ScanToStructLimit(rows *sql.Rows, dest interface{}, max int) (truncated bool, err error) {
 	destValue := reflect.ValueOf(dest)
 	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
-		return fmt.Errorf("dest must be a pointer to slice")
+		return false, fmt.Errorf("dest must be a pointer to slice")
 	}
 
 	sliceValue := destValue.Elem()
 	elemType := sliceValue.Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return false, fmt.Errorf("dest must be a pointer to slice of structs")
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+
+	elem := reflect.New(elemType).Elem()
+	targets := structTargets(elem, columns)
+	for n := 0; rows.Next(); n++ {
+		if max > 0 && n == max {
+			return true, rows.Err()
+		}
+
+		elem.SetZero()
+		if err := rows.Scan(targets...); err != nil {
+			return false, err
+		}
+		sliceValue.Set(reflect.Append(sliceValue, elem))
+	}
+
+	return false, rows.Err()
+}
+
+// ForEachStruct decodes each row into dest, a pointer to a struct, and
+// calls fn with it, so memory stays flat however many rows there are. dest
+// is reused between rows: fn must copy it to keep a row. An error from fn
+// stops the iteration and is returned.
+func ForEachStruct(rows *sql.Rows, dest interface{}, fn func(v interface{}) error) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dest must be a pointer to struct")
+	}
 
 	columns, err := rows.Columns()
 	if err != nil {
 		return err
 	}
 
+	elem := destValue.Elem()
+	targets := structTargets(elem, columns)
 	for rows.Next() {
-		elemPtr := reflect.New(elemType)
-		elem := elemPtr.Elem()
+		elem.SetZero()
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		if err := fn(dest); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// structTargets returns the scan destinations for columns in elem: the
+// address of the field named like the column, or a throwaway value.
+func structTargets(elem reflect.Value, columns []string) []interface{} {
+	targets := make([]interface{}, len(columns))
+	for i, col := range columns {
+		field := elem.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, col)
+		})
+		if field.IsValid() {
+			targets[i] = field.Addr().Interface()
+		} else {
+			var dummy interface{}
+			targets[i] = &dummy
+		}
+	}
+	return targets
+}
 
-		values := make([]interface{}, len(columns))
+// ScanToMap reads every row into a map keyed by column name. Values are
+// converted according to the column's database type into int64, float64,
+// bool, time.Time or string; NULL becomes nil.
+func ScanToMap(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]string, len(columns))
+	if columnTypes, err := rows.ColumnTypes(); err == nil {
+		for i, ct := range columnTypes {
+			types[i] = strings.ToUpper(ct.DatabaseTypeName())
+		}
+	}
+
+	result := []map[string]interface{}{}
+	raw := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
 		for i, col := range columns {
-			field := elem.FieldByNameFunc(func(name string) bool {
-				return strings.EqualFold(name, col)
-			})
-			if field.IsValid() {
-				values[i] = field.Addr().Interface()
-			} else {
-				var dummy interface{}
-				values[i] = &dummy
+			if raw[i] == nil {
+				row[col] = nil
+				continue
 			}
+			row[col] = convertRaw(string(raw[i]), types[i])
 		}
+		result = append(result, row)
+	}
 
-		if err := rows.Scan(values...); err != nil {
-			return err
-		}
+	return result, rows.Err()
+}
 
-		sliceValue.Set(reflect.Append(sliceValue, elem))
+// RowScanner is satisfied by *sql.Row and *Row.
+type RowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ScanFirstMap scans a single row into a map using the given column names,
+// which must match the selected columns in order.
+func ScanFirstMap(row RowScanner, cols []string) (map[string]interface{}, error) {
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
 	}
 
-	return rows.Err()
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(cols))
+	for i, col := range cols {
+		if b, ok := values[i].([]byte); ok {
+			result[col] = string(b)
+		} else {
+			result[col] = values[i]
+		}
+	}
+	return result, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"15:04:05",
+}
+
+func convertRaw(value, dbType string) interface{} {
+	switch {
+	case strings.Contains(dbType, "INT"):
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case strings.Contains(dbType, "BOOL"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case strings.Contains(dbType, "FLOAT"), strings.Contains(dbType, "DOUBLE"), strings.Contains(dbType, "REAL"),
+		strings.Contains(dbType, "DECIMAL"), strings.Contains(dbType, "NUMERIC"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case strings.Contains(dbType, "DATE"), strings.Contains(dbType, "TIME"):
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t
+			}
+		}
+	}
+	return value
 }
@@ -0,0 +1,167 @@
+package database_test
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+)
+
+func openSQLite(t *testing.T, cfg config.DatabaseConfig) *database.DB {
+	t.Helper()
+	cfg.Driver = "sqlite3"
+	cfg.Database = filepath.Join(t.TempDir(), "test.db")
+	db, err := database.NewDB(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func pragma(t *testing.T, db *database.DB, name string) string {
+	t.Helper()
+	var value string
+	if err := db.QueryRow("PRAGMA " + name).Scan(&value); err != nil {
+		t.Fatalf("PRAGMA %s: %v", name, err)
+	}
+	return value
+}
+
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{MaxIdle: 10, MaxOpen: 10})
+	if _, err := db.Exec("CREATE TABLE counters (id INTEGER PRIMARY KEY, worker INTEGER, n INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, writes = 10, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*writes)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < writes; n++ {
+				tx, err := db.Begin()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				var count int
+				if err := tx.QueryRow("SELECT COUNT(*) FROM counters").Scan(&count); err != nil {
+					tx.Rollback()
+					errs <- fmt.Errorf("read: %w", err)
+					continue
+				}
+				if _, err := tx.Exec("INSERT INTO counters (worker, n) VALUES (?, ?)", w, n); err != nil {
+					tx.Rollback()
+					errs <- fmt.Errorf("write: %w", err)
+					continue
+				}
+				if err := tx.Commit(); err != nil {
+					errs <- fmt.Errorf("commit: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		if failed == 0 {
+			t.Errorf("concurrent write failed: %v", err)
+		}
+		failed++
+	}
+	if failed > 0 {
+		t.Errorf("%d of %d writes failed", failed, workers*writes)
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM counters").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+	if total != workers*writes-failed {
+		t.Errorf("counted %d rows, want %d", total, workers*writes-failed)
+	}
+}
+
+func TestSQLitePragmas(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{})
+	for name, want := range map[string]string{
+		"journal_mode": "wal",
+		"foreign_keys": "1",
+		"busy_timeout": "5000",
+	} {
+		if got := pragma(t, db, name); got != want {
+			t.Errorf("PRAGMA %s = %s, want %s", name, got, want)
+		}
+	}
+
+	db = openSQLite(t, config.DatabaseConfig{Pragmas: map[string]string{
+		"busy_timeout": "250",
+		"foreign_keys": "",
+		"cache_size":   "-2000",
+	}})
+	for name, want := range map[string]string{
+		"busy_timeout": "250",
+		"foreign_keys": "0",
+		"cache_size":   "-2000",
+	} {
+		if got := pragma(t, db, name); got != want {
+			t.Errorf("PRAGMA %s = %s with overrides, want %s", name, got, want)
+		}
+	}
+}
+
+func TestSQLiteRejectsInvalidPragma(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Driver:   "sqlite3",
+		Database: filepath.Join(t.TempDir(), "test.db"),
+		Pragmas:  map[string]string{"journal_mode": "WAL; DROP TABLE users"},
+	}
+	if _, err := database.NewDB(&cfg); err == nil {
+		t.Error("NewDB accepted a pragma value with SQL in it")
+	}
+}
+
+func TestChunkReadsEveryRowOnce(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{MaxRows: 10})
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 25; i++ {
+		db.Exec("INSERT INTO items (id) VALUES (?)", i)
+	}
+
+	var ids []int
+	err := db.Query().Table("items").OrderBy("id").Offset(2).Limit(20).Chunk(7, func(rows *sql.Rows) error {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 20 || ids[0] != 3 || ids[19] != 22 {
+		t.Errorf("ids = %v", ids)
+	}
+
+	// Without a Limit, MaxRows does not cap chunked reads.
+	count := 0
+	db.Query().Table("items").OrderBy("id").Chunk(10, func(rows *sql.Rows) error {
+		count++
+		return nil
+	})
+	if count != 25 {
+		t.Errorf("read %d rows", count)
+	}
+}
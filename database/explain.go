@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Explain returns the plan the database chooses for the builder's query,
+// one line per plan row.
+func (qb *QueryBuilder) Explain() (string, error) {
+	return qb.explain(false)
+}
+
+// ExplainAnalyze runs EXPLAIN ANALYZE on MySQL and Postgres, which executes
+// the query to report actual row counts and timings. SQLite has no such
+// mode and returns its EXPLAIN QUERY PLAN summary instead.
+func (qb *QueryBuilder) ExplainAnalyze() (string, error) {
+	return qb.explain(true)
+}
+
+func (qb *QueryBuilder) explain(analyze bool) (string, error) {
+	qb.applyTenant()
+	if qb.err != nil {
+		return "", qb.err
+	}
+	return qb.db.explain(qb.buildSelectQuery(), qb.whereArgs, analyze)
+}
+
+func (db *DB) explain(query string, args []interface{}, analyze bool) (string, error) {
+	keyword, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	if !strings.EqualFold(keyword, "SELECT") && !strings.EqualFold(keyword, "WITH") {
+		return "", fmt.Errorf("explain only supports SELECT queries, got %s", strings.ToUpper(keyword))
+	}
+
+	driver := ""
+	if db.config != nil {
+		driver = db.config.Driver
+	}
+
+	rows, err := db.QueryRows(explainPrefix(driver, analyze)+query, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	return formatPlan(rows)
+}
+
+func explainPrefix(driver string, analyze bool) string {
+	switch driver {
+	case "postgres":
+		if analyze {
+			return "EXPLAIN (ANALYZE, FORMAT TEXT) "
+		}
+		return "EXPLAIN (FORMAT TEXT) "
+	case "mysql":
+		if analyze {
+			return "EXPLAIN ANALYZE "
+		}
+		return "EXPLAIN "
+	default:
+		if analyze {
+			return "EXPLAIN QUERY PLAN "
+		}
+		return "EXPLAIN "
+	}
+}
+
+// formatPlan joins plan rows with newlines. Single-column plans (Postgres,
+// MySQL ANALYZE) are returned as is; tabular ones get a header line and
+// cells separated by " | ".
+func formatPlan(rows *sql.Rows) (string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	raw := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+
+	var lines []string
+	if len(columns) > 1 {
+		lines = append(lines, strings.Join(columns, " | "))
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		cells := make([]string, len(raw))
+		for i, value := range raw {
+			if value == nil {
+				cells[i] = "NULL"
+			} else {
+				cells[i] = string(value)
+			}
+		}
+		lines = append(lines, strings.Join(cells, " | "))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
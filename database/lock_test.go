@@ -0,0 +1,181 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+)
+
+// lockSQL builds a locked query inside a transaction on db without running
+// it, by failing the transaction once the SQL is known.
+func lockSQL(t *testing.T, db *database.DB, lock func(*database.QueryBuilder) *database.QueryBuilder) string {
+	t.Helper()
+	var query string
+	stop := errors.New("stop")
+	err := db.Transaction(context.Background(), func(tx *database.Tx) error {
+		qb := lock(tx.Query().Table("products").Where("id = ?", 1).Limit(1))
+		if err := qb.Err(); err != nil {
+			return err
+		}
+		query, _ = qb.ToSQL()
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatal(err)
+	}
+	return query
+}
+
+func TestLockClauses(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{})
+	update := (*database.QueryBuilder).LockForUpdate
+	share := (*database.QueryBuilder).SharedLock
+
+	// SQLite has no locking clause; its transactions lock the database.
+	if got, want := lockSQL(t, db, update), "SELECT * FROM products WHERE id = ? LIMIT 1"; got != want {
+		t.Errorf("sqlite: %q, want %q", got, want)
+	}
+
+	for _, tc := range []struct {
+		driver string
+		lock   func(*database.QueryBuilder) *database.QueryBuilder
+		want   string
+	}{
+		{"postgres", update, "SELECT * FROM products WHERE id = $1 LIMIT 1 FOR UPDATE"},
+		{"postgres", share, "SELECT * FROM products WHERE id = $1 LIMIT 1 FOR SHARE"},
+		{"mysql", update, "SELECT * FROM products WHERE id = ? LIMIT 1 FOR UPDATE"},
+		{"mysql", share, "SELECT * FROM products WHERE id = ? LIMIT 1 LOCK IN SHARE MODE"},
+	} {
+		// The clause is built whether or not the builder is in a
+		// transaction, so the other drivers need no server.
+		other := database.NewLazyDB(&config.DatabaseConfig{Driver: tc.driver})
+		qb := tc.lock(other.Query().Table("products").Where("id = ?", 1).Limit(1))
+		if got, _ := qb.ToSQL(); got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.driver, got, tc.want)
+		}
+	}
+}
+
+func TestLockOutsideTransaction(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{})
+	if _, err := db.Exec("CREATE TABLE products (id INTEGER PRIMARY KEY, stock INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Query().Table("products").LockForUpdate().Get()
+	if !errors.Is(err, database.ErrLockOutsideTransaction) {
+		t.Errorf("Get() error = %v, want ErrLockOutsideTransaction", err)
+	}
+	if err := db.Query().Table("products").SharedLock().First().Scan(new(int), new(int)); !errors.Is(err, database.ErrLockOutsideTransaction) {
+		t.Errorf("First() error = %v, want ErrLockOutsideTransaction", err)
+	}
+}
+
+func decrementStock(db *database.DB, n int) error {
+	return db.Transaction(context.Background(), func(tx *database.Tx) error {
+		var stock int
+		if err := tx.Query().Table("products").Select("stock").Where("id = ?", 1).LockForUpdate().First().Scan(&stock); err != nil {
+			return err
+		}
+		if stock < n {
+			return errors.New("out of stock")
+		}
+		_, err := tx.Query().Table("products").Where("id = ?", 1).Update(map[string]interface{}{"stock": stock - n})
+		return err
+	})
+}
+
+func TestTransactionSerializesDecrements(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{MaxIdle: 10, MaxOpen: 10})
+	if _, err := db.Exec("CREATE TABLE products (id INTEGER PRIMARY KEY, stock INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO products (id, stock) VALUES (1, 100)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := decrementStock(db, 1); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var stock int
+	db.QueryRow("SELECT stock FROM products WHERE id = 1").Scan(&stock)
+	if stock != 50 {
+		t.Errorf("stock = %d, want 50: decrements were lost", stock)
+	}
+
+	// A failed transaction rolls back.
+	if err := decrementStock(db, 1000); err == nil {
+		t.Error("decrement beyond the stock succeeded")
+	}
+	db.QueryRow("SELECT stock FROM products WHERE id = 1").Scan(&stock)
+	if stock != 50 {
+		t.Errorf("stock = %d after a rolled back transaction", stock)
+	}
+}
+
+func TestPostgresLockForUpdate(t *testing.T) {
+	host := os.Getenv("FLUGO_TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("FLUGO_TEST_POSTGRES_HOST not set")
+	}
+	db, err := database.NewDB(&config.DatabaseConfig{
+		Driver:   "postgres",
+		Host:     host,
+		Port:     5432,
+		Username: os.Getenv("FLUGO_TEST_POSTGRES_USER"),
+		Password: os.Getenv("FLUGO_TEST_POSTGRES_PASSWORD"),
+		Database: os.Getenv("FLUGO_TEST_POSTGRES_DB"),
+		SSLMode:  "disable",
+	})
+	if err != nil {
+		t.Skipf("postgres unavailable: %v", err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS products",
+		"CREATE TABLE products (id INTEGER PRIMARY KEY, stock INTEGER)",
+		"INSERT INTO products (id, stock) VALUES (1, 100)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := decrementStock(db, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var stock int
+	db.QueryRow("SELECT stock FROM products WHERE id = 1").Scan(&stock)
+	if stock != 80 {
+		t.Errorf("stock = %d, want 80", stock)
+	}
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Migration changes the schema in one step. Versions sort lexically, so use
+// a timestamp such as 20240115093000.
+//
+// UpSQL and DownSQL return statements for the driver the migration runs on
+// (see DB.Driver), which is how the schema package writes portable
+// migrations. They run before Up and Down when both are set.
+type Migration struct {
+	Version string
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+	UpSQL   func(driver string) ([]string, error)
+	DownSQL func(driver string) ([]string, error)
+}
+
+type MigrationStatus struct {
+	Version   string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string]Migration)
+)
+
+func RegisterMigration(m Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[m.Version] = m
+}
+
+// SQLMigration builds a Migration from plain statements.
+func SQLMigration(version, name, up, down string) Migration {
+	exec := func(query string) func(tx *sql.Tx) error {
+		return func(tx *sql.Tx) error {
+			if query == "" {
+				return nil
+			}
+			_, err := tx.Exec(query)
+			return err
+		}
+	}
+
+	return Migration{Version: version, Name: name, Up: exec(up), Down: exec(down)}
+}
+
+func sortedMigrations() []Migration {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	list := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+func (db *DB) ensureMigrationsTable() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(64) PRIMARY KEY,
+		name VARCHAR(255),
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+func (db *DB) appliedMigrations() (map[string]time.Time, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryRows("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]time.Time)
+	for rows.Next() {
+		var version string
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Migrate applies every pending migration in version order and returns the
+// ones it ran. Each migration runs in its own transaction.
+func (db *DB) Migrate() ([]Migration, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range sortedMigrations() {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+
+		err := db.inTx(func(tx *sql.Tx) error {
+			if err := db.execSQL(tx, m.UpSQL); err != nil {
+				return err
+			}
+			if m.Up != nil {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(db.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				m.Version, m.Name, time.Now())
+			return err
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %s (%s) failed: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// Rollback reverts the last steps applied migrations, newest first.
+func (db *DB) Rollback(steps int) ([]Migration, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	list := sortedMigrations()
+	var reverted []Migration
+	for i := len(list) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := list[i]
+		if _, done := applied[m.Version]; !done {
+			continue
+		}
+
+		err := db.inTx(func(tx *sql.Tx) error {
+			if err := db.execSQL(tx, m.DownSQL); err != nil {
+				return err
+			}
+			if m.Down != nil {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(db.rebind("DELETE FROM schema_migrations WHERE version = ?"), m.Version)
+			return err
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("rollback of %s (%s) failed: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var status []MigrationStatus
+	for _, m := range sortedMigrations() {
+		appliedAt, done := applied[m.Version]
+		status = append(status, MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   done,
+			AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+func (db *DB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) execSQL(tx *sql.Tx, build func(driver string) ([]string, error)) error {
+	if build == nil {
+		return nil
+	}
+	statements, err := build(db.Driver())
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
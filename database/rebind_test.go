@@ -0,0 +1,95 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+)
+
+// recorder stands in for the Postgres driver, which this module does not
+// import, and records the statements it is sent.
+type recorder struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (r *recorder) Open(string) (driver.Conn, error) { return recorderConn{r}, nil }
+
+func (r *recorder) record(query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, query)
+}
+
+type recorderConn struct{ r *recorder }
+
+func (c recorderConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recorderConn) Close() error                        { return nil }
+func (c recorderConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c recorderConn) Commit() error                       { return nil }
+func (c recorderConn) Rollback() error                     { return nil }
+
+func (c recorderConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c recorderConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.r.record(query)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"n"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+var postgres = &recorder{}
+
+func init() {
+	sql.Register("postgres", postgres)
+}
+
+func TestRawQueriesRebindForPostgres(t *testing.T) {
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "postgres"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.Exec("UPDATE sessions SET revoked = ? WHERE id = ?", true, "s1")
+	db.QueryRow("SELECT id FROM sessions WHERE id = ? AND note <> '?'", "s1").Scan(new(string))
+	rows, _ := db.QueryRows("SELECT id FROM sessions WHERE user_id = ?", 7)
+	rows.Close()
+	db.Transaction(context.Background(), func(tx *database.Tx) error {
+		tx.Exec("DELETE FROM sessions WHERE id = ?", "s1")
+		tx.QueryRow("SELECT id FROM sessions WHERE id = ?", "s2").Scan(new(string))
+		return nil
+	})
+
+	want := []string{
+		"UPDATE sessions SET revoked = $1 WHERE id = $2",
+		"SELECT id FROM sessions WHERE id = $1 AND note <> '?'",
+		"SELECT id FROM sessions WHERE user_id = $1",
+		"DELETE FROM sessions WHERE id = $1",
+		"SELECT id FROM sessions WHERE id = $1",
+	}
+	postgres.mu.Lock()
+	defer postgres.mu.Unlock()
+	if len(postgres.queries) != len(want) {
+		t.Fatalf("queries = %q, want %q", postgres.queries, want)
+	}
+	for i := range want {
+		if postgres.queries[i] != want[i] {
+			t.Errorf("query %d = %q, want %q", i, postgres.queries[i], want[i])
+		}
+	}
+}
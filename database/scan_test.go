@@ -0,0 +1,115 @@
+package database_test
+
+import (
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+)
+
+type item struct {
+	ID   int
+	Name string
+}
+
+func seedItems(t *testing.T, db *database.DB, n int) {
+	t.Helper()
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		if _, err := db.Exec("INSERT INTO items (id, name) VALUES (?, ?)", i, "item"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMaxRows(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{MaxRows: 3})
+	seedItems(t, db, 5)
+
+	for name, tc := range map[string]struct {
+		query *database.QueryBuilder
+		want  int
+	}{
+		"default cap":    {db.Query().Table("items"), 3},
+		"explicit limit": {db.Query().Table("items").Limit(4), 4},
+		"unlimited":      {db.Query().Table("items").Unlimited(), 5},
+	} {
+		rows, err := tc.query.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var items []item
+		err = database.ScanToStruct(rows, &items)
+		rows.Close()
+		if err != nil || len(items) != tc.want {
+			t.Errorf("%s: scanned %d rows, %v; want %d", name, len(items), err, tc.want)
+		}
+	}
+}
+
+func TestScanToStructLimit(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{})
+	seedItems(t, db, 5)
+
+	for _, tc := range []struct {
+		max       int
+		want      int
+		truncated bool
+	}{
+		{max: 2, want: 2, truncated: true},
+		{max: 5, want: 5, truncated: false},
+		{max: 0, want: 5, truncated: false},
+	} {
+		rows, err := db.Query().Table("items").OrderBy("id").Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var items []item
+		truncated, err := database.ScanToStructLimit(rows, &items, tc.max)
+		rows.Close()
+		if err != nil || len(items) != tc.want || truncated != tc.truncated {
+			t.Errorf("max %d: %d rows, truncated %v, %v; want %d, %v", tc.max, len(items), truncated, err, tc.want, tc.truncated)
+		}
+		if len(items) > 1 && items[1].ID != 2 {
+			t.Errorf("max %d: second row has id %d", tc.max, items[1].ID)
+		}
+	}
+}
+
+func TestForEachStruct(t *testing.T) {
+	db := openSQLite(t, config.DatabaseConfig{})
+	seedItems(t, db, 4)
+	db.Exec("UPDATE items SET name = NULL WHERE id = 3")
+
+	rows, err := db.Query().Table("items").Select("id", "name").OrderBy("id").Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var row struct {
+		ID   int
+		Name *string
+	}
+	var ids []int
+	var named int
+	err = database.ForEachStruct(rows, &row, func(v interface{}) error {
+		if v != &row {
+			t.Fatal("callback not given the reused value")
+		}
+		ids = append(ids, row.ID)
+		if row.Name != nil {
+			named++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The NULL name must not keep the previous row's value.
+	if len(ids) != 4 || ids[3] != 4 || named != 3 {
+		t.Errorf("visited %v with %d names", ids, named)
+	}
+}
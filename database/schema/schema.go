@@ -0,0 +1,157 @@
+// Package schema builds DDL that runs on SQLite, PostgreSQL and MySQL:
+//
+//	users := schema.Create("users", func(t *schema.Table) {
+//		t.Increments("id")
+//		t.String("email", 100).Unique()
+//		t.Boolean("is_active").Default(true)
+//		t.Timestamps()
+//	})
+//
+//	database.RegisterMigration(schema.Migration("20240115093000", "create_users",
+//		users, schema.DropIfExists("users")))
+//
+// Statements render per driver with SQL, so tests can compare the generated
+// text without a database.
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"flugo.com/database"
+)
+
+const (
+	SQLite   = "sqlite3"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// Statement is DDL that renders differently for each driver.
+type Statement interface {
+	SQL(driver string) ([]string, error)
+}
+
+// Migration wraps up and down statements, either of which may be nil, into
+// a database.Migration rendered for the driver it runs on.
+func Migration(version, name string, up, down Statement) database.Migration {
+	m := database.Migration{Version: version, Name: name}
+	if up != nil {
+		m.UpSQL = up.SQL
+	}
+	if down != nil {
+		m.DownSQL = down.SQL
+	}
+	return m
+}
+
+// Create builds a CREATE TABLE statement.
+func Create(name string, fn func(t *Table)) Statement {
+	t := &Table{name: name}
+	fn(t)
+	return &createTable{table: t}
+}
+
+// CreateIfNotExists is Create for tables that may predate the migration.
+func CreateIfNotExists(name string, fn func(t *Table)) Statement {
+	t := &Table{name: name}
+	fn(t)
+	return &createTable{table: t, ifNotExists: true}
+}
+
+func Drop(name string) Statement {
+	return dropTable{name: name}
+}
+
+func DropIfExists(name string) Statement {
+	return dropTable{name: name, ifExists: true}
+}
+
+// All runs statements in order.
+func All(statements ...Statement) Statement {
+	return batch(statements)
+}
+
+type batch []Statement
+
+func (b batch) SQL(driver string) ([]string, error) {
+	var out []string
+	for _, statement := range b {
+		sql, err := statement.SQL(driver)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sql...)
+	}
+	return out, nil
+}
+
+type dropTable struct {
+	name     string
+	ifExists bool
+}
+
+func (d dropTable) SQL(driver string) ([]string, error) {
+	if _, err := dialectFor(driver); err != nil {
+		return nil, err
+	}
+	if d.ifExists {
+		return []string{"DROP TABLE IF EXISTS " + d.name}, nil
+	}
+	return []string{"DROP TABLE " + d.name}, nil
+}
+
+type createTable struct {
+	table       *Table
+	ifNotExists bool
+}
+
+func (c *createTable) SQL(driver string) ([]string, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	t := c.table
+
+	var defs []string
+	for _, col := range t.columns {
+		def, err := col.definition(d)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", t.name, err)
+		}
+		defs = append(defs, def)
+	}
+	if len(t.primary) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(t.primary, ", ")))
+	}
+	for _, fk := range t.foreign {
+		defs = append(defs, fk.definition())
+	}
+	if d.driver == MySQL {
+		for _, idx := range t.indexes {
+			defs = append(defs, fmt.Sprintf("INDEX %s (%s)", idx.name(t.name), strings.Join(idx.columns, ", ")))
+		}
+	}
+
+	create := "CREATE TABLE "
+	if c.ifNotExists {
+		create += "IF NOT EXISTS "
+	}
+	statement := fmt.Sprintf("%s%s (\n\t%s\n)", create, t.name, strings.Join(defs, ",\n\t"))
+	if d.driver == MySQL {
+		statement += " ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	}
+
+	statements := []string{statement}
+	if d.driver != MySQL {
+		for _, idx := range t.indexes {
+			exists := ""
+			if c.ifNotExists {
+				exists = "IF NOT EXISTS "
+			}
+			statements = append(statements, fmt.Sprintf("CREATE INDEX %s%s ON %s (%s)",
+				exists, idx.name(t.name), t.name, strings.Join(idx.columns, ", ")))
+		}
+	}
+	return statements, nil
+}
@@ -0,0 +1,143 @@
+package schema_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/database/schema"
+)
+
+func usersTable() schema.Statement {
+	return schema.Create("users", func(t *schema.Table) {
+		t.Increments("id")
+		t.String("email", 100).Unique()
+		t.Boolean("is_active").Default(true)
+		t.Decimal("balance", 10, 2).Default(0)
+		t.String("role", 20).Default("member")
+		t.Timestamp("deleted_at").Nullable()
+		t.Timestamps()
+		t.Index("role")
+	})
+}
+
+func TestCreateSQLPerDriver(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   []string
+	}{
+		{schema.SQLite, []string{
+			`CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email VARCHAR(100) NOT NULL UNIQUE,
+	is_active BOOLEAN NOT NULL DEFAULT 1,
+	balance DECIMAL(10, 2) NOT NULL DEFAULT 0,
+	role VARCHAR(20) NOT NULL DEFAULT 'member',
+	deleted_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+			`CREATE INDEX users_role_index ON users (role)`,
+		}},
+		{schema.Postgres, []string{
+			`CREATE TABLE users (
+	id SERIAL PRIMARY KEY,
+	email VARCHAR(100) NOT NULL UNIQUE,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	balance DECIMAL(10, 2) NOT NULL DEFAULT 0,
+	role VARCHAR(20) NOT NULL DEFAULT 'member',
+	deleted_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+			`CREATE INDEX users_role_index ON users (role)`,
+		}},
+		{schema.MySQL, []string{
+			`CREATE TABLE users (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	email VARCHAR(100) NOT NULL UNIQUE,
+	is_active TINYINT(1) NOT NULL DEFAULT 1,
+	balance DECIMAL(10, 2) NOT NULL DEFAULT 0,
+	role VARCHAR(20) NOT NULL DEFAULT 'member',
+	deleted_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	INDEX users_role_index (role)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		}},
+	}
+
+	for _, tt := range tests {
+		got, err := usersTable().SQL(tt.driver)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.driver, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.driver, got, tt.want)
+		}
+	}
+}
+
+func TestConstraintsAndErrors(t *testing.T) {
+	stmt := schema.CreateIfNotExists("post_tags", func(t *schema.Table) {
+		t.Integer("post_id")
+		t.BigInteger("tag_id")
+		t.Primary("post_id", "tag_id")
+		t.Foreign("post_id").References("posts", "id").OnDelete("cascade")
+	})
+	got, err := stmt.SQL(schema.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `CREATE TABLE IF NOT EXISTS post_tags (
+	post_id INTEGER NOT NULL,
+	tag_id BIGINT NOT NULL,
+	PRIMARY KEY (post_id, tag_id),
+	FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+)`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := stmt.SQL("oracle"); err == nil || !strings.Contains(err.Error(), "unsupported driver") {
+		t.Errorf("error = %v, want unsupported driver", err)
+	}
+	bad := schema.Create("t", func(t *schema.Table) { t.String("name", 0) })
+	if _, err := bad.SQL(schema.SQLite); err == nil {
+		t.Error("zero-length string column rendered without an error")
+	}
+}
+
+func TestMigrationRunsOnSQLite(t *testing.T) {
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: ":memory:", MaxIdle: 1, MaxOpen: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	database.RegisterMigration(schema.Migration("20240101000000", "create_users", usersTable(), schema.DropIfExists("users")))
+	if _, err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if _, err := db.Query().Table("users").Insert(map[string]interface{}{"email": "a@b.c"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	var active bool
+	var role string
+	if err := db.Query().Table("users").Select("is_active", "role").First().Scan(&active, &role); err != nil {
+		t.Fatal(err)
+	}
+	if !active || role != "member" {
+		t.Errorf("defaults: is_active=%v role=%q, want true and member", active, role)
+	}
+
+	if _, err := db.Rollback(1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if _, err := db.Query().Table("users").Count(); err == nil {
+		t.Error("users table still exists after rollback")
+	}
+}
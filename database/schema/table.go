@@ -0,0 +1,328 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Table collects the columns and constraints passed to Create. Columns are
+// NOT NULL unless marked Nullable.
+type Table struct {
+	name    string
+	columns []*Column
+	primary []string
+	foreign []*ForeignKey
+	indexes []index
+}
+
+type columnType int
+
+const (
+	typeIncrements columnType = iota
+	typeBigIncrements
+	typeString
+	typeText
+	typeInteger
+	typeBigInteger
+	typeBoolean
+	typeFloat
+	typeDecimal
+	typeTimestamp
+	typeDate
+	typeJSON
+)
+
+type Column struct {
+	name       string
+	kind       columnType
+	length     int
+	precision  int
+	scale      int
+	nullable   bool
+	unique     bool
+	def        interface{}
+	hasDefault bool
+	useCurrent bool
+}
+
+// Nullable allows NULL values.
+func (c *Column) Nullable() *Column {
+	c.nullable = true
+	return c
+}
+
+func (c *Column) Unique() *Column {
+	c.unique = true
+	return c
+}
+
+// Default sets a literal default: a string, bool, integer or float.
+func (c *Column) Default(value interface{}) *Column {
+	c.def = value
+	c.hasDefault = true
+	return c
+}
+
+// UseCurrent defaults a timestamp column to the current time.
+func (c *Column) UseCurrent() *Column {
+	c.useCurrent = true
+	return c
+}
+
+func (t *Table) add(name string, kind columnType) *Column {
+	col := &Column{name: name, kind: kind}
+	t.columns = append(t.columns, col)
+	return col
+}
+
+// Increments adds an auto-incrementing integer primary key.
+func (t *Table) Increments(name string) *Column {
+	return t.add(name, typeIncrements)
+}
+
+func (t *Table) BigIncrements(name string) *Column {
+	return t.add(name, typeBigIncrements)
+}
+
+func (t *Table) String(name string, length int) *Column {
+	col := t.add(name, typeString)
+	col.length = length
+	return col
+}
+
+func (t *Table) Text(name string) *Column {
+	return t.add(name, typeText)
+}
+
+func (t *Table) Integer(name string) *Column {
+	return t.add(name, typeInteger)
+}
+
+func (t *Table) BigInteger(name string) *Column {
+	return t.add(name, typeBigInteger)
+}
+
+func (t *Table) Boolean(name string) *Column {
+	return t.add(name, typeBoolean)
+}
+
+func (t *Table) Float(name string) *Column {
+	return t.add(name, typeFloat)
+}
+
+func (t *Table) Decimal(name string, precision, scale int) *Column {
+	col := t.add(name, typeDecimal)
+	col.precision, col.scale = precision, scale
+	return col
+}
+
+// Timestamp is a date and time; TIMESTAMPTZ on PostgreSQL.
+func (t *Table) Timestamp(name string) *Column {
+	return t.add(name, typeTimestamp)
+}
+
+func (t *Table) Date(name string) *Column {
+	return t.add(name, typeDate)
+}
+
+// JSON is JSONB on PostgreSQL, JSON on MySQL and TEXT on SQLite.
+func (t *Table) JSON(name string) *Column {
+	return t.add(name, typeJSON)
+}
+
+// Timestamps adds created_at and updated_at, both defaulting to the
+// current time.
+func (t *Table) Timestamps() {
+	t.Timestamp("created_at").UseCurrent()
+	t.Timestamp("updated_at").UseCurrent()
+}
+
+// Primary declares a primary key over columns, for composite keys.
+func (t *Table) Primary(columns ...string) {
+	t.primary = columns
+}
+
+// Index adds a non-unique index named <table>_<columns>_index.
+func (t *Table) Index(columns ...string) {
+	t.indexes = append(t.indexes, index{columns: columns})
+}
+
+// Foreign starts a foreign key on column; finish it with References.
+func (t *Table) Foreign(column string) *ForeignKey {
+	fk := &ForeignKey{column: column}
+	t.foreign = append(t.foreign, fk)
+	return fk
+}
+
+type ForeignKey struct {
+	column    string
+	refTable  string
+	refColumn string
+	onDelete  string
+}
+
+func (fk *ForeignKey) References(table, column string) *ForeignKey {
+	fk.refTable, fk.refColumn = table, column
+	return fk
+}
+
+// OnDelete sets the action, such as "cascade" or "set null".
+func (fk *ForeignKey) OnDelete(action string) *ForeignKey {
+	fk.onDelete = strings.ToUpper(action)
+	return fk
+}
+
+func (fk *ForeignKey) definition() string {
+	def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)", fk.column, fk.refTable, fk.refColumn)
+	if fk.onDelete != "" {
+		def += " ON DELETE " + fk.onDelete
+	}
+	return def
+}
+
+type index struct {
+	columns []string
+}
+
+func (i index) name(table string) string {
+	return table + "_" + strings.Join(i.columns, "_") + "_index"
+}
+
+func (c *Column) definition(d dialect) (string, error) {
+	typ, err := d.columnType(c)
+	if err != nil {
+		return "", err
+	}
+
+	def := c.name + " " + typ
+	if c.kind == typeIncrements || c.kind == typeBigIncrements {
+		return def, nil
+	}
+	if !c.nullable {
+		def += " NOT NULL"
+	}
+	if c.unique {
+		def += " UNIQUE"
+	}
+	if c.useCurrent {
+		def += " DEFAULT CURRENT_TIMESTAMP"
+	} else if c.hasDefault {
+		literal, err := d.literal(c.def)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", c.name, err)
+		}
+		def += " DEFAULT " + literal
+	}
+	return def, nil
+}
+
+type dialect struct {
+	driver string
+}
+
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case SQLite, "sqlite":
+		return dialect{driver: SQLite}, nil
+	case Postgres, "postgresql", "pgx":
+		return dialect{driver: Postgres}, nil
+	case MySQL:
+		return dialect{driver: MySQL}, nil
+	}
+	return dialect{}, fmt.Errorf("schema: unsupported driver %q", driver)
+}
+
+func (d dialect) columnType(c *Column) (string, error) {
+	switch c.kind {
+	case typeIncrements, typeBigIncrements:
+		switch d.driver {
+		case Postgres:
+			if c.kind == typeBigIncrements {
+				return "BIGSERIAL PRIMARY KEY", nil
+			}
+			return "SERIAL PRIMARY KEY", nil
+		case MySQL:
+			if c.kind == typeBigIncrements {
+				return "BIGINT AUTO_INCREMENT PRIMARY KEY", nil
+			}
+			return "INT AUTO_INCREMENT PRIMARY KEY", nil
+		default:
+			return "INTEGER PRIMARY KEY AUTOINCREMENT", nil
+		}
+	case typeString:
+		if c.length <= 0 {
+			return "", fmt.Errorf("column %s: string length must be positive", c.name)
+		}
+		return fmt.Sprintf("VARCHAR(%d)", c.length), nil
+	case typeText:
+		return "TEXT", nil
+	case typeInteger:
+		if d.driver == MySQL {
+			return "INT", nil
+		}
+		return "INTEGER", nil
+	case typeBigInteger:
+		if d.driver == SQLite {
+			return "INTEGER", nil
+		}
+		return "BIGINT", nil
+	case typeBoolean:
+		if d.driver == MySQL {
+			return "TINYINT(1)", nil
+		}
+		return "BOOLEAN", nil
+	case typeFloat:
+		switch d.driver {
+		case Postgres:
+			return "DOUBLE PRECISION", nil
+		case MySQL:
+			return "DOUBLE", nil
+		default:
+			return "REAL", nil
+		}
+	case typeDecimal:
+		return fmt.Sprintf("DECIMAL(%d, %d)", c.precision, c.scale), nil
+	case typeTimestamp:
+		if d.driver == Postgres {
+			return "TIMESTAMPTZ", nil
+		}
+		return "DATETIME", nil
+	case typeDate:
+		return "DATE", nil
+	case typeJSON:
+		switch d.driver {
+		case Postgres:
+			return "JSONB", nil
+		case MySQL:
+			return "JSON", nil
+		default:
+			return "TEXT", nil
+		}
+	}
+	return "", fmt.Errorf("column %s: unknown type", c.name)
+}
+
+func (d dialect) literal(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case bool:
+		if d.driver == Postgres {
+			return strings.ToUpper(strconv.FormatBool(v)), nil
+		}
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "NULL", nil
+	}
+	return "", fmt.Errorf("unsupported default %v (%T)", v, v)
+}
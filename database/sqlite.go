@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"flugo.com/config"
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultSQLitePragmas run on every SQLite connection. A busy timeout makes
+// writers wait for the lock instead of failing with "database is locked";
+// WAL lets readers run alongside the single writer. DatabaseConfig.Pragmas
+// overrides entries, and an empty value drops one.
+var DefaultSQLitePragmas = map[string]string{
+	"busy_timeout": "5000",
+	"journal_mode": "WAL",
+	"foreign_keys": "ON",
+	"synchronous":  "NORMAL",
+}
+
+// defaultSQLiteMaxOpen keeps the pool small when MaxOpen is unset: SQLite
+// serializes writers anyway, and every extra connection is one more waiting
+// on the lock.
+const defaultSQLiteMaxOpen = 4
+
+var (
+	pragmaName  = regexp.MustCompile(`^[a-z_]+$`)
+	pragmaValue = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// openSQLite opens the database with the configured pragmas. Transactions
+// begin IMMEDIATE so a transaction that reads before writing takes the
+// write lock up front; upgrading a read lock later fails at once in WAL
+// mode instead of waiting out the busy timeout.
+func openSQLite(cfg *config.DatabaseConfig) (*sql.DB, error) {
+	statements, err := sqlitePragmas(cfg.Pragmas)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := cfg.Database
+	if !strings.Contains(dsn, "_txlock=") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_txlock=immediate"
+	}
+
+	return sql.OpenDB(&sqliteConnector{
+		dsn: dsn,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for _, statement := range statements {
+					if _, err := conn.Exec(statement, nil); err != nil {
+						return fmt.Errorf("%s: %w", statement, err)
+					}
+				}
+				return nil
+			},
+		},
+	}), nil
+}
+
+func sqlitePragmas(overrides map[string]string) ([]string, error) {
+	pragmas := make(map[string]string, len(DefaultSQLitePragmas)+len(overrides))
+	for name, value := range DefaultSQLitePragmas {
+		pragmas[name] = value
+	}
+	for name, value := range overrides {
+		pragmas[strings.ToLower(name)] = value
+	}
+
+	names := make([]string, 0, len(pragmas))
+	for name, value := range pragmas {
+		if value == "" {
+			continue
+		}
+		if !pragmaName.MatchString(name) || !pragmaValue.MatchString(value) {
+			return nil, fmt.Errorf("invalid SQLite pragma %s = %q", name, value)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statements := make([]string, len(names))
+	for i, name := range names {
+		statements[i] = fmt.Sprintf("PRAGMA %s = %s", name, pragmas[name])
+	}
+	return statements, nil
+}
+
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c *sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"flugo.com/reqctx"
+)
+
+// TenantColumn is the column that holds the tenant in tenant-scoped tables.
+const TenantColumn = "tenant_id"
+
+var (
+	tenantTablesMu sync.RWMutex
+	tenantTables   = make(map[string]bool)
+)
+
+// RegisterTenantTable marks tables whose rows belong to a tenant through
+// TenantColumn. Builders scoped with ForTenant filter them automatically.
+func RegisterTenantTable(tables ...string) {
+	tenantTablesMu.Lock()
+	defer tenantTablesMu.Unlock()
+	for _, table := range tables {
+		tenantTables[table] = true
+	}
+}
+
+func IsTenantTable(table string) bool {
+	tenantTablesMu.RLock()
+	defer tenantTablesMu.RUnlock()
+	return tenantTables[table]
+}
+
+// ForTenant scopes the builder to the tenant carried by ctx, as stored by
+// middleware.Tenant. On a registered tenant table, selects, counts, updates
+// and deletes get a "tenant_id = ?" condition and inserts get the column
+// filled in; other tables are unaffected. A tenant table queried with no
+// tenant on ctx fails instead of reading across tenants. The queries also
+// run under ctx, as with WithContext.
+func (qb *QueryBuilder) ForTenant(ctx context.Context) *QueryBuilder {
+	qb.forTenant = true
+	qb.tenant = reqctx.TenantFromContext(ctx)
+	qb.ctx = ctx
+	return qb
+}
+
+// tenantScoped reports whether the builder's table needs the tenant filter.
+func (qb *QueryBuilder) tenantScoped() bool {
+	if !qb.forTenant {
+		return false
+	}
+	fields := strings.Fields(qb.table)
+	return len(fields) > 0 && IsTenantTable(fields[0])
+}
+
+// applyTenant adds the tenant condition once, before the query is built.
+func (qb *QueryBuilder) applyTenant() {
+	if qb.tenantApplied || qb.err != nil || !qb.tenantScoped() {
+		return
+	}
+	qb.tenantApplied = true
+
+	if qb.tenant == "" {
+		qb.err = fmt.Errorf("table %s is tenant-scoped but no tenant is set", qb.table)
+		return
+	}
+
+	column := TenantColumn
+	if len(qb.joins) > 0 {
+		// Qualify with the alias, or the table name when there is none.
+		fields := strings.Fields(qb.table)
+		column = fields[len(fields)-1] + "." + TenantColumn
+	}
+	qb.Where(column+" = ?", string(qb.tenant))
+}
+
+// tenantData fills in the tenant for inserts and refuses data that names
+// another one.
+func (qb *QueryBuilder) tenantData(data map[string]interface{}, fill bool) (map[string]interface{}, error) {
+	if !qb.tenantScoped() {
+		return data, nil
+	}
+	if qb.tenant == "" {
+		return nil, fmt.Errorf("table %s is tenant-scoped but no tenant is set", qb.table)
+	}
+
+	if v, ok := data[TenantColumn]; ok {
+		if fmt.Sprint(v) != string(qb.tenant) {
+			return nil, fmt.Errorf("%s %v does not match tenant %s", TenantColumn, v, qb.tenant)
+		}
+		return data, nil
+	}
+	if !fill {
+		return data, nil
+	}
+
+	scoped := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		scoped[k] = v
+	}
+	scoped[TenantColumn] = string(qb.tenant)
+	return scoped, nil
+}
@@ -0,0 +1,121 @@
+package database_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/reqctx"
+)
+
+func tenantDB(t *testing.T) *database.DB {
+	t.Helper()
+	db := openSQLite(t, config.DatabaseConfig{MaxOpen: 1})
+	database.RegisterTenantTable("orders")
+	for _, stmt := range []string{
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, tenant_id TEXT, status TEXT, public INTEGER, customer_id INTEGER)",
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO customers (id, name) VALUES (1, 'Ann')",
+		"INSERT INTO orders (id, tenant_id, status, public, customer_id) VALUES (1, 'acme', 'open', 0, 1), (2, 'acme', 'paid', 1, 1), (3, 'globex', 'open', 0, 1), (4, 'globex', 'paid', 1, 1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func acme() context.Context {
+	return reqctx.ContextWithTenant(context.Background(), "acme")
+}
+
+func orderIDs(t *testing.T, qb *database.QueryBuilder) []int {
+	t.Helper()
+	rows, err := qb.Select("orders.id").OrderBy("orders.id").Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestForTenantWrapsOrConditions(t *testing.T) {
+	db := tenantDB(t)
+
+	query, args := db.Query().Table("orders").Where("status = ? OR public = ?", "open", 1).ForTenant(acme()).ToSQL()
+	if want := "SELECT * FROM orders WHERE (status = ? OR public = ?) AND (tenant_id = ?)"; query != want || len(args) != 3 {
+		t.Errorf("ToSQL = %q %v, want %q", query, args, want)
+	}
+
+	ids := orderIDs(t, db.Query().Table("orders").Where("status = ? OR public = ?", "open", 1).ForTenant(acme()))
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("ids = %v, want only acme's orders 1 and 2", ids)
+	}
+}
+
+func TestForTenantQualifiesJoins(t *testing.T) {
+	db := tenantDB(t)
+
+	qb := db.Query().Table("orders o").Join("JOIN customers c ON c.id = o.customer_id").Where("c.name = ? OR o.public = ?", "Ann", 1).ForTenant(acme())
+	query, _ := qb.ToSQL()
+	if !strings.HasSuffix(query, "WHERE (c.name = ? OR o.public = ?) AND (o.tenant_id = ?)") {
+		t.Errorf("ToSQL = %q, want the alias-qualified tenant condition", query)
+	}
+	if n, err := qb.Count(); err != nil || n != 2 {
+		t.Errorf("Count = %d, %v, want 2", n, err)
+	}
+}
+
+func TestForTenantScopesUpdateAndDelete(t *testing.T) {
+	db := tenantDB(t)
+
+	n, err := db.Query().Table("orders").Where("status = ? OR public = ?", "open", 1).ForTenant(acme()).Update(map[string]interface{}{"status": "closed"})
+	if err != nil || n != 2 {
+		t.Fatalf("Update = %d, %v, want 2 rows", n, err)
+	}
+	if n, _ := db.Query().Table("orders").Where("tenant_id = ? AND status = ?", "globex", "closed").Count(); n != 0 {
+		t.Errorf("Update closed %d of globex's orders", n)
+	}
+
+	if _, err := db.Query().Table("orders").Where("id = ?", 1).ForTenant(acme()).Update(map[string]interface{}{"tenant_id": "globex"}); err == nil {
+		t.Error("Update moved a row to another tenant")
+	}
+
+	n, err = db.Query().Table("orders").Where("status = ? OR public = ?", "closed", 1).ForTenant(acme()).Delete()
+	if err != nil || n != 2 {
+		t.Fatalf("Delete = %d, %v, want 2 rows", n, err)
+	}
+	if n, _ := db.Query().Table("orders").Count(); n != 2 {
+		t.Errorf("%d orders left, want globex's 2", n)
+	}
+}
+
+func TestForTenantInsert(t *testing.T) {
+	db := tenantDB(t)
+
+	if _, err := db.Query().Table("orders").ForTenant(acme()).Insert(map[string]interface{}{"id": 5, "status": "open"}); err != nil {
+		t.Fatal(err)
+	}
+	var tenant string
+	db.QueryRow("SELECT tenant_id FROM orders WHERE id = 5").Scan(&tenant)
+	if tenant != "acme" {
+		t.Errorf("inserted tenant_id = %q, want acme", tenant)
+	}
+
+	_, err := db.Query().Table("orders").ForTenant(acme()).Insert(map[string]interface{}{"id": 6, "tenant_id": "globex"})
+	if err == nil || !strings.Contains(err.Error(), "does not match tenant acme") {
+		t.Errorf("Insert for another tenant error = %v", err)
+	}
+
+	if _, err := db.Query().Table("orders").ForTenant(context.Background()).Count(); err == nil {
+		t.Error("tenant table queried without a tenant")
+	}
+}
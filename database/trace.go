@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"flugo.com/trace"
+)
+
+// WithContext runs the builder's queries under ctx: they stop when ctx is
+// cancelled and, when ctx carries a trace, are recorded as child spans.
+func (qb *QueryBuilder) WithContext(ctx context.Context) *QueryBuilder {
+	qb.ctx = ctx
+	return qb
+}
+
+func (qb *QueryBuilder) context() context.Context {
+	if qb.ctx == nil {
+		return context.Background()
+	}
+	return qb.ctx
+}
+
+// startSpan names builder spans after the statement and table, as in
+// "SELECT users".
+func (qb *QueryBuilder) startSpan(verb, query string) *trace.Span {
+	name := verb
+	if fields := strings.Fields(qb.table); len(fields) > 0 {
+		name += " " + fields[0]
+	}
+	return qb.db.startSpan(qb.context(), name, query)
+}
+
+// exec runs a statement the builder built with ? placeholders.
+func (qb *QueryBuilder) exec(verb, query string, args []interface{}) (sql.Result, error) {
+	conn, err := qb.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	query = qb.db.rebind(query)
+	span := qb.startSpan(verb, query)
+	result, err := conn.ExecContext(qb.context(), query, args...)
+	if err == nil {
+		if n, err := result.RowsAffected(); err == nil {
+			span.SetAttribute("db.rows_affected", n)
+		}
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+func (db *DB) startSpan(ctx context.Context, name, query string) *trace.Span {
+	_, span := trace.Child(ctx, name, trace.WithKind(trace.KindClient))
+	if span != nil {
+		span.SetAttribute("db.system", db.Driver())
+		span.SetAttribute("db.statement", query)
+	}
+	return span
+}
+
+func endSpan(span *trace.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
+
+// statementName is the SQL verb of a raw query, used as its span name.
+func statementName(query string) string {
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return strings.ToUpper(verb)
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"flugo.com/logger"
+)
+
+// ErrLockOutsideTransaction is recorded by LockForUpdate and SharedLock on
+// a builder that does not belong to a transaction: outside one, the row
+// locks would be released as soon as the statement finished.
+var ErrLockOutsideTransaction = fmt.Errorf("row locks need a transaction: build the query with tx.Query() inside database.Transaction")
+
+// Tx is a transaction started by Transaction. Builders from its Query run
+// on the transaction.
+type Tx struct {
+	tx  *sql.Tx
+	db  *DB
+	ctx context.Context
+}
+
+// Transaction runs fn in a transaction, committing when it returns nil and
+// rolling back when it returns an error or panics. On SQLite transactions
+// begin IMMEDIATE, taking the write lock up front, which is what row locks
+// do on other databases.
+func (db *DB) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	conn, err := db.getConn()
+	if err != nil {
+		return err
+	}
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	tx := &Tx{tx: sqlTx, db: db, ctx: ctx}
+	defer func() {
+		if r := recover(); r != nil {
+			sqlTx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			logger.Warn("Transaction rollback failed: %v", rbErr)
+		}
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// Query starts a query on the transaction.
+func (tx *Tx) Query() *QueryBuilder {
+	qb := tx.db.Query()
+	qb.tx = tx.tx
+	qb.ctx = tx.ctx
+	return qb
+}
+
+// Exec runs a raw statement on the transaction, like DB.ExecContext.
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	query = tx.db.rebind(query)
+	span := tx.db.startSpan(tx.ctx, statementName(query), query)
+	result, err := tx.tx.ExecContext(tx.ctx, query, args...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *Row {
+	query = tx.db.rebind(query)
+	span := tx.db.startSpan(tx.ctx, statementName(query), query)
+	row := tx.tx.QueryRowContext(tx.ctx, query, args...)
+	endSpan(span, row.Err())
+	return &Row{row: row}
+}
+
+// SQL returns the underlying transaction.
+func (tx *Tx) SQL() *sql.Tx {
+	return tx.tx
+}
+
+const (
+	lockUpdate = "update"
+	lockShare  = "share"
+)
+
+// LockForUpdate locks the selected rows against writes and other locking
+// reads until the transaction ends, for read-modify-write sequences such
+// as decrementing stock. It must be used on a builder from tx.Query().
+func (qb *QueryBuilder) LockForUpdate() *QueryBuilder {
+	return qb.setLock(lockUpdate)
+}
+
+// SharedLock locks the selected rows against writes until the transaction
+// ends while still letting others read them.
+func (qb *QueryBuilder) SharedLock() *QueryBuilder {
+	return qb.setLock(lockShare)
+}
+
+func (qb *QueryBuilder) setLock(mode string) *QueryBuilder {
+	qb.lock = mode
+	if qb.tx == nil && qb.err == nil {
+		qb.err = ErrLockOutsideTransaction
+	}
+	return qb
+}
+
+var sqliteLockWarning sync.Once
+
+// lockClause is the locking clause of the builder's SELECT for its driver.
+// SQLite has none; its IMMEDIATE transactions already hold the write lock.
+func (qb *QueryBuilder) lockClause() string {
+	if qb.lock == "" {
+		return ""
+	}
+
+	switch qb.db.Driver() {
+	case "sqlite3":
+		sqliteLockWarning.Do(func() {
+			logger.Warn("SQLite has no row locks: LockForUpdate and SharedLock rely on the IMMEDIATE transaction's database lock")
+		})
+		return ""
+	case "mysql":
+		if qb.lock == lockShare {
+			return " LOCK IN SHARE MODE"
+		}
+		return " FOR UPDATE"
+	default:
+		if qb.lock == lockShare {
+			return " FOR SHARE"
+		}
+		return " FOR UPDATE"
+	}
+}
+
+// ToSQL returns the SELECT the builder would run, with the driver's
+// placeholders, and its arguments.
+func (qb *QueryBuilder) ToSQL() (string, []interface{}) {
+	qb.applyTenant()
+	return qb.db.rebind(qb.buildSelectQuery()), qb.whereArgs
+}
+
+// queryer is what builder statements run on: the pool or a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (qb *QueryBuilder) conn() (queryer, error) {
+	if qb.tx != nil {
+		return qb.tx, nil
+	}
+	return qb.db.getConn()
+}
+
+func Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	return FromContext(ctx).Transaction(ctx, fn)
+}
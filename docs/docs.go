@@ -0,0 +1,226 @@
+package docs
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"flugo.com/auth"
+	"flugo.com/middleware"
+	"flugo.com/router"
+	"flugo.com/router/openapi"
+)
+
+// Description documents a single route. Routes without one still appear in
+// the spec with their path parameters and a generic 200 response.
+type Description struct {
+	summary   string
+	tags      []string
+	request   interface{}
+	responses map[int]interface{}
+	secured   *bool
+}
+
+type Option func(*Description)
+
+func Summary(summary string) Option {
+	return func(d *Description) { d.summary = summary }
+}
+
+func Tags(tags ...string) Option {
+	return func(d *Description) { d.tags = tags }
+}
+
+// Request documents the JSON body, or the query parameters for GET and
+// DELETE routes. Routes guarded by middleware.ValidateBody need not repeat
+// their DTO here.
+func Request(v interface{}) Option {
+	return func(d *Description) { d.request = v }
+}
+
+// Response documents the data returned for a status code. The schema is
+// wrapped in the standard response envelope; pass nil for an empty body.
+func Response(status int, v interface{}) Option {
+	return func(d *Description) { d.responses[status] = v }
+}
+
+// Secured overrides the bearer requirement inferred from the route's
+// middlewares.
+func Secured(secured bool) Option {
+	return func(d *Description) { d.secured = &secured }
+}
+
+var (
+	descriptionsMu sync.RWMutex
+	descriptions   = make(map[string]*Description)
+)
+
+// Describe attaches documentation to a route given as "METHOD /path":
+//
+//	docs.Describe("POST /users", docs.Request(CreateUserRequest{}), docs.Response(201, User{}))
+func Describe(route string, opts ...Option) {
+	method, path, _ := strings.Cut(strings.TrimSpace(route), " ")
+
+	d := &Description{responses: make(map[int]interface{})}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	descriptionsMu.Lock()
+	descriptions[routeKey(method, path)] = d
+	descriptionsMu.Unlock()
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + strings.TrimSpace(path)
+}
+
+// securedMiddlewares holds the code pointers of middlewares that require a
+// bearer token. Closures created by the same constructor share one pointer.
+var securedMiddlewares = map[uintptr]bool{
+	funcPointer(auth.RequireAuth()):   true,
+	funcPointer(auth.RequireRoles()):  true,
+	funcPointer(auth.RequireScopes()): true,
+}
+
+func funcPointer(mw router.MiddlewareFunc) uintptr {
+	return reflect.ValueOf(mw).Pointer()
+}
+
+func requiresAuth(route router.Route) bool {
+	for _, mw := range route.Middlewares {
+		if mw != nil && securedMiddlewares[funcPointer(mw)] {
+			return true
+		}
+	}
+	return false
+}
+
+// The document model lives in router/openapi; these aliases keep the docs
+// API self-contained.
+type (
+	Info           = openapi.Info
+	Spec           = openapi.Spec
+	Server         = openapi.Server
+	Components     = openapi.Components
+	SecurityScheme = openapi.SecurityScheme
+	PathItem       = openapi.PathItem
+	Operation      = openapi.Operation
+	Parameter      = openapi.Parameter
+	RequestBody    = openapi.RequestBody
+	Reply          = openapi.Response
+	MediaType      = openapi.MediaType
+	Schema         = openapi.Schema
+)
+
+// Generate builds an OpenAPI 3 document from the routes registered on r, as
+// described by Router.OpenAPISpec, refined by the descriptions added with
+// Describe.
+func Generate(r *router.Router, info Info) *Spec {
+	spec := r.OpenAPISpec(info)
+	spec.Components = &Components{
+		SecuritySchemes: map[string]SecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+
+	schemas := newSchemaBuilder()
+
+	descriptionsMu.RLock()
+	defer descriptionsMu.RUnlock()
+
+	for _, route := range r.Routes() {
+		op := spec.Paths[route.Path].Operation(route.Method)
+		if op == nil {
+			continue
+		}
+		describe(op, route, descriptions[routeKey(route.Method, route.Path)], schemas)
+	}
+
+	if len(schemas.components) > 0 {
+		spec.Components.Schemas = schemas.components
+	}
+	return spec
+}
+
+// describe refines the operation generated by the router with d.
+func describe(op *Operation, route router.Route, d *Description, schemas *schemaBuilder) {
+	if d == nil {
+		d = &Description{responses: make(map[int]interface{})}
+	}
+
+	op.Summary = d.summary
+	if len(d.tags) > 0 {
+		op.Tags = d.tags
+	}
+
+	request := d.request
+	if request == nil {
+		request = boundRequest(route)
+	}
+	if request != nil {
+		schemas.typePathParameters(op, request)
+		if route.Method == http.MethodGet || route.Method == http.MethodDelete {
+			op.Parameters = append(op.Parameters, schemas.queryParameters(request)...)
+		} else {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: schemas.schemaFor(reflect.TypeOf(request))}},
+			}
+		}
+	}
+
+	if len(d.responses) > 0 {
+		op.Responses = make(map[string]*Reply)
+	}
+	statuses := make([]int, 0, len(d.responses))
+	for status := range d.responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		reply := &Reply{Description: http.StatusText(status)}
+		if v := d.responses[status]; v != nil {
+			reply.Content = map[string]MediaType{"application/json": {Schema: envelope(schemas.schemaFor(reflect.TypeOf(v)))}}
+		}
+		op.Responses[strconv.Itoa(status)] = reply
+	}
+
+	secured := requiresAuth(route)
+	if d.secured != nil {
+		secured = *d.secured
+	}
+	if secured {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		if _, ok := op.Responses["401"]; !ok {
+			op.Responses["401"] = &Reply{Description: http.StatusText(http.StatusUnauthorized)}
+		}
+	}
+}
+
+// boundRequest finds the DTO of a middleware.ValidateBody on the route.
+func boundRequest(route router.Route) interface{} {
+	for _, mw := range route.Middlewares {
+		if v, ok := middleware.RequestSchema(mw); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// envelope wraps data in the shape written by the response package.
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success":   {Type: "boolean"},
+			"message":   {Type: "string"},
+			"data":      data,
+			"timestamp": {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"success", "timestamp"},
+	}
+}
@@ -0,0 +1,96 @@
+package docs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/docs"
+	"flugo.com/middleware"
+	"flugo.com/router"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.golden.json")
+
+type address struct {
+	Street  string `json:"street" required:"true"`
+	Country string `json:"country" min_length:"2" max_length:"2"`
+}
+
+type user struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email" email:"true"`
+	Roles     []string  `json:"roles"`
+	Address   *address  `json:"address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type createUserRequest struct {
+	Email    string            `json:"email" required:"true" email:"true"`
+	Password string            `json:"password" required:"true" password_strength:"3"`
+	Role     string            `json:"role" enum:"admin,editor,viewer"`
+	Age      int               `json:"age" min:"13" max:"130"`
+	Tags     []string          `json:"tags" max_items:"5"`
+	Address  address           `json:"address"`
+	Meta     map[string]string `json:"meta"`
+	internal string
+}
+
+type listUsersRequest struct {
+	Page   int    `query:"page" json:"page" min:"1"`
+	Search string `query:"q" json:"search" max_length:"100"`
+}
+
+type updateUserRequest struct {
+	ID   int    `param:"id" json:"id" min:"1"`
+	Name string `json:"name" required:"true" alpha:"true"`
+}
+
+func noop(w http.ResponseWriter, r *http.Request) {}
+
+// TestGenerateGolden compares a spec covering the generator's features with
+// testdata/openapi.golden.json. Run with -update after an intended change
+// and review the diff of the golden file.
+func TestGenerateGolden(t *testing.T) {
+	r := router.NewRouter(nil)
+	r.GET("/users", noop, middleware.ValidateBody(listUsersRequest{}))
+	r.POST("/users", noop, auth.RequireAuth())
+	r.GET("/users/{id}", noop)
+	r.PUT("/users/{id}", noop, auth.RequireRoles("admin"), middleware.ValidateBody(updateUserRequest{}))
+	r.DELETE("/users/{id}", noop, auth.RequireScopes("users:write"))
+	r.GET("/health", noop)
+
+	docs.Describe("GET /users", docs.Summary("List users"), docs.Tags("users"), docs.Response(200, []user{}))
+	docs.Describe("POST /users", docs.Summary("Create a user"), docs.Tags("users"),
+		docs.Request(createUserRequest{}), docs.Response(201, user{}), docs.Response(422, nil))
+	docs.Describe("GET /users/{id}", docs.Tags("users"), docs.Response(200, &user{}), docs.Secured(true))
+	docs.Describe("DELETE /users/{id}", docs.Tags("users"), docs.Response(204, nil))
+
+	spec := docs.Generate(r, docs.Info{Title: "Flugo API", Version: "1.0.0", Description: "Golden test"})
+	got, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", "openapi.golden.json")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("spec differs from %s; run go test -update and review the diff. Got:\n%s", path, got)
+	}
+}
@@ -0,0 +1,267 @@
+package docs
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/validator"
+)
+
+// schemaBuilder turns Go types into JSON Schema. Named structs are emitted
+// once under components/schemas and referenced elsewhere.
+type schemaBuilder struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := b.components[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// Register before recursing so self-referencing types terminate.
+	b.names[t] = name
+	b.components[name] = &Schema{}
+	*b.components[name] = *b.structSchema(t)
+	return name
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.addFields(schema, t)
+	if len(schema.Properties) == 0 {
+		schema.Properties = nil
+	}
+	return schema
+}
+
+func (b *schemaBuilder) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(schema, embedded)
+				continue
+			}
+		}
+
+		prop := b.schemaFor(field.Type)
+		if prop.Ref == "" {
+			applyRules(prop, field)
+		}
+		schema.Properties[name] = prop
+
+		if field.Tag.Get("required") == "true" {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// queryParameters documents the fields of a struct as query parameters.
+func (b *schemaBuilder) queryParameters(v interface{}) []Parameter {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+		if query := field.Tag.Get("query"); query != "" {
+			name = query
+		} else if field.Tag.Get("param") != "" {
+			continue
+		}
+
+		schema := b.schemaFor(field.Type)
+		applyRules(schema, field)
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: field.Tag.Get("required") == "true",
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// typePathParameters replaces the string schema the router gives path
+// parameters with the type and rules of the DTO field bound to each by a
+// param tag.
+func (b *schemaBuilder) typePathParameters(op *Operation, v interface{}) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("param")
+		if name == "" || name == "-" {
+			continue
+		}
+		for j := range op.Parameters {
+			if param := &op.Parameters[j]; param.In == "path" && param.Name == name {
+				param.Schema = b.schemaFor(field.Type)
+				applyRules(param.Schema, field)
+			}
+		}
+	}
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// applyRules maps the validator package's struct tags onto schema keywords.
+func applyRules(schema *Schema, field reflect.StructField) {
+	tag := field.Tag
+	schema.MinLength = tagInt(tag, "min_length")
+	schema.MaxLength = tagInt(tag, "max_length")
+	schema.MinItems = tagInt(tag, "min_items")
+	schema.MaxItems = tagInt(tag, "max_items")
+	schema.Minimum = tagFloat(tag, "min")
+	schema.Maximum = tagFloat(tag, "max")
+
+	switch {
+	case tag.Get("email") == "true":
+		schema.Format = "email"
+	case tag.Get("url") == "true":
+		schema.Format = "uri"
+	case tag.Get("ip") == "true":
+		schema.Format = "ip"
+	}
+
+	switch {
+	case tag.Get("regex") != "":
+		schema.Pattern = tag.Get("regex")
+	case tag.Get("alpha") == "true":
+		schema.Pattern = `^[a-zA-Z]+$`
+	case tag.Get("alphanumeric") == "true":
+		schema.Pattern = `^[a-zA-Z0-9]+$`
+	case tag.Get("numeric") == "true":
+		schema.Pattern = `^[0-9]+$`
+	case tag.Get("phone") == "true":
+		schema.Pattern = `^[\+]?[\d\s\-\(\)]{7,15}$`
+	}
+
+	if layout := tag.Get("date"); layout != "" {
+		schema.Format = "date-time"
+		if layout == "2006-01-02" {
+			schema.Format = "date"
+		}
+	}
+
+	if spec := tag.Get("enum"); spec != "" {
+		schema.Enum = validator.EnumValues(spec, field.Type)
+	}
+
+	if score := tag.Get("password_strength"); score != "" {
+		schema.Format = "password"
+		schema.Description = "Minimum password strength score " + score + " of 4"
+	}
+}
+
+func tagInt(tag reflect.StructTag, key string) *int {
+	n, err := strconv.Atoi(tag.Get(key))
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+func tagFloat(tag reflect.StructTag, key string) *float64 {
+	f, err := strconv.ParseFloat(tag.Get(key), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,65 @@
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+
+	"flugo.com/reqctx"
+	"flugo.com/router"
+)
+
+// Mount serves the generated spec at /openapi.json and Swagger UI at /docs.
+// The spec is rebuilt on each request, so routes added later are included.
+func Mount(r *router.Router, info Info) {
+	r.GET("/openapi.json", SpecHandler(r, info))
+	r.GET("/docs", UIHandler("/openapi.json", info.Title))
+}
+
+// SpecHandler serves the spec with the URL the client used as its server,
+// so "Try it out" calls go through the same proxy.
+func SpecHandler(r *router.Router, info Info) router.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		spec := Generate(r, info)
+		spec.Servers = []Server{{URL: reqctx.BaseURL(req)}}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(spec)
+	}
+}
+
+// UIHandler serves a Swagger UI page that loads the spec from specURL.
+func UIHandler(specURL, title string) router.HandlerFunc {
+	if title == "" {
+		title = "API Documentation"
+	}
+	page := fmt.Sprintf(swaggerUIPage, html.EscapeString(title), specURL)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>%s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: %q, dom_id: "#swagger-ui", persistAuthorization: true });
+    };
+  </script>
+</body>
+</html>
+`
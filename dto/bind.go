@@ -0,0 +1,175 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/validator"
+)
+
+// Bind fills target from every part of the request, then validates it:
+//
+//	type UpdateUserRequest struct {
+//		ID   int    `param:"id" min:"1"`
+//		Name string `json:"name" required:"true"`
+//	}
+//
+// The JSON body is decoded first, when there is one. Query parameters,
+// named as for BindQuery, override body values, and route parameters,
+// named by a param tag, override both. A route parameter that differs from
+// a value the body or query gave for the same field is a conflict: the
+// route names the resource, so the request is rejected rather than one of
+// them silently winning. Values that do not convert to the field's type
+// and conflicts are reported as validator.ValidationErrors, so they answer
+// 422 like failed rules; a malformed body is a *response.BindError.
+func Bind(r *http.Request, target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a pointer to struct")
+	}
+
+	bodyKeys, err := bindBody(r, target)
+	if err != nil {
+		return err
+	}
+
+	val = val.Elem()
+	typ := val.Type()
+	query := r.URL.Query()
+
+	var errs validator.ValidationErrors
+	failed := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := val.Field(i)
+		name := fieldName(field)
+
+		source := ""
+		if bodyKeys[strings.ToLower(name)] {
+			source = "body"
+		}
+
+		if qname := bindQueryName(field); qname != "" {
+			if values, ok := query[qname]; ok && len(values) > 0 {
+				if bindErr := setFrom(fv, "query", qname, values); bindErr != nil {
+					errs = append(errs, *bindErr)
+					failed[name] = true
+					continue
+				}
+				source = "query"
+			}
+		}
+
+		pname := field.Tag.Get("param")
+		if pname == "" || pname == "-" {
+			continue
+		}
+		value, ok := router.ParamValue(r, pname)
+		if !ok {
+			continue
+		}
+
+		previous := reflect.New(fv.Type()).Elem()
+		previous.Set(fv)
+		if bindErr := setFrom(fv, "path", pname, []string{value}); bindErr != nil {
+			errs = append(errs, *bindErr)
+			failed[name] = true
+			continue
+		}
+		if source != "" && !reflect.DeepEqual(previous.Interface(), fv.Interface()) {
+			errs = append(errs, validator.FieldError(pname, validator.CodeConflict, value, map[string]interface{}{
+				"source":      "path",
+				"value":       value,
+				"other":       source,
+				"other_value": fmt.Sprint(previous.Interface()),
+			}))
+			failed[name] = true
+		}
+	}
+
+	if err := validator.Validate(target); err != nil {
+		var ruleErrs validator.ValidationErrors
+		if !errors.As(err, &ruleErrs) {
+			return err
+		}
+		// A field that could not be bound is only reported once.
+		for _, ruleErr := range ruleErrs {
+			if !failed[ruleErr.Field] {
+				errs = append(errs, ruleErr)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// bindBody decodes a JSON body into target and returns the lower-cased
+// top-level keys it set. Requests without a body bind nothing.
+func bindBody(r *http.Request, target interface{}) (map[string]bool, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, &response.BindError{Status: http.StatusBadRequest, Message: "Failed to read request body", Err: err}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err := response.BindJSON(r, target); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	json.Unmarshal(data, &raw)
+	keys := make(map[string]bool, len(raw))
+	for key := range raw {
+		keys[strings.ToLower(key)] = true
+	}
+	return keys, nil
+}
+
+// bindQueryName is the query parameter of a field for Bind. Fields bound
+// from the route are only read from the query when given a query tag.
+func bindQueryName(field reflect.StructField) string {
+	if field.Tag.Get("param") != "" && field.Tag.Get("query") == "" {
+		return ""
+	}
+	return queryName(field)
+}
+
+// fieldName is the name validator reports a field by: its json name, else
+// its Go name.
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func setFrom(field reflect.Value, source, name string, values []string) *validator.ValidationError {
+	if err := setQueryField(field, values); err != nil {
+		fieldErr := validator.FieldError(name, validator.CodeType, values[len(values)-1], map[string]interface{}{
+			"source": source,
+			"param":  name,
+			"reason": err.Error(),
+		})
+		return &fieldErr
+	}
+	return nil
+}
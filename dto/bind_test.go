@@ -0,0 +1,104 @@
+package dto_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"flugo.com/container"
+	"flugo.com/docs"
+	"flugo.com/dto"
+	"flugo.com/middleware"
+	"flugo.com/router"
+)
+
+type updateUserRequest struct {
+	ID     int    `param:"id" json:"id" min:"1"`
+	Name   string `json:"name" required:"true"`
+	Notify bool   `json:"notify" query:"notify"`
+}
+
+func newUserRouter(got *updateUserRequest) *router.Router {
+	r := router.NewRouter(container.NewContainer())
+	r.PUT("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+		*got = dto.FromContext[updateUserRequest](req)
+	}, middleware.ValidateBody(updateUserRequest{}))
+	return r
+}
+
+func put(r *router.Router, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBindMergesSources(t *testing.T) {
+	var got updateUserRequest
+	r := newUserRouter(&got)
+
+	w := put(r, "/users/5?notify=true", `{"name":"Ann","notify":false}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	want := updateUserRequest{ID: 5, Name: "Ann", Notify: true}
+	if got != want {
+		t.Errorf("bound %+v, want %+v with the query overriding the body", got, want)
+	}
+
+	// The body repeating the path's value is not a conflict.
+	if w := put(r, "/users/5", `{"id":5,"name":"Ann"}`); w.Code != http.StatusOK {
+		t.Errorf("matching id in body: status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	var got updateUserRequest
+	r := newUserRouter(&got)
+
+	for name, tc := range map[string]struct {
+		path, body string
+		code       string
+	}{
+		"non-numeric id": {"/users/abc", `{"name":"Ann"}`, "type"},
+		"rule on param":  {"/users/-3", `{"name":"Ann"}`, "min"},
+		"conflict":       {"/users/5", `{"id":6,"name":"Ann"}`, "conflict"},
+	} {
+		w := put(r, tc.path, tc.body)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want 422: %s", name, w.Code, w.Body.String())
+			continue
+		}
+
+		var body struct {
+			Errors []struct {
+				Field string `json:"field"`
+				Code  string `json:"code"`
+			} `json:"errors"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if len(body.Errors) != 1 || body.Errors[0].Field != "id" || body.Errors[0].Code != tc.code {
+			t.Errorf("%s: errors %+v, want one %s error on id", name, body.Errors, tc.code)
+		}
+	}
+}
+
+func TestPathParametersDocumented(t *testing.T) {
+	var got updateUserRequest
+	spec := docs.Generate(newUserRouter(&got), docs.Info{Title: "test"})
+
+	op := spec.Paths["/users/{id}"].Put
+	var params []string
+	for _, param := range op.Parameters {
+		params = append(params, param.In+":"+param.Name)
+		if param.In == "path" && (param.Schema.Type != "integer" || param.Schema.Minimum == nil || *param.Schema.Minimum != 1) {
+			t.Errorf("path parameter schema = %+v", param.Schema)
+		}
+	}
+	if strings.Join(params, ",") != "path:id" {
+		t.Errorf("parameters = %v", params)
+	}
+}
@@ -0,0 +1,33 @@
+package dto
+
+import (
+	"context"
+	"net/http"
+)
+
+type valueKey struct{}
+
+// WithValue stores a bound DTO on the request for FromContext.
+// middleware.ValidateBody stores a pointer to a new value of its DTO type.
+func WithValue(r *http.Request, v interface{}) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), valueKey{}, v))
+}
+
+// FromContext returns the DTO bound by middleware.ValidateBody, either as
+// the struct or as a pointer to it:
+//
+//	req := dto.FromContext[CreateUserRequest](r)
+//
+// It returns the zero value when the request carries no DTO of type T.
+func FromContext[T any](r *http.Request) T {
+	var zero T
+	switch v := r.Context().Value(valueKey{}).(type) {
+	case T:
+		return v
+	case *T:
+		if v != nil {
+			return *v
+		}
+	}
+	return zero
+}
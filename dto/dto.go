@@ -1,18 +1,18 @@
 package dto
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 
+	"flugo.com/i18n"
 	"flugo.com/response"
 	"flugo.com/validator"
 )
 
+// BindJSON decodes with response.BindJSON, so decoding failures are
+// *response.BindError values, then validates target.
 func BindJSON(r *http.Request, target interface{}) error {
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(target); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
+	if err := response.BindJSON(r, target); err != nil {
+		return err
 	}
 	return validator.Validate(target)
 }
@@ -23,6 +23,9 @@ func BindAndValidate(r *http.Request, target interface{}) error {
 
 func HandleValidationError(w http.ResponseWriter, err error) bool {
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		if i18n.Loaded() {
+			validationErrors = validationErrors.Localize(i18n.WriterLocale(w))
+		}
 		response.ValidationError(w, "Validation failed", validationErrors)
 		return true
 	}
@@ -32,7 +35,7 @@ func HandleValidationError(w http.ResponseWriter, err error) bool {
 func BindAndRespond(w http.ResponseWriter, r *http.Request, target interface{}) bool {
 	if err := BindJSON(r, target); err != nil {
 		if !HandleValidationError(w, err) {
-			response.BadRequest(w, "Invalid JSON format", err.Error())
+			response.InvalidBody(w, err)
 		}
 		return false
 	}
@@ -0,0 +1,142 @@
+package dto
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/response"
+	"flugo.com/validator"
+)
+
+// BindQuery fills the exported fields of the struct target points to from
+// the URL query, then validates it. A field is read from the parameter
+// named by its query tag, else its json name, else its Go name. Strings,
+// booleans, numbers, time.Time (RFC 3339) and slices of them are supported;
+// slices take repeated parameters or a comma-separated list. Conversion
+// failures are *response.BindError values with status 400.
+func BindQuery(r *http.Request, target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a pointer to struct")
+	}
+	val = val.Elem()
+	typ := val.Type()
+	query := r.URL.Query()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := queryName(field)
+		if name == "" {
+			continue
+		}
+		values, ok := query[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if err := setQueryField(val.Field(i), values); err != nil {
+			return &response.BindError{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("query parameter %q %s", name, err),
+				Field:   name,
+				Err:     err,
+			}
+		}
+	}
+
+	return validator.Validate(target)
+}
+
+func queryName(field reflect.StructField) string {
+	if name := field.Tag.Get("query"); name != "" {
+		if name == "-" {
+			return ""
+		}
+		return name
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func setQueryField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setQueryField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		var items []string
+		for _, v := range values {
+			items = append(items, strings.Split(v, ",")...)
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setQueryValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setQueryValue(field, values[len(values)-1])
+}
+
+func setQueryValue(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("must be an RFC 3339 time")
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a non-negative integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("has unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package email
+
+import "context"
+
+type serviceKey struct{}
+
+// ContextWithService makes FromContext return es instead of
+// DefaultEmailService.
+func ContextWithService(ctx context.Context, es *EmailService) context.Context {
+	return context.WithValue(ctx, serviceKey{}, es)
+}
+
+// FromContext returns the service attached by ContextWithService, or
+// DefaultEmailService.
+func FromContext(ctx context.Context) *EmailService {
+	if es, ok := ctx.Value(serviceKey{}).(*EmailService); ok && es != nil {
+		return es
+	}
+	return DefaultEmailService
+}
@@ -2,12 +2,18 @@ package email
 
 import (
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"html/template"
+	"mime"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
 	"strings"
+	"time"
 
 	"flugo.com/logger"
+	"flugo.com/utils"
 )
 
 type EmailConfig struct {
@@ -20,6 +26,10 @@ type EmailConfig struct {
 	ReplyTo    string `json:"reply_to"`
 	EnableSSL  bool   `json:"enable_ssl"`
 	EnableAuth bool   `json:"enable_auth"`
+	// MissingKey is the template option for variables absent from the data:
+	// "error" (the default) fails the send, "zero" or "default" render
+	// them as empty or "<no value>" without checking the data.
+	MissingKey string `json:"missing_key"`
 }
 
 type Email struct {
@@ -31,6 +41,9 @@ type Email struct {
 	HTMLBody    string
 	Attachments []Attachment
 	Headers     map[string]string
+	// Force sends to suppressed addresses too; keep it for mail the
+	// account depends on, such as password resets.
+	Force bool
 }
 
 type Attachment struct {
@@ -40,50 +53,122 @@ type Attachment struct {
 }
 
 type EmailService struct {
-	config *EmailConfig
-	auth   smtp.Auth
+	config    *EmailConfig
+	auth      smtp.Auth
+	dkim      DKIMSigner
+	transport Transport
 }
 
+var errNoRecipients = errors.New("no recipients specified")
+
 var DefaultEmailService *EmailService
 
+// Init also checks the templates registered so far; see CheckTemplates.
 func Init(cfg *EmailConfig) {
 	DefaultEmailService = NewEmailService(cfg)
+	if err := CheckTemplates(); err != nil {
+		logger.Error("Email templates do not match their data: %v", err)
+	}
 }
 
 func NewEmailService(cfg *EmailConfig) *EmailService {
+	switch cfg.MissingKey {
+	case "", "error", "zero", "default", "invalid":
+	default:
+		logger.Warn("Unknown email missing_key %q, using \"error\"", cfg.MissingKey)
+	}
+
 	var auth smtp.Auth
 	if cfg.EnableAuth {
 		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)
 	}
 
 	return &EmailService{
-		config: cfg,
-		auth:   auth,
+		config:    cfg,
+		auth:      auth,
+		transport: newSMTPTransport(cfg, auth),
 	}
 }
 
+func newSMTPTransport(cfg *EmailConfig, auth smtp.Auth) Transport {
+	return &smtpTransport{addr: fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort), host: cfg.SMTPHost, auth: auth}
+}
+
+// SetTransport replaces SMTP delivery, e.g. with a fake in tests; nil
+// restores SMTP.
+func (es *EmailService) SetTransport(transport Transport) {
+	if transport == nil {
+		transport = newSMTPTransport(es.config, es.auth)
+	}
+	es.transport = transport
+}
+
+// SetDKIMSigner signs every message sent from now on; nil disables signing.
+func (es *EmailService) SetDKIMSigner(signer DKIMSigner) {
+	es.dkim = signer
+}
+
 func (es *EmailService) Send(email *Email) error {
+	_, err := es.Deliver(email)
+	return err
+}
+
+// Deliver sends email and returns its Message-ID. When some recipients were
+// refused, the error is a *RecipientsError and the ID is returned as long as
+// the message reached the others.
+func (es *EmailService) Deliver(email *Email) (string, error) {
 	if len(email.To) == 0 {
-		return fmt.Errorf("no recipients specified")
+		return "", errNoRecipients
 	}
 
-	message := es.buildMessage(email)
+	email, suppressed := filterSuppressed(email)
+	if len(email.To) == 0 {
+		logger.Info("Email not sent: every recipient is suppressed")
+		return "", &RecipientsError{Rejected: suppressed}
+	}
 
-	addr := fmt.Sprintf("%s:%d", es.config.SMTPHost, es.config.SMTPPort)
-	recipients := append(email.To, email.CC...)
+	messageID := es.newMessageID()
+	message, err := es.buildMessage(email, messageID)
+	if err != nil {
+		logger.Error("Failed to build email: %v", err)
+		return "", err
+	}
+
+	recipients := make([]string, 0, len(email.To)+len(email.CC)+len(email.BCC))
+	recipients = append(recipients, email.To...)
+	recipients = append(recipients, email.CC...)
 	recipients = append(recipients, email.BCC...)
 
-	err := smtp.SendMail(addr, es.auth, es.config.FromEmail, recipients, message)
-	if err != nil {
+	if err := es.transport.Send(es.config.FromEmail, recipients, message); err != nil {
 		logger.Error("Failed to send email: %v", err)
-		return err
+		var recipientsErr *RecipientsError
+		if errors.As(err, &recipientsErr) {
+			for addr, reason := range suppressed {
+				recipientsErr.Rejected[addr] = reason
+			}
+			if recipientsErr.Delivered {
+				return messageID, recipientsErr
+			}
+		}
+		return "", err
 	}
 
 	logger.Info("Email sent successfully to %v", email.To)
-	return nil
+	if len(suppressed) > 0 {
+		return messageID, &RecipientsError{Rejected: suppressed, Delivered: true}
+	}
+	return messageID, nil
+}
+
+func (es *EmailService) newMessageID() string {
+	domain := "localhost"
+	if at := strings.LastIndex(es.config.FromEmail, "@"); at >= 0 && at < len(es.config.FromEmail)-1 {
+		domain = es.config.FromEmail[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", utils.NewID(), domain)
 }
 
-func (es *EmailService) buildMessage(email *Email) []byte {
+func (es *EmailService) buildMessage(email *Email, messageID string) ([]byte, error) {
 	var buffer bytes.Buffer
 
 	// Headers
@@ -99,6 +184,8 @@ func (es *EmailService) buildMessage(email *Email) []byte {
 	}
 
 	buffer.WriteString(fmt.Sprintf("Subject: %s\r\n", email.Subject))
+	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	buffer.WriteString(fmt.Sprintf("Message-ID: %s\r\n", messageID))
 
 	// Custom headers
 	for key, value := range email.Headers {
@@ -107,37 +194,103 @@ func (es *EmailService) buildMessage(email *Email) []byte {
 
 	buffer.WriteString("MIME-Version: 1.0\r\n")
 
-	if email.HTMLBody != "" {
-		// HTML email
-		buffer.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
-		buffer.WriteString(email.HTMLBody)
+	contentType, body := messageBody(email)
+	if len(email.Attachments) == 0 {
+		buffer.WriteString(fmt.Sprintf("Content-Type: %s\r\n\r\n", contentType))
+		buffer.Write(body)
 	} else {
-		// Plain text email
-		buffer.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
-		buffer.WriteString(email.Body)
+		mixed := multipart.NewWriter(&buffer)
+		buffer.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary()))
+
+		part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+		if err != nil {
+			return nil, err
+		}
+		part.Write(body)
+
+		for _, attachment := range email.Attachments {
+			if err := writeAttachment(mixed, attachment); err != nil {
+				return nil, err
+			}
+		}
+		if err := mixed.Close(); err != nil {
+			return nil, err
+		}
 	}
 
-	return buffer.Bytes()
+	if es.dkim != nil {
+		return es.dkim.Sign(buffer.Bytes())
+	}
+	return buffer.Bytes(), nil
 }
 
-func (es *EmailService) SendTemplate(templateName string, data interface{}, email *Email) error {
-	tmpl, err := template.New(templateName).Parse(getTemplate(templateName))
+// messageBody returns the content type and body of the text of email: both
+// bodies as multipart/alternative when it has both, otherwise the one set.
+func messageBody(email *Email) (string, []byte) {
+	switch {
+	case email.HTMLBody != "" && email.Body != "":
+		var buffer bytes.Buffer
+		alternative := multipart.NewWriter(&buffer)
+		for _, text := range []struct{ contentType, body string }{
+			{"text/plain; charset=UTF-8", email.Body},
+			{"text/html; charset=UTF-8", email.HTMLBody},
+		} {
+			part, _ := alternative.CreatePart(textproto.MIMEHeader{"Content-Type": {text.contentType}})
+			part.Write([]byte(text.body))
+		}
+		alternative.Close()
+		return "multipart/alternative; boundary=" + alternative.Boundary(), buffer.Bytes()
+	case email.HTMLBody != "":
+		return "text/html; charset=UTF-8", []byte(email.HTMLBody)
+	default:
+		return "text/plain; charset=UTF-8", []byte(email.Body)
+	}
+}
+
+func writeAttachment(w *multipart.Writer, attachment Attachment) error {
+	mimeType := attachment.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mimeType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return err
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+	encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+	for len(encoded) > 76 {
+		part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
 	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
 
-	email.HTMLBody = buf.String()
+func (es *EmailService) SendTemplate(templateName string, data interface{}, email *Email) error {
+	body, err := es.RenderTemplate(templateName, data)
+	if err != nil {
+		return err
+	}
+
+	email.HTMLBody = body
 	return es.Send(email)
 }
 
-func getTemplate(name string) string {
-	templates := map[string]string{
-		"welcome": `
+func init() {
+	for name, source := range builtinTemplates {
+		if err := RegisterTemplate(name, source); err != nil {
+			panic(err)
+		}
+	}
+}
+
+var builtinTemplates = map[string]string{
+	"welcome": `
 <!DOCTYPE html>
 <html>
 <head>
@@ -170,7 +323,7 @@ func getTemplate(name string) string {
 </body>
 </html>`,
 
-		"reset_password": `
+	"reset_password": `
 <!DOCTYPE html>
 <html>
 <head>
@@ -207,7 +360,7 @@ func getTemplate(name string) string {
 </body>
 </html>`,
 
-		"notification": `
+	"notification": `
 <!DOCTYPE html>
 <html>
 <head>
@@ -239,12 +392,6 @@ func getTemplate(name string) string {
     </div>
 </body>
 </html>`,
-	}
-
-	if tmpl, exists := templates[name]; exists {
-		return tmpl
-	}
-	return templates["notification"]
 }
 
 func Send(email *Email) error {
@@ -288,6 +435,7 @@ func SendPasswordReset(to, name, appName, resetLink string, expirationMinutes in
 	email := &Email{
 		To:      []string{to},
 		Subject: "Reset Your Password",
+		Force:   true,
 	}
 
 	return SendTemplate("reset_password", data, email)
@@ -309,20 +457,43 @@ func SendNotification(to, name, title, message, appName string) error {
 	return SendTemplate("notification", data, email)
 }
 
-func SendBulk(emails []*Email) error {
+// BulkResult lists the outcome of SendBulk.
+type BulkResult struct {
+	Sent int
+	// Suppressed are the recipients skipped because they are on the
+	// suppression list.
+	Suppressed []string
+}
+
+// SendBulk sends each email in turn and stops at the first failure.
+// Suppressed recipients are skipped, as is an email with no others.
+func SendBulk(emails []*Email) (*BulkResult, error) {
 	if DefaultEmailService == nil {
-		return fmt.Errorf("email service not initialized")
+		return nil, fmt.Errorf("email service not initialized")
 	}
 
+	result := &BulkResult{}
 	for i, email := range emails {
-		if err := DefaultEmailService.Send(email); err != nil {
+		_, err := DefaultEmailService.Deliver(email)
+		var recipientsErr *RecipientsError
+		if errors.As(err, &recipientsErr) && recipientsErr.onlySuppressed() {
+			for addr := range recipientsErr.Rejected {
+				result.Suppressed = append(result.Suppressed, addr)
+			}
+			if recipientsErr.Delivered {
+				result.Sent++
+			}
+			continue
+		}
+		if err != nil {
 			logger.Error("Failed to send bulk email %d: %v", i, err)
-			return err
+			return result, err
 		}
+		result.Sent++
 	}
 
-	logger.Info("Successfully sent %d bulk emails", len(emails))
-	return nil
+	logger.Info("Successfully sent %d bulk emails", result.Sent)
+	return result, nil
 }
 
 func ValidateEmail(email string) bool {
@@ -0,0 +1,335 @@
+package email
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"flugo.com/database"
+	"flugo.com/database/schema"
+	"flugo.com/logger"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// Suppression reasons recorded by RecordBounce.
+const (
+	ReasonHardBounce = "hard_bounce"
+	ReasonSoftBounce = "soft_bounce"
+	ReasonComplaint  = "complaint"
+)
+
+// SoftBounceSuppression is how long an address stays suppressed after a
+// soft bounce, such as a full mailbox.
+var SoftBounceSuppression = 24 * time.Hour
+
+// ErrSuppressed is the error of a recipient skipped because it is on the
+// suppression list. Deliver reports skipped recipients in a
+// *RecipientsError, and they are not retried.
+var ErrSuppressed = errors.New("recipient is suppressed")
+
+// Suppression holds back mail to an address, until ExpiresAt when it is
+// set.
+type Suppression struct {
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func (s *Suppression) expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// SuppressionStore keeps suppressions by normalized address. Get returns
+// nil for addresses that are not suppressed, expired ones included.
+type SuppressionStore interface {
+	Add(s Suppression) error
+	Get(address string) (*Suppression, error)
+	Remove(address string) error
+}
+
+// DefaultSuppressionStore is checked by every EmailService. It keeps
+// suppressions in memory until replaced, e.g. with DatabaseSuppressions.
+var DefaultSuppressionStore SuppressionStore = NewMemorySuppressionStore()
+
+// normalizeAddress reduces "Name <User@Example.com>" to user@example.com.
+func normalizeAddress(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+type memorySuppressionStore struct {
+	mu      sync.Mutex
+	entries map[string]Suppression
+}
+
+func NewMemorySuppressionStore() SuppressionStore {
+	return &memorySuppressionStore{entries: make(map[string]Suppression)}
+}
+
+func (m *memorySuppressionStore) Add(s Suppression) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[s.Address] = s
+	return nil
+}
+
+func (m *memorySuppressionStore) Get(address string) (*Suppression, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.entries[address]
+	if !ok {
+		return nil, nil
+	}
+	if s.expired(time.Now()) {
+		delete(m.entries, address)
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (m *memorySuppressionStore) Remove(address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, address)
+	return nil
+}
+
+// DBSuppressionStore keeps suppressions in the email_suppressions table.
+type DBSuppressionStore struct {
+	db *database.DB
+}
+
+// SuppressionsMigration creates the email_suppressions table of
+// DBSuppressionStore. Register it with database.RegisterMigration and run
+// the migrations before using the store.
+var SuppressionsMigration = schema.Migration("20240201000004", "create_email_suppressions",
+	schema.CreateIfNotExists("email_suppressions", func(t *schema.Table) {
+		t.String("address", 320)
+		t.String("reason", 64)
+		t.Timestamp("created_at")
+		t.Timestamp("expires_at").Nullable()
+		t.Primary("address")
+	}),
+	schema.DropIfExists("email_suppressions"))
+
+// DatabaseSuppressions stores suppressions in db, or in DefaultDB when db
+// is nil.
+func DatabaseSuppressions(db *database.DB) *DBSuppressionStore {
+	return &DBSuppressionStore{db: db}
+}
+
+func (s *DBSuppressionStore) Add(sup Suppression) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+
+	var expires interface{}
+	if !sup.ExpiresAt.IsZero() {
+		expires = sup.ExpiresAt
+	}
+	if _, err := db.Exec("DELETE FROM email_suppressions WHERE address = ?", sup.Address); err != nil {
+		return fmt.Errorf("failed to suppress address: %w", err)
+	}
+	_, err = db.Exec("INSERT INTO email_suppressions (address, reason, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		sup.Address, sup.Reason, sup.CreatedAt, expires)
+	if err != nil {
+		return fmt.Errorf("failed to suppress address: %w", err)
+	}
+	return nil
+}
+
+func (s *DBSuppressionStore) Get(address string) (*Suppression, error) {
+	db, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	sup := Suppression{Address: address}
+	var expires sql.NullTime
+	err = db.QueryRow("SELECT reason, created_at, expires_at FROM email_suppressions WHERE address = ?", address).
+		Scan(&sup.Reason, &sup.CreatedAt, &expires)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up suppression: %w", err)
+	}
+	if expires.Valid {
+		sup.ExpiresAt = expires.Time
+	}
+	if sup.expired(time.Now()) {
+		return nil, nil
+	}
+	return &sup, nil
+}
+
+func (s *DBSuppressionStore) Remove(address string) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM email_suppressions WHERE address = ?", address); err != nil {
+		return fmt.Errorf("failed to remove suppression: %w", err)
+	}
+	return nil
+}
+
+func (s *DBSuppressionStore) conn() (*database.DB, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+	if database.DefaultDB == nil {
+		return nil, database.ErrNotInitialized
+	}
+	return database.DefaultDB, nil
+}
+
+// Suppress stops mail to address until Unsuppress.
+func Suppress(address, reason string) error {
+	return SuppressFor(address, reason, 0)
+}
+
+// SuppressFor stops mail to address for ttl; 0 is permanent.
+func SuppressFor(address, reason string, ttl time.Duration) error {
+	s := Suppression{Address: normalizeAddress(address), Reason: reason, CreatedAt: time.Now()}
+	if ttl > 0 {
+		s.ExpiresAt = s.CreatedAt.Add(ttl)
+	}
+	return DefaultSuppressionStore.Add(s)
+}
+
+func Unsuppress(address string) error {
+	return DefaultSuppressionStore.Remove(normalizeAddress(address))
+}
+
+// IsSuppressed reports whether mail to address is being held back. Store
+// errors are logged and count as not suppressed, so a database outage does
+// not stop all mail.
+func IsSuppressed(address string) bool {
+	s, err := suppression(address)
+	return err == nil && s != nil
+}
+
+func suppression(address string) (*Suppression, error) {
+	s, err := DefaultSuppressionStore.Get(normalizeAddress(address))
+	if err != nil {
+		logger.Warn("Failed to check email suppression for %s: %v", address, err)
+	}
+	return s, err
+}
+
+// filterSuppressed returns email without its suppressed recipients and the
+// errors for those, or email itself when none are suppressed or it is
+// forced.
+func filterSuppressed(email *Email) (*Email, map[string]error) {
+	if email.Force {
+		return email, nil
+	}
+
+	skipped := make(map[string]error)
+	keep := func(addrs []string) []string {
+		var kept []string
+		for _, addr := range addrs {
+			if s, _ := suppression(addr); s != nil {
+				skipped[addr] = fmt.Errorf("%w: %s", ErrSuppressed, s.Reason)
+				continue
+			}
+			kept = append(kept, addr)
+		}
+		return kept
+	}
+
+	filtered := *email
+	filtered.To, filtered.CC, filtered.BCC = keep(email.To), keep(email.CC), keep(email.BCC)
+	if len(skipped) == 0 {
+		return email, nil
+	}
+	return &filtered, skipped
+}
+
+// Bounce is a delivery failure or complaint reported by a mail provider.
+// Type is "hard", "soft" or "complaint".
+type Bounce struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// RecordBounce suppresses the address of a bounce: permanently for hard
+// bounces and complaints, for SoftBounceSuppression for soft bounces.
+func RecordBounce(b Bounce) error {
+	switch b.Type {
+	case "hard":
+		logger.Info("Suppressing %s after a hard bounce: %s", b.Address, b.Reason)
+		return Suppress(b.Address, ReasonHardBounce)
+	case "complaint":
+		logger.Info("Suppressing %s after a complaint", b.Address)
+		return Suppress(b.Address, ReasonComplaint)
+	case "soft":
+		return SuppressFor(b.Address, ReasonSoftBounce, SoftBounceSuppression)
+	default:
+		return fmt.Errorf("unknown bounce type %q", b.Type)
+	}
+}
+
+// BounceParser reads the bounces in a provider's webhook request.
+type BounceParser func(r *http.Request) ([]Bounce, error)
+
+// JSONBounces parses a JSON Bounce or list of them, the format to convert
+// provider payloads to, e.g. in an inbound-parsing job.
+func JSONBounces(r *http.Request) ([]Bounce, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var bounces []Bounce
+	if err := json.Unmarshal(raw, &bounces); err == nil {
+		return bounces, nil
+	}
+	var bounce Bounce
+	if err := json.Unmarshal(raw, &bounce); err != nil {
+		return nil, err
+	}
+	return []Bounce{bounce}, nil
+}
+
+// BounceWebhookHandler records the bounces parser finds in each request;
+// JSONBounces when parser is nil. Anyone who can call it can suppress
+// addresses, so mount it behind middleware.VerifyHMAC or similar.
+func BounceWebhookHandler(parser BounceParser) router.HandlerFunc {
+	if parser == nil {
+		parser = JSONBounces
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		bounces, err := parser(r)
+		if err != nil {
+			response.BadRequest(w, "Invalid bounce payload")
+			return
+		}
+
+		recorded := 0
+		for _, b := range bounces {
+			if b.Address == "" {
+				continue
+			}
+			if err := RecordBounce(b); err != nil {
+				logger.Warn("Failed to record bounce for %s: %v", b.Address, err)
+				continue
+			}
+			recorded++
+		}
+		response.Success(w, map[string]int{"recorded": recorded})
+	}
+}
@@ -0,0 +1,144 @@
+package email_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/email"
+)
+
+type recordingTransport struct {
+	mu         sync.Mutex
+	recipients [][]string
+}
+
+func (t *recordingTransport) Send(from string, recipients []string, message []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recipients = append(t.recipients, recipients)
+	return nil
+}
+
+func setupSuppression(t *testing.T, store email.SuppressionStore) *recordingTransport {
+	t.Helper()
+	transport := &recordingTransport{}
+	es := email.NewEmailService(&email.EmailConfig{FromEmail: "app@example.com", FromName: "App"})
+	es.SetTransport(transport)
+
+	previousService, previousStore := email.DefaultEmailService, email.DefaultSuppressionStore
+	email.DefaultEmailService, email.DefaultSuppressionStore = es, store
+	t.Cleanup(func() {
+		email.DefaultEmailService, email.DefaultSuppressionStore = previousService, previousStore
+	})
+	return transport
+}
+
+func TestSendSkipsSuppressedRecipients(t *testing.T) {
+	transport := setupSuppression(t, email.NewMemorySuppressionStore())
+	if err := email.Suppress("Bounced <Gone@Example.com>", email.ReasonHardBounce); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := email.DefaultEmailService.Deliver(&email.Email{
+		To:      []string{"ok@example.com", "gone@example.com"},
+		Subject: "Hi",
+		Body:    "Hello",
+	})
+	var recipientsErr *email.RecipientsError
+	if !errors.As(err, &recipientsErr) || !recipientsErr.Delivered || id == "" {
+		t.Fatalf("Deliver() = %q, %v", id, err)
+	}
+	if !errors.Is(recipientsErr.Rejected["gone@example.com"], email.ErrSuppressed) || email.IsRetryable(err) {
+		t.Errorf("rejected = %v", recipientsErr.Rejected)
+	}
+	if got := strings.Join(transport.recipients[0], ","); got != "ok@example.com" {
+		t.Errorf("sent to %s", got)
+	}
+
+	// Everyone suppressed: nothing is sent.
+	if _, err := email.DefaultEmailService.Deliver(&email.Email{To: []string{"gone@example.com"}, Subject: "Hi"}); !errors.As(err, &recipientsErr) || recipientsErr.Delivered {
+		t.Errorf("Deliver() to a suppressed address = %v", err)
+	}
+	if len(transport.recipients) != 1 {
+		t.Errorf("%d messages sent", len(transport.recipients))
+	}
+
+	// Account-critical mail goes out anyway.
+	if err := email.SendPasswordReset("gone@example.com", "Gone", "App", "https://example.com/reset", 30); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.recipients) != 2 {
+		t.Error("password reset to a suppressed address was not sent")
+	}
+}
+
+func TestSendBulkReportsSuppressed(t *testing.T) {
+	transport := setupSuppression(t, email.NewMemorySuppressionStore())
+	email.Suppress("gone@example.com", email.ReasonComplaint)
+
+	result, err := email.SendBulk([]*email.Email{
+		{To: []string{"a@example.com"}, Subject: "News"},
+		{To: []string{"gone@example.com"}, Subject: "News"},
+		{To: []string{"b@example.com", "gone@example.com"}, Subject: "News"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 2 || len(result.Suppressed) != 2 || len(transport.recipients) != 2 {
+		t.Errorf("result = %+v, %d messages", result, len(transport.recipients))
+	}
+}
+
+func TestBounceWebhook(t *testing.T) {
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "mail.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	database.RegisterMigration(email.SuppressionsMigration)
+	if _, err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	setupSuppression(t, email.DatabaseSuppressions(db))
+
+	handler := email.BounceWebhookHandler(nil)
+	body := `[{"address": "hard@example.com", "type": "hard", "reason": "550 no such user"},
+		{"address": "full@example.com", "type": "soft"},
+		{"address": "angry@example.com", "type": "complaint"}]`
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/webhooks/bounces", strings.NewReader(body)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"recorded":3`) {
+		t.Fatalf("webhook = %d %s", w.Code, w.Body.String())
+	}
+
+	for _, addr := range []string{"hard@example.com", "full@example.com", "angry@example.com"} {
+		if !email.IsSuppressed(addr) {
+			t.Errorf("%s not suppressed", addr)
+		}
+	}
+
+	// Soft bounces expire.
+	email.SuppressFor("full@example.com", email.ReasonSoftBounce, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if email.IsSuppressed("full@example.com") {
+		t.Error("soft bounce did not expire")
+	}
+
+	if err := email.Unsuppress("hard@example.com"); err != nil || email.IsSuppressed("hard@example.com") {
+		t.Errorf("Unsuppress() = %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/webhooks/bounces", strings.NewReader("not json")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad payload = %d", w.Code)
+	}
+}
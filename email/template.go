@@ -0,0 +1,393 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template/parse"
+
+	"flugo.com/validator"
+)
+
+// MissingDataError lists the variables a template uses that its data does
+// not provide.
+type MissingDataError struct {
+	Template string
+	Missing  []string
+}
+
+func (e *MissingDataError) Error() string {
+	return fmt.Sprintf("email template %s is missing data: %s", e.Template, strings.Join(e.Missing, ", "))
+}
+
+type registeredTemplate struct {
+	tmpl *template.Template
+	// required variables are used unconditionally; optional ones only
+	// under if, with or range, and may be left out of map data.
+	required []string
+	optional []string
+	dataType reflect.Type
+}
+
+var (
+	templatesMu sync.RWMutex
+	templates   = make(map[string]*registeredTemplate)
+)
+
+// RegisterTemplate parses source as the HTML template name, replacing any
+// template registered under that name.
+func RegisterTemplate(name, source string) error {
+	return registerTemplate(name, source, nil)
+}
+
+// RegisterTypedTemplate registers a template whose data must be a T or *T.
+// The data is checked with the validator package before rendering, and
+// CheckTemplates reports variables the template uses that T lacks.
+func RegisterTypedTemplate[T any](name, source string) error {
+	return registerTemplate(name, source, reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func registerTemplate(name, source string, dataType reflect.Type) error {
+	tmpl, err := template.New(name).Parse(source)
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	vars := newTemplateVars()
+	vars.walk(tmpl.Tree.Root, false, false)
+	required, optional := vars.lists()
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	templates[name] = &registeredTemplate{tmpl: tmpl, required: required, optional: optional, dataType: dataType}
+	return nil
+}
+
+func lookupTemplate(name string) (*registeredTemplate, error) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	t, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	return t, nil
+}
+
+// Templates returns the names of the registered templates, sorted.
+func Templates() []string {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateVariables returns the variables a template uses, as dotted paths
+// from its data. Optional variables appear only under if, with or range.
+func TemplateVariables(name string) (required, optional []string, err error) {
+	t, err := lookupTemplate(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.required, t.optional, nil
+}
+
+// ValidateTemplateData checks data against the template before rendering:
+// a typed template's data must have its type and pass validation, and every
+// required variable must be present. Missing variables are reported
+// together in a *MissingDataError.
+func ValidateTemplateData(name string, data interface{}) error {
+	t, err := lookupTemplate(name)
+	if err != nil {
+		return err
+	}
+
+	if t.dataType != nil {
+		typ := reflect.TypeOf(data)
+		if typ != t.dataType && typ != reflect.PointerTo(t.dataType) {
+			return fmt.Errorf("email template %s expects %s data, got %T", name, t.dataType, data)
+		}
+		if err := validator.Validate(data); err != nil {
+			return err
+		}
+	}
+
+	var missing []string
+	for _, path := range t.required {
+		if !hasPath(reflect.ValueOf(data), strings.Split(path, ".")) {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingDataError{Template: name, Missing: missing}
+	}
+	return nil
+}
+
+// CheckTemplates reports the variables typed templates use that their data
+// type does not have. Run it at startup or in CI; the email:check command
+// does.
+func CheckTemplates() error {
+	var errs []error
+	for _, name := range Templates() {
+		t, err := lookupTemplate(name)
+		if err != nil || t.dataType == nil {
+			continue
+		}
+		var missing []string
+		for _, path := range append(append([]string{}, t.required...), t.optional...) {
+			if !typeHasPath(t.dataType, strings.Split(path, ".")) {
+				missing = append(missing, path)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			errs = append(errs, fmt.Errorf("email template %s uses %s, which %s does not have", name, strings.Join(missing, ", "), t.dataType))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RenderTemplate validates data and executes the template. With the
+// "error" missing key mode, the default, absent optional variables of map
+// data render as empty and any other missing variable is an error.
+func (es *EmailService) RenderTemplate(name string, data interface{}) (string, error) {
+	t, err := lookupTemplate(name)
+	if err != nil {
+		return "", err
+	}
+
+	mode := es.missingKey()
+	if mode == "error" {
+		if err := ValidateTemplateData(name, data); err != nil {
+			return "", err
+		}
+		data = withOptionalKeys(data, t.optional)
+	}
+
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare template %s: %w", name, err)
+	}
+	tmpl.Option("missingkey=" + mode)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (es *EmailService) missingKey() string {
+	if es.config != nil {
+		switch es.config.MissingKey {
+		case "zero", "default", "invalid":
+			return es.config.MissingKey
+		}
+	}
+	return "error"
+}
+
+// withOptionalKeys returns a copy of map data with the absent optional
+// top-level variables set to nil, so conditions on them do not fail.
+func withOptionalKeys(data interface{}, optional []string) interface{} {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return data
+	}
+
+	var filled map[string]interface{}
+	for _, path := range optional {
+		if strings.Contains(path, ".") {
+			continue
+		}
+		if _, ok := m[path]; ok {
+			continue
+		}
+		if filled == nil {
+			filled = make(map[string]interface{}, len(m)+len(optional))
+			for k, v := range m {
+				filled[k] = v
+			}
+		}
+		filled[path] = nil
+	}
+	if filled == nil {
+		return data
+	}
+	return filled
+}
+
+// hasPath reports whether path resolves in v the way template field
+// evaluation would. A method ends the check, since it is only known at
+// execution what it returns.
+func hasPath(v reflect.Value, path []string) bool {
+	for _, name := range path {
+		for {
+			if !v.IsValid() {
+				return false
+			}
+			if hasMethod(v.Type(), name) {
+				return true
+			}
+			if v.Kind() != reflect.Interface && v.Kind() != reflect.Pointer {
+				break
+			}
+			if v.IsNil() {
+				return false
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := v.Type().FieldByName(name)
+			if !ok || !field.IsExported() {
+				return false
+			}
+			v = v.FieldByIndex(field.Index)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return false
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func hasMethod(t reflect.Type, name string) bool {
+	if _, ok := t.MethodByName(name); ok {
+		return true
+	}
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface {
+		_, ok := reflect.PointerTo(t).MethodByName(name)
+		return ok
+	}
+	return false
+}
+
+// typeHasPath is hasPath on a type. Maps and interfaces end the check,
+// since their keys and values are only known with the data.
+func typeHasPath(t reflect.Type, path []string) bool {
+	for _, name := range path {
+		if hasMethod(t, name) {
+			return true
+		}
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := t.FieldByName(name)
+			if !ok || !field.IsExported() {
+				return false
+			}
+			t = field.Type
+		case reflect.Map, reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// templateVars collects the data fields a template tree references.
+type templateVars struct {
+	required map[string]bool
+	optional map[string]bool
+}
+
+func newTemplateVars() *templateVars {
+	return &templateVars{required: make(map[string]bool), optional: make(map[string]bool)}
+}
+
+// walk visits node. conditional is set under if, with and range; rebound is
+// set where dot no longer is the data, so only $.Field refers to it.
+func (tv *templateVars) walk(node parse.Node, conditional, rebound bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			tv.walk(child, conditional, rebound)
+		}
+	case *parse.ActionNode:
+		tv.walk(n.Pipe, conditional, rebound)
+	case *parse.TemplateNode:
+		tv.walk(n.Pipe, conditional, rebound)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			tv.walk(cmd, conditional, rebound)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			tv.walk(arg, conditional, rebound)
+		}
+	case *parse.ChainNode:
+		tv.walk(n.Node, conditional, rebound)
+	case *parse.FieldNode:
+		if !rebound {
+			tv.add(n.Ident, conditional)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			tv.add(n.Ident[1:], conditional)
+		}
+	case *parse.IfNode:
+		tv.walk(n.Pipe, true, rebound)
+		tv.walk(n.List, true, rebound)
+		tv.walk(n.ElseList, true, rebound)
+	case *parse.WithNode:
+		tv.walk(n.Pipe, true, rebound)
+		tv.walk(n.List, true, true)
+		tv.walk(n.ElseList, true, rebound)
+	case *parse.RangeNode:
+		tv.walk(n.Pipe, conditional, rebound)
+		tv.walk(n.List, true, true)
+		tv.walk(n.ElseList, true, rebound)
+	}
+}
+
+func (tv *templateVars) add(ident []string, conditional bool) {
+	path := strings.Join(ident, ".")
+	if conditional {
+		tv.optional[path] = true
+	} else {
+		tv.required[path] = true
+	}
+}
+
+func (tv *templateVars) lists() (required, optional []string) {
+	for path := range tv.required {
+		required = append(required, path)
+	}
+	for path := range tv.optional {
+		if !tv.required[path] {
+			optional = append(optional, path)
+		}
+	}
+	sort.Strings(required)
+	sort.Strings(optional)
+	return required, optional
+}
@@ -0,0 +1,116 @@
+package email_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"flugo.com/email"
+	"flugo.com/validator"
+)
+
+type invoiceData struct {
+	Name   string `json:"name" required:"true"`
+	Amount string `json:"amount" required:"true"`
+	Lines  []invoiceLine
+}
+
+type invoiceLine struct {
+	Label string
+}
+
+func (d invoiceData) Greeting() string {
+	return "Dear " + d.Name
+}
+
+const invoiceSource = `{{.Greeting}}, you owe {{.Amount}}.{{range .Lines}} {{.Label}}{{end}}{{if .Due}} Due {{$.Due}}{{end}}`
+
+func service(missingKey string) *email.EmailService {
+	return email.NewEmailService(&email.EmailConfig{MissingKey: missingKey})
+}
+
+func TestTemplateVariables(t *testing.T) {
+	required, optional, err := email.TemplateVariables("notification")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"AppName", "Message", "Name", "Title"}; !reflect.DeepEqual(required, want) {
+		t.Errorf("required = %v, want %v", required, want)
+	}
+	if want := []string{"ActionText", "ActionURL"}; !reflect.DeepEqual(optional, want) {
+		t.Errorf("optional = %v, want %v", optional, want)
+	}
+
+	if _, _, err := email.TemplateVariables("nope"); err == nil {
+		t.Error("TemplateVariables accepted an unknown template")
+	}
+}
+
+func TestRenderReportsEveryMissingVariable(t *testing.T) {
+	_, err := service("").RenderTemplate("welcome", map[string]interface{}{"Name": "Ann"})
+
+	var missing *email.MissingDataError
+	if !errors.As(err, &missing) {
+		t.Fatalf("RenderTemplate error = %v, want a MissingDataError", err)
+	}
+	if want := []string{"ActivationLink", "AppName", "Message"}; !reflect.DeepEqual(missing.Missing, want) {
+		t.Errorf("missing = %v, want %v", missing.Missing, want)
+	}
+}
+
+func TestRenderLeavesOutOptionalVariables(t *testing.T) {
+	body, err := service("").RenderTemplate("notification", map[string]interface{}{
+		"Name": "Ann", "Title": "Hi", "Message": "Hello", "AppName": "Flugo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(body, "<no value>") || strings.Contains(body, "href") {
+		t.Errorf("body renders the missing action: %s", body)
+	}
+}
+
+func TestMissingKeyDefaultKeepsOldRendering(t *testing.T) {
+	body, err := service("default").RenderTemplate("welcome", map[string]interface{}{"Name": "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "Hello Ann") {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestTypedTemplate(t *testing.T) {
+	if err := email.RegisterTypedTemplate[invoiceData]("test_invoice", invoiceSource); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := email.ValidateTemplateData("test_invoice", map[string]interface{}{"Name": "Ann"}); err == nil {
+		t.Error("typed template accepted map data")
+	}
+	var verrs validator.ValidationErrors
+	if err := email.ValidateTemplateData("test_invoice", invoiceData{Name: "Ann"}); !errors.As(err, &verrs) {
+		t.Errorf("invalid data error = %v, want validation errors", err)
+	}
+
+	// Due is only read under if, so CheckTemplates is what catches it.
+	if err := email.CheckTemplates(); err == nil || !strings.Contains(err.Error(), "test_invoice uses Due") {
+		t.Errorf("CheckTemplates() = %v, want the missing Due field reported", err)
+	}
+
+	if err := email.RegisterTypedTemplate[invoiceData]("test_invoice", strings.Replace(invoiceSource, ".Due", ".Amount", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := email.CheckTemplates(); err != nil {
+		t.Errorf("CheckTemplates() = %v after the fix", err)
+	}
+
+	body, err := service("").RenderTemplate("test_invoice", &invoiceData{Name: "Ann", Amount: "$5", Lines: []invoiceLine{{Label: "tea"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "Dear Ann, you owe $5. tea Due $5" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,157 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Transport hands a built message to the mail system. Tests replace the
+// SMTP transport with SetTransport.
+type Transport interface {
+	Send(from string, recipients []string, message []byte) error
+}
+
+// RecipientsError lists the recipients the server refused. When Delivered
+// is set the message went to the others and must not be sent again.
+type RecipientsError struct {
+	Rejected  map[string]error
+	Delivered bool
+}
+
+func (e *RecipientsError) Error() string {
+	addrs := make([]string, 0, len(e.Rejected))
+	for addr, err := range e.Rejected {
+		addrs = append(addrs, fmt.Sprintf("%s (%v)", addr, err))
+	}
+	sort.Strings(addrs)
+	return "recipients rejected: " + strings.Join(addrs, ", ")
+}
+
+// onlySuppressed reports whether every rejection is a suppressed
+// recipient.
+func (e *RecipientsError) onlySuppressed() bool {
+	for _, err := range e.Rejected {
+		if !errors.Is(err, ErrSuppressed) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRetryable reports whether sending again may succeed: SMTP 4xx replies
+// and network failures are temporary, 5xx replies, bad data and partial
+// deliveries are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var recipientsErr *RecipientsError
+	if errors.As(err, &recipientsErr) {
+		if recipientsErr.Delivered {
+			return false
+		}
+		for _, rejected := range recipientsErr.Rejected {
+			if !IsRetryable(rejected) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var missingErr *MissingDataError
+	if errors.As(err, &missingErr) || errors.Is(err, errNoRecipients) || errors.Is(err, ErrSuppressed) {
+		return false
+	}
+
+	// Network failures, such as a refused connection, are worth retrying.
+	return true
+}
+
+// smtpTransport sends over SMTP like smtp.SendMail, but addresses each
+// recipient separately so one refused address does not fail the others.
+type smtpTransport struct {
+	addr string
+	host string
+	auth smtp.Auth
+}
+
+func (t *smtpTransport) Send(from string, recipients []string, message []byte) error {
+	c, err := smtp.Dial(t.addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: t.host}); err != nil {
+			return err
+		}
+	}
+	if t.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(t.auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+
+	rejected := make(map[string]error)
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			rejected[rcpt] = err
+		}
+	}
+	if len(rejected) == len(recipients) {
+		return &RecipientsError{Rejected: rejected}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	c.Quit()
+
+	if len(rejected) > 0 {
+		return &RecipientsError{Rejected: rejected, Delivered: true}
+	}
+	return nil
+}
+
+// AttachFile reads the file at path into an attachment, typed by its
+// extension.
+func AttachFile(path string) (Attachment, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return Attachment{Filename: filepath.Base(path), Content: content, MimeType: mimeType}, nil
+}
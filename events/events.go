@@ -0,0 +1,187 @@
+// Package events is an in-process publish/subscribe bus.
+//
+// Sync subscribers run on the emitting goroutine in the order they
+// subscribed, and Emit returns once they have all run. Every sync handler
+// runs even when an earlier one fails; their errors, including recovered
+// panics, are joined into the error Emit returns.
+//
+// Async subscribers are handed to the async dispatcher as Emit reaches them
+// and run later with no ordering guarantee. Their errors are logged, or
+// retried when the queue package is the dispatcher, and never returned to
+// the emitter.
+//
+// Patterns are exact event names, "*" for every event, or a prefix ending in
+// ".*" such as "user.*", which matches "user.created" and "user.role.added".
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"flugo.com/logger"
+)
+
+type Event struct {
+	Name    string
+	Payload interface{}
+	Time    time.Time
+}
+
+type Handler func(ctx context.Context, e Event) error
+
+// AsyncDispatcher schedules run outside the emitting goroutine.
+type AsyncDispatcher func(e Event, run func() error) error
+
+type subscription struct {
+	id      uint64
+	pattern string
+	handler Handler
+	async   bool
+}
+
+type SubscribeOption func(*subscription)
+
+// Async dispatches the handler through the bus's AsyncDispatcher instead of
+// running it inline.
+func Async() SubscribeOption {
+	return func(s *subscription) {
+		s.async = true
+	}
+}
+
+type Bus struct {
+	mu       sync.RWMutex
+	subs     []*subscription
+	nextID   uint64
+	dispatch AsyncDispatcher
+}
+
+func NewBus() *Bus {
+	return &Bus{dispatch: goDispatch}
+}
+
+// DefaultBus is ready to use so framework packages can emit before the
+// application has configured anything.
+var DefaultBus = NewBus()
+
+// goDispatch runs async handlers on their own goroutine.
+func goDispatch(e Event, run func() error) error {
+	go func() {
+		if err := run(); err != nil {
+			logger.Error("Async handler for event %s failed: %v", e.Name, err)
+		}
+	}()
+	return nil
+}
+
+// SetAsyncDispatcher replaces how async handlers are scheduled; nil restores
+// plain goroutines.
+func (b *Bus) SetAsyncDispatcher(dispatch AsyncDispatcher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if dispatch == nil {
+		dispatch = goDispatch
+	}
+	b.dispatch = dispatch
+}
+
+// Subscribe registers handler for events matching pattern and returns a
+// function that removes the subscription.
+func (b *Bus) Subscribe(pattern string, handler Handler, opts ...SubscribeOption) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	sub := &subscription{id: b.nextID, pattern: pattern, handler: handler}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	b.subs = append(b.subs, sub)
+
+	return func() {
+		b.unsubscribe(sub.id)
+	}
+}
+
+func (b *Bus) unsubscribe(id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sub := range b.subs {
+		if sub.id == id {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Emit delivers payload to every subscriber whose pattern matches name.
+func (b *Bus) Emit(ctx context.Context, name string, payload interface{}) error {
+	b.mu.RLock()
+	var matched []*subscription
+	for _, sub := range b.subs {
+		if Match(sub.pattern, name) {
+			matched = append(matched, sub)
+		}
+	}
+	dispatch := b.dispatch
+	b.mu.RUnlock()
+
+	e := Event{Name: name, Payload: payload, Time: time.Now()}
+
+	var errs []error
+	for _, sub := range matched {
+		handler := sub.handler
+		if sub.async {
+			// The emitter's context usually ends with its request.
+			run := func() error { return safeCall(context.WithoutCancel(ctx), handler, e) }
+			if err := dispatch(e, run); err != nil {
+				errs = append(errs, fmt.Errorf("dispatch %s: %w", name, err))
+			}
+			continue
+		}
+
+		if err := safeCall(ctx, handler, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func safeCall(ctx context.Context, handler Handler, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Event handler for %s panicked: %v", e.Name, r)
+			err = fmt.Errorf("event %s: handler panicked: %v", e.Name, r)
+		}
+	}()
+	return handler(ctx, e)
+}
+
+// Match reports whether name matches pattern.
+func Match(pattern, name string) bool {
+	if pattern == "*" || pattern == name {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, ".") {
+		return strings.HasPrefix(name, prefix) && len(name) > len(prefix)
+	}
+	return false
+}
+
+func Subscribe(pattern string, handler Handler, opts ...SubscribeOption) func() {
+	return DefaultBus.Subscribe(pattern, handler, opts...)
+}
+
+func Emit(ctx context.Context, name string, payload interface{}) error {
+	return DefaultBus.Emit(ctx, name, payload)
+}
+
+func SetAsyncDispatcher(dispatch AsyncDispatcher) {
+	DefaultBus.SetAsyncDispatcher(dispatch)
+}
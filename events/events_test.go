@@ -0,0 +1,146 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"flugo.com/events"
+)
+
+func TestEmitRunsSyncHandlersInOrder(t *testing.T) {
+	bus := events.NewBus()
+	var order []string
+	record := func(name string) events.Handler {
+		return func(ctx context.Context, e events.Event) error {
+			order = append(order, name+":"+e.Name)
+			return nil
+		}
+	}
+	bus.Subscribe("user.created", record("exact"))
+	bus.Subscribe("*", record("all"))
+	bus.Subscribe("user.*", record("prefix"))
+	unsubscribe := bus.Subscribe("user.created", record("removed"))
+	bus.Subscribe("order.*", record("other"))
+	bus.Subscribe("user.created", record("last"))
+	unsubscribe()
+
+	if err := bus.Emit(context.Background(), "user.created", nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "exact:user.created all:user.created prefix:user.created last:user.created"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestEmitJoinsHandlerErrors(t *testing.T) {
+	bus := events.NewBus()
+	errFirst, errThird := errors.New("first failed"), errors.New("third failed")
+	ran := 0
+	bus.Subscribe("job.done", func(ctx context.Context, e events.Event) error { ran++; return errFirst })
+	bus.Subscribe("job.done", func(ctx context.Context, e events.Event) error { ran++; panic("boom") })
+	bus.Subscribe("job.done", func(ctx context.Context, e events.Event) error { ran++; return errThird })
+	bus.Subscribe("job.done", func(ctx context.Context, e events.Event) error { ran++; return nil })
+
+	err := bus.Emit(context.Background(), "job.done", nil)
+	if ran != 4 {
+		t.Errorf("%d handlers ran, want all 4", ran)
+	}
+	if !errors.Is(err, errFirst) || !errors.Is(err, errThird) {
+		t.Errorf("err = %v, want both handler errors", err)
+	}
+	if !strings.Contains(err.Error(), "handler panicked: boom") {
+		t.Errorf("err = %v, want the recovered panic", err)
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 || lines[0] != "first failed" || lines[2] != "third failed" {
+		t.Errorf("errors out of subscription order: %q", lines)
+	}
+
+	if err := bus.Emit(context.Background(), "job.started", nil); err != nil {
+		t.Errorf("event without subscribers: %v", err)
+	}
+}
+
+func TestEmitDispatchesAsyncHandlers(t *testing.T) {
+	bus := events.NewBus()
+	var runs []func() error
+	dispatchErr := errors.New("queue full")
+	failDispatch := false
+	bus.SetAsyncDispatcher(func(e events.Event, run func() error) error {
+		if failDispatch {
+			return dispatchErr
+		}
+		runs = append(runs, run)
+		return nil
+	})
+
+	var order []string
+	bus.Subscribe("mail.sent", func(ctx context.Context, e events.Event) error {
+		order = append(order, "async")
+		if ctx.Err() != nil {
+			t.Error("async handler got the emitter's canceled context")
+		}
+		return errors.New("smtp down")
+	}, events.Async())
+	bus.Subscribe("mail.sent", func(ctx context.Context, e events.Event) error {
+		order = append(order, "sync")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := bus.Emit(ctx, "mail.sent", nil); err != nil {
+		t.Errorf("async handler error reached the emitter: %v", err)
+	}
+	cancel()
+	if len(runs) != 1 || strings.Join(order, " ") != "sync" {
+		t.Fatalf("dispatched %d, ran %v; want the async handler deferred", len(runs), order)
+	}
+	if err := runs[0](); err == nil || err.Error() != "smtp down" {
+		t.Errorf("deferred run = %v, want the handler's error", err)
+	}
+
+	failDispatch = true
+	if err := bus.Emit(context.Background(), "mail.sent", nil); !errors.Is(err, dispatchErr) {
+		t.Errorf("err = %v, want the dispatch failure", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"user.created", "user.created", true},
+		{"user.created", "user.deleted", false},
+		{"*", "anything", true},
+		{"user.*", "user.created", true},
+		{"user.*", "user.role.added", true},
+		{"user.*", "user.", false},
+		{"user.*", "users.created", false},
+		{"user*", "user.created", false},
+	}
+	for _, tt := range tests {
+		if got := events.Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTopicRejectsOtherPayloads(t *testing.T) {
+	topic := events.NewTopic[int]("events-test.count")
+	var got []int
+	defer topic.Subscribe(func(ctx context.Context, n int) error {
+		got = append(got, n)
+		return nil
+	})()
+
+	if err := topic.Emit(context.Background(), 3); err != nil || len(got) != 1 || got[0] != 3 {
+		t.Fatalf("Emit = %v, handler got %v", err, got)
+	}
+	err := events.Emit(context.Background(), topic.Name, "three")
+	if err == nil || !strings.Contains(err.Error(), "payload is string") || len(got) != 1 {
+		t.Errorf("mistyped payload: err = %v, handler got %v", err, got)
+	}
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Topic binds an event name to its payload type so neither side handles
+// interface{}:
+//
+//	var UserCreated = events.NewTopic[User]("user.created")
+//
+//	UserCreated.Subscribe(func(ctx context.Context, u User) error { ... })
+//	UserCreated.Emit(ctx, user)
+type Topic[T any] struct {
+	Name string
+}
+
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{Name: name}
+}
+
+func (t Topic[T]) Emit(ctx context.Context, payload T) error {
+	return DefaultBus.Emit(ctx, t.Name, payload)
+}
+
+func (t Topic[T]) Subscribe(handler func(ctx context.Context, payload T) error, opts ...SubscribeOption) func() {
+	return On(t.Name, handler, opts...)
+}
+
+// On subscribes a handler typed by its payload. Events matching pattern
+// whose payload is not a T fail with an error instead of reaching handler.
+func On[T any](pattern string, handler func(ctx context.Context, payload T) error, opts ...SubscribeOption) func() {
+	return DefaultBus.Subscribe(pattern, func(ctx context.Context, e Event) error {
+		payload, ok := e.Payload.(T)
+		if !ok {
+			return fmt.Errorf("event %s: payload is %T, handler expects %v", e.Name, e.Payload, reflect.TypeFor[T]())
+		}
+		return handler(ctx, payload)
+	}, opts...)
+}
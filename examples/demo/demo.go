@@ -0,0 +1,134 @@
+// Package demo is the playground that used to live in main.go: a users API
+// backed by SQLite, login and profile routes, avatar uploads, a welcome email
+// sent through the queue and a cached user list. Nothing in the framework
+// depends on it; an application opts in with one call:
+//
+//	if demo.Enabled() {
+//		builder.WithModules(demo.Module())
+//	}
+//
+// The demo tables are created by migrations registered with Module and
+// seeded when the module starts.
+package demo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/container"
+	"flugo.com/database"
+	"flugo.com/docs"
+	"flugo.com/module"
+	"flugo.com/queue"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// Enabled reports whether APP_DEMO is set to a true value.
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("APP_DEMO"))
+	return enabled
+}
+
+// Module returns the demo module. Its routes are
+//
+//	GET  /users, /users/{id}    public, the list is cached
+//	POST /users                 sends a welcome email through the queue
+//	POST /login                 issues a token for a seeded user
+//	GET  /profile               requires a token
+//	POST /profile/avatar        requires a token, multipart field "avatar"
+//	GET  /utils/time            POST /utils/echo
+//	POST /admin/queues/{name}/workers   requires the admin role
+func Module() *module.Module {
+	registerMigrations()
+	describeRoutes()
+
+	profile := module.NewModule(module.ModuleConfig{
+		Name: "demo-profile",
+		Controllers: []module.ControllerConfig{
+			{Controller: &ProfileController{}, Path: "/profile"},
+		},
+		Guards: []router.MiddlewareFunc{auth.RequireAuth()},
+	})
+
+	admin := module.NewModule(module.ModuleConfig{
+		Name: "demo-admin",
+		Controllers: []module.ControllerConfig{
+			{Controller: &AdminController{}, Path: "/admin"},
+		},
+		Guards: []router.MiddlewareFunc{auth.RequireAuth(), auth.RequireRoles("admin")},
+	})
+
+	return module.NewModule(module.ModuleConfig{
+		Name: "demo",
+		Controllers: []module.ControllerConfig{
+			{Controller: &UserController{}},
+			{Controller: &UtilsController{}, Path: "/utils"},
+		},
+		Imports: []*module.Module{profile, admin},
+		Guards:  []router.MiddlewareFunc{auth.OptionalAuth()},
+		OnStart: func(ctx context.Context, c *container.Container) error {
+			return Setup(database.DefaultDB)
+		},
+	})
+}
+
+// Setup migrates and seeds db. The module runs it on start; tests that build
+// an application without starting it call it directly.
+func Setup(db *database.DB) error {
+	if db == nil {
+		return errors.New("the demo needs a database, set DB_DRIVER")
+	}
+	if _, err := db.Migrate(); err != nil {
+		return err
+	}
+	return seed(db)
+}
+
+func describeRoutes() {
+	docs.Describe("GET /users", docs.Summary("List users"), docs.Tags("demo"), docs.Response(200, []User{}))
+	docs.Describe("GET /users/{id}", docs.Summary("Get a user"), docs.Tags("demo"),
+		docs.Response(200, User{}),
+		docs.Response(404, nil))
+	docs.Describe("POST /users", docs.Summary("Create a user"), docs.Tags("demo"),
+		docs.Request(CreateUserRequest{}),
+		docs.Response(201, User{}),
+		docs.Response(422, nil))
+	docs.Describe("POST /login", docs.Summary("Log in as a demo user"), docs.Tags("demo"),
+		docs.Request(LoginRequest{}),
+		docs.Response(200, auth.Token{}),
+		docs.Response(401, nil))
+}
+
+type UtilsController struct{}
+
+func (c *UtilsController) GetTime(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	response.Success(w, map[string]interface{}{
+		"current_time": now,
+		"unix":         now.Unix(),
+		"formatted":    now.Format("2006-01-02 15:04:05"),
+	}, "Current time")
+}
+
+func (c *UtilsController) PostEcho(w http.ResponseWriter, r *http.Request) {
+	var data map[string]interface{}
+	if err := response.BindJSON(r, &data); err != nil {
+		response.InvalidBody(w, err)
+		return
+	}
+	response.Success(w, data, "Echo response")
+}
+
+// AdminController serves POST /admin/queues/{name}/workers; routes match by
+// prefix, so the queue name reaches queue.WorkersHandler.
+type AdminController struct{}
+
+func (c *AdminController) PostQueues(w http.ResponseWriter, r *http.Request) {
+	queue.WorkersHandler()(w, r)
+}
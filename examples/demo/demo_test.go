@@ -0,0 +1,147 @@
+package demo_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+
+	"flugo.com/auth"
+	"flugo.com/database"
+	"flugo.com/examples/demo"
+	"flugo.com/flugotest"
+	"flugo.com/module"
+	"flugo.com/queue"
+)
+
+func newDemoApp(t *testing.T) *flugotest.App {
+	cfg := flugotest.Config()
+	cfg.Upload.UploadPath = t.TempDir()
+	cfg.Upload.EnableResize = false
+
+	app := flugotest.NewTestApp(t, flugotest.Options{
+		Modules: []*module.Module{demo.Module()},
+		Config:  cfg,
+		Queue:   flugotest.QueueInline,
+	})
+	// The application is not started, so the module's OnStart hook does not
+	// run; set up the schema it would have created.
+	if err := demo.Setup(database.DefaultDB); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func login(t *testing.T, app *flugotest.App, email string) string {
+	var token auth.Token
+	app.Request("POST", "/login").
+		JSON(map[string]string{"email": email, "password": "password123"}).
+		Do().
+		AssertStatus(200).
+		Decode(&token)
+	return token.AccessToken
+}
+
+func TestUsers(t *testing.T) {
+	app := newDemoApp(t)
+
+	app.Request("GET", "/users").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.0.name", "John Doe")
+
+	app.Request("POST", "/users").
+		JSON(map[string]string{"name": "Alice", "email": "alice@example.com", "password": "secret123"}).
+		Do().
+		AssertStatus(201).
+		AssertJSONPath("data.id", 4)
+
+	// The cached list is dropped when a user is created.
+	app.Request("GET", "/users").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.3.email", "alice@example.com")
+	app.Request("GET", "/users?page=2&per_page=3").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.0.name", "Alice").
+		AssertJSONPath("meta.total", 4).
+		AssertJSONPath("meta.total_pages", 2)
+	app.Request("GET", "/users/4").Do().AssertStatus(200).AssertJSONPath("data.name", "Alice")
+	app.Request("GET", "/users/99").Do().AssertStatus(404)
+
+	app.Request("POST", "/users").
+		JSON(map[string]string{"name": "Alice", "email": "alice@example.com", "password": "secret123"}).
+		Do().
+		AssertStatus(409)
+
+	// The welcome email went through the inline queue.
+	if stats := queue.GetStats(); stats.Processed != 1 {
+		t.Errorf("queue stats = %+v, want 1 processed job", stats)
+	}
+}
+
+func TestLoginProfileAndAvatar(t *testing.T) {
+	app := newDemoApp(t)
+
+	app.Request("POST", "/login").
+		JSON(map[string]string{"email": "jane@example.com", "password": "wrong"}).
+		Do().
+		AssertStatus(401)
+	app.Request("GET", "/profile").Do().AssertStatus(401)
+
+	token := login(t, app, "jane@example.com")
+	app.Request("GET", "/profile").WithBearer(token).Do().
+		AssertStatus(200).
+		AssertJSONPath("data.email", "jane@example.com")
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="avatar"; filename="me.png"`},
+		"Content-Type":        {"image/png"},
+	})
+	part.Write(img.Bytes())
+	form.Close()
+
+	app.Request("POST", "/profile/avatar").
+		WithBearer(token).
+		Body(&body, form.FormDataContentType()).
+		Do().
+		AssertStatus(200).
+		AssertJSONPath("data.mime_type", "image/png")
+
+	res := app.Request("GET", "/profile").WithBearer(token).Do().AssertStatus(200)
+	if avatar, _ := res.JSONPath("data.avatar"); avatar == nil || avatar == "" {
+		t.Errorf("avatar not saved:\n%s", res)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	app := newDemoApp(t)
+
+	app.Request("POST", "/admin/queues/default/workers").
+		WithBearer(login(t, app, "jane@example.com")).
+		Do().
+		AssertStatus(403)
+	app.Request("POST", "/admin/queues/default/workers").
+		WithBearer(login(t, app, "john@example.com")).
+		JSON(map[string]int{"count": 2}).
+		Do().
+		AssertStatus(200)
+}
+
+func TestCoreStartsWithoutDemoSchema(t *testing.T) {
+	cfg := flugotest.Config()
+	cfg.Upload.UploadPath = t.TempDir()
+	cfg.Server.EnableMetrics = false
+	app := flugotest.NewTestApp(t, flugotest.Options{Config: cfg})
+
+	if _, err := database.Query().Table("users").Count(); err == nil {
+		t.Error("users table exists without the demo module")
+	}
+	if routes := app.Routes(); len(routes) != 0 {
+		t.Errorf("core mounts %d routes, want none: %+v", len(routes), routes)
+	}
+}
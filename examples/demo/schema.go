@@ -0,0 +1,109 @@
+package demo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"flugo.com/database"
+	"flugo.com/database/schema"
+	"flugo.com/logger"
+)
+
+var registerOnce sync.Once
+
+// registerMigrations adds the demo tables, built with the schema package so
+// the demo runs on SQLite, PostgreSQL and MySQL alike. Tables are created
+// only if missing, as databases from before these migrations have them.
+func registerMigrations() {
+	registerOnce.Do(func() {
+		database.RegisterMigration(schema.Migration("20240101000001", "create_demo_users",
+			schema.CreateIfNotExists("users", func(t *schema.Table) {
+				t.Increments("id")
+				t.String("name", 100)
+				t.String("email", 100).Unique()
+				t.String("phone", 20).Nullable()
+				t.Integer("age").Nullable()
+				t.String("website", 255).Nullable()
+				t.String("password", 255)
+				t.String("avatar", 255).Nullable()
+				t.Boolean("is_active").Default(true)
+				t.Timestamps()
+			}),
+			schema.DropIfExists("users")))
+
+		database.RegisterMigration(schema.Migration("20240101000002", "create_demo_posts",
+			schema.CreateIfNotExists("posts", func(t *schema.Table) {
+				t.Increments("id")
+				t.Integer("user_id")
+				t.String("title", 255)
+				t.Text("content").Nullable()
+				t.String("slug", 255).Nullable().Unique()
+				t.String("status", 20).Default("draft")
+				t.Timestamp("published_at").Nullable()
+				t.Timestamps()
+				t.Foreign("user_id").References("users", "id")
+			}),
+			schema.DropIfExists("posts")))
+
+		database.RegisterMigration(schema.Migration("20240101000003", "create_demo_categories",
+			schema.CreateIfNotExists("categories", func(t *schema.Table) {
+				t.Increments("id")
+				t.String("name", 100)
+				t.Text("description").Nullable()
+				t.Timestamp("created_at").UseCurrent()
+			}),
+			schema.DropIfExists("categories")))
+
+		database.RegisterMigration(schema.Migration("20240101000004", "create_demo_post_categories",
+			schema.CreateIfNotExists("post_categories", func(t *schema.Table) {
+				t.Integer("post_id")
+				t.Integer("category_id")
+				t.Primary("post_id", "category_id")
+				t.Foreign("post_id").References("posts", "id")
+				t.Foreign("category_id").References("categories", "id")
+			}),
+			schema.DropIfExists("post_categories")))
+	})
+}
+
+// seed fills empty demo tables. Every seeded user logs in with "password123".
+func seed(db *database.DB) error {
+	count, err := db.Query().Table("users").Count()
+	if err != nil || count > 0 {
+		return err
+	}
+
+	users := []map[string]interface{}{
+		{"name": "John Doe", "email": "john@example.com", "age": 30, "website": "https://john.dev"},
+		{"name": "Jane Smith", "email": "jane@example.com", "age": 25},
+		{"name": "Bob Wilson", "email": "bob@example.com", "age": 35},
+	}
+	for _, user := range users {
+		user["password"] = hashPassword("password123")
+		if _, err := db.Query().Table("users").Insert(user); err != nil {
+			return err
+		}
+	}
+
+	categories := []map[string]interface{}{
+		{"name": "Technology", "description": "Tech related posts"},
+		{"name": "Lifestyle", "description": "Life and style posts"},
+		{"name": "Business", "description": "Business and finance posts"},
+	}
+	for _, category := range categories {
+		if _, err := db.Query().Table("categories").Insert(category); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Demo data seeded")
+	return nil
+}
+
+// hashPassword is good enough for a playground; real applications should
+// use bcrypt or argon2.
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
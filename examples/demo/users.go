@@ -0,0 +1,260 @@
+package demo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/cache"
+	"flugo.com/database"
+	"flugo.com/dto"
+	"flugo.com/queue"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/upload"
+)
+
+const (
+	usersCacheKey = "demo:users"
+	// defaultPerPage and maxPerPage bound a page of GetUsers.
+	defaultPerPage = 20
+	maxPerPage     = 100
+	// adminEmail is the seeded user who gets the admin role on login.
+	adminEmail = "john@example.com"
+)
+
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Avatar    string    `json:"avatar,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateUserRequest struct {
+	Name     string `json:"name" required:"true" min_length:"2" max_length:"100"`
+	Email    string `json:"email" required:"true" email:"true"`
+	Password string `json:"password" required:"true" min_length:"6"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" required:"true" email:"true"`
+	Password string `json:"password" required:"true"`
+}
+
+type UserController struct{}
+
+// GetUsers lists users a page at a time: ?page= (from 1) and ?per_page=
+// (up to maxPerPage), with the totals in meta.
+func (c *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
+	page, perPage := pageParams(r)
+	cacheKey := fmt.Sprintf("%s:%d:%d", usersCacheKey, page, perPage)
+	if result, found := cache.Get(cacheKey); found {
+		response.Render(w, result)
+		return
+	}
+
+	total, err := database.Query().Table("users").Count()
+	if err != nil {
+		response.InternalError(w, "Failed to fetch users")
+		return
+	}
+
+	rows, err := userQuery().OrderBy("id").Limit(perPage).Offset((page - 1) * perPage).Get()
+	if err != nil {
+		response.InternalError(w, "Failed to fetch users")
+		return
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, perPage)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			response.InternalError(w, "Failed to scan users")
+			return
+		}
+		users = append(users, user)
+	}
+
+	result := &response.Result{
+		Message: "Users retrieved successfully",
+		Data:    users,
+		Meta: &response.Meta{
+			Page:       page,
+			PerPage:    perPage,
+			Total:      total,
+			TotalPages: (total + perPage - 1) / perPage,
+		},
+	}
+	cache.Set(cacheKey, result, 5*time.Minute)
+	response.Render(w, result)
+}
+
+func (c *UserController) GetUsersById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(router.Param(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid id parameter")
+		return
+	}
+
+	user, err := findUser("id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.NotFound(w, "User not found")
+		return
+	}
+	if err != nil {
+		response.InternalError(w, "Failed to fetch user")
+		return
+	}
+	response.Success(w, user, "User retrieved successfully")
+}
+
+func (c *UserController) PostUsers(w http.ResponseWriter, r *http.Request) {
+	var req CreateUserRequest
+	if !dto.BindAndRespond(w, r, &req) {
+		return
+	}
+
+	count, err := database.Query().Table("users").Where("email = ?", req.Email).Count()
+	if err != nil {
+		response.InternalError(w, "Failed to create user")
+		return
+	}
+	if count > 0 {
+		response.Conflict(w, "Email already registered")
+		return
+	}
+
+	id, err := database.Query().Table("users").Insert(map[string]interface{}{
+		"name":     req.Name,
+		"email":    req.Email,
+		"password": hashPassword(req.Password),
+	})
+	if err != nil {
+		response.InternalError(w, "Failed to create user")
+		return
+	}
+	forgetUsers()
+
+	if err := queue.SendEmailAsync(req.Email, "Welcome!", "Thank you for joining us!"); err != nil {
+		reqctx.Logger(r).Warn("Welcome email for %s not queued: %v", req.Email, err)
+	}
+
+	user, err := findUser("id = ?", id)
+	if err != nil {
+		response.InternalError(w, "Failed to fetch user")
+		return
+	}
+	response.Created(w, user, "User created successfully")
+}
+
+func (c *UserController) PostLogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !dto.BindAndRespond(w, r, &req) {
+		return
+	}
+
+	var id int
+	var name, password string
+	err := database.Query().Table("users").
+		Select("id", "name", "password").
+		Where("email = ?", req.Email).
+		First().
+		Scan(&id, &name, &password)
+	if err != nil || password != hashPassword(req.Password) {
+		auth.LoginFailed(r, req.Email, "invalid credentials")
+		response.Unauthorized(w, "Invalid credentials")
+		return
+	}
+
+	roles := []string{"user"}
+	if req.Email == adminEmail {
+		roles = append(roles, "admin")
+	}
+
+	token, err := auth.GenerateToken(auth.Claims{
+		UserID:   id,
+		Username: name,
+		Email:    req.Email,
+		Roles:    roles,
+	})
+	if err != nil {
+		response.InternalError(w, "Failed to generate token")
+		return
+	}
+	response.Success(w, token, "Login successful")
+}
+
+type ProfileController struct{}
+
+func (c *ProfileController) Get(w http.ResponseWriter, r *http.Request) {
+	user, err := findUser("id = ?", auth.GetCurrentUserID(r))
+	if err != nil {
+		response.NotFound(w, "User not found")
+		return
+	}
+	response.Success(w, user, "Profile retrieved successfully")
+}
+
+func (c *ProfileController) PostAvatar(w http.ResponseWriter, r *http.Request) {
+	result, err := upload.HandleUpload(r, "avatar")
+	if err != nil {
+		response.BadRequest(w, "Upload failed", err.Error())
+		return
+	}
+
+	userID := auth.GetCurrentUserID(r)
+	if _, err := database.Query().Table("users").Where("id = ?", userID).Update(map[string]interface{}{
+		"avatar": result.URL,
+	}); err != nil {
+		response.InternalError(w, "Failed to save avatar")
+		return
+	}
+	forgetUsers()
+
+	response.Success(w, result, "Avatar uploaded successfully")
+}
+
+func pageParams(r *http.Request) (page, perPage int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ = strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	return page, min(perPage, maxPerPage)
+}
+
+// forgetUsers drops every cached page of the user list.
+func forgetUsers() {
+	if cache.DefaultCache == nil {
+		return
+	}
+	for _, key := range cache.DefaultCache.KeysWithPrefix(usersCacheKey + ":") {
+		cache.Delete(key)
+	}
+}
+
+func userQuery() *database.QueryBuilder {
+	return database.Query().Table("users").Select("id", "name", "email", "avatar", "created_at")
+}
+
+func findUser(condition string, args ...interface{}) (User, error) {
+	return scanUser(userQuery().Where(condition, args...).First())
+}
+
+func scanUser(row database.RowScanner) (User, error) {
+	var user User
+	var avatar sql.NullString
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &avatar, &user.CreatedAt)
+	user.Avatar = avatar.String
+	return user, err
+}
@@ -0,0 +1,55 @@
+package examples
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// OrderBoard pushes order status changes to every open dashboard:
+//
+//	board := examples.NewOrderBoard()
+//	board.Register(r)
+//
+// Clients listen with new EventSource("/orders/stream") and receive an
+// "order" event each time POST /orders/status is called.
+type OrderBoard struct {
+	broker *response.SSEBroker
+}
+
+type OrderStatusDTO struct {
+	OrderID string `json:"order_id" required:"true"`
+	Status  string `json:"status" required:"true" enum:"pending,preparing,ready,delivered"`
+}
+
+func NewOrderBoard() *OrderBoard {
+	return &OrderBoard{broker: response.NewSSEBroker()}
+}
+
+func (b *OrderBoard) Register(r *router.Router) {
+	r.GET("/orders/stream", b.Stream)
+	r.POST("/orders/status", b.UpdateStatus)
+}
+
+func (b *OrderBoard) Stream(w http.ResponseWriter, r *http.Request) {
+	<-b.broker.Subscribe(w, r)
+}
+
+func (b *OrderBoard) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	var req OrderStatusDTO
+	if err := response.BindJSON(r, &req); err != nil {
+		response.InvalidBody(w, err)
+		return
+	}
+
+	data, _ := json.Marshal(req)
+	b.broker.Broadcast("order", string(data))
+
+	response.Success(w, map[string]interface{}{
+		"order_id":  req.OrderID,
+		"status":    req.Status,
+		"listeners": b.broker.ClientCount(),
+	}, "Order status broadcast")
+}
@@ -0,0 +1,43 @@
+// Command greeter is a module loaded at runtime with Application.LoadPlugin.
+// Build it with the same Go toolchain and flugo.com version as the host:
+//
+//	go build -buildmode=plugin -o greeter.so ./examples/plugins/greeter
+//
+// then load it before starting the application:
+//
+//	cmd.New().WithSetup(func(app *cmd.Application) {
+//		if err := app.LoadPlugin("greeter.so"); err != nil {
+//			log.Fatal(err)
+//		}
+//	})
+//
+// which serves GET /greeter?name=...
+package main
+
+import (
+	"net/http"
+
+	"flugo.com/module"
+	"flugo.com/response"
+)
+
+type GreeterController struct{}
+
+func (c *GreeterController) Get(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "world"
+	}
+	response.Success(w, map[string]string{"greeting": "Hello, " + name + ", from a plugin"})
+}
+
+// FlugoModule is the symbol module.LoadPlugin looks up.
+var FlugoModule = module.NewModule(module.ModuleConfig{
+	Name: "greeter",
+	Controllers: []module.ControllerConfig{
+		{Controller: &GreeterController{}, Path: "/greeter"},
+	},
+})
+
+// main is required for package main but never runs in a plugin.
+func main() {}
@@ -0,0 +1,126 @@
+package examples
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"flugo.com/dto"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+type Post struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreatePostDTO struct {
+	Title string `json:"title" required:"true" min_length:"3" max_length:"200"`
+	Body  string `json:"body" required:"true"`
+}
+
+// PostController shows nested resources. Registered with an empty base path:
+//
+//	r.RegisterController(examples.NewPostController(), "")
+//
+// its methods become
+//
+//	GET  /users/{userId}/posts       GetUsersPostsByUserId
+//	POST /users/{userId}/posts       PostUsersPostsByUserId
+//	GET  /users/{userId}/posts/{id}  GetUsersPostsByUserIdAndId
+type PostController struct {
+	mu     sync.RWMutex
+	posts  []Post
+	nextID int
+}
+
+func NewPostController() *PostController {
+	return &PostController{
+		posts: []Post{
+			{ID: 1, UserID: 1, Title: "Hello Flugo", Body: "First post", CreatedAt: time.Now()},
+		},
+		nextID: 2,
+	}
+}
+
+func (c *PostController) GetUsersPostsByUserId(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	posts := make([]Post, 0)
+	for _, post := range c.posts {
+		if post.UserID == userID {
+			posts = append(posts, post)
+		}
+	}
+
+	response.Success(w, posts, "Posts retrieved successfully")
+}
+
+func (c *PostController) GetUsersPostsByUserIdAndId(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+	postID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, post := range c.posts {
+		if post.UserID == userID && post.ID == postID {
+			response.Success(w, post, "Post retrieved successfully")
+			return
+		}
+	}
+
+	response.NotFound(w, "Post not found")
+}
+
+func (c *PostController) PostUsersPostsByUserId(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+
+	var req CreatePostDTO
+	if !dto.BindAndRespond(w, r, &req) {
+		return
+	}
+
+	c.mu.Lock()
+	post := Post{
+		ID:        c.nextID,
+		UserID:    userID,
+		Title:     req.Title,
+		Body:      req.Body,
+		CreatedAt: time.Now(),
+	}
+	c.nextID++
+	c.posts = append(c.posts, post)
+	c.mu.Unlock()
+
+	response.Created(w, post, "Post created successfully")
+}
+
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(router.Param(r, name))
+	if err != nil || id <= 0 {
+		response.BadRequest(w, "Invalid "+name+" parameter")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,63 @@
+package examples
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/httpclient"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// Two services talking without a user: the billing service reads daily
+// totals from the reports service. The reports service registers billing
+// as a client with the reports:read scope, in config.json:
+//
+//	"jwt": {"clients": [{"id": "billing", "secret_hash": "<auth.HashClientSecret(secret)>", "scopes": ["reports:read"]}]}
+//
+// which mounts POST /oauth/token, and guards its endpoint with the scope.
+func RegisterReports(r *router.Router) {
+	r.GET("/reports/daily", DailyReport, auth.RequireScopes("reports:read"))
+}
+
+type DailyTotals struct {
+	Date    string  `json:"date"`
+	Orders  int     `json:"orders"`
+	Revenue float64 `json:"revenue"`
+}
+
+func DailyReport(w http.ResponseWriter, r *http.Request) {
+	caller := auth.GetCurrentUser(r).ClientID
+	response.Success(w, DailyTotals{Date: time.Now().Format(time.DateOnly), Orders: 42, Revenue: 1234.5}, "Report for "+caller)
+}
+
+// ReportsClient is the billing side. Its httpclient gets a token from the
+// reports service on first use, attaches it to every call and fetches a
+// new one shortly before it expires.
+type ReportsClient struct {
+	http *httpclient.Client
+}
+
+func NewReportsClient(reportsURL, clientID, clientSecret string) *ReportsClient {
+	return &ReportsClient{http: httpclient.New(httpclient.Options{
+		BaseURL: reportsURL,
+		TokenSource: &httpclient.ClientCredentials{
+			TokenURL:     reportsURL + "/oauth/token",
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       []string{"reports:read"},
+		},
+	})}
+}
+
+func (c *ReportsClient) DailyTotals(ctx context.Context) (*DailyTotals, error) {
+	var body struct {
+		Data DailyTotals `json:"data"`
+	}
+	if err := c.http.GetJSON(ctx, "/reports/daily", &body); err != nil {
+		return nil, err
+	}
+	return &body.Data, nil
+}
@@ -0,0 +1,35 @@
+package examples
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"flugo.com/qrcode"
+	"flugo.com/upload"
+)
+
+// RegisterTicketScanner decodes the QR code on every uploaded ticket image
+// and exposes its payload as metadata["ticket_payload"] on the result.
+func RegisterTicketScanner() {
+	upload.RegisterPostProcessor(DecodeTicket)
+}
+
+func DecodeTicket(result *upload.UploadResult) error {
+	if !strings.HasPrefix(result.MimeType, "image/") {
+		return nil
+	}
+
+	data, err := os.ReadFile(result.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read ticket image: %w", err)
+	}
+
+	payload, err := qrcode.DecodeBytes(data)
+	if err != nil {
+		return fmt.Errorf("no readable QR code: %w", err)
+	}
+
+	result.Metadata["ticket_payload"] = payload
+	return nil
+}
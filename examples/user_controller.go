@@ -7,10 +7,11 @@ import (
 	"time"
 
 	"flugo.com/auth"
-	"flugo.com/cache"
 	"flugo.com/dto"
+	"flugo.com/framework"
 	"flugo.com/response"
 	"flugo.com/upload"
+	"flugo.com/utils"
 )
 
 type CreateUserDTO struct {
@@ -31,8 +32,9 @@ type UpdateUserDTO struct {
 }
 
 type LoginDTO struct {
-	Email    string `json:"email" required:"true" email:"true"`
-	Password string `json:"password" required:"true"`
+	Email      string `json:"email" required:"true" email:"true"`
+	Password   string `json:"password" required:"true"`
+	RememberMe bool   `json:"remember_me"`
 }
 
 type UserController struct {
@@ -40,21 +42,34 @@ type UserController struct {
 }
 
 func NewUserController() *UserController {
+	SubscribeUserEvents()
 	return &UserController{}
 }
 
+// GetUsers returns one page of users, 20 per page unless ?per_page= asks
+// for up to 100.
 func (c *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
-	cacheKey := "users:all"
-
-	if cachedUsers, found := cache.Get(cacheKey); found {
-		response.Success(w, cachedUsers, "Users retrieved from cache")
-		return
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
 	}
 
-	users := c.UserService.GetAll()
-	cache.Set(cacheKey, users, 5*time.Minute)
+	store := framework.From(r).Cache()
+	cacheKey := "users:all"
+	users, found := store.Get(cacheKey)
+	if !found {
+		users = c.UserService.GetAll()
+		store.Set(cacheKey, users, 5*time.Minute)
+	}
 
-	response.Success(w, users, "Users retrieved successfully")
+	all := users.([]User)
+	items, page, totalPages := utils.Paginate(all, page, perPage)
+	response.Render(w, &response.Result{
+		Message: "Users retrieved successfully",
+		Data:    items,
+		Meta:    &response.Meta{Page: page, PerPage: perPage, Total: len(all), TotalPages: totalPages},
+	})
 }
 
 func (c *UserController) GetUsersById(w http.ResponseWriter, r *http.Request) {
@@ -70,8 +85,9 @@ func (c *UserController) GetUsersById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	store := framework.From(r).Cache()
 	cacheKey := "user:" + strconv.Itoa(id)
-	if cachedUser, found := cache.Get(cacheKey); found {
+	if cachedUser, found := store.Get(cacheKey); found {
 		response.Success(w, cachedUser, "User retrieved from cache")
 		return
 	}
@@ -82,7 +98,7 @@ func (c *UserController) GetUsersById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cache.Set(cacheKey, user, 10*time.Minute)
+	store.Set(cacheKey, user, 10*time.Minute)
 	response.Success(w, user, "User retrieved successfully")
 }
 
@@ -99,7 +115,7 @@ func (c *UserController) PostUsers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	createdUser := c.UserService.Create(user)
-	cache.Delete("users:all")
+	UserCreated.Emit(r.Context(), createdUser)
 
 	response.Created(w, createdUser, "User created successfully")
 }
@@ -136,8 +152,7 @@ func (c *UserController) PutUsersById(w http.ResponseWriter, r *http.Request) {
 	}
 
 	updatedUser := c.UserService.Update(*user)
-	cache.Delete("users:all")
-	cache.Delete("user:" + strconv.Itoa(id))
+	UserUpdated.Emit(r.Context(), updatedUser)
 
 	response.Updated(w, updatedUser, "User updated successfully")
 }
@@ -160,8 +175,7 @@ func (c *UserController) DeleteUsersById(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	cache.Delete("users:all")
-	cache.Delete("user:" + strconv.Itoa(id))
+	UserDeleted.Emit(r.Context(), id)
 
 	response.Deleted(w, "User deleted successfully")
 }
@@ -174,6 +188,7 @@ func (c *UserController) PostLogin(w http.ResponseWriter, r *http.Request) {
 
 	user := c.UserService.GetByEmail(loginDTO.Email)
 	if user == nil {
+		auth.LoginFailed(r, loginDTO.Email, "unknown email")
 		response.Unauthorized(w, "Invalid credentials")
 		return
 	}
@@ -188,7 +203,12 @@ func (c *UserController) PostLogin(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	token, err := auth.GenerateToken(claims)
+	opts := []auth.ClaimsOption{auth.WithDevice(r)}
+	if loginDTO.RememberMe {
+		opts = append(opts, auth.WithRememberMe())
+	}
+
+	token, err := auth.GenerateToken(claims, opts...)
 	if err != nil {
 		response.InternalError(w, "Failed to generate token")
 		return
@@ -197,6 +217,35 @@ func (c *UserController) PostLogin(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, token, "Login successful")
 }
 
+// PostNightShiftToken issues a token ahead of time for a shift that starts at
+// the next midnight. Requested at 11 PM, the token is rejected until 00:00
+// and expires one token lifetime after that.
+func (c *UserController) PostNightShiftToken(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetCurrentUserID(r)
+	if userID == 0 {
+		response.Unauthorized(w, "User not authenticated")
+		return
+	}
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+	token, err := auth.GenerateToken(auth.Claims{
+		UserID: userID,
+		Roles:  []string{"night_shift"},
+		Nbf:    midnight.Unix(),
+	})
+	if err != nil {
+		response.InternalError(w, "Failed to generate token")
+		return
+	}
+
+	response.Success(w, map[string]interface{}{
+		"token":      token,
+		"valid_from": midnight,
+	}, "Scheduled token issued")
+}
+
 func (c *UserController) GetProfile(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetCurrentUserID(r)
 	if userID == 0 {
@@ -0,0 +1,45 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"flugo.com/cache"
+	"flugo.com/events"
+	"flugo.com/queue"
+)
+
+// The controller only announces what happened; cache invalidation and the
+// welcome email subscribe to it.
+var (
+	UserCreated = events.NewTopic[User]("user.created")
+	UserUpdated = events.NewTopic[User]("user.updated")
+	UserDeleted = events.NewTopic[int]("user.deleted")
+)
+
+var subscribeUserEvents sync.Once
+
+func SubscribeUserEvents() {
+	subscribeUserEvents.Do(func() {
+		events.Subscribe("user.*", func(ctx context.Context, e events.Event) error {
+			store := cache.FromContext(ctx)
+			if store == nil {
+				return nil
+			}
+			store.Delete("users:all")
+			switch payload := e.Payload.(type) {
+			case User:
+				store.Delete("user:" + strconv.Itoa(payload.ID))
+			case int:
+				store.Delete("user:" + strconv.Itoa(payload))
+			}
+			return nil
+		})
+
+		UserCreated.Subscribe(func(ctx context.Context, user User) error {
+			return queue.SendEmailAsync(user.Email, "Welcome", fmt.Sprintf("Hello %s, welcome aboard!", user.Name))
+		}, events.Async())
+	})
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// DownloadPath is where DownloadHandler is expected to be mounted.
+const DownloadPath = "/exports/download"
+
+// SignedURL returns a URL for DownloadHandler that serves the export file
+// name until it expires, without authentication.
+func (e *Exporter) SignedURL(name string) (string, time.Time) {
+	return e.signedURL(e.config.BaseURL, name)
+}
+
+func (e *Exporter) signedURL(baseURL, name string) (string, time.Time) {
+	expires := time.Now().Add(e.config.URLTTL).Truncate(time.Second)
+	query := url.Values{
+		"file":      {name},
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {e.sign(name, expires.Unix())},
+	}
+	return strings.TrimRight(baseURL, "/") + DownloadPath + "?" + query.Encode(), expires
+}
+
+func (e *Exporter) sign(name string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(e.config.SigningKey))
+	mac.Write([]byte(name + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DownloadHandler serves export files to holders of a SignedURL.
+func (e *Exporter) DownloadHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		name := query.Get("file")
+		expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+		valid := err == nil && name != "" && name == filepath.Base(name) &&
+			hmac.Equal([]byte(query.Get("signature")), []byte(e.sign(name, expires)))
+		if !valid {
+			response.Forbidden(w, "Invalid download link")
+			return
+		}
+		if time.Now().Unix() > expires {
+			response.Error(w, http.StatusGone, "Download link expired")
+			return
+		}
+
+		response.ServeDownload(w, r, filepath.Join(e.config.Directory, name), name)
+	}
+}
+
+func SignedURL(name string) string {
+	if DefaultExporter == nil {
+		return ""
+	}
+	u, _ := DefaultExporter.SignedURL(name)
+	return u
+}
+
+func DownloadHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if DefaultExporter == nil {
+			response.ServiceUnavailable(w, "Exports not initialized")
+			return
+		}
+		DefaultExporter.DownloadHandler()(w, r)
+	}
+}
@@ -0,0 +1,231 @@
+// Package export streams query results to CSV, XLSX or JSON Lines files
+// and hands out signed URLs to download them.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flugo.com/database"
+	"flugo.com/events"
+	"flugo.com/logger"
+	"flugo.com/utils"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatXLSX  = "xlsx"
+	FormatJSONL = "jsonl"
+)
+
+// EventCompleted is emitted with the *Result of every finished export.
+const EventCompleted = "export.completed"
+
+type Config struct {
+	// Directory is where export files are written.
+	Directory string
+	// SigningKey signs download URLs; see SignedURL.
+	SigningKey string
+	// BaseURL is prepended to download URLs, such as
+	// "https://api.example.com"; they are relative without it.
+	BaseURL string
+	// URLTTL is how long download URLs stay valid. Defaults to a day.
+	URLTTL time.Duration
+	// ChunkSize is how many rows are read per query. Defaults to 1000.
+	ChunkSize int
+}
+
+// ExportSpec describes one export. Columns are selected from Query when
+// set; the file's header names the columns the query returns.
+// Destination is the file name in the export directory, generated when
+// empty. BaseURL, typically reqctx.BaseURL of the request asking for the
+// export, overrides Config.BaseURL in the download URL. Progress, when set,
+// is called as rows are written.
+type ExportSpec struct {
+	Query       *database.QueryBuilder
+	Columns     []string
+	Format      string
+	Destination string
+	BaseURL     string
+	Progress    func(percent int, message string)
+}
+
+type Result struct {
+	Name      string    `json:"name"`
+	Format    string    `json:"format"`
+	Rows      int       `json:"rows"`
+	Size      int64     `json:"size"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Path is the file on disk.
+	Path string `json:"-"`
+}
+
+type Exporter struct {
+	config Config
+}
+
+func NewExporter(cfg Config) *Exporter {
+	if cfg.URLTTL <= 0 {
+		cfg.URLTTL = 24 * time.Hour
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 1000
+	}
+	if err := os.MkdirAll(cfg.Directory, 0755); err != nil {
+		logger.Error("Failed to create export directory: %v", err)
+	}
+	return &Exporter{config: cfg}
+}
+
+var DefaultExporter *Exporter
+
+func Init(cfg Config) {
+	DefaultExporter = NewExporter(cfg)
+}
+
+// Run writes the rows of spec.Query to a file, reading them ChunkSize at a
+// time, and emits EventCompleted. The file only appears under its name
+// once complete.
+func (e *Exporter) Run(ctx context.Context, spec ExportSpec) (*Result, error) {
+	if spec.Query == nil {
+		return nil, fmt.Errorf("export query is required")
+	}
+	switch spec.Format {
+	case FormatCSV, FormatXLSX, FormatJSONL:
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", spec.Format)
+	}
+
+	name := spec.Destination
+	if name == "" {
+		name = fmt.Sprintf("export-%s-%s.%s", time.Now().Format("20060102-150405"), utils.MustSecureToken(4), spec.Format)
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid export destination %q", name)
+	}
+
+	query := spec.Query.WithContext(ctx)
+	if len(spec.Columns) > 0 {
+		query.Select(spec.Columns...)
+	}
+
+	total := 0
+	if spec.Progress != nil {
+		count, err := query.Count()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count export rows: %w", err)
+		}
+		total = count
+		spec.Progress(0, fmt.Sprintf("Exporting %d rows", total))
+	}
+
+	path := filepath.Join(e.config.Directory, name)
+	tmp, err := os.CreateTemp(e.config.Directory, "."+name+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	rows, err := e.write(query, spec, tmp, total)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to store export file: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Name: name, Format: spec.Format, Rows: rows, Size: info.Size(), Path: path}
+	baseURL := spec.BaseURL
+	if baseURL == "" {
+		baseURL = e.config.BaseURL
+	}
+	result.URL, result.ExpiresAt = e.signedURL(baseURL, name)
+	if spec.Progress != nil {
+		spec.Progress(100, fmt.Sprintf("Exported %d rows", rows))
+	}
+
+	logger.Info("Exported %d rows to %s", rows, name)
+	if err := events.Emit(ctx, EventCompleted, result); err != nil {
+		logger.Warn("%s handlers failed: %v", EventCompleted, err)
+	}
+	return result, nil
+}
+
+func (e *Exporter) write(query *database.QueryBuilder, spec ExportSpec, f *os.File, total int) (int, error) {
+	w, err := newRowWriter(spec.Format, f)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	var values []interface{}
+	var targets []interface{}
+	err = query.Chunk(e.config.ChunkSize, func(rows *sql.Rows) error {
+		if targets == nil {
+			columns, err := rows.Columns()
+			if err != nil {
+				return err
+			}
+			if err := w.WriteHeader(columns); err != nil {
+				return err
+			}
+			values = make([]interface{}, len(columns))
+			targets = make([]interface{}, len(columns))
+			for i := range targets {
+				targets[i] = &values[i]
+			}
+		}
+
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		row := make([]interface{}, len(values))
+		for i, v := range values {
+			row[i] = cellValue(v)
+		}
+		if err := w.WriteRow(row); err != nil {
+			return err
+		}
+
+		written++
+		if spec.Progress != nil && total > 0 && written%e.config.ChunkSize == 0 {
+			spec.Progress(min(written*100/total, 99), fmt.Sprintf("Exported %d of %d rows", written, total))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to export rows: %w", err)
+	}
+
+	// Without rows nothing was scanned, but the file still gets its header.
+	if targets == nil && len(spec.Columns) > 0 {
+		if err := w.WriteHeader(spec.Columns); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	return written, nil
+}
+
+func Run(ctx context.Context, spec ExportSpec) (*Result, error) {
+	if DefaultExporter == nil {
+		return nil, fmt.Errorf("exporter not initialized")
+	}
+	return DefaultExporter.Run(ctx, spec)
+}
@@ -0,0 +1,217 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/events"
+	"flugo.com/export"
+)
+
+const rowCount = 2500
+
+func setup(t *testing.T) (*export.Exporter, *database.DB) {
+	t.Helper()
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "export.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL, paid BOOLEAN, note TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ := db.Begin()
+	for i := 1; i <= rowCount; i++ {
+		note := interface{}(nil)
+		if i%10 == 0 {
+			note = "fragile, \"handle\" <with> care\nsecond line"
+		}
+		tx.Exec("INSERT INTO orders (customer, total, paid, note) VALUES (?, ?, ?, ?)", "customer "+string(rune('A'+i%26)), float64(i)/4, i%2 == 0, note)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return export.NewExporter(export.Config{Directory: t.TempDir(), SigningKey: "secret", ChunkSize: 300}), db
+}
+
+func run(t *testing.T, e *export.Exporter, db *database.DB, format string) (*export.Result, []int) {
+	t.Helper()
+	var progress []int
+	result, err := e.Run(context.Background(), export.ExportSpec{
+		Query:    db.Query().Table("orders").OrderBy("id"),
+		Columns:  []string{"id", "customer", "total", "paid", "note"},
+		Format:   format,
+		Progress: func(percent int, message string) { progress = append(progress, percent) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != rowCount || !strings.HasSuffix(result.Name, "."+format) {
+		t.Errorf("result = %+v", result)
+	}
+	return result, progress
+}
+
+func TestExportCSV(t *testing.T) {
+	e, db := setup(t)
+	result, progress := run(t, e, db, export.FormatCSV)
+
+	f, _ := os.Open(result.Path)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != rowCount+1 || strings.Join(records[0], ",") != "id,customer,total,paid,note" {
+		t.Fatalf("%d records, header %v", len(records), records[0])
+	}
+	if got := records[10]; got[0] != "10" || got[2] != "2.5" || !strings.Contains(got[4], "\nsecond line") {
+		t.Errorf("row 10 = %q", got)
+	}
+
+	if progress[0] != 0 || progress[len(progress)-1] != 100 || len(progress) < 5 {
+		t.Errorf("progress = %v", progress)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	e, db := setup(t)
+	result, _ := run(t, e, db, export.FormatJSONL)
+
+	f, _ := os.Open(result.Path)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	lines := 0
+	for scanner.Scan() {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		lines++
+		if lines == 10 && (row["id"] != float64(10) || row["note"] == nil) {
+			t.Errorf("row 10 = %v", row)
+		}
+	}
+	if lines != rowCount {
+		t.Errorf("%d lines", lines)
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	e, db := setup(t)
+	result, _ := run(t, e, db, export.FormatXLSX)
+
+	zr, err := zip.OpenReader(result.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var sheet struct {
+		Rows []struct {
+			Ref   string `xml:"r,attr"`
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	parts := map[string]bool{}
+	for _, f := range zr.File {
+		parts[f.Name] = true
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		err := xml.NewDecoder(rc).Decode(&sheet)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if !parts[name] {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	if len(sheet.Rows) != rowCount+1 {
+		t.Fatalf("%d rows", len(sheet.Rows))
+	}
+	header := sheet.Rows[0].Cells
+	if header[1].Inline != "customer" || header[1].Ref != "B1" {
+		t.Errorf("header = %+v", header)
+	}
+	row := sheet.Rows[10].Cells
+	if row[0].Value != "10" || row[2].Value != "2.5" || row[4].Inline != "fragile, \"handle\" <with> care\nsecond line" {
+		t.Errorf("row 10 = %+v", row)
+	}
+}
+
+func TestDownloadURL(t *testing.T) {
+	e, db := setup(t)
+	var completed *export.Result
+	unsubscribe := events.On(export.EventCompleted, func(ctx context.Context, r *export.Result) error {
+		completed = r
+		return nil
+	})
+	defer unsubscribe()
+
+	result, _ := run(t, e, db, export.FormatCSV)
+	if completed != result {
+		t.Error("export.completed not emitted")
+	}
+
+	handler := e.DownloadHandler()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", result.URL, nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "id,customer") {
+		t.Fatalf("download = %d", w.Code)
+	}
+
+	u, _ := url.Parse(result.URL)
+	for name, edit := range map[string]func(q url.Values){
+		"other file": func(q url.Values) { q.Set("file", "other.csv") },
+		"extended":   func(q url.Values) { q.Set("expires", "99999999999") },
+		"unsigned":   func(q url.Values) { q.Del("signature") },
+		"traversal":  func(q url.Values) { q.Set("file", "../export.db") },
+	} {
+		q := u.Query()
+		edit(q)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", u.Path+"?"+q.Encode(), nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: %d", name, w.Code)
+		}
+	}
+}
+
+func TestRejectsBadSpecs(t *testing.T) {
+	e, db := setup(t)
+	for _, spec := range []export.ExportSpec{
+		{Query: db.Query().Table("orders"), Format: "pdf"},
+		{Query: db.Query().Table("orders"), Format: "csv", Destination: "../escape.csv"},
+		{Format: "csv"},
+	} {
+		if _, err := e.Run(context.Background(), spec); err == nil {
+			t.Errorf("%+v accepted", spec)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package featureflags turns features on for everyone, a percentage of
+// users or a list of users and roles, so code can ship dark and be enabled
+// gradually.
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/logger"
+	"flugo.com/reqctx"
+)
+
+// Rule types.
+const (
+	// TypeBoolean is on for everyone while the flag is enabled.
+	TypeBoolean = "boolean"
+	// TypePercentage is on for Percentage percent of users, always the same
+	// ones for a flag, and for its Users and Roles.
+	TypePercentage = "percentage"
+	// TypeAllowlist is on only for Users and Roles.
+	TypeAllowlist = "allowlist"
+)
+
+// Flag is off for everyone while Enabled is false, whatever its rule.
+type Flag struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Type        string    `json:"type"`
+	Enabled     bool      `json:"enabled"`
+	Percentage  int       `json:"percentage,omitempty"`
+	Users       []string  `json:"users,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// User is who a flag is evaluated for. Anonymous users have no ID and are
+// left out of percentage rollouts.
+type User struct {
+	ID    string
+	Roles []string
+}
+
+// Evaluate reports whether f is on for user.
+func (f *Flag) Evaluate(user User) bool {
+	if !f.Enabled {
+		return false
+	}
+
+	listed := (user.ID != "" && slices.Contains(f.Users, user.ID)) ||
+		slices.ContainsFunc(user.Roles, func(role string) bool { return slices.Contains(f.Roles, role) })
+	switch f.Type {
+	case TypeAllowlist:
+		return listed
+	case TypePercentage:
+		return listed || (user.ID != "" && bucket(f.Name, user.ID) < f.Percentage)
+	default:
+		return true
+	}
+}
+
+// bucket places a user in 0-99 for a flag. Hashing the flag name too keeps
+// the same users from getting every rollout first.
+func bucket(flag, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+func validate(f Flag) error {
+	switch f.Type {
+	case TypeBoolean, TypePercentage, TypeAllowlist:
+	default:
+		return fmt.Errorf("flag %s: unknown type %q", f.Name, f.Type)
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("flag %s: percentage %d is not between 0 and 100", f.Name, f.Percentage)
+	}
+	return nil
+}
+
+// Flags evaluates flags defined in config, overridden by those in a Store.
+// Store reads are cached for the TTL.
+type Flags struct {
+	defaults map[string]Flag
+	store    Store
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	cached   map[string]Flag
+	loadedAt time.Time
+}
+
+// New evaluates the definitions of cfg. Flags flipped at runtime are kept
+// in the feature_flags table with UseDatabase, in memory otherwise.
+func New(cfg *config.FlagsConfig) *Flags {
+	var store Store = NewMemoryStore()
+	if cfg.UseDatabase {
+		store = DatabaseStore(nil)
+	}
+	f := NewWithStore(store, time.Duration(cfg.CacheTTL)*time.Second)
+	for _, def := range cfg.Definitions {
+		flag := Flag{
+			Name:        def.Name,
+			Description: def.Description,
+			Type:        def.Type,
+			Enabled:     def.Enabled,
+			Percentage:  def.Percentage,
+			Users:       def.Users,
+			Roles:       def.Roles,
+		}
+		if flag.Type == "" {
+			flag.Type = TypeBoolean
+		}
+		if err := validate(flag); err != nil {
+			logger.Error("Ignoring feature flag: %v", err)
+			continue
+		}
+		f.defaults[flag.Name] = flag
+	}
+	return f
+}
+
+func NewWithStore(store Store, ttl time.Duration) *Flags {
+	return &Flags{defaults: make(map[string]Flag), store: store, ttl: ttl}
+}
+
+var DefaultFlags *Flags
+
+func Init(cfg *config.FlagsConfig) {
+	DefaultFlags = New(cfg)
+}
+
+// List returns every flag by name. Store errors are logged and leave the
+// config definitions, or the last flags read, in effect.
+func (f *Flags) List() map[string]Flag {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cached != nil && time.Since(f.loadedAt) < f.ttl {
+		return f.cached
+	}
+
+	stored, err := f.store.List()
+	if err != nil {
+		logger.Warn("Failed to load feature flags: %v", err)
+		if f.cached != nil {
+			return f.cached
+		}
+	}
+
+	flags := make(map[string]Flag, len(f.defaults)+len(stored))
+	for name, flag := range f.defaults {
+		flags[name] = flag
+	}
+	for _, flag := range stored {
+		flags[flag.Name] = flag
+	}
+	f.cached, f.loadedAt = flags, time.Now()
+	return flags
+}
+
+func (f *Flags) Get(name string) (Flag, bool) {
+	flag, ok := f.List()[name]
+	return flag, ok
+}
+
+// Set saves flag in the store, where it overrides the config definition.
+func (f *Flags) Set(flag Flag) error {
+	if flag.Type == "" {
+		flag.Type = TypeBoolean
+	}
+	if err := validate(flag); err != nil {
+		return err
+	}
+	flag.UpdatedAt = time.Now()
+	if err := f.store.Save(flag); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.cached = nil
+	f.mu.Unlock()
+	return nil
+}
+
+// Enabled reports whether the flag name is on for the user of ctx; unknown
+// flags are off. Within a request served by Middleware, the states it
+// evaluated are used, so a flag does not change halfway through.
+func (f *Flags) Enabled(ctx context.Context, name string) bool {
+	if states, ok := ctx.Value(statesKey{}).(map[string]bool); ok {
+		if on, ok := states[name]; ok {
+			return on
+		}
+	}
+
+	flag, ok := f.Get(name)
+	return ok && flag.Evaluate(UserFromContext(ctx))
+}
+
+// Evaluate returns the state of every flag for the user of ctx.
+func (f *Flags) Evaluate(ctx context.Context) map[string]bool {
+	user := UserFromContext(ctx)
+	flags := f.List()
+	states := make(map[string]bool, len(flags))
+	for name, flag := range flags {
+		states[name] = flag.Evaluate(user)
+	}
+	return states
+}
+
+type (
+	userKey   struct{}
+	statesKey struct{}
+)
+
+// WithUser sets who flags are evaluated for, for code outside an
+// authenticated request such as jobs.
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user set by WithUser or else the one
+// authenticated by auth: the user ID, or the client ID of machine tokens,
+// and roles.
+func UserFromContext(ctx context.Context) User {
+	if user, ok := ctx.Value(userKey{}).(User); ok {
+		return user
+	}
+	claims, ok := reqctx.ClaimsFromContext(ctx).(*auth.Claims)
+	if !ok || claims == nil {
+		return User{}
+	}
+	user := User{ID: claims.ClientID, Roles: claims.Roles}
+	if claims.UserID != 0 {
+		user.ID = strconv.Itoa(claims.UserID)
+	}
+	return user
+}
+
+func Enabled(ctx context.Context, name string) bool {
+	if DefaultFlags == nil {
+		return false
+	}
+	return DefaultFlags.Enabled(ctx, name)
+}
+
+func Set(flag Flag) error {
+	if DefaultFlags == nil {
+		return fmt.Errorf("feature flags not initialized")
+	}
+	return DefaultFlags.Set(flag)
+}
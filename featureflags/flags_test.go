@@ -0,0 +1,184 @@
+package featureflags_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/events"
+	"flugo.com/featureflags"
+	"flugo.com/reqctx"
+)
+
+func setup(t *testing.T, definitions ...config.FlagConfig) *featureflags.Flags {
+	t.Helper()
+	previous := featureflags.DefaultFlags
+	featureflags.Init(&config.FlagsConfig{Definitions: definitions})
+	t.Cleanup(func() { featureflags.DefaultFlags = previous })
+	return featureflags.DefaultFlags
+}
+
+func userContext(id string, roles ...string) context.Context {
+	return featureflags.WithUser(context.Background(), featureflags.User{ID: id, Roles: roles})
+}
+
+func TestRuleTypes(t *testing.T) {
+	setup(t,
+		config.FlagConfig{Name: "dark_mode", Enabled: true},
+		config.FlagConfig{Name: "killed", Type: "percentage", Percentage: 100},
+		config.FlagConfig{Name: "beta", Type: "allowlist", Enabled: true, Users: []string{"7"}, Roles: []string{"staff"}},
+	)
+
+	if !featureflags.Enabled(context.Background(), "dark_mode") {
+		t.Error("boolean flag should be on for anonymous users")
+	}
+	if featureflags.Enabled(userContext("1"), "killed") {
+		t.Error("disabled flag is on")
+	}
+	if featureflags.Enabled(userContext("1"), "missing") {
+		t.Error("unknown flag is on")
+	}
+
+	for ctx, want := range map[context.Context]bool{
+		userContext("7"):          true,
+		userContext("8", "staff"): true,
+		userContext("8", "user"):  false,
+		context.Background():      false,
+	} {
+		if got := featureflags.Enabled(ctx, "beta"); got != want {
+			t.Errorf("beta for %+v = %v", featureflags.UserFromContext(ctx), got)
+		}
+	}
+}
+
+func TestPercentageRolloutIsStable(t *testing.T) {
+	flags := setup(t, config.FlagConfig{Name: "new_checkout", Type: "percentage", Enabled: true, Percentage: 30, Roles: []string{"staff"}})
+
+	on := 0
+	for i := 0; i < 1000; i++ {
+		ctx := userContext(strconv.Itoa(i))
+		first := flags.Enabled(ctx, "new_checkout")
+		if flags.Enabled(ctx, "new_checkout") != first {
+			t.Fatalf("user %d flipped between evaluations", i)
+		}
+		if first {
+			on++
+		}
+	}
+	if on < 250 || on > 350 {
+		t.Errorf("%d of 1000 users in a 30%% rollout", on)
+	}
+	if !flags.Enabled(userContext("", "staff"), "new_checkout") {
+		t.Error("listed role left out of the rollout")
+	}
+
+	// Users in a smaller rollout stay in when it grows.
+	flag, _ := flags.Get("new_checkout")
+	var before []string
+	for i := 0; i < 100; i++ {
+		if flags.Enabled(userContext(strconv.Itoa(i)), "new_checkout") {
+			before = append(before, strconv.Itoa(i))
+		}
+	}
+	flag.Percentage = 60
+	if err := flags.Set(flag); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range before {
+		if !flags.Enabled(userContext(id), "new_checkout") {
+			t.Errorf("user %s dropped when the rollout grew", id)
+		}
+	}
+}
+
+func TestUserFromAuthClaims(t *testing.T) {
+	setup(t, config.FlagConfig{Name: "beta", Type: "allowlist", Enabled: true, Users: []string{"42"}})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r = reqctx.WithClaims(r, &auth.Claims{UserID: 42, Roles: []string{"user"}})
+	if !featureflags.Enabled(r.Context(), "beta") {
+		t.Error("flag off for the authenticated user")
+	}
+}
+
+func TestMiddlewareAndAdmin(t *testing.T) {
+	setup(t,
+		config.FlagConfig{Name: "new_checkout", Enabled: true},
+		config.FlagConfig{Name: "beta", Type: "allowlist", Enabled: true},
+	)
+
+	var states map[string]bool
+	handler := featureflags.Middleware(featureflags.MiddlewareOptions{Header: true})(func(w http.ResponseWriter, r *http.Request) {
+		states = featureflags.States(r)
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Get(featureflags.Header); got != "beta=off,new_checkout=on" {
+		t.Errorf("header = %q", got)
+	}
+	if !states["new_checkout"] || states["beta"] {
+		t.Errorf("states = %v", states)
+	}
+
+	var audits []featureflags.AuditEvent
+	unsubscribe := events.On(featureflags.EventChanged, func(ctx context.Context, e featureflags.AuditEvent) error {
+		audits = append(audits, e)
+		return nil
+	})
+	defer unsubscribe()
+
+	admin := featureflags.AdminHandler()
+	w = httptest.NewRecorder()
+	admin(w, httptest.NewRequest("POST", "/admin/flags?name=new_checkout&enabled=false", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("flip = %d %s", w.Code, w.Body.String())
+	}
+	if featureflags.Enabled(context.Background(), "new_checkout") {
+		t.Error("flag still on after flipping")
+	}
+	if len(audits) != 1 || !audits[0].Before.Enabled || audits[0].After.Enabled {
+		t.Errorf("audit events = %+v", audits)
+	}
+
+	for _, query := range []string{"name=missing&enabled=true", "name=beta&percentage=101"} {
+		w = httptest.NewRecorder()
+		admin(w, httptest.NewRequest("POST", "/admin/flags?"+query, nil))
+		if w.Code == http.StatusOK {
+			t.Errorf("%s accepted", query)
+		}
+	}
+}
+
+func TestDatabaseStore(t *testing.T) {
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "flags.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	database.RegisterMigration(featureflags.Migration)
+	if _, err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	store := featureflags.DatabaseStore(db)
+	flags := featureflags.NewWithStore(store, time.Hour)
+	if err := flags.Set(featureflags.Flag{Name: "beta", Type: featureflags.TypeAllowlist, Enabled: true, Users: []string{"1", "2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance reads the flag from the table.
+	other := featureflags.NewWithStore(store, time.Hour)
+	if !other.Enabled(userContext("2"), "beta") || other.Enabled(userContext("3"), "beta") {
+		t.Error("stored allowlist not applied")
+	}
+	if err := flags.Set(featureflags.Flag{Name: "bad", Type: "sometimes"}); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
@@ -0,0 +1,166 @@
+package featureflags
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"flugo.com/auth"
+	"flugo.com/events"
+	"flugo.com/logger"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// EventChanged is emitted with an AuditEvent when a flag is changed
+// through AdminHandler.
+const EventChanged = "featureflags.changed"
+
+// AuditEvent records who changed a flag and how.
+type AuditEvent struct {
+	Flag       string
+	UserID     int
+	RemoteAddr string
+	Before     Flag
+	After      Flag
+}
+
+// Header lists the flag states of a response when MiddlewareOptions.Header
+// is set, such as "beta_ui=off,new_checkout=on".
+const Header = "X-Feature-Flags"
+
+type MiddlewareOptions struct {
+	// Flags limits evaluation to these flags; all flags when empty.
+	Flags []string
+	// Header sets the Header response header, for debugging.
+	Header bool
+}
+
+// Middleware evaluates flags of DefaultFlags once per request, for the user
+// authenticated so far, so mount it after auth.RequireAuth or
+// auth.OptionalAuth. Handlers read the states with States, or Enabled on
+// the request context.
+func Middleware(opts MiddlewareOptions) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if DefaultFlags == nil {
+				next(w, r)
+				return
+			}
+
+			states := DefaultFlags.Evaluate(r.Context())
+			if len(opts.Flags) > 0 {
+				selected := make(map[string]bool, len(opts.Flags))
+				for _, name := range opts.Flags {
+					selected[name] = states[name]
+				}
+				states = selected
+			}
+
+			if opts.Header {
+				w.Header().Set(Header, formatStates(states))
+			}
+			next(w, r.WithContext(context.WithValue(r.Context(), statesKey{}, states)))
+		}
+	}
+}
+
+// States returns the flag states evaluated by Middleware for r, or nil.
+func States(r *http.Request) map[string]bool {
+	states, _ := r.Context().Value(statesKey{}).(map[string]bool)
+	return states
+}
+
+func formatStates(states map[string]bool) string {
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		state := "off"
+		if states[name] {
+			state = "on"
+		}
+		parts[i] = name + "=" + state
+	}
+	return strings.Join(parts, ",")
+}
+
+// AdminHandler lists the flags of DefaultFlags on GET. POST changes the flag
+// named by the name query parameter with enabled=true|false and
+// percentage=0-100, and emits EventChanged. Mount it behind
+// auth.RequireRoles("admin").
+func AdminHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if DefaultFlags == nil {
+			response.ServiceUnavailable(w, "Feature flags not initialized")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			flags := DefaultFlags.List()
+			list := make([]Flag, 0, len(flags))
+			for _, flag := range flags {
+				list = append(list, flag)
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+			response.Success(w, list)
+
+		case http.MethodPost:
+			query := r.URL.Query()
+			before, ok := DefaultFlags.Get(query.Get("name"))
+			if !ok {
+				response.NotFound(w, "Feature flag not found")
+				return
+			}
+
+			after := before
+			if v := query.Get("enabled"); v != "" {
+				enabled, err := strconv.ParseBool(v)
+				if err != nil {
+					response.BadRequest(w, "enabled must be true or false")
+					return
+				}
+				after.Enabled = enabled
+			}
+			if v := query.Get("percentage"); v != "" {
+				percentage, err := strconv.Atoi(v)
+				if err != nil || percentage < 0 || percentage > 100 {
+					response.BadRequest(w, "percentage must be between 0 and 100")
+					return
+				}
+				after.Percentage = percentage
+			}
+
+			if err := DefaultFlags.Set(after); err != nil {
+				logger.Error("Failed to update feature flag %s: %v", after.Name, err)
+				response.InternalError(w, "Failed to update feature flag")
+				return
+			}
+			after, _ = DefaultFlags.Get(after.Name)
+			audit(r, before, after)
+			response.Success(w, after, "Feature flag updated")
+
+		default:
+			response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+func audit(r *http.Request, before, after Flag) {
+	e := AuditEvent{Flag: after.Name, RemoteAddr: r.RemoteAddr, Before: before, After: after}
+	if user := auth.GetCurrentUser(r); user != nil {
+		e.UserID = user.UserID
+	}
+	logger.Info("Feature flag %s set to enabled=%v percentage=%d by user %d", after.Name, after.Enabled, after.Percentage, e.UserID)
+
+	if err := events.Emit(r.Context(), EventChanged, e); err != nil {
+		logger.Warn("%s handlers failed: %v", EventChanged, err)
+	}
+}
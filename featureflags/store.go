@@ -0,0 +1,131 @@
+package featureflags
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"flugo.com/database"
+	"flugo.com/database/schema"
+)
+
+// Store keeps the flags set at runtime.
+type Store interface {
+	List() ([]Flag, error)
+	Save(flag Flag) error
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	flags map[string]Flag
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{flags: make(map[string]Flag)}
+}
+
+func (m *memoryStore) List() ([]Flag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flags := make([]Flag, 0, len(m.flags))
+	for _, flag := range m.flags {
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+func (m *memoryStore) Save(flag Flag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags[flag.Name] = flag
+	return nil
+}
+
+// DBStore keeps flags in the feature_flags table, with users and roles
+// comma-separated.
+type DBStore struct {
+	db *database.DB
+}
+
+// Migration creates the feature_flags table of DBStore. The migrate command
+// registers it when FlagsConfig.UseDatabase is set; register it with
+// database.RegisterMigration when using DatabaseStore directly.
+var Migration = schema.Migration("20240201000005", "create_feature_flags",
+	schema.CreateIfNotExists("feature_flags", func(t *schema.Table) {
+		t.String("name", 255)
+		t.Text("description")
+		t.String("type", 32)
+		t.Boolean("enabled")
+		t.Integer("percentage")
+		t.Text("users")
+		t.Text("roles")
+		t.Timestamp("updated_at")
+		t.Primary("name")
+	}),
+	schema.DropIfExists("feature_flags"))
+
+// DatabaseStore stores flags in db, or in DefaultDB when db is nil.
+func DatabaseStore(db *database.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) List() ([]Flag, error) {
+	db, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryRows("SELECT name, description, type, enabled, percentage, users, roles, updated_at FROM feature_flags")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []Flag
+	for rows.Next() {
+		var flag Flag
+		var users, roles string
+		if err := rows.Scan(&flag.Name, &flag.Description, &flag.Type, &flag.Enabled, &flag.Percentage, &users, &roles, &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read feature flag: %w", err)
+		}
+		flag.Users, flag.Roles = splitList(users), splitList(roles)
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+func (s *DBStore) Save(flag Flag) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec("DELETE FROM feature_flags WHERE name = ?", flag.Name); err != nil {
+		return fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	_, err = db.Exec("INSERT INTO feature_flags (name, description, type, enabled, percentage, users, roles, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		flag.Name, flag.Description, flag.Type, flag.Enabled, flag.Percentage,
+		strings.Join(flag.Users, ","), strings.Join(flag.Roles, ","), flag.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func (s *DBStore) conn() (*database.DB, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+	if database.DefaultDB == nil {
+		return nil, database.ErrNotInitialized
+	}
+	return database.DefaultDB, nil
+}
@@ -0,0 +1,31 @@
+package flugotest
+
+import "sync"
+
+// SentEmail is a message the application sent while under test.
+type SentEmail struct {
+	From       string
+	Recipients []string
+	Message    string
+}
+
+// outbox is the email transport of test applications.
+type outbox struct {
+	mu   sync.Mutex
+	sent []SentEmail
+}
+
+func (o *outbox) Send(from string, recipients []string, message []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, SentEmail{From: from, Recipients: recipients, Message: string(message)})
+	return nil
+}
+
+// Emails returns the messages sent so far, oldest first. Nothing reaches an
+// SMTP server.
+func (a *App) Emails() []SentEmail {
+	a.outbox.mu.Lock()
+	defer a.outbox.mu.Unlock()
+	return append([]SentEmail(nil), a.outbox.sent...)
+}
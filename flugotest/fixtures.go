@@ -0,0 +1,35 @@
+package flugotest
+
+import (
+	"strconv"
+
+	"flugo.com/auth"
+)
+
+// Claims describes a user with roles, "user" when none are given.
+func Claims(userID int, roles ...string) auth.Claims {
+	if len(roles) == 0 {
+		roles = []string{"user"}
+	}
+	return auth.Claims{
+		UserID:   userID,
+		Username: "user" + strconv.Itoa(userID),
+		Email:    "user" + strconv.Itoa(userID) + "@example.com",
+		Roles:    roles,
+	}
+}
+
+func AdminClaims() auth.Claims {
+	return Claims(1, "admin")
+}
+
+// Token issues an access token for claims, signed with the app's JWT secret.
+func (a *App) Token(claims auth.Claims) string {
+	a.t.Helper()
+
+	token, err := auth.GenerateToken(claims)
+	if err != nil {
+		a.t.Fatalf("flugotest: failed to generate token: %v", err)
+	}
+	return token.AccessToken
+}
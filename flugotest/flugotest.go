@@ -0,0 +1,164 @@
+// Package flugotest runs an Application in-process for tests:
+//
+//	app := flugotest.NewTestApp(t, flugotest.Options{Modules: []*module.Module{users}})
+//	app.Request("POST", "/users").JSON(body).WithToken(flugotest.AdminClaims()).Do().
+//		AssertStatus(201).
+//		AssertJSONPath("data.email", "a@b.c")
+//
+// Handlers that reach the database, cache, queue and mailer through
+// framework.From use those of their own App, so one test can build two.
+// Logging, events and health checks still live in package-level defaults,
+// so tests that build an App must not run in parallel.
+package flugotest
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"flugo.com/cmd"
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/events"
+	"flugo.com/logger"
+	"flugo.com/module"
+	"flugo.com/queue"
+	"flugo.com/router"
+)
+
+type QueueMode int
+
+const (
+	// QueueDisabled leaves queue.DefaultQueue nil, so pushing jobs fails.
+	// Async event handlers still run before Emit returns.
+	QueueDisabled QueueMode = iota
+	// QueueInline runs jobs and async event handlers before Push returns.
+	QueueInline
+)
+
+type Options struct {
+	Modules     []*module.Module
+	Middlewares []router.MiddlewareFunc
+	Setup       func(app *cmd.Application)
+	// Config replaces the one returned by Config.
+	Config *config.Config
+	Queue  QueueMode
+	// Verbose keeps framework logs, which are discarded by default.
+	Verbose bool
+}
+
+type App struct {
+	*cmd.Application
+	t      testing.TB
+	outbox *outbox
+}
+
+var databaseSeq atomic.Uint64
+
+// Config returns a configuration independent of the environment: a private
+// in-memory SQLite database, no queue, no swagger and a fixed JWT secret.
+// Rate limiting is never installed globally; only routes that add a limiter
+// themselves are limited.
+func Config() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	cfg.Server.EnableSwagger = false
+	cfg.Database = config.DatabaseConfig{
+		Driver:   "sqlite3",
+		Database: fmt.Sprintf("file:flugotest%d?mode=memory&cache=shared", databaseSeq.Add(1)),
+		MaxIdle:  1,
+		MaxOpen:  1,
+	}
+	cfg.JWT = config.JWTConfig{
+		Secret:         "flugotest-secret",
+		ExpirationTime: 3600,
+		RefreshTime:    86400,
+	}
+	cfg.Queue.Enabled = false
+	cfg.Logger = config.LoggerConfig{Level: "ERROR"}
+	cfg.Email = config.EmailConfig{}
+	cfg.I18n = config.I18nConfig{}
+	return cfg
+}
+
+// NewTestApp builds an application from opts and releases the database,
+// cache and queue when the test finishes.
+func NewTestApp(t testing.TB, opts Options) *App {
+	t.Helper()
+
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = Config()
+		cfg.Upload.UploadPath = t.TempDir()
+	}
+
+	stdLog := log.Writer()
+	if !opts.Verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	builder := cmd.New().
+		WithConfig(cfg).
+		WithMiddleware(opts.Middlewares...).
+		WithModules(opts.Modules...)
+	if opts.Setup != nil {
+		builder = builder.WithSetup(opts.Setup)
+	}
+
+	app, err := builder.Build()
+	if !opts.Verbose {
+		logger.SetOutput(io.Discard)
+	}
+	if err != nil {
+		log.SetOutput(stdLog)
+		t.Fatalf("flugotest: failed to build application: %v", err)
+	}
+
+	// Emails are captured, never sent; see App.Emails.
+	outbox := &outbox{}
+	services := app.Services()
+	if mailer := services.Mailer(); mailer != nil {
+		mailer.SetTransport(outbox)
+	}
+
+	if opts.Queue == QueueInline {
+		queue.InitInline()
+	} else {
+		events.SetAsyncDispatcher(dispatchInline)
+	}
+
+	t.Cleanup(func() {
+		if queue.DefaultQueue != nil {
+			queue.DefaultQueue.Stop()
+			queue.DefaultQueue = nil
+		}
+		events.SetAsyncDispatcher(nil)
+		if db := services.DB(); db != nil {
+			db.Close()
+			if database.DefaultDB == db {
+				database.DefaultDB = nil
+			}
+		}
+		if c := services.Cache(); c != nil {
+			c.Stop()
+		}
+		logger.SetOutput(os.Stdout)
+		log.SetOutput(stdLog)
+	})
+
+	return &App{Application: app, t: t, outbox: outbox}
+}
+
+// dispatchInline keeps async handlers from outliving the test, logging
+// failures as goroutine dispatch does.
+func dispatchInline(e events.Event, run func() error) error {
+	if err := run(); err != nil {
+		logger.Error("Async handler for event %s failed: %v", e.Name, err)
+	}
+	return nil
+}
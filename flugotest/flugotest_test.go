@@ -0,0 +1,203 @@
+package flugotest_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"flugo.com/auth"
+	"flugo.com/examples"
+	"flugo.com/flugotest"
+	"flugo.com/module"
+	"flugo.com/queue"
+	"flugo.com/router"
+)
+
+func newUsersApp(t *testing.T, mode flugotest.QueueMode) *flugotest.App {
+	// The router does not fill inject fields, so the service is set here.
+	controller := examples.NewUserController()
+	controller.UserService = examples.NewUserService()
+
+	users := module.NewModule(module.ModuleConfig{
+		Name: "users",
+		Controllers: []module.ControllerConfig{
+			{Controller: controller},
+		},
+		Guards: []router.MiddlewareFunc{auth.OptionalAuth()},
+	})
+	return flugotest.NewTestApp(t, flugotest.Options{
+		Modules: []*module.Module{users},
+		Queue:   mode,
+	})
+}
+
+func TestUserCRUD(t *testing.T) {
+	app := newUsersApp(t, flugotest.QueueDisabled)
+
+	app.Request("GET", "/users").Do().
+		AssertStatus(200).
+		AssertJSONPath("success", true).
+		AssertJSONPath("data.0.name", "John Doe")
+
+	var created examples.User
+	app.Request("POST", "/users").
+		JSON(map[string]interface{}{"name": "Alice", "email": "a@b.c", "password": "secret123"}).
+		Do().
+		AssertStatus(201).
+		AssertJSONPath("data.email", "a@b.c").
+		Decode(&created)
+	if created.ID != 3 {
+		t.Fatalf("created.ID = %d, want 3", created.ID)
+	}
+
+	// The list was cached by the first request; user.created invalidates it.
+	app.Request("GET", "/users").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.2.email", "a@b.c")
+
+	app.Request("PUT", "/users/3").
+		JSON(map[string]interface{}{"name": "Alicia"}).
+		Do().
+		AssertStatus(200).
+		AssertJSONPath("data.name", "Alicia")
+
+	app.Request("GET", "/users/3").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.name", "Alicia")
+
+	app.Request("DELETE", "/users/3").Do().AssertStatus(200)
+	app.Request("GET", "/users/3").Do().
+		AssertStatus(404).
+		AssertJSONPath("success", false)
+}
+
+func TestUserValidation(t *testing.T) {
+	app := newUsersApp(t, flugotest.QueueDisabled)
+
+	res := app.Request("POST", "/users").
+		JSON(map[string]interface{}{"name": "A", "email": "not-an-email"}).
+		Do()
+	if res.StatusCode < 400 || res.StatusCode >= 500 {
+		t.Fatalf("status = %d, want a client error\n%s", res.StatusCode, res)
+	}
+	if envelope := res.Envelope(); envelope.Success || len(envelope.Errors) == 0 {
+		t.Errorf("envelope = %+v, want errors", envelope)
+	}
+
+	app.Request("POST", "/users").
+		Body(nil, "application/json").
+		Do().
+		AssertStatus(400).
+		AssertJSONPath("errors", "request body is required")
+
+	app.Request("POST", "/users").
+		Body(strings.NewReader(`{"name": "Alice"}`), "text/plain").
+		Do().
+		AssertStatus(415)
+
+	app.Request("POST", "/users").
+		Body(strings.NewReader(`{"name": 42}`), "application/json; charset=utf-8").
+		Do().
+		AssertStatus(400).
+		AssertJSONPath("errors", `field "name" must be a string, got number (byte offset 11)`)
+}
+
+func TestUserLoginAndProfile(t *testing.T) {
+	app := newUsersApp(t, flugotest.QueueDisabled)
+
+	app.Request("POST", "/login").
+		JSON(map[string]interface{}{"email": "nobody@example.com", "password": "x"}).
+		Do().
+		AssertStatus(401)
+
+	var token auth.Token
+	app.Request("POST", "/login").
+		JSON(map[string]interface{}{"email": "jane@example.com", "password": "x"}).
+		Do().
+		AssertStatus(200).
+		AssertJSONPath("data.token_type", "Bearer").
+		Decode(&token)
+
+	app.Request("GET", "/profile").
+		WithBearer(token.AccessToken).
+		Do().
+		AssertStatus(200).
+		AssertJSONPath("data.email", "jane@example.com")
+
+	app.Request("GET", "/profile").
+		WithToken(flugotest.Claims(1)).
+		Do().
+		AssertStatus(200).
+		AssertJSONPath("data.name", "John Doe")
+
+	app.Request("GET", "/profile").Do().AssertStatus(401)
+}
+
+func TestUserLoginRememberMe(t *testing.T) {
+	app := newUsersApp(t, flugotest.QueueDisabled)
+
+	var token auth.Token
+	app.Request("POST", "/login").
+		JSON(map[string]interface{}{"email": "jane@example.com", "password": "x", "remember_me": true}).
+		Do().
+		AssertStatus(200).
+		AssertJSONPath("data.expires_in", flugotest.Config().JWT.RefreshTime).
+		Decode(&token)
+
+	if _, err := auth.ValidateToken(token.RefreshToken); err == nil {
+		t.Error("refresh token accepted as an access token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token.RefreshToken, ".")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims auth.Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if !claims.RememberMe || claims.Exp-claims.Iat != 2*int64(flugotest.Config().JWT.RefreshTime) {
+		t.Errorf("refresh claims = %+v, want a remembered session", claims)
+	}
+
+	refreshed, err := auth.RefreshToken(token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.ExpiresIn != token.ExpiresIn {
+		t.Errorf("refreshed ExpiresIn = %d, want %d", refreshed.ExpiresIn, token.ExpiresIn)
+	}
+}
+
+func TestAdminRoutesRequireRole(t *testing.T) {
+	cfg := flugotest.Config()
+	cfg.Upload.UploadPath = t.TempDir()
+	cfg.Server.EnableAdmin = true
+	app := flugotest.NewTestApp(t, flugotest.Options{Config: cfg})
+
+	app.Request("GET", "/debug/cache/hot").Do().AssertStatus(401)
+	app.Request("GET", "/debug/cache/hot").WithToken(flugotest.Claims(2)).Do().AssertStatus(403)
+	app.Request("GET", "/debug/cache/hot").
+		WithToken(flugotest.AdminClaims()).
+		Query("n", "5").
+		Do().
+		AssertStatus(200)
+}
+
+func TestInlineQueueSendsWelcomeEmail(t *testing.T) {
+	app := newUsersApp(t, flugotest.QueueInline)
+
+	app.Request("POST", "/users").
+		JSON(map[string]interface{}{"name": "Bob", "email": "bob@example.com", "password": "secret123"}).
+		Do().
+		AssertStatus(201)
+
+	// The async welcome handler ran as an event_dispatch job, which pushed
+	// and ran a send_email job before the request returned.
+	if stats := queue.GetStats(); stats.Processed != 2 || stats.Failed != 0 {
+		t.Errorf("queue stats = %+v, want 2 processed", stats)
+	}
+	if emails := app.Emails(); len(emails) != 1 || emails[0].Recipients[0] != "bob@example.com" {
+		t.Errorf("sent emails = %+v, want the welcome email to bob@example.com", emails)
+	}
+}
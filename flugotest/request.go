@@ -0,0 +1,233 @@
+package flugotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"flugo.com/auth"
+)
+
+// Request is built fluently and sent with Do.
+type Request struct {
+	app    *App
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   io.Reader
+	err    error
+}
+
+func (a *App) Request(method, path string) *Request {
+	return &Request{
+		app:    a,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+func (r *Request) Body(body io.Reader, contentType string) *Request {
+	r.body = body
+	r.header.Set("Content-Type", contentType)
+	return r
+}
+
+// JSON encodes body as the request payload.
+func (r *Request) JSON(body interface{}) *Request {
+	data, err := json.Marshal(body)
+	if err != nil {
+		r.err = fmt.Errorf("failed to encode JSON body: %w", err)
+		return r
+	}
+	return r.Body(bytes.NewReader(data), "application/json")
+}
+
+func (r *Request) WithBearer(token string) *Request {
+	return r.Header("Authorization", "Bearer "+token)
+}
+
+// WithToken authenticates the request with an access token issued for
+// claims.
+func (r *Request) WithToken(claims auth.Claims) *Request {
+	return r.WithBearer(r.app.Token(claims))
+}
+
+// Do serves the request through the application's router.
+func (r *Request) Do() *Response {
+	t := r.app.t
+	t.Helper()
+
+	if r.err != nil {
+		t.Fatalf("flugotest: %s %s: %v", r.method, r.path, r.err)
+	}
+
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+
+	req := httptest.NewRequest(r.method, target, r.body)
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+
+	rec := httptest.NewRecorder()
+	r.app.Handler().ServeHTTP(rec, req)
+
+	return &Response{
+		StatusCode: rec.Code,
+		Header:     rec.Header(),
+		Body:       rec.Body.Bytes(),
+		t:          t,
+		request:    r.method + " " + r.path,
+	}
+}
+
+// Response wraps a recorded response with assertions that report through
+// the test and return the response for chaining.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	t       testing.TB
+	request string
+}
+
+// Envelope mirrors response.APIResponse with data and errors left encoded.
+type Envelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Errors  json.RawMessage `json:"errors"`
+}
+
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+func (r *Response) AssertStatus(code int) *Response {
+	r.t.Helper()
+	if r.StatusCode != code {
+		r.t.Errorf("%s: status = %d, want %d\n%s", r.request, r.StatusCode, code, r.Body)
+	}
+	return r
+}
+
+func (r *Response) AssertHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header.Get(key); got != value {
+		r.t.Errorf("%s: header %s = %q, want %q", r.request, key, got, value)
+	}
+	return r
+}
+
+// AssertJSONPath compares the value at a dot-separated path, such as
+// "data.email" or "data.0.id", with expected after a JSON round trip, so
+// numbers compare regardless of their Go type.
+func (r *Response) AssertJSONPath(path string, expected interface{}) *Response {
+	r.t.Helper()
+
+	got, err := r.JSONPath(path)
+	if err != nil {
+		r.t.Errorf("%s: %v\n%s", r.request, err, r.Body)
+		return r
+	}
+
+	want, err := normalize(expected)
+	if err != nil {
+		r.t.Fatalf("%s: failed to encode expected value: %v", r.request, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		r.t.Errorf("%s: %s = %#v, want %#v", r.request, path, got, want)
+	}
+	return r
+}
+
+// JSONPath returns the decoded value at path.
+func (r *Response) JSONPath(path string) (interface{}, error) {
+	var current interface{}
+	if err := json.Unmarshal(r.Body, &current); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %w", err)
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("path %s: no field %q", path, segment)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("path %s: invalid index %q for %d elements", path, segment, len(node))
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path %s: cannot descend into %T at %q", path, current, segment)
+		}
+	}
+	return current, nil
+}
+
+// Envelope decodes the standard response envelope.
+func (r *Response) Envelope() Envelope {
+	r.t.Helper()
+
+	var envelope Envelope
+	if err := json.Unmarshal(r.Body, &envelope); err != nil {
+		r.t.Fatalf("%s: response is not an envelope: %v\n%s", r.request, err, r.Body)
+	}
+	return envelope
+}
+
+// Decode unmarshals the envelope's data into target.
+func (r *Response) Decode(target interface{}) *Response {
+	r.t.Helper()
+
+	envelope := r.Envelope()
+	if len(envelope.Data) == 0 {
+		r.t.Fatalf("%s: response has no data\n%s", r.request, r.Body)
+	}
+	if err := json.Unmarshal(envelope.Data, target); err != nil {
+		r.t.Fatalf("%s: failed to decode data into %T: %v", r.request, target, err)
+	}
+	return r
+}
+
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,146 @@
+// Package framework hands the services an Application was built with to
+// its handlers through the request context, so handlers do not depend on
+// package-level defaults:
+//
+//	users, found := framework.From(r).Cache().Get("users:all")
+//
+// Two applications in one process each serve their own database, cache,
+// queue and mailer this way.
+package framework
+
+import (
+	"context"
+	"net/http"
+
+	"flugo.com/cache"
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/email"
+	"flugo.com/logger"
+	"flugo.com/queue"
+	"flugo.com/router"
+)
+
+// Options lists the services of an application; nil services fall back to
+// the package defaults.
+type Options struct {
+	DB     *database.DB
+	Cache  *cache.Cache
+	Queue  *queue.Queue
+	Mailer *email.EmailService
+	Logger *logger.Logger
+	Config *config.Config
+}
+
+// Services is the set of services of one application. Its accessors are
+// safe on a nil *Services and return the package default for any service
+// it lacks, which may itself be nil when that subsystem was never set up.
+type Services struct {
+	db     *database.DB
+	cache  *cache.Cache
+	queue  *queue.Queue
+	mailer *email.EmailService
+	logger *logger.Logger
+	config *config.Config
+}
+
+func New(opts Options) *Services {
+	return &Services{
+		db:     opts.DB,
+		cache:  opts.Cache,
+		queue:  opts.Queue,
+		mailer: opts.Mailer,
+		logger: opts.Logger,
+		config: opts.Config,
+	}
+}
+
+func (s *Services) DB() *database.DB {
+	if s == nil || s.db == nil {
+		return database.DefaultDB
+	}
+	return s.db
+}
+
+func (s *Services) Cache() *cache.Cache {
+	if s == nil || s.cache == nil {
+		return cache.DefaultCache
+	}
+	return s.cache
+}
+
+func (s *Services) Queue() *queue.Queue {
+	if s == nil || s.queue == nil {
+		return queue.DefaultQueue
+	}
+	return s.queue
+}
+
+func (s *Services) Mailer() *email.EmailService {
+	if s == nil || s.mailer == nil {
+		return email.DefaultEmailService
+	}
+	return s.mailer
+}
+
+// Logger never returns nil.
+func (s *Services) Logger() *logger.Logger {
+	if s != nil && s.logger != nil {
+		return s.logger
+	}
+	if logger.DefaultLogger != nil {
+		return logger.DefaultLogger
+	}
+	return logger.With(nil)
+}
+
+func (s *Services) Config() *config.Config {
+	if s == nil || s.config == nil {
+		return config.AppConfig
+	}
+	return s.config
+}
+
+type servicesKey struct{}
+
+// WithContext attaches s to ctx, along with each of its services, so the
+// package-level helpers that take a context, such as
+// database.QueryRowsContext and queue.PushContext, use them too.
+func (s *Services) WithContext(ctx context.Context) context.Context {
+	if s == nil {
+		return ctx
+	}
+	if s.db != nil {
+		ctx = database.ContextWithDB(ctx, s.db)
+	}
+	if s.cache != nil {
+		ctx = cache.ContextWithCache(ctx, s.cache)
+	}
+	if s.queue != nil {
+		ctx = queue.ContextWithQueue(ctx, s.queue)
+	}
+	if s.mailer != nil {
+		ctx = email.ContextWithService(ctx, s.mailer)
+	}
+	return context.WithValue(ctx, servicesKey{}, s)
+}
+
+// Middleware attaches s to every request.
+func Middleware(s *Services) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r.WithContext(s.WithContext(r.Context())))
+		}
+	}
+}
+
+// From returns the services attached to r, or nil, whose accessors return
+// the package defaults.
+func From(r *http.Request) *Services {
+	return FromContext(r.Context())
+}
+
+func FromContext(ctx context.Context) *Services {
+	s, _ := ctx.Value(servicesKey{}).(*Services)
+	return s
+}
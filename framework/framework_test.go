@@ -0,0 +1,88 @@
+package framework_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"flugo.com/cache"
+	"flugo.com/cmd"
+	"flugo.com/database"
+	"flugo.com/flugotest"
+	"flugo.com/framework"
+	"flugo.com/response"
+)
+
+func newNamedApp(t *testing.T, name string) *flugotest.App {
+	return flugotest.NewTestApp(t, flugotest.Options{
+		Setup: func(app *cmd.Application) {
+			db := app.Services().DB()
+			if _, err := db.Exec("CREATE TABLE app_name (name TEXT)"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("INSERT INTO app_name (name) VALUES (?)", name); err != nil {
+				t.Fatal(err)
+			}
+
+			app.GET("/whoami", func(w http.ResponseWriter, r *http.Request) {
+				var stored string
+				if err := database.QueryRowContext(r.Context(), "SELECT name FROM app_name").Scan(&stored); err != nil {
+					response.InternalError(w, err.Error())
+					return
+				}
+				visits, _ := framework.From(r).Cache().Increment("visits", 1)
+				response.Success(w, map[string]interface{}{"name": stored, "visits": visits})
+			})
+		},
+	})
+}
+
+func TestAppsInOneProcessAreIsolated(t *testing.T) {
+	first := newNamedApp(t, "first")
+	second := newNamedApp(t, "second")
+
+	first.Request("GET", "/whoami").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.name", "first").
+		AssertJSONPath("data.visits", 1)
+	first.Request("GET", "/whoami").Do().
+		AssertJSONPath("data.visits", 2)
+
+	second.Request("GET", "/whoami").Do().
+		AssertStatus(200).
+		AssertJSONPath("data.name", "second").
+		AssertJSONPath("data.visits", 1)
+
+	if first.Services().Cache() == second.Services().Cache() {
+		t.Fatal("apps share a cache")
+	}
+}
+
+func TestNilServicesFallBackToDefaults(t *testing.T) {
+	previous := cache.DefaultCache
+	defer func() { cache.DefaultCache = previous }()
+	cache.DefaultCache = cache.New(10, time.Minute)
+	defer cache.DefaultCache.Stop()
+
+	s := framework.FromContext(context.Background())
+	if s != nil {
+		t.Fatalf("FromContext = %v, want nil", s)
+	}
+	if s.Cache() != cache.DefaultCache {
+		t.Fatal("nil services did not fall back to the default cache")
+	}
+	if s.Logger() == nil {
+		t.Fatal("Logger returned nil")
+	}
+
+	own := cache.New(10, time.Minute)
+	defer own.Stop()
+	ctx := framework.New(framework.Options{Cache: own}).WithContext(context.Background())
+	if framework.FromContext(ctx).Cache() != own || cache.FromContext(ctx) != own {
+		t.Fatal("attached cache not returned")
+	}
+	if framework.FromContext(ctx).DB() != database.DefaultDB {
+		t.Fatal("missing database did not fall back to the default")
+	}
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"flugo.com/cache"
+	"flugo.com/database"
+	"flugo.com/httpclient"
+	"flugo.com/queue"
+	"flugo.com/utils"
+)
+
+func DatabaseCheck(db *database.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return database.ErrNotInitialized
+		}
+		return db.Ping(ctx)
+	}
+}
+
+// CacheCheck writes, reads back and deletes a probe key.
+func CacheCheck(c *cache.Cache) CheckFunc {
+	return func(ctx context.Context) error {
+		if c == nil {
+			return fmt.Errorf("cache not initialized")
+		}
+
+		key := "health:probe:" + utils.NewID()
+		c.Set(key, "ok", 0)
+		defer c.Delete(key)
+
+		if value, found := c.GetString(key); !found || value != "ok" {
+			return fmt.Errorf("cache did not return the probe value")
+		}
+		return nil
+	}
+}
+
+// QueueDepthCheck fails when more than maxDepth jobs are waiting.
+func QueueDepthCheck(q *queue.Queue, maxDepth int) CheckFunc {
+	return func(ctx context.Context) error {
+		if q == nil {
+			return fmt.Errorf("queue not initialized")
+		}
+		if size := q.Size(); size > maxDepth {
+			return fmt.Errorf("%d jobs queued, threshold is %d", size, maxDepth)
+		}
+		return nil
+	}
+}
+
+// SMTPCheck only verifies that the server accepts TCP connections.
+func SMTPCheck(host string, port int) CheckFunc {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+		if err != nil {
+			return fmt.Errorf("SMTP server unreachable: %w", err)
+		}
+		return conn.Close()
+	}
+}
+
+// HTTPCheck fails unless a GET of url answers 2xx within the check's
+// deadline. It does not retry, so a flapping dependency shows up.
+func HTTPCheck(url string) CheckFunc {
+	client := httpclient.New(httpclient.Options{RetryPolicy: utils.RetryPolicy{MaxAttempts: 1}})
+	return func(ctx context.Context) error {
+		resp, err := client.Get(ctx, url)
+		if err != nil {
+			return fmt.Errorf("%s unreachable: %w", url, err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s answered %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// DiskSpaceCheck fails when the filesystem holding path has less than
+// minFree bytes available.
+func DiskSpaceCheck(path string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+
+		free, err := freeDiskSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%s free on %s, need at least %s",
+				utils.FormatBytes(int64(free)), path, utils.FormatBytes(int64(minFree)))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,244 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+
+	defaultTimeout  = 2 * time.Second
+	defaultCacheTTL = 2 * time.Second
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	critical bool
+	timeout  time.Duration
+}
+
+type Option func(*check)
+
+// NonCritical marks a check whose failure degrades the service without
+// making it unready.
+func NonCritical() Option {
+	return func(c *check) { c.critical = false }
+}
+
+func Timeout(d time.Duration) Option {
+	return func(c *check) { c.timeout = d }
+}
+
+type Result struct {
+	Name     string
+	Critical bool
+	Latency  time.Duration
+	Err      error
+}
+
+type Report struct {
+	Status    string
+	Results   []Result
+	CheckedAt time.Time
+}
+
+// Checker runs registered checks concurrently and caches the report briefly
+// so frequent orchestrator probes do not hammer dependencies.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   map[string]*check
+	cacheTTL time.Duration
+
+	runMu sync.Mutex
+	last  *Report
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks:   make(map[string]*check),
+		cacheTTL: defaultCacheTTL,
+	}
+}
+
+var DefaultChecker = NewChecker()
+
+// Register adds or replaces a check. Checks are critical by default.
+func (c *Checker) Register(name string, fn CheckFunc, opts ...Option) {
+	ch := &check{name: name, fn: fn, critical: true, timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(ch)
+	}
+
+	c.mu.Lock()
+	c.checks[name] = ch
+	c.mu.Unlock()
+
+	c.invalidate()
+}
+
+func (c *Checker) Unregister(name string) {
+	c.mu.Lock()
+	delete(c.checks, name)
+	c.mu.Unlock()
+
+	c.invalidate()
+}
+
+func (c *Checker) SetCacheTTL(ttl time.Duration) {
+	c.runMu.Lock()
+	c.cacheTTL = ttl
+	c.last = nil
+	c.runMu.Unlock()
+}
+
+func (c *Checker) invalidate() {
+	c.runMu.Lock()
+	c.last = nil
+	c.runMu.Unlock()
+}
+
+// Run executes every check, or returns the cached report if it is fresh.
+func (c *Checker) Run(ctx context.Context) *Report {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	if c.last != nil && time.Since(c.last.CheckedAt) < c.cacheTTL {
+		return c.last
+	}
+
+	c.mu.RLock()
+	checks := make([]*check, 0, len(c.checks))
+	for _, ch := range c.checks {
+		checks = append(checks, ch)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func(i int, ch *check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, ch)
+		}(i, ch)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusHealthy, Results: results, CheckedAt: time.Now()}
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnhealthy
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	c.last = report
+	return report
+}
+
+// runCheck enforces the timeout even when the check ignores its context.
+func runCheck(ctx context.Context, ch *check) Result {
+	ctx, cancel := context.WithTimeout(ctx, ch.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- ch.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %v", ch.timeout)
+	}
+
+	return Result{
+		Name:     ch.name,
+		Critical: ch.critical,
+		Latency:  time.Since(start),
+		Err:      err,
+	}
+}
+
+// LiveHandler only reports that the process is serving requests.
+func LiveHandler(version string) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.Health(w, StatusHealthy, version, nil)
+	}
+}
+
+// ReadyHandler answers 503 while any critical check fails.
+func (c *Checker) ReadyHandler(version string) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		checks := make(map[string]response.HealthCheck, len(report.Results))
+		for _, result := range report.Results {
+			hc := response.HealthCheck{
+				Status:    "up",
+				Critical:  result.Critical,
+				LatencyMS: float64(result.Latency.Microseconds()) / 1000,
+			}
+			if result.Err != nil {
+				hc.Status = "down"
+				hc.Error = result.Err.Error()
+			}
+			checks[result.Name] = hc
+		}
+
+		response.HealthWithChecks(w, report.Status, version, checks)
+	}
+}
+
+// Mount registers /health/live and /health/ready. Call it before adding a
+// plain /health route, which would otherwise match both by prefix.
+func (c *Checker) Mount(r *router.Router, version string) {
+	r.GET("/health/live", LiveHandler(version))
+	r.GET("/health/ready", c.ReadyHandler(version))
+}
+
+func Register(name string, fn CheckFunc, opts ...Option) {
+	DefaultChecker.Register(name, fn, opts...)
+}
+
+func Unregister(name string) {
+	DefaultChecker.Unregister(name)
+}
+
+func Run(ctx context.Context) *Report {
+	return DefaultChecker.Run(ctx)
+}
+
+func ReadyHandler(version string) router.HandlerFunc {
+	return DefaultChecker.ReadyHandler(version)
+}
+
+func Mount(r *router.Router, version string) {
+	DefaultChecker.Mount(r, version)
+}
@@ -0,0 +1,401 @@
+// Package httpclient is the client for outbound HTTP calls: every request
+// has a timeout, idempotent requests are retried with backoff, and each
+// attempt is logged at DEBUG and counted per host.
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"flugo.com/logger"
+	"flugo.com/trace"
+	"flugo.com/utils"
+)
+
+const (
+	defaultTimeout         = 10 * time.Second
+	defaultMaxResponseSize = 10 << 20
+	defaultLogBodyLimit    = 1024
+)
+
+// Options configures a Client. Zero values pick the defaults: a 10s timeout
+// per attempt, utils.DefaultRetryPolicy, a 10MB response limit and 1KB of
+// each body in debug logs.
+type Options struct {
+	// Timeout bounds each attempt, reading the response body included.
+	Timeout     time.Duration
+	RetryPolicy utils.RetryPolicy
+	// BaseURL is prepended to paths that are not absolute URLs.
+	BaseURL string
+	// Headers are sent with every request unless the request sets them.
+	Headers map[string]string
+	// TokenSource, when set, authorizes requests without an Authorization
+	// header with its bearer token. A 401 answer drops a cached token when
+	// the source has an Invalidate method, as ClientCredentials does.
+	TokenSource TokenSource
+	// Signer, when set, signs every attempt instead of using TokenSource.
+	Signer          *Signer
+	MaxResponseSize int64
+	LogBodyLimit    int
+	// LogRedaction applies to the bodies in debug logs, see logger.SafeBody.
+	// Its MaxLength defaults to LogBodyLimit.
+	LogRedaction logger.RedactionRules
+	// Transport replaces http.DefaultTransport, for example in tests.
+	Transport http.RoundTripper
+}
+
+type Client struct {
+	http *http.Client
+	opts Options
+}
+
+// Response is a fully read response.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// StatusError reports a response outside 2xx from the JSON helpers.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d", e.Method, e.URL, e.StatusCode)
+}
+
+var ErrResponseTooLarge = errors.New("response body exceeds the size limit")
+
+var DefaultClient = New(Options{})
+
+func New(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.RetryPolicy.MaxAttempts == 0 {
+		opts.RetryPolicy = utils.DefaultRetryPolicy
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = defaultMaxResponseSize
+	}
+	if opts.LogBodyLimit <= 0 {
+		opts.LogBodyLimit = defaultLogBodyLimit
+	}
+	if opts.LogRedaction.MaxLength <= 0 {
+		opts.LogRedaction.MaxLength = opts.LogBodyLimit
+	}
+
+	return &Client{
+		http: &http.Client{Timeout: opts.Timeout, Transport: opts.Transport},
+		opts: opts,
+	}
+}
+
+// NewRequest builds a request for path, resolved against BaseURL.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	return http.NewRequestWithContext(ctx, method, c.url(path), reader)
+}
+
+func (c *Client) url(path string) string {
+	if c.opts.BaseURL == "" || strings.Contains(path, "://") {
+		return path
+	}
+	if path == "" {
+		return c.opts.BaseURL
+	}
+	return strings.TrimRight(c.opts.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// Do sends req and reads the whole response. GET, HEAD, OPTIONS, PUT and
+// DELETE requests, and any request with an Idempotency-Key header, are
+// retried on network errors, 429 and 5xx responses other than 501. When
+// every attempt gets such a status, the last response is returned without
+// an error; when ctx ends first, its error is returned.
+//
+// When the request context carries a trace, the call is recorded as a child
+// span and the traceparent header is sent along.
+func (c *Client) Do(req *http.Request) (*Response, error) {
+	ctx, span := trace.Child(req.Context(), "HTTP "+req.Method, trace.WithKind(trace.KindClient))
+	if span == nil {
+		return c.do(req)
+	}
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	req.Header.Set(trace.Header, span.Context().Traceparent())
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+	span.SetAttribute("server.address", req.URL.Host)
+
+	resp, err := c.do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.RecordError(fmt.Errorf("unexpected status %d", resp.StatusCode))
+	}
+	return resp, nil
+}
+
+func (c *Client) do(req *http.Request) (*Response, error) {
+	for name, value := range c.opts.Headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	source := c.opts.TokenSource
+	if source == nil || c.opts.Signer != nil || req.Header.Get("Authorization") != "" {
+		return c.send(req)
+	}
+	token, err := source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.send(req)
+	if inv, ok := source.(interface{ Invalidate() }); ok && err == nil && resp.StatusCode == http.StatusUnauthorized {
+		inv.Invalidate()
+	}
+	return resp, err
+}
+
+// send runs the attempts of req.
+func (c *Client) send(req *http.Request) (*Response, error) {
+	policy := c.opts.RetryPolicy
+	if !retryable(req) {
+		policy.MaxAttempts = 1
+	}
+	retryableErr := policy.Retryable
+	policy.Retryable = func(err error) bool {
+		var p *permanentError
+		if errors.As(err, &p) {
+			return false
+		}
+		return retryableErr == nil || retryableErr(err)
+	}
+
+	var last *Response
+	attempt := 0
+	resp, err := utils.RetryValue(req.Context(), policy, func() (*Response, error) {
+		attempt++
+		resp, err := c.attempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if retryStatus(resp.StatusCode) {
+			last = resp
+			return nil, &StatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Body: resp.Body}
+		}
+		return resp, nil
+	})
+
+	var statusErr *StatusError
+	if err != nil && last != nil && req.Context().Err() == nil && errors.As(err, &statusErr) {
+		return last, nil
+	}
+	if err != nil {
+		var p *permanentError
+		if errors.As(err, &p) {
+			return nil, p.err
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) attempt(req *http.Request, attempt int) (*Response, error) {
+	if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, &permanentError{err}
+		}
+		req.Body = body
+	}
+	// Each attempt is signed anew, so retries after a backoff are not
+	// rejected as stale.
+	if c.opts.Signer != nil {
+		if err := c.opts.Signer.Sign(req); err != nil {
+			return nil, &permanentError{err}
+		}
+	}
+	reqBody := c.peekBody(req)
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		record(req.URL.Host, time.Since(start), true)
+		c.log(req, attempt, 0, time.Since(start), reqBody, nil, err)
+		if req.Context().Err() != nil {
+			return nil, &permanentError{err}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxResponseSize+1))
+	duration := time.Since(start)
+	if err == nil && int64(len(body)) > c.opts.MaxResponseSize {
+		err = &permanentError{fmt.Errorf("%s %s: %w (%d bytes)", req.Method, req.URL, ErrResponseTooLarge, c.opts.MaxResponseSize)}
+	}
+	record(req.URL.Host, duration, err != nil || resp.StatusCode >= 500)
+	c.log(req, attempt, resp.StatusCode, duration, reqBody, body, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// peekBody returns a copy of the start of a replayable request body for
+// logging, as much as logger.SafeBody looks at.
+func (c *Client) peekBody(req *http.Request) []byte {
+	if req.GetBody == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	b, _ := io.ReadAll(io.LimitReader(body, 2*int64(c.opts.LogRedaction.MaxLength)+1))
+	return b
+}
+
+func (c *Client) log(req *http.Request, attempt, status int, duration time.Duration, reqBody, respBody []byte, err error) {
+	fields := map[string]interface{}{
+		"method":      req.Method,
+		"url":         req.URL.String(),
+		"attempt":     attempt,
+		"duration_ms": duration.Milliseconds(),
+	}
+	if status != 0 {
+		fields["status"] = status
+	}
+	if reqBody != nil {
+		fields["request_body"] = logger.SafeBody(reqBody, c.opts.LogRedaction)
+	}
+	if respBody != nil {
+		fields["response_body"] = logger.SafeBody(respBody, c.opts.LogRedaction)
+	}
+
+	l := logger.With(fields)
+	if err != nil {
+		l.Debug("Outbound %s %s failed: %v", req.Method, req.URL.Host, err)
+		return
+	}
+	l.Debug("Outbound %s %s -> %d", req.Method, req.URL.Host, status)
+}
+
+func (c *Client) Get(ctx context.Context, path string) (*Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) Post(ctx context.Context, path, contentType string, body []byte) (*Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// GetJSON decodes the response into out. Statuses outside 2xx are
+// *StatusError values.
+func (c *Client) GetJSON(ctx context.Context, path string, out interface{}) error {
+	return c.doJSON(ctx, http.MethodGet, path, nil, out)
+}
+
+// PostJSON sends in as JSON and decodes the response into out, which may be
+// nil to ignore it.
+func (c *Client) PostJSON(ctx context.Context, path string, in, out interface{}) error {
+	return c.doJSON(ctx, http.MethodPost, path, in, out)
+}
+
+func (c *Client) PutJSON(ctx context.Context, path string, in, out interface{}) error {
+	return c.doJSON(ctx, http.MethodPut, path, in, out)
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	req, err := c.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Method: method, URL: req.URL.String(), StatusCode: resp.StatusCode, Body: resp.Body}
+	}
+	if out == nil || len(resp.Body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL, err)
+	}
+	return nil
+}
+
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryStatus(status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && status != http.StatusNotImplemented)
+}
+
+// permanentError stops the retry loop.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
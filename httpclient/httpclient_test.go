@@ -0,0 +1,181 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flugo.com/httpclient"
+	"flugo.com/utils"
+)
+
+var fastRetry = utils.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, Multiplier: 1}
+
+// flaky answers 503 to the first failures requests and 200 afterwards.
+func flaky(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	srv, calls := flaky(t, 2)
+	client := httpclient.New(httpclient.Options{BaseURL: srv.URL, RetryPolicy: fastRetry})
+
+	var out struct{ OK bool }
+	if err := client.GetJSON(context.Background(), "/status", &out); err != nil {
+		t.Fatalf("GetJSON: %v", err)
+	}
+	if !out.OK || calls.Load() != 3 {
+		t.Errorf("ok = %v after %d calls, want true after 3", out.OK, calls.Load())
+	}
+}
+
+func TestReturnsLastResponseWhenRetriesRunOut(t *testing.T) {
+	srv, calls := flaky(t, 10)
+	client := httpclient.New(httpclient.Options{BaseURL: srv.URL, RetryPolicy: fastRetry})
+
+	resp, err := client.Get(context.Background(), "/status")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 3 {
+		t.Errorf("status %d after %d calls, want 503 after 3", resp.StatusCode, calls.Load())
+	}
+
+	var statusErr *httpclient.StatusError
+	if err := client.GetJSON(context.Background(), "/status", nil); !errors.As(err, &statusErr) || statusErr.StatusCode != 503 {
+		t.Errorf("GetJSON error = %v, want a 503 StatusError", err)
+	}
+}
+
+func TestDoesNotRetryPost(t *testing.T) {
+	srv, calls := flaky(t, 1)
+	client := httpclient.New(httpclient.Options{BaseURL: srv.URL, RetryPolicy: fastRetry})
+
+	err := client.PostJSON(context.Background(), "/orders", map[string]int{"id": 1}, nil)
+	if err == nil || calls.Load() != 1 {
+		t.Errorf("PostJSON error %v after %d calls, want an error after 1", err, calls.Load())
+	}
+}
+
+func TestRetriesPostWithIdempotencyKey(t *testing.T) {
+	var bodies []string
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	client := httpclient.New(httpclient.Options{BaseURL: srv.URL, RetryPolicy: fastRetry})
+
+	req, err := client.NewRequest(context.Background(), http.MethodPost, "/orders", []byte(`{"id":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "order-1")
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Do: status %v, error %v", resp, err)
+	}
+	if len(bodies) != 2 || bodies[1] != `{"id":1}` {
+		t.Errorf("bodies = %q, want the body sent twice", bodies)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := httpclient.New(httpclient.Options{
+		BaseURL:     srv.URL,
+		Timeout:     20 * time.Millisecond,
+		RetryPolicy: utils.RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond},
+	})
+
+	start := time.Now()
+	_, err := client.Get(context.Background(), "/slow")
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+		t.Fatalf("error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want two short attempts", elapsed)
+	}
+}
+
+func TestContextCancellationStopsRetries(t *testing.T) {
+	srv, calls := flaky(t, 10)
+	client := httpclient.New(httpclient.Options{
+		BaseURL:     srv.URL,
+		RetryPolicy: utils.RetryPolicy{MaxAttempts: 5, InitialDelay: time.Second},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Get(ctx, "/status"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+}
+
+func TestResponseSizeLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+	client := httpclient.New(httpclient.Options{BaseURL: srv.URL, MaxResponseSize: 10, RetryPolicy: fastRetry})
+
+	if _, err := client.Get(context.Background(), "/"); !errors.Is(err, httpclient.ErrResponseTooLarge) {
+		t.Errorf("error = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestHeadersAndMetrics(t *testing.T) {
+	httpclient.ResetMetrics()
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Api-Key")
+	}))
+	defer srv.Close()
+	client := httpclient.New(httpclient.Options{BaseURL: srv.URL + "/v1/", Headers: map[string]string{"X-Api-Key": "secret"}})
+
+	if _, err := client.Get(context.Background(), "/users"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "secret" {
+		t.Errorf("X-Api-Key = %q, want secret", got)
+	}
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if m := httpclient.Metrics()[host]; m.Requests != 1 || m.Errors != 0 {
+		t.Errorf("metrics for %s = %+v, want 1 request and no errors", host, m)
+	}
+}
@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// HostMetrics counts outbound attempts to one host. Errors are network
+// failures, oversized responses and 5xx statuses.
+type HostMetrics struct {
+	Requests      int64         `json:"requests"`
+	Errors        int64         `json:"errors"`
+	TotalDuration time.Duration `json:"total_duration"`
+}
+
+func (m HostMetrics) AverageDuration() time.Duration {
+	if m.Requests == 0 {
+		return 0
+	}
+	return m.TotalDuration / time.Duration(m.Requests)
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   = make(map[string]*HostMetrics)
+)
+
+func record(host string, duration time.Duration, failed bool) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	m, ok := metrics[host]
+	if !ok {
+		m = &HostMetrics{}
+		metrics[host] = m
+	}
+	m.Requests++
+	m.TotalDuration += duration
+	if failed {
+		m.Errors++
+	}
+}
+
+// Metrics returns a snapshot of the counters of every client, by host.
+func Metrics() map[string]HostMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	snapshot := make(map[string]HostMetrics, len(metrics))
+	for host, m := range metrics {
+		snapshot[host] = *m
+	}
+	return snapshot
+}
+
+// ResetMetrics clears the counters.
+func ResetMetrics() {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = make(map[string]*HostMetrics)
+}
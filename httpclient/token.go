@@ -0,0 +1,100 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the bearer token a Client sends with each request.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// tokenExpiryMargin is how long before its expiry a cached token is
+// replaced, so it does not expire in flight.
+const tokenExpiryMargin = 30 * time.Second
+
+// ClientCredentials gets machine tokens with the OAuth 2.0 client
+// credentials grant, such as from auth.TokenHandler, and caches each until
+// shortly before it expires:
+//
+//	reports := httpclient.New(httpclient.Options{
+//		BaseURL: "http://reports.internal",
+//		TokenSource: &httpclient.ClientCredentials{
+//			TokenURL: "http://auth.internal/oauth/token",
+//			ClientID: "billing", ClientSecret: os.Getenv("BILLING_SECRET"),
+//			Scopes:   []string{"reports:read"},
+//		},
+//	})
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Scopes narrows the token; empty asks for all of the client's scopes.
+	Scopes []string
+	// Client sends the token requests; DefaultClient when nil.
+	Client *Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	client := c.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	req, err := client.NewRequest(ctx, http.MethodPost, c.TokenURL, []byte(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Body, &body); err != nil || resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		if body.Error != "" {
+			return "", fmt.Errorf("token request for %s rejected: %s", c.ClientID, body.Error)
+		}
+		return "", &StatusError{Method: req.Method, URL: c.TokenURL, StatusCode: resp.StatusCode, Body: resp.Body}
+	}
+
+	c.token = body.AccessToken
+	c.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - tokenExpiryMargin)
+	return c.token, nil
+}
+
+// Invalidate drops the cached token, after the server rejected it.
+func (c *ClientCredentials) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
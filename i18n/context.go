@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+)
+
+type localeKey struct{}
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// FromContext returns the request locale set by the locale middleware, or
+// the default locale.
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale()
+}
+
+// TC translates key for the locale stored on ctx.
+func TC(ctx context.Context, key string, params map[string]interface{}) string {
+	return T(FromContext(ctx), key, params)
+}
+
+// localeWriter carries the request locale to code that only sees the
+// ResponseWriter, such as the response package.
+type localeWriter struct {
+	http.ResponseWriter
+	locale string
+}
+
+func (w *localeWriter) Locale() string {
+	return w.locale
+}
+
+func (w *localeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *localeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func WrapWriter(w http.ResponseWriter, locale string) http.ResponseWriter {
+	return &localeWriter{ResponseWriter: w, locale: locale}
+}
+
+// WriterLocale finds the locale attached by WrapWriter, looking through
+// writers that wrap it; it returns "" when there is none.
+func WriterLocale(w http.ResponseWriter) string {
+	for w != nil {
+		if lw, ok := w.(interface{ Locale() string }); ok {
+			return lw.Locale()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return ""
+		}
+		w = u.Unwrap()
+	}
+	return ""
+}
@@ -0,0 +1,359 @@
+// Package i18n translates API messages from per-locale catalogs.
+//
+// Catalogs are <locale>.json or <locale>.yaml files whose nested keys are
+// flattened with dots. A value may be an object of plural forms ("one",
+// "other", ...) chosen by the "count" parameter. {name} placeholders are
+// replaced by params. Lookups fall back from "pt-BR" to "pt", then to the
+// default locale, then to the embedded English catalog and finally to the
+// key itself, so literal messages pass through untranslated.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"flugo.com/config"
+	"flugo.com/logger"
+)
+
+//go:embed locales/en.json
+var embedded embed.FS
+
+type entry struct {
+	text   string
+	plural map[string]string
+}
+
+type Catalog struct {
+	mu            sync.RWMutex
+	messages      map[string]map[string]entry
+	names         map[string]string
+	defaultLocale string
+	reportMissing bool
+	missing       map[string]bool
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		messages:      make(map[string]map[string]entry),
+		names:         make(map[string]string),
+		defaultLocale: "en",
+		missing:       make(map[string]bool),
+	}
+}
+
+var (
+	DefaultCatalog = NewCatalog()
+	fallback       = loadEmbedded()
+)
+
+func loadEmbedded() map[string]entry {
+	data, err := embedded.ReadFile("locales/en.json")
+	if err != nil {
+		panic(err)
+	}
+	messages, err := parseCatalog(data, ".json")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: embedded catalog: %v", err))
+	}
+	return messages
+}
+
+// Init applies cfg to DefaultCatalog and loads its directory, if any.
+func Init(cfg *config.I18nConfig) error {
+	if cfg.DefaultLocale != "" {
+		DefaultCatalog.SetDefaultLocale(cfg.DefaultLocale)
+	}
+	DefaultCatalog.SetReportMissing(cfg.ReportMissing)
+
+	if cfg.Directory == "" {
+		return nil
+	}
+	return DefaultCatalog.Load(cfg.Directory)
+}
+
+// Load reads every .json, .yaml and .yml file in dir, named after its locale.
+// Keys from later files for the same locale override earlier ones.
+func (c *Catalog) Load(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read translations: %w", err)
+	}
+
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Name(), err)
+		}
+		messages, err := parseCatalog(data, ext)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file.Name(), err)
+		}
+
+		c.Add(strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())), messages)
+	}
+
+	logger.Info("Loaded translations for %s", strings.Join(c.Locales(), ", "))
+	return nil
+}
+
+// AddMessages registers translations for locale in code; values are either
+// strings or maps of plural forms.
+func (c *Catalog) AddMessages(locale string, messages map[string]interface{}) error {
+	flat := make(map[string]entry)
+	if err := flatten("", messages, flat); err != nil {
+		return err
+	}
+	c.Add(locale, flat)
+	return nil
+}
+
+func (c *Catalog) Add(locale string, messages map[string]entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := normalizeLocale(locale)
+	if c.messages[key] == nil {
+		c.messages[key] = make(map[string]entry)
+		c.names[key] = locale
+	}
+	for k, e := range messages {
+		c.messages[key][k] = e
+	}
+}
+
+// Loaded reports whether any catalog has been added.
+func (c *Catalog) Loaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.messages) > 0
+}
+
+// Locales lists the loaded locales as named by their files.
+func (c *Catalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locales := make([]string, 0, len(c.names))
+	for _, name := range c.names {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+func (c *Catalog) SetDefaultLocale(locale string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaultLocale = locale
+}
+
+func (c *Catalog) DefaultLocale() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultLocale
+}
+
+func (c *Catalog) SetReportMissing(report bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reportMissing = report
+}
+
+// Missing returns the "locale: key" pairs looked up without a translation
+// while reporting was enabled.
+func (c *Catalog) Missing() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	missing := make([]string, 0, len(c.missing))
+	for key := range c.missing {
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// T translates key for locale; an empty locale means the default locale.
+func (c *Catalog) T(locale, key string, params map[string]interface{}) string {
+	if locale == "" {
+		locale = c.DefaultLocale()
+	}
+
+	e, found := c.lookup(locale, key)
+	if !found {
+		e = entry{text: key}
+	}
+	return render(e, locale, params)
+}
+
+func (c *Catalog) lookup(locale, key string) (entry, bool) {
+	c.mu.RLock()
+	chain := []string{normalizeLocale(locale)}
+	if lang, _, ok := strings.Cut(chain[0], "-"); ok {
+		chain = append(chain, lang)
+	}
+	requested := len(chain)
+	def := normalizeLocale(c.defaultLocale)
+	chain = append(chain, def)
+	if lang, _, ok := strings.Cut(def, "-"); ok {
+		chain = append(chain, lang)
+	}
+
+	for i, loc := range chain {
+		if e, ok := c.messages[loc][key]; ok {
+			c.mu.RUnlock()
+			if i >= requested {
+				c.reportMiss(locale, key)
+			}
+			return e, true
+		}
+	}
+	c.mu.RUnlock()
+
+	// The embedded catalog is a complete English translation.
+	e, ok := fallback[key]
+	if !ok || (chain[0] != "en" && !strings.HasPrefix(chain[0], "en-")) {
+		c.reportMiss(locale, key)
+	}
+	return e, ok
+}
+
+func (c *Catalog) reportMiss(locale, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.reportMissing || len(c.messages) == 0 {
+		return
+	}
+	id := locale + ": " + key
+	if !c.missing[id] {
+		c.missing[id] = true
+		logger.Warn("Missing translation for %q in locale %s", key, locale)
+	}
+}
+
+func render(e entry, locale string, params map[string]interface{}) string {
+	text := e.text
+	if e.plural != nil {
+		form := "other"
+		if count, ok := toInt(params["count"]); ok {
+			form = pluralForm(locale, count)
+		}
+		if t, ok := e.plural[form]; ok {
+			text = t
+		} else {
+			text = e.plural["other"]
+		}
+	}
+
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func parseCatalog(data []byte, ext string) (map[string]entry, error) {
+	var raw map[string]interface{}
+	var err error
+	if ext == ".json" {
+		err = json.Unmarshal(data, &raw)
+	} else {
+		raw, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]entry)
+	return messages, flatten("", raw, messages)
+}
+
+var pluralCategories = map[string]bool{"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true}
+
+func flatten(prefix string, raw map[string]interface{}, out map[string]entry) error {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch val := v.(type) {
+		case string:
+			out[key] = entry{text: val}
+		case map[string]interface{}:
+			if forms, ok := pluralForms(val); ok {
+				out[key] = entry{plural: forms}
+				continue
+			}
+			if err := flatten(key, val, out); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("key %s: expected a string or an object, got %T", key, v)
+		}
+	}
+	return nil
+}
+
+func pluralForms(m map[string]interface{}) (map[string]string, bool) {
+	if _, ok := m["other"]; !ok {
+		return nil, false
+	}
+	forms := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !pluralCategories[k] || !ok {
+			return nil, false
+		}
+		forms[k] = s
+	}
+	return forms, true
+}
+
+func T(locale, key string, params map[string]interface{}) string {
+	return DefaultCatalog.T(locale, key, params)
+}
+
+func Load(dir string) error {
+	return DefaultCatalog.Load(dir)
+}
+
+func Loaded() bool {
+	return DefaultCatalog.Loaded()
+}
+
+func DefaultLocale() string {
+	return DefaultCatalog.DefaultLocale()
+}
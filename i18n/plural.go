@@ -0,0 +1,69 @@
+package i18n
+
+import "strings"
+
+// pluralForm picks the CLDR plural category of n for the language of
+// locale. Languages not listed use the English one/other rule.
+func pluralForm(locale string, n int) string {
+	lang, _, _ := strings.Cut(normalizeLocale(locale), "-")
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case "ja", "zh", "ko", "th", "vi", "id", "ms", "tr":
+		return "other"
+	case "fr", "pt":
+		if n <= 1 {
+			return "one"
+		}
+		return "other"
+	case "ru", "uk", "be", "sr", "hr", "bs":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		default:
+			return "other"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case n%100 >= 3 && n%100 <= 10:
+			return "few"
+		case n%100 >= 11:
+			return "many"
+		default:
+			return "other"
+		}
+	}
+
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the YAML subset translation files need: nested mappings
+// of scalar strings, indented with spaces, with # comments and single or
+// double quoted values.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	type level struct {
+		indent int
+		values map[string]interface{}
+	}
+
+	root := make(map[string]interface{})
+	stack := []level{{indent: -1, values: root}}
+	// pending is a key ending in ":" whose nested mapping has not started yet.
+	var pending map[string]interface{}
+	pendingIndent := -1
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", lineNo)
+		}
+		indent := len(line) - len(trimmed)
+
+		if pending != nil {
+			if indent <= pendingIndent {
+				return nil, fmt.Errorf("line %d: expected an indented mapping", lineNo)
+			}
+			stack = append(stack, level{indent: indent, values: pending})
+			pending = nil
+		}
+		for len(stack) > 1 && indent < stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		current := stack[len(stack)-1]
+		if indent != current.indent && len(stack) > 1 {
+			return nil, fmt.Errorf("line %d: inconsistent indentation", lineNo)
+		}
+
+		key, rest, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		key, err := yamlScalar(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			nested := make(map[string]interface{})
+			current.values[key] = nested
+			pending = nested
+			pendingIndent = indent
+			continue
+		}
+
+		value, err := yamlScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		current.values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func yamlScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return strings.ReplaceAll(s[1:i], "''", "'"), nil
+		}
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
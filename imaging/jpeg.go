@@ -0,0 +1,277 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+const (
+	markerSOI   = 0xd8
+	markerSOS   = 0xda
+	markerAPP0  = 0xe0
+	markerAPP1  = 0xe1
+	markerAPP2  = 0xe2
+	markerAPP14 = 0xee
+	markerAPP15 = 0xef
+	markerCOM   = 0xfe
+
+	exifOrientationTag = 0x0112
+	jpegQuality        = 85
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+
+	errNotJPEG = errors.New("not a JPEG file")
+)
+
+// JPEGOptions selects what CleanJPEG does.
+type JPEGOptions struct {
+	// AutoOrient turns the pixels upright according to the EXIF orientation
+	// and resets the tag.
+	AutoOrient bool
+	// StripMetadata drops EXIF, XMP, IPTC and comments, such as GPS
+	// positions and camera serial numbers. Since that drops the orientation
+	// too, it is applied to the pixels first.
+	StripMetadata bool
+	// KeepICCProfile keeps the color profile when stripping metadata.
+	KeepICCProfile bool
+}
+
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// splitJPEG returns the segments of a JPEG before its scan data, and the
+// scan data and trailer, starting at the SOS marker, as is.
+func splitJPEG(data []byte) ([]jpegSegment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, nil, errNotJPEG
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for {
+		// Markers may be preceded by any number of 0xff fill bytes.
+		for pos < len(data) && data[pos] == 0xff {
+			pos++
+		}
+		if pos >= len(data) || data[pos-1] != 0xff {
+			return nil, nil, fmt.Errorf("malformed JPEG marker at offset %d", pos)
+		}
+		marker := data[pos]
+		if marker == markerSOS {
+			return segments, data[pos-1:], nil
+		}
+		if pos+3 > len(data) {
+			return nil, nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+1:]))
+		end := pos + 1 + length
+		if length < 2 || end > len(data) {
+			return nil, nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data[pos+3 : end]})
+		pos = end
+	}
+}
+
+func joinJPEG(segments []jpegSegment, scan []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, markerSOI})
+	for _, s := range segments {
+		buf.Write([]byte{0xff, s.marker})
+		binary.Write(&buf, binary.BigEndian, uint16(len(s.data)+2))
+		buf.Write(s.data)
+	}
+	buf.Write(scan)
+	return buf.Bytes()
+}
+
+func (s jpegSegment) isExif() bool {
+	return s.marker == markerAPP1 && bytes.HasPrefix(s.data, exifHeader)
+}
+
+func (s jpegSegment) isICC() bool {
+	return s.marker == markerAPP2 && bytes.HasPrefix(s.data, iccHeader)
+}
+
+// isMetadata reports segments that only describe the image. APP0 (JFIF)
+// and APP14 (Adobe) affect decoding and are not metadata.
+func (s jpegSegment) isMetadata() bool {
+	return s.marker == markerCOM ||
+		(s.marker >= markerAPP1 && s.marker <= markerAPP15 && s.marker != markerAPP14)
+}
+
+// exifOrientation finds the orientation tag in the first IFD of an EXIF
+// segment, returning its value and the offset of the value in data, or 0
+// when the tag is missing or malformed.
+func exifOrientation(data []byte) (int, int) {
+	tiff := data[len(exifHeader):]
+	if len(tiff) < 8 {
+		return 0, 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, 0
+		}
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+		value := int(order.Uint16(tiff[entry+8:]))
+		if value < 1 || value > 8 {
+			return 0, 0
+		}
+		return value, len(exifHeader) + entry + 8
+	}
+	return 0, 0
+}
+
+// JPEGOrientation returns the EXIF orientation of a JPEG, 1 to 8, or 1
+// when it has none.
+func JPEGOrientation(data []byte) int {
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return 1
+	}
+	for _, s := range segments {
+		if s.isExif() {
+			if orientation, _ := exifOrientation(s.data); orientation != 0 {
+				return orientation
+			}
+		}
+	}
+	return 1
+}
+
+// CleanJPEG applies opts to a JPEG file, reporting whether anything
+// changed. Pixels are only re-encoded when they have to be turned; metadata
+// alone is stripped without loss.
+func CleanJPEG(data []byte, opts JPEGOptions) ([]byte, bool, error) {
+	segments, scan, err := splitJPEG(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	orientation, exifIndex, valueOffset := 1, -1, 0
+	for i, s := range segments {
+		if s.isExif() {
+			if o, offset := exifOrientation(s.data); o != 0 {
+				orientation, exifIndex, valueOffset = o, i, offset
+				break
+			}
+		}
+	}
+
+	if orientation != 1 && (opts.AutoOrient || opts.StripMetadata) {
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode image: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, Orient(img, orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, false, fmt.Errorf("failed to encode image: %w", err)
+		}
+		encoded, encodedScan, err := splitJPEG(buf.Bytes())
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Carry the original metadata over, upright, unless stripping.
+		var kept []jpegSegment
+		for i, s := range segments {
+			switch {
+			case s.isICC() && (opts.KeepICCProfile || !opts.StripMetadata):
+				kept = append(kept, s)
+			case opts.StripMetadata || !s.isMetadata():
+			case i == exifIndex:
+				patched := append([]byte(nil), s.data...)
+				littleEndian := patched[len(exifHeader)] == 'I'
+				patched[valueOffset], patched[valueOffset+1] = 0, 1
+				if littleEndian {
+					patched[valueOffset], patched[valueOffset+1] = 1, 0
+				}
+				kept = append(kept, jpegSegment{marker: s.marker, data: patched})
+			default:
+				kept = append(kept, s)
+			}
+		}
+		return joinJPEG(append(kept, encoded...), encodedScan), true, nil
+	}
+
+	if !opts.StripMetadata {
+		return data, false, nil
+	}
+	kept := segments[:0:0]
+	for _, s := range segments {
+		if !s.isMetadata() || (s.isICC() && opts.KeepICCProfile) {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == len(segments) {
+		return data, false, nil
+	}
+	return joinJPEG(kept, scan), true, nil
+}
+
+// Orient turns an image stored with an EXIF orientation upright.
+// Orientations 5 to 8 swap width and height.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs turning 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs turning 90 counterclockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
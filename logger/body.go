@@ -0,0 +1,343 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultMaxBodyLength caps SafeBody output when no rule sets MaxLength.
+const DefaultMaxBodyLength = 4096
+
+// DefaultRedactFields are the fields SafeBody redacts when no rule sets
+// Fields.
+var DefaultRedactFields = []string{
+	"password", "password_confirmation", "passwd", "secret", "client_secret",
+	"token", "access_token", "refresh_token", "api_key", "authorization",
+	"card_number", "cvv",
+}
+
+// RedactionRules decide what SafeBody hides. Zero fields fall back to the
+// rules set with SetRedactionRules; an empty, non-nil Fields or Patterns
+// turns that kind of redaction off.
+type RedactionRules struct {
+	// Fields are keys whose values are replaced, whatever their type, in
+	// JSON and in key=value text. Matching ignores case.
+	Fields []string
+	// Patterns are replaced wherever they match in string values and text,
+	// as with SetMaskPatterns.
+	Patterns []*regexp.Regexp
+	// MaxLength caps the output, not counting the truncation note.
+	MaxLength int
+}
+
+var (
+	rulesMu   sync.RWMutex
+	bodyRules = RedactionRules{
+		Fields:    DefaultRedactFields,
+		Patterns:  []*regexp.Regexp{CreditCardPattern, JWTPattern},
+		MaxLength: DefaultMaxBodyLength,
+	}
+
+	// fieldPatterns caches the text pattern of each set of fields.
+	fieldPatterns sync.Map
+)
+
+// SetRedactionRules replaces the rules SafeBody applies where a call site
+// leaves them unset. A nil Fields or Patterns keeps the current ones.
+func SetRedactionRules(rules RedactionRules) {
+	rules = rules.withDefaults()
+	rulesMu.Lock()
+	bodyRules = rules
+	rulesMu.Unlock()
+}
+
+func (r RedactionRules) withDefaults() RedactionRules {
+	rulesMu.RLock()
+	defaults := bodyRules
+	rulesMu.RUnlock()
+
+	if r.Fields == nil {
+		r.Fields = defaults.Fields
+	}
+	if r.Patterns == nil {
+		r.Patterns = defaults.Patterns
+	}
+	if r.MaxLength <= 0 {
+		r.MaxLength = defaults.MaxLength
+	}
+	return r
+}
+
+// SafeBody renders a request or response body for logs. JSON has the
+// values of rules.Fields replaced and rules.Patterns masked in its strings;
+// other text has both applied as text. Output longer than rules.MaxLength
+// is cut and ends with the number of input bytes from the first value not
+// shown in full. Binary content is summarized by its length, detected
+// content type and SHA-256.
+// Large bodies are not copied: only what fits in the output is redacted.
+func SafeBody(data []byte, rules RedactionRules) string {
+	if len(data) == 0 {
+		return ""
+	}
+	rules = rules.withDefaults()
+
+	if isBinary(data) {
+		sum := sha256.Sum256(data)
+		return fmt.Sprintf("[binary %d bytes, %s, sha256:%s]", len(data), http.DetectContentType(data), hex.EncodeToString(sum[:]))
+	}
+
+	fields := make(map[string]bool, len(rules.Fields))
+	for _, f := range rules.Fields {
+		fields[strings.ToLower(f)] = true
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if out, ok := redactJSON(data, fields, rules); ok {
+			return out
+		}
+	}
+	return redactText(data, rules)
+}
+
+// isBinary looks at the start of data for NUL bytes, invalid UTF-8 or
+// mostly control characters.
+func isBinary(data []byte) bool {
+	sample := data[:min(len(data), 512)]
+	control, runes := 0, 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			// A rune cut by the end of the sample is not a sign of binary.
+			if len(sample) < len(data) && len(sample)-i < utf8.UTFMax && !utf8.FullRune(sample[i:]) {
+				break
+			}
+			return true
+		}
+		if r == 0 {
+			return true
+		}
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' {
+			control++
+		}
+		runes++
+		i += size
+	}
+	return control*10 > runes
+}
+
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	count     int
+}
+
+// redactJSON re-encodes data token by token, compacted, until the output
+// is full. At most twice MaxLength of input is decoded. It reports false
+// when data is not JSON after all.
+func redactJSON(data []byte, fields map[string]bool, rules RedactionRules) (string, bool) {
+	window := data
+	if len(window) > 2*rules.MaxLength {
+		window = window[:2*rules.MaxLength]
+	}
+	dec := json.NewDecoder(bytes.NewReader(window))
+	dec.UseNumber()
+
+	var (
+		b         strings.Builder
+		stack     []jsonFrame
+		topCount  int
+		skipDepth int
+		skipping  bool
+	)
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return b.String(), true
+		}
+		if err != nil {
+			if len(window) == len(data) {
+				return "", false
+			}
+			return b.String() + omitted(len(data)-int(offset)), true
+		}
+
+		if skipping {
+			if delim, ok := tok.(json.Delim); ok {
+				if delim == '{' || delim == '[' {
+					skipDepth++
+				} else {
+					skipDepth--
+				}
+			}
+			if skipDepth == 0 {
+				skipping = false
+			}
+			continue
+		}
+
+		var out strings.Builder
+		var frame *jsonFrame
+		if len(stack) > 0 {
+			frame = &stack[len(stack)-1]
+		}
+		closing := false
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			closing = true
+		}
+		if !closing {
+			switch {
+			case frame == nil && topCount > 0:
+				out.WriteByte('\n')
+			case frame != nil && frame.count > 0 && (!frame.object || frame.expectKey):
+				out.WriteByte(',')
+			}
+		}
+
+		isKey := frame != nil && frame.object && frame.expectKey && !closing
+		switch v := tok.(type) {
+		case json.Delim:
+			out.WriteRune(rune(v))
+		case string:
+			if isKey {
+				writeJSONString(&out, v)
+				out.WriteByte(':')
+				if fields[strings.ToLower(v)] {
+					out.WriteString(`"` + redacted + `"`)
+					skipping, skipDepth = true, 0
+				}
+			} else {
+				writeJSONString(&out, maskString(v, rules.Patterns))
+			}
+		case json.Number:
+			if masked := maskString(v.String(), rules.Patterns); masked != v.String() {
+				out.WriteString(`"` + redacted + `"`)
+			} else {
+				out.WriteString(v.String())
+			}
+		case bool:
+			fmt.Fprintf(&out, "%t", v)
+		case nil:
+			out.WriteString("null")
+		}
+
+		if room := rules.MaxLength - b.Len(); out.Len() > room {
+			b.WriteString(cutRunes(out.String(), room))
+			return b.String() + omitted(len(data)-int(offset)), true
+		}
+		b.WriteString(out.String())
+
+		switch {
+		case isKey && skipping:
+			// The redacted value stands in for the value to be skipped.
+			frame.expectKey = true
+			frame.count++
+		case isKey:
+			frame.expectKey = false
+		case closing:
+			stack = stack[:len(stack)-1]
+			valueDone(stack, &topCount)
+		default:
+			if delim, ok := tok.(json.Delim); ok {
+				stack = append(stack, jsonFrame{object: delim == '{', expectKey: delim == '{'})
+			} else {
+				valueDone(stack, &topCount)
+			}
+		}
+	}
+}
+
+func valueDone(stack []jsonFrame, topCount *int) {
+	if len(stack) == 0 {
+		*topCount++
+		return
+	}
+	frame := &stack[len(stack)-1]
+	frame.count++
+	if frame.object {
+		frame.expectKey = true
+	}
+}
+
+func writeJSONString(b *strings.Builder, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	b.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+func maskString(s string, patterns []*regexp.Regexp) string {
+	for _, p := range patterns {
+		s = redactPattern(p, s)
+	}
+	return s
+}
+
+// redactText cuts data to MaxLength first and then masks the fields, as
+// "field": value or field=value, and the patterns in what is left.
+func redactText(data []byte, rules RedactionRules) string {
+	text, rest := data, 0
+	if len(text) > rules.MaxLength {
+		cut := rules.MaxLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text, rest = text[:cut], len(data)-cut
+	}
+
+	s := string(text)
+	if p := fieldPattern(rules.Fields); p != nil {
+		s = redactPattern(p, s)
+	}
+	s = maskString(s, rules.Patterns)
+	if rest > 0 {
+		s += omitted(rest)
+	}
+	return s
+}
+
+func fieldPattern(fields []string) *regexp.Regexp {
+	if len(fields) == 0 {
+		return nil
+	}
+	key := strings.ToLower(strings.Join(fields, "\x00"))
+	if p, ok := fieldPatterns.Load(key); ok {
+		return p.(*regexp.Regexp)
+	}
+
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	names := strings.Join(quoted, "|")
+	p := regexp.MustCompile(`(?i)"(?:` + names + `)"\s*:\s*("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)|\b(?:` + names + `)=([^\s&,"\\]*)`)
+	fieldPatterns.Store(key, p)
+	return p
+}
+
+// cutRunes returns at most n bytes of s without splitting a rune.
+func cutRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func omitted(n int) string {
+	return fmt.Sprintf("... [%d bytes omitted]", n)
+}
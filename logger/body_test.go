@@ -0,0 +1,188 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+
+	"flugo.com/logger"
+)
+
+func TestSafeBodyRedactsJSON(t *testing.T) {
+	body := `{"email": "a@b.c", "Password": "hunter2", "profile": {"token": {"value": "t0k"}, "tags": ["x", 1]},
+		"card": "4111 1111 1111 1111", "amount": 4111111111111111, "ok": true, "note": null}`
+	got := logger.SafeBody([]byte(body), logger.RedactionRules{})
+
+	want := `{"email":"a@b.c","Password":"[REDACTED]","profile":{"token":"[REDACTED]","tags":["x",1]},` +
+		`"card":"[REDACTED]","amount":"[REDACTED]","ok":true,"note":null}`
+	if got != want {
+		t.Errorf("SafeBody =\n%s\nwant\n%s", got, want)
+	}
+	if !json.Valid([]byte(got)) {
+		t.Errorf("SafeBody returned invalid JSON %s", got)
+	}
+}
+
+func TestSafeBodyRedactsText(t *testing.T) {
+	got := logger.SafeBody([]byte("grant_type=password&password=hunter2&token=t0k&user=ann"), logger.RedactionRules{})
+	if got != "grant_type=password&password=[REDACTED]&token=[REDACTED]&user=ann" {
+		t.Errorf("SafeBody = %s", got)
+	}
+
+	// JSON that does not parse is redacted as text.
+	got = logger.SafeBody([]byte(`{"password": "hunter2", "user": "ann"`), logger.RedactionRules{})
+	if strings.Contains(got, "hunter2") || !strings.Contains(got, "ann") {
+		t.Errorf("SafeBody = %s", got)
+	}
+}
+
+func TestSafeBodyTruncates(t *testing.T) {
+	body := `{"password":"hunter2","filler":"` + strings.Repeat("x", 100) + `"}`
+	got := logger.SafeBody([]byte(body), logger.RedactionRules{MaxLength: 80})
+
+	want := `{"password":"[REDACTED]","filler":"` + strings.Repeat("x", 80-len(`{"password":"[REDACTED]","filler":"`)) +
+		fmt.Sprintf("... [%d bytes omitted]", len(body)-len(`{"password":"hunter2","filler"`))
+	if got != want {
+		t.Errorf("SafeBody = %s\nwant %s", got, want)
+	}
+
+	// Only whole tokens of the input window are shown.
+	body = `{"password":"hunter2","filler":"` + strings.Repeat("x", 1000) + `"}`
+	got = logger.SafeBody([]byte(body), logger.RedactionRules{MaxLength: 64})
+	if want := `{"password":"[REDACTED]","filler":... [1004 bytes omitted]`; got != want {
+		t.Errorf("SafeBody = %s, want %s", got, want)
+	}
+
+	got = logger.SafeBody([]byte(strings.Repeat("é", 100)), logger.RedactionRules{MaxLength: 5})
+	if got != "éé... [196 bytes omitted]" {
+		t.Errorf("SafeBody = %q, want whole runes", got)
+	}
+}
+
+func TestSafeBodySummarizesBinary(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	got := logger.SafeBody(png, logger.RedactionRules{})
+	if !strings.HasPrefix(got, "[binary 108 bytes, image/png, sha256:") {
+		t.Errorf("SafeBody = %s", got)
+	}
+}
+
+func TestSafeBodyRules(t *testing.T) {
+	body := []byte(`{"pin":"1234","password":"hunter2"}`)
+
+	got := logger.SafeBody(body, logger.RedactionRules{Fields: []string{"pin"}})
+	if got != `{"pin":"[REDACTED]","password":"hunter2"}` {
+		t.Errorf("per-call fields: SafeBody = %s", got)
+	}
+
+	logger.SetRedactionRules(logger.RedactionRules{Fields: []string{"pin", "password"}})
+	defer logger.SetRedactionRules(logger.RedactionRules{Fields: logger.DefaultRedactFields})
+	got = logger.SafeBody(body, logger.RedactionRules{})
+	if got != `{"pin":"[REDACTED]","password":"[REDACTED]"}` {
+		t.Errorf("global fields: SafeBody = %s", got)
+	}
+
+	got = logger.SafeBody([]byte(`{"id":"AB-123"}`), logger.RedactionRules{Patterns: []*regexp.Regexp{regexp.MustCompile(`AB-\d+`)}})
+	if got != `{"id":"[REDACTED]"}` {
+		t.Errorf("per-call patterns: SafeBody = %s", got)
+	}
+}
+
+func TestSafeBodyLargeBody(t *testing.T) {
+	body := `[` + strings.Repeat(`{"token":"t0k","n":1},`, 1<<20) + `{}]`
+	got := logger.SafeBody([]byte(body), logger.RedactionRules{MaxLength: 100})
+	if len(got) > 100+len("... [99999999 bytes omitted]") || strings.Contains(got, "t0k") {
+		t.Errorf("SafeBody = %s", got)
+	}
+}
+
+// TestSafeBodyNeverLeaksSecrets checks random bodies, cut at random
+// lengths, for the secret values planted in redacted fields.
+func TestSafeBodyNeverLeaksSecrets(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fields := []string{"password", "Token", "api_key"}
+	rules := func() logger.RedactionRules {
+		return logger.RedactionRules{Fields: fields, MaxLength: 1 + rng.Intn(400)}
+	}
+
+	for i := 0; i < 2000; i++ {
+		var secrets []string
+		secret := func() string {
+			s := fmt.Sprintf("zq%012x", rng.Int63())
+			secrets = append(secrets, s)
+			return s
+		}
+
+		var body []byte
+		switch i % 3 {
+		case 0:
+			v := randomJSON(rng, fields, secret, 3)
+			body, _ = json.MarshalIndent(v, "", strings.Repeat(" ", rng.Intn(3)))
+		case 1:
+			v := randomJSON(rng, fields, secret, 3)
+			body, _ = json.Marshal(v)
+			// Cut JSON is no longer valid and is redacted as text.
+			body = body[:rng.Intn(len(body)+1)]
+		default:
+			var pairs []string
+			for n := rng.Intn(6); n >= 0; n-- {
+				if rng.Intn(2) == 0 {
+					pairs = append(pairs, fields[rng.Intn(len(fields))]+"="+secret())
+				} else {
+					pairs = append(pairs, "name="+filler(rng))
+				}
+			}
+			body = []byte(strings.Join(pairs, "&"))
+		}
+
+		got := logger.SafeBody(body, rules())
+		for _, s := range secrets {
+			if strings.Contains(got, s) {
+				t.Fatalf("secret %s leaked from %s:\n%s", s, body, got)
+			}
+		}
+	}
+}
+
+func randomJSON(rng *rand.Rand, fields []string, secret func() string, depth int) interface{} {
+	if depth == 0 || rng.Intn(3) == 0 {
+		return filler(rng)
+	}
+	if rng.Intn(4) == 0 {
+		list := make([]interface{}, rng.Intn(4))
+		for i := range list {
+			list[i] = randomJSON(rng, fields, secret, depth-1)
+		}
+		return list
+	}
+
+	object := map[string]interface{}{}
+	for n := rng.Intn(5); n >= 0; n-- {
+		if rng.Intn(3) == 0 {
+			field := fields[rng.Intn(len(fields))]
+			if rng.Intn(2) == 0 {
+				field = strings.ToUpper(field)
+			}
+			if rng.Intn(4) == 0 {
+				object[field] = map[string]interface{}{"nested": secret()}
+			} else {
+				object[field] = secret()
+			}
+		} else {
+			object[filler(rng)] = randomJSON(rng, fields, secret, depth-1)
+		}
+	}
+	return object
+}
+
+func filler(rng *rand.Rand) string {
+	const letters = "abcdefghijklmnop "
+	b := make([]byte, 1+rng.Intn(30))
+	for i := range b {
+		b[i] = letters[rng.Intn(len(letters))]
+	}
+	return string(b)
+}
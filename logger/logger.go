@@ -1,11 +1,13 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -47,7 +49,8 @@ type Logger struct {
 	level  Level
 	format string
 	writer io.Writer
-	prefix string
+	file   *os.File
+	fields map[string]interface{}
 }
 
 var DefaultLogger *Logger
@@ -56,10 +59,12 @@ func Init(cfg *config.LoggerConfig) {
 	level := parseLevel(cfg.Level)
 
 	var writer io.Writer = os.Stdout
+	var output *os.File
 	if cfg.OutputFile != "" {
 		file, err := os.OpenFile(cfg.OutputFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 		if err == nil {
 			writer = io.MultiWriter(os.Stdout, file)
+			output = file
 		}
 	}
 
@@ -67,7 +72,14 @@ func Init(cfg *config.LoggerConfig) {
 		level:  level,
 		format: cfg.Format,
 		writer: writer,
-		prefix: "",
+		file:   output,
+	}
+}
+
+// SetOutput redirects DefaultLogger, e.g. to io.Discard in tests.
+func SetOutput(w io.Writer) {
+	if DefaultLogger != nil {
+		DefaultLogger.writer = w
 	}
 }
 
@@ -100,30 +112,98 @@ func (l *Logger) log(level Level, format string, args ...interface{}) {
 
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	levelName := levelNames[level]
-	message := fmt.Sprintf(format, args...)
+	// Redact before JSON escaping so quoted fields in the message still match.
+	message := redact(fmt.Sprintf(format, args...))
 
 	var logLine string
 	if l.format == "json" {
-		logLine = fmt.Sprintf(`{"timestamp":"%s","level":"%s","file":"%s:%d","message":"%s"}`,
-			timestamp, levelName, filename, line, message)
+		logLine = l.jsonLine(timestamp, levelName, fmt.Sprintf("%s:%d", filename, line), message)
 	} else {
 		color := levelColors[level]
 		if l.writer == os.Stdout {
-			logLine = fmt.Sprintf("%s[%s]%s %s %s:%d - %s",
-				color, levelName, colorReset, timestamp, filename, line, message)
+			logLine = fmt.Sprintf("%s[%s]%s %s %s:%d - %s%s",
+				color, levelName, colorReset, timestamp, filename, line, message, l.textFields())
 		} else {
-			logLine = fmt.Sprintf("[%s] %s %s:%d - %s",
-				levelName, timestamp, filename, line, message)
+			logLine = fmt.Sprintf("[%s] %s %s:%d - %s%s",
+				levelName, timestamp, filename, line, message, l.textFields())
 		}
 	}
 
-	fmt.Fprintln(l.writer, logLine)
+	fmt.Fprintln(l.writer, redact(logLine))
 
 	if level == FATAL {
 		os.Exit(1)
 	}
 }
 
+var reservedKeys = map[string]bool{
+	"timestamp": true,
+	"level":     true,
+	"file":      true,
+	"message":   true,
+}
+
+func (l *Logger) jsonLine(timestamp, level, file, message string) string {
+	var b strings.Builder
+	b.WriteString("{")
+	writeJSONPair(&b, "timestamp", timestamp, false)
+	writeJSONPair(&b, "level", level, true)
+	writeJSONPair(&b, "file", file, true)
+	writeJSONPair(&b, "message", message, true)
+
+	for _, key := range l.sortedFieldKeys() {
+		name := key
+		if reservedKeys[key] {
+			name = "fields." + key
+		}
+		writeJSONPair(&b, name, l.fields[key], true)
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
+
+func writeJSONPair(b *strings.Builder, key string, value interface{}, comma bool) {
+	if comma {
+		b.WriteString(",")
+	}
+
+	keyJSON, _ := json.Marshal(key)
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		valueJSON, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+
+	b.Write(keyJSON)
+	b.WriteString(":")
+	b.Write(valueJSON)
+}
+
+func (l *Logger) textFields() string {
+	if len(l.fields) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, key := range l.sortedFieldKeys() {
+		value := fmt.Sprintf("%v", l.fields[key])
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, value)
+	}
+	return b.String()
+}
+
+func (l *Logger) sortedFieldKeys() []string {
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (l *Logger) Trace(format string, args ...interface{}) {
 	l.log(TRACE, format, args...)
 }
@@ -148,13 +228,45 @@ func (l *Logger) Fatal(format string, args ...interface{}) {
 	l.log(FATAL, format, args...)
 }
 
-func (l *Logger) WithPrefix(prefix string) *Logger {
+// With returns a child logger carrying the parent's fields merged with the
+// given ones. The parent is never modified; child values win on conflicts.
+func (l *Logger) With(fields map[string]interface{}) *Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
 	return &Logger{
 		level:  l.level,
 		format: l.format,
 		writer: l.writer,
-		prefix: prefix,
+		file:   l.file,
+		fields: merged,
+	}
+}
+
+// Sync flushes the output file, if any, to disk.
+func (l *Logger) Sync() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
+func (l *Logger) WithPrefix(prefix string) *Logger {
+	return l.With(map[string]interface{}{"prefix": prefix})
+}
+
+func With(fields map[string]interface{}) *Logger {
+	if DefaultLogger != nil {
+		return DefaultLogger.With(fields)
 	}
+
+	base := &Logger{level: INFO, format: "text", writer: os.Stdout}
+	return base.With(fields)
 }
 
 func Trace(format string, args ...interface{}) {
@@ -204,3 +316,10 @@ func Fatal(format string, args ...interface{}) {
 		log.Fatalf("[FATAL] "+format, args...)
 	}
 }
+
+func Sync() error {
+	if DefaultLogger != nil {
+		return DefaultLogger.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"regexp"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// Built-in patterns for SetMaskPatterns. When a pattern has capture groups
+// only the captured text is replaced, so keys stay readable.
+var (
+	CreditCardPattern    = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	EmailPattern         = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	JWTPattern           = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	PasswordFieldPattern = regexp.MustCompile(`(?i)"(?:password|passwd|pwd|secret)"\s*:\s*"([^"]*)"|\b(?:password|passwd|pwd|secret)=([^\s&,"\\]+)`)
+)
+
+var (
+	maskMu       sync.RWMutex
+	maskPatterns []*regexp.Regexp
+)
+
+// SetMaskPatterns replaces the patterns redacted from every log line.
+// Duplicate patterns are ignored, so repeated calls are harmless.
+func SetMaskPatterns(patterns []*regexp.Regexp) {
+	seen := make(map[string]bool, len(patterns))
+	unique := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		if p == nil || seen[p.String()] {
+			continue
+		}
+		seen[p.String()] = true
+		unique = append(unique, p)
+	}
+
+	maskMu.Lock()
+	maskPatterns = unique
+	maskMu.Unlock()
+}
+
+func redact(s string) string {
+	maskMu.RLock()
+	patterns := maskPatterns
+	maskMu.RUnlock()
+
+	for _, p := range patterns {
+		s = redactPattern(p, s)
+	}
+	return s
+}
+
+func redactPattern(p *regexp.Regexp, s string) string {
+	if p.NumSubexp() == 0 {
+		return p.ReplaceAllString(s, redacted)
+	}
+
+	matches := p.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	out := make([]byte, 0, len(s))
+	last := 0
+	for _, m := range matches {
+		for g := 1; g*2 < len(m); g++ {
+			start, end := m[g*2], m[g*2+1]
+			if start < 0 || start < last {
+				continue
+			}
+			out = append(out, s[last:start]...)
+			out = append(out, redacted...)
+			last = end
+		}
+	}
+	out = append(out, s[last:]...)
+	return string(out)
+}
@@ -0,0 +1,323 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"flugo.com/logger"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+const (
+	defaultFailureThreshold = 0.5
+	defaultBreakerMinimum   = 20
+	defaultBreakerWindow    = 10 * time.Second
+	defaultRecoveryTime     = 30 * time.Second
+	defaultHalfOpenRequests = 3
+)
+
+type CircuitBreakerConfig struct {
+	// Name shares one breaker between the routes using it; empty gives
+	// each route its own, named by method and pattern. The first config
+	// registered under a name is the one used.
+	Name string
+	// FailureThreshold is the share of failed requests in a window, 0.5 by
+	// default, that opens the circuit once MinRequests (default 20) have
+	// been seen.
+	FailureThreshold float64
+	MinRequests      int
+	// Window is how long failures are counted before the counts restart;
+	// 10 seconds by default.
+	Window time.Duration
+	// RecoveryTime is how long the circuit stays open, 30 seconds by
+	// default, before HalfOpenRequests probes (default 3) are let through.
+	// The circuit closes when they all succeed and opens again on the first
+	// failure.
+	RecoveryTime     time.Duration
+	HalfOpenRequests int
+	// Classify reports whether a request failed. err is the panic value or
+	// the expired request context, if any. By default 5xx responses and
+	// errors count.
+	Classify func(status int, err error) bool
+}
+
+// DefaultClassify counts 5xx responses, panics and timeouts as failures.
+func DefaultClassify(status int, err error) bool {
+	return status >= 500 || err != nil
+}
+
+// BreakerStats is a snapshot of one breaker. Requests and Failures cover
+// the current window, or the probes while half open.
+type BreakerStats struct {
+	Name      string       `json:"name"`
+	State     BreakerState `json:"state"`
+	Requests  int          `json:"requests"`
+	Failures  int          `json:"failures"`
+	Opened    int64        `json:"opened"`
+	Rejected  int64        `json:"rejected"`
+	OpenSince *time.Time   `json:"open_since,omitempty"`
+}
+
+var breakers = struct {
+	sync.Mutex
+	byName map[string]*breaker
+}{byName: make(map[string]*breaker)}
+
+type breaker struct {
+	name   string
+	config CircuitBreakerConfig
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64
+	started    time.Time
+	requests   int
+	failures   int
+	probes     int
+	openedAt   time.Time
+	opened     int64
+	rejected   int64
+}
+
+func getBreaker(name string, config CircuitBreakerConfig) *breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.byName[name]
+	if !ok {
+		b = &breaker{name: name, config: config, state: BreakerClosed, started: time.Now()}
+		breakers.byName[name] = b
+	}
+	return b
+}
+
+// allow admits a request, returning the generation its outcome belongs to,
+// or how long until the circuit lets requests through again.
+func (b *breaker) allow(now time.Time) (uint64, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.openedAt.Add(b.config.RecoveryTime).Sub(now); wait > 0 {
+			b.rejected++
+			return 0, wait, false
+		}
+		b.transition(BreakerHalfOpen, now)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			b.rejected++
+			return 0, time.Second, false
+		}
+		b.probes++
+	default:
+		if now.Sub(b.started) >= b.config.Window {
+			b.started, b.requests, b.failures = now, 0, 0
+		}
+	}
+	return b.generation, 0, true
+}
+
+// done records the outcome of a request admitted in generation, ignoring
+// requests that finish after the state they were admitted in.
+func (b *breaker) done(generation uint64, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.transition(BreakerOpen, now)
+		} else if b.requests >= b.config.HalfOpenRequests {
+			b.transition(BreakerClosed, now)
+		}
+	case BreakerClosed:
+		if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureThreshold {
+			b.transition(BreakerOpen, now)
+		}
+	}
+}
+
+func (b *breaker) transition(state BreakerState, now time.Time) {
+	switch state {
+	case BreakerOpen:
+		logger.Warn("Circuit breaker %s opened after %d failures in %d requests", b.name, b.failures, b.requests)
+		b.openedAt = now
+		b.opened++
+	case BreakerClosed:
+		logger.Info("Circuit breaker %s closed", b.name)
+	}
+	b.state = state
+	b.generation++
+	b.started, b.requests, b.failures, b.probes = now, 0, 0, 0
+}
+
+func (b *breaker) stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		Name:     b.name,
+		State:    b.state,
+		Requests: b.requests,
+		Failures: b.failures,
+		Opened:   b.opened,
+		Rejected: b.rejected,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		stats.OpenSince = &openedAt
+	}
+	return stats
+}
+
+// CircuitBreaker fails requests fast with 503 and Retry-After while too
+// many recent ones have failed, so a melted-down dependency does not tie
+// up every worker in timeouts. It belongs inside Recovery; panics count as
+// failures and are re-raised. Requests that match no route pass through.
+func CircuitBreaker(config CircuitBreakerConfig) router.MiddlewareFunc {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultBreakerMinimum
+	}
+	if config.Window <= 0 {
+		config.Window = defaultBreakerWindow
+	}
+	if config.RecoveryTime <= 0 {
+		config.RecoveryTime = defaultRecoveryTime
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = defaultHalfOpenRequests
+	}
+	if config.Classify == nil {
+		config.Classify = DefaultClassify
+	}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			name := config.Name
+			if name == "" {
+				route := reqctx.Route(r)
+				if route == "" {
+					next(w, r)
+					return
+				}
+				name = r.Method + " " + route
+			}
+			b := getBreaker(name, config)
+
+			generation, wait, ok := b.allow(time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+				response.ErrorWithCode(w, http.StatusServiceUnavailable, "circuit_open", "Service temporarily unavailable", nil)
+				return
+			}
+
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				var err error
+				if recovered != nil {
+					err = fmt.Errorf("panic: %v", recovered)
+				} else if ctxErr := r.Context().Err(); errors.Is(ctxErr, context.DeadlineExceeded) {
+					err = ctxErr
+				}
+				status := rec.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				b.done(generation, b.config.Classify(status, err), time.Now())
+				if recovered != nil {
+					panic(recovered)
+				}
+			}()
+			next(rec, r)
+		}
+	}
+}
+
+// CircuitBreakerStats returns a snapshot of every breaker, by name.
+func CircuitBreakerStats() []BreakerStats {
+	breakers.Lock()
+	list := make([]*breaker, 0, len(breakers.byName))
+	for _, b := range breakers.byName {
+		list = append(list, b)
+	}
+	breakers.Unlock()
+
+	stats := make([]BreakerStats, len(list))
+	for i, b := range list {
+		stats[i] = b.stats()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// ResetCircuitBreaker closes the named breaker, reporting whether it
+// exists.
+func ResetCircuitBreaker(name string) bool {
+	breakers.Lock()
+	b, ok := breakers.byName[name]
+	breakers.Unlock()
+	if !ok {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerClosed {
+		b.transition(BreakerClosed, time.Now())
+	}
+	return true
+}
+
+// CircuitBreakerAdminHandler serves the breakers for admins: GET lists
+// their state and POST ?name= closes one. Mount it behind
+// auth.RequireRoles("admin").
+func CircuitBreakerAdminHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			response.Success(w, CircuitBreakerStats())
+
+		case http.MethodPost:
+			name := r.URL.Query().Get("name")
+			if !ResetCircuitBreaker(name) {
+				response.NotFound(w, "Circuit breaker not found")
+				return
+			}
+			reqctx.Logger(r).Warn("Circuit breaker %s reset by %s", name, r.RemoteAddr)
+			response.Success(w, map[string]interface{}{"name": name, "state": BreakerClosed}, "Circuit breaker reset")
+
+		default:
+			response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flugo.com/middleware"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var calls atomic.Int32
+
+	handler := middleware.CircuitBreaker(middleware.CircuitBreakerConfig{
+		Name:             "reports-db",
+		MinRequests:      4,
+		RecoveryTime:     50 * time.Millisecond,
+		HalfOpenRequests: 2,
+	})(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/reports", nil))
+		return w
+	}
+	state := func() middleware.BreakerState {
+		for _, s := range middleware.CircuitBreakerStats() {
+			if s.Name == "reports-db" {
+				return s.State
+			}
+		}
+		return ""
+	}
+
+	for i := 0; i < 4; i++ {
+		serve()
+	}
+	if state() != middleware.BreakerOpen {
+		t.Fatalf("state after 4 failures = %s", state())
+	}
+
+	w := serve()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" || calls.Load() != 4 {
+		t.Errorf("open circuit answered %d, Retry-After %q, after %d calls", w.Code, w.Header().Get("Retry-After"), calls.Load())
+	}
+
+	// A failed probe opens the circuit again.
+	time.Sleep(60 * time.Millisecond)
+	serve()
+	if state() != middleware.BreakerOpen || calls.Load() != 5 {
+		t.Fatalf("state after a failed probe = %s, %d calls", state(), calls.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	failing.Store(false)
+	serve()
+	if state() != middleware.BreakerHalfOpen {
+		t.Fatalf("state after one good probe = %s", state())
+	}
+	serve()
+	if state() != middleware.BreakerClosed {
+		t.Errorf("state after the probes succeeded = %s", state())
+	}
+}
+
+func TestCircuitBreakerCountsPanics(t *testing.T) {
+	handler := middleware.Recovery()(middleware.CircuitBreaker(middleware.CircuitBreakerConfig{
+		Name:        "panicky",
+		MinRequests: 2,
+	})(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	for i := 0; i < 3; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	for _, s := range middleware.CircuitBreakerStats() {
+		if s.Name == "panicky" && (s.State != middleware.BreakerOpen || s.Rejected != 1) {
+			t.Errorf("stats = %+v", s)
+		}
+	}
+
+	if !middleware.ResetCircuitBreaker("panicky") {
+		t.Fatal("ResetCircuitBreaker did not find the breaker")
+	}
+	for _, s := range middleware.CircuitBreakerStats() {
+		if s.Name == "panicky" && s.State != middleware.BreakerClosed {
+			t.Errorf("state after reset = %s", s.State)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flugo.com/logger"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/utils"
+)
+
+const (
+	defaultMaxCaptureBody = 64 << 10
+	defaultCaptureSize    = 100
+	defaultCaptureTTL     = time.Hour
+	redactedValue         = "[REDACTED]"
+)
+
+// DefaultRedactFields are the fields blanked in captured bodies when
+// CaptureOptions.RedactFields is nil. Matching ignores case.
+var DefaultRedactFields = []string{
+	"password", "password_confirmation", "token", "access_token", "refresh_token",
+	"secret", "client_secret", "api_key",
+}
+
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Capture is one recorded exchange. Bodies are kept up to the size cap;
+// the Truncated flags report when more was sent.
+type Capture struct {
+	ID                string        `json:"id"`
+	Time              time.Time     `json:"time"`
+	Duration          time.Duration `json:"duration"`
+	Method            string        `json:"method"`
+	Path              string        `json:"path"`
+	Query             string        `json:"query,omitempty"`
+	Route             string        `json:"route,omitempty"`
+	RequestID         string        `json:"request_id,omitempty"`
+	RemoteAddr        string        `json:"remote_addr"`
+	Status            int           `json:"status"`
+	RequestHeader     http.Header   `json:"request_header,omitempty"`
+	RequestBody       string        `json:"request_body,omitempty"`
+	RequestTruncated  bool          `json:"request_truncated,omitempty"`
+	ResponseHeader    http.Header   `json:"response_header,omitempty"`
+	ResponseBody      string        `json:"response_body,omitempty"`
+	ResponseTruncated bool          `json:"response_truncated,omitempty"`
+}
+
+// CaptureStore keeps captures and decides whether capturing is on, so it
+// can be switched at runtime without reinstalling the middleware.
+type CaptureStore interface {
+	Enabled() bool
+	SetEnabled(enabled bool)
+	Add(c Capture)
+	// List returns the captures that have not expired, newest first.
+	List() []Capture
+	Get(id string) (Capture, bool)
+}
+
+// CaptureBuffer is an in-memory CaptureStore holding the latest captures.
+// It starts disabled.
+type CaptureBuffer struct {
+	enabled atomic.Bool
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	captures []Capture
+	next     int
+	full     bool
+}
+
+// NewCaptureBuffer keeps up to size captures (100 when size is 0 or less)
+// for ttl (an hour when ttl is 0 or less).
+func NewCaptureBuffer(size int, ttl time.Duration) *CaptureBuffer {
+	if size <= 0 {
+		size = defaultCaptureSize
+	}
+	if ttl <= 0 {
+		ttl = defaultCaptureTTL
+	}
+	return &CaptureBuffer{captures: make([]Capture, size), ttl: ttl}
+}
+
+func (b *CaptureBuffer) Enabled() bool {
+	return b.enabled.Load()
+}
+
+func (b *CaptureBuffer) SetEnabled(enabled bool) {
+	b.enabled.Store(enabled)
+}
+
+func (b *CaptureBuffer) Add(c Capture) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.captures[b.next] = c
+	b.next = (b.next + 1) % len(b.captures)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+func (b *CaptureBuffer) List() []Capture {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.captures)
+	}
+
+	cutoff := time.Now().Add(-b.ttl)
+	list := make([]Capture, 0, count)
+	for i := 1; i <= count; i++ {
+		c := b.captures[(b.next-i+len(b.captures))%len(b.captures)]
+		if c.Time.Before(cutoff) {
+			break
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+func (b *CaptureBuffer) Get(id string) (Capture, bool) {
+	for _, c := range b.List() {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Capture{}, false
+}
+
+type CaptureOptions struct {
+	// Match selects the requests to capture, such as one partner's routes or
+	// client addresses; nil captures every request.
+	Match func(r *http.Request) bool
+	// MaxBodySize caps each stored body. Defaults to 64KB.
+	MaxBodySize int
+	// RedactFields are fields whose values are replaced before storage,
+	// in JSON and key=value bodies; nil uses DefaultRedactFields.
+	RedactFields []string
+}
+
+// DebugCapture records request and response bodies into store while the
+// store is enabled. The handler still reads the full request body, however
+// large; only the stored copy is capped. Authorization and cookie headers
+// and the configured fields are redacted before storage, by
+// logger.SafeBody, which also stores binary bodies as a summary.
+func DebugCapture(store CaptureStore, opts CaptureOptions) router.MiddlewareFunc {
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultMaxCaptureBody
+	}
+	if opts.RedactFields == nil {
+		opts.RedactFields = DefaultRedactFields
+	}
+	rules := logger.RedactionRules{Fields: opts.RedactFields, MaxLength: opts.MaxBodySize}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !store.Enabled() || opts.Match != nil && !opts.Match(r) {
+				next(w, r)
+				return
+			}
+
+			start := time.Now()
+			reqBody, reqTruncated := teeRequestBody(r, opts.MaxBodySize)
+			rec := &captureRecorder{ResponseWriter: w, limit: opts.MaxBodySize}
+			next(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			store.Add(Capture{
+				ID:                utils.NewID(),
+				Time:              start,
+				Duration:          time.Since(start),
+				Method:            r.Method,
+				Path:              r.URL.Path,
+				Query:             r.URL.RawQuery,
+				Route:             reqctx.Route(r),
+				RequestID:         w.Header().Get("X-Request-ID"),
+				RemoteAddr:        r.RemoteAddr,
+				Status:            rec.status,
+				RequestHeader:     redactHeader(r.Header),
+				RequestBody:       logger.SafeBody(reqBody, rules),
+				RequestTruncated:  reqTruncated,
+				ResponseHeader:    redactHeader(w.Header()),
+				ResponseBody:      logger.SafeBody(rec.body.Bytes(), rules),
+				ResponseTruncated: rec.overflow,
+			})
+		}
+	}
+}
+
+// teeRequestBody reads up to limit bytes of the body for the capture and
+// puts them back in front of the rest, so the handler reads the body as
+// sent without it being buffered whole.
+func teeRequestBody(r *http.Request, limit int) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, false
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(head), errReader{err}, r.Body), Closer: r.Body}
+	if len(head) > limit {
+		return head[:limit], true
+	}
+	return head, false
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader hands a read error hit while capturing to the handler, after
+// the bytes read before it.
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	return 0, io.EOF
+}
+
+// captureRecorder passes the response through, keeping the status and up
+// to limit bytes of body.
+type captureRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (rec *captureRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *captureRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if room := rec.limit - rec.body.Len(); len(b) > room {
+		rec.body.Write(b[:room])
+		rec.overflow = true
+	} else {
+		rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *captureRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range redactedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redactedValue)
+		}
+	}
+	return h
+}
+
+// CaptureAdminHandler serves store for admins: GET lists recent captures
+// without their bodies, GET ?id= returns one in full, and POST
+// ?enabled=true|false switches capturing. Mount it behind
+// auth.RequireRoles("admin").
+func CaptureAdminHandler(store CaptureStore) router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if id := r.URL.Query().Get("id"); id != "" {
+				capture, ok := store.Get(id)
+				if !ok {
+					response.NotFound(w, "Capture not found")
+					return
+				}
+				response.Success(w, capture)
+				return
+			}
+
+			captures := store.List()
+			for i := range captures {
+				captures[i].RequestHeader, captures[i].ResponseHeader = nil, nil
+				captures[i].RequestBody, captures[i].ResponseBody = "", ""
+			}
+			response.Success(w, map[string]interface{}{
+				"enabled":  store.Enabled(),
+				"captures": captures,
+			})
+
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				response.BadRequest(w, "enabled must be true or false")
+				return
+			}
+			store.SetEnabled(enabled)
+			reqctx.Logger(r).Warn("Debug capture enabled set to %v by %s", enabled, r.RemoteAddr)
+			response.Success(w, map[string]interface{}{"enabled": enabled}, "Debug capture updated")
+
+		default:
+			response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
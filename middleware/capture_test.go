@@ -0,0 +1,169 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/middleware"
+	"flugo.com/router"
+)
+
+// echo answers with the request body it read, as a JSON handler would.
+func echo(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+}
+
+func post(handler router.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer abc")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestDebugCaptureOffByDefault(t *testing.T) {
+	store := middleware.NewCaptureBuffer(10, 0)
+	handler := middleware.DebugCapture(store, middleware.CaptureOptions{})(echo)
+
+	post(handler, "/partners", `{"a":1}`)
+	if n := len(store.List()); n != 0 {
+		t.Fatalf("captured %d requests while disabled, want 0", n)
+	}
+
+	store.SetEnabled(true)
+	post(handler, "/partners", `{"a":1}`)
+	if n := len(store.List()); n != 1 {
+		t.Errorf("captured %d requests once enabled, want 1", n)
+	}
+}
+
+func TestDebugCaptureRecordsAndRedacts(t *testing.T) {
+	store := middleware.NewCaptureBuffer(10, 0)
+	store.SetEnabled(true)
+	handler := middleware.DebugCapture(store, middleware.CaptureOptions{})(echo)
+
+	body := `{"email":"a@b.c","password":"hunter2","nested":{"Token":"t0k"}}`
+	w := post(handler, "/partners", body)
+	if w.Body.String() != body {
+		t.Fatalf("handler read %q, want the body untouched", w.Body.String())
+	}
+
+	c := store.List()[0]
+	if c.Method != "POST" || c.Path != "/partners" || c.Status != http.StatusCreated {
+		t.Errorf("capture = %s %s %d", c.Method, c.Path, c.Status)
+	}
+	for _, stored := range []string{c.RequestBody, c.ResponseBody} {
+		if strings.Contains(stored, "hunter2") || strings.Contains(stored, "t0k") || !strings.Contains(stored, "a@b.c") {
+			t.Errorf("stored body %s, want secrets redacted and the rest kept", stored)
+		}
+	}
+	if got := c.RequestHeader.Get("Authorization"); got != "[REDACTED]" {
+		t.Errorf("Authorization = %q, want it redacted", got)
+	}
+
+	if got, ok := store.Get(c.ID); !ok || got.RequestBody != c.RequestBody {
+		t.Errorf("Get(%q) = %v, %v", c.ID, got, ok)
+	}
+}
+
+func TestDebugCaptureTruncatesWithoutBreakingHandler(t *testing.T) {
+	store := middleware.NewCaptureBuffer(10, 0)
+	store.SetEnabled(true)
+	handler := middleware.DebugCapture(store, middleware.CaptureOptions{MaxBodySize: 32})(echo)
+
+	body := `{"password":"hunter2","filler":"` + strings.Repeat("x", 100) + `"}`
+	w := post(handler, "/partners", body)
+	if w.Body.String() != body {
+		t.Fatalf("handler read %d bytes, want all %d", w.Body.Len(), len(body))
+	}
+
+	c := store.List()[0]
+	if !c.RequestTruncated || !c.ResponseTruncated {
+		t.Errorf("truncated = %v, %v, want both", c.RequestTruncated, c.ResponseTruncated)
+	}
+	if strings.Contains(c.RequestBody, "hunter2") || strings.Contains(c.ResponseBody, "hunter2") {
+		t.Errorf("truncated bodies %q, %q leak the password", c.RequestBody, c.ResponseBody)
+	}
+}
+
+func TestDebugCaptureMatch(t *testing.T) {
+	store := middleware.NewCaptureBuffer(10, 0)
+	store.SetEnabled(true)
+	handler := middleware.DebugCapture(store, middleware.CaptureOptions{
+		Match: func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/partners") },
+	})(echo)
+
+	post(handler, "/users", `{}`)
+	post(handler, "/partners/acme", `{}`)
+	if list := store.List(); len(list) != 1 || list[0].Path != "/partners/acme" {
+		t.Errorf("captures = %+v, want only /partners/acme", list)
+	}
+}
+
+func TestCaptureBufferKeepsLatestAndExpires(t *testing.T) {
+	store := middleware.NewCaptureBuffer(2, 50*time.Millisecond)
+	for _, id := range []string{"a", "b", "c"} {
+		store.Add(middleware.Capture{ID: id, Time: time.Now()})
+	}
+	if list := store.List(); len(list) != 2 || list[0].ID != "c" || list[1].ID != "b" {
+		t.Errorf("List() = %+v, want c then b", list)
+	}
+
+	store.Add(middleware.Capture{ID: "old", Time: time.Now().Add(-time.Second)})
+	if _, ok := store.Get("old"); ok {
+		t.Error("expired capture still listed")
+	}
+}
+
+func TestCaptureAdminHandler(t *testing.T) {
+	store := middleware.NewCaptureBuffer(10, 0)
+	admin := middleware.CaptureAdminHandler(store)
+
+	w := httptest.NewRecorder()
+	admin(w, httptest.NewRequest("POST", "/admin/captures?enabled=true", nil))
+	if w.Code != http.StatusOK || !store.Enabled() {
+		t.Fatalf("enable = %d, enabled %v", w.Code, store.Enabled())
+	}
+
+	post(middleware.DebugCapture(store, middleware.CaptureOptions{})(echo), "/partners", `{"a":1}`)
+
+	w = httptest.NewRecorder()
+	admin(w, httptest.NewRequest("GET", "/admin/captures", nil))
+	var list struct {
+		Data struct {
+			Enabled  bool                 `json:"enabled"`
+			Captures []middleware.Capture `json:"captures"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if !list.Data.Enabled || len(list.Data.Captures) != 1 || list.Data.Captures[0].RequestBody != "" {
+		t.Fatalf("list = %+v, want one capture without bodies", list.Data)
+	}
+
+	w = httptest.NewRecorder()
+	admin(w, httptest.NewRequest("GET", "/admin/captures?id="+list.Data.Captures[0].ID, nil))
+	if !strings.Contains(w.Body.String(), `{\"a\":1}`) {
+		t.Errorf("GET ?id= = %s, want the request body", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	admin(w, httptest.NewRequest("GET", "/admin/captures?id=missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET missing capture = %d, want 404", w.Code)
+	}
+}
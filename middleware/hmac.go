@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strings"
+
+	"flugo.com/logger"
+	"flugo.com/reqbody"
+	"flugo.com/router"
+)
+
+type HMACAlgo string
+
+const (
+	SHA1   HMACAlgo = "sha1"
+	SHA256 HMACAlgo = "sha256"
+	SHA512 HMACAlgo = "sha512"
+)
+
+func (a HMACAlgo) hash() func() hash.Hash {
+	switch a {
+	case SHA1:
+		return sha1.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+// VerifyHMAC rejects requests whose headerName does not carry the HMAC of the
+// raw body under secret. The signature may be hex or base64 and may carry an
+// algorithm prefix as GitHub sends it ("sha256=..."). The body is buffered
+// with reqbody, so the handler can read it again.
+func VerifyHMAC(secret string, headerName string, algo HMACAlgo) router.MiddlewareFunc {
+	newHash := algo.hash()
+
+	return router.NeedsBody(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			signature := r.Header.Get(headerName)
+			if signature == "" {
+				http.Error(w, "Missing signature", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := reqbody.Bytes(r)
+			if errors.Is(err, reqbody.ErrTooLarge) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+
+			mac := hmac.New(newHash, []byte(secret))
+			mac.Write(body)
+			if !signatureMatches(signature, algo, mac.Sum(nil)) {
+				logger.Warn("Invalid %s signature from %s", headerName, r.RemoteAddr)
+				http.Error(w, "Invalid signature", http.StatusUnauthorized)
+				return
+			}
+
+			next(w, r)
+		}
+	})
+}
+
+func signatureMatches(signature string, algo HMACAlgo, expected []byte) bool {
+	signature = strings.TrimSpace(signature)
+	if prefix, value, ok := strings.Cut(signature, "="); ok && strings.EqualFold(prefix, string(algo)) {
+		signature = value
+	}
+
+	if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+		return true
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return true
+		}
+	}
+	return false
+}
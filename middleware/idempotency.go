@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/cache"
+	"flugo.com/reqbody"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+const (
+	maxIdempotencyKey         = 255
+	defaultMaxIdempotentBody  = 1 << 20
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyStoreKeyPrefix = "idempotency:"
+)
+
+// IdempotentResponse is what an IdempotencyStore keeps for a key.
+type IdempotentResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// Fingerprint is a hash of the method and body of the request, which a
+	// request repeating the key must match.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+type IdempotencyStore interface {
+	Get(key string) (*IdempotentResponse, bool)
+	Set(key string, resp *IdempotentResponse, ttl time.Duration) error
+}
+
+type cacheIdempotencyStore struct {
+	cache *cache.Cache
+}
+
+// CacheIdempotencyStore keeps responses in c, or in cache.DefaultCache when c
+// is nil. Without a cache nothing is stored and every request runs.
+func CacheIdempotencyStore(c *cache.Cache) IdempotencyStore {
+	return &cacheIdempotencyStore{cache: c}
+}
+
+func (s *cacheIdempotencyStore) target() *cache.Cache {
+	if s.cache != nil {
+		return s.cache
+	}
+	return cache.DefaultCache
+}
+
+func (s *cacheIdempotencyStore) Get(key string) (*IdempotentResponse, bool) {
+	c := s.target()
+	if c == nil {
+		return nil, false
+	}
+	var resp IdempotentResponse
+	if !c.GetJSON(key, &resp) {
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (s *cacheIdempotencyStore) Set(key string, resp *IdempotentResponse, ttl time.Duration) error {
+	c := s.target()
+	if c == nil {
+		return nil
+	}
+	return c.SetJSON(key, resp, ttl)
+}
+
+type IdempotencyConfig struct {
+	Store IdempotencyStore
+	TTL   time.Duration
+	// WaitInFlight makes a repeated key wait for the first request and replay
+	// its response instead of answering 409 Conflict.
+	WaitInFlight bool
+	// MaxBodySize caps the stored response body; larger responses are sent
+	// but not stored. Defaults to 1MB.
+	MaxBodySize int
+}
+
+func Idempotency(store IdempotencyStore, ttl time.Duration) router.MiddlewareFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{Store: store, TTL: ttl})
+}
+
+// IdempotencyWithConfig replays the stored response when a POST, PUT, PATCH
+// or DELETE repeats an Idempotency-Key. Keys are scoped to the request path
+// and the caller's tenant, service client and user, so it belongs after
+// auth.RequireAuth or auth.OptionalAuth. A key repeated with another method
+// or body is answered 422. Server errors are not stored so the client can
+// retry. In-flight tracking is per process.
+func IdempotencyWithConfig(config IdempotencyConfig) router.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = CacheIdempotencyStore(nil)
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultMaxIdempotentBody
+	}
+
+	var mu sync.Mutex
+	inFlight := make(map[string]chan struct{})
+
+	return router.NeedsBody(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next(w, r)
+				return
+			}
+
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next(w, r)
+				return
+			}
+			if !validIdempotencyKey(key) {
+				response.BadRequest(w, "Invalid Idempotency-Key header")
+				return
+			}
+			fingerprint, err := requestFingerprint(r)
+			if errors.Is(err, reqbody.ErrTooLarge) {
+				response.ErrorWithCode(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large", nil)
+				return
+			}
+			if err != nil {
+				response.BadRequest(w, "Failed to read request body")
+				return
+			}
+			storeKey := idempotencyStoreKey(r, key)
+
+			for {
+				if stored, ok := config.Store.Get(storeKey); ok {
+					if stored.Fingerprint != "" && stored.Fingerprint != fingerprint {
+						response.ErrorWithCode(w, http.StatusUnprocessableEntity, "idempotency_key_reused",
+							"Idempotency-Key was already used for a different request", nil)
+						return
+					}
+					replayResponse(w, stored)
+					return
+				}
+
+				mu.Lock()
+				done, busy := inFlight[storeKey]
+				if !busy {
+					done = make(chan struct{})
+					inFlight[storeKey] = done
+				}
+				mu.Unlock()
+				if !busy {
+					break
+				}
+
+				if !config.WaitInFlight {
+					response.Conflict(w, "A request with this Idempotency-Key is still in progress")
+					return
+				}
+				select {
+				case <-done:
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			defer func() {
+				mu.Lock()
+				close(inFlight[storeKey])
+				delete(inFlight, storeKey)
+				mu.Unlock()
+			}()
+
+			rec := &idempotencyRecorder{ResponseWriter: w, limit: config.MaxBodySize}
+			next(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+				rec.header = w.Header().Clone()
+			}
+			if rec.status >= 500 || rec.overflow {
+				return
+			}
+			rec.header.Del("X-Request-ID")
+			err = config.Store.Set(storeKey, &IdempotentResponse{
+				Status:      rec.status,
+				Header:      rec.header,
+				Body:        rec.body.Bytes(),
+				Fingerprint: fingerprint,
+			}, config.TTL)
+			if err != nil {
+				reqctx.Logger(r).Warn("Failed to store idempotent response: %v", err)
+			}
+		}
+	})
+}
+
+// validIdempotencyKey accepts the characters UUIDs and common client-side
+// ID schemes use.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKey {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyStoreKey leaves the method out, so that reusing a key with
+// another method is caught by the fingerprint. Machine tokens have no user,
+// so the client ID keeps their keys apart, and the tenant keeps apart users
+// whose IDs overlap across tenants.
+func idempotencyStoreKey(r *http.Request, key string) string {
+	var tenant, client string
+	var user int
+	if claims := auth.GetCurrentUser(r); claims != nil {
+		tenant, client, user = claims.TenantID, claims.ClientID, claims.UserID
+	}
+	scope := strings.Join([]string{r.URL.Path, tenant, client, strconv.Itoa(user), key}, "\x00")
+	sum := sha256.Sum256([]byte(scope))
+	return idempotencyStoreKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// requestFingerprint hashes the method and body of r, reading the buffered
+// body without copying it.
+func requestFingerprint(r *http.Request) (string, error) {
+	body, err := reqbody.Buffer(r, reqbody.DefaultMaxMemory)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\x00")
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), body.Rewind()
+}
+
+func replayResponse(w http.ResponseWriter, stored *IdempotentResponse) {
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// idempotencyRecorder passes the response through while keeping a copy of
+// the status, headers and up to limit bytes of body.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > rec.limit {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,111 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/middleware"
+	"flugo.com/reqctx"
+)
+
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*middleware.IdempotentResponse
+}
+
+func (s *memoryIdempotencyStore) Get(key string) (*middleware.IdempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, ok := s.responses[key]
+	return resp, ok
+}
+
+func (s *memoryIdempotencyStore) Set(key string, resp *middleware.IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[key] = resp
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	store := &memoryIdempotencyStore{responses: map[string]*middleware.IdempotentResponse{}}
+	calls := 0
+	handler := middleware.Idempotency(store, time.Minute)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		echo(w, r)
+	})
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "k1")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	first := send("POST", "/orders/1", `{"qty":1}`)
+	replay := send("POST", "/orders/1", `{"qty":1}`)
+	if calls != 1 || replay.Body.String() != first.Body.String() || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay: calls = %d, body %q, headers %v", calls, replay.Body.String(), replay.Header())
+	}
+
+	// The key is scoped to the concrete path, not the route pattern.
+	if w := send("POST", "/orders/2", `{"qty":1}`); calls != 2 || w.Code != http.StatusCreated {
+		t.Errorf("other path: calls = %d, status %d; want the handler to run", calls, w.Code)
+	}
+
+	for _, tt := range []struct{ method, body string }{
+		{"POST", `{"qty":2}`},
+		{"PUT", `{"qty":1}`},
+	} {
+		w := send(tt.method, "/orders/1", tt.body)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "idempotency_key_reused") {
+			t.Errorf("%s %s: status %d, body %s; want 422", tt.method, tt.body, w.Code, w.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want a reused key never to reach the handler", calls)
+	}
+}
+
+func TestIdempotencyKeyScopedToCaller(t *testing.T) {
+	store := &memoryIdempotencyStore{responses: map[string]*middleware.IdempotentResponse{}}
+	calls := 0
+	handler := middleware.Idempotency(store, time.Minute)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		echo(w, r)
+	})
+	send := func(claims *auth.Claims) {
+		req := httptest.NewRequest("POST", "/payments", strings.NewReader(`{"amount":10}`))
+		req.Header.Set("Idempotency-Key", "retry-1")
+		if claims != nil {
+			req = reqctx.WithClaims(req, claims)
+		}
+		handler(httptest.NewRecorder(), req)
+	}
+
+	callers := []*auth.Claims{
+		nil,
+		{ClientID: "billing"},
+		{ClientID: "reports"},
+		{UserID: 7, TenantID: "acme"},
+		{UserID: 7, TenantID: "globex"},
+	}
+	for _, claims := range callers {
+		send(claims)
+	}
+	if calls != len(callers) {
+		t.Errorf("calls = %d, want every caller to get its own key namespace", calls)
+	}
+
+	for _, claims := range callers {
+		send(claims)
+	}
+	if calls != len(callers) {
+		t.Errorf("calls = %d after repeating, want every caller's response replayed", calls)
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"flugo.com/i18n"
+	"flugo.com/router"
+)
+
+// Locale resolves the request locale from the lang or locale query parameter,
+// then Accept-Language, falling back to the default locale. Only supported
+// locales are chosen, or the loaded catalogs when none are given. The result
+// is read back with i18n.FromContext and used by the response package.
+func Locale(supported ...string) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			candidates := supported
+			if len(candidates) == 0 {
+				candidates = i18n.DefaultCatalog.Locales()
+			}
+
+			locale := resolveLocale(r, candidates)
+			if locale == "" {
+				locale = i18n.DefaultLocale()
+			}
+
+			w.Header().Set("Content-Language", locale)
+			ctx := i18n.WithLocale(r.Context(), locale)
+			next(i18n.WrapWriter(w, locale), r.WithContext(ctx))
+		}
+	}
+}
+
+func resolveLocale(r *http.Request, supported []string) string {
+	for _, param := range []string{"lang", "locale"} {
+		if v := r.URL.Query().Get(param); v != "" {
+			if locale := matchLocale(v, supported); locale != "" {
+				return locale
+			}
+		}
+	}
+
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if locale := matchLocale(tag, supported); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// matchLocale prefers an exact match, then the same language: "pt-BR"
+// matches "pt", and "pt" matches "pt-BR".
+func matchLocale(tag string, supported []string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	lang, _, _ := strings.Cut(tag, "-")
+
+	for _, s := range supported {
+		if strings.EqualFold(s, tag) {
+			return s
+		}
+	}
+	for _, s := range supported {
+		sLang, _, _ := strings.Cut(strings.ReplaceAll(s, "_", "-"), "-")
+		if strings.EqualFold(sLang, lang) {
+			return s
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage returns language tags ordered by quality.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
@@ -1,11 +1,15 @@
 package middleware
 
 import (
-	"log"
+	"fmt"
 	"net/http"
 	"time"
 
+	"flugo.com/logger"
+	"flugo.com/reqctx"
+	"flugo.com/response"
 	"flugo.com/router"
+	"flugo.com/utils"
 )
 
 func CORS() router.MiddlewareFunc {
@@ -25,13 +29,53 @@ func CORS() router.MiddlewareFunc {
 	}
 }
 
+// RequestID tags each request with its X-Request-ID header, or a new ID when
+// the header is missing or unusable, echoes it on the response and attaches
+// a logger carrying it. Read it back with reqctx.RequestID.
+func RequestID() router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-ID")
+			if !validRequestID(id) {
+				id = utils.NewID()
+			}
+
+			w.Header().Set("X-Request-ID", id)
+			r = reqctx.WithRequestID(r, id)
+			r = reqctx.WithLogger(r, logger.With(map[string]interface{}{"request_id": id}))
+			next(w, r)
+		}
+	}
+}
+
+// validRequestID accepts short printable IDs so client values cannot break
+// log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger logs each request with its matched route through reqctx.Logger, so
+// the line carries the request ID when RequestID runs first.
 func Logger() router.MiddlewareFunc {
 	return func(next router.HandlerFunc) router.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			next(w, r)
 			duration := time.Since(start)
-			log.Printf("[%s] %s %s - %v", r.Method, r.URL.Path, r.RemoteAddr, duration)
+
+			route := reqctx.Route(r)
+			if route == "" {
+				route = "-"
+			}
+			reqctx.Logger(r).Info("[%s] %s (route %s) %s - %v", r.Method, r.URL.Path, route, r.RemoteAddr, duration)
 		}
 	}
 }
@@ -50,8 +94,8 @@ func Recovery() router.MiddlewareFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					log.Printf("Panic recovered: %v", err)
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					reqctx.Logger(r).Error("Panic recovered: %v", err)
+					response.ErrorWithCode(w, http.StatusInternalServerError, "internal_error", "Internal server error", fmt.Sprint(err))
 				}
 			}()
 			next(w, r)
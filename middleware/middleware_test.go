@@ -0,0 +1,44 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flugo.com/middleware"
+	"flugo.com/response"
+)
+
+func TestRecoveryRespondsWithRequestID(t *testing.T) {
+	defer response.SetDebug(false)
+
+	handler := middleware.RequestID()(middleware.Recovery()(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map write")
+	}))
+
+	for _, debug := range []bool{false, true} {
+		response.SetDebug(debug)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "req-42")
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON %q: %v", w.Body.String(), err)
+		}
+		if w.Code != http.StatusInternalServerError || body["request_id"] != "req-42" || body["code"] != "internal_error" {
+			t.Errorf("debug=%v: status %d, body %v", debug, w.Code, body)
+		}
+
+		details, ok := body["details"]
+		if debug && details != "nil map write" {
+			t.Errorf("details = %v in debug mode, want the panic value", details)
+		}
+		if !debug && ok {
+			t.Errorf("details = %v in production, want them stripped", details)
+		}
+	}
+}
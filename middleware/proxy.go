@@ -0,0 +1,378 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"flugo.com/router"
+)
+
+// ForwardingHeaders names the headers a load balancer sets. Proxies that
+// set one family usually pass the other through from the client, so only
+// the configured family is read.
+type ForwardingHeaders string
+
+const (
+	// XForwardedHeaders reads X-Forwarded-For, X-Forwarded-Proto and
+	// X-Forwarded-Host.
+	XForwardedHeaders ForwardingHeaders = "x-forwarded"
+	// ForwardedHeader reads the Forwarded header of RFC 7239.
+	ForwardedHeader ForwardingHeaders = "forwarded"
+)
+
+var (
+	xForwardedHeaders = []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host"}
+
+	// forwardingHeaders are removed from requests of untrusted peers, so
+	// nothing downstream can mistake them for a proxy's.
+	forwardingHeaders = append([]string{"Forwarded", "X-Real-IP"}, xForwardedHeaders...)
+)
+
+// ProxyHeaders applies the forwarding headers of load balancers: for
+// requests whose peer address is in trustedCIDRs, the scheme, host and
+// client address set by the headers family replace r.URL.Scheme, r.Host
+// and r.RemoteAddr, so reqctx.BaseURL and client addresses match what the
+// client used. Addresses in the chain that are themselves trusted are
+// skipped. The other family is removed, as are all forwarding headers of
+// requests from other peers, which are otherwise left as they arrived.
+// Entries may be CIDRs or plain addresses; ProxyHeaders panics on any
+// other, and on an unknown family.
+func ProxyHeaders(trustedCIDRs []string, headers ForwardingHeaders) router.MiddlewareFunc {
+	var ignored []string
+	switch headers {
+	case XForwardedHeaders:
+		ignored = []string{"Forwarded"}
+	case ForwardedHeader:
+		ignored = xForwardedHeaders
+	default:
+		panic(fmt.Sprintf("middleware: unknown forwarding headers %q", headers))
+	}
+
+	trusted := make(trustedProxies, 0, len(trustedCIDRs))
+	for _, entry := range trustedCIDRs {
+		prefix, err := parseTrustedProxy(entry)
+		if err != nil {
+			panic(fmt.Sprintf("middleware: invalid trusted proxy %q: %v", entry, err))
+		}
+		trusted = append(trusted, prefix)
+	}
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			peer, ok := addrOf(r.RemoteAddr)
+			if !ok || !trusted.contains(peer) {
+				next(w, withoutHeaders(r, forwardingHeaders))
+				return
+			}
+
+			r = withoutHeaders(r, ignored)
+			var hop proxyHop
+			if headers == ForwardedHeader {
+				hop, ok = forwardedHop(r.Header, trusted)
+			} else {
+				hop, ok = xForwardedHop(r.Header, trusted)
+			}
+			if !ok {
+				next(w, r)
+				return
+			}
+
+			r = r.WithContext(r.Context())
+			u := *r.URL
+			r.URL = &u
+			if hop.proto != "" {
+				r.URL.Scheme = hop.proto
+			}
+			if hop.host != "" {
+				r.Host = hop.host
+			}
+			if hop.addr != "" {
+				r.RemoteAddr = hop.addr
+			}
+			next(w, r)
+		}
+	}
+}
+
+type trustedProxies []netip.Prefix
+
+func (t trustedProxies) contains(addr netip.Addr) bool {
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTrustedProxy(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// addrOf parses an address with or without a port.
+func addrOf(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func withoutHeaders(r *http.Request, names []string) *http.Request {
+	found := false
+	for _, name := range names {
+		if _, ok := r.Header[name]; ok {
+			found = true
+		}
+	}
+	if !found {
+		return r
+	}
+
+	r = r.WithContext(r.Context())
+	r.Header = r.Header.Clone()
+	for _, name := range names {
+		r.Header.Del(name)
+	}
+	return r
+}
+
+// proxyHop is what the outermost trusted proxy saw of the client. Empty
+// fields were not forwarded or not valid.
+type proxyHop struct {
+	proto string
+	host  string
+	addr  string
+}
+
+// forwardedHop reads the Forwarded header. Each proxy appends an element
+// describing the connection it received, so the elements are walked from
+// the last while their "for" is a trusted proxy; the element where the walk
+// stops was written by the outermost trusted proxy. A malformed header is
+// ignored as a whole.
+func forwardedHop(header http.Header, trusted trustedProxies) (proxyHop, bool) {
+	values := header.Values("Forwarded")
+	if len(values) == 0 {
+		return proxyHop{}, false
+	}
+	elements, err := parseForwarded(strings.Join(values, ","))
+	if err != nil || len(elements) == 0 {
+		return proxyHop{}, false
+	}
+
+	var element map[string]string
+	for i := len(elements) - 1; i >= 0; i-- {
+		element = elements[i]
+		addr, ok := addrOf(element["for"])
+		if !ok || !trusted.contains(addr) {
+			break
+		}
+	}
+
+	hop := proxyHop{proto: forwardedProto(element["proto"]), host: forwardedHost(element["host"])}
+	if addr, ok := forwardedAddr(element["for"]); ok {
+		hop.addr = addr
+	}
+	return hop, true
+}
+
+// xForwardedHop reads the X-Forwarded-* headers the same way. A single
+// proto or host is taken as set by the trusted peer, since load balancers
+// such as ALB and nginx overwrite those headers while appending to
+// X-Forwarded-For. Longer lists that do not line up with X-Forwarded-For
+// cannot be attributed to a proxy and are ignored.
+func xForwardedHop(header http.Header, trusted trustedProxies) (proxyHop, bool) {
+	chain := headerList(header, "X-Forwarded-For")
+	protos := headerList(header, "X-Forwarded-Proto")
+	hosts := headerList(header, "X-Forwarded-Host")
+	if len(chain) == 0 && len(protos) == 0 && len(hosts) == 0 {
+		return proxyHop{}, false
+	}
+
+	var hop proxyHop
+	index := len(chain) - 1
+	for ; index >= 0; index-- {
+		addr, ok := addrOf(chain[index])
+		if !ok {
+			break
+		}
+		hop.addr = addr.String()
+		if !trusted.contains(addr) {
+			break
+		}
+	}
+	index = max(index, 0)
+
+	pick := func(list []string) string {
+		switch len(list) {
+		case 0:
+			return ""
+		case len(chain):
+			return list[index]
+		case 1:
+			return list[0]
+		}
+		return ""
+	}
+	hop.proto = forwardedProto(pick(protos))
+	hop.host = forwardedHost(pick(hosts))
+	return hop, true
+}
+
+func headerList(header http.Header, name string) []string {
+	var list []string
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+func forwardedProto(proto string) string {
+	proto = strings.ToLower(proto)
+	if proto == "http" || proto == "https" {
+		return proto
+	}
+	return ""
+}
+
+// forwardedHost accepts a host name or address with an optional port.
+func forwardedHost(host string) string {
+	if host == "" || len(host) > 255 {
+		return ""
+	}
+	for _, c := range host {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_', c == ':', c == '[', c == ']':
+		default:
+			return ""
+		}
+	}
+	return host
+}
+
+// forwardedAddr turns a node of the Forwarded header, such as "192.0.2.60",
+// "[2001:db8::1]:4711" or "unknown", into a RemoteAddr.
+func forwardedAddr(node string) (string, bool) {
+	addr, ok := addrOf(node)
+	if !ok {
+		return "", false
+	}
+	if _, port, err := net.SplitHostPort(node); err == nil && port != "" {
+		return net.JoinHostPort(addr.String(), port), true
+	}
+	return addr.String(), true
+}
+
+// parseForwarded parses a Forwarded header into its elements, each a map
+// of lowercased parameter names to unquoted values:
+//
+//	for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"
+func parseForwarded(s string) ([]map[string]string, error) {
+	var elements []map[string]string
+	element := map[string]string{}
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i == len(s) {
+			break
+		}
+		if s[i] == ',' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(s) && isTokenChar(s[i]) {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		if name == "" || i == len(s) || s[i] != '=' {
+			return nil, fmt.Errorf("expected parameter at offset %d", start)
+		}
+		i++
+
+		var value string
+		if i < len(s) && s[i] == '"' {
+			var b strings.Builder
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated quoted value")
+			}
+			i++
+			value = b.String()
+		} else {
+			start = i
+			for i < len(s) && isTokenChar(s[i]) {
+				i++
+			}
+			value = s[start:i]
+			if value == "" {
+				return nil, fmt.Errorf("empty value for %s", name)
+			}
+		}
+		if _, dup := element[name]; dup {
+			return nil, fmt.Errorf("duplicate parameter %s", name)
+		}
+		element[name] = value
+
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i == len(s) {
+			break
+		}
+		switch s[i] {
+		case ';':
+		case ',':
+			if len(element) > 0 {
+				elements = append(elements, element)
+			}
+			element = map[string]string{}
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", s[i], i)
+		}
+		i++
+	}
+	if len(element) > 0 {
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+// isTokenChar reports whether c may appear in an HTTP token (RFC 7230).
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,226 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flugo.com/middleware"
+	"flugo.com/reqctx"
+)
+
+type proxied struct {
+	baseURL    string
+	remoteAddr string
+	header     http.Header
+}
+
+func throughProxyHeaders(t *testing.T, family middleware.ForwardingHeaders, remoteAddr string, headers map[string]string) proxied {
+	t.Helper()
+	var got proxied
+	handler := middleware.ProxyHeaders([]string{"10.0.0.0/8", "fd00::1"}, family)(func(w http.ResponseWriter, r *http.Request) {
+		got = proxied{baseURL: reqctx.BaseURL(r), remoteAddr: r.RemoteAddr, header: r.Header}
+	})
+
+	req := httptest.NewRequest("GET", "/exports", nil)
+	req.Host = "app.internal:8080"
+	req.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	handler(httptest.NewRecorder(), req)
+	return got
+}
+
+func TestProxyHeadersFromTrustedProxy(t *testing.T) {
+	x, fwd := middleware.XForwardedHeaders, middleware.ForwardedHeader
+	tests := []struct {
+		name       string
+		family     middleware.ForwardingHeaders
+		peer       string
+		headers    map[string]string
+		baseURL    string
+		remoteAddr string
+	}{
+		{
+			name:   "x-forwarded",
+			family: x,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https, http",
+				"X-Forwarded-Host":  "api.example.com, lb.internal",
+				"X-Forwarded-For":   "203.0.113.7, 10.0.0.9",
+			},
+			baseURL:    "https://api.example.com",
+			remoteAddr: "203.0.113.7",
+		},
+		{
+			name:       "forwarded",
+			family:     fwd,
+			peer:       "10.0.0.5:41000",
+			headers:    map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host=api.example.com`},
+			baseURL:    "https://api.example.com",
+			remoteAddr: "192.0.2.60",
+		},
+		{
+			name:       "forwarded ipv6 peer and client",
+			family:     fwd,
+			peer:       "[fd00::1]:41000",
+			headers:    map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711";Proto=HTTPS;Host="api.example.com:8443"`},
+			baseURL:    "https://api.example.com:8443",
+			remoteAddr: "[2001:db8:cafe::17]:4711",
+		},
+		{
+			name:   "x-forwarded is ignored in forwarded mode",
+			family: fwd,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"Forwarded":        `for=192.0.2.60;proto=https;host=api.example.com`,
+				"X-Forwarded-Host": "other.example.com",
+			},
+			baseURL:    "https://api.example.com",
+			remoteAddr: "192.0.2.60",
+		},
+		{
+			// The proxy sets X-Forwarded-* and passes the client's Forwarded
+			// header through.
+			name:   "forwarded is ignored in x-forwarded mode",
+			family: x,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"Forwarded":         `for=198.51.100.1;proto=https;host=evil.example`,
+				"X-Forwarded-For":   "203.0.113.7",
+				"X-Forwarded-Proto": "http",
+			},
+			baseURL:    "http://app.internal:8080",
+			remoteAddr: "203.0.113.7",
+		},
+		{
+			// The client sent the first element itself; the load balancer
+			// appended the second.
+			name:       "client elements before the proxy's are ignored",
+			family:     fwd,
+			peer:       "10.0.0.5:41000",
+			headers:    map[string]string{"Forwarded": `for=198.51.100.1;host=evil.example, for=203.0.113.7;proto=https;host=api.example.com`},
+			baseURL:    "https://api.example.com",
+			remoteAddr: "203.0.113.7",
+		},
+		{
+			name:       "chained trusted proxies are skipped",
+			family:     fwd,
+			peer:       "10.0.0.5:41000",
+			headers:    map[string]string{"Forwarded": `for=203.0.113.7;proto=https;host=api.example.com, for=10.0.0.9;proto=http;host=lb.internal`},
+			baseURL:    "https://api.example.com",
+			remoteAddr: "203.0.113.7",
+		},
+		{
+			name:   "spoofed x-forwarded-for entries are ignored",
+			family: x,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1, 203.0.113.7",
+				"X-Forwarded-Proto": "http, https",
+			},
+			baseURL:    "https://app.internal:8080",
+			remoteAddr: "203.0.113.7",
+		},
+		{
+			// ALB and nginx overwrite the proto and host but append to
+			// X-Forwarded-For, here after a CDN.
+			name:   "single proto and host set by the peer",
+			family: x,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7, 198.51.100.20",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "api.example.com",
+			},
+			baseURL:    "https://api.example.com",
+			remoteAddr: "198.51.100.20",
+		},
+		{
+			name:   "lists that do not line up are ignored",
+			family: x,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1, 203.0.113.7",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.example, api.example.com, lb.internal",
+			},
+			baseURL:    "https://app.internal:8080",
+			remoteAddr: "203.0.113.7",
+		},
+		{
+			name:   "invalid values are ignored",
+			family: x,
+			peer:   "10.0.0.5:41000",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "javascript",
+				"X-Forwarded-Host":  "evil.example/path",
+				"X-Forwarded-For":   "203.0.113.7",
+			},
+			baseURL:    "http://app.internal:8080",
+			remoteAddr: "203.0.113.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := throughProxyHeaders(t, tt.family, tt.peer, tt.headers)
+			if got.baseURL != tt.baseURL || got.remoteAddr != tt.remoteAddr {
+				t.Errorf("got %s from %s, want %s from %s", got.baseURL, got.remoteAddr, tt.baseURL, tt.remoteAddr)
+			}
+		})
+	}
+}
+
+func TestProxyHeadersIgnoredFromUntrustedPeer(t *testing.T) {
+	got := throughProxyHeaders(t, middleware.XForwardedHeaders, "203.0.113.7:52000", map[string]string{
+		"Forwarded":         `for=192.0.2.60;proto=https;host=evil.example`,
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "evil.example",
+		"X-Forwarded-For":   "10.0.0.5",
+		"X-Real-IP":         "10.0.0.5",
+	})
+
+	if got.baseURL != "http://app.internal:8080" || got.remoteAddr != "203.0.113.7:52000" {
+		t.Errorf("got %s from %s, want the request unchanged", got.baseURL, got.remoteAddr)
+	}
+	for _, name := range []string{"Forwarded", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-For", "X-Real-IP"} {
+		if value := got.header.Get(name); value != "" {
+			t.Errorf("%s = %q reached the handler", name, value)
+		}
+	}
+}
+
+func TestMalformedForwardedHeaderIsIgnored(t *testing.T) {
+	for _, header := range []string{
+		`for=`,
+		`for="192.0.2.60`,
+		`=192.0.2.60`,
+		`for=192.0.2.60 host=evil.example`,
+		`for=192.0.2.60;for=198.51.100.1`,
+		`for=192.0.2.60;proto=https"`,
+		`for=192.0.2.60;host=a@evil.example`,
+	} {
+		got := throughProxyHeaders(t, middleware.ForwardedHeader, "10.0.0.5:41000", map[string]string{"Forwarded": header})
+		if got.baseURL != "http://app.internal:8080" || got.remoteAddr != "10.0.0.5:41000" {
+			t.Errorf("Forwarded %q: got %s from %s, want the request unchanged", header, got.baseURL, got.remoteAddr)
+		}
+	}
+}
+
+func TestProxyHeadersPanicsOnInvalidConfig(t *testing.T) {
+	for name, build := range map[string]func(){
+		"cidr":   func() { middleware.ProxyHeaders([]string{"10.0.0.0/33"}, middleware.XForwardedHeaders) },
+		"family": func() { middleware.ProxyHeaders([]string{"10.0.0.0/8"}, "x-real-ip") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ProxyHeaders accepted an invalid %s", name)
+				}
+			}()
+			build()
+		}()
+	}
+}
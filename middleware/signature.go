@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flugo.com/httpclient"
+	"flugo.com/logger"
+	"flugo.com/reqbody"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// SignatureTolerance is how far the timestamp of a signed request may be
+// from the server's clock.
+var SignatureTolerance = 5 * time.Minute
+
+type signatureKeyIDKey struct{}
+
+// VerifySignature authenticates internal callers that sign requests with
+// httpclient.Signer instead of sending a JWT. keyLookup returns the secret
+// of a key ID, or an error for unknown keys. Failures answer 401 with the
+// code missing_signature, malformed_signature, clock_skew, unknown_key,
+// invalid_signature or body_mismatch. The verified key ID is read back with
+// SignatureKeyID.
+func VerifySignature(keyLookup func(keyID string) ([]byte, error)) router.MiddlewareFunc {
+	return router.NeedsBody(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				response.ErrorWithCode(w, http.StatusUnauthorized, "missing_signature", "Missing request signature", nil)
+				return
+			}
+			sig, err := httpclient.ParseSignature(header)
+			if err != nil {
+				response.ErrorWithCode(w, http.StatusUnauthorized, "malformed_signature", "Malformed request signature", nil)
+				return
+			}
+			timestamp := r.Header.Get(httpclient.TimestampHeader)
+			declaredHash := r.Header.Get(httpclient.ContentHashHeader)
+			signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil || declaredHash == "" {
+				response.ErrorWithCode(w, http.StatusUnauthorized, "malformed_signature", "Malformed request signature", nil)
+				return
+			}
+
+			if skew := time.Since(time.Unix(signedAt, 0)).Abs(); skew > SignatureTolerance {
+				logger.Warn("Signed request from key %s is %v off the server clock", sig.KeyID, skew.Round(time.Second))
+				response.ErrorWithCode(w, http.StatusUnauthorized, "clock_skew", "Request timestamp outside the allowed clock skew", nil)
+				return
+			}
+
+			secret, err := keyLookup(sig.KeyID)
+			if err != nil || len(secret) == 0 {
+				logger.Warn("Signed request with unknown key %s from %s", sig.KeyID, r.RemoteAddr)
+				response.ErrorWithCode(w, http.StatusUnauthorized, "unknown_key", "Unknown signing key", nil)
+				return
+			}
+
+			expected := httpclient.SignString(secret, httpclient.StringToSign(r, sig.SignedHeaders, timestamp, declaredHash))
+			if !hmac.Equal([]byte(sig.Value), []byte(expected)) {
+				logger.Warn("Invalid signature for key %s from %s", sig.KeyID, r.RemoteAddr)
+				response.ErrorWithCode(w, http.StatusUnauthorized, "invalid_signature", "Invalid request signature", nil)
+				return
+			}
+
+			body, err := reqbody.Bytes(r)
+			if errors.Is(err, reqbody.ErrTooLarge) {
+				response.ErrorWithCode(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large", nil)
+				return
+			}
+			if err != nil {
+				response.BadRequest(w, "Failed to read request body")
+				return
+			}
+			// The signature covers the declared hash, so a valid signature
+			// with a different body means the body changed in transit.
+			sum := sha256.Sum256(body)
+			if !hmac.Equal([]byte(hex.EncodeToString(sum[:])), []byte(declaredHash)) {
+				logger.Warn("Body of signed request for key %s does not match its hash", sig.KeyID)
+				response.ErrorWithCode(w, http.StatusUnauthorized, "body_mismatch", "Request body does not match its signature", nil)
+				return
+			}
+
+			next(w, r.WithContext(context.WithValue(r.Context(), signatureKeyIDKey{}, sig.KeyID)))
+		}
+	})
+}
+
+// SignatureKeyID returns the key ID VerifySignature accepted for r.
+func SignatureKeyID(r *http.Request) string {
+	keyID, _ := r.Context().Value(signatureKeyIDKey{}).(string)
+	return keyID
+}
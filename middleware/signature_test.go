@@ -0,0 +1,149 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flugo.com/container"
+	"flugo.com/httpclient"
+	"flugo.com/middleware"
+	"flugo.com/router"
+	"flugo.com/utils"
+)
+
+var signingKeys = map[string][]byte{"reports": []byte("reports-secret")}
+
+func lookupSigningKey(keyID string) ([]byte, error) {
+	if secret, ok := signingKeys[keyID]; ok {
+		return secret, nil
+	}
+	return nil, errors.New("unknown key")
+}
+
+func signedServer(t *testing.T) *httptest.Server {
+	r := router.NewRouter(container.NewContainer())
+	r.POST("/reports", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(middleware.SignatureKeyID(r) + ":" + string(body)))
+	}, middleware.VerifySignature(lookupSigningKey))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// tamper rewrites requests after they were signed.
+type tamper func(req *http.Request)
+
+func (f tamper) RoundTrip(req *http.Request) (*http.Response, error) {
+	f(req)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func signedPost(t *testing.T, srv *httptest.Server, signer *httpclient.Signer, transport http.RoundTripper) *httpclient.Response {
+	client := httpclient.New(httpclient.Options{
+		BaseURL:     srv.URL,
+		Signer:      signer,
+		Transport:   transport,
+		RetryPolicy: utils.RetryPolicy{MaxAttempts: 1},
+	})
+	resp, err := client.Post(context.Background(), "/reports?day=2026-10-15&format=csv", "text/plain", []byte("totals"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func errorCode(t *testing.T, resp *httpclient.Response) string {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		t.Fatalf("invalid JSON %q: %v", resp.Body, err)
+	}
+	return body.Code
+}
+
+func TestSignedRequestIsAccepted(t *testing.T) {
+	srv := signedServer(t)
+
+	resp := signedPost(t, srv, &httpclient.Signer{KeyID: "reports", Secret: signingKeys["reports"]}, nil)
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "reports:totals" {
+		t.Fatalf("got %d %q, want 200 with the key ID and the body", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestSignatureFailuresHaveDistinctCodes(t *testing.T) {
+	srv := signedServer(t)
+	valid := &httpclient.Signer{KeyID: "reports", Secret: signingKeys["reports"]}
+
+	tests := []struct {
+		name      string
+		signer    *httpclient.Signer
+		transport http.RoundTripper
+		code      string
+	}{
+		{"unsigned", nil, nil, "missing_signature"},
+		{
+			name:   "clock skew",
+			signer: &httpclient.Signer{KeyID: "reports", Secret: signingKeys["reports"], Now: func() time.Time { return time.Now().Add(-time.Hour) }},
+			code:   "clock_skew",
+		},
+		{
+			name:   "unknown key",
+			signer: &httpclient.Signer{KeyID: "billing", Secret: []byte("billing-secret")},
+			code:   "unknown_key",
+		},
+		{
+			name:   "wrong secret",
+			signer: &httpclient.Signer{KeyID: "reports", Secret: []byte("guessed")},
+			code:   "invalid_signature",
+		},
+		{
+			name:   "altered query",
+			signer: valid,
+			transport: tamper(func(req *http.Request) {
+				req.URL.RawQuery = "day=2026-10-16&format=csv"
+			}),
+			code: "invalid_signature",
+		},
+		{
+			name:   "altered body",
+			signer: valid,
+			transport: tamper(func(req *http.Request) {
+				req.Body = io.NopCloser(bytes.NewReader([]byte("forged")))
+			}),
+			code: "body_mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := signedPost(t, srv, tt.signer, tt.transport)
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401: %s", resp.StatusCode, resp.Body)
+			}
+			if code := errorCode(t, resp); code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestSignedQueryOrderDoesNotMatter(t *testing.T) {
+	srv := signedServer(t)
+	transport := tamper(func(req *http.Request) {
+		req.URL.RawQuery = "format=csv&day=2026-10-15"
+	})
+
+	resp := signedPost(t, srv, &httpclient.Signer{KeyID: "reports", Secret: signingKeys["reports"]}, transport)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+}
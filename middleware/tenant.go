@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"flugo.com/auth"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+type TenantID = reqctx.TenantID
+
+const maxTenantID = 64
+
+// Tenant resolves the tenant of each request and stores it with
+// reqctx.WithTenant, for database.QueryBuilder.ForTenant, cache.TenantKey
+// and the tenant check in auth.RequireAuth. Requests whose tenant cannot be
+// resolved, or resolves to an ID other than letters, digits, '-' and '_',
+// are rejected with 400. Tokens issued for another tenant are rejected with
+// 403, here when authentication ran first and by auth.RequireAuth when it
+// runs after Tenant.
+func Tenant(resolver func(*http.Request) (TenantID, error)) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			id, err := resolver(r)
+			if err == nil && !validTenantID(id) {
+				err = fmt.Errorf("invalid tenant ID %q", id)
+			}
+			if err != nil {
+				reqctx.Logger(r).Warn("Tenant not resolved for %s: %v", r.Host, err)
+				response.BadRequest(w, "Unknown tenant")
+				return
+			}
+
+			if user := auth.GetCurrentUser(r); user != nil && user.TenantID != string(id) {
+				reqctx.Logger(r).Warn("Token for tenant %q used on tenant %q", user.TenantID, id)
+				response.Forbidden(w, "Token does not belong to this tenant")
+				return
+			}
+
+			r = reqctx.WithTenant(r, id)
+			r = reqctx.WithLogger(r, reqctx.Logger(r).With(map[string]interface{}{"tenant": string(id)}))
+			next(w, r)
+		}
+	}
+}
+
+// TenantFromHeader reads the tenant from a header such as X-Tenant-ID.
+func TenantFromHeader(name string) func(*http.Request) (TenantID, error) {
+	return func(r *http.Request) (TenantID, error) {
+		id := strings.TrimSpace(r.Header.Get(name))
+		if id == "" {
+			return "", fmt.Errorf("missing %s header", name)
+		}
+		return TenantID(id), nil
+	}
+}
+
+// TenantFromSubdomain takes the label left of baseDomain, so
+// "acme.example.com" is tenant "acme" for base domain "example.com".
+func TenantFromSubdomain(baseDomain string) func(*http.Request) (TenantID, error) {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(r *http.Request) (TenantID, error) {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		sub, ok := strings.CutSuffix(host, suffix)
+		if !ok || sub == "" || strings.Contains(sub, ".") {
+			return "", fmt.Errorf("host %q is not a subdomain of %s", r.Host, baseDomain)
+		}
+		return TenantID(sub), nil
+	}
+}
+
+// FirstTenant tries resolvers in order and uses the first that succeeds.
+func FirstTenant(resolvers ...func(*http.Request) (TenantID, error)) func(*http.Request) (TenantID, error) {
+	return func(r *http.Request) (TenantID, error) {
+		var errs []string
+		for _, resolve := range resolvers {
+			id, err := resolve(r)
+			if err == nil {
+				return id, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return "", fmt.Errorf("no tenant: %s", strings.Join(errs, "; "))
+	}
+}
+
+func validTenantID(id TenantID) bool {
+	if id == "" || len(id) > maxTenantID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/middleware"
+	"flugo.com/reqctx"
+	"flugo.com/router"
+)
+
+func TestTenantRejectsTokensOfAnotherTenant(t *testing.T) {
+	auth.Init(&config.JWTConfig{Secret: "test-secret", ExpirationTime: 3600, RefreshTime: 86400})
+	token, err := auth.GenerateToken(auth.Claims{UserID: 7}, auth.WithTenant("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant := middleware.Tenant(middleware.TenantFromHeader("X-Tenant-ID"))
+	var served reqctx.TenantID
+	handler := func(w http.ResponseWriter, r *http.Request) { served = reqctx.Tenant(r) }
+	chains := map[string]router.HandlerFunc{
+		"Tenant then RequireAuth": tenant(auth.RequireAuth()(handler)),
+		"RequireAuth then Tenant": auth.RequireAuth()(tenant(handler)),
+	}
+
+	for name, chain := range chains {
+		for _, tt := range []struct {
+			tenant string
+			status int
+		}{
+			{"acme", http.StatusOK},
+			{"globex", http.StatusForbidden},
+			{"", http.StatusBadRequest},
+		} {
+			served = ""
+			req := httptest.NewRequest("GET", "/orders", nil)
+			req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant-ID", tt.tenant)
+			}
+			w := httptest.NewRecorder()
+			chain(w, req)
+			if w.Code != tt.status {
+				t.Errorf("%s, tenant %q: status %d, want %d", name, tt.tenant, w.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && served != "acme" {
+				t.Errorf("%s: handler saw tenant %q", name, served)
+			}
+			if tt.status != http.StatusOK && served != "" {
+				t.Errorf("%s, tenant %q: handler ran", name, tt.tenant)
+			}
+		}
+	}
+}
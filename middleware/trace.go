@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"flugo.com/reqctx"
+	"flugo.com/router"
+	"flugo.com/trace"
+)
+
+// Trace continues the trace of an incoming traceparent header, or starts a
+// new one, with a server span around the request. Queries, cache loads,
+// outbound calls and queued jobs started from the request context become
+// its children. The request logger gets a trace_id field.
+func Trace() router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sc, err := trace.ParseTraceparent(r.Header.Get(trace.Header)); err == nil {
+				ctx = trace.ContextWithRemote(ctx, sc)
+			}
+
+			route := reqctx.Route(r)
+			name := r.Method + " " + route
+			if route == "" {
+				name = r.Method + " " + r.URL.Path
+			}
+			ctx, span := trace.Start(ctx, name, trace.WithKind(trace.KindServer))
+			defer span.End()
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.target", r.URL.Path)
+			if route != "" {
+				span.SetAttribute("http.route", route)
+			}
+
+			r = r.WithContext(ctx)
+			r = reqctx.WithLogger(r, reqctx.Logger(r).With(map[string]interface{}{
+				"trace_id": span.Context().TraceID.String(),
+			}))
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			span.SetAttribute("http.status_code", rec.status)
+			if rec.status >= 500 {
+				span.RecordError(errors.New(http.StatusText(rec.status)))
+			}
+		}
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
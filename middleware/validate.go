@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"flugo.com/dto"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/validator"
+)
+
+// ValidateBody binds each request into a new value of v's type and
+// validates it before the handler runs, which reads it back with
+// dto.FromContext:
+//
+//	r.POST("/users", createUser, middleware.ValidateBody(CreateUserRequest{}))
+//
+// GET, HEAD and DELETE requests are bound from the query string with
+// dto.BindQuery, other methods from the JSON body. DTOs with param tags are
+// bound from the route, query and body together with dto.Bind. Validation failures get
+// the standard 422 response, malformed input a 400, 413 or 415.
+func ValidateBody(v interface{}) router.MiddlewareFunc {
+	return ValidateBodyWithOptions(v, response.BindOptions{})
+}
+
+// ValidateBodyWithOptions is ValidateBody with the JSON binding options,
+// such as a size limit or rejecting unknown fields.
+func ValidateBodyWithOptions(v interface{}, opts response.BindOptions) router.MiddlewareFunc {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("middleware.ValidateBody: %T is not a struct", v))
+	}
+	fromRoute := hasParamTags(t)
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if probe, ok := r.Context().Value(schemaProbeKey{}).(*schemaProbe); ok {
+				probe.sample = v
+				return
+			}
+
+			target := reflect.New(t).Interface()
+
+			var err error
+			switch {
+			case fromRoute:
+				err = dto.Bind(r, target)
+			case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodDelete:
+				err = dto.BindQuery(r, target)
+			default:
+				if err = response.BindJSONWithOptions(w, r, target, opts); err == nil {
+					err = validator.Validate(target)
+				}
+			}
+			if err != nil {
+				respondBindError(w, r, err)
+				return
+			}
+
+			next(w, dto.WithValue(r, target))
+		}
+	}
+}
+
+func hasParamTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("param") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func respondBindError(w http.ResponseWriter, r *http.Request, err error) {
+	if dto.HandleValidationError(w, err) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		response.BadRequest(w, "Invalid query parameters", err.Error())
+	default:
+		response.InvalidBody(w, err)
+	}
+}
+
+type schemaProbeKey struct{}
+
+type schemaProbe struct {
+	sample interface{}
+}
+
+// validateBodyCode identifies middlewares built by ValidateBody: closures
+// created by the same function share one code pointer.
+var validateBodyCode = reflect.ValueOf(ValidateBody(struct{}{})).Pointer()
+
+// RequestSchema returns the DTO that mw, if built by ValidateBody, binds,
+// so documentation can describe the request without a separate
+// annotation. Other middlewares are never called.
+func RequestSchema(mw router.MiddlewareFunc) (interface{}, bool) {
+	if mw == nil || reflect.ValueOf(mw).Pointer() != validateBodyCode {
+		return nil, false
+	}
+
+	probe := &schemaProbe{}
+	r, _ := http.NewRequestWithContext(context.WithValue(context.Background(), schemaProbeKey{}, probe), http.MethodGet, "/", nil)
+	mw(func(http.ResponseWriter, *http.Request) {})(nil, r)
+	return probe.sample, probe.sample != nil
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"flugo.com/router"
+)
+
+type apiVersionKey struct{}
+
+var (
+	pathVersionPattern   = regexp.MustCompile(`^/(v\d+)(/|$)`)
+	vendorVersionPattern = regexp.MustCompile(`^application/vnd\.[^.]+\.(v\d+)(\+json)?$`)
+)
+
+// APIVersion resolves the API version for each request from, in order, a
+// /vN/ path prefix, the X-API-Version header, or an Accept media type such as
+// application/vnd.myapp.v2+json (or a version=2 parameter). The result is
+// normalised to "vN" and read back with GetAPIVersion.
+func APIVersion(defaultVersion string) router.MiddlewareFunc {
+	defaultVersion = normalizeVersion(defaultVersion)
+
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			version := resolveAPIVersion(r)
+			if version == "" {
+				version = defaultVersion
+			}
+
+			w.Header().Set("X-API-Version", version)
+			ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+func GetAPIVersion(r *http.Request) string {
+	version, _ := r.Context().Value(apiVersionKey{}).(string)
+	return version
+}
+
+func resolveAPIVersion(r *http.Request) string {
+	if match := pathVersionPattern.FindStringSubmatch(r.URL.Path); match != nil {
+		return match[1]
+	}
+
+	if header := r.Header.Get("X-API-Version"); header != "" {
+		return normalizeVersion(header)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if match := vendorVersionPattern.FindStringSubmatch(mediaType); match != nil {
+			return match[1]
+		}
+		if v := params["version"]; v != "" {
+			return normalizeVersion(v)
+		}
+	}
+
+	return ""
+}
+
+func normalizeVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version == "" || strings.HasPrefix(version, "v") {
+		return version
+	}
+	return "v" + version
+}
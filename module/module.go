@@ -1,14 +1,31 @@
 package module
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
 	"flugo.com/container"
 	"flugo.com/router"
 )
 
+// ModuleConfig describes a module. OnStart runs once the application boots,
+// after imported modules have started; OnStop runs on shutdown in reverse.
+//
+// Guards and then Middlewares wrap every route of the module's controllers.
+// With InheritGuards the module's guards also apply to the routes of the
+// modules it imports.
 type ModuleConfig struct {
-	Controllers []ControllerConfig
-	Providers   []interface{}
-	Imports     []*Module
+	Name          string
+	Controllers   []ControllerConfig
+	Providers     []interface{}
+	Imports       []*Module
+	Guards        []router.MiddlewareFunc
+	Middlewares   []router.MiddlewareFunc
+	InheritGuards bool
+	OnStart       func(ctx context.Context, c *container.Container) error
+	OnStop        func(ctx context.Context) error
 }
 
 type ControllerConfig struct {
@@ -20,6 +37,10 @@ type Module struct {
 	config    ModuleConfig
 	container *container.Container
 	router    *router.Router
+	routes    []router.Route
+
+	bootstrapped bool
+	started      bool
 }
 
 func NewModule(config ModuleConfig) *Module {
@@ -28,19 +49,153 @@ func NewModule(config ModuleConfig) *Module {
 	}
 }
 
-func (m *Module) Bootstrap(c *container.Container, r *router.Router) {
-	m.container = c
-	m.router = r
+// Name returns the configured name, falling back to the type of the first
+// controller.
+func (m *Module) Name() string {
+	if m.config.Name != "" {
+		return m.config.Name
+	}
+	if len(m.config.Controllers) > 0 && m.config.Controllers[0].Controller != nil {
+		t := reflect.TypeOf(m.config.Controllers[0].Controller)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return t.Name()
+	}
+	return "anonymous"
+}
+
+func (m *Module) Imports() []*Module {
+	return m.config.Imports
+}
+
+// Routes returns the routes registered by this module's own controllers.
+func (m *Module) Routes() []router.Route {
+	return m.routes
+}
+
+// Resolve flattens the import graph of roots into dependency order: every
+// module appears once, after all modules it imports. A circular import is
+// reported with the full chain of names.
+func Resolve(roots ...*Module) ([]*Module, error) {
+	var ordered []*Module
+	done := make(map[*Module]bool)
+	var path []*Module
+
+	var visit func(m *Module) error
+	visit = func(m *Module) error {
+		if done[m] {
+			return nil
+		}
+		for i, p := range path {
+			if p == m {
+				names := make([]string, 0, len(path)-i+1)
+				for _, c := range path[i:] {
+					names = append(names, c.Name())
+				}
+				names = append(names, m.Name())
+				return fmt.Errorf("circular module import: %s", strings.Join(names, " -> "))
+			}
+		}
+
+		path = append(path, m)
+		for _, imported := range m.config.Imports {
+			if err := visit(imported); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[m] = true
+		ordered = append(ordered, m)
+		return nil
+	}
 
-	for _, importedModule := range m.config.Imports {
-		importedModule.Bootstrap(c, r)
+	for _, m := range roots {
+		if err := visit(m); err != nil {
+			return nil, err
+		}
 	}
+	return ordered, nil
+}
+
+// Bootstrap registers the providers and controllers of m and everything it
+// imports. Modules imported more than once are only registered the first
+// time.
+func (m *Module) Bootstrap(c *container.Container, r *router.Router) error {
+	modules, err := Resolve(m)
+	if err != nil {
+		return err
+	}
+
+	// Importers come after their imports in resolved order, so walking it
+	// backwards settles every importer's guards before they are inherited.
+	guards := make(map[*Module][]router.MiddlewareFunc, len(modules))
+	for i := len(modules) - 1; i >= 0; i-- {
+		mod := modules[i]
+		guards[mod] = append(guards[mod], mod.config.Guards...)
+		if mod.config.InheritGuards {
+			for _, imported := range mod.config.Imports {
+				guards[imported] = append(guards[imported], guards[mod]...)
+			}
+		}
+	}
+
+	for _, mod := range modules {
+		mod.bootstrap(c, r, guards[mod])
+	}
+	return nil
+}
+
+func (m *Module) bootstrap(c *container.Container, r *router.Router, guards []router.MiddlewareFunc) {
+	if m.bootstrapped {
+		return
+	}
+	m.bootstrapped = true
+	m.container = c
+	m.router = r
 
 	for _, provider := range m.config.Providers {
 		c.Register(provider)
 	}
 
+	group := r.Group("", append(append([]router.MiddlewareFunc{}, guards...), m.config.Middlewares...)...)
+
+	before := len(r.Routes())
 	for _, controllerConfig := range m.config.Controllers {
-		r.RegisterController(controllerConfig.Controller, controllerConfig.Path)
+		group.RegisterController(controllerConfig.Controller, controllerConfig.Path)
+	}
+	m.routes = r.Routes()[before:]
+}
+
+// Start runs the OnStart hook of this module only; callers start modules in
+// the order returned by Resolve. Starting twice is a no-op, and a module
+// whose OnStart failed is not started, so Stop skips it.
+func (m *Module) Start(ctx context.Context) error {
+	if m.started {
+		return nil
+	}
+
+	if m.config.OnStart != nil {
+		if err := m.config.OnStart(ctx, m.container); err != nil {
+			return fmt.Errorf("module %s: %w", m.Name(), err)
+		}
+	}
+	m.started = true
+	return nil
+}
+
+// Stop runs the OnStop hook if the module was started.
+func (m *Module) Stop(ctx context.Context) error {
+	if !m.started {
+		return nil
+	}
+	m.started = false
+
+	if m.config.OnStop != nil {
+		if err := m.config.OnStop(ctx); err != nil {
+			return fmt.Errorf("module %s: %w", m.Name(), err)
+		}
 	}
+	return nil
 }
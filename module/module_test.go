@@ -0,0 +1,118 @@
+package module_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"flugo.com/container"
+	"flugo.com/module"
+)
+
+// recorder collects lifecycle hook calls in order.
+type recorder []string
+
+func (r *recorder) module(name string, imports ...*module.Module) *module.Module {
+	return module.NewModule(module.ModuleConfig{
+		Name:    name,
+		Imports: imports,
+		OnStart: func(ctx context.Context, c *container.Container) error {
+			*r = append(*r, "start "+name)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			*r = append(*r, "stop "+name)
+			return nil
+		},
+	})
+}
+
+func names(modules []*module.Module) string {
+	list := make([]string, len(modules))
+	for i, m := range modules {
+		list[i] = m.Name()
+	}
+	return strings.Join(list, ",")
+}
+
+func TestResolveDeduplicatesImports(t *testing.T) {
+	var r recorder
+	db := r.module("db")
+	users := r.module("users", db)
+	orders := r.module("orders", db, users)
+	app := r.module("app", users, orders, db)
+
+	modules, err := module.Resolve(app, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(modules); got != "db,users,orders,app" {
+		t.Errorf("Resolve = %s, want db,users,orders,app", got)
+	}
+}
+
+func TestResolveReportsCycleChain(t *testing.T) {
+	var r recorder
+	a := module.NewModule(module.ModuleConfig{Name: "a"})
+	b := r.module("b", a)
+	c := r.module("c", b)
+	*a = *r.module("a", c)
+	root := r.module("root", a)
+
+	_, err := module.Resolve(root)
+	if err == nil || err.Error() != "circular module import: a -> c -> b -> a" {
+		t.Errorf("Resolve error = %v, want the chain a -> c -> b -> a", err)
+	}
+}
+
+func TestStartAndStopOrder(t *testing.T) {
+	var r recorder
+	db := r.module("db")
+	users := r.module("users", db)
+	app := r.module("app", users, db)
+
+	modules, err := module.Resolve(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, m := range modules {
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		m.Start(ctx)
+	}
+	for i := len(modules) - 1; i >= 0; i-- {
+		if err := modules[i].Stop(ctx); err != nil {
+			t.Fatal(err)
+		}
+		modules[i].Stop(ctx)
+	}
+
+	want := "start db,start users,start app,stop app,stop users,stop db"
+	if got := strings.Join(r, ","); got != want {
+		t.Errorf("hooks = %s, want %s", got, want)
+	}
+}
+
+func TestFailedStartSkipsStop(t *testing.T) {
+	stopped := false
+	m := module.NewModule(module.ModuleConfig{
+		Name:    "broken",
+		OnStart: func(ctx context.Context, c *container.Container) error { return errors.New("no connection") },
+		OnStop: func(ctx context.Context) error {
+			stopped = true
+			return nil
+		},
+	})
+
+	err := m.Start(context.Background())
+	if err == nil || err.Error() != "module broken: no connection" {
+		t.Errorf("Start error = %v", err)
+	}
+	m.Stop(context.Background())
+	if stopped {
+		t.Error("OnStop ran for a module whose OnStart failed")
+	}
+}
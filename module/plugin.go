@@ -0,0 +1,41 @@
+package module
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginSymbol is the variable a plugin exports for LoadPlugin:
+//
+//	var FlugoModule = module.NewModule(module.ModuleConfig{...})
+const PluginSymbol = "FlugoModule"
+
+// LoadPlugin opens a shared library built with go build -buildmode=plugin
+// and returns the module it exports as FlugoModule. The plugin must be built
+// with the same Go version and the same versions of every package it shares
+// with the host, flugo.com included, or plugin.Open refuses it. Go plugins
+// are only supported on Linux, FreeBSD and macOS, and cannot be unloaded.
+func LoadPlugin(soPath string) (*Module, error) {
+	p, err := plugin.Open(soPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin: %w", err)
+	}
+
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", soPath, err)
+	}
+
+	// Lookup returns a pointer to the exported variable.
+	switch v := sym.(type) {
+	case **Module:
+		if *v == nil {
+			return nil, fmt.Errorf("plugin %s: %s is nil", soPath, PluginSymbol)
+		}
+		return *v, nil
+	case *Module:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("plugin %s: %s is %T, want *module.Module", soPath, PluginSymbol, sym)
+	}
+}
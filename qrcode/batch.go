@@ -0,0 +1,171 @@
+package qrcode
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// BatchItem is a single entry of a batch. Config overrides DefaultConfig
+// when set; Label names the result and its file inside a batch zip. Format
+// is "png", the default, or "svg".
+type BatchItem struct {
+	Text   string
+	Label  string
+	Config *Config
+	Format string
+}
+
+// BatchResult holds the outcome of one BatchItem: PNG or SVG according to
+// its format, or Err when that item could not be generated.
+type BatchResult struct {
+	Label string
+	PNG   []byte
+	SVG   string
+	Info  map[string]interface{}
+	Err   error
+}
+
+func GenerateBatchWithConfig(items []BatchItem) ([]BatchResult, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("batch cannot be empty")
+	}
+
+	labels := batchLabels(items)
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i] = generateBatchItem(item, labels[i])
+	}
+
+	return results, nil
+}
+
+// WriteBatchZip streams a zip archive to w with one <label>.png or
+// <label>.svg per item.
+// Items that fail are listed in errors.txt instead of aborting the archive.
+func WriteBatchZip(w io.Writer, items []BatchItem) error {
+	if len(items) == 0 {
+		return fmt.Errorf("batch cannot be empty")
+	}
+
+	labels := batchLabels(items)
+	archive := zip.NewWriter(w)
+
+	var failures []string
+	for i, item := range items {
+		result := generateBatchItem(item, labels[i])
+		if result.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", result.Label, result.Err))
+			continue
+		}
+
+		name, body := result.Label+".png", result.PNG
+		if result.SVG != "" {
+			name, body = result.Label+".svg", []byte(result.SVG)
+		}
+		entry, err := archive.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", result.Label, err)
+		}
+		if _, err := entry.Write(body); err != nil {
+			return fmt.Errorf("failed to write %s to archive: %w", result.Label, err)
+		}
+	}
+
+	if len(failures) > 0 {
+		entry, err := archive.Create("errors.txt")
+		if err != nil {
+			return fmt.Errorf("failed to add errors.txt to archive: %w", err)
+		}
+		if _, err := io.WriteString(entry, strings.Join(failures, "\n")+"\n"); err != nil {
+			return fmt.Errorf("failed to write errors.txt to archive: %w", err)
+		}
+	}
+
+	return archive.Close()
+}
+
+func generateBatchItem(item BatchItem, label string) BatchResult {
+	result := BatchResult{Label: label, Info: GetQRInfo(item.Text)}
+
+	if err := ValidateQRData(item.Text); err != nil {
+		result.Err = err
+		return result
+	}
+
+	config := DefaultConfig
+	if item.Config != nil {
+		config = *item.Config
+	}
+
+	switch item.Format {
+	case "", "png":
+		png, err := GenerateBytesWithConfig(item.Text, config)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		result.PNG = png
+	case "svg":
+		svg, err := GenerateSVGWithConfig(item.Text, config)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		result.SVG = svg
+	default:
+		result.Err = fmt.Errorf("format must be png or svg")
+		return result
+	}
+
+	version := calculateVersion(item.Text, config.Level)
+	result.Info["level"] = config.Level.String()
+	result.Info["version"] = version
+	result.Info["size"] = version*4 + 17
+	return result
+}
+
+// batchLabels returns a file-safe, unique label for every item, falling
+// back to qr_001, qr_002, ... for items without one.
+func batchLabels(items []BatchItem) []string {
+	labels := make([]string, len(items))
+	seen := make(map[string]int)
+
+	for i, item := range items {
+		label := sanitizeLabel(item.Label)
+		if label == "" {
+			label = fmt.Sprintf("qr_%03d", i+1)
+		}
+
+		base := label
+		for seen[label] > 0 {
+			seen[base]++
+			label = fmt.Sprintf("%s_%d", base, seen[base])
+		}
+		seen[label]++
+		labels[i] = label
+	}
+
+	return labels
+}
+
+func sanitizeLabel(label string) string {
+	label = path.Base(strings.ReplaceAll(label, "\\", "/"))
+	label = strings.TrimSuffix(strings.TrimSuffix(label, ".png"), ".svg")
+	if label == "." || label == "/" || label == ".." {
+		return ""
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		case r == ' ':
+			return '_'
+		default:
+			return -1
+		}
+	}, label)
+}
@@ -0,0 +1,57 @@
+package qrcode_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"flugo.com/qrcode"
+)
+
+func TestBatchHandlerFormats(t *testing.T) {
+	body := `["https://example.com/a", {"text": "https://example.com/b", "label": "b.svg"}, {"text": "hello", "label": "c", "format": "png"}]`
+	req := httptest.NewRequest("POST", "/qr/batch?format=svg", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	qrcode.BatchHandler()(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("status %d, content type %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make(map[string]string)
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		entries[f.Name] = string(data)
+	}
+	if len(entries) != 3 {
+		t.Errorf("entries = %v, want 3 files", len(entries))
+	}
+	for _, name := range []string{"qr_001.svg", "b.svg"} {
+		if !strings.Contains(entries[name], "<svg") {
+			t.Errorf("%s is not an SVG", name)
+		}
+	}
+	if !strings.HasPrefix(entries["c.png"], "\x89PNG") {
+		t.Error("c.png is not a PNG")
+	}
+
+	req = httptest.NewRequest("POST", "/qr/batch?format=gif", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	qrcode.BatchHandler()(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("format=gif: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,623 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Decode reads the payload of a QR code from an image. It targets
+// reasonably clean, axis-aligned captures such as uploaded tickets or
+// screenshots; heavily rotated or perspective-distorted input may fail.
+func Decode(img image.Image) (string, error) {
+	grid := binarize(img)
+
+	finders := findFinderPatterns(grid)
+	if len(finders) < 3 {
+		return "", fmt.Errorf("could not locate QR code finder patterns")
+	}
+
+	topLeft, topRight, bottomLeft, err := selectFinders(finders)
+	if err != nil {
+		return "", err
+	}
+
+	moduleSize := (topLeft.moduleSize + topRight.moduleSize + bottomLeft.moduleSize) / 3
+	span := (distance(topLeft, topRight) + distance(topLeft, bottomLeft)) / 2
+	estimated := estimateVersion(span / moduleSize)
+
+	var lastErr error
+	for _, version := range []int{estimated, estimated - 1, estimated + 1} {
+		if version < minVersion || version > maxVersion {
+			continue
+		}
+		text, err := decodeGrid(grid, topLeft, topRight, bottomLeft, version)
+		if err == nil {
+			return text, nil
+		}
+		lastErr = err
+	}
+
+	return "", lastErr
+}
+
+// DecodeBytes decodes a QR code from an encoded PNG, JPEG or GIF image.
+func DecodeBytes(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return Decode(img)
+}
+
+type bitGrid struct {
+	width  int
+	height int
+	dark   []bool
+}
+
+func (g *bitGrid) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= g.width || y >= g.height {
+		return false
+	}
+	return g.dark[y*g.width+x]
+}
+
+func binarize(img image.Image) *bitGrid {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	luma := make([]uint32, width*height)
+
+	minLuma, maxLuma := uint32(math.MaxUint32), uint32(0)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			l := (299*r + 587*g + 114*b) / 1000
+			luma[y*width+x] = l
+			if l < minLuma {
+				minLuma = l
+			}
+			if l > maxLuma {
+				maxLuma = l
+			}
+		}
+	}
+
+	threshold := (minLuma + maxLuma) / 2
+	grid := &bitGrid{width: width, height: height, dark: make([]bool, width*height)}
+	for i, l := range luma {
+		grid.dark[i] = l < threshold
+	}
+	return grid
+}
+
+type finderPattern struct {
+	x          float64
+	y          float64
+	moduleSize float64
+	count      int
+}
+
+func distance(a, b finderPattern) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+func findFinderPatterns(grid *bitGrid) []finderPattern {
+	var candidates []finderPattern
+
+	for y := 0; y < grid.height; y++ {
+		runs := rowRuns(grid, y)
+		for i := 0; i+5 <= len(runs); i++ {
+			if !runs[i].dark {
+				continue
+			}
+			window := [5]int{runs[i].length, runs[i+1].length, runs[i+2].length, runs[i+3].length, runs[i+4].length}
+			if !finderRatio(window) {
+				continue
+			}
+
+			cx := float64(runs[i+2].start) + float64(runs[i+2].length)/2
+			cy, vTotal, ok := crossCheck(grid, cx, float64(y)+0.5, 0, 1)
+			if !ok {
+				continue
+			}
+			cx, hTotal, ok := crossCheck(grid, cx, cy, 1, 0)
+			if !ok {
+				continue
+			}
+
+			candidates = addCandidate(candidates, finderPattern{
+				x:          cx,
+				y:          cy,
+				moduleSize: (vTotal + hTotal) / 14,
+				count:      1,
+			})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].count > candidates[j].count
+	})
+	return candidates
+}
+
+type pixelRun struct {
+	dark   bool
+	start  int
+	length int
+}
+
+func rowRuns(grid *bitGrid, y int) []pixelRun {
+	var runs []pixelRun
+	for x := 0; x < grid.width; x++ {
+		dark := grid.at(x, y)
+		if len(runs) > 0 && runs[len(runs)-1].dark == dark {
+			runs[len(runs)-1].length++
+			continue
+		}
+		runs = append(runs, pixelRun{dark: dark, start: x, length: 1})
+	}
+	return runs
+}
+
+func finderRatio(runs [5]int) bool {
+	total := 0
+	for _, r := range runs {
+		if r == 0 {
+			return false
+		}
+		total += r
+	}
+	if total < 7 {
+		return false
+	}
+
+	module := float64(total) / 7
+	tolerance := module / 2
+	return math.Abs(module-float64(runs[0])) < tolerance &&
+		math.Abs(module-float64(runs[1])) < tolerance &&
+		math.Abs(3*module-float64(runs[2])) < 3*tolerance &&
+		math.Abs(module-float64(runs[3])) < tolerance &&
+		math.Abs(module-float64(runs[4])) < tolerance
+}
+
+// crossCheck walks through (cx, cy) along (dx, dy) and verifies the 1:1:3:1:1
+// pattern. It returns the refined center coordinate along that axis and the
+// total pattern width.
+func crossCheck(grid *bitGrid, cx, cy float64, dx, dy int) (float64, float64, bool) {
+	x, y := int(cx), int(cy)
+	if !grid.at(x, y) {
+		return 0, 0, false
+	}
+
+	get := func(p int) bool {
+		if dx == 1 {
+			return grid.at(p, y)
+		}
+		return grid.at(x, p)
+	}
+	pos := x*dx + y*dy
+	limit := grid.width*dx + grid.height*dy
+
+	var counts [5]int
+	p := pos
+	for p >= 0 && get(p) {
+		counts[2]++
+		p--
+	}
+	for p >= 0 && !get(p) {
+		counts[1]++
+		p--
+	}
+	for p >= 0 && get(p) {
+		counts[0]++
+		p--
+	}
+
+	p = pos + 1
+	for p < limit && get(p) {
+		counts[2]++
+		p++
+	}
+	for p < limit && !get(p) {
+		counts[3]++
+		p++
+	}
+	for p < limit && get(p) {
+		counts[4]++
+		p++
+	}
+
+	if !finderRatio(counts) {
+		return 0, 0, false
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+
+	center := float64(p-counts[4]-counts[3]) - float64(counts[2])/2
+	return center, float64(total), true
+}
+
+func addCandidate(candidates []finderPattern, p finderPattern) []finderPattern {
+	for i, c := range candidates {
+		if math.Abs(c.x-p.x) <= c.moduleSize && math.Abs(c.y-p.y) <= c.moduleSize &&
+			math.Abs(c.moduleSize-p.moduleSize) <= math.Max(1, c.moduleSize/2) {
+			n := float64(c.count)
+			candidates[i] = finderPattern{
+				x:          (c.x*n + p.x) / (n + 1),
+				y:          (c.y*n + p.y) / (n + 1),
+				moduleSize: (c.moduleSize*n + p.moduleSize) / (n + 1),
+				count:      c.count + 1,
+			}
+			return candidates
+		}
+	}
+	return append(candidates, p)
+}
+
+// selectFinders picks the three candidates that best form the corner of a
+// square and orders them as top-left, top-right and bottom-left.
+func selectFinders(candidates []finderPattern) (finderPattern, finderPattern, finderPattern, error) {
+	if len(candidates) > 10 {
+		candidates = candidates[:10]
+	}
+
+	bestScore := math.Inf(1)
+	var best [3]finderPattern
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			for k := j + 1; k < len(candidates); k++ {
+				trio := [3]finderPattern{candidates[i], candidates[j], candidates[k]}
+				ordered, score := scoreTrio(trio)
+				if score < bestScore {
+					bestScore = score
+					best = ordered
+				}
+			}
+		}
+	}
+
+	if math.IsInf(bestScore, 1) {
+		return finderPattern{}, finderPattern{}, finderPattern{}, fmt.Errorf("could not locate QR code finder patterns")
+	}
+	return best[0], best[1], best[2], nil
+}
+
+func scoreTrio(trio [3]finderPattern) ([3]finderPattern, float64) {
+	// The corner finder sits opposite the longest side.
+	a, b, c := trio[0], trio[1], trio[2]
+	ab, ac, bc := distance(a, b), distance(a, c), distance(b, c)
+	switch {
+	case bc >= ab && bc >= ac:
+	case ac >= ab && ac >= bc:
+		a, b = b, a
+	default:
+		a, c = c, a
+	}
+
+	ux, uy := b.x-a.x, b.y-a.y
+	vx, vy := c.x-a.x, c.y-a.y
+	if ux*vy-uy*vx < 0 {
+		b, c = c, b
+		ux, uy, vx, vy = vx, vy, ux, uy
+	}
+
+	lenU, lenV := math.Hypot(ux, uy), math.Hypot(vx, vy)
+	if lenU == 0 || lenV == 0 {
+		return trio, math.Inf(1)
+	}
+
+	cos := math.Abs(ux*vx+uy*vy) / (lenU * lenV)
+	sizes := []float64{a.moduleSize, b.moduleSize, c.moduleSize}
+	sort.Float64s(sizes)
+	if sizes[2] > sizes[0]*1.5 || cos > 0.2 {
+		return trio, math.Inf(1)
+	}
+
+	score := cos + math.Abs(lenU-lenV)/math.Max(lenU, lenV) + (sizes[2]-sizes[0])/sizes[2]
+	return [3]finderPattern{a, b, c}, score
+}
+
+func estimateVersion(modulesBetweenCenters float64) int {
+	dimension := int(math.Round(modulesBetweenCenters)) + 7
+	return int(math.Round(float64(dimension-17) / 4))
+}
+
+func decodeGrid(grid *bitGrid, topLeft, topRight, bottomLeft finderPattern, version int) (string, error) {
+	size := version*4 + 17
+	sampled := sampleModules(grid, topLeft, topRight, bottomLeft, size)
+
+	if version >= 7 {
+		if read, ok := readVersion(sampled, size); ok && read != version {
+			return "", fmt.Errorf("version mismatch: estimated %d, read %d", version, read)
+		}
+	}
+
+	level, mask, err := readFormat(sampled, size)
+	if err != nil {
+		return "", err
+	}
+
+	m := newMatrix(version)
+	for y := 0; y < size; y++ {
+		copy(m.modules[y], sampled[y])
+	}
+	m.applyMask(mask)
+
+	raw := make([]byte, numRawDataModules(version)/8)
+	i := 0
+	m.forEachDataModule(func(x, y int) {
+		if i < len(raw)*8 {
+			if m.modules[y][x] {
+				raw[i>>3] |= 1 << uint(7-(i&7))
+			}
+			i++
+		}
+	})
+
+	data, err := correctCodewords(raw, version, level)
+	if err != nil {
+		return "", err
+	}
+
+	return parseSegments(data, version)
+}
+
+func sampleModules(grid *bitGrid, topLeft, topRight, bottomLeft finderPattern, size int) [][]bool {
+	span := float64(size - 7)
+	ux, uy := (topRight.x-topLeft.x)/span, (topRight.y-topLeft.y)/span
+	vx, vy := (bottomLeft.x-topLeft.x)/span, (bottomLeft.y-topLeft.y)/span
+
+	modules := make([][]bool, size)
+	for y := 0; y < size; y++ {
+		modules[y] = make([]bool, size)
+		for x := 0; x < size; x++ {
+			mx, my := float64(x)+0.5-3.5, float64(y)+0.5-3.5
+			px := topLeft.x + mx*ux + my*vx
+			py := topLeft.y + mx*uy + my*vy
+			modules[y][x] = grid.at(int(math.Floor(px)), int(math.Floor(py)))
+		}
+	}
+	return modules
+}
+
+func readFormat(modules [][]bool, size int) (ErrorLevel, int, error) {
+	bestDistance := 16
+	var bestLevel ErrorLevel
+	bestMask := 0
+
+	for _, positions := range formatPositions(size) {
+		bits := 0
+		for i, p := range positions {
+			if modules[p[1]][p[0]] {
+				bits |= 1 << uint(i)
+			}
+		}
+
+		for _, level := range []ErrorLevel{Low, Medium, Quartile, High} {
+			for mask := 0; mask < 8; mask++ {
+				d := hammingDistance(bits, formatInfoBits(level, mask))
+				if d < bestDistance {
+					bestDistance = d
+					bestLevel = level
+					bestMask = mask
+				}
+			}
+		}
+	}
+
+	if bestDistance > 3 {
+		return 0, 0, fmt.Errorf("could not read format information")
+	}
+	return bestLevel, bestMask, nil
+}
+
+func readVersion(modules [][]bool, size int) (int, bool) {
+	var copies [2]int
+	for i := 0; i < 18; i++ {
+		a := size - 11 + i%3
+		b := i / 3
+		if modules[b][a] {
+			copies[0] |= 1 << uint(i)
+		}
+		if modules[a][b] {
+			copies[1] |= 1 << uint(i)
+		}
+	}
+
+	bestDistance := 19
+	bestVersion := 0
+	for _, bits := range copies {
+		for version := 7; version <= maxVersion; version++ {
+			if d := hammingDistance(bits, versionInfoBits(version)); d < bestDistance {
+				bestDistance = d
+				bestVersion = version
+			}
+		}
+	}
+
+	return bestVersion, bestDistance <= 3
+}
+
+func hammingDistance(a, b int) int {
+	count := 0
+	for x := a ^ b; x != 0; x &= x - 1 {
+		count++
+	}
+	return count
+}
+
+func correctCodewords(raw []byte, version int, level ErrorLevel) ([]byte, error) {
+	layout := layoutFor(version, level)
+
+	blocks := make([][]byte, layout.numBlocks)
+	for j := range blocks {
+		blocks[j] = make([]byte, layout.dataLen(j)+layout.eccLen)
+	}
+
+	pos := 0
+	for i := 0; i < layout.shortBlockLen+1; i++ {
+		for j := range blocks {
+			if j < layout.numShortBlocks && i == layout.shortBlockLen-layout.eccLen {
+				continue
+			}
+			idx := i
+			if j < layout.numShortBlocks && i > layout.shortBlockLen-layout.eccLen {
+				idx--
+			}
+			if idx < len(blocks[j]) {
+				blocks[j][idx] = raw[pos]
+				pos++
+			}
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		if _, err := rsCorrect(block, layout.eccLen); err != nil {
+			return nil, fmt.Errorf("block %d: %w", j, err)
+		}
+		data = append(data, block[:layout.dataLen(j)]...)
+	}
+	return data, nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) available() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.available() {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	value := 0
+	for i := 0; i < n; i++ {
+		bit := (r.data[r.pos>>3] >> uint(7-(r.pos&7))) & 1
+		value = value<<1 | int(bit)
+		r.pos++
+	}
+	return value, nil
+}
+
+func parseSegments(data []byte, version int) (string, error) {
+	reader := &bitReader{data: data}
+	var result strings.Builder
+
+	for reader.available() >= 4 {
+		indicator, _ := reader.read(4)
+
+		var mode segmentMode
+		switch indicator {
+		case 0x0:
+			return result.String(), nil
+		case 0x1:
+			mode = modeNumeric
+		case 0x2:
+			mode = modeAlphanumeric
+		case 0x4:
+			mode = modeByte
+		case 0x7:
+			if err := skipECI(reader); err != nil {
+				return "", err
+			}
+			continue
+		default:
+			return "", fmt.Errorf("unsupported segment mode %d", indicator)
+		}
+
+		count, err := reader.read(mode.charCountBits(version))
+		if err != nil {
+			return "", err
+		}
+
+		if err := readSegment(reader, mode, count, &result); err != nil {
+			return "", err
+		}
+	}
+
+	return result.String(), nil
+}
+
+func skipECI(reader *bitReader) error {
+	first, err := reader.read(8)
+	if err != nil {
+		return err
+	}
+	switch {
+	case first&0x80 == 0:
+		return nil
+	case first&0xC0 == 0x80:
+		_, err = reader.read(8)
+	default:
+		_, err = reader.read(16)
+	}
+	return err
+}
+
+func readSegment(reader *bitReader, mode segmentMode, count int, out *strings.Builder) error {
+	switch mode {
+	case modeNumeric:
+		for count > 0 {
+			digits := 3
+			if count < 3 {
+				digits = count
+			}
+			value, err := reader.read(digits*3 + 1)
+			if err != nil {
+				return err
+			}
+			chunk := strconv.Itoa(value)
+			for len(chunk) < digits {
+				chunk = "0" + chunk
+			}
+			out.WriteString(chunk)
+			count -= digits
+		}
+	case modeAlphanumeric:
+		for count > 1 {
+			value, err := reader.read(11)
+			if err != nil {
+				return err
+			}
+			if value/45 >= len(alphanumericCharset) {
+				return fmt.Errorf("invalid alphanumeric data")
+			}
+			out.WriteByte(alphanumericCharset[value/45])
+			out.WriteByte(alphanumericCharset[value%45])
+			count -= 2
+		}
+		if count == 1 {
+			value, err := reader.read(6)
+			if err != nil {
+				return err
+			}
+			if value >= len(alphanumericCharset) {
+				return fmt.Errorf("invalid alphanumeric data")
+			}
+			out.WriteByte(alphanumericCharset[value])
+		}
+	default:
+		for i := 0; i < count; i++ {
+			value, err := reader.read(8)
+			if err != nil {
+				return err
+			}
+			out.WriteByte(byte(value))
+		}
+	}
+	return nil
+}
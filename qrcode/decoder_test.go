@@ -0,0 +1,86 @@
+package qrcode_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"flugo.com/qrcode"
+)
+
+// symbolVersion reads the version of the symbol text encodes to from the
+// viewBox of its SVG, which spans the symbol and a 4 module border.
+func symbolVersion(t *testing.T, text string, config qrcode.Config) int {
+	t.Helper()
+	svg, err := qrcode.GenerateSVGWithConfig(text, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int
+	if _, err := fmt.Sscanf(svg[strings.Index(svg, `viewBox="0 0 `)+len(`viewBox="0 0 `):], "%d", &total); err != nil {
+		t.Fatal(err)
+	}
+	return (total - 2*config.Border - 17) / 4
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	levels := map[string]qrcode.ErrorLevel{"L": qrcode.Low, "M": qrcode.Medium, "Q": qrcode.Quartile, "H": qrcode.High}
+	// Each alphabet selects a different encoding mode.
+	alphabets := map[string]string{
+		"numeric":      "0123456789",
+		"alphanumeric": "HTTPS://FLUGO.COM/ABC-123 $%*+./:",
+		"byte":         "https://flugo.com/r?id=é&q=✓",
+	}
+
+	for levelName, level := range levels {
+		for modeName, alphabet := range alphabets {
+			config := qrcode.DefaultConfig
+			config.Level = level
+
+			// Round-trip the shortest text of every version from 1 to 10,
+			// found by bisecting the text length.
+			for version := 1; version <= 10; version++ {
+				low, high := 1, 700
+				for low < high {
+					mid := (low + high) / 2
+					if symbolVersion(t, repeatRunes(alphabet, mid), config) >= version {
+						high = mid
+					} else {
+						low = mid + 1
+					}
+				}
+				text := repeatRunes(alphabet, low)
+				if v := symbolVersion(t, text, config); v != version {
+					t.Fatalf("%s/%s: shortest text past version %d encodes to version %d", levelName, modeName, version-1, v)
+				}
+
+				t.Run(fmt.Sprintf("%s/%s/v%d", levelName, modeName, version), func(t *testing.T) {
+					// Four pixels per module keeps every symbol well above
+					// the decoder's sampling limits.
+					config := config
+					config.Size = (version*4 + 17 + 2*config.Border) * 4
+					png, err := qrcode.GenerateBytesWithConfig(text, config)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := qrcode.DecodeBytes(png)
+					if err != nil {
+						t.Fatalf("Decode: %v", err)
+					}
+					if got != text {
+						t.Errorf("Decode = %q, want %q", got, text)
+					}
+				})
+			}
+		}
+	}
+}
+
+func repeatRunes(alphabet string, n int) string {
+	runes := []rune(alphabet)
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = runes[i%len(runes)]
+	}
+	return string(out)
+}
@@ -0,0 +1,639 @@
+package qrcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+
+	penaltyN1 = 3
+	penaltyN2 = 3
+	penaltyN3 = 40
+	penaltyN4 = 10
+)
+
+type segmentMode int
+
+const (
+	modeNumeric segmentMode = iota
+	modeAlphanumeric
+	modeByte
+)
+
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// Indexed by ErrorLevel, then by version. Index 0 is unused.
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var numErrorCorrectionBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 80},
+}
+
+// formatBits returns the two-bit error correction indicator stored in the
+// format information, which does not follow the L < M < Q < H ordering.
+func (l ErrorLevel) formatBits() int {
+	switch l {
+	case Low:
+		return 1
+	case Medium:
+		return 0
+	case Quartile:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (l ErrorLevel) String() string {
+	switch l {
+	case Low:
+		return "L"
+	case Medium:
+		return "M"
+	case Quartile:
+		return "Q"
+	default:
+		return "H"
+	}
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) appendBits(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+// matrix holds the module grid while a symbol is being built or read back.
+type matrix struct {
+	size       int
+	version    int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newMatrix(version int) *matrix {
+	size := version*4 + 17
+	m := &matrix{
+		size:       size,
+		version:    version,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := 0; i < size; i++ {
+		m.modules[i] = make([]bool, size)
+		m.isFunction[i] = make([]bool, size)
+	}
+	m.drawFunctionPatterns()
+	return m
+}
+
+func encode(text string, level ErrorLevel) (*QRCode, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+
+	mode := detectMode(text)
+	version, err := selectVersion(text, mode, level)
+	if err != nil {
+		return nil, err
+	}
+
+	data := encodeData(text, mode, version, level)
+	codewords := addErrorCorrection(data, version, level)
+
+	m := newMatrix(version)
+	m.drawCodewords(codewords)
+
+	bestMask := 0
+	minPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(level, mask)
+		penalty := m.penaltyScore()
+		if minPenalty < 0 || penalty < minPenalty {
+			bestMask = mask
+			minPenalty = penalty
+		}
+		m.applyMask(mask)
+	}
+	m.applyMask(bestMask)
+	m.drawFormatBits(level, bestMask)
+
+	return &QRCode{
+		data:    m.modules,
+		size:    m.size,
+		version: version,
+		level:   level,
+	}, nil
+}
+
+func detectMode(text string) segmentMode {
+	numeric := true
+	alphanumeric := true
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			numeric = false
+		}
+		if !strings.ContainsRune(alphanumericCharset, r) {
+			alphanumeric = false
+		}
+	}
+
+	switch {
+	case numeric:
+		return modeNumeric
+	case alphanumeric:
+		return modeAlphanumeric
+	default:
+		return modeByte
+	}
+}
+
+func (m segmentMode) indicator() int {
+	switch m {
+	case modeNumeric:
+		return 0x1
+	case modeAlphanumeric:
+		return 0x2
+	default:
+		return 0x4
+	}
+}
+
+func (m segmentMode) charCountBits(version int) int {
+	idx := 0
+	if version >= 27 {
+		idx = 2
+	} else if version >= 10 {
+		idx = 1
+	}
+
+	switch m {
+	case modeNumeric:
+		return [3]int{10, 12, 14}[idx]
+	case modeAlphanumeric:
+		return [3]int{9, 11, 13}[idx]
+	default:
+		return [3]int{8, 16, 16}[idx]
+	}
+}
+
+func segmentBits(text string, mode segmentMode) (bitBuffer, int) {
+	var bits bitBuffer
+
+	switch mode {
+	case modeNumeric:
+		for i := 0; i < len(text); i += 3 {
+			end := i + 3
+			if end > len(text) {
+				end = len(text)
+			}
+			chunk := text[i:end]
+			value := 0
+			for _, c := range chunk {
+				value = value*10 + int(c-'0')
+			}
+			bits.appendBits(value, len(chunk)*3+1)
+		}
+		return bits, len(text)
+	case modeAlphanumeric:
+		i := 0
+		for ; i+1 < len(text); i += 2 {
+			value := strings.IndexByte(alphanumericCharset, text[i])*45 + strings.IndexByte(alphanumericCharset, text[i+1])
+			bits.appendBits(value, 11)
+		}
+		if i < len(text) {
+			bits.appendBits(strings.IndexByte(alphanumericCharset, text[i]), 6)
+		}
+		return bits, len(text)
+	default:
+		raw := []byte(text)
+		for _, b := range raw {
+			bits.appendBits(int(b), 8)
+		}
+		return bits, len(raw)
+	}
+}
+
+func selectVersion(text string, mode segmentMode, level ErrorLevel) (int, error) {
+	payload, count := segmentBits(text, mode)
+
+	for version := minVersion; version <= maxVersion; version++ {
+		countBits := mode.charCountBits(version)
+		if count >= 1<<uint(countBits) {
+			continue
+		}
+		if 4+countBits+len(payload) <= numDataCodewords(version, level)*8 {
+			return version, nil
+		}
+	}
+
+	return 0, fmt.Errorf("data too long for a QR code at error level %s", level)
+}
+
+func encodeData(text string, mode segmentMode, version int, level ErrorLevel) []byte {
+	payload, count := segmentBits(text, mode)
+
+	var bits bitBuffer
+	bits.appendBits(mode.indicator(), 4)
+	bits.appendBits(count, mode.charCountBits(version))
+	bits = append(bits, payload...)
+
+	capacity := numDataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.appendBits(0, terminator)
+	bits.appendBits(0, (8-len(bits)%8)%8)
+
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.appendBits(pad, 8)
+	}
+
+	data := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			data[i>>3] |= 1 << uint(7-(i&7))
+		}
+	}
+	return data
+}
+
+// numRawDataModules returns the number of modules available for data and
+// error correction codewords once all function patterns are excluded.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level ErrorLevel) int {
+	return numRawDataModules(version)/8 -
+		eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+// blockLayout describes how the raw codewords of a version/level are split
+// into error correction blocks. Short blocks come first and hold one data
+// codeword fewer than the long ones.
+type blockLayout struct {
+	numBlocks      int
+	eccLen         int
+	numShortBlocks int
+	shortBlockLen  int
+}
+
+func layoutFor(version int, level ErrorLevel) blockLayout {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	return blockLayout{
+		numBlocks:      numBlocks,
+		eccLen:         eccCodewordsPerBlock[level][version],
+		numShortBlocks: numBlocks - rawCodewords%numBlocks,
+		shortBlockLen:  rawCodewords / numBlocks,
+	}
+}
+
+func (l blockLayout) dataLen(block int) int {
+	n := l.shortBlockLen - l.eccLen
+	if block >= l.numShortBlocks {
+		n++
+	}
+	return n
+}
+
+func addErrorCorrection(data []byte, version int, level ErrorLevel) []byte {
+	layout := layoutFor(version, level)
+	generator := rsGenerator(layout.eccLen)
+
+	blocks := make([][]byte, layout.numBlocks)
+	offset := 0
+	for i := range blocks {
+		n := layout.dataLen(i)
+		block := make([]byte, n, n+layout.eccLen)
+		copy(block, data[offset:offset+n])
+		offset += n
+		blocks[i] = append(block, rsRemainder(block, generator)...)
+	}
+
+	result := make([]byte, 0, numRawDataModules(version)/8)
+	longest := layout.shortBlockLen + 1
+	for i := 0; i < longest; i++ {
+		for j, block := range blocks {
+			// Short blocks have no codeword at the last data position.
+			if j < layout.numShortBlocks && i == layout.shortBlockLen-layout.eccLen {
+				continue
+			}
+			idx := i
+			if j < layout.numShortBlocks && i > layout.shortBlockLen-layout.eccLen {
+				idx--
+			}
+			if idx < len(block) {
+				result = append(result, block[idx])
+			}
+		}
+	}
+	return result
+}
+
+func (m *matrix) setFunction(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.isFunction[y][x] = true
+}
+
+func (m *matrix) drawFunctionPatterns() {
+	for i := 0; i < m.size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinderPattern(3, 3)
+	m.drawFinderPattern(m.size-4, 3)
+	m.drawFinderPattern(3, m.size-4)
+
+	positions := alignmentPatternPositions(m.version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			m.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// Reserve the format areas; real bits are drawn once the mask is known.
+	m.drawFormatBits(Low, 0)
+	m.drawVersion()
+}
+
+func (m *matrix) drawFinderPattern(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= m.size || y < 0 || y >= m.size {
+				continue
+			}
+			dist := maxInt(absInt(dx), absInt(dy))
+			m.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignmentPattern(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.setFunction(cx+dx, cy+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+		}
+	}
+}
+
+func alignmentPatternPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func formatInfoBits(level ErrorLevel, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func versionInfoBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (m *matrix) drawFormatBits(level ErrorLevel, mask int) {
+	bits := formatInfoBits(level, mask)
+	for _, pos := range formatPositions(m.size) {
+		for i, p := range pos {
+			m.setFunction(p[0], p[1], (bits>>uint(i))&1 != 0)
+		}
+	}
+	m.setFunction(8, m.size-8, true)
+}
+
+// formatPositions returns the (x, y) coordinates of both copies of the 15
+// format bits, least significant bit first.
+func formatPositions(size int) [2][15][2]int {
+	var copies [2][15][2]int
+
+	for i := 0; i <= 5; i++ {
+		copies[0][i] = [2]int{8, i}
+	}
+	copies[0][6] = [2]int{8, 7}
+	copies[0][7] = [2]int{8, 8}
+	copies[0][8] = [2]int{7, 8}
+	for i := 9; i < 15; i++ {
+		copies[0][i] = [2]int{14 - i, 8}
+	}
+
+	for i := 0; i < 8; i++ {
+		copies[1][i] = [2]int{size - 1 - i, 8}
+	}
+	for i := 8; i < 15; i++ {
+		copies[1][i] = [2]int{8, size - 15 + i}
+	}
+
+	return copies
+}
+
+func (m *matrix) drawVersion() {
+	if m.version < 7 {
+		return
+	}
+
+	bits := versionInfoBits(m.version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a := m.size - 11 + i%3
+		b := i / 3
+		m.setFunction(a, b, dark)
+		m.setFunction(b, a, dark)
+	}
+}
+
+// forEachDataModule walks the data area in the standard zigzag order.
+func (m *matrix) forEachDataModule(fn func(x, y int)) {
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+				if !m.isFunction[y][x] {
+					fn(x, y)
+				}
+			}
+		}
+	}
+}
+
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+	m.forEachDataModule(func(x, y int) {
+		if i < len(codewords)*8 {
+			m.modules[y][x] = (codewords[i>>3]>>uint(7-(i&7)))&1 != 0
+			i++
+		}
+	})
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask XORs the data modules with the given mask pattern. Calling it
+// twice with the same mask restores the original grid.
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if !m.isFunction[y][x] && maskBit(mask, x, y) {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+func (m *matrix) penaltyScore() int {
+	result := 0
+
+	line := func(get func(i int) bool) {
+		runColor := false
+		runLen := 0
+		for i := 0; i < m.size; i++ {
+			if i > 0 && get(i) == runColor {
+				runLen++
+				if runLen == 5 {
+					result += penaltyN1
+				} else if runLen > 5 {
+					result++
+				}
+			} else {
+				runColor = get(i)
+				runLen = 1
+			}
+		}
+
+		// Finder-like 1:1:3:1:1 runs with four light modules on either side.
+		for i := 0; i+11 <= m.size; i++ {
+			core := get(i+4) && !get(i+5) && get(i+6) && get(i+7) && get(i+8) && !get(i+9) && get(i+10)
+			if !core {
+				continue
+			}
+			if !get(i) && !get(i+1) && !get(i+2) && !get(i+3) {
+				result += penaltyN3
+			}
+		}
+		for i := 0; i+11 <= m.size; i++ {
+			core := get(i) && !get(i+1) && get(i+2) && get(i+3) && get(i+4) && !get(i+5) && get(i+6)
+			if core && !get(i+7) && !get(i+8) && !get(i+9) && !get(i+10) {
+				result += penaltyN3
+			}
+		}
+	}
+
+	for y := 0; y < m.size; y++ {
+		row := y
+		line(func(i int) bool { return m.modules[row][i] })
+	}
+	for x := 0; x < m.size; x++ {
+		col := x
+		line(func(i int) bool { return m.modules[i][col] })
+	}
+
+	for y := 0; y < m.size-1; y++ {
+		for x := 0; x < m.size-1; x++ {
+			c := m.modules[y][x]
+			if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+				result += penaltyN2
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+		}
+	}
+	total := m.size * m.size
+	k := (absInt(dark*20-total*10)+total-1)/total - 1
+	result += k * penaltyN4
+
+	return result
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
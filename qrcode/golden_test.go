@@ -0,0 +1,102 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flugo.com/qrcode"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// TestGoldenImages renders each style option and compares the pixels with
+// the PNGs in testdata. Run with -update after an intended rendering change
+// and look at the new images before committing them.
+func TestGoldenImages(t *testing.T) {
+	style := func(change func(*qrcode.Config)) qrcode.Config {
+		config := qrcode.DefaultConfig
+		config.Size = 200
+		change(&config)
+		return config
+	}
+	tests := []struct {
+		name   string
+		text   string
+		config qrcode.Config
+	}{
+		{"square", "https://flugo.com", style(func(c *qrcode.Config) {})},
+		{"rounded", "https://flugo.com", style(func(c *qrcode.Config) { c.ModuleShape = qrcode.ShapeRounded })},
+		{"dots", "https://flugo.com", style(func(c *qrcode.Config) { c.ModuleShape = qrcode.ShapeDots })},
+		{"colors", "https://flugo.com", style(func(c *qrcode.Config) {
+			c.ForeColor = color.RGBA{0x1a, 0x3c, 0x8f, 0xff}
+			c.BackColor = color.RGBA{0xfa, 0xf3, 0xe0, 0xff}
+			c.FinderColor = color.RGBA{0xc0, 0x28, 0x28, 0xff}
+		})},
+		{"quiet_zone", "https://flugo.com", style(func(c *qrcode.Config) { c.QuietZone = 1 })},
+		{"level_high", "https://flugo.com", style(func(c *qrcode.Config) { c.Level = qrcode.High })},
+		{"micro_m1", "12345", style(func(c *qrcode.Config) { c.Micro, c.Level, c.QuietZone = true, qrcode.Low, 2 })},
+		{"micro_m4", "FLUGO-42", style(func(c *qrcode.Config) { c.Micro, c.Level, c.QuietZone = true, qrcode.Quartile, 2 })},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := qrcode.GenerateBytesWithConfig(tt.text, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", tt.name+".png")
+			if *update {
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			golden, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			// Pixels are compared rather than bytes, since the PNG encoder
+			// may compress the same image differently.
+			if diff := diffImages(t, data, golden); diff != "" {
+				t.Errorf("%s differs from %s: %s", tt.name, path, diff)
+			}
+		})
+	}
+}
+
+func diffImages(t *testing.T, got, want []byte) string {
+	t.Helper()
+	gotImg, err := png.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantImg, err := png.Decode(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotImg.Bounds() != wantImg.Bounds() {
+		return "bounds " + gotImg.Bounds().String() + ", want " + wantImg.Bounds().String()
+	}
+	b := gotImg.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !sameColor(gotImg.At(x, y), wantImg.At(x, y)) {
+				return "first difference at " + image.Pt(x, y).String()
+			}
+		}
+	}
+	return ""
+}
+
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
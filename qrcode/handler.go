@@ -0,0 +1,234 @@
+package qrcode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"flugo.com/module"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+const (
+	MinHandlerSize = 64
+	MaxHandlerSize = 2048
+	MaxBatchItems  = 100
+)
+
+// Handler serves GET /qr?data=...&size=512&level=H&format=png|svg.
+//
+// Image generation is CPU bound, so public deployments should mount it behind
+// a limiter, e.g. r.GET("/qr", qrcode.Handler(), ratelimit.Limit(30, time.Minute)).
+func Handler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		data := query.Get("data")
+		if err := ValidateQRData(data); err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+
+		config, format, err := parseHandlerParams(query.Get("size"), query.Get("level"), query.Get("format"))
+		if err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		response.WithETag(w, r, handlerETag(data, config, format), func() {
+			body, contentType, err := render(data, config, format)
+			if err != nil {
+				response.InternalError(w, "Failed to generate QR code")
+				return
+			}
+
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+		})
+	}
+}
+
+// BatchHandler serves POST requests with a JSON array of payloads and
+// streams back a zip archive holding one PNG or SVG per entry. Entries are
+// either plain strings or objects of the form {"text", "label", "size",
+// "level", "format"}; the size, level and format query parameters are the
+// defaults for every entry.
+func BatchHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var raw []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			response.BadRequest(w, "Request body must be a JSON array")
+			return
+		}
+
+		if len(raw) == 0 {
+			response.BadRequest(w, "Batch cannot be empty")
+			return
+		}
+
+		if len(raw) > MaxBatchItems {
+			response.BadRequest(w, fmt.Sprintf("Batch too large (max %d items)", MaxBatchItems))
+			return
+		}
+
+		query := r.URL.Query()
+		config, format, err := parseHandlerParams(query.Get("size"), query.Get("level"), query.Get("format"))
+		if err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+
+		items := make([]BatchItem, len(raw))
+		for i, entry := range raw {
+			item, err := parseBatchEntry(entry, config, format)
+			if err != nil {
+				response.BadRequest(w, fmt.Sprintf("Invalid item %d: %v", i, err))
+				return
+			}
+			items[i] = item
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="qrcodes.zip"`)
+		w.WriteHeader(http.StatusOK)
+		if err := WriteBatchZip(w, items); err != nil {
+			reqctx.Logger(r).Error("Failed to stream QR batch: %v", err)
+		}
+	}
+}
+
+func parseBatchEntry(entry json.RawMessage, defaults Config, defaultFormat string) (BatchItem, error) {
+	var text string
+	if err := json.Unmarshal(entry, &text); err == nil {
+		return BatchItem{Text: text, Config: &defaults, Format: defaultFormat}, nil
+	}
+
+	var obj struct {
+		Text   string      `json:"text"`
+		Label  string      `json:"label"`
+		Size   json.Number `json:"size"`
+		Level  string      `json:"level"`
+		Format string      `json:"format"`
+	}
+	if err := json.Unmarshal(entry, &obj); err != nil {
+		return BatchItem{}, fmt.Errorf("must be a string or an object")
+	}
+
+	if obj.Format == "" {
+		obj.Format = defaultFormat
+	}
+	config, format, err := parseHandlerParams(obj.Size.String(), obj.Level, obj.Format)
+	if err != nil {
+		return BatchItem{}, err
+	}
+	if obj.Size == "" {
+		config.Size = defaults.Size
+	}
+	if obj.Level == "" {
+		config.Level = defaults.Level
+	}
+
+	return BatchItem{Text: obj.Text, Label: obj.Label, Config: &config, Format: format}, nil
+}
+
+type Controller struct{}
+
+func NewController() *Controller {
+	return &Controller{}
+}
+
+// Get maps to GET {path}.
+func (c *Controller) Get(w http.ResponseWriter, r *http.Request) {
+	Handler()(w, r)
+}
+
+// PostBatch maps to POST {path}/batch.
+func (c *Controller) PostBatch(w http.ResponseWriter, r *http.Request) {
+	BatchHandler()(w, r)
+}
+
+// NewModule mounts the QR endpoints under /qr:
+//
+//	cmd.New().WithModules(qrcode.NewModule())
+func NewModule() *module.Module {
+	return module.NewModule(module.ModuleConfig{
+		Name: "qrcode",
+		Controllers: []module.ControllerConfig{
+			{Controller: NewController(), Path: "/qr"},
+		},
+	})
+}
+
+func parseHandlerParams(sizeStr, levelStr, format string) (Config, string, error) {
+	config := DefaultConfig
+
+	if sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < MinHandlerSize || size > MaxHandlerSize {
+			return config, "", fmt.Errorf("size must be an integer between %d and %d", MinHandlerSize, MaxHandlerSize)
+		}
+		config.Size = size
+	}
+
+	if levelStr != "" {
+		level, err := ParseErrorLevel(levelStr)
+		if err != nil {
+			return config, "", err
+		}
+		config.Level = level
+	}
+
+	format = strings.ToLower(format)
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		return config, "", fmt.Errorf("format must be png or svg")
+	}
+
+	return config, format, nil
+}
+
+func ParseErrorLevel(level string) (ErrorLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	default:
+		return Medium, fmt.Errorf("level must be one of L, M, Q, H")
+	}
+}
+
+func handlerETag(data string, config Config, format string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s", data, config.Size, config.Level, format)))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+func render(text string, config Config, format string) ([]byte, string, error) {
+	if format == "svg" {
+		svg, err := GenerateSVGWithConfig(text, config)
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(svg), "image/svg+xml", nil
+	}
+
+	png, err := GenerateBytesWithConfig(text, config)
+	if err != nil {
+		return nil, "", err
+	}
+	return png, "image/png", nil
+}
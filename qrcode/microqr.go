@@ -0,0 +1,225 @@
+package qrcode
+
+import "fmt"
+
+// microSpec describes one Micro QR symbol variant. M1 only offers error
+// detection and is treated as Low.
+type microSpec struct {
+	version  int
+	level    ErrorLevel
+	symbol   int
+	dataBits int
+	eccLen   int
+}
+
+// Ordered from the smallest symbol to the largest.
+var microSpecs = []microSpec{
+	{1, Low, 0, 20, 2},
+	{2, Low, 1, 40, 5},
+	{2, Medium, 2, 32, 6},
+	{3, Low, 3, 84, 6},
+	{3, Medium, 4, 68, 8},
+	{4, Low, 5, 128, 8},
+	{4, Medium, 6, 112, 10},
+	{4, Quartile, 7, 80, 14},
+}
+
+// Micro QR only defines four of the eight mask patterns.
+var microMasks = [4]int{1, 4, 6, 7}
+
+func encodeMicro(text string, level ErrorLevel) (*QRCode, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+
+	if level == High {
+		return nil, fmt.Errorf("Micro QR codes do not support error level H")
+	}
+
+	mode := detectMode(text)
+	payload, count := segmentBits(text, mode)
+
+	for _, spec := range microSpecs {
+		if spec.level != level || !microSupportsMode(spec.version, mode) {
+			continue
+		}
+
+		countBits := microCharCountBits(spec.version, mode)
+		if count >= 1<<uint(countBits) {
+			continue
+		}
+
+		var bits bitBuffer
+		bits.appendBits(int(mode), spec.version-1)
+		bits.appendBits(count, countBits)
+		bits = append(bits, payload...)
+		if len(bits) > spec.dataBits {
+			continue
+		}
+
+		return buildMicro(spec, bits), nil
+	}
+
+	return nil, fmt.Errorf("data too long for a Micro QR code at error level %s", level)
+}
+
+func microSupportsMode(version int, mode segmentMode) bool {
+	switch mode {
+	case modeNumeric:
+		return true
+	case modeAlphanumeric:
+		return version >= 2
+	default:
+		return version >= 3
+	}
+}
+
+func microCharCountBits(version int, mode segmentMode) int {
+	if mode == modeNumeric {
+		return version + 2
+	}
+	return version + 1
+}
+
+func buildMicro(spec microSpec, bits bitBuffer) *QRCode {
+	capacity := spec.dataBits
+
+	terminator := minInt(2*spec.version+1, capacity-len(bits))
+	bits.appendBits(0, terminator)
+	bits.appendBits(0, minInt((8-len(bits)%8)%8, capacity-len(bits)))
+	for pad := 0xEC; len(bits)+8 <= capacity; pad ^= 0xEC ^ 0x11 {
+		bits.appendBits(pad, 8)
+	}
+	// M1 and M3 end with a 4-bit data codeword.
+	bits.appendBits(0, capacity-len(bits))
+
+	// The short codeword sits in the high nibble for error correction.
+	data := make([]byte, (capacity+7)/8)
+	for i, bit := range bits {
+		if bit {
+			data[i>>3] |= 1 << uint(7-(i&7))
+		}
+	}
+	ecc := rsRemainder(data, rsGenerator(spec.eccLen))
+
+	stream := bits
+	for _, b := range ecc {
+		stream.appendBits(int(b), 8)
+	}
+
+	m := newMicroMatrix(spec.version)
+	i := 0
+	m.forEachMicroDataModule(func(x, y int) {
+		if i < len(stream) {
+			m.modules[y][x] = stream[i]
+			i++
+		}
+	})
+
+	bestMask := 0
+	bestScore := -1
+	for mask := 0; mask < len(microMasks); mask++ {
+		m.applyMask(microMasks[mask])
+		m.drawMicroFormatBits(spec.symbol, mask)
+		if score := m.microMaskScore(); score > bestScore {
+			bestMask = mask
+			bestScore = score
+		}
+		m.applyMask(microMasks[mask])
+	}
+	m.applyMask(microMasks[bestMask])
+	m.drawMicroFormatBits(spec.symbol, bestMask)
+
+	return &QRCode{
+		data:    m.modules,
+		size:    m.size,
+		version: spec.version,
+		level:   spec.level,
+		micro:   true,
+	}
+}
+
+func newMicroMatrix(version int) *matrix {
+	size := version*2 + 9
+	m := &matrix{
+		size:       size,
+		version:    version,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := 0; i < size; i++ {
+		m.modules[i] = make([]bool, size)
+		m.isFunction[i] = make([]bool, size)
+	}
+
+	m.drawFinderPattern(3, 3)
+	for i := 8; i < size; i++ {
+		m.setFunction(i, 0, i%2 == 0)
+		m.setFunction(0, i, i%2 == 0)
+	}
+	m.drawMicroFormatBits(0, 0)
+	return m
+}
+
+func microFormatBits(symbol, mask int) int {
+	data := symbol<<2 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x4445
+}
+
+func (m *matrix) drawMicroFormatBits(symbol, mask int) {
+	bits := microFormatBits(symbol, mask)
+	for i := 0; i < 8; i++ {
+		m.setFunction(8, i+1, (bits>>uint(i))&1 != 0)
+		m.setFunction(i+1, 8, (bits>>uint(14-i))&1 != 0)
+	}
+}
+
+// forEachMicroDataModule walks the zigzag from the bottom right corner. Micro
+// symbols keep their timing pattern in column 0, so no column is skipped.
+func (m *matrix) forEachMicroDataModule(fn func(x, y int)) {
+	upward := true
+	for right := m.size - 1; right >= 1; right -= 2 {
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !m.isFunction[y][x] {
+					fn(x, y)
+				}
+			}
+		}
+		upward = !upward
+	}
+}
+
+// microMaskScore favours masks that leave many dark modules along the right
+// and bottom edges, which carry no timing pattern in Micro QR.
+func (m *matrix) microMaskScore() int {
+	right, bottom := 0, 0
+	for i := 1; i < m.size; i++ {
+		if m.modules[i][m.size-1] {
+			right++
+		}
+		if m.modules[m.size-1][i] {
+			bottom++
+		}
+	}
+
+	if right <= bottom {
+		return right*16 + bottom
+	}
+	return bottom*16 + right
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
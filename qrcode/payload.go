@@ -0,0 +1,254 @@
+package qrcode
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/utils"
+)
+
+var phoneRegex = regexp.MustCompile(`^\+?[0-9]{3,15}$`)
+
+var (
+	wifiEscaper  = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, `"`, `\"`)
+	vcardEscaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	// MECARD has no escape for line breaks, so they become spaces.
+	mecardEscaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, `"`, `\"`, "\r\n", " ", "\n", " ", "\r", " ")
+)
+
+// WiFiPayload builds a WIFI: payload. Special characters in the SSID and
+// password are backslash-escaped as required by the format.
+func WiFiPayload(ssid, password, security string) (string, error) {
+	if ssid == "" {
+		return "", fmt.Errorf("ssid cannot be empty")
+	}
+
+	security = strings.ToUpper(security)
+	switch security {
+	case "":
+		security = "WPA"
+	case "WPA", "WPA2", "WPA3":
+		security = "WPA"
+	case "WEP":
+	case "NOPASS":
+		security = "nopass"
+	default:
+		return "", fmt.Errorf("unsupported wifi security %q (use WPA, WEP or nopass)", security)
+	}
+
+	if security == "nopass" {
+		return fmt.Sprintf("WIFI:T:nopass;S:%s;;", wifiEscaper.Replace(ssid)), nil
+	}
+
+	if password == "" {
+		return "", fmt.Errorf("password is required for %s networks", security)
+	}
+
+	return fmt.Sprintf("WIFI:T:%s;S:%s;P:%s;H:false;;",
+		security, wifiEscaper.Replace(ssid), wifiEscaper.Replace(password)), nil
+}
+
+// VCardPayload builds a vCard 3.0 payload. Empty optional fields are left out.
+func VCardPayload(name, phone, email, organization string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("name cannot be empty")
+	}
+
+	lines := []string{"BEGIN:VCARD", "VERSION:3.0", "FN:" + vcardEscaper.Replace(name)}
+
+	if phone != "" {
+		normalized, err := normalizePhone(phone)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, "TEL:"+normalized)
+	}
+
+	if email != "" {
+		if err := validateEmailAddress(email); err != nil {
+			return "", err
+		}
+		lines = append(lines, "EMAIL:"+vcardEscaper.Replace(email))
+	}
+
+	if organization != "" {
+		lines = append(lines, "ORG:"+vcardEscaper.Replace(organization))
+	}
+
+	lines = append(lines, "END:VCARD")
+	return strings.Join(lines, "\n"), nil
+}
+
+// MeCardPayload builds a MECARD: payload, the compact contact format most
+// phone cameras read. Empty optional fields are left out.
+func MeCardPayload(name, phone, email string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("name cannot be empty")
+	}
+
+	payload := "MECARD:N:" + mecardEscaper.Replace(name) + ";"
+
+	if phone != "" {
+		normalized, err := normalizePhone(phone)
+		if err != nil {
+			return "", err
+		}
+		payload += "TEL:" + normalized + ";"
+	}
+
+	if email != "" {
+		if err := validateEmailAddress(email); err != nil {
+			return "", err
+		}
+		payload += "EMAIL:" + mecardEscaper.Replace(email) + ";"
+	}
+
+	return payload + ";", nil
+}
+
+func SMSPayload(phone, message string) (string, error) {
+	normalized, err := normalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("SMSTO:%s:%s", normalized, message), nil
+}
+
+func WhatsAppPayload(phone, message string) (string, error) {
+	normalized, err := normalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+
+	// wa.me expects the number in international format without the plus.
+	link := "https://wa.me/" + strings.TrimPrefix(normalized, "+")
+	if message != "" {
+		link += "?text=" + queryEscape(message)
+	}
+	return link, nil
+}
+
+func EmailPayload(email, subject, body string) (string, error) {
+	if err := validateEmailAddress(email); err != nil {
+		return "", err
+	}
+
+	var params []string
+	if subject != "" {
+		params = append(params, "subject="+queryEscape(subject))
+	}
+	if body != "" {
+		params = append(params, "body="+queryEscape(body))
+	}
+
+	link := "mailto:" + email
+	if len(params) > 0 {
+		link += "?" + strings.Join(params, "&")
+	}
+	return link, nil
+}
+
+func GeoPayload(lat, lng float64) (string, error) {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return "", fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(lng) || lng < -180 || lng > 180 {
+		return "", fmt.Errorf("longitude must be between -180 and 180")
+	}
+
+	return "geo:" + strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lng, 'f', -1, 64), nil
+}
+
+// EventPayload builds a bare VEVENT block. Prefer CalendarEventPayload, which
+// wraps the event in a VCALENDAR and formats the dates itself.
+func EventPayload(title, location, start, end string) (string, error) {
+	if strings.TrimSpace(title) == "" {
+		return "", fmt.Errorf("title cannot be empty")
+	}
+
+	lines := []string{
+		"BEGIN:VEVENT",
+		"SUMMARY:" + icalEscape(title),
+		"LOCATION:" + icalEscape(location),
+		"DTSTART:" + icalEscape(start),
+		"DTEND:" + icalEscape(end),
+		"END:VEVENT",
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func CalendarEventPayload(title, location string, start, end time.Time) (string, error) {
+	if strings.TrimSpace(title) == "" {
+		return "", fmt.Errorf("title cannot be empty")
+	}
+	if start.IsZero() || end.IsZero() {
+		return "", fmt.Errorf("start and end times are required")
+	}
+	if !end.After(start) {
+		return "", fmt.Errorf("end time must be after start time")
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Flugo//QR Code//EN",
+		"BEGIN:VEVENT",
+		"UID:" + utils.UUID() + "@flugo",
+		"DTSTAMP:" + icalTime(time.Now()),
+		"SUMMARY:" + icalEscape(title),
+	}
+	if location != "" {
+		lines = append(lines, "LOCATION:"+icalEscape(location))
+	}
+	lines = append(lines,
+		"DTSTART:"+icalTime(start),
+		"DTEND:"+icalTime(end),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	return strings.Join(lines, "\r\n"), nil
+}
+
+func normalizePhone(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, phone)
+
+	if !phoneRegex.MatchString(normalized) {
+		return "", fmt.Errorf("invalid phone number %q", phone)
+	}
+	return normalized, nil
+}
+
+func validateEmailAddress(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email address %q", email)
+	}
+	return nil
+}
+
+// queryEscape percent-encodes a query value. Spaces become %20 rather than
+// "+", since mailto: and most messaging apps do not decode "+" as a space.
+func queryEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func icalEscape(value string) string {
+	return vcardEscaper.Replace(value)
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,141 @@
+package qrcode_test
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/qrcode"
+)
+
+func TestPayloadEscaping(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload func() (string, error)
+		want    string
+	}{
+		{
+			"wifi separators",
+			func() (string, error) { return qrcode.WiFiPayload(`my;net,5:G`, `p\a"s;s`, "wpa2") },
+			`WIFI:T:WPA;S:my\;net\,5\:G;P:p\\a\"s\;s;H:false;;`,
+		},
+		{
+			"wifi injected field",
+			func() (string, error) { return qrcode.WiFiPayload(`cafe;P:stolen;;`, "", "nopass") },
+			`WIFI:T:nopass;S:cafe\;P\:stolen\;\;;;`,
+		},
+		{
+			"vcard line breaks",
+			func() (string, error) {
+				return qrcode.VCardPayload("Ann\nEND:VCARD\r\nBEGIN:VCARD", "", "", "A;B,\\C")
+			},
+			"BEGIN:VCARD\nVERSION:3.0\nFN:Ann\\nEND:VCARD\\nBEGIN:VCARD\nORG:A\\;B\\,\\\\C\nEND:VCARD",
+		},
+		{
+			"vcard phone and email",
+			func() (string, error) { return qrcode.VCardPayload("Ann", "+1 (555) 010-9999", "ann@example.com", "") },
+			"BEGIN:VCARD\nVERSION:3.0\nFN:Ann\nTEL:+15550109999\nEMAIL:ann@example.com\nEND:VCARD",
+		},
+		{
+			"mecard separators",
+			func() (string, error) { return qrcode.MeCardPayload(`Doe, Ann;TEL:1:\`, "555 0100", "") },
+			`MECARD:N:Doe\, Ann\;TEL\:1\:\\;TEL:5550100;;`,
+		},
+		{
+			"mecard line breaks",
+			func() (string, error) { return qrcode.MeCardPayload("Ann\r\nEMAIL:x@y.z;", "", "ann@example.com") },
+			`MECARD:N:Ann EMAIL\:x@y.z\;;EMAIL:ann@example.com;;`,
+		},
+		{
+			"email query",
+			func() (string, error) { return qrcode.EmailPayload("ann@example.com", "a&b=c?", "line 1\nline 2") },
+			"mailto:ann@example.com?subject=a%26b%3Dc%3F&body=line%201%0Aline%202",
+		},
+		{
+			"whatsapp query",
+			func() (string, error) { return qrcode.WhatsAppPayload("+44 20 7946 0958", "hi & bye #1") },
+			"https://wa.me/442079460958?text=hi%20%26%20bye%20%231",
+		},
+		{
+			"geo trims zeros",
+			func() (string, error) { return qrcode.GeoPayload(-33.8600, 151.2000) },
+			"geo:-33.86,151.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.payload()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("payload =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+var calendarStamp = regexp.MustCompile(`\r\nUID:([0-9a-f-]{36}@flugo)\r\nDTSTAMP:(\d{8}T\d{6}Z)\r\n`)
+
+func TestCalendarEventPayload(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	before := time.Now().UTC().Truncate(time.Second)
+	got, err := qrcode.CalendarEventPayload("Sync; all, hands", "Room\n4", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := calendarStamp.FindStringSubmatch(got)
+	if m == nil {
+		t.Fatalf("payload %q has no UID and DTSTAMP", got)
+	}
+	stamp, err := time.Parse("20060102T150405Z", m[2])
+	if err != nil || stamp.Before(before) || stamp.After(time.Now().UTC()) {
+		t.Errorf("DTSTAMP = %s, want the current UTC time", m[2])
+	}
+
+	want := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Flugo//QR Code//EN\r\nBEGIN:VEVENT\r\n" +
+		"SUMMARY:Sync\\; all\\, hands\r\nLOCATION:Room\\n4\r\n" +
+		"DTSTART:20240501T070000Z\r\nDTEND:20240501T080000Z\r\nEND:VEVENT\r\nEND:VCALENDAR"
+	if rest := strings.Replace(got, m[0], "\r\n", 1); rest != want {
+		t.Errorf("payload =\n%q\nwant\n%q", rest, want)
+	}
+
+	again, _ := qrcode.CalendarEventPayload("Sync", "", start, start.Add(time.Hour))
+	if other := calendarStamp.FindStringSubmatch(again); other == nil || other[1] == m[1] {
+		t.Errorf("second event UID in %q, want a fresh one", again)
+	}
+}
+
+func TestPayloadRejectsInvalidInput(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	tests := map[string]func() (string, error){
+		"wifi empty ssid":       func() (string, error) { return qrcode.WiFiPayload("", "secret", "WPA") },
+		"wifi unknown security": func() (string, error) { return qrcode.WiFiPayload("net", "secret", "WPA;T:nopass") },
+		"wifi missing password": func() (string, error) { return qrcode.WiFiPayload("net", "", "WEP") },
+		"vcard blank name":      func() (string, error) { return qrcode.VCardPayload(" \n", "", "", "") },
+		"vcard bad phone":       func() (string, error) { return qrcode.VCardPayload("Ann", "555;TEL:1", "", "") },
+		"vcard bad email":       func() (string, error) { return qrcode.VCardPayload("Ann", "", "ann@example.com\nX:y", "") },
+		"mecard blank name":     func() (string, error) { return qrcode.MeCardPayload("", "", "") },
+		"mecard bad phone":      func() (string, error) { return qrcode.MeCardPayload("Ann", "12", "") },
+		"mecard bad email":      func() (string, error) { return qrcode.MeCardPayload("Ann", "", "Ann <ann@example.com>") },
+		"sms bad phone":         func() (string, error) { return qrcode.SMSPayload("call me", "hi") },
+		"whatsapp bad phone":    func() (string, error) { return qrcode.WhatsAppPayload("+1234567890123456", "") },
+		"email bad address":     func() (string, error) { return qrcode.EmailPayload("ann@example.com?cc=x@y.z", "", "") },
+		"geo latitude":          func() (string, error) { return qrcode.GeoPayload(90.0001, 0) },
+		"geo longitude":         func() (string, error) { return qrcode.GeoPayload(0, -180.5) },
+		"geo nan":               func() (string, error) { return qrcode.GeoPayload(math.NaN(), 0) },
+		"event blank title":     func() (string, error) { return qrcode.EventPayload("\t", "", "", "") },
+		"calendar zero time":    func() (string, error) { return qrcode.CalendarEventPayload("Sync", "", time.Time{}, start) },
+		"calendar end first":    func() (string, error) { return qrcode.CalendarEventPayload("Sync", "", start, start.Add(-time.Minute)) },
+	}
+
+	for name, payload := range tests {
+		if got, err := payload(); err == nil {
+			t.Errorf("%s: payload = %q, want an error", name, got)
+		}
+	}
+}
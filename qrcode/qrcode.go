@@ -10,6 +10,7 @@ import (
 	"image/png"
 	"math"
 	"strings"
+	"time"
 )
 
 type QRCode struct {
@@ -17,6 +18,7 @@ type QRCode struct {
 	size    int
 	version int
 	level   ErrorLevel
+	micro   bool
 }
 
 type ErrorLevel int
@@ -28,13 +30,29 @@ const (
 	High
 )
 
+type ModuleShape int
+
+const (
+	ShapeSquare ModuleShape = iota
+	ShapeRounded
+	ShapeDots
+)
+
+// Config controls rendering. FinderColor defaults to ForeColor and QuietZone,
+// when positive, replaces Border as the margin in modules. Finder patterns
+// are always drawn square so styled symbols stay scannable. Micro selects a
+// Micro QR symbol (M1-M4) for very short payloads.
 type Config struct {
-	Size      int
-	Level     ErrorLevel
-	ForeColor color.Color
-	BackColor color.Color
-	Border    int
-	LogoSize  float64
+	Size        int
+	Level       ErrorLevel
+	ForeColor   color.Color
+	BackColor   color.Color
+	FinderColor color.Color
+	Border      int
+	QuietZone   int
+	ModuleShape ModuleShape
+	LogoSize    float64
+	Micro       bool
 }
 
 var DefaultConfig = Config{
@@ -51,7 +69,7 @@ func Generate(text string) (string, error) {
 }
 
 func GenerateWithConfig(text string, config Config) (string, error) {
-	qr, err := encode(text, config.Level)
+	qr, err := encodeWithConfig(text, config)
 	if err != nil {
 		return "", err
 	}
@@ -71,7 +89,7 @@ func GenerateBytes(text string) ([]byte, error) {
 }
 
 func GenerateBytesWithConfig(text string, config Config) ([]byte, error) {
-	qr, err := encode(text, config.Level)
+	qr, err := encodeWithConfig(text, config)
 	if err != nil {
 		return nil, err
 	}
@@ -94,146 +112,251 @@ func GenerateURL(text string) (string, error) {
 	return "data:image/png;base64," + base64Data, nil
 }
 
+func GenerateSVG(text string) (string, error) {
+	return GenerateSVGWithConfig(text, DefaultConfig)
+}
+
+func GenerateSVGWithConfig(text string, config Config) (string, error) {
+	qr, err := encodeWithConfig(text, config)
+	if err != nil {
+		return "", err
+	}
+
+	return qr.toSVG(config), nil
+}
+
 func GenerateVCard(name, phone, email, organization string) (string, error) {
-	vcard := fmt.Sprintf(`BEGIN:VCARD
-VERSION:3.0
-FN:%s
-TEL:%s
-EMAIL:%s
-ORG:%s
-END:VCARD`, name, phone, email, organization)
+	payload, err := VCardPayload(name, phone, email, organization)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
+}
 
-	return Generate(vcard)
+func GenerateMeCard(name, phone, email string) (string, error) {
+	payload, err := MeCardPayload(name, phone, email)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
 }
 
 func GenerateWiFi(ssid, password, security string) (string, error) {
-	if security == "" {
-		security = "WPA"
+	payload, err := WiFiPayload(ssid, password, security)
+	if err != nil {
+		return "", err
 	}
-	wifi := fmt.Sprintf("WIFI:T:%s;S:%s;P:%s;H:false;;", security, ssid, password)
-	return Generate(wifi)
+	return Generate(payload)
 }
 
 func GenerateSMS(phone, message string) (string, error) {
-	sms := fmt.Sprintf("SMSTO:%s:%s", phone, message)
-	return Generate(sms)
+	payload, err := SMSPayload(phone, message)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
 }
 
 func GenerateWhatsApp(phone, message string) (string, error) {
-	wa := fmt.Sprintf("https://wa.me/%s?text=%s", phone, message)
-	return Generate(wa)
+	payload, err := WhatsAppPayload(phone, message)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
 }
 
 func GenerateEmail(email, subject, body string) (string, error) {
-	mailto := fmt.Sprintf("mailto:%s?subject=%s&body=%s", email, subject, body)
-	return Generate(mailto)
+	payload, err := EmailPayload(email, subject, body)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
 }
 
 func GenerateGeoLocation(lat, lng float64) (string, error) {
-	geo := fmt.Sprintf("geo:%f,%f", lat, lng)
-	return Generate(geo)
+	payload, err := GeoPayload(lat, lng)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
 }
 
 func GenerateEvent(title, location, start, end string) (string, error) {
-	event := fmt.Sprintf(`BEGIN:VEVENT
-SUMMARY:%s
-LOCATION:%s
-DTSTART:%s
-DTEND:%s
-END:VEVENT`, title, location, start, end)
-
-	return Generate(event)
+	payload, err := EventPayload(title, location, start, end)
+	if err != nil {
+		return "", err
+	}
+	return Generate(payload)
 }
 
-func encode(text string, level ErrorLevel) (*QRCode, error) {
-	if text == "" {
-		return nil, fmt.Errorf("text cannot be empty")
+func GenerateCalendarEvent(title, location string, start, end time.Time) (string, error) {
+	payload, err := CalendarEventPayload(title, location, start, end)
+	if err != nil {
+		return "", err
 	}
+	return Generate(payload)
+}
 
-	size := calculateSize(len(text))
-	data := make([][]bool, size)
-	for i := range data {
-		data[i] = make([]bool, size)
+func encodeWithConfig(text string, config Config) (*QRCode, error) {
+	if config.Micro {
+		return encodeMicro(text, config.Level)
 	}
+	return encode(text, config.Level)
+}
 
-	for i := 0; i < size; i++ {
-		for j := 0; j < size; j++ {
-			data[i][j] = (i+j+len(text))%2 == 0
-		}
+func (qr *QRCode) toImage(config Config) image.Image {
+	quiet := quietZone(config)
+	moduleSize := config.Size / (qr.size + 2*quiet)
+	if moduleSize < 1 {
+		moduleSize = 1
 	}
 
-	addFinderPattern(data, 0, 0)
-	addFinderPattern(data, 0, size-7)
-	addFinderPattern(data, size-7, 0)
+	imgSize := (qr.size + 2*quiet) * moduleSize
+	img := image.NewRGBA(image.Rect(0, 0, imgSize, imgSize))
 
-	return &QRCode{
-		data:    data,
-		size:    size,
-		version: 1,
-		level:   level,
-	}, nil
-}
+	draw.Draw(img, img.Bounds(), &image.Uniform{config.BackColor}, image.Point{}, draw.Src)
 
-func calculateSize(textLen int) int {
-	switch {
-	case textLen <= 25:
-		return 21
-	case textLen <= 47:
-		return 25
-	case textLen <= 77:
-		return 29
-	case textLen <= 114:
-		return 33
-	default:
-		return 37
+	for i := 0; i < qr.size; i++ {
+		for j := 0; j < qr.size; j++ {
+			if !qr.data[i][j] {
+				continue
+			}
+
+			x1 := (j + quiet) * moduleSize
+			y1 := (i + quiet) * moduleSize
+			cell := image.Rect(x1, y1, x1+moduleSize, y1+moduleSize)
+
+			if qr.isFinderModule(i, j) {
+				draw.Draw(img, cell, &image.Uniform{finderColor(config)}, image.Point{}, draw.Src)
+				continue
+			}
+			drawModule(img, cell, config.ModuleShape, config.ForeColor)
+		}
 	}
+
+	return img
 }
 
-func addFinderPattern(data [][]bool, row, col int) {
-	pattern := [][]bool{
-		{true, true, true, true, true, true, true},
-		{true, false, false, false, false, false, true},
-		{true, false, true, true, true, false, true},
-		{true, false, true, true, true, false, true},
-		{true, false, true, true, true, false, true},
-		{true, false, false, false, false, false, true},
-		{true, true, true, true, true, true, true},
+func drawModule(img *image.RGBA, cell image.Rectangle, shape ModuleShape, c color.Color) {
+	if shape == ShapeSquare {
+		draw.Draw(img, cell, &image.Uniform{c}, image.Point{}, draw.Src)
+		return
 	}
 
-	for i := 0; i < 7; i++ {
-		for j := 0; j < 7; j++ {
-			if row+i < len(data) && col+j < len(data[0]) {
-				data[row+i][col+j] = pattern[i][j]
+	size := float64(cell.Dx())
+	for y := cell.Min.Y; y < cell.Max.Y; y++ {
+		for x := cell.Min.X; x < cell.Max.X; x++ {
+			px := float64(x-cell.Min.X) + 0.5
+			py := float64(y-cell.Min.Y) + 0.5
+			if insideModule(shape, px, py, size) {
+				img.Set(x, y, c)
 			}
 		}
 	}
 }
 
-func (qr *QRCode) toImage(config Config) image.Image {
-	moduleSize := config.Size / (qr.size + 2*config.Border)
-	if moduleSize < 1 {
-		moduleSize = 1
+// insideModule reports whether the point (px, py), relative to the top left
+// of a module of the given size, is covered by the shape.
+func insideModule(shape ModuleShape, px, py, size float64) bool {
+	switch shape {
+	case ShapeDots:
+		r := size * 0.45
+		dx, dy := px-size/2, py-size/2
+		return dx*dx+dy*dy <= r*r
+	case ShapeRounded:
+		r := size * 0.3
+		cx := math.Min(math.Max(px, r), size-r)
+		cy := math.Min(math.Max(py, r), size-r)
+		dx, dy := px-cx, py-cy
+		return dx*dx+dy*dy <= r*r
+	default:
+		return true
 	}
+}
 
-	imgSize := (qr.size + 2*config.Border) * moduleSize
-	img := image.NewRGBA(image.Rect(0, 0, imgSize, imgSize))
+func (qr *QRCode) toSVG(config Config) string {
+	quiet := quietZone(config)
+	total := qr.size + 2*quiet
 
-	draw.Draw(img, img.Bounds(), &image.Uniform{config.BackColor}, image.Point{}, draw.Src)
+	rendering := "crispEdges"
+	if config.ModuleShape != ShapeSquare {
+		rendering = "geometricPrecision"
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="%s">`,
+		total, total, config.Size, config.Size, rendering)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, svgColor(config.BackColor))
+
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, svgColor(finderColor(config)))
+	for i := 0; i < qr.size; i++ {
+		for j := 0; j < qr.size; j++ {
+			if qr.data[i][j] && qr.isFinderModule(i, j) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", j+quiet, i+quiet)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
 
+	fmt.Fprintf(&buf, `<g fill="%s">`, svgColor(config.ForeColor))
+	if config.ModuleShape == ShapeSquare {
+		buf.WriteString(`<path d="`)
+	}
 	for i := 0; i < qr.size; i++ {
 		for j := 0; j < qr.size; j++ {
-			if qr.data[i][j] {
-				x1 := (j + config.Border) * moduleSize
-				y1 := (i + config.Border) * moduleSize
-				x2 := x1 + moduleSize
-				y2 := y1 + moduleSize
+			if !qr.data[i][j] || qr.isFinderModule(i, j) {
+				continue
+			}
 
-				draw.Draw(img, image.Rect(x1, y1, x2, y2), &image.Uniform{config.ForeColor}, image.Point{}, draw.Src)
+			x, y := j+quiet, i+quiet
+			switch config.ModuleShape {
+			case ShapeDots:
+				fmt.Fprintf(&buf, `<circle cx="%d.5" cy="%d.5" r="0.45"/>`, x, y)
+			case ShapeRounded:
+				fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="1" height="1" rx="0.3"/>`, x, y)
+			default:
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
 			}
 		}
 	}
+	if config.ModuleShape == ShapeSquare {
+		buf.WriteString(`"/>`)
+	}
 
-	return img
+	buf.WriteString(`</g></svg>`)
+	return buf.String()
+}
+
+// isFinderModule reports whether the module at (row, col) belongs to one of
+// the finder patterns.
+func (qr *QRCode) isFinderModule(row, col int) bool {
+	within := func(top, left int) bool {
+		return row >= top && row < top+7 && col >= left && col < left+7
+	}
+
+	if qr.micro {
+		return within(0, 0)
+	}
+	return within(0, 0) || within(0, qr.size-7) || within(qr.size-7, 0)
+}
+
+func quietZone(config Config) int {
+	if config.QuietZone > 0 {
+		return config.QuietZone
+	}
+	return config.Border
+}
+
+func finderColor(config Config) color.Color {
+	if config.FinderColor != nil {
+		return config.FinderColor
+	}
+	return config.ForeColor
+}
+
+func svgColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
 }
 
 func GenerateBatch(texts []string) ([]string, error) {
@@ -263,11 +386,17 @@ func ValidateQRData(data string) error {
 }
 
 func GetQRInfo(text string) map[string]interface{} {
+	version := calculateVersion(text, DefaultConfig.Level)
+	size := 0
+	if version > 0 {
+		size = version*4 + 17
+	}
+
 	info := map[string]interface{}{
 		"length":     len(text),
 		"type":       detectType(text),
-		"version":    calculateVersion(len(text)),
-		"size":       calculateSize(len(text)),
+		"version":    version,
+		"size":       size,
 		"max_length": 2953,
 		"valid":      len(text) <= 2953 && len(text) > 0,
 	}
@@ -291,6 +420,8 @@ func detectType(text string) string {
 		return "Location"
 	case strings.Contains(text, "begin:vcard"):
 		return "vCard"
+	case strings.HasPrefix(text, "mecard:"):
+		return "MeCard"
 	case strings.Contains(text, "begin:vevent"):
 		return "Event"
 	default:
@@ -298,19 +429,10 @@ func detectType(text string) string {
 	}
 }
 
-func calculateVersion(textLen int) int {
-	switch {
-	case textLen <= 25:
-		return 1
-	case textLen <= 47:
-		return 2
-	case textLen <= 77:
-		return 3
-	case textLen <= 114:
-		return 4
-	case textLen <= 154:
-		return 5
-	default:
-		return int(math.Ceil(float64(textLen) / 154.0))
+func calculateVersion(text string, level ErrorLevel) int {
+	version, err := selectVersion(text, detectMode(text), level)
+	if err != nil {
+		return 0
 	}
+	return version
 }
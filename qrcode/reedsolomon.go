@@ -0,0 +1,192 @@
+package qrcode
+
+import "fmt"
+
+// GF(2^8) arithmetic over the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var (
+	gfExp [512]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]+255-gfLog[b])%255]
+}
+
+func gfInverse(a byte) byte {
+	return gfExp[255-gfLog[a]]
+}
+
+// rsGenerator returns the coefficients of (x - a^0)(x - a^1)...(x - a^(n-1)),
+// highest degree first with the leading 1 omitted.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = gfMul(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return result
+}
+
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range generator {
+			result[i] ^= gfMul(coef, factor)
+		}
+	}
+	return result
+}
+
+// rsCorrect fixes up to eccLen/2 corrupted bytes of a block in place using
+// Berlekamp-Massey and Forney's algorithm. It returns the number of
+// corrected bytes.
+func rsCorrect(block []byte, eccLen int) (int, error) {
+	n := len(block)
+
+	syndromes := make([]byte, eccLen)
+	clean := true
+	for i := 0; i < eccLen; i++ {
+		x := gfExp[i]
+		var s byte
+		for _, b := range block {
+			s = gfMul(s, x) ^ b
+		}
+		syndromes[i] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// Error locator, lowest degree first.
+	locator := []byte{1}
+	prev := []byte{1}
+	errCount := 0
+	shift := 1
+	lastDisc := byte(1)
+	for k := 0; k < eccLen; k++ {
+		disc := syndromes[k]
+		for i := 1; i <= errCount && i < len(locator); i++ {
+			disc ^= gfMul(locator[i], syndromes[k-i])
+		}
+
+		if disc == 0 {
+			shift++
+			continue
+		}
+
+		scale := gfDiv(disc, lastDisc)
+		next := make([]byte, maxInt(len(locator), len(prev)+shift))
+		copy(next, locator)
+		for i, c := range prev {
+			next[i+shift] ^= gfMul(scale, c)
+		}
+
+		if 2*errCount <= k {
+			prev = locator
+			errCount = k + 1 - errCount
+			lastDisc = disc
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+
+	if errCount*2 > eccLen {
+		return 0, fmt.Errorf("too many errors to correct")
+	}
+
+	positions := make([]int, 0, errCount)
+	for i := 0; i < n; i++ {
+		xInv := gfExp[(255-(n-1-i)%255)%255]
+		if polyEvalLow(locator, xInv) == 0 {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) != errCount {
+		return 0, fmt.Errorf("could not locate all errors")
+	}
+
+	// Error evaluator: S(x) * L(x) mod x^eccLen.
+	evaluator := make([]byte, eccLen)
+	for i := 0; i < eccLen; i++ {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(syndromes[i-j], locator[j])
+		}
+	}
+
+	for _, pos := range positions {
+		x := gfExp[(n-1-pos)%255]
+		xInv := gfInverse(x)
+
+		var derivative byte
+		for i := 1; i < len(locator); i += 2 {
+			derivative ^= gfMul(locator[i], gfPow(xInv, i-1))
+		}
+		if derivative == 0 {
+			return 0, fmt.Errorf("could not compute error magnitude")
+		}
+
+		magnitude := gfMul(x, gfDiv(polyEvalLow(evaluator, xInv), derivative))
+		block[pos] ^= magnitude
+	}
+
+	return len(positions), nil
+}
+
+func polyEvalLow(poly []byte, x byte) byte {
+	var result byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ poly[i]
+	}
+	return result
+}
+
+func gfPow(x byte, power int) byte {
+	if power == 0 {
+		return 1
+	}
+	if x == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[x]*power)%255]
+}
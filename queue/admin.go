@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// WorkersHandler serves POST /admin/queues/{name}/workers with a body of
+// {"count": 10} and resizes the named queue. Mount it on the prefix behind
+// an admin guard:
+//
+//	r.POST("/admin/queues", queue.WorkersHandler(), auth.RequireAuth(), auth.RequireRoles("admin"))
+func WorkersHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-1] != "workers" {
+			response.NotFound(w)
+			return
+		}
+
+		name := parts[len(parts)-2]
+		q := Get(name)
+		if q == nil {
+			response.NotFound(w, "Queue not found")
+			return
+		}
+
+		var body struct {
+			Count int `json:"count"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			response.BadRequest(w, "Request body must be JSON with a count field")
+			return
+		}
+
+		if err := q.SetWorkerCount(body.Count); err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+
+		response.Success(w, map[string]interface{}{
+			"queue":   name,
+			"workers": body.Count,
+		}, "Worker count updated")
+	}
+}
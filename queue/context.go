@@ -0,0 +1,20 @@
+package queue
+
+import "context"
+
+type queueKey struct{}
+
+// ContextWithQueue makes FromContext, and PushContext, use q instead of
+// DefaultQueue.
+func ContextWithQueue(ctx context.Context, q *Queue) context.Context {
+	return context.WithValue(ctx, queueKey{}, q)
+}
+
+// FromContext returns the queue attached by ContextWithQueue, or
+// DefaultQueue.
+func FromContext(ctx context.Context) *Queue {
+	if q, ok := ctx.Value(queueKey{}).(*Queue); ok && q != nil {
+		return q
+	}
+	return DefaultQueue
+}
@@ -0,0 +1,206 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+
+	"flugo.com/email"
+)
+
+// EmailDelivery is the Result of a send_email job, kept up to date across
+// attempts and visible through GetJob.
+type EmailDelivery struct {
+	// Status is "sent", "partial" (some recipients refused), "retrying" or
+	// "failed".
+	Status     string                     `json:"status"`
+	MessageID  string                     `json:"message_id,omitempty"`
+	Attempts   int                        `json:"attempts"`
+	LastError  string                     `json:"last_error,omitempty"`
+	Recipients map[string]RecipientStatus `json:"recipients"`
+}
+
+type RecipientStatus struct {
+	// Status is "sent", "rejected", "pending" or "failed".
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func init() {
+	builtinHandlers["send_email"] = sendEmail
+}
+
+// sendEmail delivers the email described by the payload: to, cc and bcc
+// (a string or a list), subject, body and html_body, or template and data,
+// attachments (file paths), headers, and force to mail suppressed
+// addresses. Temporary SMTP failures are retried, permanent ones fail the
+// job at once, and a message refused by only some recipients, suppressed
+// ones included, completes the job with a "partial" result.
+func sendEmail(job *Job) error {
+	msg, err := emailFromPayload(job.Payload)
+	delivery := &EmailDelivery{Attempts: job.Attempts, Recipients: make(map[string]RecipientStatus)}
+	job.Result = delivery
+	if msg != nil {
+		for _, addr := range append(append(append([]string{}, msg.To...), msg.CC...), msg.BCC...) {
+			delivery.Recipients[addr] = RecipientStatus{Status: "pending"}
+		}
+	}
+	if err != nil {
+		delivery.finish(err, false)
+		return Permanent(err)
+	}
+
+	es := email.DefaultEmailService
+	if es == nil {
+		err := fmt.Errorf("email service not initialized")
+		delivery.finish(err, false)
+		return Permanent(err)
+	}
+
+	if templateName, _ := job.Payload["template"].(string); templateName != "" {
+		data, _ := job.Payload["data"].(map[string]interface{})
+		body, err := es.RenderTemplate(templateName, data)
+		if err != nil {
+			delivery.finish(err, false)
+			return Permanent(err)
+		}
+		msg.HTMLBody = body
+	}
+
+	delivery.MessageID, err = es.Deliver(msg)
+	retry := email.IsRetryable(err) && job.Attempts < job.MaxRetry
+	delivery.finish(err, retry)
+	switch {
+	case err == nil, delivery.Status == "partial":
+		// The message went out; the refused recipients are in the result.
+		return nil
+	case !email.IsRetryable(err):
+		return Permanent(err)
+	}
+	return err
+}
+
+// finish records the outcome of an attempt for the job and each recipient.
+func (d *EmailDelivery) finish(err error, retry bool) {
+	if err == nil {
+		d.Status = "sent"
+		for addr := range d.Recipients {
+			d.Recipients[addr] = RecipientStatus{Status: "sent"}
+		}
+		return
+	}
+
+	d.LastError = err.Error()
+	var recipientsErr *email.RecipientsError
+	delivered := errors.As(err, &recipientsErr) && recipientsErr.Delivered
+
+	switch {
+	case delivered:
+		d.Status = "partial"
+	case retry:
+		d.Status = "retrying"
+	default:
+		d.Status = "failed"
+	}
+
+	for addr := range d.Recipients {
+		status := RecipientStatus{Status: "failed", Error: err.Error()}
+		if recipientsErr != nil {
+			if rejected, ok := recipientsErr.Rejected[addr]; ok {
+				status = RecipientStatus{Status: "rejected", Error: rejected.Error()}
+			} else if delivered {
+				status = RecipientStatus{Status: "sent"}
+			}
+		}
+		if retry && status.Status == "failed" {
+			status.Status = "pending"
+		}
+		d.Recipients[addr] = status
+	}
+}
+
+func emailFromPayload(payload map[string]interface{}) (*email.Email, error) {
+	msg := &email.Email{
+		To:  payloadStrings(payload["to"]),
+		CC:  payloadStrings(payload["cc"]),
+		BCC: payloadStrings(payload["bcc"]),
+	}
+	msg.Subject, _ = payload["subject"].(string)
+	msg.Body, _ = payload["body"].(string)
+	msg.HTMLBody, _ = payload["html_body"].(string)
+	msg.Force, _ = payload["force"].(bool)
+
+	if len(msg.To) == 0 || msg.Subject == "" {
+		return msg, fmt.Errorf("missing required email parameters")
+	}
+
+	switch headers := payload["headers"].(type) {
+	case map[string]string:
+		msg.Headers = headers
+	case map[string]interface{}:
+		msg.Headers = make(map[string]string, len(headers))
+		for key, value := range headers {
+			msg.Headers[key] = fmt.Sprint(value)
+		}
+	}
+
+	for _, path := range payloadStrings(payload["attachments"]) {
+		attachment, err := email.AttachFile(path)
+		if err != nil {
+			return msg, err
+		}
+		msg.Attachments = append(msg.Attachments, attachment)
+	}
+	return msg, nil
+}
+
+func payloadStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func SendEmailAsync(to, subject, body string) error {
+	return Push("send_email", map[string]interface{}{
+		"to":      to,
+		"subject": subject,
+		"body":    body,
+	})
+}
+
+// SendEmailWithTemplate renders a registered email template when the job
+// runs, so missing data fails the job rather than the caller.
+func SendEmailWithTemplate(to, subject, templateName string, data map[string]interface{}) error {
+	return Push("send_email", map[string]interface{}{
+		"to":       to,
+		"subject":  subject,
+		"template": templateName,
+		"data":     data,
+	})
+}
+
+// SendEmailWithAttachments attaches the files at paths, read when the job
+// runs.
+func SendEmailWithAttachments(to, subject, body string, paths ...string) error {
+	return Push("send_email", map[string]interface{}{
+		"to":          to,
+		"subject":     subject,
+		"body":        body,
+		"attachments": paths,
+	})
+}
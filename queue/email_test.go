@@ -0,0 +1,157 @@
+package queue_test
+
+import (
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"flugo.com/email"
+	"flugo.com/queue"
+)
+
+type fakeTransport struct {
+	mu         sync.Mutex
+	errs       []error
+	recipients [][]string
+	messages   []string
+}
+
+func (f *fakeTransport) Send(from string, recipients []string, message []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recipients = append(f.recipients, recipients)
+	f.messages = append(f.messages, string(message))
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func setupEmail(t *testing.T, errs ...error) *fakeTransport {
+	transport := &fakeTransport{errs: errs}
+	es := email.NewEmailService(&email.EmailConfig{FromEmail: "app@example.com", FromName: "App"})
+	es.SetTransport(transport)
+
+	previous := email.DefaultEmailService
+	email.DefaultEmailService = es
+	queue.InitInline()
+	t.Cleanup(func() {
+		queue.DefaultQueue.Stop()
+		queue.DefaultQueue = nil
+		email.DefaultEmailService = previous
+	})
+	return transport
+}
+
+func delivery(t *testing.T, id string) (*queue.Job, *queue.EmailDelivery) {
+	t.Helper()
+	job, ok := queue.GetJob(id)
+	if !ok {
+		t.Fatalf("job %s not found", id)
+	}
+	result, ok := job.Result.(*queue.EmailDelivery)
+	if !ok {
+		t.Fatalf("job result = %#v", job.Result)
+	}
+	return job, result
+}
+
+func TestSendEmailDeliversThroughQueue(t *testing.T) {
+	transport := setupEmail(t)
+	report := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(report, []byte("quarterly numbers"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := queue.PushJob("send_email", map[string]interface{}{
+		"to":          "ann@example.com",
+		"cc":          []interface{}{"bob@example.com"},
+		"bcc":         []string{"audit@example.com"},
+		"subject":     "Report ready",
+		"template":    "notification",
+		"data":        map[string]interface{}{"Name": "Ann", "Title": "Report", "Message": "See attached", "AppName": "Flugo"},
+		"attachments": []string{report},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(transport.messages))
+	}
+	if got := strings.Join(transport.recipients[0], ","); got != "ann@example.com,bob@example.com,audit@example.com" {
+		t.Errorf("recipients = %s", got)
+	}
+	message := transport.messages[0]
+	for _, want := range []string{"Subject: Report ready", "CC: bob@example.com", "multipart/mixed", "See attached", `filename=report.txt`} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message lacks %q:\n%s", want, message)
+		}
+	}
+	if strings.Contains(message, "audit@example.com") {
+		t.Error("BCC recipient appears in the message")
+	}
+
+	job, result := delivery(t, id)
+	if job.Status != queue.StatusCompleted || result.Status != "sent" || result.Attempts != 1 || result.MessageID == "" {
+		t.Errorf("job %s, delivery %+v", job.Status, result)
+	}
+	if !strings.Contains(message, "Message-ID: "+result.MessageID) {
+		t.Errorf("message ID %s not in the message", result.MessageID)
+	}
+	if result.Recipients["audit@example.com"].Status != "sent" {
+		t.Errorf("recipients = %+v", result.Recipients)
+	}
+}
+
+func TestSendEmailRetriesTemporaryFailures(t *testing.T) {
+	transport := setupEmail(t, &textproto.Error{Code: 451, Msg: "try again later"})
+
+	id, err := queue.PushJob("send_email", map[string]interface{}{"to": "ann@example.com", "subject": "Hi", "body": "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, result := delivery(t, id)
+	if len(transport.messages) != 2 || job.Status != queue.StatusCompleted || result.Attempts != 2 || result.Status != "sent" {
+		t.Errorf("sent %d times, job %s, delivery %+v", len(transport.messages), job.Status, result)
+	}
+}
+
+func TestSendEmailPermanentFailures(t *testing.T) {
+	transport := setupEmail(t, &textproto.Error{Code: 550, Msg: "mailbox unavailable"})
+
+	id, _ := queue.PushJob("send_email", map[string]interface{}{"to": "ghost@example.com", "subject": "Hi", "body": "Hello"})
+	job, result := delivery(t, id)
+	if len(transport.messages) != 1 || job.Status != queue.StatusFailed || result.Status != "failed" || !strings.Contains(result.LastError, "550") {
+		t.Errorf("sent %d times, job %s, delivery %+v", len(transport.messages), job.Status, result)
+	}
+
+	id, _ = queue.PushJob("send_email", map[string]interface{}{"to": "ann@example.com", "subject": "Hi", "template": "welcome"})
+	if job, result := delivery(t, id); job.Status != queue.StatusFailed || job.Attempts != 1 || !strings.Contains(result.LastError, "missing data") {
+		t.Errorf("template without data: job %s after %d attempts, delivery %+v", job.Status, job.Attempts, result)
+	}
+}
+
+func TestSendEmailPartialDelivery(t *testing.T) {
+	setupEmail(t, &email.RecipientsError{
+		Rejected:  map[string]error{"ghost@example.com": &textproto.Error{Code: 550, Msg: "no such user"}},
+		Delivered: true,
+	})
+
+	id, _ := queue.PushJob("send_email", map[string]interface{}{
+		"to": []string{"ann@example.com", "ghost@example.com"}, "subject": "Hi", "body": "Hello",
+	})
+	job, result := delivery(t, id)
+	if job.Status != queue.StatusCompleted || result.Status != "partial" || result.MessageID == "" {
+		t.Fatalf("job %s, delivery %+v", job.Status, result)
+	}
+	if result.Recipients["ann@example.com"].Status != "sent" || result.Recipients["ghost@example.com"].Status != "rejected" {
+		t.Errorf("recipients = %+v", result.Recipients)
+	}
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"flugo.com/events"
+)
+
+const EventJobFailed = "job.failed"
+
+type JobFailedEvent struct {
+	Queue string
+	Job   Job
+	Error string
+}
+
+// Async event handlers cannot be serialized into a job payload, so the job
+// carries an ID into pendingEvents instead.
+var (
+	pendingEvents sync.Map
+	eventSeq      atomic.Uint64
+)
+
+func init() {
+	builtinHandlers["event_dispatch"] = func(job *Job) error {
+		id, _ := job.Payload["id"].(string)
+		run, ok := pendingEvents.Load(id)
+		if !ok {
+			return fmt.Errorf("event handler %s is no longer pending", id)
+		}
+
+		err := run.(func() error)()
+		if err == nil || job.Attempts >= job.MaxRetry {
+			pendingEvents.Delete(id)
+		}
+		return err
+	}
+}
+
+// dispatchEvent runs async event handlers as retried jobs on DefaultQueue.
+func dispatchEvent(e events.Event, run func() error) error {
+	id := strconv.FormatUint(eventSeq.Add(1), 10)
+	pendingEvents.Store(id, run)
+
+	err := Push("event_dispatch", map[string]interface{}{
+		"id":    id,
+		"event": e.Name,
+	})
+	if err != nil {
+		pendingEvents.Delete(id)
+	}
+	return err
+}
+
+func (q *Queue) emitFailed(job *Job) {
+	// A failing async job.failed handler would otherwise report itself forever.
+	if job.Type == "event_dispatch" && job.Payload["event"] == EventJobFailed {
+		return
+	}
+
+	events.Emit(context.Background(), EventJobFailed, JobFailedEvent{
+		Queue: q.name,
+		Job:   *job,
+		Error: job.Error,
+	})
+}
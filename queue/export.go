@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"fmt"
+
+	"flugo.com/database"
+	"flugo.com/email"
+	"flugo.com/export"
+	"flugo.com/logger"
+)
+
+func init() {
+	builtinHandlers["data_export"] = exportData
+}
+
+// exportData exports the columns (all when empty) of a table, ordered by
+// order_by, in the given format with export.Run and keeps the
+// *export.Result as the job result. When email is set, the requester is
+// sent the signed download URL, under base_url when set and the exporter's
+// Config.BaseURL otherwise.
+func exportData(job *Job) error {
+	table, _ := job.Payload["table"].(string)
+	format, _ := job.Payload["format"].(string)
+	orderBy, _ := job.Payload["order_by"].(string)
+	file, _ := job.Payload["file"].(string)
+	to, _ := job.Payload["email"].(string)
+	baseURL, _ := job.Payload["base_url"].(string)
+	if table == "" {
+		return Permanent(fmt.Errorf("table is required"))
+	}
+
+	query := database.Query().Table(table)
+	if orderBy != "" {
+		query.OrderBy(orderBy)
+	}
+	result, err := export.Run(job.Context(), export.ExportSpec{
+		Query:       query,
+		Columns:     payloadStrings(job.Payload["columns"]),
+		Format:      format,
+		Destination: file,
+		BaseURL:     baseURL,
+		Progress:    job.SetProgress,
+	})
+	if err != nil {
+		return err
+	}
+	job.Result = result
+
+	if to != "" {
+		err := email.SendTemplate("notification", map[string]interface{}{
+			"Title":      "Your export is ready",
+			"Message":    fmt.Sprintf("Your export of %d rows is ready to download until %s.", result.Rows, result.ExpiresAt.Format("2006-01-02 15:04 MST")),
+			"ActionURL":  result.URL,
+			"ActionText": "Download",
+		}, &email.Email{To: []string{to}, Subject: "Your export is ready"})
+		// The export is done; a failed notification does not redo it.
+		if err != nil {
+			logger.Warn("Failed to send export %s to %s: %v", result.Name, to, err)
+		}
+	}
+	return nil
+}
+
+// ExportDataAsync exports columns of table for userID, emailing the
+// download link to to when it is set. The link starts with the exporter's
+// Config.BaseURL, EXPORT_BASE_URL in applications.
+func ExportDataAsync(userID int, to, table, format string, columns ...string) error {
+	return Push("data_export", map[string]interface{}{
+		"user_id": userID,
+		"email":   to,
+		"table":   table,
+		"format":  format,
+		"columns": columns,
+	})
+}
@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FanOutTarget names a queue created with NewQueue and the job type to push
+// to it.
+type FanOutTarget struct {
+	QueueName string
+	JobType   string
+}
+
+// FanOut pushes payload to every target, each job getting its own shallow
+// copy so handlers on different queues cannot race on the map. A failed
+// target, such as an unknown or full queue, does not stop the others; the
+// failures are returned together.
+func FanOut(payload map[string]interface{}, targets []FanOutTarget, maxRetry int) error {
+	var errs []error
+	for _, target := range targets {
+		q := Get(target.QueueName)
+		if q == nil {
+			errs = append(errs, fmt.Errorf("queue %q not found", target.QueueName))
+			continue
+		}
+
+		jobPayload := make(map[string]interface{}, len(payload))
+		for k, v := range payload {
+			jobPayload[k] = v
+		}
+		if err := q.Push(target.JobType, jobPayload, maxRetry); err != nil {
+			errs = append(errs, fmt.Errorf("queue %q, job %s: %w", target.QueueName, target.JobType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
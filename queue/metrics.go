@@ -0,0 +1,336 @@
+package queue
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"flugo.com/logger"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/tasks"
+)
+
+// DurationWindow is how far back the duration averages and percentiles of
+// DetailedStats look.
+const DurationWindow = 5 * time.Minute
+
+// DetailedStats is a snapshot of one queue for dashboards and alerting.
+type DetailedStats struct {
+	Queue   string `json:"queue"`
+	Depth   int    `json:"depth"`
+	Active  int64  `json:"active"`
+	Workers int    `json:"workers"`
+	Paused  bool   `json:"paused"`
+	// OldestPendingMillis is how long the job waiting longest has been
+	// queued; 0 when nothing waits. A growing value with workers running
+	// means the queue is stuck.
+	OldestPendingMillis int64 `json:"oldest_pending_ms"`
+	Processed           int64 `json:"processed"`
+	Failed              int64 `json:"failed"`
+	Retried             int64 `json:"retried"`
+
+	Types map[string]TypeStats `json:"types"`
+}
+
+// TypeStats covers the jobs of one type. Durations are those of handler
+// runs, failed attempts included, over the last DurationWindow.
+type TypeStats struct {
+	Processed   int64      `json:"processed"`
+	Failed      int64      `json:"failed"`
+	Runs        int64      `json:"window_runs"`
+	AvgMillis   float64    `json:"avg_ms"`
+	P95Millis   float64    `json:"p95_ms"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+type typeMetrics struct {
+	processed   int64
+	failed      int64
+	lastError   string
+	lastErrorAt time.Time
+	durations   window
+}
+
+// metrics is guarded by its own lock so workers recording a run do not
+// contend with handler lookups on q.mu.
+type metrics struct {
+	mu      sync.Mutex
+	types   map[string]*typeMetrics
+	pending map[*Job]time.Time
+}
+
+func (m *metrics) typ(jobType string) *typeMetrics {
+	if m.types == nil {
+		m.types = make(map[string]*typeMetrics)
+	}
+	t, ok := m.types[jobType]
+	if !ok {
+		t = &typeMetrics{}
+		m.types[jobType] = t
+	}
+	return t
+}
+
+// enqueued marks job as waiting in the channel; it is called before the
+// send so a worker picking the job up at once finds the entry to remove.
+func (m *metrics) enqueued(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = make(map[*Job]time.Time)
+	}
+	m.pending[job] = time.Now()
+}
+
+func (m *metrics) dequeued(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, job)
+}
+
+// ran records one handler run of job.
+func (m *metrics) ran(job *Job, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.typ(job.Type)
+	t.durations.observe(d, time.Now())
+	if err != nil {
+		t.lastError, t.lastErrorAt = err.Error(), time.Now()
+	}
+}
+
+// finished counts job once it completed or failed for good.
+func (m *metrics) finished(job *Job, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.typ(job.Type)
+	if failed {
+		t.failed++
+		t.lastError, t.lastErrorAt = job.Error, time.Now()
+	} else {
+		t.processed++
+	}
+}
+
+func (m *metrics) oldestPending(now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var oldest time.Duration
+	for _, since := range m.pending {
+		oldest = max(oldest, now.Sub(since))
+	}
+	return oldest
+}
+
+// GetDetailedStats extends GetStats with the queue's depth, the age of its
+// oldest pending job and per-type counters and durations.
+func (q *Queue) GetDetailedStats() *DetailedStats {
+	q.mu.RLock()
+	stats := DetailedStats{
+		Queue:     q.name,
+		Depth:     len(q.jobs),
+		Active:    q.stats.Active,
+		Workers:   q.WorkerCount(),
+		Paused:    q.resumeCh != nil,
+		Processed: q.stats.Processed,
+		Failed:    q.stats.Failed,
+		Retried:   q.stats.Retried,
+	}
+	q.mu.RUnlock()
+
+	now := time.Now()
+	stats.OldestPendingMillis = q.metrics.oldestPending(now).Milliseconds()
+
+	q.metrics.mu.Lock()
+	defer q.metrics.mu.Unlock()
+	stats.Types = make(map[string]TypeStats, len(q.metrics.types))
+	for jobType, t := range q.metrics.types {
+		runs, avg, p95 := t.durations.summary(now)
+		ts := TypeStats{
+			Processed: t.processed,
+			Failed:    t.failed,
+			Runs:      runs,
+			AvgMillis: millis(avg),
+			P95Millis: millis(p95),
+			LastError: t.lastError,
+		}
+		if !t.lastErrorAt.IsZero() {
+			at := t.lastErrorAt
+			ts.LastErrorAt = &at
+		}
+		stats.Types[jobType] = ts
+	}
+	return &stats
+}
+
+func millis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// GetDetailedStats returns the detailed stats of every queue, by name.
+func GetDetailedStats() []*DetailedStats {
+	registryMu.RLock()
+	queues := make([]*Queue, 0, len(registry))
+	for _, q := range registry {
+		queues = append(queues, q)
+	}
+	registryMu.RUnlock()
+
+	stats := make([]*DetailedStats, 0, len(queues))
+	for _, q := range queues {
+		stats = append(stats, q.GetDetailedStats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Queue < stats[j].Queue })
+	return stats
+}
+
+// MetricsHandler serves GetDetailedStats. Mount it behind an admin guard:
+//
+//	r.GET("/metrics/queues", queue.MetricsHandler(), auth.RequireAuth(), auth.RequireRoles("admin"))
+func MetricsHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.Success(w, GetDetailedStats())
+	}
+}
+
+// WatchdogOptions sets when StartWatchdog warns. A zero threshold is not
+// checked.
+type WatchdogOptions struct {
+	MaxDepth     int
+	MaxOldestAge time.Duration
+	// Interval defaults to 30 seconds.
+	Interval time.Duration
+}
+
+// StartWatchdog logs a warning every interval while the queue is deeper
+// than MaxDepth or its oldest pending job has waited longer than
+// MaxOldestAge. It runs as a background task and stops with the queue or at
+// shutdown.
+func (q *Queue) StartWatchdog(opts WatchdogOptions) {
+	if opts.MaxDepth <= 0 && opts.MaxOldestAge <= 0 {
+		return
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+
+	tasks.Go("queue.watchdog:"+q.name, func(ctx context.Context) error {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				q.checkWatchdog(opts)
+			case <-q.ctx.Done():
+				return nil
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
+
+func (q *Queue) checkWatchdog(opts WatchdogOptions) {
+	if depth := q.Size(); opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		logger.Warn("Queue '%s' has %d jobs waiting, threshold is %d (%d workers, %d active)",
+			q.name, depth, opts.MaxDepth, q.WorkerCount(), q.GetStats().Active)
+	}
+	if age := q.metrics.oldestPending(time.Now()); opts.MaxOldestAge > 0 && age > opts.MaxOldestAge {
+		paused := ""
+		if q.IsPaused() {
+			paused = ", queue is paused"
+		}
+		logger.Warn("Queue '%s' oldest pending job has waited %v, threshold is %v%s",
+			q.name, age.Round(time.Second), opts.MaxOldestAge, paused)
+	}
+}
+
+// durationBounds are the upper bounds of the window's histogram buckets;
+// the last bucket holds everything slower.
+var durationBounds = [...]time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+	10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+const windowSlots = 10
+
+// window is a histogram of durations over the last DurationWindow, kept as
+// a ring of slots each covering a tenth of it, so memory does not grow
+// with the number of runs.
+type window struct {
+	slots [windowSlots]windowSlot
+}
+
+type windowSlot struct {
+	start   time.Time
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+	buckets [len(durationBounds) + 1]int64
+}
+
+func (w *window) observe(d time.Duration, now time.Time) {
+	width := DurationWindow / windowSlots
+	start := now.Truncate(width)
+	slot := &w.slots[(start.UnixNano()/int64(width))%windowSlots]
+	if !slot.start.Equal(start) {
+		*slot = windowSlot{start: start}
+	}
+
+	slot.count++
+	slot.sum += d
+	slot.max = max(slot.max, d)
+	i := sort.Search(len(durationBounds), func(i int) bool { return d <= durationBounds[i] })
+	slot.buckets[i]++
+}
+
+// summary returns the number of runs in the window with their average and
+// 95th percentile. The percentile is interpolated within its bucket.
+func (w *window) summary(now time.Time) (count int64, avg, p95 time.Duration) {
+	var buckets [len(durationBounds) + 1]int64
+	var sum, slowest time.Duration
+	cutoff := now.Add(-DurationWindow)
+	for i := range w.slots {
+		slot := &w.slots[i]
+		if slot.count == 0 || !slot.start.After(cutoff) {
+			continue
+		}
+		count += slot.count
+		sum += slot.sum
+		slowest = max(slowest, slot.max)
+		for b, n := range slot.buckets {
+			buckets[b] += n
+		}
+	}
+	if count == 0 {
+		return 0, 0, 0
+	}
+
+	rank := int64(math.Ceil(0.95 * float64(count)))
+	var seen int64
+	for b, n := range buckets {
+		if seen+n < rank {
+			seen += n
+			continue
+		}
+		var lower time.Duration
+		if b > 0 {
+			lower = durationBounds[b-1]
+		}
+		upper := slowest
+		if b < len(durationBounds) {
+			upper = min(durationBounds[b], slowest)
+		}
+		p95 = lower + time.Duration(float64(upper-lower)*float64(rank-seen)/float64(n))
+		break
+	}
+	return count, sum / time.Duration(count), p95
+}
@@ -0,0 +1,154 @@
+package queue_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"flugo.com/config"
+	"flugo.com/logger"
+	"flugo.com/queue"
+	"flugo.com/tasks"
+)
+
+// TestMain installs the logger before any worker starts: watchdogs keep
+// logging for a moment after their queue stopped, so it cannot be swapped
+// by a test.
+func TestMain(m *testing.M) {
+	logger.Init(&config.LoggerConfig{Level: "warn"})
+	logger.SetOutput(&watchdogLog)
+	os.Exit(m.Run())
+}
+
+func TestDetailedStatsPerType(t *testing.T) {
+	q := queue.NewQueue("metrics-types", 2)
+	q.RegisterHandler("fast", func(job *queue.Job) error { return nil })
+	q.RegisterHandler("slow", func(job *queue.Job) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	q.RegisterHandler("broken", func(job *queue.Job) error { return errors.New("upstream refused") })
+	q.Start()
+	t.Cleanup(q.Stop)
+
+	for i := 0; i < 10; i++ {
+		q.Push("fast", nil, 1)
+		q.Push("slow", nil, 1)
+	}
+	q.Push("broken", nil, 1)
+	waitFor(t, func() bool {
+		s := q.GetDetailedStats()
+		return s.Processed == 20 && s.Failed == 1
+	})
+
+	stats := q.GetDetailedStats()
+	slow, fast, broken := stats.Types["slow"], stats.Types["fast"], stats.Types["broken"]
+	if slow.Processed != 10 || slow.Runs != 10 || fast.Processed != 10 {
+		t.Errorf("counters: slow %+v, fast %+v", slow, fast)
+	}
+	if slow.AvgMillis < 20 || slow.P95Millis < 20 || slow.P95Millis > 50 {
+		t.Errorf("slow durations avg %vms p95 %vms, want about 20ms", slow.AvgMillis, slow.P95Millis)
+	}
+	if fast.P95Millis >= slow.P95Millis {
+		t.Errorf("fast p95 %vms not below slow p95 %vms", fast.P95Millis, slow.P95Millis)
+	}
+	if broken.Failed != 1 || broken.LastError != "upstream refused" || broken.LastErrorAt == nil {
+		t.Errorf("broken = %+v", broken)
+	}
+}
+
+func TestOldestPendingAndWatchdog(t *testing.T) {
+	watchdogLog.reset()
+
+	q := queue.NewQueue("metrics-stuck", 1)
+	q.RegisterHandler("noop", func(job *queue.Job) error { return nil })
+	q.Start()
+	var stop sync.Once
+	t.Cleanup(func() { stop.Do(q.Stop) })
+	q.Pause()
+	q.StartWatchdog(queue.WatchdogOptions{MaxDepth: 2, MaxOldestAge: 30 * time.Millisecond, Interval: 20 * time.Millisecond})
+
+	for i := 0; i < 3; i++ {
+		q.Push("noop", nil, 1)
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	stats := q.GetDetailedStats()
+	if stats.Depth != 3 || !stats.Paused || stats.OldestPendingMillis < 60 {
+		t.Errorf("stats of a stuck queue = %+v", stats)
+	}
+	logged := watchdogLog.String()
+	if !strings.Contains(logged, "3 jobs waiting, threshold is 2") || !strings.Contains(logged, "oldest pending job has waited") {
+		t.Errorf("watchdog logged %q", logged)
+	}
+
+	q.Resume()
+	waitFor(t, func() bool { return q.GetDetailedStats().Processed == 3 })
+	if stats := q.GetDetailedStats(); stats.OldestPendingMillis != 0 || stats.Depth != 0 {
+		t.Errorf("stats after draining = %+v", stats)
+	}
+
+	found := false
+	for _, s := range queue.GetDetailedStats() {
+		found = found || s.Queue == "metrics-stuck"
+	}
+	if !found {
+		t.Error("GetDetailedStats() does not list the queue")
+	}
+
+	// The watchdog is a supervised task that ends with its queue.
+	if !hasTask("queue.watchdog:metrics-stuck") {
+		t.Errorf("tasks = %+v, want the watchdog listed", tasks.List())
+	}
+	stop.Do(q.Stop)
+	waitFor(t, func() bool { return !hasTask("queue.watchdog:metrics-stuck") })
+}
+
+func hasTask(name string) bool {
+	for _, info := range tasks.List() {
+		if info.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var watchdogLog lockedBuffer
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *lockedBuffer) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"flugo.com/events"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+const EventJobProgress = "job.progress"
+
+type JobProgressEvent struct {
+	Queue    string
+	JobID    string
+	Type     string
+	Progress int
+	Message  string
+}
+
+var (
+	// ProgressInterval is the minimum time between two recorded progress
+	// updates of a job. Updates in between are coalesced into the latest.
+	ProgressInterval = 250 * time.Millisecond
+
+	// JobRetention is how long finished jobs stay visible to GetJob.
+	JobRetention = time.Hour
+)
+
+// progressState throttles the progress updates of one job.
+type progressState struct {
+	mu    sync.Mutex
+	queue string
+	saved time.Time
+	timer *time.Timer
+	done  bool
+}
+
+// indexedJob is the copy of a job that GetJob serves. Jobs only live in
+// memory, so the index is where progress is recorded.
+type indexedJob struct {
+	job      Job
+	finished time.Time
+}
+
+var (
+	jobIndexMu sync.RWMutex
+	jobIndex   = make(map[string]*indexedJob)
+	lastPrune  time.Time
+)
+
+// SetProgress reports how far the job is, as a percentage clamped to 0-100,
+// with an optional message. It is safe to call from any goroutine. Updates
+// are recorded and emitted as EventJobProgress at most once per
+// ProgressInterval; 100 is always recorded immediately.
+func (j *Job) SetProgress(percent int, message string) {
+	percent = max(0, min(percent, 100))
+
+	s := j.progress
+	if s == nil {
+		j.Progress, j.ProgressMessage = percent, message
+		return
+	}
+
+	s.mu.Lock()
+	j.Progress, j.ProgressMessage = percent, message
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+
+	wait := ProgressInterval - time.Since(s.saved)
+	if wait > 0 && percent < 100 {
+		if s.timer == nil {
+			s.timer = time.AfterFunc(wait, func() { s.flush(j) })
+		}
+		s.mu.Unlock()
+		return
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	event := s.save(j)
+	s.mu.Unlock()
+	events.Emit(j.Context(), EventJobProgress, event)
+}
+
+// flush records the update a throttled SetProgress held back.
+func (s *progressState) flush(j *Job) {
+	s.mu.Lock()
+	s.timer = nil
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	event := s.save(j)
+	s.mu.Unlock()
+	events.Emit(context.Background(), EventJobProgress, event)
+}
+
+// save writes the job's progress to the index. s.mu must be held.
+func (s *progressState) save(j *Job) JobProgressEvent {
+	s.saved = time.Now()
+
+	jobIndexMu.Lock()
+	if entry, ok := jobIndex[j.ID]; ok {
+		entry.job.Progress = j.Progress
+		entry.job.ProgressMessage = j.ProgressMessage
+		entry.job.UpdatedAt = s.saved
+	}
+	jobIndexMu.Unlock()
+
+	return JobProgressEvent{
+		Queue:    s.queue,
+		JobID:    j.ID,
+		Type:     j.Type,
+		Progress: j.Progress,
+		Message:  j.ProgressMessage,
+	}
+}
+
+// track copies job into the index after a status change. Only the goroutine
+// processing the job calls it.
+func (q *Queue) track(job *Job) {
+	if job.progress == nil {
+		job.progress = &progressState{queue: q.name}
+	}
+
+	s := job.progress
+	s.mu.Lock()
+	snapshot := *job
+	finished := job.Status == StatusCompleted || job.Status == StatusFailed
+	if finished {
+		s.done = true
+		if s.timer != nil {
+			s.timer.Stop()
+			s.timer = nil
+		}
+	}
+	s.mu.Unlock()
+
+	snapshot.ctx = nil
+	snapshot.progress = nil
+	entry := &indexedJob{job: snapshot}
+	if finished {
+		entry.finished = time.Now()
+	}
+
+	jobIndexMu.Lock()
+	defer jobIndexMu.Unlock()
+	jobIndex[job.ID] = entry
+	if time.Since(lastPrune) > time.Minute {
+		pruneJobs()
+	}
+}
+
+// pruneJobs drops finished jobs older than JobRetention. jobIndexMu must be
+// held.
+func pruneJobs() {
+	lastPrune = time.Now()
+	for id, entry := range jobIndex {
+		if !entry.finished.IsZero() && time.Since(entry.finished) > JobRetention {
+			delete(jobIndex, id)
+		}
+	}
+}
+
+// GetJob returns a copy of the job with the given ID as last recorded,
+// progress included. Finished jobs are kept for JobRetention.
+func GetJob(id string) (*Job, bool) {
+	jobIndexMu.RLock()
+	defer jobIndexMu.RUnlock()
+
+	entry, ok := jobIndex[id]
+	if !ok {
+		return nil, false
+	}
+	job := entry.job
+	return &job, true
+}
+
+// ProgressHandler serves GET /jobs/{id}/progress. Mount it on the prefix:
+//
+//	r.GET("/jobs", queue.ProgressHandler(), auth.RequireAuth())
+//
+// Subscribe to EventJobProgress to stream updates instead of polling.
+func ProgressHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-1] != "progress" {
+			response.NotFound(w)
+			return
+		}
+
+		job, ok := GetJob(parts[len(parts)-2])
+		if !ok {
+			response.NotFound(w, "Job not found")
+			return
+		}
+
+		response.Success(w, map[string]interface{}{
+			"id":         job.ID,
+			"type":       job.Type,
+			"status":     job.Status,
+			"progress":   job.Progress,
+			"message":    job.ProgressMessage,
+			"updated_at": job.UpdatedAt,
+		}, "Job progress")
+	}
+}
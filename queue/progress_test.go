@@ -0,0 +1,92 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"flugo.com/events"
+	"flugo.com/queue"
+)
+
+func TestJobProgress(t *testing.T) {
+	var mu sync.Mutex
+	var updates []queue.JobProgressEvent
+	unsubscribe := events.On(queue.EventJobProgress, func(ctx context.Context, e queue.JobProgressEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		updates = append(updates, e)
+		return nil
+	})
+	t.Cleanup(unsubscribe)
+
+	q := queue.NewQueue("progress-test", 1)
+	q.Start()
+	t.Cleanup(q.Stop)
+
+	step := make(chan struct{})
+	done := make(chan struct{})
+	q.RegisterHandler("export", func(job *queue.Job) error {
+		for i := 1; i <= 50; i++ {
+			job.SetProgress(i, "rows")
+		}
+		<-step
+		job.SetProgress(150, "written")
+		close(done)
+		return nil
+	})
+
+	id, err := q.PushJob("export", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The burst is coalesced: 1 is recorded at once, 50 once the interval
+	// has passed.
+	time.Sleep(queue.ProgressInterval + 100*time.Millisecond)
+	job, ok := queue.GetJob(id)
+	if !ok || job.Status != queue.StatusProcessing || job.Progress != 50 || job.ProgressMessage != "rows" {
+		t.Fatalf("GetJob(%s) = %+v, %v", id, job, ok)
+	}
+
+	close(step)
+	<-done
+	time.Sleep(20 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	queue.ProgressHandler()(rec, httptest.NewRequest("GET", "/jobs/"+id+"/progress", nil))
+	var body struct {
+		Data struct {
+			Status   string `json:"status"`
+			Progress int    `json:"progress"`
+			Message  string `json:"message"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.Status != "completed" || body.Data.Progress != 100 || body.Data.Message != "written" {
+		t.Errorf("progress response = %s", rec.Body.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got []int
+	for _, e := range updates {
+		if e.JobID == id {
+			got = append(got, e.Progress)
+		}
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 50 || got[2] != 100 {
+		t.Errorf("progress events = %v, want [1 50 100]", got)
+	}
+
+	rec = httptest.NewRecorder()
+	queue.ProgressHandler()(rec, httptest.NewRequest("GET", "/jobs/job_missing/progress", nil))
+	if rec.Code != 404 {
+		t.Errorf("unknown job answered %d", rec.Code)
+	}
+}
@@ -2,12 +2,20 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"flugo.com/cache"
+	"flugo.com/events"
+	"flugo.com/httpclient"
+	"flugo.com/imaging"
 	"flugo.com/logger"
+	"flugo.com/tasks"
+	"flugo.com/utils"
 )
 
 type Job struct {
@@ -20,6 +28,16 @@ type Job struct {
 	UpdatedAt time.Time              `json:"updated_at"`
 	Status    JobStatus              `json:"status"`
 	Error     string                 `json:"error,omitempty"`
+
+	// Progress and ProgressMessage are set through SetProgress.
+	Progress        int    `json:"progress"`
+	ProgressMessage string `json:"progress_message,omitempty"`
+	// Result is set by the handler to report the outcome of the job through
+	// GetJob, such as the delivery status of an email.
+	Result interface{} `json:"result,omitempty"`
+
+	ctx      context.Context
+	progress *progressState
 }
 
 type JobStatus string
@@ -43,6 +61,21 @@ type Queue struct {
 	ctx      context.Context
 	cancel   context.CancelFunc
 	stats    *QueueStats
+	metrics  metrics
+	retry    utils.RetryPolicy
+	inline   bool
+
+	liveWorkers  atomic.Int32
+	nextWorkerID atomic.Int32
+	// quits holds a channel per started worker; closing one stops that
+	// worker once its current job is done.
+	quits []chan struct{}
+	// running tracks worker goroutines so Stop can wait for them to exit.
+	running sync.WaitGroup
+
+	schedules        map[string]*Schedule
+	schedulerStarted bool
+	resumeCh         chan struct{}
 }
 
 type QueueStats struct {
@@ -54,23 +87,80 @@ type QueueStats struct {
 
 var DefaultQueue *Queue
 
+var webhookClient = httpclient.New(httpclient.Options{RetryPolicy: utils.RetryPolicy{MaxAttempts: 1}})
+
+// builtinHandlers are installed on every queue created by NewQueue.
+var builtinHandlers = make(map[string]JobHandler)
+
 func Init(workers int) {
 	DefaultQueue = NewQueue("default", workers)
 	DefaultQueue.Start()
+	events.SetAsyncDispatcher(dispatchEvent)
+}
+
+// InitInline installs a DefaultQueue without workers whose Push runs the job,
+// retries included, before returning. Tests use it to assert on side effects
+// of jobs and async event handlers right after a request.
+func InitInline() {
+	DefaultQueue = NewQueue("default", 0)
+	DefaultQueue.inline = true
+	DefaultQueue.retry = utils.RetryPolicy{}
+	events.SetAsyncDispatcher(dispatchEvent)
 }
 
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Queue)
+)
+
 func NewQueue(name string, workers int) *Queue {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Queue{
-		name:     name,
-		jobs:     make(chan *Job, 1000),
-		handlers: make(map[string]JobHandler),
-		workers:  workers,
-		ctx:      ctx,
-		cancel:   cancel,
-		stats:    &QueueStats{},
+	handlers := make(map[string]JobHandler, len(builtinHandlers))
+	for jobType, handler := range builtinHandlers {
+		handlers[jobType] = handler
 	}
+
+	q := &Queue{
+		name:      name,
+		jobs:      make(chan *Job, 1000),
+		handlers:  handlers,
+		workers:   workers,
+		ctx:       ctx,
+		cancel:    cancel,
+		stats:     &QueueStats{},
+		retry:     defaultRetryPolicy,
+		schedules: make(map[string]*Schedule),
+	}
+
+	registryMu.Lock()
+	registry[name] = q
+	registryMu.Unlock()
+
+	return q
+}
+
+// Get returns the queue created with the given name, or nil.
+func Get(name string) *Queue {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[name]
+}
+
+// defaultRetryPolicy spaces out job retries; a job's MaxRetry still bounds
+// the number of attempts.
+var defaultRetryPolicy = utils.RetryPolicy{
+	InitialDelay: time.Second,
+	MaxDelay:     time.Minute,
+	Multiplier:   2,
+	Jitter:       0.1,
+}
+
+// SetRetryPolicy changes the backoff used between job attempts.
+func (q *Queue) SetRetryPolicy(policy utils.RetryPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retry = policy
 }
 
 func (q *Queue) RegisterHandler(jobType string, handler JobHandler) {
@@ -79,31 +169,182 @@ func (q *Queue) RegisterHandler(jobType string, handler JobHandler) {
 	q.handlers[jobType] = handler
 }
 
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Handlers returns the registered job types, sorted.
+func (q *Queue) Handlers() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	jobTypes := make([]string, 0, len(q.handlers))
+	for jobType := range q.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+	sort.Strings(jobTypes)
+	return jobTypes
+}
+
 func (q *Queue) Start() {
-	for i := 0; i < q.workers; i++ {
-		go q.worker(i)
+	q.mu.Lock()
+	workers := q.workers
+	for i := 0; i < workers; i++ {
+		q.startWorker()
+	}
+	q.mu.Unlock()
+
+	logger.Info("Queue '%s' started with %d workers", q.name, workers)
+}
+
+// startWorker must be called with q.mu held.
+func (q *Queue) startWorker() {
+	id := int(q.nextWorkerID.Add(1)) - 1
+	quit := make(chan struct{})
+	q.quits = append(q.quits, quit)
+	q.liveWorkers.Add(1)
+	q.running.Add(1)
+	go q.worker(id, quit)
+}
+
+// SetWorkerCount grows or shrinks the pool to n workers at runtime. Extra
+// workers finish their current job and exit; queued jobs stay queued.
+func (q *Queue) SetWorkerCount(n int) error {
+	if n < 1 {
+		return fmt.Errorf("worker count must be at least 1")
+	}
+	if q.ctx.Err() != nil {
+		return fmt.Errorf("queue '%s' is stopped", q.name)
+	}
+
+	q.mu.Lock()
+	delta := n - q.workers
+	q.workers = n
+	for i := 0; i < delta; i++ {
+		q.startWorker()
+	}
+	for i := 0; i < -delta && len(q.quits) > 0; i++ {
+		last := len(q.quits) - 1
+		close(q.quits[last])
+		q.quits = q.quits[:last]
+	}
+	q.mu.Unlock()
+
+	if delta != 0 {
+		logger.Info("Queue '%s' resized to %d workers", q.name, n)
 	}
-	logger.Info("Queue '%s' started with %d workers", q.name, q.workers)
+	return nil
+}
+
+// WorkerCount returns the number of running workers, which may briefly lag
+// behind the last SetWorkerCount while workers drain.
+func (q *Queue) WorkerCount() int {
+	return int(q.liveWorkers.Load())
 }
 
+// Stop cancels the queue and waits for its workers to finish their current
+// job and exit.
 func (q *Queue) Stop() {
+	if q == DefaultQueue {
+		events.SetAsyncDispatcher(nil)
+	}
 	q.cancel()
 	close(q.jobs)
+	q.running.Wait()
 	logger.Info("Queue '%s' stopped", q.name)
 }
 
-func (q *Queue) worker(id int) {
+// Drain waits until every queued and running job has finished, or until ctx
+// expires. Jobs pushed while draining are waited for as well.
+func (q *Queue) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if q.Size() == 0 && q.GetStats().Active == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("queue '%s' not drained: %d queued, %d active: %w",
+				q.name, q.Size(), q.GetStats().Active, ctx.Err())
+		}
+	}
+}
+
+// Pause stops workers from picking up new jobs and suppresses scheduled
+// runs until Resume is called. Queued jobs and schedules are kept.
+func (q *Queue) Pause() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.resumeCh == nil {
+		q.resumeCh = make(chan struct{})
+		logger.Info("Queue '%s' paused", q.name)
+	}
+}
+
+func (q *Queue) Resume() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.resumeCh != nil {
+		close(q.resumeCh)
+		q.resumeCh = nil
+		logger.Info("Queue '%s' resumed", q.name)
+	}
+}
+
+func (q *Queue) IsPaused() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.resumeCh != nil
+}
+
+func (q *Queue) waitIfPaused(quit <-chan struct{}) bool {
+	q.mu.RLock()
+	resumeCh := q.resumeCh
+	q.mu.RUnlock()
+
+	if resumeCh == nil {
+		return true
+	}
+
+	select {
+	case <-resumeCh:
+		return true
+	case <-quit:
+		return false
+	case <-q.ctx.Done():
+		return false
+	}
+}
+
+func (q *Queue) worker(id int, quit <-chan struct{}) {
+	defer q.running.Done()
+	defer q.liveWorkers.Add(-1)
 	logger.Debug("Worker %d started", id)
 
 	for {
+		if !q.waitIfPaused(quit) {
+			logger.Debug("Worker %d stopped", id)
+			return
+		}
+
 		select {
-		case job := <-q.jobs:
-			if job == nil {
+		case job, ok := <-q.jobs:
+			if !ok {
 				logger.Debug("Worker %d stopped", id)
 				return
 			}
 			q.processJob(job, id)
 
+		case <-quit:
+			logger.Debug("Worker %d stopped", id)
+			return
+
 		case <-q.ctx.Done():
 			logger.Debug("Worker %d stopped due to context cancellation", id)
 			return
@@ -121,12 +362,14 @@ func (q *Queue) processJob(job *Job, workerID int) {
 		q.stats.Active--
 		q.mu.Unlock()
 	}()
+	q.metrics.dequeued(job)
 
 	logger.Debug("Worker %d processing job %s (type: %s)", workerID, job.ID, job.Type)
 
 	job.Status = StatusProcessing
 	job.UpdatedAt = time.Now()
 	job.Attempts++
+	q.track(job)
 
 	q.mu.RLock()
 	handler, exists := q.handlers[job.Type]
@@ -136,54 +379,114 @@ func (q *Queue) processJob(job *Job, workerID int) {
 		job.Status = StatusFailed
 		job.Error = fmt.Sprintf("no handler registered for job type: %s", job.Type)
 		logger.Error("No handler for job type %s", job.Type)
+		q.track(job)
 		q.mu.Lock()
 		q.stats.Failed++
 		q.mu.Unlock()
+		q.metrics.finished(job, true)
+		q.emitFailed(job)
 		return
 	}
 
+	span := q.startSpan(job)
+	started := time.Now()
 	err := handler(job)
+	q.metrics.ran(job, time.Since(started), err)
+	span.RecordError(err)
+	span.End()
 	if err != nil {
 		job.Error = err.Error()
 
-		if job.Attempts < job.MaxRetry {
+		var permanent *permanentError
+		if job.Attempts < job.MaxRetry && !errors.As(err, &permanent) {
 			job.Status = StatusRetrying
+			q.track(job)
 			logger.Warn("Job %s failed, retrying (%d/%d): %v", job.ID, job.Attempts, job.MaxRetry, err)
 
-			// Retry with exponential backoff
-			delay := time.Duration(job.Attempts*job.Attempts) * time.Second
-			time.Sleep(delay)
+			q.mu.RLock()
+			policy := q.retry
+			q.mu.RUnlock()
+
+			delay := policy.Delay(job.Attempts)
+			if policy.OnRetry != nil {
+				policy.OnRetry(job.Attempts, err, delay)
+			}
 
+			select {
+			case <-time.After(delay):
+			case <-q.ctx.Done():
+				job.Status = StatusFailed
+				q.track(job)
+				return
+			}
+
+			q.metrics.enqueued(job)
 			select {
 			case q.jobs <- job:
 				q.mu.Lock()
 				q.stats.Retried++
 				q.mu.Unlock()
 			default:
+				q.metrics.dequeued(job)
 				logger.Error("Failed to requeue job %s: queue is full", job.ID)
 				job.Status = StatusFailed
+				q.track(job)
 				q.mu.Lock()
 				q.stats.Failed++
 				q.mu.Unlock()
+				q.metrics.finished(job, true)
+				q.emitFailed(job)
 			}
 		} else {
 			job.Status = StatusFailed
 			logger.Error("Job %s failed permanently after %d attempts: %v", job.ID, job.Attempts, err)
+			q.track(job)
 			q.mu.Lock()
 			q.stats.Failed++
 			q.mu.Unlock()
+			q.metrics.finished(job, true)
+			q.emitFailed(job)
 		}
 	} else {
 		job.Status = StatusCompleted
 		job.UpdatedAt = time.Now()
 		logger.Info("Job %s completed successfully", job.ID)
+		q.track(job)
 		q.mu.Lock()
 		q.stats.Processed++
 		q.mu.Unlock()
+		q.metrics.finished(job, false)
+	}
+}
+
+// Permanent marks a handler error as final: the job fails without using its
+// remaining retries.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
 	}
+	return &permanentError{err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
 }
 
 func (q *Queue) Push(jobType string, payload map[string]interface{}, maxRetry int) error {
+	_, err := q.PushJob(jobType, payload, maxRetry)
+	return err
+}
+
+// PushJob pushes a job like Push and returns its ID, for GetJob.
+func (q *Queue) PushJob(jobType string, payload map[string]interface{}, maxRetry int) (string, error) {
 	job := &Job{
 		ID:        generateJobID(),
 		Type:      jobType,
@@ -194,20 +497,54 @@ func (q *Queue) Push(jobType string, payload map[string]interface{}, maxRetry in
 		UpdatedAt: time.Now(),
 	}
 
+	q.track(job)
+
+	if q.inline {
+		q.runInline(job)
+		return job.ID, nil
+	}
+
+	q.metrics.enqueued(job)
 	select {
 	case q.jobs <- job:
 		logger.Debug("Job %s queued (type: %s)", job.ID, job.Type)
-		return nil
+		return job.ID, nil
 	default:
-		return fmt.Errorf("queue is full")
+		q.metrics.dequeued(job)
+		jobIndexMu.Lock()
+		delete(jobIndex, job.ID)
+		jobIndexMu.Unlock()
+		return "", fmt.Errorf("queue is full")
+	}
+}
+
+// runInline processes job on the caller's goroutine, then the retries it
+// queued.
+func (q *Queue) runInline(job *Job) {
+	q.processJob(job, 0)
+	for {
+		select {
+		case next := <-q.jobs:
+			q.processJob(next, 0)
+		default:
+			return
+		}
 	}
 }
 
 func (q *Queue) PushDelay(jobType string, payload map[string]interface{}, maxRetry int, delay time.Duration) error {
-	go func() {
-		time.Sleep(delay)
-		q.Push(jobType, payload, maxRetry)
-	}()
+	tasks.Go("queue.delay:"+jobType, func(ctx context.Context) error {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return q.Push(jobType, payload, maxRetry)
+		case <-ctx.Done():
+			logger.Warn("Delayed %s job dropped at shutdown", jobType)
+			return nil
+		}
+	})
 
 	logger.Debug("Delayed job %s scheduled (type: %s, delay: %v)", generateJobID(), jobType, delay)
 	return nil
@@ -230,7 +567,7 @@ func (q *Queue) Size() int {
 }
 
 func generateJobID() string {
-	return fmt.Sprintf("job_%d", time.Now().UnixNano())
+	return "job_" + utils.NewID()
 }
 
 // Helper functions
@@ -251,6 +588,13 @@ func PushWithRetry(jobType string, payload map[string]interface{}, maxRetry int)
 	return DefaultQueue.Push(jobType, payload, maxRetry)
 }
 
+func PushJob(jobType string, payload map[string]interface{}) (string, error) {
+	if DefaultQueue == nil {
+		return "", fmt.Errorf("queue not initialized")
+	}
+	return DefaultQueue.PushJob(jobType, payload, 3)
+}
+
 func PushDelay(jobType string, payload map[string]interface{}, delay time.Duration) error {
 	if DefaultQueue == nil {
 		return fmt.Errorf("queue not initialized")
@@ -258,6 +602,25 @@ func PushDelay(jobType string, payload map[string]interface{}, delay time.Durati
 	return DefaultQueue.PushDelay(jobType, payload, 3, delay)
 }
 
+func Pause() {
+	if DefaultQueue != nil {
+		DefaultQueue.Pause()
+	}
+}
+
+func Resume() {
+	if DefaultQueue != nil {
+		DefaultQueue.Resume()
+	}
+}
+
+func Drain(ctx context.Context) error {
+	if DefaultQueue == nil {
+		return nil
+	}
+	return DefaultQueue.Drain(ctx)
+}
+
 func GetStats() *QueueStats {
 	if DefaultQueue == nil {
 		return &QueueStats{}
@@ -267,22 +630,7 @@ func GetStats() *QueueStats {
 
 // Built-in job handlers
 func init() {
-	RegisterHandler("send_email", func(job *Job) error {
-		to, _ := job.Payload["to"].(string)
-		subject, _ := job.Payload["subject"].(string)
-		_, _ = job.Payload["body"].(string)
-
-		if to == "" || subject == "" {
-			return fmt.Errorf("missing required email parameters")
-		}
-
-		logger.Info("Sending email to %s: %s", to, subject)
-		time.Sleep(100 * time.Millisecond) // Simulate email sending
-
-		return nil
-	})
-
-	RegisterHandler("image_process", func(job *Job) error {
+	builtinHandlers["image_process"] = func(job *Job) error {
 		imagePath, _ := job.Payload["image_path"].(string)
 		operation, _ := job.Payload["operation"].(string)
 
@@ -294,19 +642,9 @@ func init() {
 		time.Sleep(500 * time.Millisecond) // Simulate image processing
 
 		return nil
-	})
-
-	RegisterHandler("data_export", func(job *Job) error {
-		format, _ := job.Payload["format"].(string)
-		userID, _ := job.Payload["user_id"].(float64)
-
-		logger.Info("Exporting data for user %d in format %s", int(userID), format)
-		time.Sleep(2 * time.Second) // Simulate data export
-
-		return nil
-	})
+	}
 
-	RegisterHandler("webhook_call", func(job *Job) error {
+	builtinHandlers["webhook_call"] = func(job *Job) error {
 		url, _ := job.Payload["url"].(string)
 		data, _ := job.Payload["data"].(map[string]interface{})
 
@@ -314,14 +652,15 @@ func init() {
 			return fmt.Errorf("webhook URL is required")
 		}
 
-		dataBytes, _ := json.Marshal(data)
-		logger.Info("Calling webhook %s with data: %s", url, string(dataBytes))
-		time.Sleep(200 * time.Millisecond) // Simulate webhook call
-
+		// The queue retries failed jobs, so the client does not.
+		if err := webhookClient.PostJSON(job.Context(), url, data, nil); err != nil {
+			return fmt.Errorf("webhook call failed: %w", err)
+		}
+		logger.Info("Called webhook %s", url)
 		return nil
-	})
+	}
 
-	RegisterHandler("notification", func(job *Job) error {
+	builtinHandlers["notification"] = func(job *Job) error {
 		userID, _ := job.Payload["user_id"].(float64)
 		message, _ := job.Payload["message"].(string)
 		channel, _ := job.Payload["channel"].(string)
@@ -330,15 +669,45 @@ func init() {
 		time.Sleep(100 * time.Millisecond) // Simulate notification sending
 
 		return nil
-	})
+	}
+
+	builtinHandlers["generate_thumbnail"] = func(job *Job) error {
+		filePath, _ := job.Payload["file_path"].(string)
+		thumbPath, _ := job.Payload["thumb_path"].(string)
+		statusKey, _ := job.Payload["status_key"].(string)
+		maxSize := payloadInt(job.Payload["max_size"])
+
+		if filePath == "" || thumbPath == "" {
+			return fmt.Errorf("file_path and thumb_path are required")
+		}
+
+		if err := imaging.Thumbnail(filePath, thumbPath, maxSize); err != nil {
+			if statusKey != "" && job.Attempts >= job.MaxRetry {
+				cache.Set(statusKey, "failed", -1)
+			}
+			return err
+		}
+
+		if statusKey != "" {
+			cache.Set(statusKey, "ready", -1)
+		}
+		logger.Info("Thumbnail created: %s -> %s", filePath, thumbPath)
+
+		return nil
+	}
 }
 
-func SendEmailAsync(to, subject, body string) error {
-	return Push("send_email", map[string]interface{}{
-		"to":      to,
-		"subject": subject,
-		"body":    body,
-	})
+func payloadInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
 }
 
 func ProcessImageAsync(imagePath, operation string) error {
@@ -348,13 +717,6 @@ func ProcessImageAsync(imagePath, operation string) error {
 	})
 }
 
-func ExportDataAsync(userID int, format string) error {
-	return Push("data_export", map[string]interface{}{
-		"user_id": userID,
-		"format":  format,
-	})
-}
-
 func CallWebhookAsync(url string, data map[string]interface{}) error {
 	return Push("webhook_call", map[string]interface{}{
 		"url":  url,
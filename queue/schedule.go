@@ -0,0 +1,299 @@
+package queue
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/logger"
+)
+
+// Schedule is a named recurring job registered with Queue.Schedule.
+type Schedule struct {
+	Name     string                 `json:"name"`
+	CronExpr string                 `json:"cron_expr"`
+	JobType  string                 `json:"job_type"`
+	Payload  map[string]interface{} `json:"payload"`
+	NextRun  time.Time              `json:"next_run"`
+	LastRun  time.Time              `json:"last_run,omitempty"`
+
+	expr *cronExpr
+}
+
+// Schedule registers a recurring job under a unique name. Scheduling a name
+// that already exists updates that schedule instead of adding a second one.
+func (q *Queue) Schedule(name, cronExpr, jobType string, payload map[string]interface{}) (*Schedule, error) {
+	if name == "" {
+		return nil, fmt.Errorf("schedule name is required")
+	}
+
+	expr, err := parseCron(cronExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	schedule, exists := q.schedules[name]
+	if !exists {
+		schedule = &Schedule{Name: name}
+		q.schedules[name] = schedule
+	}
+
+	schedule.CronExpr = cronExpr
+	schedule.JobType = jobType
+	schedule.Payload = payload
+	schedule.expr = expr
+	schedule.NextRun = expr.next(time.Now())
+	if schedule.NextRun.IsZero() {
+		if !exists {
+			delete(q.schedules, name)
+		}
+		return nil, fmt.Errorf("cron expression %q never matches", cronExpr)
+	}
+
+	if exists {
+		logger.Info("Schedule '%s' updated (%s, next run %s)", name, cronExpr, schedule.NextRun.Format(time.RFC3339))
+	} else {
+		logger.Info("Schedule '%s' registered (%s, next run %s)", name, cronExpr, schedule.NextRun.Format(time.RFC3339))
+	}
+
+	if !q.schedulerStarted {
+		q.schedulerStarted = true
+		go q.runScheduler()
+	}
+
+	copied := *schedule
+	return &copied, nil
+}
+
+func (q *Queue) Schedules() map[string]*Schedule {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	result := make(map[string]*Schedule, len(q.schedules))
+	for name, schedule := range q.schedules {
+		copied := *schedule
+		result[name] = &copied
+	}
+	return result
+}
+
+func (q *Queue) CancelSchedule(name string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.schedules[name]; !exists {
+		return false
+	}
+	delete(q.schedules, name)
+	logger.Info("Schedule '%s' cancelled", name)
+	return true
+}
+
+func (q *Queue) runScheduler() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			q.fireDueSchedules(now)
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *Queue) fireDueSchedules(now time.Time) {
+	q.mu.Lock()
+	paused := q.resumeCh != nil
+	var due []Schedule
+	for _, schedule := range q.schedules {
+		if now.Before(schedule.NextRun) {
+			continue
+		}
+		if !paused {
+			schedule.LastRun = now
+			due = append(due, *schedule)
+		}
+		schedule.NextRun = schedule.expr.next(now)
+	}
+	q.mu.Unlock()
+
+	for _, schedule := range due {
+		if err := q.Push(schedule.JobType, schedule.Payload, 3); err != nil {
+			logger.Error("Failed to enqueue scheduled job '%s': %v", schedule.Name, err)
+		}
+	}
+}
+
+// cronExpr is a parsed standard five-field cron expression:
+// minute hour day-of-month month day-of-week.
+type cronExpr struct {
+	minute     uint64
+	hour       uint64
+	dom        uint64
+	month      uint64
+	dow        uint64
+	domStarred bool
+	dowStarred bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func parseCron(expr string) (*cronExpr, error) {
+	spec := strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", expr)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var bits [5]uint64
+	for i, field := range fields {
+		b, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+
+	// Both 0 and 7 mean Sunday.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+		bits[4] &^= 1 << 7
+	}
+
+	return &cronExpr{
+		minute:     bits[0],
+		hour:       bits[1],
+		dom:        bits[2],
+		month:      bits[3],
+		dow:        bits[4],
+		domStarred: strings.HasPrefix(fields[2], "*"),
+		dowStarred: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:idx]
+		}
+
+		low, high := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			low = n
+			if step == 1 {
+				high = n
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("value out of range in %q (allowed %d-%d)", part, min, max)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (c *cronExpr) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStarred || c.dowStarred {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next returns the first matching minute strictly after t.
+func (c *cronExpr) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// ScheduleJob registers a recurring job on the default queue; see
+// Queue.Schedule.
+func ScheduleJob(name, cronExpr, jobType string, payload map[string]interface{}) (*Schedule, error) {
+	if DefaultQueue == nil {
+		return nil, fmt.Errorf("queue not initialized")
+	}
+	return DefaultQueue.Schedule(name, cronExpr, jobType, payload)
+}
+
+func Schedules() map[string]*Schedule {
+	if DefaultQueue == nil {
+		return map[string]*Schedule{}
+	}
+	return DefaultQueue.Schedules()
+}
+
+func CancelSchedule(name string) bool {
+	if DefaultQueue == nil {
+		return false
+	}
+	return DefaultQueue.CancelSchedule(name)
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"flugo.com/trace"
+)
+
+// TraceparentKey is the payload entry carrying the trace of the request
+// that pushed a job, so its processing shows up in the same trace.
+const TraceparentKey = "_traceparent"
+
+// PushContext pushes a job like Push and, when ctx carries a trace, records
+// the push as a span and stores its traceparent in a copy of the payload.
+func (q *Queue) PushContext(ctx context.Context, jobType string, payload map[string]interface{}, maxRetry int) error {
+	_, span := trace.Child(ctx, "queue.push "+jobType, trace.WithKind(trace.KindProducer))
+	if span == nil {
+		return q.Push(jobType, payload, maxRetry)
+	}
+	defer span.End()
+	span.SetAttribute("queue.name", q.name)
+	span.SetAttribute("job.type", jobType)
+
+	traced := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		traced[k] = v
+	}
+	traced[TraceparentKey] = span.Context().Traceparent()
+
+	err := q.Push(jobType, traced, maxRetry)
+	span.RecordError(err)
+	return err
+}
+
+func PushContext(ctx context.Context, jobType string, payload map[string]interface{}) error {
+	q := FromContext(ctx)
+	if q == nil {
+		return fmt.Errorf("queue not initialized")
+	}
+	return q.PushContext(ctx, jobType, payload, 3)
+}
+
+// Context returns the context of the attempt being processed. It carries
+// the job's span when the job was pushed with a trace, so handlers can pass
+// it on to queries and outbound calls.
+func (j *Job) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+// startSpan starts the span of one processing attempt, linked to the trace
+// stored in the payload.
+func (q *Queue) startSpan(job *Job) *trace.Span {
+	job.ctx = nil
+	traceparent, _ := job.Payload[TraceparentKey].(string)
+	sc, err := trace.ParseTraceparent(traceparent)
+	if err != nil {
+		return nil
+	}
+
+	ctx, span := trace.Start(trace.ContextWithRemote(context.Background(), sc), "queue.process "+job.Type, trace.WithKind(trace.KindConsumer))
+	span.SetAttribute("queue.name", q.name)
+	span.SetAttribute("job.id", job.ID)
+	span.SetAttribute("job.type", job.Type)
+	span.SetAttribute("job.attempt", job.Attempts)
+	job.ctx = ctx
+	return span
+}
@@ -0,0 +1,65 @@
+package queue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"flugo.com/queue"
+)
+
+func TestSetWorkerCountShrinksWithoutQueueingStopSignals(t *testing.T) {
+	q := queue.NewQueue("workers-shrink", 4)
+	q.RegisterHandler("noop", func(job *queue.Job) error { return nil })
+	q.Start()
+
+	if err := q.SetWorkerCount(1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return q.WorkerCount() == 1 })
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size = %d after shrinking, want 0", size)
+	}
+
+	q.Push("noop", nil, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shrinking right before Stop must not send on the closed job channel.
+	if err := q.SetWorkerCount(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SetWorkerCount(1); err != nil {
+		t.Fatal(err)
+	}
+	q.Stop()
+	waitFor(t, func() bool { return q.WorkerCount() == 0 })
+}
+
+func TestSetWorkerCountStopsPausedWorkers(t *testing.T) {
+	q := queue.NewQueue("workers-paused", 3)
+	q.Start()
+	t.Cleanup(q.Stop)
+
+	q.Pause()
+	if err := q.SetWorkerCount(1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return q.WorkerCount() == 1 })
+}
+
+func TestStopWaitsForWorkers(t *testing.T) {
+	q := queue.NewQueue("workers-stop", 1)
+	q.Start()
+	if err := q.SetWorkerCount(3); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Stop()
+	if n := q.WorkerCount(); n != 0 {
+		t.Errorf("WorkerCount = %d after Stop, want 0", n)
+	}
+}
@@ -1,76 +1,172 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"flugo.com/auth"
+	"flugo.com/reqctx"
 	"flugo.com/response"
 	"flugo.com/router"
+	"flugo.com/tasks"
 )
 
 type Limiter struct {
-	requests map[string][]time.Time
-	mu       sync.RWMutex
-	max      int
-	window   time.Duration
+	requests  map[string][]time.Time
+	overrides map[string]Override
+	mu        sync.RWMutex
+	max       int
+	window    time.Duration
 }
 
+// HeaderMode picks the rate limit headers set on responses.
+type HeaderMode int
+
+const (
+	// HeadersBoth sets the legacy X-RateLimit-* headers and the IETF draft
+	// RateLimit-* headers.
+	HeadersBoth HeaderMode = iota
+	HeadersStandard
+	HeadersLegacy
+	HeadersNone
+)
+
 type Config struct {
 	Requests int
 	Window   time.Duration
 	KeyFunc  func(*http.Request) string
+	// DryRun evaluates the limit and sets the headers but lets denied
+	// requests through, logging them instead, to observe a new limit
+	// before enforcing it.
+	DryRun  bool
+	Headers HeaderMode
+	// Limiter is shared instead of a new one, for example to set per-key
+	// limits on it.
+	Limiter *Limiter
+}
+
+// Override replaces a key's limit. A zero Window keeps the limiter's own.
+type Override struct {
+	Limit  int           `json:"limit"`
+	Window time.Duration `json:"window"`
+}
+
+// Result is the outcome of one check. Reset is the time until the oldest
+// counted request leaves the window.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
 }
 
 var DefaultLimiter *Limiter
 
+var (
+	overridesMu sync.RWMutex
+	overrides   = make(map[string]Override)
+)
+
 func Init(max int, window time.Duration) {
 	DefaultLimiter = NewLimiter(max, window)
 
-	go func() {
+	limiter := DefaultLimiter
+	tasks.Go("ratelimit.cleanup", func(ctx context.Context) error {
 		ticker := time.NewTicker(time.Minute)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			DefaultLimiter.cleanup()
+		for {
+			select {
+			case <-ticker.C:
+				limiter.cleanup()
+			case <-ctx.Done():
+				return nil
+			}
 		}
-	}()
+	})
 }
 
 func NewLimiter(max int, window time.Duration) *Limiter {
 	return &Limiter{
-		requests: make(map[string][]time.Time),
-		max:      max,
-		window:   window,
+		requests:  make(map[string][]time.Time),
+		overrides: make(map[string]Override),
+		max:       max,
+		window:    window,
+	}
+}
+
+// SetLimitFor gives key its own limit on this limiter, taking precedence
+// over the package-level SetLimitFor.
+func (l *Limiter) SetLimitFor(key string, limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides[key] = Override{Limit: max(limit, 0), Window: window}
+}
+
+func (l *Limiter) ClearLimitFor(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.overrides, key)
+}
+
+// limitFor returns the limit and window for key. Callers hold l.mu.
+func (l *Limiter) limitFor(key string) (int, time.Duration) {
+	o, ok := l.overrides[key]
+	if !ok {
+		overridesMu.RLock()
+		o, ok = overrides[key]
+		overridesMu.RUnlock()
+	}
+	if !ok {
+		return l.max, l.window
+	}
+	if o.Window <= 0 {
+		o.Window = l.window
 	}
+	return o.Limit, o.Window
 }
 
 func (l *Limiter) Allow(key string) bool {
+	return l.Take(key).Allowed
+}
+
+// Take counts a request for key when it is within the limit and reports
+// the outcome.
+func (l *Limiter) Take(key string) Result {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	limit, window := l.limitFor(key)
 	now := time.Now()
-	cutoff := now.Add(-l.window)
+	valid := validRequests(l.requests[key], now.Add(-window))
 
-	requests := l.requests[key]
+	res := Result{Limit: limit, Allowed: len(valid) < limit}
+	if res.Allowed {
+		valid = append(valid, now)
+	}
+	l.requests[key] = valid
 
-	validRequests := make([]time.Time, 0)
+	res.Remaining = max(limit-len(valid), 0)
+	res.Reset = window
+	if len(valid) > 0 {
+		res.Reset = valid[0].Add(window).Sub(now)
+	}
+	return res
+}
+
+func validRequests(requests []time.Time, cutoff time.Time) []time.Time {
+	valid := make([]time.Time, 0, len(requests))
 	for _, reqTime := range requests {
 		if reqTime.After(cutoff) {
-			validRequests = append(validRequests, reqTime)
+			valid = append(valid, reqTime)
 		}
 	}
-
-	if len(validRequests) >= l.max {
-		l.requests[key] = validRequests
-		return false
-	}
-
-	validRequests = append(validRequests, now)
-	l.requests[key] = validRequests
-
-	return true
+	return valid
 }
 
 func (l *Limiter) cleanup() {
@@ -78,20 +174,13 @@ func (l *Limiter) cleanup() {
 	defer l.mu.Unlock()
 
 	now := time.Now()
-	cutoff := now.Add(-l.window)
-
 	for key, requests := range l.requests {
-		validRequests := make([]time.Time, 0)
-		for _, reqTime := range requests {
-			if reqTime.After(cutoff) {
-				validRequests = append(validRequests, reqTime)
-			}
-		}
-
-		if len(validRequests) == 0 {
+		_, window := l.limitFor(key)
+		valid := validRequests(requests, now.Add(-window))
+		if len(valid) == 0 {
 			delete(l.requests, key)
 		} else {
-			l.requests[key] = validRequests
+			l.requests[key] = valid
 		}
 	}
 }
@@ -106,26 +195,9 @@ func (l *Limiter) Remaining(key string) int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
-	requests := l.requests[key]
-	if requests == nil {
-		return l.max
-	}
-
-	now := time.Now()
-	cutoff := now.Add(-l.window)
-
-	validCount := 0
-	for _, reqTime := range requests {
-		if reqTime.After(cutoff) {
-			validCount++
-		}
-	}
-
-	remaining := l.max - validCount
-	if remaining < 0 {
-		return 0
-	}
-	return remaining
+	limit, window := l.limitFor(key)
+	valid := validRequests(l.requests[key], time.Now().Add(-window))
+	return max(limit-len(valid), 0)
 }
 
 func getClientIP(r *http.Request) string {
@@ -146,16 +218,19 @@ func Limit(requests int, window time.Duration) router.MiddlewareFunc {
 	})
 }
 
+// LimitByUser keys on the authenticated user, so it belongs after
+// auth.RequireAuth or auth.OptionalAuth; anonymous requests are keyed by
+// client IP.
 func LimitByUser(requests int, window time.Duration) router.MiddlewareFunc {
 	return LimitWithConfig(Config{
 		Requests: requests,
 		Window:   window,
 		KeyFunc: func(r *http.Request) string {
-			userID := r.Header.Get("X-Current-User")
-			if userID == "" {
+			userID := auth.GetCurrentUserID(r)
+			if userID == 0 {
 				return getClientIP(r)
 			}
-			return "user:" + userID
+			return "user:" + strconv.Itoa(userID)
 		},
 	})
 }
@@ -171,37 +246,53 @@ func LimitByEndpoint(requests int, window time.Duration) router.MiddlewareFunc {
 }
 
 func LimitWithConfig(config Config) router.MiddlewareFunc {
-	limiter := NewLimiter(config.Requests, config.Window)
+	limiter := config.Limiter
+	if limiter == nil {
+		limiter = NewLimiter(config.Requests, config.Window)
+	}
 
 	return func(next router.HandlerFunc) router.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			key := config.KeyFunc(r)
-
-			if !limiter.Allow(key) {
-				remaining := limiter.Remaining(key)
-				resetTime := time.Now().Add(config.Window).Unix()
-
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", config.Requests))
-				w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-				w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetTime))
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(config.Window.Seconds())))
-
+			res := limiter.Take(key)
+			setHeaders(w.Header(), config.Headers, res)
+
+			switch {
+			case res.Allowed:
+				allowed.Add(1)
+			case config.DryRun:
+				wouldDeny.Add(1)
+				reqctx.Logger(r).Warn("Rate limit dry run: would deny %s %s for %s (limit %d)", r.Method, r.URL.Path, key, res.Limit)
+			default:
+				denied.Add(1)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.Reset)))
 				response.TooManyRequests(w, "Rate limit exceeded")
 				return
 			}
 
-			remaining := limiter.Remaining(key)
-			resetTime := time.Now().Add(config.Window).Unix()
-
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", config.Requests))
-			w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetTime))
-
 			next(w, r)
 		}
 	}
 }
 
+func setHeaders(h http.Header, mode HeaderMode, res Result) {
+	if mode == HeadersBoth || mode == HeadersLegacy {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(res.Reset).Unix(), 10))
+	}
+	// The draft headers give the reset as seconds from now.
+	if mode == HeadersBoth || mode == HeadersStandard {
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
 func GlobalLimit(requests int, window time.Duration) router.MiddlewareFunc {
 	return Limit(requests, window)
 }
@@ -225,3 +316,51 @@ func Remaining(key string) int {
 	}
 	return DefaultLimiter.Remaining(key)
 }
+
+// SetLimitFor overrides key's limit in every limiter, those created by the
+// middlewares included, until ClearLimitFor. Keys are what the limiter's
+// KeyFunc returns, such as "user:42" for LimitByUser. A zero window keeps
+// each limiter's own.
+func SetLimitFor(key string, limit int, window time.Duration) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	overrides[key] = Override{Limit: max(limit, 0), Window: window}
+}
+
+func ClearLimitFor(key string) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	delete(overrides, key)
+}
+
+// LimitOverrides returns the keys given their own limit with SetLimitFor.
+func LimitOverrides() map[string]Override {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+
+	snapshot := make(map[string]Override, len(overrides))
+	for key, o := range overrides {
+		snapshot[key] = o
+	}
+	return snapshot
+}
+
+// Counters are totals of the middleware's decisions. WouldDeny counts the
+// requests a DryRun limit let through.
+type Counters struct {
+	Allowed   int64 `json:"allowed"`
+	Denied    int64 `json:"denied"`
+	WouldDeny int64 `json:"would_deny"`
+}
+
+var allowed, denied, wouldDeny atomic.Int64
+
+func Metrics() Counters {
+	return Counters{Allowed: allowed.Load(), Denied: denied.Load(), WouldDeny: wouldDeny.Load()}
+}
+
+func ResetMetrics() {
+	allowed.Store(0)
+	denied.Store(0)
+	wouldDeny.Store(0)
+}
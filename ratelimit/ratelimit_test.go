@@ -0,0 +1,107 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flugo.com/ratelimit"
+	"flugo.com/router"
+)
+
+func serve(handler router.HandlerFunc, addr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/reports", nil)
+	req.RemoteAddr = addr
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func byAddr(r *http.Request) string {
+	return r.RemoteAddr
+}
+
+func TestHeaders(t *testing.T) {
+	handler := ratelimit.Limit(2, time.Minute)(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := serve(handler, "10.0.0.1")
+	h := rec.Header()
+	if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != "1" || h.Get("RateLimit-Reset") != "60" {
+		t.Errorf("standard headers = %v", h)
+	}
+	if h.Get("X-RateLimit-Limit") != "2" || h.Get("X-RateLimit-Remaining") != "1" || h.Get("X-RateLimit-Reset") == "" {
+		t.Errorf("legacy headers = %v", h)
+	}
+
+	serve(handler, "10.0.0.1")
+	rec = serve(handler, "10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("third request = %d %v", rec.Code, rec.Header())
+	}
+
+	standard := ratelimit.LimitWithConfig(ratelimit.Config{
+		Requests: 2, Window: time.Minute, KeyFunc: byAddr, Headers: ratelimit.HeadersStandard,
+	})(func(w http.ResponseWriter, r *http.Request) {})
+	if h := serve(standard, "10.0.0.1").Header(); h.Get("X-RateLimit-Limit") != "" || h.Get("RateLimit-Limit") != "2" {
+		t.Errorf("HeadersStandard set %v", h)
+	}
+}
+
+func TestDryRunNeverBlocks(t *testing.T) {
+	ratelimit.ResetMetrics()
+	served := 0
+	handler := ratelimit.LimitWithConfig(ratelimit.Config{
+		Requests: 1, Window: time.Minute, KeyFunc: byAddr, DryRun: true,
+	})(func(w http.ResponseWriter, r *http.Request) { served++ })
+
+	for i := 0; i < 3; i++ {
+		rec := serve(handler, "10.0.0.1")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("dry run answered %d", rec.Code)
+		}
+		if i > 0 && rec.Header().Get("RateLimit-Remaining") != "0" {
+			t.Errorf("remaining = %s, want 0 once over the limit", rec.Header().Get("RateLimit-Remaining"))
+		}
+	}
+	if m := ratelimit.Metrics(); served != 3 || m.Allowed != 1 || m.WouldDeny != 2 || m.Denied != 0 {
+		t.Errorf("served %d, metrics %+v", served, m)
+	}
+}
+
+func TestSetLimitFor(t *testing.T) {
+	limiter := ratelimit.NewLimiter(1, time.Minute)
+	handler := ratelimit.LimitWithConfig(ratelimit.Config{
+		Requests: 1, Window: time.Minute, KeyFunc: byAddr,
+	})(func(w http.ResponseWriter, r *http.Request) {})
+
+	ratelimit.SetLimitFor("10.0.0.9", 3, 0)
+	t.Cleanup(func() { ratelimit.ClearLimitFor("10.0.0.9") })
+
+	for i := 0; i < 3; i++ {
+		if rec := serve(handler, "10.0.0.9"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "3" {
+			t.Fatalf("request %d with a raised limit = %d, limit %s", i+1, rec.Code, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+	if rec := serve(handler, "10.0.0.9"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("fourth request = %d, want 429", rec.Code)
+	}
+	if rec := serve(handler, "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("other key = %d, want the default limit", rec.Code)
+	}
+	serve(handler, "10.0.0.1")
+
+	if !limiter.Allow("10.0.0.9") || !limiter.Allow("10.0.0.9") {
+		t.Error("the package override does not reach NewLimiter limiters")
+	}
+
+	limiter.SetLimitFor("10.0.0.9", 0, 0)
+	if limiter.Allow("10.0.0.9") {
+		t.Error("limiter override did not take precedence")
+	}
+
+	ratelimit.ClearLimitFor("10.0.0.9")
+	if _, ok := ratelimit.LimitOverrides()["10.0.0.9"]; ok {
+		t.Error("override still listed after ClearLimitFor")
+	}
+}
@@ -0,0 +1,186 @@
+// Package reqbody makes request bodies readable more than once, for
+// middleware that has to see the body before the handler does, such as
+// signature checks.
+package reqbody
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// DefaultMaxMemory is how much of a body Bytes keeps in memory before
+// spilling the rest to a temporary file.
+const DefaultMaxMemory = 1 << 20
+
+// MaxSize caps the bodies Buffer accepts, in memory and on disk together.
+var MaxSize int64 = 32 << 20
+
+var (
+	ErrTooLarge = errors.New("request body too large")
+	// ErrStreaming is returned for routes that opted out of buffering with
+	// router.StreamBody; their bodies can only be read once, by the handler.
+	ErrStreaming = errors.New("request body is streamed and cannot be buffered")
+)
+
+// Body is a buffered request body. Reads continue where the last one
+// stopped; Rewind goes back to the start.
+type Body struct {
+	data []byte
+	file *os.File
+	size int64
+	r    io.ReadSeeker
+}
+
+func (b *Body) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// Rewind makes the next Read start at the beginning of the body.
+func (b *Body) Rewind() error {
+	_, err := b.r.Seek(0, io.SeekStart)
+	return err
+}
+
+// Size is the length of the body in bytes.
+func (b *Body) Size() int64 {
+	return b.size
+}
+
+// Close is a no-op so handlers closing the body do not discard it for the
+// middleware around them; Release frees the temporary file.
+func (b *Body) Close() error {
+	return nil
+}
+
+// Release removes the temporary file of a spilled body. The router calls
+// it when the request is done.
+func (b *Body) Release() {
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+		b.file = nil
+	}
+}
+
+type contextKey struct{}
+
+// state is attached to each request by Track so bodies buffered deep in
+// the middleware chain are released, and streamed routes are known.
+type state struct {
+	mu        sync.Mutex
+	body      *Body
+	streaming bool
+}
+
+// Track prepares r for buffering and returns a function releasing whatever
+// is buffered for it; streaming marks a route that opted out.
+func Track(r *http.Request, streaming bool) (*http.Request, func()) {
+	s := &state{streaming: streaming}
+	r = r.WithContext(context.WithValue(r.Context(), contextKey{}, s))
+	return r, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.body != nil {
+			s.body.Release()
+		}
+	}
+}
+
+// Buffer reads the body of r, keeping up to maxMemory bytes in memory and
+// the rest in a temporary file, and replaces r.Body with a rewindable *Body.
+// Buffering an already buffered body only rewinds it. Bodies over MaxSize
+// fail with ErrTooLarge and are not replaced, so r should not be served.
+func Buffer(r *http.Request, maxMemory int64) (*Body, error) {
+	if b, ok := r.Body.(*Body); ok {
+		return b, b.Rewind()
+	}
+
+	s, _ := r.Context().Value(contextKey{}).(*state)
+	if s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.streaming {
+			return nil, ErrStreaming
+		}
+		// A copy of r made before buffering still has the original body.
+		if s.body != nil {
+			r.Body = s.body
+			return s.body, s.body.Rewind()
+		}
+	}
+
+	b, err := read(r.Body, maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	r.Body = b
+	if s != nil {
+		s.body = b
+	}
+	return b, nil
+}
+
+func read(body io.Reader, maxMemory int64) (*Body, error) {
+	if body == nil || body == http.NoBody {
+		return &Body{r: bytes.NewReader(nil)}, nil
+	}
+	if maxMemory > MaxSize {
+		maxMemory = MaxSize
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, body, maxMemory+1)
+	if err == io.EOF || (err == nil && n <= maxMemory) {
+		return &Body{data: buf.Bytes(), size: n, r: bytes.NewReader(buf.Bytes())}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "flugo-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer request body: %w", err)
+	}
+	b := &Body{file: file}
+	size, err := io.Copy(file, io.MultiReader(&buf, io.LimitReader(body, MaxSize-n+1)))
+	if err == nil && size > MaxSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		b.Release()
+		if errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to buffer request body: %w", err)
+	}
+	b.size = size
+	b.r = file
+	return b, b.Rewind()
+}
+
+// Bytes returns the whole body of r, buffering it with DefaultMaxMemory
+// first if no one has, and leaves it rewound for the next reader.
+func Bytes(r *http.Request) ([]byte, error) {
+	b, err := Buffer(r, DefaultMaxMemory)
+	if err != nil {
+		return nil, err
+	}
+	if b.file == nil {
+		return b.data, nil
+	}
+
+	data, err := io.ReadAll(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read buffered body: %w", err)
+	}
+	return data, b.Rewind()
+}
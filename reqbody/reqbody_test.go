@@ -0,0 +1,92 @@
+package reqbody_test
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"flugo.com/reqbody"
+)
+
+func TestBufferRewinds(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+	data, err := reqbody.Bytes(r)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("Bytes() = %q, %v", data, err)
+	}
+
+	read, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+	if string(read) != "hello" {
+		t.Errorf("handler read %q", read)
+	}
+	// Closing and reading to the end does not stop middleware reading again.
+	if data, _ := reqbody.Bytes(r); string(data) != "hello" {
+		t.Errorf("second Bytes() = %q", data)
+	}
+}
+
+func TestBufferSpillsToFile(t *testing.T) {
+	content := strings.Repeat("x", 100)
+	r := httptest.NewRequest("POST", "/", strings.NewReader(content))
+	r, release := reqbody.Track(r, false)
+
+	b, err := reqbody.Buffer(r, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Size() != 100 {
+		t.Errorf("Size() = %d", b.Size())
+	}
+	if data, _ := reqbody.Bytes(r); string(data) != content {
+		t.Errorf("Bytes() = %d bytes", len(data))
+	}
+
+	temps := func() int {
+		matches, _ := os.ReadDir(os.TempDir())
+		n := 0
+		for _, m := range matches {
+			if strings.HasPrefix(m.Name(), "flugo-body-") {
+				n++
+			}
+		}
+		return n
+	}
+	before := temps()
+	release()
+	if temps() != before-1 {
+		t.Error("temporary file not removed")
+	}
+}
+
+func TestBufferTooLarge(t *testing.T) {
+	previous := reqbody.MaxSize
+	reqbody.MaxSize = 50
+	t.Cleanup(func() { reqbody.MaxSize = previous })
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 51)))
+	if _, err := reqbody.Buffer(r, 10); !errors.Is(err, reqbody.ErrTooLarge) {
+		t.Errorf("Buffer() = %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 50)))
+	if _, err := reqbody.Buffer(r, 10); err != nil {
+		t.Errorf("Buffer() at the limit = %v", err)
+	}
+}
+
+func TestStreamingRefusesBuffering(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+	r, release := reqbody.Track(r, true)
+	defer release()
+
+	if _, err := reqbody.Bytes(r); !errors.Is(err, reqbody.ErrStreaming) {
+		t.Errorf("Bytes() = %v", err)
+	}
+	if data, _ := io.ReadAll(r.Body); string(data) != "hello" {
+		t.Errorf("body consumed: %q", data)
+	}
+}
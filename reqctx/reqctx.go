@@ -0,0 +1,122 @@
+// Package reqctx carries values the framework attaches to a request: the
+// request ID, the authenticated claims, the matched route pattern, the
+// tenant and a request-scoped logger. Setters return a derived request, like
+// http.Request.WithContext; accessors return the zero value when nothing
+// was set.
+package reqctx
+
+import (
+	"context"
+	"net/http"
+
+	"flugo.com/logger"
+)
+
+type (
+	requestIDKey struct{}
+	claimsKey    struct{}
+	routeKey     struct{}
+	loggerKey    struct{}
+	tenantKey    struct{}
+)
+
+// TenantID identifies the tenant a request belongs to.
+type TenantID string
+
+func WithRequestID(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+func RequestID(r *http.Request) string {
+	return RequestIDFromContext(r.Context())
+}
+
+// RequestIDFromContext serves code that only has the context, such as jobs
+// started by a handler.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithClaims stores the authenticated principal. It is untyped because the
+// auth package, which owns the claims type, builds on this one; use
+// auth.GetCurrentUser for a typed value.
+func WithClaims(r *http.Request, claims interface{}) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims))
+}
+
+func Claims(r *http.Request) interface{} {
+	return ClaimsFromContext(r.Context())
+}
+
+func ClaimsFromContext(ctx context.Context) interface{} {
+	return ctx.Value(claimsKey{})
+}
+
+// WithRoute stores the pattern of the matched route, such as "/users/{id}".
+func WithRoute(r *http.Request, pattern string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, pattern))
+}
+
+func Route(r *http.Request) string {
+	pattern, _ := r.Context().Value(routeKey{}).(string)
+	return pattern
+}
+
+func WithTenant(r *http.Request, id TenantID) *http.Request {
+	return r.WithContext(ContextWithTenant(r.Context(), id))
+}
+
+func Tenant(r *http.Request) TenantID {
+	return TenantFromContext(r.Context())
+}
+
+// ContextWithTenant serves code running outside a request, such as a job
+// that queries tenant-scoped tables.
+func ContextWithTenant(ctx context.Context, id TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+func TenantFromContext(ctx context.Context) TenantID {
+	id, _ := ctx.Value(tenantKey{}).(TenantID)
+	return id
+}
+
+// BaseURL returns the scheme and host the client used to reach the server,
+// such as "https://api.example.com", for building absolute URLs. Behind a
+// load balancer they are only right once middleware.ProxyHeaders has
+// applied its forwarding headers.
+func BaseURL(r *http.Request) string {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	return scheme + "://" + host
+}
+
+func WithLogger(r *http.Request, l *logger.Logger) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), loggerKey{}, l))
+}
+
+// Logger returns the request's logger. Without one it derives a logger
+// tagged with the request ID, if any, from the default logger; it never
+// returns nil.
+func Logger(r *http.Request) *logger.Logger {
+	if l, ok := r.Context().Value(loggerKey{}).(*logger.Logger); ok && l != nil {
+		return l
+	}
+	if id := RequestID(r); id != "" {
+		return logger.With(map[string]interface{}{"request_id": id})
+	}
+	if logger.DefaultLogger != nil {
+		return logger.DefaultLogger
+	}
+	return logger.With(nil)
+}
@@ -0,0 +1,221 @@
+package reqctx_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/container"
+	"flugo.com/logger"
+	"flugo.com/middleware"
+	"flugo.com/ratelimit"
+	"flugo.com/reqctx"
+	"flugo.com/router"
+)
+
+func newRequest() *http.Request {
+	return httptest.NewRequest("GET", "/users/7", nil)
+}
+
+func TestAbsentValues(t *testing.T) {
+	r := newRequest()
+
+	if id := reqctx.RequestID(r); id != "" {
+		t.Errorf("RequestID = %q, want empty", id)
+	}
+	if id := reqctx.RequestIDFromContext(r.Context()); id != "" {
+		t.Errorf("RequestIDFromContext = %q, want empty", id)
+	}
+	if claims := reqctx.Claims(r); claims != nil {
+		t.Errorf("Claims = %v, want nil", claims)
+	}
+	if route := reqctx.Route(r); route != "" {
+		t.Errorf("Route = %q, want empty", route)
+	}
+	if l := reqctx.Logger(r); l == nil {
+		t.Error("Logger returned nil without a logger on the request")
+	}
+	if user := auth.GetCurrentUser(r); user != nil {
+		t.Errorf("GetCurrentUser = %+v, want nil", user)
+	}
+}
+
+func TestPresentValues(t *testing.T) {
+	original := newRequest()
+	claims := &auth.Claims{UserID: 7, Roles: []string{"user"}}
+	l := logger.With(map[string]interface{}{"component": "test"})
+
+	r := reqctx.WithRequestID(original, "req-1")
+	r = reqctx.WithClaims(r, claims)
+	r = reqctx.WithRoute(r, "/users/{id}")
+	r = reqctx.WithLogger(r, l)
+
+	if id := reqctx.RequestID(r); id != "req-1" {
+		t.Errorf("RequestID = %q, want req-1", id)
+	}
+	if id := reqctx.RequestIDFromContext(r.Context()); id != "req-1" {
+		t.Errorf("RequestIDFromContext = %q, want req-1", id)
+	}
+	if got := reqctx.Claims(r); got != claims {
+		t.Errorf("Claims = %v, want %v", got, claims)
+	}
+	if route := reqctx.Route(r); route != "/users/{id}" {
+		t.Errorf("Route = %q, want /users/{id}", route)
+	}
+	if got := reqctx.Logger(r); got != l {
+		t.Error("Logger did not return the stored logger")
+	}
+	if user := auth.GetCurrentUser(r); user != claims {
+		t.Errorf("GetCurrentUser = %+v, want %+v", user, claims)
+	}
+
+	// Setters derive a new request and leave the original untouched.
+	if reqctx.RequestID(original) != "" || reqctx.Claims(original) != nil || reqctx.Route(original) != "" {
+		t.Error("setters modified the original request")
+	}
+}
+
+func TestLaterValuesWin(t *testing.T) {
+	r := reqctx.WithRequestID(newRequest(), "first")
+	r = reqctx.WithRequestID(r, "second")
+	if id := reqctx.RequestID(r); id != "second" {
+		t.Errorf("RequestID = %q, want second", id)
+	}
+}
+
+func TestClaimsOfAnotherTypeAreIgnoredByAuth(t *testing.T) {
+	r := reqctx.WithClaims(newRequest(), map[string]interface{}{"user_id": 7})
+	if user := auth.GetCurrentUser(r); user != nil {
+		t.Errorf("GetCurrentUser = %+v, want nil for foreign claims", user)
+	}
+	if id := auth.GetCurrentUserID(r); id != 0 {
+		t.Errorf("GetCurrentUserID = %d, want 0", id)
+	}
+}
+
+func TestLoggerFallsBackToRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger.Init(&config.LoggerConfig{Level: "info", Format: "json"})
+	logger.SetOutput(&buf)
+	t.Cleanup(func() { logger.DefaultLogger = nil })
+
+	r := reqctx.WithRequestID(newRequest(), "req-42")
+	reqctx.Logger(r).Info("hello")
+
+	if !strings.Contains(buf.String(), `"request_id":"req-42"`) {
+		t.Errorf("log line %q does not carry the request ID", buf.String())
+	}
+}
+
+func TestRouterSetsRoutePattern(t *testing.T) {
+	r := router.NewRouter(container.NewContainer())
+
+	var route string
+	r.GET("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+		route = reqctx.Route(req)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), newRequest())
+	if route != "/users/{id}" {
+		t.Errorf("Route = %q, want /users/{id}", route)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	r := router.NewRouter(container.NewContainer())
+	r.Use(middleware.RequestID())
+
+	var id string
+	r.GET("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id = reqctx.RequestID(req)
+	})
+
+	req := newRequest()
+	req.Header.Set("X-Request-ID", "client-id")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if id != "client-id" || rec.Header().Get("X-Request-ID") != "client-id" {
+		t.Errorf("request ID = %q, header %q, want client-id", id, rec.Header().Get("X-Request-ID"))
+	}
+
+	for _, incoming := range []string{"", "has space", strings.Repeat("x", 200)} {
+		req := newRequest()
+		req.Header.Set("X-Request-ID", incoming)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if id == "" || id == incoming || rec.Header().Get("X-Request-ID") != id {
+			t.Errorf("incoming %q: request ID = %q, header %q, want a generated ID", incoming, id, rec.Header().Get("X-Request-ID"))
+		}
+	}
+}
+
+func TestAuthStoresClaimsOnContext(t *testing.T) {
+	auth.Init(&config.JWTConfig{Secret: "test", ExpirationTime: 60, RefreshTime: 120})
+	token, err := auth.GenerateToken(auth.Claims{UserID: 7, Roles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mw := range map[string]router.MiddlewareFunc{
+		"RequireAuth":  auth.RequireAuth(),
+		"OptionalAuth": auth.OptionalAuth(),
+	} {
+		r := router.NewRouter(container.NewContainer())
+
+		var userID int
+		r.GET("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+			userID = auth.GetCurrentUserID(req)
+		}, mw, auth.RequireRoles("admin"))
+
+		req := newRequest()
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || userID != 7 {
+			t.Errorf("%s: status %d, user %d, want 200 and user 7", name, rec.Code, userID)
+		}
+	}
+
+	// A spoofed header no longer identifies anyone.
+	req := newRequest()
+	req.Header.Set("X-Current-User", "7")
+	if id := auth.GetCurrentUserID(req); id != 0 {
+		t.Errorf("GetCurrentUserID from header = %d, want 0", id)
+	}
+}
+
+func TestLimitByUserKeysOnClaims(t *testing.T) {
+	limit := ratelimit.LimitByUser(1, time.Minute)
+	handler := limit(func(w http.ResponseWriter, r *http.Request) {})
+
+	serve := func(userID int, remoteAddr string) int {
+		req := newRequest()
+		req.RemoteAddr = remoteAddr
+		if userID != 0 {
+			req = reqctx.WithClaims(req, &auth.Claims{UserID: userID})
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(1, "10.0.0.1:1"); code != http.StatusOK {
+		t.Fatalf("first request for user 1: status %d", code)
+	}
+	// Same user from another address shares the budget.
+	if code := serve(1, "10.0.0.2:1"); code != http.StatusTooManyRequests {
+		t.Errorf("second request for user 1: status %d, want 429", code)
+	}
+	if code := serve(2, "10.0.0.1:1"); code != http.StatusOK {
+		t.Errorf("user 2: status %d, want 200", code)
+	}
+	if code := serve(0, "10.0.0.3:1"); code != http.StatusOK {
+		t.Errorf("anonymous request: status %d, want 200", code)
+	}
+}
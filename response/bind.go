@@ -0,0 +1,199 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// BindError describes why a request body could not be bound. Message is
+// safe to show to clients and Status is the code to answer with.
+type BindError struct {
+	Status  int
+	Message string
+	// Field is the JSON path of a value with the wrong type, if known.
+	Field string
+	// Offset is the byte offset of a syntax or type error, if known.
+	Offset int64
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	return e.Message
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+type BindOptions struct {
+	// AnyContentType skips the application/json Content-Type check.
+	AnyContentType bool
+	// MaxBytes caps the body size; 0 leaves any limit to the caller, such as
+	// an http.MaxBytesReader installed by middleware.
+	MaxBytes int64
+	// DisallowUnknownFields rejects fields the target does not declare.
+	DisallowUnknownFields bool
+}
+
+// BindJSON decodes a single JSON value from the request body into target.
+// Failures are *BindError values with client-friendly messages.
+func BindJSON(r *http.Request, target interface{}) error {
+	return BindJSONWithOptions(nil, r, target, BindOptions{})
+}
+
+// BindJSONWithOptions is BindJSON with options. w is only needed for
+// MaxBytes, which installs an http.MaxBytesReader on the body.
+func BindJSONWithOptions(w http.ResponseWriter, r *http.Request, target interface{}, opts BindOptions) error {
+	if !opts.AnyContentType && !isJSONContentType(r.Header.Get("Content-Type")) {
+		return &BindError{
+			Status:  http.StatusUnsupportedMediaType,
+			Message: "Content-Type must be application/json",
+		}
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return errBodyRequired()
+	}
+
+	body := r.Body
+	if opts.MaxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, opts.MaxBytes)
+	}
+
+	decoder := json.NewDecoder(body)
+	if opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(target); err != nil {
+		return bindError(err)
+	}
+	if decoder.More() {
+		return &BindError{
+			Status:  http.StatusBadRequest,
+			Message: "request body must contain a single JSON value",
+			Offset:  decoder.InputOffset(),
+		}
+	}
+	if _, err := decoder.Token(); err != nil && err != io.EOF {
+		return bindError(err)
+	}
+	return nil
+}
+
+// isJSONContentType accepts application/json and +json types such as
+// application/problem+json, with any parameters.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func errBodyRequired() *BindError {
+	return &BindError{Status: http.StatusBadRequest, Message: "request body is required"}
+}
+
+func bindError(err error) *BindError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errBodyRequired()
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &BindError{
+			Status:  http.StatusBadRequest,
+			Message: "malformed JSON: unexpected end of body",
+			Err:     err,
+		}
+	case errors.As(err, &syntaxErr):
+		return &BindError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("malformed JSON at byte offset %d", syntaxErr.Offset),
+			Offset:  syntaxErr.Offset,
+			Err:     err,
+		}
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return &BindError{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("request body must be %s, got %s", describeType(typeErr.Type.String()), typeErr.Value),
+				Offset:  typeErr.Offset,
+				Err:     err,
+			}
+		}
+		return &BindError{
+			Status: http.StatusBadRequest,
+			Message: fmt.Sprintf("field %q must be %s, got %s (byte offset %d)",
+				field, describeType(typeErr.Type.String()), typeErr.Value, typeErr.Offset),
+			Field:  field,
+			Offset: typeErr.Offset,
+			Err:    err,
+		}
+	case errors.As(err, &maxBytesErr):
+		return &BindError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit),
+			Err:     err,
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &BindError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("unknown field %q", field),
+			Field:   field,
+			Err:     err,
+		}
+	}
+
+	return &BindError{Status: http.StatusBadRequest, Message: "invalid request body", Err: err}
+}
+
+// describeType names Go types the way JSON clients think of them.
+func describeType(goType string) string {
+	goType = strings.TrimLeft(goType, "*")
+	switch {
+	case goType == "string":
+		return "a string"
+	case goType == "bool":
+		return "a boolean"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"):
+		return "an integer"
+	case strings.HasPrefix(goType, "float"):
+		return "a number"
+	case strings.HasPrefix(goType, "[]"):
+		return "an array"
+	case strings.HasPrefix(goType, "map["):
+		return "an object"
+	case goType == "time.Time":
+		return "a timestamp"
+	}
+	return "an object"
+}
+
+// InvalidBody answers with the status and message of a BindJSON error.
+func InvalidBody(w http.ResponseWriter, err error) {
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		BadRequest(w, "Invalid JSON format", err.Error())
+		return
+	}
+
+	message := "Invalid JSON format"
+	switch bindErr.Status {
+	case http.StatusUnsupportedMediaType:
+		message = "Unsupported media type"
+	case http.StatusRequestEntityTooLarge:
+		message = "Request body too large"
+	}
+	Error(w, bindErr.Status, message, bindErr.Message)
+}
@@ -0,0 +1,72 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithLastModified sets Last-Modified and calls handler unless the request's
+// If-Modified-Since shows the client already has this version, in which case
+// it responds 304 Not Modified. It can be nested with WithETag; a match on
+// either one short-circuits.
+func WithLastModified(w http.ResponseWriter, r *http.Request, lastModified time.Time, handler func()) {
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if isConditionalMethod(r) && !lastModified.IsZero() {
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !since.Before(lastModified) {
+			NotModified(w)
+			return
+		}
+	}
+
+	handler()
+}
+
+// WithETag sets ETag and calls handler unless If-None-Match lists the same
+// entity tag, in which case it responds 304 Not Modified.
+func WithETag(w http.ResponseWriter, r *http.Request, etag string, handler func()) {
+	if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = `"` + etag + `"`
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if isConditionalMethod(r) && etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		NotModified(w)
+		return
+	}
+
+	handler()
+}
+
+func NotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func isConditionalMethod(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// etagMatches applies the weak comparison used by If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fileETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// fileETags remembers content hashes by path until the file's size or
+// modification time changes, so a file is hashed once rather than per request.
+var fileETags sync.Map
+
+// ServeFile writes the file at filePath with Content-Type, Content-Length,
+// Last-Modified and a content-hash ETag, answering conditional and Range
+// requests through http.ServeContent. Missing files and directories are 404.
+func ServeFile(w http.ResponseWriter, r *http.Request, filePath string) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			NotFound(w, "File not found")
+			return
+		}
+		InternalError(w, "Failed to open file")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		InternalError(w, "Failed to read file")
+		return
+	}
+	if info.IsDir() {
+		NotFound(w, "File not found")
+		return
+	}
+
+	etag, err := fileContentETag(f, filePath, info)
+	if err != nil {
+		InternalError(w, "Failed to read file")
+		return
+	}
+	contentType, err := fileContentType(f, filePath)
+	if err != nil {
+		InternalError(w, "Failed to read file")
+		return
+	}
+
+	// Replace anything set earlier, such as by middleware.JSONContentType.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// ServeDownload is ServeFile with a Content-Disposition that makes browsers
+// save the file as downloadName, or under its own name when that is empty.
+func ServeDownload(w http.ResponseWriter, r *http.Request, filePath string, downloadName string) {
+	if downloadName == "" {
+		downloadName = filepath.Base(filePath)
+	}
+	w.Header().Set("Content-Disposition", attachmentDisposition(downloadName))
+	ServeFile(w, r, filePath)
+}
+
+func fileContentETag(f *os.File, filePath string, info os.FileInfo) (string, error) {
+	if cached, ok := fileETags.Load(filePath); ok {
+		entry := cached.(fileETag)
+		if entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			return entry.etag, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+	fileETags.Store(filePath, fileETag{size: info.Size(), modTime: info.ModTime(), etag: etag})
+	return etag, nil
+}
+
+// fileContentType goes by extension and falls back to sniffing the first
+// 512 bytes.
+func fileContentType(f *os.File, filePath string) (string, error) {
+	if ctype := mime.TypeByExtension(filepath.Ext(filePath)); ctype != "" {
+		return ctype, nil
+	}
+
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// attachmentDisposition quotes an ASCII fallback of name and adds an RFC 5987
+// filename* when name has other characters.
+func attachmentDisposition(name string) string {
+	var fallback strings.Builder
+	ascii := true
+	for _, c := range name {
+		switch {
+		case c == '"' || c == '\\':
+			fallback.WriteByte('_')
+		case c < 0x20 || c == 0x7f:
+			fallback.WriteByte('_')
+		case c > 0x7e:
+			ascii = false
+			fallback.WriteByte('_')
+		default:
+			fallback.WriteRune(c)
+		}
+	}
+
+	disposition := fmt.Sprintf(`attachment; filename="%s"`, fallback.String())
+	if !ascii {
+		disposition += "; filename*=UTF-8''" + url.PathEscape(name)
+	}
+	return disposition
+}
@@ -0,0 +1,465 @@
+package response
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Struct fields tagged serialize:"never" are left out of every response,
+// and fields tagged with roles, such as serialize:"admin" or
+// serialize:"admin,support", only reach callers holding one of them. The
+// roles come from the writer, where auth.RequireAuth and auth.OptionalAuth
+// put the token's roles with WithRoles.
+const (
+	serializeTag   = "serialize"
+	serializeNever = "never"
+)
+
+// Filtered writes data in the success envelope keeping only the requested
+// fields, as parsed by FieldsParam. A dotted name such as author.name
+// selects inside a nested object, slices are filtered item by item, and
+// names that match nothing are ignored. No fields means all of them.
+func Filtered(w http.ResponseWriter, data interface{}, fields []string, message ...string) {
+	msg := "Success"
+	if len(message) > 0 {
+		msg = message[0]
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: msg,
+		Data:    filterValue(reflect.ValueOf(data), parseFields(fields), WriterRoles(w)),
+	})
+}
+
+// FieldsParam splits the comma-separated ?fields= query parameter.
+func FieldsParam(r *http.Request) []string {
+	var fields []string
+	for _, field := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// rolesWriter carries the caller's roles to code that only sees the
+// ResponseWriter, like i18n's locale writer.
+type rolesWriter struct {
+	http.ResponseWriter
+	roles []string
+}
+
+func (w *rolesWriter) Roles() []string {
+	return w.roles
+}
+
+func (w *rolesWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *rolesWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func WithRoles(w http.ResponseWriter, roles []string) http.ResponseWriter {
+	return &rolesWriter{ResponseWriter: w, roles: roles}
+}
+
+// WriterRoles finds the roles attached by WithRoles, looking through
+// writers that wrap it.
+func WriterRoles(w http.ResponseWriter) []string {
+	for w != nil {
+		if rw, ok := w.(interface{ Roles() []string }); ok {
+			return rw.Roles()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+	return nil
+}
+
+// visible drops the fields data's serialize tags hide from the caller. It
+// returns data itself when its type has no such tags to check.
+func visible(w http.ResponseWriter, data interface{}) interface{} {
+	if data == nil || !hasTagged(reflect.ValueOf(data)) {
+		return data
+	}
+	return filterValue(reflect.ValueOf(data), nil, WriterRoles(w))
+}
+
+// hasTagged reports whether v holds a struct with serialize tags, looking
+// into interfaces only for their dynamic values, without copying anything.
+func hasTagged(v reflect.Value) bool {
+	if !v.IsValid() || !needsFilter(v.Type()) {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return !v.IsNil() && hasTagged(v.Elem())
+	case reflect.Struct:
+		for _, field := range structFields(v.Type()) {
+			if field.never || len(field.roles) > 0 || hasTagged(v.Field(field.index)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if hasTagged(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasTagged(iter.Value()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selection is a parsed fields list: the selected names, each mapped to the
+// selection inside it, where nil selects everything.
+type selection map[string]selection
+
+func parseFields(fields []string) selection {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	root := selection{}
+	for _, field := range fields {
+		node := root
+		parts := strings.Split(field, ".")
+		for i, part := range parts {
+			child, seen := node[part]
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+			if seen && child == nil {
+				// A shorter path already selected all of it.
+				break
+			}
+			if child == nil {
+				child = selection{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return root
+}
+
+func (s selection) has(name string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[name]
+	return ok
+}
+
+type fieldInfo struct {
+	index     int
+	name      string
+	omitEmpty bool
+	// inline is set for embedded structs whose fields json promotes.
+	inline bool
+	never  bool
+	roles  []string
+}
+
+var (
+	fieldCache sync.Map // reflect.Type -> []fieldInfo
+	needsCache sync.Map // reflect.Type -> bool
+
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// structFields lists the fields of t that json would encode, with their
+// serialize rules.
+func structFields(t reflect.Type) []fieldInfo {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]fieldInfo)
+	}
+
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// Embedded unexported structs are skipped: their fields cannot be
+		// read through reflection.
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		info := fieldInfo{index: i, name: name, omitEmpty: strings.Contains(","+opts+",", ",omitempty,")}
+		if name == "" {
+			info.name = sf.Name
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			info.inline = sf.Anonymous && ft.Kind() == reflect.Struct
+		}
+
+		switch serialize := sf.Tag.Get(serializeTag); serialize {
+		case "":
+		case serializeNever:
+			info.never = true
+		default:
+			for _, role := range strings.Split(serialize, ",") {
+				if role = strings.TrimSpace(role); role != "" {
+					info.roles = append(info.roles, role)
+				}
+			}
+		}
+		fields = append(fields, info)
+	}
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// needsFilter reports whether values of t may hold fields with serialize
+// tags. Interfaces are checked when the value is walked.
+func needsFilter(t reflect.Type) bool {
+	if cached, ok := needsCache.Load(t); ok {
+		return cached.(bool)
+	}
+	// Only t's own answer is cached: inside a recursive type, the types
+	// on the cycle are answered before the tags around them are seen.
+	needs := typeNeedsFilter(t, map[reflect.Type]bool{})
+	needsCache.Store(t, needs)
+	return needs
+}
+
+func typeNeedsFilter(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] || isLeaf(t) {
+		return false
+	}
+	visiting[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeNeedsFilter(t.Elem(), visiting)
+	case reflect.Struct:
+		for _, field := range structFields(t) {
+			if field.never || len(field.roles) > 0 || typeNeedsFilter(t.Field(field.index).Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLeaf reports whether json encodes t through its own marshaler.
+func isLeaf(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	return t.Implements(marshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(marshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// filterValue encodes v with only the selected fields the roles may see.
+// The result is written as is by the response's encoder, so nested values
+// are not re-marshaled on the way out.
+func filterValue(v reflect.Value, sel selection, roles []string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	f := &filterEncoder{roles: roles}
+	f.enc = json.NewEncoder(&f.buf)
+	if err := f.encode(v, sel); err != nil {
+		return nil
+	}
+	return json.RawMessage(f.buf.Bytes())
+}
+
+type filterEncoder struct {
+	buf   bytes.Buffer
+	enc   *json.Encoder
+	roles []string
+}
+
+func (f *filterEncoder) encode(v reflect.Value, sel selection) error {
+	if !v.IsValid() {
+		f.buf.WriteString("null")
+		return nil
+	}
+	if sel == nil && !needsFilter(v.Type()) {
+		return f.leaf(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			f.buf.WriteString("null")
+			return nil
+		}
+		if isLeaf(v.Type()) {
+			return f.leaf(v)
+		}
+		return f.encode(v.Elem(), sel)
+	case reflect.Struct:
+		if isLeaf(v.Type()) {
+			return f.leaf(v)
+		}
+		f.buf.WriteByte('{')
+		_, err := f.encodeFields(v, sel, true)
+		f.buf.WriteByte('}')
+		return err
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			return f.leaf(v)
+		}
+		f.buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				f.buf.WriteByte(',')
+			}
+			if err := f.encode(v.Index(i), sel); err != nil {
+				return err
+			}
+		}
+		f.buf.WriteByte(']')
+		return nil
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return f.leaf(v)
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if key := iter.Key().String(); sel.has(key) {
+				keys = append(keys, key)
+				values[key] = iter.Value()
+			}
+		}
+		sort.Strings(keys)
+
+		f.buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				f.buf.WriteByte(',')
+			}
+			f.name(key)
+			if err := f.encode(values[key], sel[key]); err != nil {
+				return err
+			}
+		}
+		f.buf.WriteByte('}')
+		return nil
+	}
+	return f.leaf(v)
+}
+
+// encodeFields writes the members of struct v, without braces, and
+// reports whether the object is still empty.
+func (f *filterEncoder) encodeFields(v reflect.Value, sel selection, first bool) (bool, error) {
+	for _, field := range structFields(v.Type()) {
+		if field.never || (len(field.roles) > 0 && !hasRole(f.roles, field.roles)) {
+			continue
+		}
+
+		fv := v.Field(field.index)
+		if field.inline {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			var err error
+			if first, err = f.encodeFields(fv, sel, first); err != nil {
+				return first, err
+			}
+			continue
+		}
+
+		if !sel.has(field.name) || (field.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		if !first {
+			f.buf.WriteByte(',')
+		}
+		first = false
+		f.name(field.name)
+		if err := f.encode(fv, sel[field.name]); err != nil {
+			return first, err
+		}
+	}
+	return first, nil
+}
+
+func (f *filterEncoder) name(name string) {
+	f.enc.Encode(name)
+	f.buf.WriteByte(':')
+}
+
+// leaf writes v as encoding/json does; the encoder's newline is harmless
+// whitespace inside the object.
+func (f *filterEncoder) leaf(v reflect.Value) error {
+	if isLeaf(v.Type()) {
+		return f.enc.Encode(v.Interface())
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		return nil
+	case reflect.Bool:
+		f.buf.WriteString(strconv.FormatBool(v.Bool()))
+		return nil
+	}
+	return f.enc.Encode(v.Interface())
+}
+
+// isEmptyValue reports whether omitempty drops v, as encoding/json does.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+	return false
+}
+
+func hasRole(roles, allowed []string) bool {
+	for _, role := range roles {
+		for _, a := range allowed {
+			if role == a {
+				return true
+			}
+		}
+	}
+	return false
+}
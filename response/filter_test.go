@@ -0,0 +1,105 @@
+package response_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/response"
+)
+
+type author struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email" serialize:"admin"`
+}
+
+type Audit struct {
+	CreatedAt time.Time `json:"created_at"`
+	Internal  bool      `json:"internal" serialize:"never"`
+}
+
+type article struct {
+	Audit
+	ID           int               `json:"id"`
+	Title        string            `json:"title"`
+	Author       *author           `json:"author"`
+	Tags         []string          `json:"tags,omitempty"`
+	PasswordHash string            `json:"password_hash" serialize:"never"`
+	Comments     []author          `json:"comments"`
+	Extra        map[string]string `json:"extra,omitempty"`
+}
+
+func sampleArticle() article {
+	return article{
+		Audit:        Audit{CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Internal: true},
+		ID:           7,
+		Title:        "Hello",
+		Author:       &author{ID: 1, Name: "Ann", Email: "ann@example.com"},
+		PasswordHash: "secret",
+		Comments:     []author{{ID: 2, Name: "Bob", Email: "bob@example.com"}},
+	}
+}
+
+func TestSerializeTagsApplyToEveryResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	response.Success(w, map[string]interface{}{"article": sampleArticle()})
+
+	body := w.Body.String()
+	for _, hidden := range []string{"password_hash", "secret", "internal", "ann@example.com"} {
+		if strings.Contains(body, hidden) {
+			t.Errorf("response exposes %q: %s", hidden, body)
+		}
+	}
+	if !strings.Contains(body, `"created_at":"2024-01-02T00:00:00Z","id":7,"title":"Hello"`) {
+		t.Errorf("fields not in declaration order: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	response.JSON(response.WithRoles(w, []string{"user", "admin"}), 200, []article{sampleArticle()})
+	body = w.Body.String()
+	if !strings.Contains(body, "ann@example.com") || strings.Contains(body, "secret") {
+		t.Errorf("admin response = %s", body)
+	}
+}
+
+func TestFiltered(t *testing.T) {
+	w := httptest.NewRecorder()
+	fields := response.FieldsParam(httptest.NewRequest("GET", "/articles?fields=id,+author.name,comments.id,author.email,unknown,title.x", nil))
+	response.Filtered(w, []article{sampleArticle()}, fields)
+
+	data := decode(t, w)["data"].([]interface{})
+	got := fmt.Sprint(data[0])
+	want := "map[author:map[name:Ann] comments:[map[id:2]] id:7 title:Hello]"
+	if got != want {
+		t.Errorf("filtered item = %s, want %s", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	response.Filtered(w, sampleArticle(), nil)
+	if item := decode(t, w)["data"].(map[string]interface{}); len(item) != 5 || item["author"] == nil {
+		t.Errorf("no fields selected = %v", item)
+	}
+}
+
+func BenchmarkFiltered(b *testing.B) {
+	items := make([]article, 1000)
+	for i := range items {
+		items[i] = sampleArticle()
+		items[i].ID = i
+	}
+	fields := []string{"id", "title", "author.name"}
+
+	b.Run("plain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			response.Success(httptest.NewRecorder(), items)
+		}
+	})
+	b.Run("fields", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			response.Filtered(httptest.NewRecorder(), items, fields)
+		}
+	})
+}
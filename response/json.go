@@ -0,0 +1,61 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// MarshalOpts controls how JSONWithOpts encodes a body.
+type MarshalOpts struct {
+	// Indent indents nested values by this string; empty writes compact JSON.
+	Indent string
+	// EscapeHTML escapes <, > and & as json.Marshal does.
+	EscapeHTML bool
+}
+
+var prettyPrint atomic.Bool
+
+// SetPrettyPrint switches every response helper between indented and
+// compact JSON. Applications take it from config.ServerConfig.PrettyPrint.
+func SetPrettyPrint(enabled bool) {
+	prettyPrint.Store(enabled)
+}
+
+func PrettyPrint() bool {
+	return prettyPrint.Load()
+}
+
+// JSONWithOpts is JSON with explicit encoding options instead of the global
+// pretty-print setting.
+func JSONWithOpts(w http.ResponseWriter, statusCode int, data interface{}, opts MarshalOpts) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(marshalWithOpts(visible(w, data), opts))
+}
+
+// marshal encodes v per the pretty-print setting, with a trailing newline.
+func marshal(v interface{}) []byte {
+	if prettyPrint.Load() {
+		return marshalWithOpts(v, MarshalOpts{Indent: "  ", EscapeHTML: true})
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return append(b, '\n')
+}
+
+func marshalWithOpts(v interface{}, opts MarshalOpts) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(opts.EscapeHTML)
+	if opts.Indent != "" {
+		encoder.SetIndent("", opts.Indent)
+	}
+	if err := encoder.Encode(v); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
@@ -1,9 +1,12 @@
 package response
 
 import (
-	"encoding/json"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"flugo.com/i18n"
+	"flugo.com/validator"
 )
 
 type APIResponse struct {
@@ -12,6 +15,9 @@ type APIResponse struct {
 	Data      interface{} `json:"data,omitempty"`
 	Errors    interface{} `json:"errors,omitempty"`
 	Meta      *Meta       `json:"meta,omitempty"`
+	Code      string      `json:"code,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 }
 
@@ -27,15 +33,40 @@ type PaginatedResponse struct {
 	Meta Meta          `json:"meta"`
 }
 
+var debug atomic.Bool
+
+// SetDebug controls whether ErrorWithCode writes error details. Leave it off
+// in production, where responses carry only the code, message and request
+// ID. Applications take it from config.ServerConfig.Debug.
+func SetDebug(enabled bool) {
+	debug.Store(enabled)
+}
+
+func Debug() bool {
+	return debug.Load()
+}
+
+// writeJSON translates the message for the request locale once a catalog is
+// loaded; messages without a translation are written as given. The request
+// ID set by middleware.RequestID is copied into the envelope so clients can
+// quote it when reporting a problem. Fields the caller may not see, by
+// their serialize tags, are dropped from the data.
 func writeJSON(w http.ResponseWriter, statusCode int, response APIResponse) {
+	response.RequestID = w.Header().Get("X-Request-ID")
+	if !debug.Load() {
+		response.Details = nil
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
 	response.Timestamp = time.Now()
+	response.Data = visible(w, response.Data)
+	if response.Message != "" && i18n.Loaded() {
+		response.Message = i18n.T(i18n.WriterLocale(w), response.Message, nil)
+	}
 
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	encoder.Encode(response)
+	w.Write(marshal(response))
 }
 
 func Success(w http.ResponseWriter, data interface{}, message ...string) {
@@ -130,6 +161,23 @@ func Error(w http.ResponseWriter, statusCode int, message string, errors ...inte
 	writeJSON(w, statusCode, response)
 }
 
+// ErrorWithCode writes an error with a machine-readable code. details, such
+// as an underlying error, are dropped unless debug mode is on.
+func ErrorWithCode(w http.ResponseWriter, statusCode int, code, message string, details interface{}) {
+	if err, ok := details.(error); ok {
+		details = err.Error()
+	}
+
+	response := APIResponse{
+		Success: false,
+		Message: message,
+		Code:    code,
+		Details: details,
+	}
+
+	writeJSON(w, statusCode, response)
+}
+
 func BadRequest(w http.ResponseWriter, message string, errors ...interface{}) {
 	Error(w, http.StatusBadRequest, message, errors...)
 }
@@ -172,12 +220,21 @@ func ValidationError(w http.ResponseWriter, message string, errors interface{})
 	writeJSON(w, http.StatusUnprocessableEntity, response)
 }
 
+// ValidationErrorMap answers like ValidationError with the messages grouped
+// by JSON Pointer path, e.g. {"/items/2/price": ["must be at least 0"]}.
+func ValidationErrorMap(w http.ResponseWriter, message string, errors validator.ValidationErrors) {
+	if i18n.Loaded() {
+		errors = errors.Localize(i18n.WriterLocale(w))
+	}
+	ValidationError(w, message, errors.ToMap())
+}
+
 func InternalError(w http.ResponseWriter, message ...string) {
 	msg := "Internal server error"
 	if len(message) > 0 {
 		msg = message[0]
 	}
-	Error(w, http.StatusInternalServerError, msg)
+	ErrorWithCode(w, http.StatusInternalServerError, "internal_error", msg, nil)
 }
 
 func ServiceUnavailable(w http.ResponseWriter, message ...string) {
@@ -211,9 +268,7 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	encoder.Encode(data)
+	w.Write(marshal(visible(w, data)))
 }
 
 func EmptySuccess(w http.ResponseWriter) {
@@ -241,10 +296,18 @@ func NoContent(w http.ResponseWriter) {
 }
 
 type HealthStatus struct {
-	Status    string            `json:"status"`
-	Timestamp time.Time         `json:"timestamp"`
-	Version   string            `json:"version,omitempty"`
-	Services  map[string]string `json:"services,omitempty"`
+	Status    string                 `json:"status"`
+	Timestamp time.Time              `json:"timestamp"`
+	Version   string                 `json:"version,omitempty"`
+	Services  map[string]string      `json:"services,omitempty"`
+	Checks    map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
 }
 
 func Health(w http.ResponseWriter, status string, version string, services map[string]string) {
@@ -255,14 +318,25 @@ func Health(w http.ResponseWriter, status string, version string, services map[s
 		Services:  services,
 	}
 
-	statusCode := http.StatusOK
-	if status != "healthy" {
-		statusCode = http.StatusServiceUnavailable
+	JSON(w, healthStatusCode(status), health)
+}
+
+// HealthWithChecks reports individual check results. A "degraded" status,
+// where only non-critical checks fail, is still served with 200.
+func HealthWithChecks(w http.ResponseWriter, status string, version string, checks map[string]HealthCheck) {
+	health := HealthStatus{
+		Status:    status,
+		Timestamp: time.Now(),
+		Version:   version,
+		Checks:    checks,
 	}
 
-	JSON(w, statusCode, health)
+	JSON(w, healthStatusCode(status), health)
 }
 
-func BindJSON(r *http.Request, target interface{}) error {
-	return json.NewDecoder(r.Body).Decode(target)
+func healthStatusCode(status string) int {
+	if status == "healthy" || status == "degraded" {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
 }
@@ -0,0 +1,77 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flugo.com/response"
+)
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func TestEnvelopeCarriesRequestID(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-123")
+	response.Success(w, nil)
+	if body := decode(t, w); body["request_id"] != "req-123" {
+		t.Errorf("request_id = %v, want req-123", body["request_id"])
+	}
+
+	w = httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-456")
+	response.NotFound(w)
+	if body := decode(t, w); body["request_id"] != "req-456" {
+		t.Errorf("request_id = %v, want req-456", body["request_id"])
+	}
+
+	w = httptest.NewRecorder()
+	response.Success(w, nil)
+	if body := decode(t, w); body["request_id"] != nil {
+		t.Errorf("request_id = %v without a request ID, want it omitted", body["request_id"])
+	}
+}
+
+func TestErrorWithCodeStripsDetailsInProduction(t *testing.T) {
+	defer response.SetDebug(false)
+
+	response.SetDebug(false)
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-1")
+	response.ErrorWithCode(w, http.StatusBadGateway, "upstream_failed", "Payment provider unavailable", errors.New("dial tcp 10.0.0.7:443: connection refused"))
+
+	body := decode(t, w)
+	if w.Code != http.StatusBadGateway || body["code"] != "upstream_failed" || body["message"] != "Payment provider unavailable" || body["request_id"] != "req-1" {
+		t.Errorf("status %d, body %v", w.Code, body)
+	}
+	if _, ok := body["details"]; ok {
+		t.Errorf("details = %v, want them stripped", body["details"])
+	}
+
+	response.SetDebug(true)
+	w = httptest.NewRecorder()
+	response.ErrorWithCode(w, http.StatusBadGateway, "upstream_failed", "Payment provider unavailable", errors.New("connection refused"))
+	if body := decode(t, w); body["details"] != "connection refused" {
+		t.Errorf("details = %v in debug mode, want the error text", body["details"])
+	}
+}
+
+func TestInternalErrorHasCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-9")
+	response.InternalError(w)
+
+	body := decode(t, w)
+	if w.Code != http.StatusInternalServerError || body["code"] != "internal_error" || body["request_id"] != "req-9" {
+		t.Errorf("status %d, body %v", w.Code, body)
+	}
+}
@@ -0,0 +1,144 @@
+package response
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+
+	"flugo.com/i18n"
+	"flugo.com/validator"
+)
+
+// Result is what a handler returns to choose the status, message and meta
+// of the envelope its data is written in. A zero Status means 200, and 204
+// writes no body.
+type Result struct {
+	Status  int
+	Message string
+	Data    interface{}
+	Meta    *Meta
+}
+
+// Render writes value in the success envelope, or as given when it is a
+// *Result.
+func Render(w http.ResponseWriter, value interface{}) {
+	result, ok := value.(*Result)
+	if !ok {
+		Success(w, value)
+		return
+	}
+	if result == nil {
+		Success(w, nil)
+		return
+	}
+
+	status := result.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status == http.StatusNoContent {
+		NoContent(w)
+		return
+	}
+
+	message := result.Message
+	if message == "" {
+		message = "Success"
+	}
+	writeJSON(w, status, APIResponse{
+		Success: status < http.StatusBadRequest,
+		Message: message,
+		Data:    result.Data,
+		Meta:    result.Meta,
+	})
+}
+
+// ErrorMapping is how an error is answered. Errors, if set, is written in
+// the envelope's errors field.
+type ErrorMapping struct {
+	Status  int
+	Code    string
+	Message string
+	Errors  interface{}
+}
+
+// ErrorMapper maps the errors it recognizes, typically with errors.As.
+type ErrorMapper func(err error) (ErrorMapping, bool)
+
+var (
+	errorMappersMu sync.RWMutex
+	errorMappers   []ErrorMapper
+)
+
+func init() {
+	RegisterError(sql.ErrNoRows, ErrorMapping{Status: http.StatusNotFound, Code: "not_found", Message: "Resource not found"})
+	RegisterErrorMapper(func(err error) (ErrorMapping, bool) {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			return ErrorMapping{}, false
+		}
+		return ErrorMapping{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    "validation_failed",
+			Message: "Validation failed",
+			Errors:  validationErrors,
+		}, true
+	})
+	RegisterErrorMapper(func(err error) (ErrorMapping, bool) {
+		var bindErr *BindError
+		if !errors.As(err, &bindErr) {
+			return ErrorMapping{}, false
+		}
+		return ErrorMapping{Status: bindErr.Status, Code: "invalid_body", Message: bindErr.Message}, true
+	})
+}
+
+// RegisterError answers errors matching target with errors.Is with mapping.
+func RegisterError(target error, mapping ErrorMapping) {
+	RegisterErrorMapper(func(err error) (ErrorMapping, bool) {
+		return mapping, errors.Is(err, target)
+	})
+}
+
+// RegisterErrorMapper adds mapper to the registry used by MapError. Mappers
+// registered later are tried first, so applications can override the
+// built-in mappings.
+func RegisterErrorMapper(mapper ErrorMapper) {
+	errorMappersMu.Lock()
+	defer errorMappersMu.Unlock()
+	errorMappers = append(errorMappers, mapper)
+}
+
+// MapError looks err up in the registry.
+func MapError(err error) (ErrorMapping, bool) {
+	errorMappersMu.RLock()
+	defer errorMappersMu.RUnlock()
+
+	for i := len(errorMappers) - 1; i >= 0; i-- {
+		if mapping, ok := errorMappers[i](err); ok {
+			return mapping, true
+		}
+	}
+	return ErrorMapping{}, false
+}
+
+// WriteError answers with the mapping registered for err, or with a 500
+// whose details are only shown in debug mode.
+func WriteError(w http.ResponseWriter, err error) {
+	mapping, ok := MapError(err)
+	if !ok {
+		ErrorWithCode(w, http.StatusInternalServerError, "internal_error", "Internal server error", err)
+		return
+	}
+
+	if validationErrors, ok := mapping.Errors.(validator.ValidationErrors); ok && i18n.Loaded() {
+		mapping.Errors = validationErrors.Localize(i18n.WriterLocale(w))
+	}
+	writeJSON(w, mapping.Status, APIResponse{
+		Success: false,
+		Message: mapping.Message,
+		Errors:  mapping.Errors,
+		Code:    mapping.Code,
+	})
+}
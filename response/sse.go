@@ -0,0 +1,119 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	sseClientBuffer = 16
+	sseHeartbeat    = 30 * time.Second
+)
+
+type SSEMessage struct {
+	Event string
+	Data  string
+}
+
+// SSEBroker fans server-sent events out to every subscribed client.
+type SSEBroker struct {
+	clients sync.Map // client id -> chan SSEMessage
+	nextID  atomic.Int64
+	count   atomic.Int64
+}
+
+func NewSSEBroker() *SSEBroker {
+	return &SSEBroker{}
+}
+
+// Subscribe registers the request as a client and streams events to it in
+// the background. The returned channel yields one value (nil or the write
+// error) once the client is gone; handlers must wait on it before returning:
+//
+//	<-broker.Subscribe(w, r)
+func (b *SSEBroker) Subscribe(w http.ResponseWriter, r *http.Request) <-chan error {
+	done := make(chan error, 1)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		done <- fmt.Errorf("streaming is not supported by this response writer")
+		close(done)
+		return done
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	id := b.nextID.Add(1)
+	messages := make(chan SSEMessage, sseClientBuffer)
+	b.clients.Store(id, messages)
+	b.count.Add(1)
+
+	go func() {
+		var err error
+		defer func() {
+			b.clients.Delete(id)
+			b.count.Add(-1)
+			done <- err
+			close(done)
+		}()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case msg := <-messages:
+				if _, err = w.Write([]byte(formatSSE(msg))); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err = w.Write([]byte(": ping\n\n")); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	return done
+}
+
+// Broadcast queues the event for every connected client. Clients whose
+// buffer is full miss the event rather than stalling the others.
+func (b *SSEBroker) Broadcast(event, data string) {
+	msg := SSEMessage{Event: event, Data: data}
+	b.clients.Range(func(_, value interface{}) bool {
+		select {
+		case value.(chan SSEMessage) <- msg:
+		default:
+		}
+		return true
+	})
+}
+
+func (b *SSEBroker) ClientCount() int {
+	return int(b.count.Load())
+}
+
+func formatSSE(msg SSEMessage) string {
+	var buf strings.Builder
+	if msg.Event != "" {
+		buf.WriteString("event: " + msg.Event + "\n")
+	}
+	for _, line := range strings.Split(msg.Data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
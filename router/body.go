@@ -0,0 +1,74 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"flugo.com/reqbody"
+	"flugo.com/response"
+)
+
+// bodyMiddlewares holds the code pointers of middlewares marked by NeedsBody
+// or StreamBody. Every middleware built by the same constructor shares one.
+var bodyMiddlewares sync.Map
+
+type bodyMode int
+
+const (
+	bodyNeeded bodyMode = iota + 1
+	bodyStreamed
+)
+
+// NeedsBody marks mw, and every middleware built by the same function, as
+// reading the request body. Routes using such a middleware have their body
+// buffered with reqbody before the chain runs, so it can be read again by the
+// handler; see reqbody.Bytes. It returns mw unchanged, for constructors to
+// wrap what they return.
+func NeedsBody(mw MiddlewareFunc) MiddlewareFunc {
+	bodyMiddlewares.Store(reflect.ValueOf(mw).Pointer(), bodyNeeded)
+	return mw
+}
+
+// StreamBody opts a route out of body buffering, for uploads and other
+// bodies that should go straight to the handler. Middlewares marked with
+// NeedsBody on such a route get reqbody.ErrStreaming from reqbody.Bytes.
+func StreamBody() MiddlewareFunc {
+	return streamBody
+}
+
+func streamBody(next HandlerFunc) HandlerFunc {
+	return next
+}
+
+func init() {
+	bodyMiddlewares.Store(reflect.ValueOf(MiddlewareFunc(streamBody)).Pointer(), bodyStreamed)
+}
+
+// bodyModeOf reports whether any of the middlewares streams or needs the
+// body; streaming wins.
+func bodyModeOf(middlewares ...[]MiddlewareFunc) bodyMode {
+	var mode bodyMode
+	for _, list := range middlewares {
+		for _, mw := range list {
+			if m, ok := bodyMiddlewares.Load(reflect.ValueOf(mw).Pointer()); ok && m.(bodyMode) > mode {
+				mode = m.(bodyMode)
+			}
+		}
+	}
+	return mode
+}
+
+// bufferBody answers the request itself when the body cannot be buffered.
+func bufferBody(w http.ResponseWriter, req *http.Request) bool {
+	if _, err := reqbody.Buffer(req, reqbody.DefaultMaxMemory); err != nil {
+		if errors.Is(err, reqbody.ErrTooLarge) {
+			response.ErrorWithCode(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large", nil)
+		} else {
+			response.BadRequest(w, "Failed to read request body")
+		}
+		return false
+	}
+	return true
+}
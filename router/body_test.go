@@ -0,0 +1,87 @@
+package router_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"flugo.com/container"
+	"flugo.com/reqbody"
+	"flugo.com/router"
+)
+
+// peekBody reads the body before the handler, as a signature check would.
+func peekBody(seen *string) router.MiddlewareFunc {
+	return router.NeedsBody(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			data, err := reqbody.Bytes(r)
+			if errors.Is(err, reqbody.ErrStreaming) {
+				*seen = "streaming"
+			} else {
+				*seen = string(data)
+			}
+			next(w, r)
+		}
+	})
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	w.Write(data)
+}
+
+func TestNeedsBodyBuffersForHandler(t *testing.T) {
+	r := router.NewRouter(container.NewContainer())
+	var seen string
+	r.POST("/hook", echo, peekBody(&seen))
+	r.POST("/upload", echo, peekBody(&seen), router.StreamBody())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/hook", strings.NewReader("payload")))
+	if seen != "payload" || w.Body.String() != "payload" {
+		t.Errorf("middleware saw %q, handler read %q", seen, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/upload", strings.NewReader("file")))
+	if seen != "streaming" || w.Body.String() != "file" {
+		t.Errorf("streamed route: middleware saw %q, handler read %q", seen, w.Body.String())
+	}
+
+	if names := router.MiddlewareNames([]router.MiddlewareFunc{peekBody(&seen)}); names[0] != "router_test.peekBody" {
+		t.Errorf("NeedsBody changed the middleware name to %v", names)
+	}
+}
+
+func TestBodyNotBufferedWithoutNeed(t *testing.T) {
+	r := router.NewRouter(container.NewContainer())
+	var buffered bool
+	r.POST("/plain", func(w http.ResponseWriter, req *http.Request) {
+		_, buffered = req.Body.(*reqbody.Body)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/plain", strings.NewReader("x")))
+	if buffered {
+		t.Error("body buffered for a route without a body middleware")
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	previous := reqbody.MaxSize
+	reqbody.MaxSize = 4
+	t.Cleanup(func() { reqbody.MaxSize = previous })
+
+	r := router.NewRouter(container.NewContainer())
+	var seen string
+	r.Use(peekBody(&seen))
+	r.POST("/hook", echo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/hook", strings.NewReader(strings.Repeat("x", 5))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d", w.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package router
+
+import "strings"
+
+// Group registers routes under a shared path prefix and middleware stack:
+//
+//	v1 := r.Group("/v1")
+//	v1.GET("/users", listUsersV1)
+type Group struct {
+	router      *Router
+	parent      *Group
+	prefix      string
+	middlewares []MiddlewareFunc
+}
+
+func (r *Router) Group(prefix string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{
+		router:      r,
+		prefix:      strings.TrimRight(prefix, "/"),
+		middlewares: middlewares,
+	}
+}
+
+func (g *Group) Group(prefix string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{
+		router:      g.router,
+		parent:      g,
+		prefix:      g.prefix + strings.TrimRight(prefix, "/"),
+		middlewares: middlewares,
+	}
+}
+
+// Use adds a middleware to the group's routes and subgroups, including those
+// registered before the call.
+func (g *Group) Use(middleware MiddlewareFunc) {
+	g.middlewares = append(g.middlewares, middleware)
+}
+
+// chain returns the middlewares of g and its parents, outermost first.
+func (g *Group) chain() []MiddlewareFunc {
+	if g == nil {
+		return nil
+	}
+	return append(g.parent.chain(), g.middlewares...)
+}
+
+func (g *Group) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
+	g.addRoute("GET", path, handler, middlewares)
+}
+
+func (g *Group) POST(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
+	g.addRoute("POST", path, handler, middlewares)
+}
+
+func (g *Group) PUT(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
+	g.addRoute("PUT", path, handler, middlewares)
+}
+
+func (g *Group) DELETE(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
+	g.addRoute("DELETE", path, handler, middlewares)
+}
+
+func (g *Group) addRoute(method, path string, handler HandlerFunc, middlewares []MiddlewareFunc) {
+	g.router.addGroupRoute(g, method, g.prefix+path, handler, middlewares)
+}
+
+// RegisterController auto-routes controller under the group's prefix and
+// middleware.
+func (g *Group) RegisterController(controller interface{}, basePath string) {
+	g.router.registerController(controller, basePath, func(method, path string, handler HandlerFunc) {
+		g.addRoute(method, path, handler, nil)
+	})
+}
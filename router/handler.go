@@ -0,0 +1,71 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+
+	"flugo.com/logger"
+	"flugo.com/response"
+)
+
+// Handle adapts a handler that returns its data instead of writing it. The
+// value is written in the success envelope, or as given when it is a
+// *response.Result; errors are answered through response.WriteError, and
+// those without a registered mapping are logged and become a 500.
+func Handle(fn func(r *http.Request) (interface{}, error)) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		value, err := fn(req)
+		render(w, req, value, err)
+	}
+}
+
+// HandleResult is Handle for handlers that always return a *response.Result.
+func HandleResult(fn func(r *http.Request) (*response.Result, error)) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		result, err := fn(req)
+		render(w, req, result, err)
+	}
+}
+
+func render(w http.ResponseWriter, req *http.Request, value interface{}, err error) {
+	if err != nil {
+		if _, ok := response.MapError(err); !ok {
+			logger.Error("%s %s failed: %v", req.Method, req.URL.Path, err)
+		}
+		response.WriteError(w, err)
+		return
+	}
+	response.Render(w, value)
+}
+
+var (
+	responseWriterType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+	requestType        = reflect.TypeOf((*http.Request)(nil))
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// methodHandler builds the handler for a controller method written either
+// as func(http.ResponseWriter, *http.Request) or as
+// func(*http.Request) (T, error), which is rendered like Handle. Other
+// methods are not routes.
+func methodHandler(method reflect.Value) (HandlerFunc, bool) {
+	t := method.Type()
+
+	if t.NumIn() == 2 &&
+		t.In(0).Implements(responseWriterType) && t.In(1) == requestType {
+		return func(w http.ResponseWriter, req *http.Request) {
+			method.Call([]reflect.Value{reflect.ValueOf(w), reflect.ValueOf(req)})
+		}, true
+	}
+
+	if t.NumIn() == 1 && t.In(0) == requestType &&
+		t.NumOut() == 2 && t.Out(1) == errorType {
+		return func(w http.ResponseWriter, req *http.Request) {
+			out := method.Call([]reflect.Value{reflect.ValueOf(req)})
+			err, _ := out[1].Interface().(error)
+			render(w, req, out[0].Interface(), err)
+		}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,225 @@
+package router_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flugo.com/auth"
+	"flugo.com/container"
+	"flugo.com/database"
+	"flugo.com/response"
+	"flugo.com/router"
+	"flugo.com/validator"
+)
+
+var errTeapot = errors.New("out of coffee")
+
+type quotaError struct{ limit int }
+
+func (e *quotaError) Error() string { return fmt.Sprintf("quota of %d exceeded", e.limit) }
+
+func init() {
+	response.RegisterError(errTeapot, response.ErrorMapping{
+		Status: http.StatusTeapot, Code: "teapot", Message: "I'm a teapot",
+	})
+	response.RegisterErrorMapper(func(err error) (response.ErrorMapping, bool) {
+		var quotaErr *quotaError
+		if !errors.As(err, &quotaErr) {
+			return response.ErrorMapping{}, false
+		}
+		return response.ErrorMapping{
+			Status:  http.StatusTooManyRequests,
+			Code:    "quota_exceeded",
+			Message: quotaErr.Error(),
+		}, true
+	})
+}
+
+type itemController struct{}
+
+func (itemController) GetItems(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, "classic")
+}
+
+func (itemController) GetItemsById(r *http.Request) (interface{}, error) {
+	switch id := router.Param(r, "id"); id {
+	case "missing":
+		return nil, fmt.Errorf("load item: %w", database.ErrNotFound)
+	case "invalid":
+		return nil, validator.ValidationErrors{{Field: "name", Message: "name is required", Tag: "required"}}
+	case "anonymous":
+		return nil, auth.ErrUnauthorized
+	case "denied":
+		return nil, fmt.Errorf("item %s: %w", id, auth.ErrForbidden)
+	case "teapot":
+		return nil, errTeapot
+	case "quota":
+		return nil, fmt.Errorf("create item: %w", &quotaError{limit: 10})
+	case "broken":
+		return nil, errors.New("disk on fire")
+	default:
+		return map[string]string{"id": id}, nil
+	}
+}
+
+func (itemController) PostItems(r *http.Request) (*response.Result, error) {
+	return &response.Result{
+		Status:  http.StatusCreated,
+		Message: "Item created",
+		Data:    map[string]string{"id": "7"},
+		Meta:    &response.Meta{Total: 1},
+	}, nil
+}
+
+func (itemController) DeleteItemsById(r *http.Request) (*response.Result, error) {
+	return &response.Result{Status: http.StatusNoContent}, nil
+}
+
+func (itemController) GetCount(r *http.Request) (int, error) {
+	return 3, nil
+}
+
+// GetLabel has neither handler shape, so it is not a route.
+func (itemController) GetLabel() string {
+	return "items"
+}
+
+func newRouter() *router.Router {
+	r := router.NewRouter(container.NewContainer())
+	r.RegisterController(&itemController{}, "/api")
+	return r
+}
+
+func serve(t *testing.T, r http.Handler, method, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	if w.Body.Len() == 0 {
+		return w, nil
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: invalid JSON %q: %v", method, path, w.Body.String(), err)
+	}
+	return w, body
+}
+
+func TestControllerClassicSignature(t *testing.T) {
+	w, body := serve(t, newRouter(), "GET", "/api/items")
+	if w.Code != http.StatusOK || body["data"] != "classic" {
+		t.Errorf("GET /api/items = %d %v, want 200 with data classic", w.Code, body)
+	}
+}
+
+func TestControllerReturnedValue(t *testing.T) {
+	r := newRouter()
+
+	w, body := serve(t, r, "GET", "/api/items/42")
+	data, _ := body["data"].(map[string]interface{})
+	if w.Code != http.StatusOK || body["success"] != true || data["id"] != "42" {
+		t.Errorf("GET /api/items/42 = %d %v, want 200 with the item", w.Code, body)
+	}
+
+	w, body = serve(t, r, "GET", "/api/count")
+	if w.Code != http.StatusOK || body["data"] != float64(3) {
+		t.Errorf("GET /api/count = %d %v, want 200 with data 3", w.Code, body)
+	}
+}
+
+func TestControllerResult(t *testing.T) {
+	r := newRouter()
+
+	w, body := serve(t, r, "POST", "/api/items")
+	meta, _ := body["meta"].(map[string]interface{})
+	if w.Code != http.StatusCreated || body["message"] != "Item created" || meta["total"] != float64(1) {
+		t.Errorf("POST /api/items = %d %v, want 201 with message and meta", w.Code, body)
+	}
+
+	w, _ = serve(t, r, "DELETE", "/api/items/7")
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("DELETE /api/items/7 = %d %q, want an empty 204", w.Code, w.Body.String())
+	}
+}
+
+func TestControllerSkipsOtherMethods(t *testing.T) {
+	for _, route := range newRouter().Routes() {
+		if route.Path == "/api/label" {
+			t.Errorf("GetLabel was registered as %s %s", route.Method, route.Path)
+		}
+	}
+}
+
+func TestErrorMappings(t *testing.T) {
+	tests := []struct {
+		id     string
+		status int
+		code   string
+	}{
+		{"missing", http.StatusNotFound, "not_found"},
+		{"invalid", http.StatusUnprocessableEntity, "validation_failed"},
+		{"anonymous", http.StatusUnauthorized, "unauthorized"},
+		{"denied", http.StatusForbidden, "forbidden"},
+		{"teapot", http.StatusTeapot, "teapot"},
+		{"quota", http.StatusTooManyRequests, "quota_exceeded"},
+		{"broken", http.StatusInternalServerError, "internal_error"},
+	}
+
+	r := newRouter()
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			w, body := serve(t, r, "GET", "/api/items/"+tt.id)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if body["success"] != false || body["code"] != tt.code {
+				t.Errorf("body = %v, want success false and code %s", body, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsAreListed(t *testing.T) {
+	_, body := serve(t, newRouter(), "GET", "/api/items/invalid")
+	errs, _ := body["errors"].([]interface{})
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want one field error", body["errors"])
+	}
+	if field, _ := errs[0].(map[string]interface{}); field["field"] != "name" {
+		t.Errorf("errors[0] = %v, want the name field", errs[0])
+	}
+}
+
+func TestUnmappedErrorHidesDetails(t *testing.T) {
+	_, body := serve(t, newRouter(), "GET", "/api/items/broken")
+	if body["message"] != "Internal server error" || body["details"] != nil {
+		t.Errorf("body = %v, want a generic message without details", body)
+	}
+
+	response.SetDebug(true)
+	defer response.SetDebug(false)
+	_, body = serve(t, newRouter(), "GET", "/api/items/broken")
+	if body["details"] != "disk on fire" {
+		t.Errorf("details = %v in debug mode, want the error", body["details"])
+	}
+}
+
+func TestHandle(t *testing.T) {
+	r := router.NewRouter(container.NewContainer())
+	r.GET("/ping", router.Handle(func(req *http.Request) (interface{}, error) {
+		return "pong", nil
+	}))
+	r.GET("/gone", router.HandleResult(func(req *http.Request) (*response.Result, error) {
+		return nil, database.ErrNotFound
+	}))
+
+	if w, body := serve(t, r, "GET", "/ping"); w.Code != http.StatusOK || body["data"] != "pong" {
+		t.Errorf("GET /ping = %d %v, want 200 with data pong", w.Code, body)
+	}
+	if w, _ := serve(t, r, "GET", "/gone"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /gone = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"flugo.com/container"
+	"flugo.com/router"
+)
+
+type trail struct {
+	steps []string
+}
+
+func (tr *trail) record(name string) router.MiddlewareFunc {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tr.steps = append(tr.steps, name)
+			next(w, r)
+		}
+	}
+}
+
+func (tr *trail) handler(w http.ResponseWriter, r *http.Request) {
+	tr.steps = append(tr.steps, "handler")
+}
+
+func (tr *trail) serve(r *router.Router, path string) []string {
+	tr.steps = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	return tr.steps
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	want := []string{"global1", "global2", "api", "v1", "route1", "route2", "handler"}
+
+	// Every registration order yields the same chain: global, then groups
+	// from the outside in, then the route's own.
+	for name, register := range map[string]func(r *router.Router, tr *trail){
+		"middlewares first": func(r *router.Router, tr *trail) {
+			r.Use(tr.record("global1"))
+			r.Use(tr.record("global2"))
+			api := r.Group("/api", tr.record("api"))
+			v1 := api.Group("/v1")
+			v1.Use(tr.record("v1"))
+			v1.GET("/items", tr.handler, tr.record("route1"), tr.record("route2"))
+		},
+		"routes first": func(r *router.Router, tr *trail) {
+			api := r.Group("/api")
+			v1 := api.Group("/v1")
+			v1.GET("/items", tr.handler, tr.record("route1"), tr.record("route2"))
+			v1.Use(tr.record("v1"))
+			r.Use(tr.record("global1"))
+			api.Use(tr.record("api"))
+			r.Use(tr.record("global2"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tr := &trail{}
+			r := router.NewRouter(container.NewContainer())
+			register(r, tr)
+
+			if got := tr.serve(r, "/api/v1/items"); !reflect.DeepEqual(got, want) {
+				t.Errorf("ran %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+type orderController struct {
+	tr *trail
+}
+
+func (c *orderController) GetOrders(w http.ResponseWriter, r *http.Request) {
+	c.tr.handler(w, r)
+}
+
+func TestMiddlewareAddedAfterControllers(t *testing.T) {
+	tr := &trail{}
+	r := router.NewRouter(container.NewContainer())
+
+	admin := r.Group("/admin")
+	admin.RegisterController(&orderController{tr: tr}, "")
+	r.RegisterController(&orderController{tr: tr}, "")
+	admin.Use(tr.record("admin"))
+	r.Use(tr.record("global"))
+
+	if got := tr.serve(r, "/admin/orders"); !reflect.DeepEqual(got, []string{"global", "admin", "handler"}) {
+		t.Errorf("group controller ran %v", got)
+	}
+	if got := tr.serve(r, "/orders"); !reflect.DeepEqual(got, []string{"global", "handler"}) {
+		t.Errorf("controller ran %v", got)
+	}
+
+	for _, route := range r.Routes() {
+		names := router.MiddlewareNames(route.Middlewares)
+		if wantGroup := strings.HasPrefix(route.Path, "/admin"); wantGroup != (len(names) == 1) {
+			t.Errorf("%s lists middlewares %v", route.Path, names)
+		}
+	}
+	if got := r.MiddlewareNames(); len(got) != 1 || !strings.HasPrefix(got[0], "router_test.") {
+		t.Errorf("MiddlewareNames() = %v", got)
+	}
+}
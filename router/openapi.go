@@ -0,0 +1,153 @@
+package router
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"flugo.com/router/openapi"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPISpec describes every registered route: {name} segments become path
+// parameters and the Go doc comment of controller methods and named handler
+// functions becomes the description, when the source is available.
+func (r *Router) OpenAPISpec(info openapi.Info) *openapi.Spec {
+	spec := &openapi.Spec{
+		OpenAPI: openapi.Version,
+		Info:    info,
+		Paths:   make(map[string]*openapi.PathItem),
+	}
+
+	for _, route := range r.routes {
+		item := spec.Paths[route.Path]
+		if item == nil {
+			item = &openapi.PathItem{}
+			spec.Paths[route.Path] = item
+		}
+
+		op := &openapi.Operation{
+			Description: routeDoc(route),
+			OperationID: operationID(route.Method, route.Path),
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Successful response"},
+			},
+		}
+		if segment := firstSegment(route.Path); segment != "" {
+			op.Tags = []string{segment}
+		}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "string"},
+			})
+		}
+		item.SetOperation(route.Method, op)
+	}
+
+	return spec
+}
+
+// OpenAPIHandler serves the spec as JSON, rebuilt on each request:
+//
+//	r.GET("/openapi.json", r.OpenAPIHandler(info))
+func (r *Router) OpenAPIHandler(info openapi.Info) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(r.OpenAPISpec(info))
+	}
+}
+
+func operationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func firstSegment(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if strings.HasPrefix(segment, "{") {
+		return ""
+	}
+	return segment
+}
+
+var closureName = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+
+// routeDoc finds the declaration behind a route through the runtime's file
+// and line information and returns its doc comment.
+func routeDoc(route Route) string {
+	pc := route.source
+	if pc == 0 && route.Handler != nil {
+		pc = reflect.ValueOf(route.Handler).Pointer()
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil || closureName.MatchString(fn.Name()) {
+		return ""
+	}
+	file, line := fn.FileLine(fn.Entry())
+
+	fset, parsed := parseSource(file)
+	if parsed == nil {
+		return ""
+	}
+	name := fn.Name()[strings.LastIndex(fn.Name(), ".")+1:]
+
+	for _, decl := range parsed.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Name.Name != name || funcDecl.Doc == nil {
+			continue
+		}
+		if fset.Position(funcDecl.Pos()).Line <= line && line <= fset.Position(funcDecl.End()).Line {
+			return strings.TrimSpace(funcDecl.Doc.Text())
+		}
+	}
+	return ""
+}
+
+type parsedSource struct {
+	fset *token.FileSet
+	file *ast.File
+}
+
+var (
+	sourcesMu sync.Mutex
+	sources   = make(map[string]parsedSource)
+)
+
+// parseSource parses and caches a Go file; missing sources, as in deployed
+// binaries, are cached as nil.
+func parseSource(path string) (*token.FileSet, *ast.File) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	if src, ok := sources[path]; ok {
+		return src.fset, src.file
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		file = nil
+	}
+	sources[path] = parsedSource{fset: fset, file: file}
+	return fset, file
+}
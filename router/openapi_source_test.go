@@ -0,0 +1,38 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// documented has a doc comment that is lost without its source.
+func documented(w http.ResponseWriter, r *http.Request) {}
+
+func TestRouteDocWithoutSource(t *testing.T) {
+	if _, file := parseSource("/nonexistent/controller.go"); file != nil {
+		t.Error("parseSource of a missing file returned a syntax tree")
+	}
+
+	route := Route{Method: "GET", Path: "/documented", Handler: documented}
+	if doc := routeDoc(route); doc != "documented has a doc comment that is lost without its source." {
+		t.Fatalf("routeDoc = %q", doc)
+	}
+
+	// Deployed binaries carry the file name but not the file.
+	file, _ := runtime.FuncForPC(reflect.ValueOf(documented).Pointer()).FileLine(reflect.ValueOf(documented).Pointer())
+	sourcesMu.Lock()
+	saved := sources[file]
+	sources[file] = parsedSource{}
+	sourcesMu.Unlock()
+	t.Cleanup(func() {
+		sourcesMu.Lock()
+		sources[file] = saved
+		sourcesMu.Unlock()
+	})
+
+	if doc := routeDoc(route); doc != "" {
+		t.Errorf("routeDoc without the source = %q, want none", doc)
+	}
+}
@@ -0,0 +1,93 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flugo.com/container"
+	"flugo.com/router"
+	"flugo.com/router/openapi"
+)
+
+type postController struct{}
+
+// GetUsersPostsByUserIdAndId returns one post of a user.
+func (postController) GetUsersPostsByUserIdAndId(w http.ResponseWriter, r *http.Request) {}
+
+func (postController) PostUsersPostsByUserId(w http.ResponseWriter, r *http.Request) {}
+
+// healthz reports that the process is up.
+func healthz(w http.ResponseWriter, r *http.Request) {}
+
+func newSpecRouter() *router.Router {
+	r := router.NewRouter(container.NewContainer())
+	r.RegisterController(&postController{}, "/api")
+	r.GET("/healthz", healthz)
+	r.DELETE("/api/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {})
+	return r
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	spec := newSpecRouter().OpenAPISpec(openapi.Info{Title: "Posts", Version: "2.0.0"})
+
+	if spec.OpenAPI != openapi.Version || spec.Info.Title != "Posts" || len(spec.Paths) != 4 {
+		t.Fatalf("spec = %s %+v with %d paths, want 4", spec.OpenAPI, spec.Info, len(spec.Paths))
+	}
+
+	tests := []struct {
+		method, path string
+		id           string
+		tag          string
+		description  string
+		params       []string
+	}{
+		{"GET", "/api/users/{userId}/posts/{id}", "get_api_users_userId_posts_id", "api", "GetUsersPostsByUserIdAndId returns one post of a user.", []string{"userId", "id"}},
+		{"POST", "/api/users/{userId}/posts", "post_api_users_userId_posts", "api", "", []string{"userId"}},
+		{"GET", "/healthz", "get_healthz", "healthz", "healthz reports that the process is up.", nil},
+		// Closures have no declaration to document them.
+		{"DELETE", "/api/tags/{tag}", "delete_api_tags_tag", "api", "", []string{"tag"}},
+	}
+	for _, tt := range tests {
+		item := spec.Paths[tt.path]
+		if item == nil || item.Operation(tt.method) == nil {
+			t.Errorf("no operation for %s %s", tt.method, tt.path)
+			continue
+		}
+		op := item.Operation(tt.method)
+		if op.OperationID != tt.id || len(op.Tags) != 1 || op.Tags[0] != tt.tag || op.Description != tt.description {
+			t.Errorf("%s %s = id %q, tags %v, description %q", tt.method, tt.path, op.OperationID, op.Tags, op.Description)
+		}
+		if len(op.Parameters) != len(tt.params) {
+			t.Errorf("%s %s parameters = %+v, want %v", tt.method, tt.path, op.Parameters, tt.params)
+			continue
+		}
+		for i, name := range tt.params {
+			p := op.Parameters[i]
+			if p.Name != name || p.In != "path" || !p.Required || p.Schema == nil || p.Schema.Type != "string" {
+				t.Errorf("%s %s parameter %d = %+v, want required path parameter %s", tt.method, tt.path, i, p, name)
+			}
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	r := newSpecRouter()
+	r.GET("/openapi.json", r.OpenAPIHandler(openapi.Info{Title: "Posts", Version: "2.0.0"}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /openapi.json = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var spec openapi.Spec
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("invalid JSON %q: %v", w.Body.String(), err)
+	}
+	// The spec is built per request, so it lists the handler's own route.
+	if spec.Info.Version != "2.0.0" || spec.Paths["/openapi.json"] == nil || spec.Paths["/healthz"].Get == nil {
+		t.Errorf("served spec = %+v", spec)
+	}
+}
@@ -1,21 +1,39 @@
 package router
 
 import (
+	"context"
 	"net/http"
 	"reflect"
+	"runtime"
 	"strings"
 
 	"flugo.com/container"
+	"flugo.com/reqbody"
+	"flugo.com/reqctx"
 )
 
 type HandlerFunc func(http.ResponseWriter, *http.Request)
 type MiddlewareFunc func(HandlerFunc) HandlerFunc
 
 type Route struct {
-	Method      string
-	Path        string
-	Handler     HandlerFunc
+	Method  string
+	Path    string
+	Handler HandlerFunc
+	// Middlewares are the group middlewares of the route followed by its
+	// own, outermost first. The global middlewares wrap them all.
 	Middlewares []MiddlewareFunc
+
+	// source is the controller method behind Handler, used for its doc comment.
+	source uintptr
+	// group and own are resolved into Middlewares when the route is served
+	// or listed, so middlewares added to a group later still apply.
+	group *Group
+	own   []MiddlewareFunc
+}
+
+// middlewares resolves the group and route middlewares of route.
+func (route Route) middlewares() []MiddlewareFunc {
+	return append(route.group.chain(), route.own...)
 }
 
 type Router struct {
@@ -32,10 +50,71 @@ func NewRouter(c *container.Container) *Router {
 	}
 }
 
+// Use adds a global middleware. Middlewares wrap every route, including
+// routes registered before the call, in this order from the outside in:
+// global middlewares in Use order, then group middlewares from the outermost
+// group, then the route's own.
 func (r *Router) Use(middleware MiddlewareFunc) {
 	r.globalMiddlewares = append(r.globalMiddlewares, middleware)
 }
 
+// Middlewares returns the global middlewares in the order they run.
+func (r *Router) Middlewares() []MiddlewareFunc {
+	middlewares := make([]MiddlewareFunc, len(r.globalMiddlewares))
+	copy(middlewares, r.globalMiddlewares)
+	return middlewares
+}
+
+// MiddlewareNames returns the names of the global middlewares in the order
+// they run; see MiddlewareName.
+func (r *Router) MiddlewareNames() []string {
+	return MiddlewareNames(r.globalMiddlewares)
+}
+
+func MiddlewareNames(middlewares []MiddlewareFunc) []string {
+	names := make([]string, len(middlewares))
+	for i, mw := range middlewares {
+		names[i] = MiddlewareName(mw)
+	}
+	return names
+}
+
+// MiddlewareName names mw after the function that built it, such as
+// "auth.RequireAuth" for the closure returned by auth.RequireAuth().
+func MiddlewareName(mw MiddlewareFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "anonymous"
+	}
+
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for {
+		i := strings.LastIndex(name, ".")
+		if i < 0 || !isClosureSuffix(name[i+1:]) {
+			return name
+		}
+		name = name[:i]
+	}
+}
+
+// isClosureSuffix matches the "func1" and "2" parts the compiler appends to
+// closure names.
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *Router) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
 	r.addRoute("GET", path, handler, middlewares)
 }
@@ -53,49 +132,61 @@ func (r *Router) DELETE(path string, handler HandlerFunc, middlewares ...Middlew
 }
 
 func (r *Router) addRoute(method, path string, handler HandlerFunc, middlewares []MiddlewareFunc) {
+	r.addGroupRoute(nil, method, path, handler, middlewares)
+}
+
+func (r *Router) addGroupRoute(group *Group, method, path string, handler HandlerFunc, middlewares []MiddlewareFunc) {
 	route := Route{
-		Method:      method,
-		Path:        path,
-		Handler:     handler,
-		Middlewares: middlewares,
+		Method:  method,
+		Path:    path,
+		Handler: handler,
+		group:   group,
+		own:     middlewares,
 	}
 	r.routes = append(r.routes, route)
 }
 
 func (r *Router) RegisterController(controller interface{}, basePath string) {
+	r.registerController(controller, basePath, func(method, path string, handler HandlerFunc) {
+		r.addRoute(method, path, handler, nil)
+	})
+}
+
+func (r *Router) registerController(controller interface{}, basePath string, add func(method, path string, handler HandlerFunc)) {
+	// Keep the pointer so methods with pointer receivers are found too.
 	controllerType := reflect.TypeOf(controller)
 	controllerValue := reflect.ValueOf(controller)
 
-	if controllerType.Kind() == reflect.Ptr {
-		controllerType = controllerType.Elem()
-		controllerValue = controllerValue.Elem()
-	}
-
 	r.container.Register(controller)
 
 	for i := 0; i < controllerType.NumMethod(); i++ {
 		method := controllerType.Method(i)
-		methodValue := controllerValue.Method(i)
-
-		if method.Type.NumIn() == 3 &&
-			method.Type.In(1).Implements(reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()) &&
-			method.Type.In(2) == reflect.TypeOf((*http.Request)(nil)) {
-
-			httpMethod := extractHTTPMethod(method.Name)
-			if httpMethod != "" {
-				path := basePath + extractPath(method.Name)
-
-				methodFunc := methodValue
-				handler := func(w http.ResponseWriter, req *http.Request) {
-					methodFunc.Call([]reflect.Value{
-						reflect.ValueOf(w),
-						reflect.ValueOf(req),
-					})
-				}
-				r.addRoute(httpMethod, path, handler, nil)
-			}
+
+		httpMethod := extractHTTPMethod(method.Name)
+		if httpMethod == "" {
+			continue
+		}
+		handler, ok := methodHandler(controllerValue.Method(i))
+		if !ok {
+			continue
+		}
+
+		add(httpMethod, basePath+extractPath(method.Name), handler)
+		// add appends exactly one route, whether through a group or not.
+		r.routes[len(r.routes)-1].source = methodSource(controllerType, method)
+	}
+}
+
+// methodSource returns the code address of a controller method. Value
+// receiver methods reached through a pointer point at the declared method
+// rather than the compiler's wrapper, which has no source.
+func methodSource(controllerType reflect.Type, method reflect.Method) uintptr {
+	if controllerType.Kind() == reflect.Ptr {
+		if declared, ok := controllerType.Elem().MethodByName(method.Name); ok {
+			return declared.Func.Pointer()
 		}
 	}
+	return method.Func.Pointer()
 }
 
 func extractHTTPMethod(methodName string) string {
@@ -121,38 +212,163 @@ func extractPath(methodName string) string {
 			if remaining == "" {
 				return ""
 			}
-			if strings.HasSuffix(remaining, "ById") {
-				remaining = remaining[:len(remaining)-4] // Remove "ById"
-				if remaining == "" {
-					return "/{id}"
-				}
-				return "/" + strings.ToLower(remaining) + "/{id}"
-			}
-			return "/" + strings.ToLower(remaining)
+			return resourcePath(remaining)
 		}
 	}
 	return "/" + strings.ToLower(methodName)
 }
 
-func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	for _, route := range r.routes {
-		if route.Method == req.Method && r.matchPath(route.Path, req.URL.Path) {
-			handler := route.Handler
+// resourcePath turns the rest of a method name into a path. A trailing
+// By<Owner>Id[And<Owner>Id...] adds parameters: each one follows the word it
+// belongs to, so UsersPostsByUserId becomes /users/{userId}/posts, while a
+// plain ById is appended as /{id}. Words without a parameter between them
+// are joined, keeping UploadAvatar as /uploadavatar.
+func resourcePath(name string) string {
+	resource, owners := splitParams(name)
 
-			for i := len(r.globalMiddlewares) - 1; i >= 0; i-- {
-				handler = r.globalMiddlewares[i](handler)
-			}
+	var path, segment strings.Builder
+	flush := func() {
+		if segment.Len() > 0 {
+			path.WriteString("/" + segment.String())
+			segment.Reset()
+		}
+	}
 
-			for i := len(route.Middlewares) - 1; i >= 0; i-- {
-				handler = route.Middlewares[i](handler)
+	for _, word := range splitCamel(resource) {
+		segment.WriteString(strings.ToLower(word))
+
+		for i, owner := range owners {
+			if owner != "" && strings.HasPrefix(strings.ToLower(word), strings.ToLower(owner)) {
+				flush()
+				path.WriteString("/{" + paramName(owner) + "}")
+				owners = append(owners[:i], owners[i+1:]...)
+				break
 			}
+		}
+	}
+	flush()
 
-			handler(w, req)
-			return
+	for _, owner := range owners {
+		path.WriteString("/{" + paramName(owner) + "}")
+	}
+	return path.String()
+}
+
+// splitParams separates "UsersPostsByUserId" into "UsersPosts" and the
+// parameter owners ["User"]; an owner of "" stands for a bare Id. By and
+// And only count as whole words, so BypassRulesById keeps its resource
+// BypassRules.
+func splitParams(name string) (string, []string) {
+	words := splitCamel(name)
+	for i, word := range words {
+		if word != "By" {
+			continue
+		}
+		if owners, ok := paramOwners(words[i+1:]); ok {
+			return strings.Join(words[:i], ""), owners
 		}
 	}
+	return name, nil
+}
 
-	http.NotFound(w, req)
+// paramOwners reads words such as [User Id And Id] as the owners
+// ["User", ""]; every parameter must end with the word Id.
+func paramOwners(words []string) ([]string, bool) {
+	var owners []string
+	start := 0
+	for i := 0; i <= len(words); i++ {
+		if i < len(words) && words[i] != "And" {
+			continue
+		}
+		param := words[start:i]
+		if len(param) == 0 || param[len(param)-1] != "Id" {
+			return nil, false
+		}
+		owners = append(owners, strings.Join(param[:len(param)-1], ""))
+		start = i + 1
+	}
+	return owners, true
+}
+
+func paramName(owner string) string {
+	if owner == "" {
+		return "id"
+	}
+	return strings.ToLower(owner[:1]) + owner[1:] + "Id"
+}
+
+func splitCamel(s string) []string {
+	var words []string
+	start := 0
+	for i := 1; i < len(s); i++ {
+		if s[i] >= 'A' && s[i] <= 'Z' {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// ServeHTTP prefers exact and parameterized matches; plain routes still act
+// as prefixes for anything below them when nothing more specific matches.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	route, params, found := r.findRoute(req.Method, req.URL.Path)
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+
+	req = reqctx.WithRoute(req, route.Path)
+	if len(params) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), paramsContextKey, params))
+	}
+
+	// The chain is composed per request from the current middlewares, so
+	// registration order does not matter; global middlewares are outermost.
+	handler := route.Handler
+	middlewares := route.middlewares()
+
+	// Bodies are buffered only for routes with a middleware that reads them.
+	mode := bodyModeOf(r.globalMiddlewares, middlewares)
+	req, release := reqbody.Track(req, mode == bodyStreamed)
+	defer release()
+	if mode == bodyNeeded && !bufferBody(w, req) {
+		return
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	for i := len(r.globalMiddlewares) - 1; i >= 0; i-- {
+		handler = r.globalMiddlewares[i](handler)
+	}
+
+	handler(w, req)
+}
+
+func (r *Router) findRoute(method, path string) (Route, map[string]string, bool) {
+	for _, route := range r.routes {
+		if route.Method != method {
+			continue
+		}
+		if route.Path == path {
+			return route, nil, true
+		}
+		if params, ok := matchParams(route.Path, path); ok {
+			return route, params, true
+		}
+	}
+
+	for _, route := range r.routes {
+		if route.Method == method && r.matchPath(route.Path, path) {
+			return route, nil, true
+		}
+	}
+
+	return Route{}, nil, false
 }
 
 func (r *Router) matchPath(routePath, requestPath string) bool {
@@ -160,6 +376,10 @@ func (r *Router) matchPath(routePath, requestPath string) bool {
 		return true
 	}
 
+	if strings.Contains(routePath, "{") {
+		return false
+	}
+
 	if strings.HasPrefix(requestPath, routePath) &&
 		(strings.HasSuffix(routePath, "/") ||
 			len(requestPath) > len(routePath) && requestPath[len(routePath)] == '/') {
@@ -168,3 +388,59 @@ func (r *Router) matchPath(routePath, requestPath string) bool {
 
 	return false
 }
+
+// matchParams matches "/users/{userId}/posts" against "/users/7/posts".
+func matchParams(routePath, requestPath string) (map[string]string, bool) {
+	if !strings.Contains(routePath, "{") {
+		return nil, false
+	}
+
+	routeParts := strings.Split(strings.Trim(routePath, "/"), "/")
+	requestParts := strings.Split(strings.Trim(requestPath, "/"), "/")
+	if len(routeParts) != len(requestParts) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, part := range routeParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if requestParts[i] == "" {
+				return nil, false
+			}
+			params[part[1:len(part)-1]] = requestParts[i]
+			continue
+		}
+		if part != requestParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+type contextKey string
+
+const paramsContextKey contextKey = "route_params"
+
+// Param returns the value of a {name} segment of the matched route.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsContextKey).(map[string]string)
+	return params[name]
+}
+
+// ParamValue is Param reporting whether the route has a {name} segment.
+func ParamValue(r *http.Request, name string) (string, bool) {
+	params, _ := r.Context().Value(paramsContextKey).(map[string]string)
+	value, ok := params[name]
+	return value, ok
+}
+
+// Routes returns a copy of the registered routes in registration order,
+// with their group and route middlewares resolved.
+func (r *Router) Routes() []Route {
+	routes := make([]Route, len(r.routes))
+	for i, route := range r.routes {
+		route.Middlewares = route.middlewares()
+		routes[i] = route
+	}
+	return routes
+}
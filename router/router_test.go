@@ -0,0 +1,55 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+
+	"flugo.com/container"
+	"flugo.com/router"
+)
+
+type resourceController struct{}
+
+func (resourceController) GetUsers(w http.ResponseWriter, r *http.Request)                      {}
+func (resourceController) GetById(w http.ResponseWriter, r *http.Request)                       {}
+func (resourceController) GetUsersById(w http.ResponseWriter, r *http.Request)                  {}
+func (resourceController) GetUsersPostsByUserId(w http.ResponseWriter, r *http.Request)         {}
+func (resourceController) GetUsersPostsByUserIdAndId(w http.ResponseWriter, r *http.Request)    {}
+func (resourceController) PostUploadAvatar(w http.ResponseWriter, r *http.Request)              {}
+func (resourceController) GetBypassRulesById(w http.ResponseWriter, r *http.Request)            {}
+func (resourceController) GetStandbyNodes(w http.ResponseWriter, r *http.Request)               {}
+func (resourceController) GetPassersByIdle(w http.ResponseWriter, r *http.Request)              {}
+func (resourceController) GetDevicesByAndroidId(w http.ResponseWriter, r *http.Request)         {}
+func (resourceController) GetBrandsModelsByBrandIdAndId(w http.ResponseWriter, r *http.Request) {}
+
+func TestControllerMethodPaths(t *testing.T) {
+	r := router.NewRouter(container.NewContainer())
+	r.RegisterController(resourceController{}, "/api")
+
+	got := make(map[string]string)
+	for _, route := range r.Routes() {
+		got[route.Path] = route.Method
+	}
+
+	for _, want := range []struct{ method, path string }{
+		{"GET", "/api/users"},
+		{"GET", "/api/{id}"},
+		{"GET", "/api/users/{id}"},
+		{"GET", "/api/users/{userId}/posts"},
+		{"GET", "/api/users/{userId}/posts/{id}"},
+		{"POST", "/api/uploadavatar"},
+		// By only splits as a whole word.
+		{"GET", "/api/bypassrules/{id}"},
+		{"GET", "/api/standbynodes"},
+		{"GET", "/api/passersbyidle"},
+		{"GET", "/api/devices/{androidId}"},
+		{"GET", "/api/brands/{brandId}/models/{id}"},
+	} {
+		if method, ok := got[want.path]; !ok || method != want.method {
+			t.Errorf("no %s %s route; routes: %v", want.method, want.path, got)
+		}
+	}
+	if len(got) != 11 {
+		t.Errorf("got %d routes, want 11: %v", len(got), got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package tasks runs supervised background goroutines. Tasks share a
+// context that is cancelled at shutdown, panics are recovered and logged,
+// and the running tasks can be listed for diagnostics:
+//
+//	tasks.Go("report.rebuild", func(ctx context.Context) error {
+//		return rebuildReports(ctx)
+//	})
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flugo.com/logger"
+)
+
+// Info describes a running task.
+type Info struct {
+	ID      uint64    `json:"id"`
+	Name    string    `json:"name"`
+	Started time.Time `json:"started"`
+}
+
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	running map[uint64]Info
+	nextID  uint64
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[uint64]Info),
+	}
+}
+
+// DefaultManager is ready to use so framework packages can start tasks
+// before the application has configured anything.
+var DefaultManager = NewManager()
+
+// Go runs fn on its own goroutine. fn should return once ctx is done;
+// returning ctx.Err() then is not reported as a failure. Tasks started after
+// Shutdown are not run.
+func (m *Manager) Go(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		logger.Warn("Task %s not started: shutting down", name)
+		return
+	}
+	m.nextID++
+	id := m.nextID
+	m.running[id] = Info{ID: id, Name: name, Started: time.Now()}
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.mu.Lock()
+			delete(m.running, id)
+			m.mu.Unlock()
+		}()
+
+		if err := m.run(fn); err != nil && !(m.ctx.Err() != nil && errors.Is(err, context.Canceled)) {
+			logger.Error("Task %s failed: %v", name, err)
+		}
+	}()
+}
+
+func (m *Manager) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(m.ctx)
+}
+
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.running)
+}
+
+// List returns the running tasks, oldest first.
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	list := make([]Info, 0, len(m.running))
+	for _, info := range m.running {
+		list = append(list, info)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Shutdown cancels the tasks' context and waits for them to return until
+// ctx is done. The error names the tasks still running at the deadline.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		var names []string
+		for _, info := range m.List() {
+			names = append(names, info.Name)
+		}
+		return fmt.Errorf("tasks still running: %s", strings.Join(names, ", "))
+	}
+}
+
+func Go(name string, fn func(ctx context.Context) error) {
+	DefaultManager.Go(name, fn)
+}
+
+func Count() int {
+	return DefaultManager.Count()
+}
+
+func List() []Info {
+	return DefaultManager.List()
+}
+
+func Shutdown(ctx context.Context) error {
+	return DefaultManager.Shutdown(ctx)
+}
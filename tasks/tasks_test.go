@@ -0,0 +1,90 @@
+package tasks_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/tasks"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestListAndShutdown(t *testing.T) {
+	m := tasks.NewManager()
+	m.Go("first", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.Go("second", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	list := m.List()
+	if len(list) != 2 || list[0].Name != "first" || list[1].Name != "second" {
+		t.Fatalf("List() = %+v, want first and second", list)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if n := m.Count(); n != 0 {
+		t.Errorf("Count() = %d after shutdown, want 0", n)
+	}
+}
+
+func TestPanicIsRecovered(t *testing.T) {
+	m := tasks.NewManager()
+	m.Go("boom", func(ctx context.Context) error {
+		panic("boom")
+	})
+	waitFor(t, func() bool { return m.Count() == 0 })
+}
+
+func TestShutdownDeadlineNamesStuckTasks(t *testing.T) {
+	m := tasks.NewManager()
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Shutdown() = %v, want an error naming the stuck task", err)
+	}
+}
+
+func TestGoAfterShutdownDoesNotRun(t *testing.T) {
+	m := tasks.NewManager()
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := make(chan struct{}, 1)
+	m.Go("late", func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	select {
+	case <-ran:
+		t.Error("task started after shutdown ran")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
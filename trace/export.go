@@ -0,0 +1,367 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flugo.com/logger"
+	"flugo.com/tasks"
+)
+
+// Exporter receives ended spans. Export is called on the goroutine that
+// ended the span and must not block; Shutdown sends what is still buffered.
+type Exporter interface {
+	Export(span SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+type exporterHolder struct {
+	Exporter
+}
+
+var exporter atomic.Pointer[exporterHolder]
+
+// SetExporter sends ended spans to e; nil drops them, which is the default.
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&exporterHolder{e})
+}
+
+func currentExporter() Exporter {
+	if h := exporter.Load(); h != nil {
+		return h.Exporter
+	}
+	return nil
+}
+
+// Shutdown flushes and stops the current exporter.
+func Shutdown(ctx context.Context) error {
+	if e := currentExporter(); e != nil {
+		return e.Shutdown(ctx)
+	}
+	return nil
+}
+
+// LogExporter writes each span as an INFO log line.
+type LogExporter struct{}
+
+func NewLogExporter() *LogExporter {
+	return &LogExporter{}
+}
+
+func (e *LogExporter) Export(span SpanData) {
+	fields := map[string]interface{}{
+		"trace_id":    span.TraceID.String(),
+		"span_id":     span.SpanID.String(),
+		"span_kind":   span.Kind.String(),
+		"duration_ms": float64(span.Duration().Microseconds()) / 1000,
+	}
+	if span.ParentID.IsValid() {
+		fields["parent_id"] = span.ParentID.String()
+	}
+	if len(span.Attributes) > 0 {
+		fields["attributes"] = span.Attributes
+	}
+	if span.Error != "" {
+		fields["error"] = span.Error
+	}
+	logger.With(fields).Info("Span %s took %v", span.Name, span.Duration())
+}
+
+func (e *LogExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+const (
+	defaultServiceName   = "flugo"
+	defaultBatchSize     = 256
+	defaultMaxQueue      = 4096
+	defaultFlushInterval = 5 * time.Second
+	defaultExportTimeout = 10 * time.Second
+)
+
+// OTLPOptions configures an OTLPExporter. Zero values pick the defaults.
+type OTLPOptions struct {
+	// Endpoint is the collector's traces URL, such as
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+	// ServiceName is reported as the service.name resource attribute.
+	// Defaults to "flugo".
+	ServiceName string
+	// Headers are sent with every export, for example an API key.
+	Headers map[string]string
+	// BatchSize is the most spans sent per request. Defaults to 256.
+	BatchSize int
+	// MaxQueue bounds the spans waiting to be sent; spans ended while it
+	// is full are dropped. Defaults to 4096.
+	MaxQueue int
+	// FlushInterval is how often pending spans are sent. Defaults to 5s.
+	FlushInterval time.Duration
+	// Client sends the requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector using
+// OTLP over HTTP with JSON encoding.
+type OTLPExporter struct {
+	opts OTLPOptions
+
+	mu      sync.Mutex
+	pending []SpanData
+	closed  bool
+	dropped atomic.Int64
+
+	sendMu sync.Mutex
+	kick   chan struct{}
+}
+
+// NewOTLPExporter starts an exporter whose flush loop runs as a background
+// task.
+func NewOTLPExporter(opts OTLPOptions) *OTLPExporter {
+	if opts.ServiceName == "" {
+		opts.ServiceName = defaultServiceName
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.MaxQueue <= 0 {
+		opts.MaxQueue = defaultMaxQueue
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: defaultExportTimeout}
+	}
+
+	e := &OTLPExporter{opts: opts, kick: make(chan struct{}, 1)}
+	tasks.Go("trace.otlp", e.run)
+	return e
+}
+
+func (e *OTLPExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed || len(e.pending) >= e.opts.MaxQueue {
+		e.dropped.Add(1)
+		return
+	}
+	e.pending = append(e.pending, span)
+	if len(e.pending) >= e.opts.BatchSize {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of spans discarded because the queue was full
+// or the exporter was shut down.
+func (e *OTLPExporter) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+func (e *OTLPExporter) run(ctx context.Context) error {
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.kick:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := e.Flush(ctx); err != nil {
+			logger.Warn("Trace export failed: %v", err)
+		}
+	}
+}
+
+// Flush sends every pending span.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := min(len(spans), e.opts.BatchSize)
+		if err := e.send(ctx, spans[:n]); err != nil {
+			e.dropped.Add(int64(len(spans)))
+			return err
+		}
+		spans = spans[n:]
+	}
+	return nil
+}
+
+// Shutdown sends the pending spans; spans ended afterwards are dropped.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	return e.Flush(ctx)
+}
+
+func (e *OTLPExporter) send(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.opts.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector answered %d for %d spans", resp.StatusCode, len(spans))
+	}
+	return nil
+}
+
+// The otlp types follow the OTLP/JSON encoding of ExportTraceServiceRequest.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const otlpStatusError = 2
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *OTLPExporter) request(spans []SpanData) otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		out[i] = otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              int(span.Kind) + 1,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.ParentID.IsValid() {
+			out[i].ParentSpanID = span.ParentID.String()
+		}
+		if span.Error != "" {
+			out[i].Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+			"service.name": e.opts.ServiceName,
+		})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "flugo.com/trace"}, Spans: out}},
+	}}}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, len(keys))
+	for i, key := range keys {
+		kvs[i] = otlpKeyValue{Key: key, Value: otlpValue(attrs[key])}
+	}
+	return kvs
+}
+
+func otlpValue(v interface{}) otlpAnyValue {
+	var value otlpAnyValue
+	switch val := v.(type) {
+	case bool:
+		value.BoolValue = &val
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(val)
+		value.IntValue = &s
+	case float32:
+		f := float64(val)
+		value.DoubleValue = &f
+	case float64:
+		value.DoubleValue = &val
+	case string:
+		value.StringValue = &val
+	default:
+		s := fmt.Sprint(val)
+		value.StringValue = &s
+	}
+	return value
+}
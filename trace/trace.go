@@ -0,0 +1,319 @@
+// Package trace records spans compatible with W3C Trace Context. A request
+// carries its trace on the context; work done for it starts child spans,
+// and finished spans go to the configured exporter:
+//
+//	ctx, span := trace.Start(r.Context(), "report.build")
+//	defer span.End()
+//	span.SetAttribute("report.rows", len(rows))
+//
+// Framework packages use Child, which does nothing when the context carries
+// no trace, so untraced work costs nothing.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header is the W3C header carrying the trace across processes.
+const Header = "traceparent"
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats sc as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent reads a traceparent header value. Versions after 00 are
+// accepted as long as they start with the version 00 fields.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, fmt.Errorf("malformed traceparent %q", value)
+	}
+
+	version, ok := decodeHex(value[:2], 1)
+	if !ok || version[0] == 0xff || version[0] == 0 && len(value) != 55 || len(value) > 55 && value[55] != '-' {
+		return sc, fmt.Errorf("unsupported traceparent version in %q", value)
+	}
+
+	traceID, ok := decodeHex(value[3:35], 16)
+	if !ok {
+		return sc, fmt.Errorf("invalid trace ID in %q", value)
+	}
+	spanID, ok := decodeHex(value[36:52], 8)
+	if !ok {
+		return sc, fmt.Errorf("invalid parent ID in %q", value)
+	}
+	flags, ok := decodeHex(value[53:55], 1)
+	if !ok {
+		return sc, fmt.Errorf("invalid trace flags in %q", value)
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent %q has an all-zero ID", value)
+	}
+	return sc, nil
+}
+
+// decodeHex accepts lowercase hex only, as the header format requires.
+func decodeHex(s string, size int) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil && len(b) == size
+}
+
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota
+	KindServer
+	KindClient
+	KindProducer
+	KindConsumer
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	case KindProducer:
+		return "producer"
+	case KindConsumer:
+		return "consumer"
+	}
+	return "internal"
+}
+
+// SpanData is a snapshot of a span, as handed to exporters.
+type SpanData struct {
+	Name       string
+	Kind       SpanKind
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	// Error describes the failure recorded on the span, if any.
+	Error string
+}
+
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Span is one timed operation. A nil *Span is valid and does nothing, so
+// code can use the span returned by Child without checking it.
+type Span struct {
+	mu      sync.Mutex
+	data    SpanData
+	sampled bool
+	ended   bool
+}
+
+type StartOption func(*Span)
+
+func WithKind(kind SpanKind) StartOption {
+	return func(s *Span) {
+		s.data.Kind = kind
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithSpan returns ctx with span as the parent of spans started
+// from it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemote returns ctx with sc, read from another process, as the
+// parent of spans started from it.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the context of the current span, or of
+// the remote parent when no span was started yet.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a span as a child of the span on ctx, or of a remote parent,
+// or as the root of a new trace when ctx has neither. The returned context
+// carries the span.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{data: SpanData{Name: name, Start: time.Now()}}
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.data.TraceID = parent.TraceID
+		span.data.ParentID = parent.SpanID
+		span.sampled = parent.Sampled
+	} else {
+		rand.Read(span.data.TraceID[:])
+		span.sampled = true
+	}
+	rand.Read(span.data.SpanID[:])
+
+	for _, opt := range opts {
+		opt(span)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Child starts a span only when ctx already carries a trace. Otherwise it
+// returns ctx unchanged and a nil span.
+func Child(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if !SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	return Start(ctx, name, opts...)
+}
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID, Sampled: s.sampled}
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End records the span's duration and exports it when the trace is
+// sampled. Calls after the first do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.mu.Unlock()
+
+	if exporter := currentExporter(); exporter != nil && s.sampled {
+		exporter.Export(s.Data())
+	}
+}
+
+// Duration is the span's duration once ended, and the time since it
+// started before that.
+func (s *Span) Duration() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		return time.Since(s.data.Start)
+	}
+	return s.data.Duration()
+}
+
+func (s *Span) Data() SpanData {
+	if s == nil {
+		return SpanData{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := s.data
+	if s.data.Attributes != nil {
+		data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+		for k, v := range s.data.Attributes {
+			data.Attributes[k] = v
+		}
+	}
+	return data
+}
@@ -0,0 +1,304 @@
+package trace_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"flugo.com/cache"
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/httpclient"
+	"flugo.com/middleware"
+	"flugo.com/queue"
+	"flugo.com/trace"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+}
+
+func (r *recorder) Export(span trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func (r *recorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func (r *recorder) byName(t *testing.T, name string) trace.SpanData {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, span := range r.spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	names := make([]string, len(r.spans))
+	for i, span := range r.spans {
+		names[i] = span.Name
+	}
+	t.Fatalf("no span %q among %v", name, names)
+	return trace.SpanData{}
+}
+
+func record(t *testing.T) *recorder {
+	rec := &recorder{}
+	trace.SetExporter(rec)
+	t.Cleanup(func() { trace.SetExporter(nil) })
+	return rec
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := trace.ParseTraceparent(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("ParseTraceparent = %+v", sc)
+	}
+	if got := sc.Traceparent(); got != header {
+		t.Errorf("Traceparent() = %s, want %s", got, header)
+	}
+
+	if _, err := trace.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil {
+		t.Errorf("later version rejected: %v", err)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := trace.ParseTraceparent(bad); err == nil {
+			t.Errorf("ParseTraceparent(%q) accepted", bad)
+		}
+	}
+}
+
+func TestStartAndChild(t *testing.T) {
+	rec := record(t)
+
+	if ctx, span := trace.Child(context.Background(), "untraced"); span != nil || ctx != context.Background() {
+		t.Fatal("Child started a span without a trace")
+	}
+
+	ctx, root := trace.Start(context.Background(), "root")
+	_, child := trace.Child(ctx, "child")
+	child.SetAttribute("rows", 3)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End()
+	root.End()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(rec.spans))
+	}
+	c, r := rec.byName(t, "child"), rec.byName(t, "root")
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID || r.ParentID.IsValid() {
+		t.Errorf("child %+v is not linked to root %+v", c, r)
+	}
+	if c.Attributes["rows"] != 3 || c.Error != "boom" || c.Duration() <= 0 {
+		t.Errorf("child = %+v", c)
+	}
+}
+
+func TestUnsampledTraceIsNotExported(t *testing.T) {
+	rec := record(t)
+	sc, _ := trace.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	_, span := trace.Start(trace.ContextWithRemote(context.Background(), sc), "work")
+	span.End()
+	if len(rec.spans) != 0 {
+		t.Errorf("exported %d spans of an unsampled trace", len(rec.spans))
+	}
+	if got := span.Context().Traceparent(); got[:36] != "00-4bf92f3577b34da6a3ce929d0e0e4736-" || got[52:] != "-00" {
+		t.Errorf("child traceparent = %s, want the same trace, unsampled", got)
+	}
+}
+
+// TestRequestTrace follows one request through a cache load, a query, an
+// outbound call and a queued job, all in the trace of the caller.
+func TestRequestTrace(t *testing.T) {
+	rec := record(t)
+
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "trace.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var downstream string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Get(trace.Header)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := cache.New(10, time.Minute)
+	t.Cleanup(c.Stop)
+
+	q := queue.NewQueue("trace-test", 1)
+	q.Start()
+	t.Cleanup(q.Stop)
+	done := make(chan error, 1)
+	q.RegisterHandler("report", func(job *queue.Job) error {
+		_, err := db.Query().WithContext(job.Context()).Table("items").Count()
+		done <- err
+		return err
+	})
+
+	handler := middleware.Trace()(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		_, err := c.GetOrSetE("items:count", func() (interface{}, error) {
+			return db.Query().WithContext(ctx).Table("items").Count()
+		}, cache.Options{Context: ctx})
+		if err == nil {
+			_, err = httpclient.New(httpclient.Options{}).Get(ctx, srv.URL)
+		}
+		if err == nil {
+			err = q.PushContext(ctx, "report", map[string]interface{}{"id": 1}, 1)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set(trace.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), req)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not run")
+	}
+	// The job's span ends after its handler returns.
+	time.Sleep(20 * time.Millisecond)
+
+	server := rec.byName(t, "GET /items")
+	if server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span %+v did not continue the incoming trace", server)
+	}
+	if server.Kind != trace.KindServer || server.Attributes["http.status_code"] != http.StatusOK {
+		t.Errorf("server span = %+v", server)
+	}
+
+	parents := map[string]string{
+		"cache.load":           "GET /items",
+		"HTTP GET":             "GET /items",
+		"queue.push report":    "GET /items",
+		"queue.process report": "queue.push report",
+	}
+	for name, parent := range parents {
+		span, want := rec.byName(t, name), rec.byName(t, parent)
+		if span.TraceID != server.TraceID || span.ParentID != want.SpanID {
+			t.Errorf("%s has parent %s, want %s", name, span.ParentID, parent)
+		}
+	}
+
+	client := rec.byName(t, "HTTP GET")
+	if downstream != (trace.SpanContext{TraceID: client.TraceID, SpanID: client.SpanID, Sampled: true}).Traceparent() {
+		t.Errorf("downstream got traceparent %q, want the client span's", downstream)
+	}
+
+	var queries int
+	for _, span := range rec.spans {
+		if span.Name == "SELECT items" {
+			queries++
+			if span.Attributes["db.system"] != "sqlite3" || span.Attributes["db.statement"] == nil {
+				t.Errorf("query span attributes = %v", span.Attributes)
+			}
+		}
+	}
+	if queries != 2 {
+		t.Errorf("recorded %d query spans, want one from the request and one from the job", queries)
+	}
+}
+
+func TestOTLPExporterBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil || r.Header.Get("X-Key") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		batches = append(batches, payload)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter := trace.NewOTLPExporter(trace.OTLPOptions{
+		Endpoint:      srv.URL,
+		ServiceName:   "orders",
+		Headers:       map[string]string{"X-Key": "secret"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	trace.SetExporter(exporter)
+	t.Cleanup(func() { trace.SetExporter(nil) })
+
+	ctx, root := trace.Start(context.Background(), "root")
+	_, child := trace.Start(ctx, "child", trace.WithKind(trace.KindClient))
+	child.SetAttribute("db.rows", 2)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+	_, last := trace.Start(context.Background(), "last")
+	last.End()
+
+	if err := trace.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var spans []interface{}
+	for _, batch := range batches {
+		rs := batch["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		attr := rs["resource"].(map[string]interface{})["attributes"].([]interface{})[0].(map[string]interface{})
+		if attr["key"] != "service.name" || attr["value"].(map[string]interface{})["stringValue"] != "orders" {
+			t.Errorf("resource attribute = %v", attr)
+		}
+		spans = append(spans, rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})...)
+	}
+	if len(batches) != 2 || len(spans) != 3 {
+		t.Fatalf("sent %d spans in %d batches, want 3 in 2", len(spans), len(batches))
+	}
+
+	first := spans[0].(map[string]interface{})
+	if first["name"] != "child" || first["kind"] != float64(3) || first["parentSpanId"] == nil {
+		t.Errorf("child span = %v", first)
+	}
+	if status := first["status"].(map[string]interface{}); status["code"] != float64(2) || status["message"] != "boom" {
+		t.Errorf("child status = %v", status)
+	}
+	attr := first["attributes"].([]interface{})[0].(map[string]interface{})
+	if attr["value"].(map[string]interface{})["intValue"] != "2" {
+		t.Errorf("child attribute = %v", attr)
+	}
+}
@@ -0,0 +1,188 @@
+package upload_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"flugo.com/config"
+	"flugo.com/imaging"
+	"flugo.com/upload"
+)
+
+// photo encodes a w x h JPEG, white with a red quarter in the corner given
+// by right and bottom, carrying an EXIF orientation and a GPS position.
+func photo(t *testing.T, w, h int, right, bottom bool, orientation uint16, extra ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			inX := (x < w/2) != right
+			inY := (y < h/2) != bottom
+			if inX && inY {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	// IFD0 holds the orientation and a pointer to a GPS IFD at offset 38.
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(&tiff, le, []uint16{42})
+	binary.Write(&tiff, le, []uint32{8})
+	binary.Write(&tiff, le, []uint16{2, 0x0112, 3})
+	binary.Write(&tiff, le, []uint32{1})
+	binary.Write(&tiff, le, []uint16{orientation, 0, 0x8825, 4})
+	binary.Write(&tiff, le, []uint32{1, 38, 0})
+	binary.Write(&tiff, le, []uint16{1, 0x0001, 2})
+	binary.Write(&tiff, le, []uint32{2})
+	tiff.WriteString("N\x00\x00\x00")
+	binary.Write(&tiff, le, []uint32{0})
+
+	segments := [][]byte{append([]byte("Exif\x00\x00"), tiff.Bytes()...)}
+	segments = append(segments, extra...)
+
+	out := []byte{0xff, 0xd8}
+	for _, s := range segments {
+		marker := byte(0xe1)
+		if bytes.HasPrefix(s, []byte("ICC_PROFILE")) {
+			marker = 0xe2
+		}
+		out = append(out, 0xff, marker, byte((len(s)+2)>>8), byte(len(s)+2))
+		out = append(out, s...)
+	}
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func uploadJPEG(t *testing.T, svc *upload.UploadService, data []byte) []byte {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "photo.jpg")
+	part.Write(data)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	result, err := svc.HandleUpload(r, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(result.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Size != int64(len(stored)) {
+		t.Errorf("result size %d, stored %d bytes", result.Size, len(stored))
+	}
+	return stored
+}
+
+func jpegService(t *testing.T, cfg config.UploadConfig) *upload.UploadService {
+	cfg.MaxFileSize = 1 << 20
+	cfg.UploadPath = t.TempDir()
+	return upload.NewUploadService(&cfg)
+}
+
+func TestAutoOrientAndStrip(t *testing.T) {
+	svc := jpegService(t, config.UploadConfig{AutoOrient: true, StripMetadata: true})
+
+	// Where the red quarter is stored so that it shows top left once the
+	// orientation is applied to a 32x16 photo.
+	for orientation, stored := range map[uint16]struct {
+		w, h          int
+		right, bottom bool
+	}{
+		1: {32, 16, false, false},
+		2: {32, 16, true, false},
+		3: {32, 16, true, true},
+		4: {32, 16, false, true},
+		5: {16, 32, false, false},
+		6: {16, 32, false, true},
+		7: {16, 32, true, true},
+		8: {16, 32, true, false},
+	} {
+		out := uploadJPEG(t, svc, photo(t, stored.w, stored.h, stored.right, stored.bottom, orientation))
+
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("orientation %d: %v", orientation, err)
+		}
+		if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 16 {
+			t.Errorf("orientation %d: %dx%d, want 32x16", orientation, b.Dx(), b.Dy())
+		}
+		if r, g, _, _ := img.At(4, 4).RGBA(); r < 0xc000 || g > 0x4000 {
+			t.Errorf("orientation %d: top left is not red", orientation)
+		}
+		if r, g, _, _ := img.At(27, 11).RGBA(); r < 0xc000 || g < 0xc000 {
+			t.Errorf("orientation %d: bottom right is not white", orientation)
+		}
+		if bytes.Contains(out, []byte("Exif")) || imaging.JPEGOrientation(out) != 1 {
+			t.Errorf("orientation %d: EXIF with the GPS position was kept", orientation)
+		}
+	}
+}
+
+func TestAutoOrientKeepsMetadata(t *testing.T) {
+	svc := jpegService(t, config.UploadConfig{AutoOrient: true})
+
+	out := uploadJPEG(t, svc, photo(t, 16, 32, false, true, 6))
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(out)); err != nil || cfg.Width != 32 {
+		t.Errorf("config = %+v, %v; want turned to 32 wide", cfg, err)
+	}
+	if imaging.JPEGOrientation(out) != 1 || !bytes.Contains(out, []byte("Exif")) {
+		t.Error("EXIF should be kept with the orientation reset")
+	}
+}
+
+func TestStripKeepsICCProfile(t *testing.T) {
+	icc := []byte("ICC_PROFILE\x00\x01\x01fake profile")
+	in := photo(t, 32, 16, false, false, 1, icc)
+
+	out := uploadJPEG(t, jpegService(t, config.UploadConfig{StripMetadata: true, KeepICCProfile: true}), in)
+	if !bytes.Contains(out, icc) || bytes.Contains(out, []byte("Exif")) {
+		t.Error("stripping with KeepICCProfile should keep only the profile")
+	}
+	// Without turning, stripping does not re-encode the pixels.
+	if !bytes.HasSuffix(in, out[bytes.Index(out, []byte{0xff, 0xdb}):]) {
+		t.Error("pixel data changed")
+	}
+
+	out = uploadJPEG(t, jpegService(t, config.UploadConfig{StripMetadata: true}), in)
+	if bytes.Contains(out, icc) {
+		t.Error("profile kept without KeepICCProfile")
+	}
+}
+
+func TestNonJPEGUntouched(t *testing.T) {
+	svc := jpegService(t, config.UploadConfig{AutoOrient: true, StripMetadata: true})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("Exif\x00\x00 is just text here"))
+	mw.Close()
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	result, err := svc.HandleUpload(r, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(result.Path); string(data) != "Exif\x00\x00 is just text here" {
+		t.Errorf("stored %q", data)
+	}
+}
@@ -0,0 +1,298 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flugo.com/auth"
+	"flugo.com/cache"
+	"flugo.com/database"
+	"flugo.com/database/schema"
+	"flugo.com/logger"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// ErrQuotaExceeded is returned, wrapped in a *QuotaError, when an upload
+// would take a user over their storage quota. Nothing is written.
+var ErrQuotaExceeded = errors.New("upload quota exceeded")
+
+type QuotaError struct {
+	UserID int
+	Usage  int64
+	Quota  int64
+	Size   int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: user %d uses %d of %d bytes, upload needs %d", ErrQuotaExceeded, e.UserID, e.Usage, e.Quota, e.Size)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaFunc returns the quota of a user, in bytes. Returning false applies
+// the default quota.
+type QuotaFunc func(userID int) (int64, bool)
+
+// usageTTL bounds how long a cached usage total may be served.
+const usageTTL = 5 * time.Minute
+
+func init() {
+	response.RegisterErrorMapper(func(err error) (response.ErrorMapping, bool) {
+		var quotaErr *QuotaError
+		if !errors.As(err, &quotaErr) {
+			return response.ErrorMapping{}, false
+		}
+		return response.ErrorMapping{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    "quota_exceeded",
+			Message: "Storage quota exceeded",
+			Errors: map[string]interface{}{
+				"usage": quotaErr.Usage,
+				"quota": quotaErr.Quota,
+				"size":  quotaErr.Size,
+			},
+		}, true
+	})
+}
+
+// SetQuota sets the default quota per user in bytes; 0 disables quotas.
+func (u *UploadService) SetQuota(bytes int64) {
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+	u.quota = bytes
+}
+
+// SetQuotaFunc resolves per-user quotas, e.g. from the user's plan.
+func (u *UploadService) SetQuotaFunc(fn QuotaFunc) {
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+	u.quotaFunc = fn
+}
+
+// Migration creates the uploads table that records each file's owner and
+// size. Register it with database.RegisterMigration and run the migrations
+// before enabling quotas.
+var Migration = schema.Migration("20240201000003", "create_uploads",
+	schema.CreateIfNotExists("uploads", func(t *schema.Table) {
+		t.String("file_name", 255)
+		t.Integer("user_id")
+		t.BigInteger("size")
+		t.String("mime_type", 255).Nullable()
+		t.Timestamp("created_at")
+		t.Primary("file_name")
+	}),
+	schema.DropIfExists("uploads"))
+
+// SetDB sets the database holding the uploads table. DefaultDB is used
+// otherwise.
+func (u *UploadService) SetDB(db *database.DB) {
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+	u.db = db
+}
+
+// QuotaFor returns the quota of a user in bytes, 0 meaning unlimited.
+func (u *UploadService) QuotaFor(userID int) int64 {
+	u.quotaMu.Lock()
+	quota, fn := u.quota, u.quotaFunc
+	u.quotaMu.Unlock()
+
+	if fn != nil {
+		if override, ok := fn(userID); ok {
+			return override
+		}
+	}
+	return quota
+}
+
+// UsageFor returns the bytes stored by a user, from the uploads table. The
+// total is cached until the user uploads or deletes a file.
+func (u *UploadService) UsageFor(userID int) (int64, error) {
+	key := usageKey(userID)
+	if cached, ok := cache.Get(key); ok {
+		if usage, ok := cached.(int64); ok {
+			return usage, nil
+		}
+	}
+
+	db, err := u.metaDB()
+	if err != nil {
+		return 0, err
+	}
+
+	var usage int64
+	if err := db.QueryRow("SELECT COALESCE(SUM(size), 0) FROM uploads WHERE user_id = ?", userID).Scan(&usage); err != nil {
+		return 0, fmt.Errorf("failed to compute upload usage: %w", err)
+	}
+	cache.Set(key, usage, usageTTL)
+	return usage, nil
+}
+
+// reserve checks that size more bytes fit in the user's quota and holds
+// them until release, so concurrent uploads cannot overshoot it together.
+func (u *UploadService) reserve(userID int, size int64) error {
+	quota := u.QuotaFor(userID)
+	if userID == 0 || quota <= 0 {
+		return nil
+	}
+
+	usage, err := u.UsageFor(userID)
+	if err != nil {
+		return err
+	}
+
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+	usage += u.reserved[userID]
+	if usage+size > quota {
+		return &QuotaError{UserID: userID, Usage: usage, Quota: quota, Size: size}
+	}
+	u.reserved[userID] += size
+	return nil
+}
+
+func (u *UploadService) release(userID int, size int64) {
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+	if u.reserved[userID] -= size; u.reserved[userID] <= 0 {
+		delete(u.reserved, userID)
+	}
+}
+
+// recordUpload stores the owner and size of a file in the uploads table.
+// Without a database or the table, files are stored untracked unless a
+// quota applies to the user.
+func (u *UploadService) recordUpload(userID int, result *UploadResult) error {
+	db, err := u.metaDB()
+	if err == nil {
+		_, err = db.Exec("INSERT INTO uploads (file_name, user_id, size, mime_type, created_at) VALUES (?, ?, ?, ?, ?)",
+			result.FileName, userID, result.Size, result.MimeType, result.UploadedAt)
+		if err != nil {
+			err = fmt.Errorf("failed to record upload: %w", err)
+		}
+	}
+	if err != nil {
+		if u.QuotaFor(userID) > 0 {
+			return err
+		}
+		if !errors.Is(err, database.ErrNotInitialized) {
+			logger.Warn("Upload %s stored untracked: %v", result.FileName, err)
+		}
+		return nil
+	}
+	cache.Delete(usageKey(userID))
+	return nil
+}
+
+// forgetUpload removes a deleted file from the uploads table, which lowers
+// its owner's usage. Failures only matter while quotas are enabled.
+func (u *UploadService) forgetUpload(fileName string) error {
+	err := u.deleteRecord(fileName)
+	if err == nil || errors.Is(err, database.ErrNotInitialized) {
+		return nil
+	}
+	if u.quotasEnabled() {
+		return err
+	}
+	logger.Warn("Upload record of %s not deleted: %v", fileName, err)
+	return nil
+}
+
+func (u *UploadService) deleteRecord(fileName string) error {
+	db, err := u.metaDB()
+	if err != nil {
+		return err
+	}
+
+	var userID int
+	err = db.QueryRow("SELECT user_id FROM uploads WHERE file_name = ?", fileName).Scan(&userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up upload: %w", err)
+	}
+
+	if _, err := db.Exec("DELETE FROM uploads WHERE file_name = ?", fileName); err != nil {
+		return fmt.Errorf("failed to delete upload record: %w", err)
+	}
+	cache.Delete(usageKey(userID))
+	return nil
+}
+
+func (u *UploadService) quotasEnabled() bool {
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+	return u.quota > 0 || u.quotaFunc != nil
+}
+
+// metaDB returns the database of the uploads table.
+func (u *UploadService) metaDB() (*database.DB, error) {
+	u.quotaMu.Lock()
+	defer u.quotaMu.Unlock()
+
+	if u.db != nil {
+		return u.db, nil
+	}
+	if database.DefaultDB == nil {
+		return nil, database.ErrNotInitialized
+	}
+	return database.DefaultDB, nil
+}
+
+func usageKey(userID int) string {
+	return "upload:usage:" + strconv.Itoa(userID)
+}
+
+// UsageHandler serves the storage usage and quota of the current user:
+//
+//	r.GET("/uploads/usage", upload.UsageHandler(), auth.RequireAuth())
+func UsageHandler() router.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if DefaultUploadService == nil {
+			response.ServiceUnavailable(w, "Upload service not initialized")
+			return
+		}
+
+		userID := auth.GetCurrentUserID(r)
+		if userID == 0 {
+			response.Unauthorized(w)
+			return
+		}
+
+		usage, err := DefaultUploadService.UsageFor(userID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		data := map[string]interface{}{
+			"usage": usage,
+			"quota": nil,
+		}
+		if quota := DefaultUploadService.QuotaFor(userID); quota > 0 {
+			data["quota"] = quota
+			data["remaining"] = max(quota-usage, 0)
+		}
+		response.Success(w, data)
+	}
+}
+
+func UsageFor(userID int) (int64, error) {
+	if DefaultUploadService == nil {
+		return 0, fmt.Errorf("upload service not initialized")
+	}
+	return DefaultUploadService.UsageFor(userID)
+}
+
+func SetQuotaFunc(fn QuotaFunc) {
+	if DefaultUploadService != nil {
+		DefaultUploadService.SetQuotaFunc(fn)
+	}
+}
@@ -0,0 +1,139 @@
+package upload_test
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flugo.com/auth"
+	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/reqctx"
+	"flugo.com/response"
+	"flugo.com/upload"
+)
+
+func newService(t *testing.T, quota int64) *upload.UploadService {
+	t.Helper()
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "uploads.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	database.RegisterMigration(upload.Migration)
+	if _, err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := upload.NewUploadService(&config.UploadConfig{
+		MaxFileSize: 1 << 20,
+		UploadPath:  t.TempDir(),
+		Quota:       quota,
+	})
+	svc.SetDB(db)
+	return svc
+}
+
+func uploadRequest(userID int, contents ...string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, content := range contents {
+		part, _ := mw.CreateFormFile("file", "note"+strings.Repeat("x", i)+".txt")
+		part.Write([]byte(content))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return reqctx.WithClaims(r, &auth.Claims{UserID: userID})
+}
+
+func TestQuotaBoundary(t *testing.T) {
+	svc := newService(t, 10)
+
+	if _, err := svc.HandleUpload(uploadRequest(1, "123456"), "file"); err != nil {
+		t.Fatal(err)
+	}
+	// Exactly reaching the quota is allowed.
+	second, err := svc.HandleUpload(uploadRequest(1, "7890"), "file")
+	if err != nil {
+		t.Fatalf("upload filling the quota exactly: %v", err)
+	}
+
+	_, err = svc.HandleUpload(uploadRequest(1, "!"), "file")
+	var quotaErr *upload.QuotaError
+	if !errors.Is(err, upload.ErrQuotaExceeded) || !errors.As(err, &quotaErr) || quotaErr.Usage != 10 || quotaErr.Size != 1 {
+		t.Fatalf("upload over the quota = %v", err)
+	}
+	if files, _ := svc.ListFiles(); len(files) != 2 {
+		t.Errorf("stored %d files, want the rejected one not written", len(files))
+	}
+
+	if _, err := svc.HandleUpload(uploadRequest(2, "other"), "file"); err != nil {
+		t.Errorf("other user's upload: %v", err)
+	}
+
+	if err := svc.DeleteFile(second.FileName); err != nil {
+		t.Fatal(err)
+	}
+	if usage, err := svc.UsageFor(1); err != nil || usage != 6 {
+		t.Errorf("usage after delete = %d, %v; want 6", usage, err)
+	}
+	if _, err := svc.HandleUpload(uploadRequest(1, "abcd"), "file"); err != nil {
+		t.Errorf("upload after freeing space: %v", err)
+	}
+}
+
+func TestQuotaOverridesAndBatches(t *testing.T) {
+	svc := newService(t, 4)
+	svc.SetQuotaFunc(func(userID int) (int64, bool) {
+		return 8, userID == 7
+	})
+
+	if _, err := svc.HandleMultipleUploads(uploadRequest(7, "1234", "5678"), "file"); err != nil {
+		t.Errorf("batch within the override: %v", err)
+	}
+
+	_, err := svc.HandleMultipleUploads(uploadRequest(3, "123", "45"), "file")
+	if !errors.Is(err, upload.ErrQuotaExceeded) {
+		t.Fatalf("batch over the default quota = %v", err)
+	}
+	if usage, _ := svc.UsageFor(3); usage != 0 {
+		t.Errorf("usage = %d, want no file of the rejected batch written", usage)
+	}
+
+	w := httptest.NewRecorder()
+	response.WriteError(w, err)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), `"code":"quota_exceeded"`) {
+		t.Errorf("quota error response = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUploadsWithoutTable(t *testing.T) {
+	db, err := database.NewDB(&config.DatabaseConfig{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "plain.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	svc := upload.NewUploadService(&config.UploadConfig{MaxFileSize: 1 << 20, UploadPath: t.TempDir()})
+	svc.SetDB(db)
+
+	// Without quotas the uploads table is optional.
+	result, err := svc.HandleUpload(uploadRequest(1, "hello"), "file")
+	if err != nil {
+		t.Fatalf("upload without the uploads table: %v", err)
+	}
+	if err := svc.DeleteFile(result.FileName); err != nil {
+		t.Errorf("delete without the uploads table: %v", err)
+	}
+
+	svc.SetQuota(100)
+	if _, err := svc.HandleUpload(uploadRequest(1, "hello"), "file"); err == nil {
+		t.Error("quota enforced without the uploads table")
+	}
+}
@@ -1,37 +1,77 @@
 package upload
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"flugo.com/auth"
+	"flugo.com/cache"
 	"flugo.com/config"
+	"flugo.com/database"
+	"flugo.com/events"
+	"flugo.com/imaging"
 	"flugo.com/logger"
+	"flugo.com/queue"
 )
 
+// EventCompleted is emitted with the UploadResult of every stored file.
+const EventCompleted = "upload.completed"
+
+// ErrMIMEMismatch is returned when the sniffed content of a file is not one of
+// the allowed types, regardless of the Content-Type the client declared.
+var ErrMIMEMismatch = errors.New("file content does not match an allowed type")
+
 type UploadResult struct {
-	FileName     string    `json:"file_name"`
-	OriginalName string    `json:"original_name"`
-	Size         int64     `json:"size"`
-	MimeType     string    `json:"mime_type"`
-	Path         string    `json:"path"`
-	URL          string    `json:"url"`
-	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
-	Extension    string    `json:"extension"`
-	UploadedAt   time.Time `json:"uploaded_at"`
+	FileName        string                 `json:"file_name"`
+	OriginalName    string                 `json:"original_name"`
+	Size            int64                  `json:"size"`
+	MimeType        string                 `json:"mime_type"`
+	Path            string                 `json:"path"`
+	URL             string                 `json:"url"`
+	ThumbnailURL    string                 `json:"thumbnail_url,omitempty"`
+	ThumbnailStatus ThumbnailStatus        `json:"thumbnail_status,omitempty"`
+	Extension       string                 `json:"extension"`
+	UploadedAt      time.Time              `json:"uploaded_at"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
 }
 
+type ThumbnailStatus string
+
+const (
+	ThumbnailPending ThumbnailStatus = "pending"
+	ThumbnailReady   ThumbnailStatus = "ready"
+	ThumbnailFailed  ThumbnailStatus = "failed"
+	ThumbnailNone    ThumbnailStatus = "none"
+)
+
+// PostProcessor runs after a file has been stored and may enrich the result,
+// typically through Metadata. Errors are logged and do not fail the upload.
+type PostProcessor func(result *UploadResult) error
+
 type UploadService struct {
-	uploadPath    string
-	maxFileSize   int64
-	allowedTypes  []string
-	enableResize  bool
-	thumbnailSize int
+	uploadPath     string
+	maxFileSize    int64
+	allowedTypes   []string
+	enableResize   bool
+	thumbnailSize  int
+	jpegOptions    imaging.JPEGOptions
+	postProcessors []PostProcessor
+
+	quotaMu   sync.Mutex
+	quota     int64
+	quotaFunc QuotaFunc
+	reserved  map[int]int64
+	db        *database.DB
 }
 
 func NewUploadService(cfg *config.UploadConfig) *UploadService {
@@ -41,6 +81,13 @@ func NewUploadService(cfg *config.UploadConfig) *UploadService {
 		allowedTypes:  cfg.AllowedTypes,
 		enableResize:  cfg.EnableResize,
 		thumbnailSize: cfg.ThumbnailSize,
+		quota:         cfg.Quota,
+		reserved:      make(map[int]int64),
+		jpegOptions: imaging.JPEGOptions{
+			AutoOrient:     cfg.AutoOrient,
+			StripMetadata:  cfg.StripMetadata,
+			KeepICCProfile: cfg.KeepICCProfile,
+		},
 	}
 
 	if err := os.MkdirAll(cfg.UploadPath, 0755); err != nil {
@@ -56,6 +103,10 @@ func Init(cfg *config.UploadConfig) {
 	DefaultUploadService = NewUploadService(cfg)
 }
 
+func (u *UploadService) RegisterPostProcessor(processor PostProcessor) {
+	u.postProcessors = append(u.postProcessors, processor)
+}
+
 func (u *UploadService) HandleUpload(r *http.Request, fieldName string) (*UploadResult, error) {
 	if err := r.ParseMultipartForm(u.maxFileSize); err != nil {
 		return nil, fmt.Errorf("failed to parse multipart form: %w", err)
@@ -76,7 +127,19 @@ func (u *UploadService) HandleUpload(r *http.Request, fieldName string) (*Upload
 		return nil, fmt.Errorf("file type %s is not allowed", mimeType)
 	}
 
-	return u.saveFile(file, handler)
+	userID := auth.GetCurrentUserID(r)
+	if err := u.reserve(userID, handler.Size); err != nil {
+		return nil, err
+	}
+	defer u.release(userID, handler.Size)
+
+	result, err := u.saveFile(file, handler, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emitCompleted(r.Context(), result)
+	return result, nil
 }
 
 func (u *UploadService) HandleMultipleUploads(r *http.Request, fieldName string) ([]*UploadResult, error) {
@@ -89,27 +152,35 @@ func (u *UploadService) HandleMultipleUploads(r *http.Request, fieldName string)
 		return nil, fmt.Errorf("no files found in field %s", fieldName)
 	}
 
-	var results []*UploadResult
+	var accepted []*multipart.FileHeader
+	var total int64
 	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
-		if err != nil {
+		if fileHeader.Size > u.maxFileSize || !u.isAllowedType(fileHeader.Header.Get("Content-Type")) {
 			continue
 		}
+		accepted = append(accepted, fileHeader)
+		total += fileHeader.Size
+	}
 
-		if fileHeader.Size > u.maxFileSize {
-			file.Close()
-			continue
-		}
+	// The batch is checked against the quota as a whole, before any file is
+	// written.
+	userID := auth.GetCurrentUserID(r)
+	if err := u.reserve(userID, total); err != nil {
+		return nil, err
+	}
+	defer u.release(userID, total)
 
-		mimeType := fileHeader.Header.Get("Content-Type")
-		if !u.isAllowedType(mimeType) {
-			file.Close()
+	var results []*UploadResult
+	for _, fileHeader := range accepted {
+		file, err := fileHeader.Open()
+		if err != nil {
 			continue
 		}
 
-		result, err := u.saveFile(file, fileHeader)
+		result, err := u.saveFile(file, fileHeader, userID)
 		file.Close()
 		if err == nil {
+			emitCompleted(r.Context(), result)
 			results = append(results, result)
 		}
 	}
@@ -121,7 +192,7 @@ func (u *UploadService) HandleMultipleUploads(r *http.Request, fieldName string)
 	return results, nil
 }
 
-func (u *UploadService) saveFile(file multipart.File, handler *multipart.FileHeader) (*UploadResult, error) {
+func (u *UploadService) saveFile(file multipart.File, handler *multipart.FileHeader, userID int) (*UploadResult, error) {
 	ext := filepath.Ext(handler.Filename)
 	fileName := u.generateFileName(ext)
 	filePath := filepath.Join(u.uploadPath, fileName)
@@ -130,37 +201,112 @@ func (u *UploadService) saveFile(file multipart.File, handler *multipart.FileHea
 	if err != nil {
 		return nil, fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer dst.Close()
 
 	size, err := io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		os.Remove(filePath)
 		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
 
+	// The declared Content-Type is only a pre-filter; trust the content.
+	declared := handler.Header.Get("Content-Type")
+	detected, err := detectContentType(filePath)
+	if err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to detect file type: %w", err)
+	}
+	if !u.isAllowedType(detected) {
+		logger.Warn("Rejected upload %s: declared MIME %s, detected %s", handler.Filename, declared, detected)
+		os.Remove(filePath)
+		return nil, fmt.Errorf("%w: declared %s, detected %s", ErrMIMEMismatch, declared, detected)
+	}
+
+	if detected == "image/jpeg" {
+		if size, err = u.cleanJPEG(filePath, size); err != nil {
+			os.Remove(filePath)
+			return nil, err
+		}
+	}
+
 	result := &UploadResult{
 		FileName:     fileName,
 		OriginalName: handler.Filename,
 		Size:         size,
-		MimeType:     handler.Header.Get("Content-Type"),
+		MimeType:     detected,
 		Path:         filePath,
 		URL:          "/uploads/" + fileName,
 		Extension:    ext,
 		UploadedAt:   time.Now(),
 	}
 
+	if err := u.recordUpload(userID, result); err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
 	if u.enableResize && u.isImage(result.MimeType) {
 		thumbnailName := u.generateThumbnailName(fileName)
 		thumbnailPath := filepath.Join(u.uploadPath, thumbnailName)
 
-		if err := u.createThumbnail(filePath, thumbnailPath); err == nil {
+		result.ThumbnailStatus = u.queueThumbnail(fileName, filePath, thumbnailPath)
+		if result.ThumbnailStatus == ThumbnailReady {
 			result.ThumbnailURL = "/uploads/" + thumbnailName
 		}
 	}
 
+	u.runPostProcessors(result)
+
 	return result, nil
 }
 
+// cleanJPEG applies the AutoOrient and StripMetadata options to a stored
+// JPEG, returning its new size.
+func (u *UploadService) cleanJPEG(path string, size int64) (int64, error) {
+	if !u.jpegOptions.AutoOrient && !u.jpegOptions.StripMetadata {
+		return size, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read image: %w", err)
+	}
+	cleaned, changed, err := imaging.CleanJPEG(data, u.jpegOptions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to process image: %w", err)
+	}
+	if !changed {
+		return size, nil
+	}
+	if err := os.WriteFile(path, cleaned, 0644); err != nil {
+		return 0, fmt.Errorf("failed to save image: %w", err)
+	}
+	return int64(len(cleaned)), nil
+}
+
+// emitCompleted publishes EventCompleted; subscriber errors are logged and
+// never fail the upload.
+func emitCompleted(ctx context.Context, result *UploadResult) {
+	if err := events.Emit(ctx, EventCompleted, *result); err != nil {
+		logger.Warn("upload.completed handlers failed for %s: %v", result.FileName, err)
+	}
+}
+
+func (u *UploadService) runPostProcessors(result *UploadResult) {
+	if len(u.postProcessors) == 0 {
+		return
+	}
+
+	result.Metadata = make(map[string]interface{})
+	for _, processor := range u.postProcessors {
+		if err := processor(result); err != nil {
+			logger.Warn("Upload post-processor failed for %s: %v", result.FileName, err)
+		}
+	}
+}
+
 func (u *UploadService) generateFileName(ext string) string {
 	timestamp := time.Now().UnixNano()
 	return fmt.Sprintf("%d%s", timestamp, ext)
@@ -193,27 +339,73 @@ func (u *UploadService) isAllowedType(mimeType string) bool {
 	return false
 }
 
+// detectContentType sniffs the first 512 bytes of the file and returns the
+// media type without parameters such as charset.
+func detectContentType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	detected := http.DetectContentType(head[:n])
+	if mediaType, _, err := mime.ParseMediaType(detected); err == nil {
+		detected = mediaType
+	}
+	return detected, nil
+}
+
 func (u *UploadService) isImage(mimeType string) bool {
 	return strings.HasPrefix(mimeType, "image/")
 }
 
-func (u *UploadService) createThumbnail(srcPath, dstPath string) error {
-	logger.Info("Creating thumbnail: %s -> %s", srcPath, dstPath)
+// queueThumbnail hands thumbnail generation to the "generate_thumbnail" job
+// so the upload response is not blocked by image resizing. When the queue is
+// unavailable the thumbnail is generated inline instead.
+func (u *UploadService) queueThumbnail(fileName, srcPath, dstPath string) ThumbnailStatus {
+	key := thumbnailStatusKey(fileName)
+	cache.Set(key, string(ThumbnailPending), -1)
+
+	err := queue.PushWithRetry("generate_thumbnail", map[string]interface{}{
+		"file_path":  srcPath,
+		"thumb_path": dstPath,
+		"max_size":   u.thumbnailSize,
+		"status_key": key,
+	}, 3)
+	if err == nil {
+		return ThumbnailPending
+	}
 
-	srcFile, err := os.Open(srcPath)
-	if err != nil {
-		return err
+	logger.Warn("Thumbnail queue unavailable, generating inline: %v", err)
+	status := ThumbnailReady
+	if err := imaging.Thumbnail(srcPath, dstPath, u.thumbnailSize); err != nil {
+		logger.Error("Failed to create thumbnail for %s: %v", fileName, err)
+		status = ThumbnailFailed
 	}
-	defer srcFile.Close()
+	cache.Set(key, string(status), -1)
+	return status
+}
 
-	dstFile, err := os.Create(dstPath)
-	if err != nil {
-		return err
+func (u *UploadService) GetThumbnailStatus(fileName string) ThumbnailStatus {
+	if status, found := cache.GetString(thumbnailStatusKey(fileName)); found {
+		return ThumbnailStatus(status)
+	}
+
+	thumbnailPath := filepath.Join(u.uploadPath, u.generateThumbnailName(fileName))
+	if _, err := os.Stat(thumbnailPath); err == nil {
+		return ThumbnailReady
 	}
-	defer dstFile.Close()
+	return ThumbnailNone
+}
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+func thumbnailStatusKey(fileName string) string {
+	return "upload:thumbnail:" + fileName
 }
 
 func (u *UploadService) DeleteFile(fileName string) error {
@@ -225,8 +417,9 @@ func (u *UploadService) DeleteFile(fileName string) error {
 	thumbnailName := u.generateThumbnailName(fileName)
 	thumbnailPath := filepath.Join(u.uploadPath, thumbnailName)
 	os.Remove(thumbnailPath)
+	cache.Delete(thumbnailStatusKey(fileName))
 
-	return nil
+	return u.forgetUpload(fileName)
 }
 
 func (u *UploadService) GetFileInfo(fileName string) (*UploadResult, error) {
@@ -303,6 +496,12 @@ func HandleUpload(r *http.Request, fieldName string) (*UploadResult, error) {
 	return DefaultUploadService.HandleUpload(r, fieldName)
 }
 
+func RegisterPostProcessor(processor PostProcessor) {
+	if DefaultUploadService != nil {
+		DefaultUploadService.RegisterPostProcessor(processor)
+	}
+}
+
 func HandleMultipleUploads(r *http.Request, fieldName string) ([]*UploadResult, error) {
 	if DefaultUploadService == nil {
 		return nil, fmt.Errorf("upload service not initialized")
@@ -324,6 +523,13 @@ func GetFileInfo(fileName string) (*UploadResult, error) {
 	return DefaultUploadService.GetFileInfo(fileName)
 }
 
+func GetThumbnailStatus(fileName string) ThumbnailStatus {
+	if DefaultUploadService == nil {
+		return ThumbnailNone
+	}
+	return DefaultUploadService.GetThumbnailStatus(fileName)
+}
+
 func ListFiles() ([]*UploadResult, error) {
 	if DefaultUploadService == nil {
 		return nil, fmt.Errorf("upload service not initialized")
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Debounce returns a function that calls fn once wait has passed without
+// further calls, on its own goroutine. A burst of calls runs fn once, after
+// the last of them.
+func Debounce(fn func(), wait time.Duration) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if timer == nil {
+			timer = time.AfterFunc(wait, fn)
+			return
+		}
+		timer.Reset(wait)
+	}
+}
+
+// DebounceLeading returns a function that calls fn immediately on the first
+// call of a burst and ignores the rest; a burst ends once wait passes
+// without calls.
+func DebounceLeading(fn func(), wait time.Duration) func() {
+	var mu sync.Mutex
+	var last time.Time
+
+	return func() {
+		mu.Lock()
+		now := time.Now()
+		leading := last.IsZero() || now.Sub(last) >= wait
+		last = now
+		mu.Unlock()
+
+		if leading {
+			fn()
+		}
+	}
+}
+
+// Throttle returns a function that calls fn at most once per limit,
+// discarding calls in between. Unlike DebounceLeading, a steady stream of
+// calls still runs fn every limit.
+func Throttle(fn func(), limit time.Duration) func() {
+	var mu sync.Mutex
+	var last time.Time
+
+	return func() {
+		mu.Lock()
+		now := time.Now()
+		if !last.IsZero() && now.Sub(last) < limit {
+			mu.Unlock()
+			return
+		}
+		last = now
+		mu.Unlock()
+
+		fn()
+	}
+}
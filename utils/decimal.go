@@ -0,0 +1,365 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const maxDecimalScale = 18
+
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds ties away from zero (1.005 -> 1.01).
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds ties to the nearest even digit (banker's rounding).
+	RoundHalfEven
+	RoundDown
+)
+
+// Decimal is a fixed-point number stored as an int64 scaled by 10^Scale, so
+// 19.99 with scale 2 is held as 1999. Use it instead of float64 for money.
+type Decimal struct {
+	units int64
+	scale int
+}
+
+func NewDecimal(units int64, scale int) (Decimal, error) {
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale must be between 0 and %d", maxDecimalScale)
+	}
+	return Decimal{units: units, scale: scale}, nil
+}
+
+func DecimalFromInt(n int64) Decimal {
+	return Decimal{units: n}
+}
+
+// ParseDecimal parses strings such as "19.99", "-0.5" or "1000". The scale
+// is the number of digits after the point.
+func ParseDecimal(s string) (Decimal, error) {
+	raw := strings.TrimSpace(s)
+	if raw == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	negative := false
+	switch raw[0] {
+	case '-':
+		negative = true
+		raw = raw[1:]
+	case '+':
+		raw = raw[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(raw, ".")
+	if whole == "" && frac == "" || hasPoint && frac == "" ||
+		whole != "" && !isDigits(whole) || frac != "" && !isDigits(frac) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(frac) > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d fractional digits", s, maxDecimalScale)
+	}
+
+	n, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if negative {
+		n.Neg(n)
+	}
+	if !n.IsInt64() {
+		return Decimal{}, fmt.Errorf("decimal %q overflows int64", s)
+	}
+
+	return Decimal{units: n.Int64(), scale: len(frac)}, nil
+}
+
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Units returns the scaled integer value, e.g. cents for a scale of 2.
+func (d Decimal) Units() int64 {
+	return d.units
+}
+
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) Neg() (Decimal, error) {
+	return fromBig(new(big.Int).Neg(big.NewInt(d.units)), d.scale)
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	scale := Max(d.scale, other.scale)
+	return d.bigAt(scale).Cmp(other.bigAt(scale))
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	scale := Max(d.scale, other.scale)
+	return fromBig(new(big.Int).Add(d.bigAt(scale), other.bigAt(scale)), scale)
+}
+
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	scale := Max(d.scale, other.scale)
+	return fromBig(new(big.Int).Sub(d.bigAt(scale), other.bigAt(scale)), scale)
+}
+
+// Mul keeps the larger of the two scales, rounding half up.
+func (d Decimal) Mul(other Decimal) (Decimal, error) {
+	return d.MulRound(other, RoundHalfUp)
+}
+
+func (d Decimal) MulRound(other Decimal, mode RoundingMode) (Decimal, error) {
+	scale := Max(d.scale, other.scale)
+	product := new(big.Int).Mul(big.NewInt(d.units), big.NewInt(other.units))
+	return fromBig(divRound(product, pow10(d.scale+other.scale-scale), mode), scale)
+}
+
+// Div keeps the larger of the two scales, rounding half up.
+func (d Decimal) Div(other Decimal) (Decimal, error) {
+	return d.DivRound(other, Max(d.scale, other.scale), RoundHalfUp)
+}
+
+func (d Decimal) DivRound(other Decimal, scale int, mode RoundingMode) (Decimal, error) {
+	if other.units == 0 {
+		return Decimal{}, fmt.Errorf("decimal division by zero")
+	}
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale must be between 0 and %d", maxDecimalScale)
+	}
+
+	// d/other at the target scale is d.units * 10^(scale+other.scale-d.scale) / other.units.
+	num := big.NewInt(d.units)
+	den := big.NewInt(other.units)
+	if shift := scale + other.scale - d.scale; shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+
+	return fromBig(divRound(num, den, mode), scale)
+}
+
+// Round changes the scale, rounding with mode when digits are dropped.
+func (d Decimal) Round(scale int, mode RoundingMode) (Decimal, error) {
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale must be between 0 and %d", maxDecimalScale)
+	}
+	if scale >= d.scale {
+		return fromBig(d.bigAt(scale), scale)
+	}
+	return fromBig(divRound(big.NewInt(d.units), pow10(d.scale-scale), mode), scale)
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+func (d Decimal) String() string {
+	return d.format(".", "")
+}
+
+// NumberFormat holds the separators used by Decimal.Format.
+type NumberFormat struct {
+	Decimal   string
+	Thousands string
+}
+
+var (
+	numberFormatsMu sync.RWMutex
+	numberFormats   = map[string]NumberFormat{
+		"en": {Decimal: ".", Thousands: ","},
+		"id": {Decimal: ",", Thousands: "."},
+		"de": {Decimal: ",", Thousands: "."},
+		"es": {Decimal: ",", Thousands: "."},
+		"it": {Decimal: ",", Thousands: "."},
+		"fr": {Decimal: ",", Thousands: " "},
+		"ch": {Decimal: ".", Thousands: "'"},
+	}
+)
+
+func SetNumberFormat(locale string, format NumberFormat) {
+	numberFormatsMu.Lock()
+	defer numberFormatsMu.Unlock()
+	numberFormats[strings.ToLower(locale)] = format
+}
+
+// Format renders d with the separators of locale ("en", "id", "de-DE", ...),
+// falling back to English.
+func (d Decimal) Format(locale string) string {
+	locale = strings.ToLower(locale)
+
+	numberFormatsMu.RLock()
+	format, ok := numberFormats[locale]
+	if !ok {
+		base, _, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+		format, ok = numberFormats[base]
+	}
+	if !ok {
+		format = numberFormats["en"]
+	}
+	numberFormatsMu.RUnlock()
+
+	return d.format(format.Decimal, format.Thousands)
+}
+
+func (d Decimal) format(point, thousands string) string {
+	digits := new(big.Int).Abs(big.NewInt(d.units)).String()
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+
+	whole, frac := digits[:len(digits)-d.scale], digits[len(digits)-d.scale:]
+
+	if thousands != "" && len(whole) > 3 {
+		var buf strings.Builder
+		lead := len(whole) % 3
+		if lead > 0 {
+			buf.WriteString(whole[:lead])
+		}
+		for i := lead; i < len(whole); i += 3 {
+			if buf.Len() > 0 {
+				buf.WriteString(thousands)
+			}
+			buf.WriteString(whole[i : i+3])
+		}
+		whole = buf.String()
+	}
+
+	s := whole
+	if frac != "" {
+		s += point + frac
+	}
+	if d.units < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts both "19.99" and 19.99.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	}
+
+	parsed, err := ParseDecimal(raw)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC/DECIMAL columns.
+func (d *Decimal) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	case int64:
+		*d = Decimal{units: v}
+		return nil
+	case float64:
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", src)
+	}
+
+	parsed, err := ParseDecimal(raw)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value implements driver.Valuer; the decimal is sent as its string form.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d Decimal) bigAt(scale int) *big.Int {
+	n := big.NewInt(d.units)
+	if scale > d.scale {
+		n.Mul(n, pow10(scale-d.scale))
+	}
+	return n
+}
+
+func fromBig(n *big.Int, scale int) (Decimal, error) {
+	if !n.IsInt64() {
+		return Decimal{}, fmt.Errorf("decimal overflow")
+	}
+	return Decimal{units: n.Int64(), scale: scale}, nil
+}
+
+// divRound divides num by den, rounding the quotient according to mode.
+func divRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 || mode == RoundDown {
+		return q
+	}
+
+	// Compare 2|r| with |den| to find out which side of the half we are on.
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(new(big.Int).Abs(den))
+
+	away := cmp > 0 || cmp == 0 && (mode == RoundHalfUp || q.Bit(0) == 1)
+	if !away {
+		return q
+	}
+
+	if num.Sign()*den.Sign() < 0 {
+		return q.Sub(q, big.NewInt(1))
+	}
+	return q.Add(q, big.NewInt(1))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,130 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+
+	"flugo.com/utils"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	d := utils.MustParseDecimal
+	tests := []struct {
+		name string
+		op   func() (utils.Decimal, error)
+		want string
+	}{
+		{"add aligns scales", func() (utils.Decimal, error) { return d("0.1").Add(d("0.20")) }, "0.30"},
+		{"sub below zero", func() (utils.Decimal, error) { return d("1.5").Sub(d("2.25")) }, "-0.75"},
+		{"mul rounds half up", func() (utils.Decimal, error) { return d("1.15").Mul(d("0.50")) }, "0.58"},
+		{"mul negative", func() (utils.Decimal, error) { return d("-1.15").Mul(d("0.50")) }, "-0.58"},
+		{"div rounds half up", func() (utils.Decimal, error) { return d("10.00").Div(d("3")) }, "3.33"},
+		{"div two thirds", func() (utils.Decimal, error) { return d("2.00").Div(d("3")) }, "0.67"},
+		{"div scale", func() (utils.Decimal, error) { return d("1").DivRound(d("8"), 3, utils.RoundHalfEven) }, "0.125"},
+		{"div negative", func() (utils.Decimal, error) { return d("-1").DivRound(d("8"), 2, utils.RoundHalfEven) }, "-0.12"},
+		{"neg", func() (utils.Decimal, error) { return d("19.99").Neg() }, "-19.99"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.op()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	tests := []struct {
+		value string
+		mode  utils.RoundingMode
+		want  string
+	}{
+		{"1.005", utils.RoundHalfUp, "1.01"},
+		{"1.015", utils.RoundHalfUp, "1.02"},
+		{"-1.005", utils.RoundHalfUp, "-1.01"},
+		{"1.004", utils.RoundHalfUp, "1.00"},
+		{"1.005", utils.RoundHalfEven, "1.00"},
+		{"1.015", utils.RoundHalfEven, "1.02"},
+		{"-1.025", utils.RoundHalfEven, "-1.02"},
+		{"1.0051", utils.RoundHalfEven, "1.01"},
+		{"1.009", utils.RoundDown, "1.00"},
+		{"-1.009", utils.RoundDown, "-1.00"},
+		{"1.5", utils.RoundHalfUp, "1.50"},
+	}
+
+	for _, tt := range tests {
+		got, err := utils.MustParseDecimal(tt.value).Round(2, tt.mode)
+		if err != nil {
+			t.Errorf("Round(%s, %d): %v", tt.value, tt.mode, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.value, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalErrors(t *testing.T) {
+	d := utils.MustParseDecimal
+	max := utils.DecimalFromInt(math.MaxInt64)
+	min := utils.DecimalFromInt(math.MinInt64)
+	tests := map[string]func() (utils.Decimal, error){
+		"add overflow":      func() (utils.Decimal, error) { return max.Add(d("1")) },
+		"sub overflow":      func() (utils.Decimal, error) { return min.Sub(d("1")) },
+		"mul overflow":      func() (utils.Decimal, error) { return max.Mul(d("2")) },
+		"scale up overflow": func() (utils.Decimal, error) { return max.Add(d("0.1")) },
+		"neg overflow":      func() (utils.Decimal, error) { return min.Neg() },
+		"div overflow":      func() (utils.Decimal, error) { return max.Div(d("0.5")) },
+		"div by zero":       func() (utils.Decimal, error) { return d("1").Div(d("0.00")) },
+		"div bad scale":     func() (utils.Decimal, error) { return d("1").DivRound(d("3"), 19, utils.RoundHalfUp) },
+		"round bad scale":   func() (utils.Decimal, error) { return d("1").Round(-1, utils.RoundHalfUp) },
+		"round up overflow": func() (utils.Decimal, error) { return max.Round(1, utils.RoundHalfUp) },
+		"new bad scale":     func() (utils.Decimal, error) { return utils.NewDecimal(1, 19) },
+		"parse overflow":    func() (utils.Decimal, error) { return utils.ParseDecimal("9223372036854775808") },
+		"parse fraction":    func() (utils.Decimal, error) { return utils.ParseDecimal("0.1234567890123456789") },
+	}
+	for name, op := range tests {
+		if got, err := op(); err == nil {
+			t.Errorf("%s = %s, want an error", name, got)
+		}
+	}
+
+	if got, err := utils.ParseDecimal(strconv.FormatInt(math.MinInt64, 10)); err != nil || got != min {
+		t.Errorf("ParseDecimal(MinInt64) = %v, %v", got, err)
+	}
+	for _, s := range []string{"", "-", ".", "1.", "1e3", "1,5", "--1", "0x10"} {
+		if _, err := utils.ParseDecimal(s); err == nil {
+			t.Errorf("ParseDecimal(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestDecimalFormatAndJSON(t *testing.T) {
+	price := utils.MustParseDecimal("-1234567.05")
+	for locale, want := range map[string]string{
+		"en": "-1,234,567.05", "de-DE": "-1.234.567,05", "fr": "-1\u202f234\u202f567,05", "xx": "-1,234,567.05",
+	} {
+		if got := price.Format(locale); got != want {
+			t.Errorf("Format(%s) = %q, want %q", locale, got, want)
+		}
+	}
+	if got := utils.MustParseDecimal("-0.05").String(); got != "-0.05" {
+		t.Errorf("String = %s, want -0.05", got)
+	}
+
+	var v struct{ A, B utils.Decimal }
+	if err := json.Unmarshal([]byte(`{"A":"19.99","B":0.1}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	sum, _ := v.A.Add(v.B)
+	out, _ := json.Marshal(sum)
+	if string(out) != `"20.09"` {
+		t.Errorf("Marshal = %s, want \"20.09\"", out)
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"html"
+	"strings"
+)
+
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "td": true, "th": true,
+	"tr": true, "ul": true,
+}
+
+// StripTags removes markup, comments and the contents of script and style
+// elements, decodes entities and collapses whitespace.
+func StripTags(s string) string {
+	var buf strings.Builder
+	lower := strings.ToLower(s)
+
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "<!--") {
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+
+		if s[i] != '<' || i+1 == len(s) || !isTagStart(lower[i+1]) {
+			buf.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '>')
+		if end < 0 {
+			break
+		}
+		name := tagName(lower[i+1 : i+end])
+		i += end + 1
+
+		if name == "script" || name == "style" {
+			closing := strings.Index(lower[i:], "</"+name)
+			if closing < 0 {
+				break
+			}
+			i += closing
+			continue
+		}
+
+		if blockTags[strings.TrimPrefix(name, "/")] {
+			buf.WriteByte(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(html.UnescapeString(buf.String())), " ")
+}
+
+// TruncateHTML strips markup from s and truncates the text on a word
+// boundary, which is what excerpts and previews need.
+func TruncateHTML(s string, length int) string {
+	return TruncateWithOptions(StripTags(s), length, TruncateOptions{Ellipsis: "...", WordBoundary: true})
+}
+
+func isTagStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c == '/' || c == '!' || c == '?'
+}
+
+func tagName(tag string) string {
+	end := strings.IndexAny(tag, " \t\r\n/>")
+	if strings.HasPrefix(tag, "/") {
+		end = strings.IndexAny(tag[1:], " \t\r\n/>")
+		if end >= 0 {
+			end++
+		}
+	}
+	if end < 0 {
+		return tag
+	}
+	return tag[:end]
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// HumanizeLocale supplies the words used by HumanizeTime and HumanDuration.
+// Units are "second", "minute", "hour", "day", "month" and "year".
+type HumanizeLocale interface {
+	Count(unit string, n int) string
+	Past(phrase string) string
+	Future(phrase string) string
+	JustNow() string
+	Abbrev(unit string) string
+}
+
+type englishLocale struct{}
+
+func (englishLocale) Count(unit string, n int) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func (englishLocale) Past(phrase string) string   { return phrase + " ago" }
+func (englishLocale) Future(phrase string) string { return "in " + phrase }
+func (englishLocale) JustNow() string             { return "just now" }
+func (englishLocale) Abbrev(unit string) string   { return unit[:1] }
+
+var (
+	localeMu       sync.RWMutex
+	humanizeLocale HumanizeLocale = englishLocale{}
+)
+
+// SetHumanizeLocale replaces the English wording; nil restores it.
+func SetHumanizeLocale(locale HumanizeLocale) {
+	localeMu.Lock()
+	defer localeMu.Unlock()
+
+	if locale == nil {
+		locale = englishLocale{}
+	}
+	humanizeLocale = locale
+}
+
+func currentLocale() HumanizeLocale {
+	localeMu.RLock()
+	defer localeMu.RUnlock()
+	return humanizeLocale
+}
+
+// HumanizeTime describes t relative to now, e.g. "3 hours ago" or
+// "in 2 days".
+func HumanizeTime(t time.Time) string {
+	return HumanizeTimeFrom(t, time.Now())
+}
+
+func HumanizeTimeFrom(t, now time.Time) string {
+	locale := currentLocale()
+
+	diff := now.Sub(t)
+	future := diff < 0
+	if future {
+		// Sub saturates at the minimum Duration, which cannot be negated.
+		diff = -max(diff, -math.MaxInt64)
+	}
+
+	if diff < time.Minute {
+		return locale.JustNow()
+	}
+
+	var phrase string
+	switch {
+	case diff < time.Hour:
+		phrase = locale.Count("minute", int(diff/time.Minute))
+	case diff < 24*time.Hour:
+		phrase = locale.Count("hour", int(diff/time.Hour))
+	case diff < 30*24*time.Hour:
+		phrase = locale.Count("day", int(diff/(24*time.Hour)))
+	case diff < 365*24*time.Hour:
+		phrase = locale.Count("month", int(diff/(30*24*time.Hour)))
+	default:
+		phrase = locale.Count("year", int(diff/(365*24*time.Hour)))
+	}
+
+	if future {
+		return locale.Future(phrase)
+	}
+	return locale.Past(phrase)
+}
+
+// HumanDuration formats d with its two most significant units, such as
+// "45s", "5m 03s", "1h 05m" or "2d 03h".
+func HumanDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + HumanDuration(-max(d, -math.MaxInt64))
+	}
+
+	locale := currentLocale()
+	s, m, h, day := locale.Abbrev("second"), locale.Abbrev("minute"), locale.Abbrev("hour"), locale.Abbrev("day")
+
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d%s", int(d/time.Second), s)
+	case d < time.Hour:
+		return fmt.Sprintf("%d%s %02d%s", int(d/time.Minute), m, int(d%time.Minute/time.Second), s)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d%s %02d%s", int(d/time.Hour), h, int(d%time.Hour/time.Minute), m)
+	default:
+		return fmt.Sprintf("%d%s %02d%s", int(d/(24*time.Hour)), day, int(d%(24*time.Hour)/time.Hour), h)
+	}
+}
+
+var byteUnits = map[string]int{
+	"": 0, "b": 0,
+	"k": 1, "kb": 1, "kib": 1,
+	"m": 2, "mb": 2, "mib": 2,
+	"g": 3, "gb": 3, "gib": 3,
+	"t": 4, "tb": 4, "tib": 4,
+	"p": 5, "pb": 5, "pib": 5,
+}
+
+// ParseBytes is the inverse of FormatBytes: it accepts values such as
+// "512 B", "1.5GB" or "10k" and uses 1024-based units.
+func ParseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if split < 0 {
+		split = len(s)
+	}
+
+	number := s[:split]
+	unit := strings.ToLower(strings.TrimSpace(s[split:]))
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	exp, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown byte unit %q", unit)
+	}
+
+	bytes := value * math.Pow(1024, float64(exp))
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("byte size %q is too large", s)
+	}
+	return int64(math.Round(bytes)), nil
+}
@@ -0,0 +1,79 @@
+package utils_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"flugo.com/utils"
+)
+
+func TestHumanizeTimeBoundaries(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{time.Minute - time.Nanosecond, "just now"},
+		{time.Minute, "1 minute ago"},
+		{2 * time.Minute, "2 minutes ago"},
+		{time.Hour - time.Nanosecond, "59 minutes ago"},
+		{time.Hour, "1 hour ago"},
+		{day - time.Nanosecond, "23 hours ago"},
+		{day, "1 day ago"},
+		{30*day - time.Nanosecond, "29 days ago"},
+		{30 * day, "1 month ago"},
+		{365 * day, "1 year ago"},
+		// Negative durations are in the future.
+		{-(time.Minute - time.Nanosecond), "just now"},
+		{-time.Minute, "in 1 minute"},
+		{-time.Hour, "in 1 hour"},
+		{-day, "in 1 day"},
+		{-3 * day, "in 3 days"},
+	}
+
+	for _, tt := range tests {
+		if got := utils.HumanizeTimeFrom(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("HumanizeTimeFrom(now - %v) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+
+	// Spans too long for a Duration saturate instead of wrapping.
+	if got := utils.HumanizeTimeFrom(now.AddDate(400, 0, 0), now); got != "in 292 years" {
+		t.Errorf("far future = %q, want %q", got, "in 292 years")
+	}
+	if got := utils.HumanizeTimeFrom(now.AddDate(-400, 0, 0), now); got != "292 years ago" {
+		t.Errorf("far past = %q, want %q", got, "292 years ago")
+	}
+}
+
+func TestHumanDurationBoundaries(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{time.Minute - time.Second, "59s"},
+		{time.Minute - 400*time.Millisecond, "1m 00s"},
+		{time.Minute, "1m 00s"},
+		{time.Hour - time.Second, "59m 59s"},
+		{time.Hour, "1h 00m"},
+		{day - time.Minute, "23h 59m"},
+		{day, "1d 00h"},
+		{day + 3*time.Hour + 59*time.Minute, "1d 03h"},
+		{-time.Second, "-1s"},
+		{-time.Minute, "-1m 00s"},
+		{-time.Hour, "-1h 00m"},
+		{-day, "-1d 00h"},
+		{math.MinInt64, "-106751d 23h"},
+	}
+
+	for _, tt := range tests {
+		if got := utils.HumanDuration(tt.d); got != tt.want {
+			t.Errorf("HumanDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+var irregularPlurals = map[string]string{
+	"person": "people",
+	"child":  "children",
+	"mouse":  "mice",
+	"man":    "men",
+	"woman":  "women",
+	"foot":   "feet",
+	"tooth":  "teeth",
+	"goose":  "geese",
+	"ox":     "oxen",
+}
+
+var irregularSingulars = func() map[string]string {
+	m := make(map[string]string, len(irregularPlurals))
+	for singular, plural := range irregularPlurals {
+		m[plural] = singular
+	}
+	return m
+}()
+
+var uncountables = map[string]bool{
+	"sheep": true, "fish": true, "series": true, "species": true,
+	"news": true, "information": true, "equipment": true, "data": true,
+}
+
+// Pluralize returns word unchanged when count is 1 and its English plural
+// otherwise, keeping the capitalization of the first letter:
+//
+//	Pluralize("user", 5)   // "users"
+//	Pluralize("Person", 2) // "People"
+func Pluralize(word string, count int) string {
+	if count == 1 || word == "" {
+		return word
+	}
+
+	lower := strings.ToLower(word)
+	if uncountables[lower] {
+		return word
+	}
+	if plural, ok := irregularPlurals[lower]; ok {
+		return matchCase(word, plural)
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !isVowel(lower[len(lower)-2]):
+		return word[:len(word)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return word + "es"
+	default:
+		return word + "s"
+	}
+}
+
+// Singularize reverses Pluralize for the same set of rules.
+func Singularize(word string) string {
+	lower := strings.ToLower(word)
+	if uncountables[lower] {
+		return word
+	}
+	if singular, ok := irregularSingulars[lower]; ok {
+		return matchCase(word, singular)
+	}
+	if _, ok := irregularPlurals[lower]; ok {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(lower) > 3:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "zes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "uses") && len(lower) >= 5 && !isVowel(lower[len(lower)-5]):
+		// statuses, viruses and campuses, but not houses or causes
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "ss"), strings.HasSuffix(lower, "us"), strings.HasSuffix(lower, "is"):
+		return word
+	case strings.HasSuffix(lower, "s") && len(lower) > 1:
+		return word[:len(word)-1]
+	default:
+		return word
+	}
+}
+
+// Humanize turns snake_case, kebab-case or camelCase identifiers into
+// capitalized words: "created_at" and "createdAt" both become "Created At".
+// Runs of capitals are kept together, so "userID" becomes "User ID".
+func Humanize(s string) string {
+	var words []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(current) > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+func matchCase(original, replacement string) string {
+	if original == strings.ToUpper(original) && len(original) > 1 {
+		return strings.ToUpper(replacement)
+	}
+	if unicode.IsUpper([]rune(original)[0]) {
+		r := []rune(replacement)
+		r[0] = unicode.ToUpper(r[0])
+		return string(r)
+	}
+	return replacement
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}()
+
+const (
+	passwordLower   = "abcdefghijklmnopqrstuvwxyz"
+	passwordUpper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigits  = "0123456789"
+	passwordSymbols = "!@#$%^&*()-_=+[]{};:,.?/~"
+	ambiguousChars  = "Il1O0o"
+)
+
+// PasswordStrength scores pw from 0 (very weak) to 4 (strong) and returns
+// suggestions for improving it. Length and character variety add points;
+// repeated characters, sequences and common passwords take them away.
+func PasswordStrength(pw string) (int, []string) {
+	if pw == "" {
+		return 0, []string{"Password cannot be empty"}
+	}
+
+	if isCommonPassword(pw) {
+		return 0, []string{"This is a commonly used password"}
+	}
+
+	var feedback []string
+	runes := []rune(pw)
+	length := len(runes)
+
+	score := 0
+	switch {
+	case length >= 16:
+		score += 3
+	case length >= 12:
+		score += 2
+	case length >= 8:
+		score++
+	}
+	if length < 12 {
+		feedback = append(feedback, "Use at least 12 characters")
+	}
+
+	classes := passwordClasses(runes)
+	if classes > 3 {
+		score += 2
+	} else if classes == 3 {
+		score++
+	}
+	if classes < 3 {
+		feedback = append(feedback, "Mix upper and lower case letters, digits and symbols")
+	}
+
+	if hasRepeatedRun(runes, 3) {
+		score--
+		feedback = append(feedback, "Avoid repeated characters such as \"aaa\"")
+	}
+	if hasSequence(runes, 3) {
+		score--
+		feedback = append(feedback, "Avoid sequences such as \"abc\" or \"123\"")
+	}
+	if uniqueRunes(runes)*3 < length {
+		score--
+		feedback = append(feedback, "Use more distinct characters")
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	if score > 4 {
+		score = 4
+	}
+	return score, feedback
+}
+
+// isCommonPassword also catches common passwords padded with digits or
+// symbols, such as "password2024!".
+func isCommonPassword(pw string) bool {
+	lower := strings.ToLower(pw)
+	if commonPasswords[lower] {
+		return true
+	}
+
+	trimmed := strings.TrimRightFunc(lower, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	return utf8.RuneCountInString(trimmed) >= 4 && commonPasswords[trimmed]
+}
+
+func passwordClasses(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsLetter(r):
+			other = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func hasRepeatedRun(runes []rune, n int) bool {
+	run := 1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == runes[i-1] {
+			run++
+			if run >= n {
+				return true
+			}
+		} else {
+			run = 1
+		}
+	}
+	return false
+}
+
+func hasSequence(runes []rune, n int) bool {
+	up, down := 1, 1
+	for i := 1; i < len(runes); i++ {
+		prev, cur := unicode.ToLower(runes[i-1]), unicode.ToLower(runes[i])
+		if cur == prev+1 {
+			up++
+		} else {
+			up = 1
+		}
+		if cur == prev-1 {
+			down++
+		} else {
+			down = 1
+		}
+		if up >= n || down >= n {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueRunes(runes []rune) int {
+	seen := make(map[rune]bool, len(runes))
+	for _, r := range runes {
+		seen[r] = true
+	}
+	return len(seen)
+}
+
+// PasswordOptions selects the character classes used by GeneratePassword.
+// When no class is enabled all four are used.
+type PasswordOptions struct {
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+}
+
+// GeneratePassword returns a random password containing at least one
+// character from every enabled class.
+func GeneratePassword(length int, opts PasswordOptions) (string, error) {
+	if !opts.Lower && !opts.Upper && !opts.Digits && !opts.Symbols {
+		opts.Lower, opts.Upper, opts.Digits, opts.Symbols = true, true, true, true
+	}
+
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{opts.Lower, passwordLower},
+		{opts.Upper, passwordUpper},
+		{opts.Digits, passwordDigits},
+		{opts.Symbols, passwordSymbols},
+	} {
+		if !class.enabled {
+			continue
+		}
+		chars := class.chars
+		if opts.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguousChars, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+
+	if length < len(classes) {
+		return "", fmt.Errorf("password length must be at least %d", len(classes))
+	}
+
+	result := make([]byte, 0, length)
+	for _, chars := range classes {
+		c, err := RandomStringCharset(1, chars)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, c...)
+	}
+
+	rest, err := RandomStringCharset(length-len(result), strings.Join(classes, ""))
+	if err != nil {
+		return "", err
+	}
+	result = append(result, rest...)
+
+	for i := len(result) - 1; i > 0; i-- {
+		j, err := RandomIntRange(0, i)
+		if err != nil {
+			return "", err
+		}
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return string(result), nil
+}
@@ -0,0 +1,53 @@
+package utils_test
+
+import (
+	"slices"
+	"testing"
+
+	"flugo.com/utils"
+)
+
+func TestPasswordStrengthUnicode(t *testing.T) {
+	const (
+		tooShort  = "Use at least 12 characters"
+		mix       = "Mix upper and lower case letters, digits and symbols"
+		repeated  = "Avoid repeated characters such as \"aaa\""
+		sequence  = "Avoid sequences such as \"abc\" or \"123\""
+		distinct  = "Use more distinct characters"
+		common    = "This is a commonly used password"
+		noProblem = ""
+	)
+	tests := []struct {
+		pw       string
+		score    int
+		feedback string
+	}{
+		// Length is counted in characters, not bytes.
+		{"Ünïcödé", 0, tooShort},
+		{"日本語のパスワード", 1, mix},
+		{"Пароль2024!Ёж", 4, noProblem},
+		{"🔐🔑 Straße 9x", 4, noProblem},
+		{"ääääääää", 0, repeated},
+		{"абвгдежз", 0, sequence},
+		{"ΑΒΓ-αβγ-ΑΒΓ-αβγ", 2, sequence},
+		{"ßßßßßßßßßßßß1", 0, distinct},
+		// Common passwords are caught whatever their case and padding.
+		{"PASSWORD2024!", 0, common},
+		{"Dragon🐉🐉", 0, common},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pw, func(t *testing.T) {
+			score, feedback := utils.PasswordStrength(tt.pw)
+			if score != tt.score {
+				t.Errorf("score = %d, want %d (feedback %q)", score, tt.score, feedback)
+			}
+			if tt.feedback == noProblem && len(feedback) > 0 {
+				t.Errorf("feedback = %q, want none", feedback)
+			}
+			if tt.feedback != noProblem && !slices.Contains(feedback, tt.feedback) {
+				t.Errorf("feedback = %q, want %q", feedback, tt.feedback)
+			}
+		})
+	}
+}
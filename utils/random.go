@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	alphanumericCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	crockfordBase32     = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// SecureToken returns nBytes of crypto/rand entropy encoded as unpadded
+// base64url, suitable for URLs, cookies and API keys.
+func SecureToken(nBytes int) (string, error) {
+	if nBytes <= 0 {
+		return "", fmt.Errorf("token length must be positive")
+	}
+
+	buf := make([]byte, nBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func MustSecureToken(nBytes int) string {
+	token, err := SecureToken(nBytes)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+// RandomStringCharset returns n characters drawn uniformly from charset.
+// Bytes that would introduce modulo bias are rejected and redrawn.
+func RandomStringCharset(n int, charset string) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("length cannot be negative")
+	}
+	if len(charset) == 0 || len(charset) > 256 {
+		return "", fmt.Errorf("charset must contain between 1 and 256 characters")
+	}
+
+	limit := 256 - 256%len(charset)
+	result := make([]byte, 0, n)
+	buf := make([]byte, n+n/4+1)
+
+	for len(result) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, charset[int(b)%len(charset)])
+			if len(result) == n {
+				break
+			}
+		}
+	}
+
+	return string(result), nil
+}
+
+// RandomString returns an alphanumeric string. It panics if the system
+// entropy source fails; use RandomStringCharset to handle that case.
+func RandomString(length int) string {
+	s, err := RandomStringCharset(length, alphanumericCharset)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// RandomIntRange returns a uniform random integer in [min, max].
+func RandomIntRange(min, max int) (int, error) {
+	if min > max {
+		return 0, fmt.Errorf("min (%d) cannot be greater than max (%d)", min, max)
+	}
+
+	span := new(big.Int).Sub(big.NewInt(int64(max)), big.NewInt(int64(min)))
+	span.Add(span, big.NewInt(1))
+
+	n, err := rand.Int(rand.Reader, span)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return int(n.Int64() + int64(min)), nil
+}
+
+// RandomInt returns a uniform random integer in [min, max]. Swapped bounds
+// are accepted, and min is returned if the entropy source fails.
+func RandomInt(min, max int) int {
+	if min > max {
+		min, max = max, min
+	}
+
+	n, err := RandomIntRange(min, max)
+	if err != nil {
+		return min
+	}
+	return n
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	bytes[6] = (bytes[6] & 0x0f) | 0x40
+	bytes[8] = (bytes[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:]), nil
+}
+
+// UUID is NewUUID panicking if the system entropy source fails, like
+// MustSecureToken.
+func UUID() string {
+	id, err := NewUUID()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+var (
+	ulidMu      sync.Mutex
+	ulidLastMs  uint64
+	ulidLastRnd [10]byte
+)
+
+// ULID returns a 26 character Crockford base32 identifier made of a 48-bit
+// millisecond timestamp and 80 random bits. IDs generated in the same
+// millisecond increment the random part, so they sort in creation order.
+// It panics if the system entropy source fails.
+func ULID() string {
+	ulidMu.Lock()
+	defer ulidMu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= ulidLastMs {
+		ms = ulidLastMs
+		for i := len(ulidLastRnd) - 1; i >= 0; i-- {
+			ulidLastRnd[i]++
+			if ulidLastRnd[i] != 0 {
+				break
+			}
+		}
+	} else {
+		if _, err := rand.Read(ulidLastRnd[:]); err != nil {
+			panic(fmt.Errorf("failed to read random bytes: %w", err))
+		}
+		ulidLastMs = ms
+	}
+
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], ulidLastRnd[:])
+
+	return encodeCrockford(raw)
+}
+
+// NewID returns a new ULID; used for job and request identifiers.
+func NewID() string {
+	return ULID()
+}
+
+func encodeCrockford(raw [16]byte) string {
+	hi := binary.BigEndian.Uint64(raw[0:8])
+	lo := binary.BigEndian.Uint64(raw[8:16])
+
+	// 128 bits are written as 26 groups of 5 bits, the first holding 3.
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordBase32[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
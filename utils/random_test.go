@@ -0,0 +1,27 @@
+package utils_test
+
+import (
+	"regexp"
+	"testing"
+
+	"flugo.com/utils"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := utils.NewUUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !uuidV4.MatchString(id) || seen[id] {
+			t.Fatalf("NewUUID = %q, want a fresh version 4 UUID", id)
+		}
+		seen[id] = true
+	}
+	if id := utils.UUID(); !uuidV4.MatchString(id) {
+		t.Errorf("UUID = %q", id)
+	}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy describes how often and how quickly an operation is retried.
+// Jitter is a fraction (0-1) of each delay that is randomly added or
+// removed. A nil Retryable treats every error as retryable.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64
+	Retryable    func(err error) bool
+	OnRetry      func(attempt int, err error, delay time.Duration)
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// Delay returns the wait before the retry that follows the given 1-based
+// attempt.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
+
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	_, err := RetryValue(ctx, policy, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// RetryValue calls fn until it succeeds, returns a non-retryable error, runs
+// out of attempts or ctx is done. The returned error wraps the last failure.
+func RetryValue[T any](ctx context.Context, policy RetryPolicy, fn func() (T, error)) (T, error) {
+	var zero T
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return zero, fmt.Errorf("retry aborted before attempt %d: %w", attempt, err)
+		}
+
+		value, err := fn()
+		if err == nil {
+			return value, nil
+		}
+
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return zero, fmt.Errorf("attempt %d failed with non-retryable error: %w", attempt, err)
+		}
+		if attempt >= maxAttempts {
+			return zero, fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		delay := policy.Delay(attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, fmt.Errorf("retry aborted after %d attempts: %w (last error: %w)", attempt, ctx.Err(), err)
+		}
+	}
+}
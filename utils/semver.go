@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Semver is a semantic version as defined by semver 2.0. Build metadata is
+// kept for String but ignored when comparing.
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+	Build      string
+}
+
+// ParseSemver parses versions such as 1.4.0, v2.0.0-rc.1 or 1.0.0+build.5.
+func ParseSemver(s string) (Semver, error) {
+	var v Semver
+
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if raw == "" {
+		return v, fmt.Errorf("invalid semantic version %q", s)
+	}
+
+	if i := strings.IndexByte(raw, '+'); i >= 0 {
+		v.Build = raw[i+1:]
+		raw = raw[:i]
+		if !validIdentifiers(v.Build, false) {
+			return Semver{}, fmt.Errorf("invalid build metadata in version %q", s)
+		}
+	}
+
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		pre := raw[i+1:]
+		raw = raw[:i]
+		if !validIdentifiers(pre, true) {
+			return Semver{}, fmt.Errorf("invalid prerelease in version %q", s)
+		}
+		v.Prerelease = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return Semver{}, fmt.Errorf("invalid semantic version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+
+	nums := [3]int{}
+	for i, part := range parts {
+		n, err := parseVersionNumber(part)
+		if err != nil {
+			return Semver{}, fmt.Errorf("invalid semantic version %q: %v", s, err)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+
+	return v, nil
+}
+
+func MustParseSemver(s string) Semver {
+	v, err := ParseSemver(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func parseVersionNumber(s string) (int, error) {
+	if s == "" || !isDigits(s) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("%q has a leading zero", s)
+	}
+	return strconv.Atoi(s)
+}
+
+func validIdentifiers(s string, prerelease bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+				return false
+			}
+		}
+		if prerelease && len(id) > 1 && id[0] == '0' && isDigits(id) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (v Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+func (v Semver) Compare(other Semver) int {
+	return Compare(v, other)
+}
+
+func (v Semver) LessThan(other Semver) bool {
+	return Compare(v, other) < 0
+}
+
+func (v Semver) AtLeast(other Semver) bool {
+	return Compare(v, other) >= 0
+}
+
+// Compare returns -1, 0 or 1 following semver precedence rules.
+func Compare(a, b Semver) int {
+	for _, pair := range [][2]int{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		if pair[0] != pair[1] {
+			return compareInts(pair[0], pair[1])
+		}
+	}
+
+	// A version without prerelease ranks above any prerelease of it.
+	switch {
+	case len(a.Prerelease) == 0 && len(b.Prerelease) == 0:
+		return 0
+	case len(a.Prerelease) == 0:
+		return 1
+	case len(b.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.Prerelease) && i < len(b.Prerelease); i++ {
+		if c := comparePrerelease(a.Prerelease[i], b.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(a.Prerelease), len(b.Prerelease))
+}
+
+// CompareVersions parses and compares two version strings.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	return Compare(va, vb), nil
+}
+
+func comparePrerelease(a, b string) int {
+	aNum, bNum := isDigits(a), isDigits(b)
+	switch {
+	case aNum && bNum:
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return compareInts(x, y)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func SortSemvers(versions []Semver) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return Compare(versions[i], versions[j]) < 0
+	})
+}
+
+// SortVersions sorts version strings in ascending order and fails on the
+// first string that is not a valid semantic version.
+func SortVersions(versions []string) error {
+	parsed := make([]Semver, len(versions))
+	for i, s := range versions {
+		v, err := ParseSemver(s)
+		if err != nil {
+			return err
+		}
+		parsed[i] = v
+	}
+
+	order := make([]int, len(versions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return Compare(parsed[order[i]], parsed[order[j]]) < 0
+	})
+
+	sorted := make([]string, len(versions))
+	for i, idx := range order {
+		sorted[i] = versions[idx]
+	}
+	copy(versions, sorted)
+	return nil
+}
+
+func MaxSemver(versions []Semver) (Semver, bool) {
+	if len(versions) == 0 {
+		return Semver{}, false
+	}
+	latest := versions[0]
+	for _, v := range versions[1:] {
+		if Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest, true
+}
+
+// Constraint is a version range such as ">=1.2.0 <2.0.0". Space separated
+// comparisons must all hold and "||" separates alternatives. Supported
+// operators are =, !=, >, >=, <, <=, ~ (same minor) and ^ (same major).
+type Constraint string
+
+// Match reports whether v satisfies the constraint. An invalid constraint
+// never matches; use Validate to surface the error.
+func (c Constraint) Match(v Semver) bool {
+	ok, err := c.Check(v)
+	return err == nil && ok
+}
+
+func (c Constraint) MatchString(version string) (bool, error) {
+	v, err := ParseSemver(version)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v)
+}
+
+func (c Constraint) Validate() error {
+	_, err := c.Check(Semver{})
+	return err
+}
+
+func (c Constraint) Check(v Semver) (bool, error) {
+	if strings.TrimSpace(string(c)) == "" {
+		return false, fmt.Errorf("empty version constraint")
+	}
+
+	matched := false
+	for _, group := range strings.Split(string(c), "||") {
+		terms := strings.Fields(group)
+		if len(terms) == 0 {
+			return false, fmt.Errorf("invalid version constraint %q", string(c))
+		}
+
+		all := true
+		for _, term := range terms {
+			ok, err := matchTerm(term, v)
+			if err != nil {
+				return false, fmt.Errorf("invalid version constraint %q: %v", string(c), err)
+			}
+			all = all && ok
+		}
+		matched = matched || all
+	}
+	return matched, nil
+}
+
+func matchTerm(term string, v Semver) (bool, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(term, candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	target, err := ParseSemver(strings.TrimPrefix(term, op))
+	if err != nil {
+		return false, err
+	}
+
+	c := Compare(v, target)
+	switch op {
+	case "", "=":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case "~":
+		return c >= 0 && v.Major == target.Major && v.Minor == target.Minor, nil
+	default:
+		if target.Major == 0 {
+			return c >= 0 && v.Major == 0 && v.Minor == target.Minor, nil
+		}
+		return c >= 0 && v.Major == target.Major, nil
+	}
+}
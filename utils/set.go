@@ -0,0 +1,78 @@
+package utils
+
+// Set is an unordered collection of distinct values. The zero value is not
+// usable; create sets with NewSet. Union, Intersection and Difference return
+// new sets and leave their operands untouched.
+type Set[T comparable] map[T]struct{}
+
+func NewSet[T comparable](items ...T) Set[T] {
+	s := make(Set[T], len(items))
+	for _, item := range items {
+		s[item] = struct{}{}
+	}
+	return s
+}
+
+func (s Set[T]) Add(item T) {
+	s[item] = struct{}{}
+}
+
+func (s Set[T]) Remove(item T) {
+	delete(s, item)
+}
+
+func (s Set[T]) Contains(item T) bool {
+	_, ok := s[item]
+	return ok
+}
+
+func (s Set[T]) Size() int {
+	return len(s)
+}
+
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	result := make(Set[T], len(s)+len(other))
+	for item := range s {
+		result[item] = struct{}{}
+	}
+	for item := range other {
+		result[item] = struct{}{}
+	}
+	return result
+}
+
+func (s Set[T]) Intersection(other Set[T]) Set[T] {
+	small, large := s, other
+	if len(large) < len(small) {
+		small, large = large, small
+	}
+
+	result := make(Set[T])
+	for item := range small {
+		if large.Contains(item) {
+			result[item] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Difference returns the items of s that are not in other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	result := make(Set[T])
+	for item := range s {
+		if !other.Contains(item) {
+			result[item] = struct{}{}
+		}
+	}
+	return result
+}
+
+// ToSlice returns the items in no particular order; sort the result when
+// the order matters.
+func (s Set[T]) ToSlice() []T {
+	result := make([]T, 0, len(s))
+	for item := range s {
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package utils
+
+import "math"
+
+func Contains[T comparable](slice []T, item T) bool {
+	return IndexOf(slice, item) >= 0
+}
+
+// IndexOf returns the index of the first occurrence of item, or -1.
+func IndexOf[T comparable](slice []T, item T) int {
+	for i, v := range slice {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// Unique returns the distinct elements of slice in first-seen order.
+func Unique[T comparable](slice []T) []T {
+	seen := make(map[T]bool)
+	result := []T{}
+
+	for _, item := range slice {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func Map[T, U any](slice []T, fn func(T) U) []U {
+	result := make([]U, len(slice))
+	for i, v := range slice {
+		result[i] = fn(v)
+	}
+	return result
+}
+
+func Filter[T any](slice []T, fn func(T) bool) []T {
+	result := []T{}
+	for _, v := range slice {
+		if fn(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func Reduce[T, U any](slice []T, fn func(U, T) U, initial U) U {
+	result := initial
+	for _, v := range slice {
+		result = fn(result, v)
+	}
+	return result
+}
+
+// Chunk splits slice into groups of size; the last group may be shorter.
+func Chunk[T any](slice []T, size int) [][]T {
+	if size <= 0 {
+		return nil
+	}
+
+	var chunks [][]T
+	for i := 0; i < len(slice); i += size {
+		end := i + size
+		if end > len(slice) {
+			end = len(slice)
+		}
+		chunks = append(chunks, slice[i:end])
+	}
+	return chunks
+}
+
+// Difference returns the elements of a that are not in b.
+func Difference[T comparable](a, b []T) []T {
+	exclude := make(map[T]bool, len(b))
+	for _, v := range b {
+		exclude[v] = true
+	}
+
+	return Filter(a, func(v T) bool {
+		return !exclude[v]
+	})
+}
+
+// Intersect returns the distinct elements of a that are also in b.
+func Intersect[T comparable](a, b []T) []T {
+	include := make(map[T]bool, len(b))
+	for _, v := range b {
+		include[v] = true
+	}
+
+	return Unique(Filter(a, func(v T) bool {
+		return include[v]
+	}))
+}
+
+func GroupBy[T any, K comparable](slice []T, key func(T) K) map[K][]T {
+	result := make(map[K][]T)
+	for _, v := range slice {
+		k := key(v)
+		result[k] = append(result[k], v)
+	}
+	return result
+}
+
+// Paginate returns the items on the given 1-based page along with the
+// clamped page number and the total number of pages. A perPage of zero or
+// less has no pages and returns an empty slice, page 1 and 0 pages.
+func Paginate[T any](slice []T, page, perPage int) ([]T, int, int) {
+	if perPage <= 0 {
+		return []T{}, 1, 0
+	}
+
+	total := len(slice)
+	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
+
+	if page > totalPages {
+		page = totalPages
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * perPage
+	end := start + perPage
+
+	if start >= total {
+		return []T{}, page, totalPages
+	}
+	if end > total {
+		end = total
+	}
+
+	return slice[start:end], page, totalPages
+}
@@ -0,0 +1,111 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"flugo.com/utils"
+)
+
+// The properties use int8 elements so that random slices share values.
+
+func TestUniqueProperties(t *testing.T) {
+	property := func(s []int8) bool {
+		once := utils.Unique(s)
+		seen := map[int8]bool{}
+		for _, v := range once {
+			if seen[v] || !utils.Contains(s, v) {
+				return false
+			}
+			seen[v] = true
+		}
+		for _, v := range s {
+			if !seen[v] {
+				return false
+			}
+		}
+		return reflect.DeepEqual(utils.Unique(once), once)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChunkProperties(t *testing.T) {
+	property := func(s []int8, size uint8) bool {
+		n := int(size%16) + 1
+		var joined []int8
+		chunks := utils.Chunk(s, n)
+		for i, chunk := range chunks {
+			if len(chunk) == 0 || len(chunk) > n || (i < len(chunks)-1 && len(chunk) != n) {
+				return false
+			}
+			joined = append(joined, chunk...)
+		}
+		return len(joined) == len(s) && (len(s) == 0 || reflect.DeepEqual(joined, s))
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+
+	if chunks := utils.Chunk([]int{1, 2}, 0); chunks != nil {
+		t.Errorf("Chunk with size 0 = %v, want nil", chunks)
+	}
+}
+
+func TestSetOperationProperties(t *testing.T) {
+	property := func(a, b []int8) bool {
+		diff, inter := utils.Difference(a, b), utils.Intersect(a, b)
+		for _, v := range a {
+			inB := utils.Contains(b, v)
+			if utils.Contains(diff, v) == inB || utils.Contains(inter, v) != inB {
+				return false
+			}
+		}
+		for _, v := range diff {
+			if !utils.Contains(a, v) || utils.Contains(b, v) {
+				return false
+			}
+		}
+		// Intersect is a set, in first-seen order of a, and symmetric as one.
+		reverse := utils.Intersect(b, a)
+		if !reflect.DeepEqual(inter, utils.Unique(inter)) || len(reverse) != len(inter) {
+			return false
+		}
+		for _, v := range reverse {
+			if !utils.Contains(inter, v) {
+				return false
+			}
+		}
+		// Every distinct element of a is in exactly one of the two results.
+		return len(utils.Unique(diff))+len(inter) == len(utils.Unique(a))
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	tests := []struct {
+		page, perPage int
+		want          []int
+		wantPage      int
+		wantPages     int
+	}{
+		{1, 2, []int{1, 2}, 1, 3},
+		{3, 2, []int{5}, 3, 3},
+		{9, 2, []int{5}, 3, 3},
+		{-1, 2, []int{1, 2}, 1, 3},
+		{1, 0, []int{}, 1, 0},
+		{2, -5, []int{}, 1, 0},
+	}
+	for _, tt := range tests {
+		got, page, pages := utils.Paginate(items, tt.page, tt.perPage)
+		if !reflect.DeepEqual(got, tt.want) || page != tt.wantPage || pages != tt.wantPages {
+			t.Errorf("Paginate(page %d, perPage %d) = %v, %d, %d; want %v, %d, %d",
+				tt.page, tt.perPage, got, page, pages, tt.want, tt.wantPage, tt.wantPages)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// StructMapOptions controls Struct2MapWithOptions and Map2StructWithOptions.
+type StructMapOptions struct {
+	// TagName is the tag keys are read from, "json" by default. Fields
+	// without it fall back to their json tag, then to the field name, so
+	// "db" works for structs that only tag their columns.
+	TagName string
+	// Flatten joins the keys of nested structs with Separator ("author.name")
+	// instead of nesting maps.
+	Flatten   bool
+	Separator string
+	// TimeFormat formats and parses time.Time values; time.RFC3339 by
+	// default. Parsing also accepts RFC 3339 with fractional seconds.
+	TimeFormat string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (o StructMapOptions) withDefaults() StructMapOptions {
+	if o.TagName == "" {
+		o.TagName = "json"
+	}
+	if o.Separator == "" {
+		o.Separator = "."
+	}
+	if o.TimeFormat == "" {
+		o.TimeFormat = time.RFC3339
+	}
+	return o
+}
+
+// Struct2Map converts a struct into a map keyed by json tags. Nested structs
+// and slices of structs become nested maps, time.Time values become RFC 3339
+// strings, and `json:"-"`, omitempty and omitzero are honored.
+func Struct2Map(obj interface{}) map[string]interface{} {
+	return Struct2MapWithOptions(obj, StructMapOptions{})
+}
+
+func Struct2MapWithOptions(obj interface{}, opts StructMapOptions) map[string]interface{} {
+	opts = opts.withDefaults()
+	result := make(map[string]interface{})
+
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return result
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return result
+	}
+
+	structToMap(v, opts, "", result)
+	return result
+}
+
+func structToMap(v reflect.Value, opts StructMapOptions, prefix string, out map[string]interface{}) {
+	for _, f := range structFields(v.Type(), opts.TagName) {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok || f.omitEmpty && isEmptyValue(fv) || f.omitZero && fv.IsZero() {
+			continue
+		}
+
+		key := prefix + f.key
+		if opts.Flatten {
+			if nested, ok := nestedStruct(fv); ok {
+				structToMap(nested, opts, key+opts.Separator, out)
+				continue
+			}
+		}
+		out[key] = toMapValue(fv, opts)
+	}
+}
+
+// nestedStruct returns the struct behind v when it should become a map
+// rather than stay a value.
+func nestedStruct(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type() == timeType || isOpaque(v.Type()) {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// isOpaque reports whether t marshals itself, such as Decimal or
+// sql.NullString, and should be kept as a value.
+func isOpaque(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	for _, iface := range []reflect.Type{
+		reflect.TypeOf((*json.Marshaler)(nil)).Elem(),
+		reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+		reflect.TypeOf((*driver.Valuer)(nil)).Elem(),
+	} {
+		if t.Implements(iface) || pt.Implements(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+func toMapValue(v reflect.Value, opts StructMapOptions) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == timeType:
+		return v.Interface().(time.Time).Format(opts.TimeFormat)
+	case v.Kind() == reflect.Struct && !isOpaque(v.Type()):
+		m := make(map[string]interface{})
+		structToMap(v, opts, "", m)
+		return m
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && convertsElements(v.Type().Elem()):
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = toMapValue(v.Index(i), opts)
+		}
+		return list
+	}
+	return v.Interface()
+}
+
+// convertsElements reports whether a slice of t must be rebuilt because its
+// elements are structs or times.
+func convertsElements(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == timeType || t.Kind() == reflect.Struct && !isOpaque(t) || t.Kind() == reflect.Interface
+}
+
+// Map2Struct fills obj, a pointer to a struct, from m as produced by
+// Struct2Map: nested maps fill nested structs and strings are parsed into
+// time.Time fields.
+func Map2Struct(m map[string]interface{}, obj interface{}) error {
+	return Map2StructWithOptions(m, obj, StructMapOptions{})
+}
+
+func Map2StructWithOptions(m map[string]interface{}, obj interface{}, opts StructMapOptions) error {
+	opts = opts.withDefaults()
+
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Map2Struct: target must be a non-nil pointer to a struct, got %T", obj)
+	}
+	if opts.Flatten {
+		m = unflatten(m, opts.Separator)
+	}
+	return mapToStruct(m, v.Elem(), opts)
+}
+
+func mapToStruct(m map[string]interface{}, v reflect.Value, opts StructMapOptions) error {
+	for _, f := range structFields(v.Type(), opts.TagName) {
+		raw, ok := m[f.key]
+		if !ok {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			continue
+		}
+		if err := assignValue(fv, raw, opts); err != nil {
+			return fmt.Errorf("field %s: %w", f.key, err)
+		}
+	}
+	return nil
+}
+
+func assignValue(dst reflect.Value, raw interface{}, opts StructMapOptions) error {
+	if raw == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(raw)
+	if rv.Type().AssignableTo(dst.Type()) {
+		dst.Set(rv)
+		return nil
+	}
+
+	switch {
+	case dst.Kind() == reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := assignValue(elem.Elem(), raw, opts); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+
+	case dst.Type() == timeType:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("cannot parse %T as a time", raw)
+		}
+		t, err := time.Parse(opts.TimeFormat, s)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				return err
+			}
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+
+	case dst.Kind() == reflect.Struct && !isOpaque(dst.Type()):
+		if nested, ok := raw.(map[string]interface{}); ok {
+			return mapToStruct(nested, dst, opts)
+		}
+
+	case dst.Kind() == reflect.Slice && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array):
+		list := reflect.MakeSlice(dst.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := assignValue(list.Index(i), rv.Index(i).Interface(), opts); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		dst.Set(list)
+		return nil
+	}
+
+	// Numbers, strings in custom types, maps and self-unmarshaling types go
+	// through encoding/json, as Map2Struct always did.
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst.Addr().Interface())
+}
+
+// unflatten turns {"author.name": "x"} back into {"author": {"name": "x"}}.
+func unflatten(m map[string]interface{}, sep string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for key, value := range m {
+		parts := strings.Split(key, sep)
+		node := out
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+	return out
+}
+
+type structField struct {
+	index     []int
+	key       string
+	omitEmpty bool
+	omitZero  bool
+}
+
+// structFields lists the keyed fields of t, promoting the fields of
+// untagged embedded structs as encoding/json does. Outer fields win over
+// promoted ones with the same key.
+func structFields(t reflect.Type, tagName string) []structField {
+	var fields []structField
+	seen := make(map[string]bool)
+	var embedded [][]int
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, tagged := fieldTag(field, tagName)
+		if name == "-" && options == "" {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && !tagged && ft.Kind() == reflect.Struct && ft != timeType {
+			embedded = append(embedded, []int{i})
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		seen[name] = true
+		fields = append(fields, structField{
+			index:     []int{i},
+			key:       name,
+			omitEmpty: hasOption(options, "omitempty"),
+			omitZero:  hasOption(options, "omitzero"),
+		})
+	}
+
+	for _, index := range embedded {
+		ft := t.Field(index[0]).Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		for _, f := range structFields(ft, tagName) {
+			if seen[f.key] {
+				continue
+			}
+			seen[f.key] = true
+			f.index = append(append([]int{}, index...), f.index...)
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// fieldTag reads the key and options from tagName, falling back to the
+// json tag. tagged reports whether a tag named the field.
+func fieldTag(field reflect.StructField, tagName string) (name, options string, tagged bool) {
+	tag, ok := field.Tag.Lookup(tagName)
+	if !ok && tagName != "json" {
+		tag, ok = field.Tag.Lookup("json")
+	}
+	if !ok {
+		return "", "", false
+	}
+	name, options, _ = strings.Cut(tag, ",")
+	return name, options, name != ""
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldByIndex walks index through embedded pointers, allocating nil ones
+// when alloc is set; otherwise a nil pointer reports false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue follows encoding/json's omitempty rules.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"flugo.com/utils"
+)
+
+type author struct {
+	Name  string `json:"name" db:"author_name"`
+	Email string `json:"email,omitempty"`
+}
+
+type audit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type post struct {
+	audit
+	ID        int           `json:"id" db:"post_id"`
+	Title     string        `json:"title"`
+	Author    author        `json:"author"`
+	Reviewers []author      `json:"reviewers"`
+	Published time.Time     `json:"published"`
+	EditedAt  *time.Time    `json:"edited_at,omitempty"`
+	Price     utils.Decimal `json:"price"`
+	Tags      []string      `json:"tags,omitempty"`
+	Draft     bool          `json:"draft,omitempty"`
+	Secret    string        `json:"-"`
+	internal  int
+}
+
+func samplePost() post {
+	published := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	edited := published.Add(2 * time.Hour)
+	return post{
+		audit:     audit{CreatedBy: "admin"},
+		ID:        7,
+		Title:     "Hello",
+		Author:    author{Name: "Ann", Email: "ann@example.com"},
+		Reviewers: []author{{Name: "Bob"}, {Name: "Cy"}},
+		Published: published,
+		EditedAt:  &edited,
+		Price:     utils.MustParseDecimal("9.99"),
+		Secret:    "hidden",
+		internal:  1,
+	}
+}
+
+func TestStruct2Map(t *testing.T) {
+	m := utils.Struct2Map(samplePost())
+
+	want := map[string]interface{}{
+		"created_by": "admin",
+		"id":         7,
+		"title":      "Hello",
+		"author":     map[string]interface{}{"name": "Ann", "email": "ann@example.com"},
+		"reviewers": []interface{}{
+			map[string]interface{}{"name": "Bob"},
+			map[string]interface{}{"name": "Cy"},
+		},
+		"published": "2024-03-01T09:30:00Z",
+		"edited_at": "2024-03-01T11:30:00Z",
+		"price":     utils.MustParseDecimal("9.99"),
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Struct2Map() =\n%#v\nwant\n%#v", m, want)
+	}
+}
+
+func TestStruct2MapFlattenAndTags(t *testing.T) {
+	m := utils.Struct2MapWithOptions(samplePost(), utils.StructMapOptions{
+		TagName:    "db",
+		Flatten:    true,
+		TimeFormat: "2006-01-02",
+	})
+
+	for key, want := range map[string]interface{}{
+		"post_id":            7,
+		"author.author_name": "Ann",
+		"author.email":       "ann@example.com",
+		"published":          "2024-03-01",
+	} {
+		if m[key] != want {
+			t.Errorf("m[%q] = %#v, want %#v", key, m[key], want)
+		}
+	}
+	if _, ok := m["author"]; ok {
+		t.Error("flattened map still has a nested author")
+	}
+}
+
+func TestMapRoundTrip(t *testing.T) {
+	for name, opts := range map[string]utils.StructMapOptions{
+		"nested":    {},
+		"flattened": {Flatten: true, Separator: "__"},
+		"db tags":   {TagName: "db", TimeFormat: time.RFC1123Z},
+	} {
+		t.Run(name, func(t *testing.T) {
+			in := samplePost()
+			var out post
+			if err := utils.Map2StructWithOptions(utils.Struct2MapWithOptions(in, opts), &out, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			in.Secret, in.internal = "", 0
+			if !out.Published.Equal(in.Published) || !out.EditedAt.Equal(*in.EditedAt) {
+				t.Errorf("times = %v, %v, want %v, %v", out.Published, out.EditedAt, in.Published, in.EditedAt)
+			}
+			out.Published, out.EditedAt = in.Published, in.EditedAt
+			if !reflect.DeepEqual(out, in) {
+				t.Errorf("round trip =\n%+v\nwant\n%+v", out, in)
+			}
+		})
+	}
+}
+
+func TestMap2StructFromJSONTypes(t *testing.T) {
+	var out post
+	err := utils.Map2Struct(map[string]interface{}{
+		"id":        float64(3),
+		"published": "2024-03-01T09:30:00.5Z",
+		"author":    map[string]interface{}{"name": "Ann"},
+		"tags":      []interface{}{"go", "web"},
+		"price":     "1.50",
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.ID != 3 || out.Author.Name != "Ann" || len(out.Tags) != 2 || out.Price.String() != "1.50" {
+		t.Errorf("Map2Struct() = %+v", out)
+	}
+	if out.Published.Nanosecond() != 5e8 {
+		t.Errorf("published = %v, want fractional seconds kept", out.Published)
+	}
+
+	if err := utils.Map2Struct(map[string]interface{}{"published": "yesterday"}, &out); err == nil {
+		t.Error("Map2Struct accepted an unparseable time")
+	}
+	if err := utils.Map2Struct(nil, out); err == nil {
+		t.Error("Map2Struct accepted a non-pointer target")
+	}
+}
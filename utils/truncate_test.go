@@ -0,0 +1,71 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"flugo.com/utils"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		length int
+		want   string
+	}{
+		{"ascii", "hello world", 5, "hello..."},
+		{"short enough", "héllo", 5, "héllo"},
+		{"cjk", "日本語のテキスト", 3, "日本語..."},
+		{"emoji", "😀😃😄", 2, "😀😃..."},
+		{"combining accent", "ééé", 3, "é..."},
+		{"keycap", "1️⃣2️⃣", 3, "1️⃣..."},
+		{"keycap too long", "1️⃣2️⃣", 2, "..."},
+		{"zwj family", "👨‍👩‍👧 ok", 2, "..."},
+		{"whole zwj family", "👨‍👩‍👧 ok", 5, "👨‍👩‍👧..."},
+		{"skin tone", "👍🏽👍🏽", 3, "👍🏽..."},
+		{"flags", "🇯🇵🇫🇷🇩🇪", 3, "🇯🇵..."},
+		{"trailing space", "ab  cd", 3, "ab..."},
+		{"negative length", "abc", -1, "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utils.Truncate(tt.s, tt.length)
+			if got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.length, got, tt.want)
+			}
+			if !utf8.ValidString(got) || !strings.HasPrefix(tt.s, strings.TrimSuffix(got, "...")) {
+				t.Errorf("Truncate(%q, %d) = %q is not a valid prefix", tt.s, tt.length, got)
+			}
+		})
+	}
+}
+
+func TestTruncateHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		length int
+		want   string
+	}{
+		{"cjk words", "<p>東京 タワー</p><p>😀 emoji</p>", 4, "東京..."},
+		{"emoji words", "<b>🎉 party</b> 🎈 time", 8, "🎉 party..."},
+		{"entity accent", "<i>caf&eacute; au lait</i>", 7, "café au..."},
+		{"numeric combining", "<b>cafe&#769;</b> au lait", 4, "caf..."},
+		{"script dropped", "<script>alert('é')</script>ü <br>ö", 10, "ü ö"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utils.TruncateHTML(tt.s, tt.length)
+			if got != tt.want {
+				t.Errorf("TruncateHTML(%q, %d) = %q, want %q", tt.s, tt.length, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateHTML(%q, %d) = %q is not valid UTF-8", tt.s, tt.length, got)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"crypto/md5"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -16,34 +15,6 @@ import (
 	"unicode"
 )
 
-func RandomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	bytes := make([]byte, length)
-	rand.Read(bytes)
-	for i, b := range bytes {
-		bytes[i] = charset[b%byte(len(charset))]
-	}
-	return string(bytes)
-}
-
-func RandomInt(min, max int) int {
-	bytes := make([]byte, 4)
-	rand.Read(bytes)
-	n := int(bytes[0])<<24 | int(bytes[1])<<16 | int(bytes[2])<<8 | int(bytes[3])
-	if n < 0 {
-		n = -n
-	}
-	return min + n%(max-min+1)
-}
-
-func UUID() string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	bytes[6] = (bytes[6] & 0x0f) | 0x40
-	bytes[8] = (bytes[8] & 0x3f) | 0x80
-	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:])
-}
-
 func MD5(text string) string {
 	hash := md5.Sum([]byte(text))
 	return hex.EncodeToString(hash[:])
@@ -54,50 +25,16 @@ func SHA256(text string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func Contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
-}
-
 func ContainsInt(slice []int, item int) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
+	return Contains(slice, item)
 }
 
 func UniqueStrings(slice []string) []string {
-	seen := make(map[string]bool)
-	result := []string{}
-
-	for _, item := range slice {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
-		}
-	}
-
-	return result
+	return Unique(slice)
 }
 
 func UniqueInts(slice []int) []int {
-	seen := make(map[int]bool)
-	result := []int{}
-
-	for _, item := range slice {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
-		}
-	}
-
-	return result
+	return Unique(slice)
 }
 
 func Reverse(s string) string {
@@ -108,13 +45,71 @@ func Reverse(s string) string {
 	return string(runes)
 }
 
+// Truncate shortens s to at most length characters (runes) followed by
+// "...", never splitting a character from its combining marks.
 func Truncate(s string, length int) string {
+	return TruncateWithOptions(s, length, TruncateOptions{Ellipsis: "..."})
+}
+
+// TruncateBytes cuts s at a byte offset. The result may be invalid UTF-8.
+func TruncateBytes(s string, length int) string {
 	if len(s) <= length {
 		return s
 	}
 	return s[:length] + "..."
 }
 
+type TruncateOptions struct {
+	Ellipsis     string
+	WordBoundary bool
+}
+
+func TruncateWithOptions(s string, length int, opts TruncateOptions) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	if length < 0 {
+		length = 0
+	}
+
+	cut := length
+	for cut > 0 && (isCombining(runes[cut]) || runes[cut-1] == '\u200d') {
+		cut--
+	}
+	// Flags are pairs of regional indicators; an odd run before the cut
+	// means it falls inside one.
+	indicators := 0
+	for i := cut - 1; i >= 0 && isRegionalIndicator(runes[i]); i-- {
+		indicators++
+	}
+	if indicators%2 == 1 && isRegionalIndicator(runes[cut]) {
+		cut--
+	}
+
+	if opts.WordBoundary {
+		for i := cut; i > 0; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + opts.Ellipsis
+}
+
+// isCombining reports whether r attaches to the rune before it: marks,
+// joiners, variation selectors and emoji skin tones.
+func isCombining(r rune) bool {
+	return unicode.Is(unicode.M, r) || r == '\u200d' || unicode.Is(unicode.Variation_Selector, r) ||
+		r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
 func TruncateWords(s string, words int) string {
 	wordSlice := strings.Fields(s)
 	if len(wordSlice) <= words {
@@ -300,110 +295,15 @@ func ClampFloat(value, min, max float64) float64 {
 }
 
 func MapStrings(slice []string, fn func(string) string) []string {
-	result := make([]string, len(slice))
-	for i, v := range slice {
-		result[i] = fn(v)
-	}
-	return result
+	return Map(slice, fn)
 }
 
 func FilterStrings(slice []string, fn func(string) bool) []string {
-	result := []string{}
-	for _, v := range slice {
-		if fn(v) {
-			result = append(result, v)
-		}
-	}
-	return result
+	return Filter(slice, fn)
 }
 
 func ReduceStrings(slice []string, fn func(string, string) string, initial string) string {
-	result := initial
-	for _, v := range slice {
-		result = fn(result, v)
-	}
-	return result
-}
-
-func Chunk(slice []string, size int) [][]string {
-	if size <= 0 {
-		return nil
-	}
-
-	var chunks [][]string
-	for i := 0; i < len(slice); i += size {
-		end := i + size
-		if end > len(slice) {
-			end = len(slice)
-		}
-		chunks = append(chunks, slice[i:end])
-	}
-	return chunks
-}
-
-func Paginate(slice []string, page, perPage int) ([]string, int, int) {
-	total := len(slice)
-	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
-
-	if page < 1 {
-		page = 1
-	}
-	if page > totalPages {
-		page = totalPages
-	}
-
-	start := (page - 1) * perPage
-	end := start + perPage
-
-	if start > total {
-		return []string{}, page, totalPages
-	}
-	if end > total {
-		end = total
-	}
-
-	return slice[start:end], page, totalPages
-}
-
-func Struct2Map(obj interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	v := reflect.ValueOf(obj)
-
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-
-	if v.Kind() != reflect.Struct {
-		return result
-	}
-
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		if field.PkgPath != "" { // Skip unexported fields
-			continue
-		}
-
-		key := field.Name
-		if tag := field.Tag.Get("json"); tag != "" && tag != "-" {
-			parts := strings.Split(tag, ",")
-			if parts[0] != "" {
-				key = parts[0]
-			}
-		}
-
-		result[key] = v.Field(i).Interface()
-	}
-
-	return result
-}
-
-func Map2Struct(m map[string]interface{}, obj interface{}) error {
-	jsonBytes, err := json.Marshal(m)
-	if err != nil {
-		return err
-	}
-	return json.Unmarshal(jsonBytes, obj)
+	return Reduce(slice, fn, initial)
 }
 
 func FormatBytes(bytes int64) string {
@@ -435,28 +335,6 @@ func FormatDuration(d time.Duration) string {
 	return fmt.Sprintf("%.1fd", d.Hours()/24)
 }
 
-func HumanizeTime(t time.Time) string {
-	now := time.Now()
-	diff := now.Sub(t)
-
-	if diff < time.Minute {
-		return "just now"
-	}
-	if diff < time.Hour {
-		return fmt.Sprintf("%d minutes ago", int(diff.Minutes()))
-	}
-	if diff < 24*time.Hour {
-		return fmt.Sprintf("%d hours ago", int(diff.Hours()))
-	}
-	if diff < 30*24*time.Hour {
-		return fmt.Sprintf("%d days ago", int(diff.Hours()/24))
-	}
-	if diff < 365*24*time.Hour {
-		return fmt.Sprintf("%d months ago", int(diff.Hours()/(24*30)))
-	}
-	return fmt.Sprintf("%d years ago", int(diff.Hours()/(24*365)))
-}
-
 func ParseDate(dateStr, layout string) (time.Time, error) {
 	return time.Parse(layout, dateStr)
 }
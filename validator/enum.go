@@ -0,0 +1,126 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RegisterEnum names a set of allowed values, so DTOs share one definition
+// through `enum:"@name"`. Values may be typed constants of a string or
+// numeric kind; they are kept as plain strings and numbers.
+//
+//	validator.RegisterEnum("post_status", []interface{}{StatusDraft, StatusPublished, StatusArchived})
+func RegisterEnum(name string, values []interface{}) {
+	DefaultValidator.RegisterEnum(name, values)
+}
+
+func (v *Validator) RegisterEnum(name string, values []interface{}) {
+	plain := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		rv := reflect.ValueOf(value)
+		switch {
+		case rv.Kind() == reflect.String:
+			plain = append(plain, rv.String())
+		case rv.CanInt():
+			plain = append(plain, rv.Int())
+		case rv.CanUint():
+			plain = append(plain, rv.Uint())
+		case rv.CanFloat():
+			plain = append(plain, rv.Float())
+		default:
+			panic(fmt.Sprintf("validator: enum %s has a %T value; use strings or numbers", name, value))
+		}
+	}
+	v.enums[name] = plain
+}
+
+// EnumValues returns the values, as strings, int64s, uint64s or float64s,
+// that an enum tag allows for a field of type t:
+// the registered enum for "@name", or the inline list parsed to t's kind,
+// so `enum:"1,2,3"` on an int field allows the numbers 1, 2 and 3. An
+// unknown name allows nothing.
+func (v *Validator) EnumValues(spec string, t reflect.Type) []interface{} {
+	if name, ok := strings.CutPrefix(spec, "@"); ok {
+		return v.enums[name]
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var values []interface{}
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				values = append(values, n)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n, err := strconv.ParseUint(raw, 10, 64); err == nil {
+				values = append(values, n)
+			}
+		case reflect.Float32, reflect.Float64:
+			if f, err := strconv.ParseFloat(raw, 64); err == nil {
+				values = append(values, f)
+			}
+		default:
+			values = append(values, raw)
+		}
+	}
+	return values
+}
+
+// EnumValues resolves an enum tag with DefaultValidator.
+func EnumValues(spec string, t reflect.Type) []interface{} {
+	return DefaultValidator.EnumValues(spec, t)
+}
+
+// isInEnum compares value with each allowed value by kind rather than
+// string form, so 1.0 matches 1 and a named string type matches its
+// constants.
+func (v *Validator) isInEnum(value reflect.Value, allowed []interface{}) bool {
+	for _, a := range allowed {
+		if sameValue(value, reflect.ValueOf(a)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameValue(value, allowed reflect.Value) bool {
+	if value.Kind() == reflect.String || allowed.Kind() == reflect.String {
+		return value.Kind() == allowed.Kind() && value.String() == allowed.String()
+	}
+	if value.CanInt() && allowed.CanInt() {
+		return value.Int() == allowed.Int()
+	}
+	if value.CanUint() && allowed.CanUint() {
+		return value.Uint() == allowed.Uint()
+	}
+	x, ok := floatValue(value)
+	y, allowedOK := floatValue(allowed)
+	return ok && allowedOK && x == y
+}
+
+func floatValue(v reflect.Value) (float64, bool) {
+	switch {
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	case v.CanFloat():
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// formatEnum lists allowed values for messages as a client sends them.
+func formatEnum(values []interface{}) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprint(value)
+	}
+	return strings.Join(formatted, ", ")
+}
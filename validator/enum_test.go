@@ -0,0 +1,61 @@
+package validator_test
+
+import (
+	"testing"
+
+	"flugo.com/validator"
+)
+
+type postStatus string
+
+const (
+	statusDraft     postStatus = "draft"
+	statusPublished postStatus = "published"
+)
+
+type priority int
+
+func (p priority) String() string { return [...]string{"none", "low", "high"}[p] }
+
+type post struct {
+	Status   postStatus `json:"status" enum:"@post_status"`
+	Priority priority   `json:"priority" enum:"1,2"`
+	Rating   float64    `json:"rating" enum:"0.5, 1"`
+	Kind     string     `json:"kind" enum:"@missing"`
+}
+
+func init() {
+	validator.RegisterEnum("post_status", []interface{}{statusDraft, statusPublished})
+}
+
+func TestEnumAcceptsTypedAndNumericValues(t *testing.T) {
+	if err := validator.Validate(post{Status: statusPublished, Priority: 2, Rating: 0.50}); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestEnumRejections(t *testing.T) {
+	errs := validationErrors(t, post{Status: "deleted", Priority: 3, Rating: 2, Kind: "anything"})
+
+	want := map[string]string{
+		"status":   "must be one of: draft, published",
+		"priority": "must be one of: 1, 2",
+		"rating":   "must be one of: 0.5, 1",
+		"kind":     "must be one of: ",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v", errs)
+	}
+	for _, err := range errs {
+		if err.Code != validator.CodeEnum || err.Message != want[err.Field] {
+			t.Errorf("%s: %s %q, want %q", err.Field, err.Code, err.Message, want[err.Field])
+		}
+	}
+}
+
+func TestEnumValues(t *testing.T) {
+	values := validator.EnumValues("@post_status", nil)
+	if len(values) != 2 || values[0] != "draft" {
+		t.Errorf("registered values = %#v", values)
+	}
+}
@@ -10,13 +10,78 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"flugo.com/i18n"
+	"flugo.com/utils"
+)
+
+// Error codes are part of the API: clients localize on them, so they do not
+// change between releases. A custom validator's code is its tag.
+const (
+	CodeRequired         = "required"
+	CodeMinLength        = "min_length"
+	CodeMaxLength        = "max_length"
+	CodeEmail            = "email"
+	CodeURL              = "url"
+	CodePhone            = "phone"
+	CodeAlphanumeric     = "alphanumeric"
+	CodeAlpha            = "alpha"
+	CodeNumeric          = "numeric"
+	CodeIP               = "ip"
+	CodeDate             = "date"
+	CodeRegex            = "regex"
+	CodePasswordStrength = "password_strength"
+	CodeEnum             = "enum"
+	CodeMin              = "min"
+	CodeMax              = "max"
+	CodeMinItems         = "min_items"
+	CodeMaxItems         = "max_items"
+	// CodeType and CodeConflict are reported by binders: a value that does
+	// not convert to the field's type, and one given differently by two
+	// sources, such as the path and the body.
+	CodeType     = "type"
+	CodeConflict = "conflict"
 )
 
+// ValidationError describes one failed rule. Path is the JSON Pointer of the
+// value in the request body, e.g. /items/2/price, and Field its dotted form.
 type ValidationError struct {
 	Field   string `json:"field"`
+	Path    string `json:"path"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
 	Tag     string `json:"tag"`
 	Value   string `json:"value"`
+
+	key    string
+	params map[string]interface{}
+}
+
+// fieldError builds the error for a built-in tag, whose message is the
+// "validation.<tag>" catalog entry in the default locale.
+func fieldError(field, tag, value string, params map[string]interface{}) ValidationError {
+	return newError(field, tag, value, "validation."+tag, params)
+}
+
+// FieldError builds the error for a top-level field with code, whose
+// message is the "validation.<code>" catalog entry. Binders use it to report
+// their failures alongside Validate's.
+func FieldError(field, code, value string, params map[string]interface{}) ValidationError {
+	err := fieldError(field, code, value, params)
+	err.Path = "/" + escapePointer(field)
+	return err
+}
+
+func newError(field, tag, value, key string, params map[string]interface{}) ValidationError {
+	return ValidationError{
+		Field:   field,
+		Code:    tag,
+		Message: i18n.T("", key, params),
+		Tag:     tag,
+		Value:   value,
+		key:     key,
+		params:  params,
+	}
 }
 
 type ValidationErrors []ValidationError
@@ -33,15 +98,38 @@ func (v ValidationErrors) HasErrors() bool {
 	return len(v) > 0
 }
 
+// ToMap groups the messages by path, the form form libraries bind to inputs.
+func (v ValidationErrors) ToMap() map[string][]string {
+	grouped := make(map[string][]string, len(v))
+	for _, err := range v {
+		grouped[err.Path] = append(grouped[err.Path], err.Message)
+	}
+	return grouped
+}
+
+// Localize returns a copy with every message translated for locale.
+func (v ValidationErrors) Localize(locale string) ValidationErrors {
+	localized := make(ValidationErrors, len(v))
+	for i, err := range v {
+		if err.key != "" {
+			err.Message = i18n.T(locale, err.key, err.params)
+		}
+		localized[i] = err
+	}
+	return localized
+}
+
 type Validator struct {
 	customValidators map[string]func(interface{}) bool
 	customMessages   map[string]string
+	enums            map[string][]interface{}
 }
 
 func New() *Validator {
 	return &Validator{
 		customValidators: make(map[string]func(interface{}) bool),
 		customMessages:   make(map[string]string),
+		enums:            make(map[string][]interface{}),
 	}
 }
 
@@ -57,8 +145,6 @@ func Validate(target interface{}) error {
 }
 
 func (v *Validator) Validate(target interface{}) error {
-	var errors ValidationErrors
-
 	val := reflect.ValueOf(target)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -68,38 +154,98 @@ func (v *Validator) Validate(target interface{}) error {
 		return fmt.Errorf("target must be a struct or pointer to struct")
 	}
 
+	errors := v.validateStruct(val, "", "")
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+// validateStruct validates the fields of val, then the structs nested in
+// them. path and field locate val in the target.
+func (v *Validator) validateStruct(val reflect.Value, path, field string) ValidationErrors {
+	var errors ValidationErrors
 	typ := val.Type()
 
 	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
+		structField := typ.Field(i)
 		fieldValue := val.Field(i)
 
 		if !fieldValue.CanInterface() {
 			continue
 		}
 
-		fieldErrors := v.validateField(field, fieldValue)
+		name, named := jsonName(structField)
+		fieldPath, fieldField := path, field
+		// Embedded structs without a JSON name are flattened by encoding/json.
+		if !structField.Anonymous || named {
+			fieldPath = path + "/" + escapePointer(name)
+			fieldField = joinField(field, name)
+		}
+
+		fieldErrors := v.validateField(structField, fieldValue, name)
+		for i := range fieldErrors {
+			fieldErrors[i].Field = fieldField
+			fieldErrors[i].Path = fieldPath
+		}
 		errors = append(errors, fieldErrors...)
+		errors = append(errors, v.validateNested(fieldValue, fieldPath, fieldField)...)
 	}
 
-	if len(errors) > 0 {
-		return errors
+	return errors
+}
+
+// validateNested validates a struct value, or each struct element of a
+// slice or array.
+func (v *Validator) validateNested(val reflect.Value, path, field string) ValidationErrors {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
 	}
 
+	switch val.Kind() {
+	case reflect.Struct:
+		return v.validateStruct(val, path, field)
+	case reflect.Slice, reflect.Array:
+		var errors ValidationErrors
+		for i := 0; i < val.Len(); i++ {
+			index := strconv.Itoa(i)
+			errors = append(errors, v.validateNested(val.Index(i), path+"/"+index, joinField(field, index))...)
+		}
+		return errors
+	}
 	return nil
 }
 
-func (v *Validator) validateField(field reflect.StructField, value reflect.Value) []ValidationError {
-	var errors []ValidationError
-	tag := field.Tag
-	fieldName := field.Name
-
-	if jsonTag := tag.Get("json"); jsonTag != "" && jsonTag != "-" {
-		parts := strings.Split(jsonTag, ",")
-		if parts[0] != "" {
-			fieldName = parts[0]
+// jsonName returns the name a field has in JSON, and whether a json tag
+// set it.
+func jsonName(field reflect.StructField) (string, bool) {
+	if jsonTag := field.Tag.Get("json"); jsonTag != "" && jsonTag != "-" {
+		if name, _, _ := strings.Cut(jsonTag, ","); name != "" {
+			return name, true
 		}
 	}
+	return field.Name, false
+}
+
+func joinField(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// escapePointer escapes a JSON Pointer reference token (RFC 6901).
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func (v *Validator) validateField(field reflect.StructField, value reflect.Value, fieldName string) []ValidationError {
+	var errors []ValidationError
+	tag := field.Tag
 
 	fieldInterface := value.Interface()
 	fieldStr := fmt.Sprintf("%v", fieldInterface)
@@ -107,12 +253,7 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 	// Required validation
 	if tag.Get("required") == "true" {
 		if v.isZeroValue(value) {
-			errors = append(errors, ValidationError{
-				Field:   fieldName,
-				Message: "field is required",
-				Tag:     "required",
-				Value:   fieldStr,
-			})
+			errors = append(errors, fieldError(fieldName, "required", fieldStr, nil))
 			return errors
 		}
 	}
@@ -127,12 +268,7 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 		if minLenStr := tag.Get("min_length"); minLenStr != "" {
 			if minLen, err := strconv.Atoi(minLenStr); err == nil {
 				if len(strValue) < minLen {
-					errors = append(errors, ValidationError{
-						Field:   fieldName,
-						Message: fmt.Sprintf("minimum length is %d characters", minLen),
-						Tag:     "min_length",
-						Value:   fieldStr,
-					})
+					errors = append(errors, fieldError(fieldName, "min_length", fieldStr, map[string]interface{}{"min": minLen}))
 				}
 			}
 		}
@@ -140,139 +276,93 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 		if maxLenStr := tag.Get("max_length"); maxLenStr != "" {
 			if maxLen, err := strconv.Atoi(maxLenStr); err == nil {
 				if len(strValue) > maxLen {
-					errors = append(errors, ValidationError{
-						Field:   fieldName,
-						Message: fmt.Sprintf("maximum length is %d characters", maxLen),
-						Tag:     "max_length",
-						Value:   fieldStr,
-					})
+					errors = append(errors, fieldError(fieldName, "max_length", fieldStr, map[string]interface{}{"max": maxLen}))
 				}
 			}
 		}
 
 		if tag.Get("email") == "true" {
 			if !v.isValidEmail(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must be a valid email address",
-					Tag:     "email",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "email", fieldStr, nil))
 			}
 		}
 
 		if tag.Get("url") == "true" {
 			if !v.isValidURL(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must be a valid URL",
-					Tag:     "url",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "url", fieldStr, nil))
 			}
 		}
 
 		if tag.Get("phone") == "true" {
 			if !v.isValidPhone(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must be a valid phone number",
-					Tag:     "phone",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "phone", fieldStr, nil))
 			}
 		}
 
 		if tag.Get("alphanumeric") == "true" {
 			if !v.isAlphanumeric(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must contain only letters and numbers",
-					Tag:     "alphanumeric",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "alphanumeric", fieldStr, nil))
 			}
 		}
 
 		if tag.Get("alpha") == "true" {
 			if !v.isAlpha(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must contain only letters",
-					Tag:     "alpha",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "alpha", fieldStr, nil))
 			}
 		}
 
 		if tag.Get("numeric") == "true" {
 			if !v.isNumeric(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must contain only numbers",
-					Tag:     "numeric",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "numeric", fieldStr, nil))
 			}
 		}
 
 		if tag.Get("ip") == "true" {
 			if !v.isValidIP(strValue) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "must be a valid IP address",
-					Tag:     "ip",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "ip", fieldStr, nil))
 			}
 		}
 
 		if dateFormat := tag.Get("date"); dateFormat != "" {
 			if !v.isValidDate(strValue, dateFormat) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: fmt.Sprintf("must be a valid date in format %s", dateFormat),
-					Tag:     "date",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "date", fieldStr, map[string]interface{}{"format": dateFormat}))
 			}
 		}
 
 		if regexPattern := tag.Get("regex"); regexPattern != "" {
 			if !v.matchesRegex(strValue, regexPattern) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: "does not match required pattern",
-					Tag:     "regex",
-					Value:   fieldStr,
-				})
+				errors = append(errors, fieldError(fieldName, "regex", fieldStr, nil))
 			}
 		}
 
-		if enumValues := tag.Get("enum"); enumValues != "" {
-			if !v.isInEnum(strValue, enumValues) {
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: fmt.Sprintf("must be one of: %s", enumValues),
-					Tag:     "enum",
-					Value:   fieldStr,
-				})
+		if minScoreStr := tag.Get("password_strength"); minScoreStr != "" {
+			if minScore, err := strconv.Atoi(minScoreStr); err == nil {
+				if score, feedback := utils.PasswordStrength(strValue); score < minScore {
+					params := map[string]interface{}{"score": score, "min": minScore}
+					err := fieldError(fieldName, "password_strength", "", params)
+					if len(feedback) > 0 {
+						params["feedback"] = strings.Join(feedback, "; ")
+						err = newError(fieldName, "password_strength", "", "validation.password_strength_feedback", params)
+					}
+					errors = append(errors, err)
+				}
 			}
 		}
 	}
 
+	if spec := tag.Get("enum"); spec != "" && (value.Kind() == reflect.String || v.isNumericType(value)) {
+		if allowed := v.EnumValues(spec, value.Type()); !v.isInEnum(value, allowed) {
+			errors = append(errors, fieldError(fieldName, "enum", fieldStr, map[string]interface{}{"values": formatEnum(allowed)}))
+		}
+	}
+
 	if v.isNumericType(value) {
 		numValue := v.getNumericValue(value)
 
 		if minStr := tag.Get("min"); minStr != "" {
 			if min, err := strconv.ParseFloat(minStr, 64); err == nil {
 				if numValue < min {
-					errors = append(errors, ValidationError{
-						Field:   fieldName,
-						Message: fmt.Sprintf("minimum value is %v", min),
-						Tag:     "min",
-						Value:   fieldStr,
-					})
+					errors = append(errors, fieldError(fieldName, "min", fieldStr, map[string]interface{}{"min": min}))
 				}
 			}
 		}
@@ -280,12 +370,7 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 		if maxStr := tag.Get("max"); maxStr != "" {
 			if max, err := strconv.ParseFloat(maxStr, 64); err == nil {
 				if numValue > max {
-					errors = append(errors, ValidationError{
-						Field:   fieldName,
-						Message: fmt.Sprintf("maximum value is %v", max),
-						Tag:     "max",
-						Value:   fieldStr,
-					})
+					errors = append(errors, fieldError(fieldName, "max", fieldStr, map[string]interface{}{"max": max}))
 				}
 			}
 		}
@@ -295,12 +380,7 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 		if minItemsStr := tag.Get("min_items"); minItemsStr != "" {
 			if minItems, err := strconv.Atoi(minItemsStr); err == nil {
 				if value.Len() < minItems {
-					errors = append(errors, ValidationError{
-						Field:   fieldName,
-						Message: fmt.Sprintf("minimum items is %d", minItems),
-						Tag:     "min_items",
-						Value:   fieldStr,
-					})
+					errors = append(errors, fieldError(fieldName, "min_items", fieldStr, map[string]interface{}{"min": minItems}))
 				}
 			}
 		}
@@ -308,12 +388,7 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 		if maxItemsStr := tag.Get("max_items"); maxItemsStr != "" {
 			if maxItems, err := strconv.Atoi(maxItemsStr); err == nil {
 				if value.Len() > maxItems {
-					errors = append(errors, ValidationError{
-						Field:   fieldName,
-						Message: fmt.Sprintf("maximum items is %d", maxItems),
-						Tag:     "max_items",
-						Value:   fieldStr,
-					})
+					errors = append(errors, fieldError(fieldName, "max_items", fieldStr, map[string]interface{}{"max": maxItems}))
 				}
 			}
 		}
@@ -322,16 +397,14 @@ func (v *Validator) validateField(field reflect.StructField, value reflect.Value
 	for tag, validator := range v.customValidators {
 		if field.Tag.Get(tag) == "true" {
 			if !validator(fieldInterface) {
-				message := v.customMessages[tag]
-				if message == "" {
-					message = fmt.Sprintf("failed custom validation: %s", tag)
+				err := fieldError(fieldName, "custom", fieldStr, map[string]interface{}{"tag": tag})
+				err.Tag = tag
+				err.Code = tag
+				// Registered messages double as catalog keys.
+				if message := v.customMessages[tag]; message != "" {
+					err = newError(fieldName, tag, fieldStr, message, nil)
 				}
-				errors = append(errors, ValidationError{
-					Field:   fieldName,
-					Message: message,
-					Tag:     tag,
-					Value:   fieldStr,
-				})
+				errors = append(errors, err)
 			}
 		}
 	}
@@ -407,16 +480,6 @@ func (v *Validator) matchesRegex(str, pattern string) bool {
 	return regex.MatchString(str)
 }
 
-func (v *Validator) isInEnum(value, enumStr string) bool {
-	enumValues := strings.Split(enumStr, ",")
-	for _, enumValue := range enumValues {
-		if strings.TrimSpace(enumValue) == value {
-			return true
-		}
-	}
-	return false
-}
-
 func (v *Validator) isNumericType(val reflect.Value) bool {
 	switch val.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -0,0 +1,106 @@
+package validator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"flugo.com/response"
+	"flugo.com/validator"
+)
+
+type address struct {
+	City string `json:"city" required:"true"`
+}
+
+type orderItem struct {
+	SKU   string `json:"sku" required:"true"`
+	Price int    `json:"price" min:"1"`
+}
+
+type createOrder struct {
+	Email    string      `json:"email" email:"true"`
+	Shipping *address    `json:"shipping"`
+	Billing  address     `json:"billing/default"`
+	Items    []orderItem `json:"items" min_items:"1"`
+}
+
+func validationErrors(t *testing.T, target interface{}) validator.ValidationErrors {
+	t.Helper()
+	var errs validator.ValidationErrors
+	if err := validator.Validate(target); !errors.As(err, &errs) {
+		t.Fatalf("Validate() = %v, want validation errors", err)
+	}
+	return errs
+}
+
+func TestNestedPathsAndCodes(t *testing.T) {
+	errs := validationErrors(t, createOrder{
+		Email:    "not-an-email",
+		Shipping: &address{},
+		Billing:  address{City: "Lyon"},
+		Items:    []orderItem{{SKU: "a", Price: 3}, {SKU: "b", Price: 2}, {Price: -1}},
+	})
+
+	type located struct{ Path, Field, Code string }
+	var got []located
+	for _, err := range errs {
+		got = append(got, located{err.Path, err.Field, err.Code})
+	}
+	want := []located{
+		{"/email", "email", validator.CodeEmail},
+		{"/shipping/city", "shipping.city", validator.CodeRequired},
+		{"/items/2/sku", "items.2.sku", validator.CodeRequired},
+		{"/items/2/price", "items.2.price", validator.CodeMin},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %+v\nwant %+v", got, want)
+	}
+
+	grouped := errs.ToMap()
+	if len(grouped) != 4 || grouped["/items/2/price"][0] != "minimum value is 1" {
+		t.Errorf("ToMap() = %v", grouped)
+	}
+}
+
+func TestPathEscaping(t *testing.T) {
+	errs := validationErrors(t, createOrder{Billing: address{}, Items: []orderItem{{SKU: "a"}}})
+	if len(errs) != 1 || errs[0].Path != "/billing~1default/city" {
+		t.Errorf("errors = %+v, want one at /billing~1default/city", errs)
+	}
+}
+
+func TestCustomCode(t *testing.T) {
+	v := validator.New()
+	v.RegisterCustom("even", func(value interface{}) bool { return value.(int)%2 == 0 }, "")
+
+	var errs validator.ValidationErrors
+	err := v.Validate(struct {
+		Count int `json:"count" even:"true"`
+	}{Count: 3})
+	if !errors.As(err, &errs) || errs[0].Code != "even" || errs[0].Path != "/count" {
+		t.Errorf("Validate() = %+v, want code even at /count", err)
+	}
+}
+
+func TestValidationErrorMapResponse(t *testing.T) {
+	errs := validationErrors(t, createOrder{Items: []orderItem{{SKU: "a", Price: 0}, {}}})
+
+	w := httptest.NewRecorder()
+	response.ValidationErrorMap(w, "Validation failed", errs)
+
+	var body struct {
+		Errors map[string][]string `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 422 || !reflect.DeepEqual(body.Errors, map[string][]string{
+		"/billing~1default/city": {"field is required"},
+		"/items/1/sku":           {"field is required"},
+	}) {
+		t.Errorf("response %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,119 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scheme is how a provider signs its webhooks.
+type Scheme struct {
+	Name string
+	// SignatureHeader is where the provider sends the signature; see
+	// Config.SignatureHeader.
+	SignatureHeader string
+	// Parse reads the Unix timestamp and the candidate signatures from a
+	// request, given the value of the signature header. Providers rotating
+	// secrets send several signatures; any one may match.
+	Parse func(r *http.Request, signature string) (timestamp string, signatures []string, err error)
+	// Sign computes the signature of body sent at timestamp, encoded the way
+	// Parse returns them.
+	Sign func(secret, timestamp string, body []byte) string
+	// Format sets the headers for a signature, as the provider would send
+	// them; used by Headers.
+	Format func(h http.Header, timestamp, signature string)
+}
+
+// Headers returns the headers s would send for body at timestamp, to test
+// receivers or to send webhooks of your own.
+func (s *Scheme) Headers(secret, timestamp string, body []byte) http.Header {
+	h := make(http.Header)
+	s.Format(h, timestamp, s.Sign(secret, timestamp, body))
+	return h
+}
+
+func hmacHex(secret string, parts ...[]byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HMACSHA256 is the default scheme: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" in X-Webhook-Signature, with the Unix timestamp in
+// X-Webhook-Timestamp.
+var HMACSHA256 = &Scheme{
+	Name:            "hmac-sha256",
+	SignatureHeader: "X-Webhook-Signature",
+	Parse: func(r *http.Request, signature string) (string, []string, error) {
+		timestamp := r.Header.Get("X-Webhook-Timestamp")
+		if timestamp == "" {
+			return "", nil, fmt.Errorf("missing X-Webhook-Timestamp header")
+		}
+		return timestamp, []string{strings.TrimPrefix(signature, "sha256=")}, nil
+	},
+	Sign: func(secret, timestamp string, body []byte) string {
+		return hmacHex(secret, []byte(timestamp), []byte("."), body)
+	},
+	Format: func(h http.Header, timestamp, signature string) {
+		h.Set("X-Webhook-Timestamp", timestamp)
+		h.Set("X-Webhook-Signature", signature)
+	},
+}
+
+// Stripe verifies Stripe-Signature headers such as "t=1700000000,v1=...",
+// where each v1 is the hex HMAC-SHA256 of "<t>.<body>".
+var Stripe = &Scheme{
+	Name:            "stripe",
+	SignatureHeader: "Stripe-Signature",
+	Parse: func(r *http.Request, signature string) (string, []string, error) {
+		var timestamp string
+		var signatures []string
+		for _, part := range strings.Split(signature, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				signatures = append(signatures, value)
+			}
+		}
+		if timestamp == "" || len(signatures) == 0 {
+			return "", nil, fmt.Errorf("malformed Stripe-Signature header")
+		}
+		return timestamp, signatures, nil
+	},
+	Sign: func(secret, timestamp string, body []byte) string {
+		return hmacHex(secret, []byte(timestamp), []byte("."), body)
+	},
+	Format: func(h http.Header, timestamp, signature string) {
+		h.Set("Stripe-Signature", "t="+timestamp+",v1="+signature)
+	},
+}
+
+// Slack verifies X-Slack-Signature headers, "v0=" and the hex HMAC-SHA256
+// of "v0:<timestamp>:<body>", with the timestamp in
+// X-Slack-Request-Timestamp.
+var Slack = &Scheme{
+	Name:            "slack",
+	SignatureHeader: "X-Slack-Signature",
+	Parse: func(r *http.Request, signature string) (string, []string, error) {
+		timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+		value, ok := strings.CutPrefix(signature, "v0=")
+		if timestamp == "" || !ok {
+			return "", nil, fmt.Errorf("malformed Slack signature headers")
+		}
+		return timestamp, []string{value}, nil
+	},
+	Sign: func(secret, timestamp string, body []byte) string {
+		return hmacHex(secret, []byte("v0:"+timestamp+":"), body)
+	},
+	Format: func(h http.Header, timestamp, signature string) {
+		h.Set("X-Slack-Request-Timestamp", timestamp)
+		h.Set("X-Slack-Signature", "v0="+signature)
+	},
+}
@@ -0,0 +1,221 @@
+// Package webhooks receives signed webhooks: it checks the signature against
+// the raw body, refuses stale and replayed deliveries and hands the handler
+// the parsed event.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flugo.com/cache"
+	"flugo.com/logger"
+	"flugo.com/reqbody"
+	"flugo.com/response"
+	"flugo.com/router"
+)
+
+// DefaultTolerance is how old a signed timestamp may be, or how far ahead,
+// before the delivery is refused as a replay.
+const DefaultTolerance = 5 * time.Minute
+
+// DefaultDedupeTTL is how long event IDs are remembered when deduplicating.
+const DefaultDedupeTTL = 24 * time.Hour
+
+type Config struct {
+	Secret string
+	// SignatureHeader overrides the header of the Scheme.
+	SignatureHeader string
+	// Scheme is HMACSHA256 when nil.
+	Scheme *Scheme
+	// Tolerance is DefaultTolerance when 0; a negative Tolerance accepts any
+	// timestamp.
+	Tolerance time.Duration
+
+	// Dedupe answers events whose ID was already received within DedupeTTL
+	// with 200 without running the handler, as providers redeliver events
+	// they are unsure about. IDs are kept in Cache, cache.DefaultCache when
+	// nil.
+	Dedupe    bool
+	DedupeTTL time.Duration
+	Cache     *cache.Cache
+}
+
+// Event is the envelope of a verified delivery. ID, Type and Data are read
+// from the JSON body when it has them: "id" or "event_id", "type", and
+// "data" or "event".
+type Event struct {
+	ID        string
+	Type      string
+	Data      json.RawMessage
+	Timestamp time.Time
+	// Body is the raw body as signed; r.Body can still be read as well.
+	Body []byte
+}
+
+type contextKey struct{}
+
+// FromRequest returns the event verified by Receiver, or nil.
+func FromRequest(r *http.Request) *Event {
+	event, _ := r.Context().Value(contextKey{}).(*Event)
+	return event
+}
+
+// Receiver verifies webhooks signed according to cfg before the handler
+// runs; see FromRequest for the event.
+func Receiver(cfg Config) router.MiddlewareFunc {
+	if cfg.Secret == "" {
+		panic("webhooks: Config.Secret is required")
+	}
+	if cfg.Scheme == nil {
+		cfg.Scheme = HMACSHA256
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = cfg.Scheme.SignatureHeader
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = DefaultTolerance
+	}
+	if cfg.DedupeTTL <= 0 {
+		cfg.DedupeTTL = DefaultDedupeTTL
+	}
+
+	return router.NeedsBody(func(next router.HandlerFunc) router.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, err := reqbody.Bytes(r)
+			if errors.Is(err, reqbody.ErrTooLarge) {
+				response.ErrorWithCode(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large", nil)
+				return
+			}
+			if err != nil {
+				response.BadRequest(w, "Failed to read request body")
+				return
+			}
+
+			event, err := verify(cfg, r, body)
+			if err != nil {
+				logger.Warn("Rejected %s webhook from %s: %v", cfg.Scheme.Name, r.RemoteAddr, err)
+				code := "invalid_signature"
+				if errors.Is(err, errStale) {
+					code = "stale_timestamp"
+				}
+				response.ErrorWithCode(w, http.StatusUnauthorized, code, "Invalid webhook signature", nil)
+				return
+			}
+
+			var c *cache.Cache
+			if cfg.Dedupe && event.ID != "" {
+				c = cfg.Cache
+				if c == nil {
+					c = cache.DefaultCache
+				}
+			}
+			key := "webhooks:" + cfg.Scheme.Name + ":" + event.ID
+			if c != nil && !c.Add(key, true, cfg.DedupeTTL) {
+				logger.Info("Skipping duplicate %s webhook %s", cfg.Scheme.Name, event.ID)
+				response.Success(w, map[string]interface{}{"duplicate": true})
+				return
+			}
+
+			// A delivery the handler failed is not a duplicate when the
+			// provider retries it.
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				if c == nil {
+					return
+				}
+				if err := recover(); err != nil {
+					c.Delete(key)
+					panic(err)
+				}
+				if rec.status >= http.StatusInternalServerError {
+					c.Delete(key)
+				}
+			}()
+
+			next(rec, r.WithContext(context.WithValue(r.Context(), contextKey{}, event)))
+		}
+	})
+}
+
+var errStale = errors.New("timestamp outside tolerance")
+
+func verify(cfg Config, r *http.Request, body []byte) (*Event, error) {
+	header := strings.TrimSpace(r.Header.Get(cfg.SignatureHeader))
+	if header == "" {
+		return nil, errors.New("missing " + cfg.SignatureHeader + " header")
+	}
+	timestamp, signatures, err := cfg.Scheme.Parse(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := []byte(cfg.Scheme.Sign(cfg.Secret, timestamp, body))
+	matched := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(strings.ToLower(signature)), expected) {
+			matched = true
+		}
+	}
+	if !matched {
+		return nil, errors.New("signature mismatch")
+	}
+
+	// The timestamp is checked once signed, so it cannot be forged fresh.
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+	sent := time.Unix(seconds, 0)
+	if age := time.Since(sent); cfg.Tolerance > 0 && (age > cfg.Tolerance || age < -cfg.Tolerance) {
+		return nil, errStale
+	}
+
+	event := &Event{Timestamp: sent, Body: body}
+	var envelope struct {
+		ID      string          `json:"id"`
+		EventID string          `json:"event_id"`
+		Type    string          `json:"type"`
+		Data    json.RawMessage `json:"data"`
+		Event   json.RawMessage `json:"event"`
+	}
+	// Bodies that are not JSON objects, such as form posts, have no envelope.
+	if json.Unmarshal(body, &envelope) == nil {
+		event.ID, event.Type, event.Data = envelope.ID, envelope.Type, envelope.Data
+		if event.ID == "" {
+			event.ID = envelope.EventID
+		}
+		if event.Data == nil {
+			event.Data = envelope.Event
+		}
+	}
+	return event, nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
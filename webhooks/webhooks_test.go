@@ -0,0 +1,126 @@
+package webhooks_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"flugo.com/cache"
+	"flugo.com/container"
+	"flugo.com/router"
+	"flugo.com/webhooks"
+)
+
+const secret = "whsec_test"
+
+type receiver struct {
+	*router.Router
+	events []*webhooks.Event
+	status int
+}
+
+func newReceiver(t *testing.T, cfg webhooks.Config) *receiver {
+	rv := &receiver{Router: router.NewRouter(container.NewContainer()), status: http.StatusOK}
+	rv.POST("/hooks", func(w http.ResponseWriter, r *http.Request) {
+		rv.events = append(rv.events, webhooks.FromRequest(r))
+		w.WriteHeader(rv.status)
+	}, webhooks.Receiver(cfg))
+	return rv
+}
+
+func (rv *receiver) deliver(scheme *webhooks.Scheme, sent time.Time, body string, edit func(http.Header)) int {
+	r := httptest.NewRequest("POST", "/hooks", strings.NewReader(body))
+	for key, values := range scheme.Headers(secret, strconv.FormatInt(sent.Unix(), 10), []byte(body)) {
+		r.Header[key] = values
+	}
+	if edit != nil {
+		edit(r.Header)
+	}
+	w := httptest.NewRecorder()
+	rv.ServeHTTP(w, r)
+	return w.Code
+}
+
+const payload = `{"id": "evt_1", "type": "invoice.paid", "data": {"amount": 100}}`
+
+func TestReceiverVerifiesSignature(t *testing.T) {
+	for _, scheme := range []*webhooks.Scheme{webhooks.HMACSHA256, webhooks.Stripe, webhooks.Slack} {
+		rv := newReceiver(t, webhooks.Config{Secret: secret, Scheme: scheme})
+
+		if code := rv.deliver(scheme, time.Now(), payload, nil); code != http.StatusOK {
+			t.Fatalf("%s: valid delivery = %d", scheme.Name, code)
+		}
+		event := rv.events[0]
+		if event.ID != "evt_1" || event.Type != "invoice.paid" || string(event.Data) != `{"amount": 100}` {
+			t.Errorf("%s: event = %+v", scheme.Name, event)
+		}
+
+		// Tampered: the body signed is not the body sent.
+		r := httptest.NewRequest("POST", "/hooks", strings.NewReader(strings.Replace(payload, "100", "999", 1)))
+		for key, values := range scheme.Headers(secret, strconv.FormatInt(time.Now().Unix(), 10), []byte(payload)) {
+			r.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		rv.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "invalid_signature") {
+			t.Errorf("%s: tampered delivery = %d %s", scheme.Name, w.Code, w.Body.String())
+		}
+
+		if code := rv.deliver(scheme, time.Now(), payload, func(h http.Header) {
+			h.Del(scheme.SignatureHeader)
+		}); code != http.StatusUnauthorized {
+			t.Errorf("%s: unsigned delivery = %d", scheme.Name, code)
+		}
+		if len(rv.events) != 1 {
+			t.Errorf("%s: handler ran %d times", scheme.Name, len(rv.events))
+		}
+	}
+}
+
+func TestReceiverRejectsReplays(t *testing.T) {
+	rv := newReceiver(t, webhooks.Config{Secret: secret, Tolerance: time.Minute})
+
+	if code := rv.deliver(webhooks.HMACSHA256, time.Now().Add(-2*time.Minute), payload, nil); code != http.StatusUnauthorized {
+		t.Errorf("stale delivery = %d", code)
+	}
+	if code := rv.deliver(webhooks.HMACSHA256, time.Now().Add(2*time.Minute), payload, nil); code != http.StatusUnauthorized {
+		t.Errorf("future delivery = %d", code)
+	}
+	// A replayed signature cannot be refreshed with a new timestamp.
+	if code := rv.deliver(webhooks.HMACSHA256, time.Now().Add(-2*time.Minute), payload, func(h http.Header) {
+		h.Set("X-Webhook-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	}); code != http.StatusUnauthorized {
+		t.Errorf("re-dated delivery = %d", code)
+	}
+	if len(rv.events) != 0 {
+		t.Errorf("handler ran %d times", len(rv.events))
+	}
+}
+
+func TestReceiverDedupesEvents(t *testing.T) {
+	c := cache.New(100, time.Hour)
+	t.Cleanup(c.Stop)
+	rv := newReceiver(t, webhooks.Config{Secret: secret, Dedupe: true, Cache: c})
+
+	// A failed delivery may be retried.
+	rv.status = http.StatusInternalServerError
+	rv.deliver(webhooks.HMACSHA256, time.Now(), payload, nil)
+	rv.status = http.StatusOK
+
+	for i := 0; i < 2; i++ {
+		if code := rv.deliver(webhooks.HMACSHA256, time.Now(), payload, nil); code != http.StatusOK {
+			t.Errorf("delivery %d = %d", i, code)
+		}
+	}
+	if len(rv.events) != 2 {
+		t.Errorf("handler ran %d times, want a failed attempt and one success", len(rv.events))
+	}
+
+	rv.deliver(webhooks.HMACSHA256, time.Now(), strings.Replace(payload, "evt_1", "evt_2", 1), nil)
+	if len(rv.events) != 3 {
+		t.Error("a new event was treated as a duplicate")
+	}
+}